@@ -0,0 +1,97 @@
+package admin
+
+import (
+	"net/http"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// slowRequestThreshold is the duration above which a request is logged as a warning
+const slowRequestThreshold = 2 * time.Second
+
+// unmatchedRoute is the route of requests no handler is registered for, such as probes and scanners
+const unmatchedRoute = "unmatched"
+
+// RequestObserver records the route, status code and duration of served requests
+type RequestObserver interface {
+	ObserveRequest(route string, code int, duration time.Duration)
+}
+
+// SetObserver records every served request, including rejected and unmatched ones
+func (s *Server) SetObserver(observer RequestObserver) {
+	s.observer = observer
+}
+
+// statusWriter captures the status code and body size of a response
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+	bytes  int
+}
+
+// WriteHeader records the status code
+func (w *statusWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+// Write records the body size; a write without WriteHeader implies 200
+func (w *statusWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	n, err := w.ResponseWriter.Write(b)
+	w.bytes += n
+	return n, err
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer
+func (w *statusWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// logRequests logs every request and passes its outcome to the observer
+// Successful requests are logged at debug, client errors at info, and server errors
+// and requests slower than slowRequestThreshold as warnings
+func (s *Server) logRequests(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Resolve the route up front; rejected requests never reach the mux
+		route := unmatchedRoute
+		if _, pattern := s.mux.Handler(r); pattern != "" {
+			route = pattern
+		}
+
+		start := time.Now()
+		sw := &statusWriter{ResponseWriter: w}
+		next.ServeHTTP(sw, r)
+		duration := time.Since(start)
+		if sw.status == 0 {
+			sw.status = http.StatusOK
+		}
+
+		if s.observer != nil {
+			s.observer.ObserveRequest(route, sw.status, duration)
+		}
+
+		level := zap.DebugLevel
+		switch {
+		case sw.status >= 500 || duration > slowRequestThreshold:
+			level = zap.WarnLevel
+		case sw.status >= 400:
+			level = zap.InfoLevel
+		}
+		s.logger.Log(level, "admin request",
+			zap.String("method", r.Method),
+			zap.String("path", r.URL.Path),
+			zap.String("route", route),
+			zap.Int("status", sw.status),
+			zap.Duration("duration", duration),
+			zap.Int("response_bytes", sw.bytes),
+			zap.String("remote_addr", r.RemoteAddr),
+			zap.String("user_agent", r.UserAgent()),
+		)
+	})
+}
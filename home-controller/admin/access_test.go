@@ -0,0 +1,67 @@
+package admin
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+// recordingObserver collects observed requests
+type recordingObserver struct {
+	routes []string
+	codes  []int
+}
+
+func (o *recordingObserver) ObserveRequest(route string, code int, duration time.Duration) {
+	o.routes = append(o.routes, route)
+	o.codes = append(o.codes, code)
+}
+
+func TestServer_LogRequests(t *testing.T) {
+	core, logs := observer.New(zapcore.DebugLevel)
+	server := New(":0", zap.New(core))
+	server.HandleFunc("GET /api/events", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("[]"))
+	})
+	server.HandleFunc("GET /api/failing", func(w http.ResponseWriter, r *http.Request) {
+		WriteError(w, http.StatusInternalServerError, "failed")
+	})
+	server.SetAuth(Auth{}, []AuthGroup{
+		{PathPrefix: "/api/lease", Auth: Auth{Type: AuthBearer, Token: "secret"}},
+	})
+	server.HandleFunc("POST /api/lease", func(w http.ResponseWriter, r *http.Request) {})
+	recorder := &recordingObserver{}
+	server.SetObserver(recorder)
+
+	requests := []struct {
+		method, path string
+		route        string
+		code         int
+		level        zapcore.Level
+	}{
+		{http.MethodGet, "/api/events", "GET /api/events", http.StatusOK, zapcore.DebugLevel},
+		{http.MethodGet, "/api/failing", "GET /api/failing", http.StatusInternalServerError, zapcore.WarnLevel},
+		{http.MethodPost, "/api/lease", "POST /api/lease", http.StatusUnauthorized, zapcore.InfoLevel},
+		{http.MethodGet, "/wp-login.php", unmatchedRoute, http.StatusNotFound, zapcore.InfoLevel},
+	}
+	for i, tt := range requests {
+		server.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(tt.method, tt.path, nil))
+
+		if recorder.routes[i] != tt.route || recorder.codes[i] != tt.code {
+			t.Errorf("%s %s: expected route %q with %d, got %q with %d",
+				tt.method, tt.path, tt.route, tt.code, recorder.routes[i], recorder.codes[i])
+		}
+		entry := logs.All()[i]
+		if entry.Level != tt.level {
+			t.Errorf("%s %s: expected log level %s, got %s", tt.method, tt.path, tt.level, entry.Level)
+		}
+		if path := entry.ContextMap()["path"]; path != tt.path {
+			t.Errorf("Expected logged path %s, got %v", tt.path, path)
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package admin
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+)
+
+// Authentication types accepted by the admin server
+const (
+	AuthNone   = "none"
+	AuthBearer = "bearer"
+	AuthBasic  = "basic"
+	AuthMTLS   = "mtls" // Client certificate signed by the configured client CA
+)
+
+// Auth contains the credentials required by a group of endpoints
+type Auth struct {
+	Type     string // none, bearer, basic or mtls
+	Token    string // bearer
+	Username string // basic
+	Password string // basic
+}
+
+// AuthGroup applies an Auth to endpoints whose path starts with PathPrefix
+type AuthGroup struct {
+	PathPrefix string
+	Auth       Auth
+}
+
+// BearerAuthorized checks the request's bearer token against token in constant time; any request
+// is authorized when token is empty, for endpoints whose token is optional
+func BearerAuthorized(r *http.Request, token string) bool {
+	if token == "" {
+		return true
+	}
+	bearer, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	return ok && subtle.ConstantTimeCompare([]byte(bearer), []byte(token)) == 1
+}
+
+// authorized checks the request's credentials in constant time
+func (a Auth) authorized(r *http.Request) bool {
+	switch a.Type {
+	case AuthBearer:
+		return BearerAuthorized(r, a.Token)
+	case AuthBasic:
+		username, password, ok := r.BasicAuth()
+		usernameMatch := subtle.ConstantTimeCompare([]byte(username), []byte(a.Username)) == 1
+		passwordMatch := subtle.ConstantTimeCompare([]byte(password), []byte(a.Password)) == 1
+		return ok && usernameMatch && passwordMatch
+	case AuthMTLS:
+		return r.TLS != nil && len(r.TLS.VerifiedChains) > 0
+	}
+	return true
+}
+
+// SetAuth requires defaultAuth for every endpoint, except those matching a group's path prefix,
+// which use the group's auth instead; the longest matching prefix wins
+// Endpoint specific tokens, such as the ingest token, are checked in addition
+func (s *Server) SetAuth(defaultAuth Auth, groups []AuthGroup) {
+	s.defaultAuth = defaultAuth
+	s.authGroups = groups
+}
+
+// authFor returns the auth for a request path
+func (s *Server) authFor(path string) Auth {
+	auth := s.defaultAuth
+	longest := -1
+	for _, group := range s.authGroups {
+		if strings.HasPrefix(path, group.PathPrefix) && len(group.PathPrefix) > longest {
+			auth = group.Auth
+			longest = len(group.PathPrefix)
+		}
+	}
+	return auth
+}
+
+// authenticate rejects requests without the credentials required for their path
+func (s *Server) authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth := s.authFor(r.URL.Path)
+		if auth.authorized(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if auth.Type == AuthBasic {
+			w.Header().Set("WWW-Authenticate", `Basic realm="home-controller"`)
+		}
+		WriteError(w, http.StatusUnauthorized, "unauthorized")
+	})
+}
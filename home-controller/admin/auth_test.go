@@ -0,0 +1,105 @@
+package admin
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+func TestServer_Auth(t *testing.T) {
+	server := New(":0", zap.NewNop())
+	ok := func(w http.ResponseWriter, r *http.Request) {
+		WriteJSON(w, http.StatusOK, Response{Success: true})
+	}
+	server.HandleFunc("GET /api/events", ok)
+	server.HandleFunc("POST /api/v1/write", ok)
+	server.HandleFunc("POST /api/lease", ok)
+	server.SetAuth(Auth{Type: AuthBasic, Username: "admin", Password: "secret"}, []AuthGroup{
+		{PathPrefix: "/api/v1/", Auth: Auth{Type: AuthBearer, Token: "device-token"}},
+		{PathPrefix: "/api/lease", Auth: Auth{Type: AuthNone}},
+	})
+
+	tests := []struct {
+		name   string
+		req    func() *http.Request
+		status int
+	}{
+		{"default group without credentials", func() *http.Request {
+			return httptest.NewRequest(http.MethodGet, "/api/events", nil)
+		}, http.StatusUnauthorized},
+		{"default group with basic auth", func() *http.Request {
+			req := httptest.NewRequest(http.MethodGet, "/api/events", nil)
+			req.SetBasicAuth("admin", "secret")
+			return req
+		}, http.StatusOK},
+		{"default group with wrong password", func() *http.Request {
+			req := httptest.NewRequest(http.MethodGet, "/api/events", nil)
+			req.SetBasicAuth("admin", "wrong")
+			return req
+		}, http.StatusUnauthorized},
+		{"bearer group with basic auth", func() *http.Request {
+			req := httptest.NewRequest(http.MethodPost, "/api/v1/write", nil)
+			req.SetBasicAuth("admin", "secret")
+			return req
+		}, http.StatusUnauthorized},
+		{"bearer group with token", func() *http.Request {
+			req := httptest.NewRequest(http.MethodPost, "/api/v1/write", nil)
+			req.Header.Set("Authorization", "Bearer device-token")
+			return req
+		}, http.StatusOK},
+		{"open group", func() *http.Request {
+			return httptest.NewRequest(http.MethodPost, "/api/lease", nil)
+		}, http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder := httptest.NewRecorder()
+			server.ServeHTTP(recorder, tt.req())
+			if recorder.Code != tt.status {
+				t.Errorf("Expected status %d, got %d", tt.status, recorder.Code)
+			}
+		})
+	}
+}
+
+func TestAuth_MTLS(t *testing.T) {
+	auth := Auth{Type: AuthMTLS}
+
+	req := httptest.NewRequest(http.MethodGet, "/api/events", nil)
+	if auth.authorized(req) {
+		t.Error("Expected plain HTTP request to be rejected")
+	}
+
+	req.TLS = &tls.ConnectionState{}
+	if auth.authorized(req) {
+		t.Error("Expected TLS request without a client certificate to be rejected")
+	}
+
+	req.TLS.VerifiedChains = [][]*x509.Certificate{{{}}}
+	if !auth.authorized(req) {
+		t.Error("Expected TLS request with a verified client certificate to be accepted")
+	}
+}
+
+func TestBearerAuthorized(t *testing.T) {
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/ingest", nil)
+	if !BearerAuthorized(req, "") {
+		t.Error("Expected any request authorized without a token")
+	}
+	if BearerAuthorized(req, "device-token") {
+		t.Error("Expected a request without a bearer token rejected")
+	}
+	req.Header.Set("Authorization", "Bearer wrong")
+	if BearerAuthorized(req, "device-token") {
+		t.Error("Expected a wrong bearer token rejected")
+	}
+	req.Header.Set("Authorization", "Bearer device-token")
+	if !BearerAuthorized(req, "device-token") {
+		t.Error("Expected the bearer token accepted")
+	}
+}
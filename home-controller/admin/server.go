@@ -0,0 +1,104 @@
+package admin
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// Response is the JSON body returned by admin endpoints
+type Response struct {
+	Success bool        `json:"success"`
+	Message string      `json:"message,omitempty"`
+	Data    interface{} `json:"data,omitempty"`
+}
+
+// Server is the embedded HTTP server exposing admin endpoints
+type Server struct {
+	server *http.Server
+	mux    *http.ServeMux
+	logger *zap.Logger
+
+	defaultAuth Auth
+	authGroups  []AuthGroup
+	observer    RequestObserver
+}
+
+// New creates a new admin server listening on listenAddress
+func New(listenAddress string, logger *zap.Logger) *Server {
+	s := &Server{
+		server: &http.Server{
+			Addr:              listenAddress,
+			ReadHeaderTimeout: 10 * time.Second,
+		},
+		mux:    http.NewServeMux(),
+		logger: logger,
+	}
+	s.server.Handler = s.logRequests(s.authenticate(s.mux))
+	return s
+}
+
+// HandleFunc registers a handler for the pattern, e.g. "POST /api/airquality/calibrate"
+func (s *Server) HandleFunc(pattern string, handler http.HandlerFunc) {
+	s.mux.HandleFunc(pattern, handler)
+}
+
+// ServeHTTP dispatches the request to the registered handlers
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.server.Handler.ServeHTTP(w, r)
+}
+
+// Start serves requests until the context is cancelled
+func (s *Server) Start(ctx context.Context) error {
+	errChan := make(chan error, 1)
+	go func() {
+		s.logger.Info("admin server listening",
+			zap.String("address", s.server.Addr),
+			zap.Bool("tls", s.server.TLSConfig != nil),
+		)
+		var err error
+		if s.server.TLSConfig != nil {
+			err = s.server.ListenAndServeTLS("", "")
+		} else {
+			err = s.server.ListenAndServe()
+		}
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
+			errChan <- err
+		}
+		close(errChan)
+	}()
+
+	select {
+	case err := <-errChan:
+		if err != nil {
+			return fmt.Errorf("admin server failed: %w", err)
+		}
+		return nil
+	case <-ctx.Done():
+	}
+
+	s.logger.Info("stopping admin server")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := s.server.Shutdown(shutdownCtx); err != nil {
+		return fmt.Errorf("failed to shut down admin server: %w", err)
+	}
+	return nil
+}
+
+// WriteJSON writes a JSON response with the given status code
+func WriteJSON(w http.ResponseWriter, status int, response Response) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(response)
+}
+
+// WriteError writes a failed JSON response with the given status code
+func WriteError(w http.ResponseWriter, status int, message string) {
+	WriteJSON(w, status, Response{Success: false, Message: message})
+}
@@ -0,0 +1,117 @@
+package admin
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/hex"
+	"fmt"
+	"math/big"
+	"net"
+	"os"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// selfSignedValidity is how long a generated certificate is valid; it is regenerated on every start
+const selfSignedValidity = 365 * 24 * time.Hour
+
+// SetTLS serves HTTPS with the certificate and key; with a client CA, client certificates signed
+// by it are verified when presented, which endpoints with mtls auth then require
+func (s *Server) SetTLS(certFile, keyFile, clientCAFile string) error {
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return fmt.Errorf("failed to load server certificate: %w", err)
+	}
+	return s.setTLS(cert, clientCAFile)
+}
+
+// SetSelfSignedTLS serves HTTPS with a certificate generated for the hostname, localhost and the
+// local IP addresses; its SHA-256 fingerprint is logged so clients can pin it
+func (s *Server) SetSelfSignedTLS(clientCAFile string) error {
+	cert, err := selfSignedCertificate(time.Now())
+	if err != nil {
+		return err
+	}
+	fingerprint := sha256.Sum256(cert.Certificate[0])
+	s.logger.Info("generated self-signed admin certificate",
+		zap.String("sha256_fingerprint", hex.EncodeToString(fingerprint[:])),
+		zap.Strings("dns_names", cert.Leaf.DNSNames),
+	)
+	return s.setTLS(cert, clientCAFile)
+}
+
+// setTLS configures the server certificate and optional client CA
+func (s *Server) setTLS(cert tls.Certificate, clientCAFile string) error {
+	tlsConfig := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}
+
+	if clientCAFile != "" {
+		caCert, err := os.ReadFile(clientCAFile)
+		if err != nil {
+			return fmt.Errorf("failed to read client CA file: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(caCert) {
+			return fmt.Errorf("no certificates found in client CA file %s", clientCAFile)
+		}
+		tlsConfig.ClientCAs = pool
+		tlsConfig.ClientAuth = tls.VerifyClientCertIfGiven
+	}
+
+	s.server.TLSConfig = tlsConfig
+	return nil
+}
+
+// selfSignedCertificate generates an ECDSA P-256 certificate valid from now
+func selfSignedCertificate(now time.Time) (tls.Certificate, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return tls.Certificate{}, fmt.Errorf("failed to generate key: %w", err)
+	}
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return tls.Certificate{}, fmt.Errorf("failed to generate serial number: %w", err)
+	}
+
+	dnsNames := []string{"localhost"}
+	if hostname, err := os.Hostname(); err == nil && hostname != "localhost" {
+		dnsNames = append([]string{hostname, hostname + ".local"}, dnsNames...)
+	}
+	ips := []net.IP{net.IPv4(127, 0, 0, 1), net.IPv6loopback}
+	if addrs, err := net.InterfaceAddrs(); err == nil {
+		for _, addr := range addrs {
+			if ipNet, ok := addr.(*net.IPNet); ok && !ipNet.IP.IsLoopback() {
+				ips = append(ips, ipNet.IP)
+			}
+		}
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: dnsNames[0], Organization: []string{"home-controller"}},
+		NotBefore:    now.Add(-time.Hour), // Tolerate clients with a slightly slow clock
+		NotAfter:     now.Add(selfSignedValidity),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		DNSNames:     dnsNames,
+		IPAddresses:  ips,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return tls.Certificate{}, fmt.Errorf("failed to create certificate: %w", err)
+	}
+	leaf, err := x509.ParseCertificate(der)
+	if err != nil {
+		return tls.Certificate{}, fmt.Errorf("failed to parse certificate: %w", err)
+	}
+
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key, Leaf: leaf}, nil
+}
@@ -0,0 +1,81 @@
+package admin
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+func TestServer_SelfSignedTLS(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	address := listener.Addr().String()
+	listener.Close()
+
+	server := New(address, zap.NewNop())
+	server.HandleFunc("GET /api/ping", func(w http.ResponseWriter, r *http.Request) {
+		WriteJSON(w, http.StatusOK, Response{Success: true})
+	})
+	if err := server.SetSelfSignedTLS(""); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go server.Start(ctx)
+
+	// Trust only the generated certificate, as a client pinning it would
+	pool := x509.NewCertPool()
+	pool.AddCert(server.server.TLSConfig.Certificates[0].Leaf)
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: pool}}}
+
+	var resp *http.Response
+	for i := 0; i < 50; i++ {
+		resp, err = client.Get("https://" + address + "/api/ping")
+		if err == nil {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if err != nil {
+		t.Fatalf("Expected HTTPS request to succeed, got: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("Expected status 200, got %d", resp.StatusCode)
+	}
+
+	// The TLS listener answers plain HTTP with 400 instead of serving the endpoint
+	plain, err := http.Get("http://" + address + "/api/ping")
+	if err == nil {
+		plain.Body.Close()
+		if plain.StatusCode != http.StatusBadRequest {
+			t.Errorf("Expected plain HTTP to be rejected, got status %d", plain.StatusCode)
+		}
+	}
+}
+
+func TestSelfSignedCertificate(t *testing.T) {
+	now := time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)
+	cert, err := selfSignedCertificate(now)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if err := cert.Leaf.VerifyHostname("localhost"); err != nil {
+		t.Errorf("Expected certificate valid for localhost: %v", err)
+	}
+	if err := cert.Leaf.VerifyHostname("127.0.0.1"); err != nil {
+		t.Errorf("Expected certificate valid for 127.0.0.1: %v", err)
+	}
+	if !cert.Leaf.NotAfter.Equal(now.Add(selfSignedValidity)) {
+		t.Errorf("Expected expiry %v, got %v", now.Add(selfSignedValidity), cert.Leaf.NotAfter)
+	}
+}
@@ -0,0 +1,82 @@
+package airquality
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/mjasion/balena-home/thermostats/admin"
+)
+
+// ErrUnknownSensor is returned when a command targets a sensor ID that is not configured
+var ErrUnknownSensor = errors.New("unknown sensor ID")
+
+// RegisterHandlers registers the calibration endpoints on the admin server
+func (p *Poller) RegisterHandlers(server *admin.Server) {
+	server.HandleFunc("POST /api/airquality/calibrate", p.handleCalibrate)
+	server.HandleFunc("POST /api/airquality/autocalibration", p.handleAutoCalibration)
+}
+
+// handleCalibrate handles POST /api/airquality/calibrate?sensor_id=<id>&ppm=<target>
+func (p *Poller) handleCalibrate(w http.ResponseWriter, r *http.Request) {
+	sensorID, err := strconv.Atoi(r.URL.Query().Get("sensor_id"))
+	if err != nil {
+		admin.WriteError(w, http.StatusBadRequest, "sensor_id must be an integer")
+		return
+	}
+
+	// Fresh outdoor air is the usual reference
+	targetPPM := 400
+	if value := r.URL.Query().Get("ppm"); value != "" {
+		targetPPM, err = strconv.Atoi(value)
+		if err != nil || targetPPM < 400 || targetPPM > 2000 {
+			admin.WriteError(w, http.StatusBadRequest, "ppm must be an integer between 400 and 2000")
+			return
+		}
+	}
+
+	if err := p.Calibrate(sensorID, targetPPM); err != nil {
+		writeCommandError(w, err)
+		return
+	}
+
+	admin.WriteJSON(w, http.StatusOK, admin.Response{
+		Success: true,
+		Message: fmt.Sprintf("Calibrated sensor %d to %d ppm.", sensorID, targetPPM),
+	})
+}
+
+// handleAutoCalibration handles POST /api/airquality/autocalibration?sensor_id=<id>&enabled=<bool>
+func (p *Poller) handleAutoCalibration(w http.ResponseWriter, r *http.Request) {
+	sensorID, err := strconv.Atoi(r.URL.Query().Get("sensor_id"))
+	if err != nil {
+		admin.WriteError(w, http.StatusBadRequest, "sensor_id must be an integer")
+		return
+	}
+
+	enabled, err := strconv.ParseBool(r.URL.Query().Get("enabled"))
+	if err != nil {
+		admin.WriteError(w, http.StatusBadRequest, "enabled must be true or false")
+		return
+	}
+
+	if err := p.SetAutoCalibration(sensorID, enabled); err != nil {
+		writeCommandError(w, err)
+		return
+	}
+
+	admin.WriteJSON(w, http.StatusOK, admin.Response{
+		Success: true,
+		Message: fmt.Sprintf("Set automatic calibration of sensor %d to %t.", sensorID, enabled),
+	})
+}
+
+// writeCommandError maps a sensor command error to a response
+func writeCommandError(w http.ResponseWriter, err error) {
+	if errors.Is(err, ErrUnknownSensor) {
+		admin.WriteError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	admin.WriteError(w, http.StatusInternalServerError, err.Error())
+}
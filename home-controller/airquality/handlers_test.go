@@ -0,0 +1,143 @@
+package airquality
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/mjasion/balena-home/thermostats/admin"
+	"github.com/mjasion/balena-home/thermostats/buffer"
+	"go.uber.org/zap"
+)
+
+// fakeSensor records calibration commands
+type fakeSensor struct {
+	calibratedPPM   int
+	autoCalibration *bool
+	err             error
+}
+
+func (s *fakeSensor) Read() (*Measurement, error) {
+	return &Measurement{CO2PPM: 612}, s.err
+}
+
+func (s *fakeSensor) Calibrate(targetPPM int) error {
+	s.calibratedPPM = targetPPM
+	return s.err
+}
+
+func (s *fakeSensor) SetAutoCalibration(enabled bool) error {
+	s.autoCalibration = &enabled
+	return s.err
+}
+
+func (s *fakeSensor) Close() error { return nil }
+
+func newTestPoller(sensor *fakeSensor) *Poller {
+	poller := NewPoller(
+		[]SensorConfig{{Name: "Salon", ID: 1, Model: ModelMHZ19, Device: "/dev/null"}},
+		buffer.New(10, zap.NewNop()),
+		30,
+		zap.NewNop(),
+	)
+	poller.open = func(SensorConfig) (Sensor, error) { return sensor, nil }
+	return poller
+}
+
+func TestPoller_ReadAndBuffer(t *testing.T) {
+	buf := buffer.New(10, zap.NewNop())
+	poller := newTestPoller(&fakeSensor{})
+	poller.buffer = buf
+
+	poller.readAndBuffer()
+
+	readings := buf.GetAll()
+	if len(readings) != 1 {
+		t.Fatalf("Expected 1 reading, got %d", len(readings))
+	}
+	reading := readings[0].AirQuality
+	if reading == nil || reading.CO2PPM != 612 || reading.SensorName != "Salon" {
+		t.Errorf("Unexpected reading: %+v", reading)
+	}
+}
+
+func TestPoller_ReadFailureReopens(t *testing.T) {
+	sensor := &fakeSensor{err: errors.New("timeout")}
+	poller := newTestPoller(sensor)
+
+	poller.readAndBuffer()
+
+	if len(poller.devices) != 0 {
+		t.Errorf("Expected failed sensor to be closed, got %d open devices", len(poller.devices))
+	}
+}
+
+func TestHandleCalibrate(t *testing.T) {
+	sensor := &fakeSensor{}
+	server := admin.New(":0", zap.NewNop())
+	newTestPoller(sensor).RegisterHandlers(server)
+
+	tests := []struct {
+		name           string
+		query          string
+		expectedStatus int
+		expectedPPM    int
+	}{
+		{name: "default ppm", query: "sensor_id=1", expectedStatus: http.StatusOK, expectedPPM: 400},
+		{name: "explicit ppm", query: "sensor_id=1&ppm=450", expectedStatus: http.StatusOK, expectedPPM: 450},
+		{name: "invalid sensor", query: "sensor_id=abc", expectedStatus: http.StatusBadRequest},
+		{name: "unknown sensor", query: "sensor_id=7", expectedStatus: http.StatusNotFound},
+		{name: "ppm out of range", query: "sensor_id=1&ppm=100", expectedStatus: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sensor.calibratedPPM = 0
+			req := httptest.NewRequest(http.MethodPost, "/api/airquality/calibrate?"+tt.query, nil)
+			rec := httptest.NewRecorder()
+			server.ServeHTTP(rec, req)
+
+			if rec.Code != tt.expectedStatus {
+				t.Errorf("Expected status %d, got %d", tt.expectedStatus, rec.Code)
+			}
+			if sensor.calibratedPPM != tt.expectedPPM {
+				t.Errorf("Expected calibration to %d ppm, got %d", tt.expectedPPM, sensor.calibratedPPM)
+			}
+
+			var response admin.Response
+			if err := json.NewDecoder(rec.Body).Decode(&response); err != nil {
+				t.Fatalf("Failed to decode response: %v", err)
+			}
+			if response.Success != (tt.expectedStatus == http.StatusOK) {
+				t.Errorf("Unexpected success flag in response: %+v", response)
+			}
+		})
+	}
+}
+
+func TestHandleAutoCalibration(t *testing.T) {
+	sensor := &fakeSensor{}
+	server := admin.New(":0", zap.NewNop())
+	newTestPoller(sensor).RegisterHandlers(server)
+
+	req := httptest.NewRequest(http.MethodPost, "/api/airquality/autocalibration?sensor_id=1&enabled=false", nil)
+	rec := httptest.NewRecorder()
+	server.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Errorf("Expected status 200, got %d", rec.Code)
+	}
+	if sensor.autoCalibration == nil || *sensor.autoCalibration {
+		t.Errorf("Expected automatic calibration to be disabled")
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/api/airquality/autocalibration?sensor_id=1&enabled=maybe", nil)
+	rec = httptest.NewRecorder()
+	server.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400, got %d", rec.Code)
+	}
+}
@@ -0,0 +1,104 @@
+package airquality
+
+import (
+	"fmt"
+	"io"
+)
+
+const (
+	mhz19CmdRead      = 0x86
+	mhz19CmdZeroPoint = 0x87
+	mhz19CmdABC       = 0x79
+	mhz19ABCOn        = 0xA0
+	mhz19ZeroPointPPM = 400
+	mhz19FrameLength  = 9
+	mhz19StartByte    = 0xFF
+	mhz19SensorNumber = 0x01
+)
+
+// MHZ19 reads CO2 concentration from a Winsen MH-Z19 over UART
+type MHZ19 struct {
+	port io.ReadWriteCloser
+}
+
+// NewMHZ19 creates a new MH-Z19 sensor on an opened serial port (9600 8N1)
+func NewMHZ19(port io.ReadWriteCloser) *MHZ19 {
+	return &MHZ19{port: port}
+}
+
+// Read requests the current CO2 concentration
+// Response format (9 bytes):
+// - Byte 0: Start byte (0xFF)
+// - Byte 1: Command (0x86)
+// - Bytes 2-3: CO2 concentration in ppm (big endian)
+// - Bytes 4-7: Undocumented (temperature + 40, status)
+// - Byte 8: Checksum
+func (s *MHZ19) Read() (*Measurement, error) {
+	response, err := s.command(mhz19CmdRead, 0, true)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Measurement{
+		CO2PPM: float64(int(response[2])<<8 | int(response[3])),
+	}, nil
+}
+
+// Calibrate performs a zero point calibration; the sensor must be in fresh air (400 ppm)
+func (s *MHZ19) Calibrate(targetPPM int) error {
+	if targetPPM != mhz19ZeroPointPPM {
+		return fmt.Errorf("MH-Z19 only supports zero point calibration at %d ppm", mhz19ZeroPointPPM)
+	}
+	_, err := s.command(mhz19CmdZeroPoint, 0, false)
+	return err
+}
+
+// SetAutoCalibration enables or disables automatic baseline correction (ABC)
+func (s *MHZ19) SetAutoCalibration(enabled bool) error {
+	var arg byte
+	if enabled {
+		arg = mhz19ABCOn
+	}
+	_, err := s.command(mhz19CmdABC, arg, false)
+	return err
+}
+
+// Close closes the serial port
+func (s *MHZ19) Close() error {
+	return s.port.Close()
+}
+
+// command sends a command frame and optionally reads the response frame
+func (s *MHZ19) command(cmd, arg byte, readResponse bool) ([]byte, error) {
+	frame := []byte{mhz19StartByte, mhz19SensorNumber, cmd, arg, 0, 0, 0, 0, 0}
+	frame[8] = mhz19Checksum(frame)
+
+	if _, err := s.port.Write(frame); err != nil {
+		return nil, fmt.Errorf("failed to send command 0x%02x: %w", cmd, err)
+	}
+	if !readResponse {
+		return nil, nil
+	}
+
+	response := make([]byte, mhz19FrameLength)
+	if _, err := io.ReadFull(s.port, response); err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+	if response[0] != mhz19StartByte || response[1] != cmd {
+		return nil, fmt.Errorf("unexpected response header 0x%02x 0x%02x", response[0], response[1])
+	}
+	if mhz19Checksum(response) != response[8] {
+		return nil, fmt.Errorf("response checksum mismatch")
+	}
+
+	return response, nil
+}
+
+// mhz19Checksum computes the frame checksum: 0xFF - sum(bytes 1-7) + 1
+func mhz19Checksum(frame []byte) byte {
+	var sum byte
+	for _, b := range frame[1:8] {
+		sum += b
+	}
+	return 0xFF - sum + 1
+}
@@ -0,0 +1,106 @@
+package airquality
+
+import (
+	"bytes"
+	"testing"
+)
+
+// fakePort records written frames and replays a canned response
+type fakePort struct {
+	written  bytes.Buffer
+	response bytes.Buffer
+	closed   bool
+}
+
+func (p *fakePort) Write(data []byte) (int, error) { return p.written.Write(data) }
+func (p *fakePort) Read(data []byte) (int, error)  { return p.response.Read(data) }
+func (p *fakePort) Close() error {
+	p.closed = true
+	return nil
+}
+
+func TestMHZ19Checksum(t *testing.T) {
+	// Read command from the datasheet: FF 01 86 00 00 00 00 00 79
+	frame := []byte{0xFF, 0x01, 0x86, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00}
+
+	if got := mhz19Checksum(frame); got != 0x79 {
+		t.Errorf("Expected checksum 0x79, got 0x%02x", got)
+	}
+}
+
+func TestMHZ19_Read(t *testing.T) {
+	port := &fakePort{}
+	response := []byte{0xFF, 0x86, 0x02, 0x60, 0x47, 0x00, 0x00, 0x00, 0x00}
+	response[8] = mhz19Checksum(response)
+	port.response.Write(response)
+
+	sensor := NewMHZ19(port)
+	measurement, err := sensor.Read()
+	if err != nil {
+		t.Fatalf("Read failed: %v", err)
+	}
+
+	if measurement.CO2PPM != 608 {
+		t.Errorf("Expected 608 ppm, got %f", measurement.CO2PPM)
+	}
+	if measurement.HasClimate {
+		t.Error("Expected MH-Z19 measurement without climate data")
+	}
+
+	expected := []byte{0xFF, 0x01, 0x86, 0x00, 0x00, 0x00, 0x00, 0x00, 0x79}
+	if !bytes.Equal(port.written.Bytes(), expected) {
+		t.Errorf("Expected command % x, got % x", expected, port.written.Bytes())
+	}
+}
+
+func TestMHZ19_ReadChecksumMismatch(t *testing.T) {
+	port := &fakePort{}
+	port.response.Write([]byte{0xFF, 0x86, 0x02, 0x60, 0x47, 0x00, 0x00, 0x00, 0x00})
+
+	sensor := NewMHZ19(port)
+	if _, err := sensor.Read(); err == nil {
+		t.Error("Expected checksum error, got nil")
+	}
+}
+
+func TestMHZ19_Calibrate(t *testing.T) {
+	port := &fakePort{}
+	sensor := NewMHZ19(port)
+
+	if err := sensor.Calibrate(800); err == nil {
+		t.Error("Expected error for span other than 400 ppm, got nil")
+	}
+	if port.written.Len() != 0 {
+		t.Errorf("Expected no command to be sent, got % x", port.written.Bytes())
+	}
+
+	if err := sensor.Calibrate(400); err != nil {
+		t.Fatalf("Calibrate failed: %v", err)
+	}
+	expected := []byte{0xFF, 0x01, 0x87, 0x00, 0x00, 0x00, 0x00, 0x00, 0x78}
+	if !bytes.Equal(port.written.Bytes(), expected) {
+		t.Errorf("Expected command % x, got % x", expected, port.written.Bytes())
+	}
+}
+
+func TestMHZ19_SetAutoCalibration(t *testing.T) {
+	tests := []struct {
+		enabled  bool
+		expected []byte
+	}{
+		{enabled: true, expected: []byte{0xFF, 0x01, 0x79, 0xA0, 0x00, 0x00, 0x00, 0x00, 0xE6}},
+		{enabled: false, expected: []byte{0xFF, 0x01, 0x79, 0x00, 0x00, 0x00, 0x00, 0x00, 0x86}},
+	}
+
+	for _, tt := range tests {
+		port := &fakePort{}
+		sensor := NewMHZ19(port)
+
+		if err := sensor.SetAutoCalibration(tt.enabled); err != nil {
+			t.Fatalf("SetAutoCalibration(%t) failed: %v", tt.enabled, err)
+		}
+		if !bytes.Equal(port.written.Bytes(), tt.expected) {
+			t.Errorf("SetAutoCalibration(%t): expected command % x, got % x", tt.enabled, tt.expected, port.written.Bytes())
+		}
+	}
+}
@@ -0,0 +1,214 @@
+package airquality
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/mjasion/balena-home/thermostats/buffer"
+	"github.com/mjasion/balena-home/thermostats/clock"
+	"github.com/mjasion/balena-home/thermostats/schedule"
+	"go.uber.org/zap"
+)
+
+// Poller periodically reads CO2 sensors and adds readings to the buffer
+type Poller struct {
+	sensors      []SensorConfig
+	open         func(SensorConfig) (Sensor, error)
+	mu           sync.Mutex     // Serializes sensor access between polling and admin commands
+	devices      map[int]Sensor // Opened sensors by config ID
+	buffer       *buffer.RingBuffer
+	logger       *zap.Logger
+	readInterval time.Duration
+	skipper      schedule.Skipper
+	clock        clock.Clock
+}
+
+// NewPoller creates a new air quality poller
+func NewPoller(sensors []SensorConfig, buf *buffer.RingBuffer, readIntervalSeconds int, logger *zap.Logger) *Poller {
+	return &Poller{
+		sensors:      sensors,
+		open:         Open,
+		devices:      make(map[int]Sensor),
+		buffer:       buf,
+		logger:       logger,
+		readInterval: time.Duration(readIntervalSeconds) * time.Second,
+		clock:        clock.Real,
+	}
+}
+
+// SetCadence reads only every cadence.Multiplier() intervals, e.g. less often while nobody is home
+func (p *Poller) SetCadence(cadence schedule.Cadence) {
+	p.skipper = schedule.NewSkipper(cadence)
+}
+
+// SetClock sets the clock driving the read ticker and reading timestamps, e.g. a fake one in tests
+func (p *Poller) SetClock(c clock.Clock) {
+	p.clock = c
+}
+
+// Start starts the polling loop
+func (p *Poller) Start(ctx context.Context) {
+	p.logger.Info("starting air quality poller",
+		zap.Duration("read_interval", p.readInterval),
+		zap.Int("sensor_count", len(p.sensors)),
+	)
+
+	// Create ticker for periodic reading
+	ticker := p.clock.NewTicker(p.readInterval)
+	defer ticker.Stop()
+
+	// Read immediately on start
+	if err := p.readAndBuffer(); err != nil {
+		p.logger.Info("buffer closed, stopping air quality poller")
+		p.closeAll()
+		return
+	}
+
+	// Then read at regular intervals
+	for {
+		select {
+		case <-ctx.Done():
+			p.logger.Info("stopping air quality poller")
+			p.closeAll()
+			return
+		case <-ticker.C():
+			if p.skipper.Skip() {
+				continue
+			}
+			if err := p.readAndBuffer(); err != nil {
+				p.logger.Info("buffer closed, stopping air quality poller")
+				p.closeAll()
+				return
+			}
+		}
+	}
+}
+
+// Calibrate runs a calibration command on the sensor with the given config ID
+func (p *Poller) Calibrate(sensorID, targetPPM int) error {
+	return p.withDevice(sensorID, func(cfg SensorConfig, device Sensor) error {
+		p.logger.Info("calibrating CO2 sensor",
+			zap.String("sensor_name", cfg.Name),
+			zap.Int("target_ppm", targetPPM),
+		)
+		return device.Calibrate(targetPPM)
+	})
+}
+
+// SetAutoCalibration toggles automatic baseline correction on the sensor with the given config ID
+func (p *Poller) SetAutoCalibration(sensorID int, enabled bool) error {
+	return p.withDevice(sensorID, func(cfg SensorConfig, device Sensor) error {
+		p.logger.Info("setting CO2 sensor automatic calibration",
+			zap.String("sensor_name", cfg.Name),
+			zap.Bool("enabled", enabled),
+		)
+		return device.SetAutoCalibration(enabled)
+	})
+}
+
+// withDevice runs fn with the opened sensor for sensorID while holding the lock
+func (p *Poller) withDevice(sensorID int, fn func(SensorConfig, Sensor) error) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for _, cfg := range p.sensors {
+		if cfg.ID != sensorID {
+			continue
+		}
+		device, err := p.device(cfg)
+		if err != nil {
+			return err
+		}
+		return fn(cfg, device)
+	}
+
+	return fmt.Errorf("%w: %d", ErrUnknownSensor, sensorID)
+}
+
+// device returns the opened sensor, opening it if needed; callers must hold the lock
+func (p *Poller) device(cfg SensorConfig) (Sensor, error) {
+	if device, ok := p.devices[cfg.ID]; ok {
+		return device, nil
+	}
+
+	device, err := p.open(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open sensor %s: %w", cfg.Name, err)
+	}
+	p.devices[cfg.ID] = device
+	return device, nil
+}
+
+// readAndBuffer reads all configured sensors and adds the readings to the buffer
+// Returns buffer.ErrClosed once the buffer no longer accepts readings
+func (p *Poller) readAndBuffer() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	count := 0
+	for _, cfg := range p.sensors {
+		// Sensors that failed to initialize are retried on every interval
+		device, err := p.device(cfg)
+		if err != nil {
+			p.logger.Warn("failed to open CO2 sensor",
+				zap.String("sensor_name", cfg.Name),
+				zap.String("model", cfg.Model),
+				zap.Error(err),
+			)
+			continue
+		}
+
+		measurement, err := device.Read()
+		if err != nil {
+			p.logger.Warn("failed to read CO2 sensor, reopening on next interval",
+				zap.String("sensor_name", cfg.Name),
+				zap.String("model", cfg.Model),
+				zap.Error(err),
+			)
+			device.Close()
+			delete(p.devices, cfg.ID)
+			continue
+		}
+
+		err = p.buffer.Add(&buffer.Reading{
+			Type: buffer.ReadingTypeAirQuality,
+			AirQuality: &buffer.AirQualityReading{
+				Timestamp:          p.clock.Now(),
+				SensorName:         cfg.Name,
+				SensorID:           cfg.ID,
+				Model:              cfg.Model,
+				CO2PPM:             measurement.CO2PPM,
+				TemperatureCelsius: measurement.TemperatureCelsius,
+				HumidityPercent:    measurement.HumidityPercent,
+				HasClimate:         measurement.HasClimate,
+			},
+		})
+		if err != nil {
+			return err
+		}
+		count++
+
+		p.logger.Debug("added air quality reading to buffer",
+			zap.String("sensor_name", cfg.Name),
+			zap.Float64("co2_ppm", measurement.CO2PPM),
+		)
+	}
+
+	p.logger.Info("read and buffered air quality data",
+		zap.Int("reading_count", count),
+	)
+	return nil
+}
+
+// closeAll closes every opened sensor
+func (p *Poller) closeAll() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for id, device := range p.devices {
+		device.Close()
+		delete(p.devices, id)
+	}
+}
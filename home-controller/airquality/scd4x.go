@@ -0,0 +1,155 @@
+package airquality
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/mjasion/balena-home/thermostats/i2csensor"
+)
+
+const (
+	scd4xCmdStartPeriodic     = 0x21B1
+	scd4xCmdStopPeriodic      = 0x3F86
+	scd4xCmdReadMeasurement   = 0xEC05
+	scd4xCmdDataReady         = 0xE4B8
+	scd4xCmdForcedRecalibrate = 0x362F
+	scd4xCmdSetASC            = 0x2416
+	scd4xStopDelay            = 500 * time.Millisecond
+	scd4xFRCFailed            = 0xFFFF
+)
+
+// SCD4x reads CO2, temperature and humidity from a Sensirion SCD40/SCD41
+type SCD4x struct {
+	bus   i2csensor.Bus
+	sleep func(time.Duration)
+}
+
+// NewSCD4x restarts periodic measurement on the sensor
+func NewSCD4x(bus i2csensor.Bus) (*SCD4x, error) {
+	s := &SCD4x{bus: bus, sleep: time.Sleep}
+
+	// Stop any measurement left running by a previous process before restarting
+	s.writeCommand(scd4xCmdStopPeriodic)
+	s.sleep(scd4xStopDelay)
+
+	if err := s.writeCommand(scd4xCmdStartPeriodic); err != nil {
+		return nil, fmt.Errorf("failed to start periodic measurement: %w", err)
+	}
+	return s, nil
+}
+
+// Read returns the latest periodic measurement (updated every 5 seconds)
+func (s *SCD4x) Read() (*Measurement, error) {
+	ready, err := s.readWords(scd4xCmdDataReady, 1)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read data ready status: %w", err)
+	}
+	if ready[0]&0x07FF == 0 {
+		return nil, fmt.Errorf("no new measurement available")
+	}
+
+	words, err := s.readWords(scd4xCmdReadMeasurement, 3)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read measurement: %w", err)
+	}
+
+	return &Measurement{
+		CO2PPM:             float64(words[0]),
+		TemperatureCelsius: -45.0 + 175.0*float64(words[1])/65535.0,
+		HumidityPercent:    100.0 * float64(words[2]) / 65535.0,
+		HasClimate:         true,
+	}, nil
+}
+
+// Calibrate performs a forced recalibration to targetPPM
+// The sensor should have been operating in a stable environment for at least 3 minutes
+func (s *SCD4x) Calibrate(targetPPM int) error {
+	return s.whileStopped(func() error {
+		if err := s.writeCommand(scd4xCmdForcedRecalibrate, uint16(targetPPM)); err != nil {
+			return fmt.Errorf("failed to send forced recalibration: %w", err)
+		}
+		s.sleep(400 * time.Millisecond)
+
+		correction, err := s.readResponse(1)
+		if err != nil {
+			return fmt.Errorf("failed to read forced recalibration result: %w", err)
+		}
+		if correction[0] == scd4xFRCFailed {
+			return fmt.Errorf("forced recalibration failed")
+		}
+		return nil
+	})
+}
+
+// SetAutoCalibration enables or disables automatic self-calibration (ASC)
+func (s *SCD4x) SetAutoCalibration(enabled bool) error {
+	var arg uint16
+	if enabled {
+		arg = 1
+	}
+	return s.whileStopped(func() error {
+		if err := s.writeCommand(scd4xCmdSetASC, arg); err != nil {
+			return fmt.Errorf("failed to set automatic self-calibration: %w", err)
+		}
+		s.sleep(time.Millisecond)
+		return nil
+	})
+}
+
+// Close stops periodic measurement and closes the bus
+func (s *SCD4x) Close() error {
+	s.writeCommand(scd4xCmdStopPeriodic)
+	return s.bus.Close()
+}
+
+// whileStopped runs fn with periodic measurement stopped, as required by configuration commands
+func (s *SCD4x) whileStopped(fn func() error) error {
+	if err := s.writeCommand(scd4xCmdStopPeriodic); err != nil {
+		return fmt.Errorf("failed to stop periodic measurement: %w", err)
+	}
+	s.sleep(scd4xStopDelay)
+
+	fnErr := fn()
+
+	if err := s.writeCommand(scd4xCmdStartPeriodic); err != nil {
+		return fmt.Errorf("failed to restart periodic measurement: %w", err)
+	}
+	return fnErr
+}
+
+// writeCommand sends a 16-bit command followed by CRC-protected arguments
+func (s *SCD4x) writeCommand(cmd uint16, args ...uint16) error {
+	data := []byte{byte(cmd >> 8), byte(cmd)}
+	for _, arg := range args {
+		word := []byte{byte(arg >> 8), byte(arg)}
+		data = append(data, word[0], word[1], i2csensor.CRC8(word))
+	}
+	return s.bus.Write(data)
+}
+
+// readWords sends a read command and returns the CRC-checked response words
+func (s *SCD4x) readWords(cmd uint16, count int) ([]uint16, error) {
+	if err := s.writeCommand(cmd); err != nil {
+		return nil, err
+	}
+	s.sleep(time.Millisecond)
+	return s.readResponse(count)
+}
+
+// readResponse reads count words, each followed by a CRC byte
+func (s *SCD4x) readResponse(count int) ([]uint16, error) {
+	data := make([]byte, count*3)
+	if err := s.bus.Read(data); err != nil {
+		return nil, err
+	}
+
+	words := make([]uint16, count)
+	for i := range words {
+		chunk := data[i*3 : i*3+3]
+		if i2csensor.CRC8(chunk[:2]) != chunk[2] {
+			return nil, fmt.Errorf("CRC mismatch in word %d", i)
+		}
+		words[i] = uint16(chunk[0])<<8 | uint16(chunk[1])
+	}
+	return words, nil
+}
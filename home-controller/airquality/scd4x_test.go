@@ -0,0 +1,122 @@
+package airquality
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/mjasion/balena-home/thermostats/i2csensor"
+)
+
+// fakeSCD4xBus records commands and answers reads with CRC-protected words
+type fakeSCD4xBus struct {
+	commands  []uint16
+	responses map[uint16][]uint16
+	last      uint16
+	closed    bool
+}
+
+func (b *fakeSCD4xBus) Write(data []byte) error {
+	b.last = uint16(data[0])<<8 | uint16(data[1])
+	b.commands = append(b.commands, b.last)
+	return nil
+}
+
+func (b *fakeSCD4xBus) Read(data []byte) error {
+	for i, word := range b.responses[b.last] {
+		chunk := []byte{byte(word >> 8), byte(word)}
+		copy(data[i*3:], []byte{chunk[0], chunk[1], i2csensor.CRC8(chunk)})
+	}
+	return nil
+}
+
+func (b *fakeSCD4xBus) Close() error {
+	b.closed = true
+	return nil
+}
+
+func newTestSCD4x(t *testing.T, bus *fakeSCD4xBus) *SCD4x {
+	t.Helper()
+	sensor, err := NewSCD4x(bus)
+	if err != nil {
+		t.Fatalf("NewSCD4x failed: %v", err)
+	}
+	sensor.sleep = func(time.Duration) {}
+	bus.commands = nil
+	return sensor
+}
+
+func TestSCD4x_Read(t *testing.T) {
+	bus := &fakeSCD4xBus{responses: map[uint16][]uint16{
+		scd4xCmdDataReady: {0x8006},
+		// Example from the SCD4x datasheet: 500 ppm, 25°C, 37% RH
+		scd4xCmdReadMeasurement: {0x01F4, 0x6667, 0x5EB9},
+	}}
+	sensor := newTestSCD4x(t, bus)
+
+	measurement, err := sensor.Read()
+	if err != nil {
+		t.Fatalf("Read failed: %v", err)
+	}
+
+	if measurement.CO2PPM != 500 {
+		t.Errorf("Expected 500 ppm, got %f", measurement.CO2PPM)
+	}
+	if math.Abs(measurement.TemperatureCelsius-25.0) > 0.01 {
+		t.Errorf("Expected temperature 25.0°C, got %f", measurement.TemperatureCelsius)
+	}
+	if math.Abs(measurement.HumidityPercent-37.0) > 0.01 {
+		t.Errorf("Expected humidity 37.0%%, got %f", measurement.HumidityPercent)
+	}
+	if !measurement.HasClimate {
+		t.Error("Expected SCD4x measurement with climate data")
+	}
+}
+
+func TestSCD4x_ReadNotReady(t *testing.T) {
+	bus := &fakeSCD4xBus{responses: map[uint16][]uint16{
+		scd4xCmdDataReady: {0x8000},
+	}}
+	sensor := newTestSCD4x(t, bus)
+
+	if _, err := sensor.Read(); err == nil {
+		t.Error("Expected error when no measurement is ready, got nil")
+	}
+}
+
+func TestSCD4x_Calibrate(t *testing.T) {
+	bus := &fakeSCD4xBus{responses: map[uint16][]uint16{
+		scd4xCmdForcedRecalibrate: {0x7FCE},
+	}}
+	sensor := newTestSCD4x(t, bus)
+
+	if err := sensor.Calibrate(400); err != nil {
+		t.Fatalf("Calibrate failed: %v", err)
+	}
+
+	expected := []uint16{scd4xCmdStopPeriodic, scd4xCmdForcedRecalibrate, scd4xCmdStartPeriodic}
+	if len(bus.commands) != len(expected) {
+		t.Fatalf("Expected commands %04x, got %04x", expected, bus.commands)
+	}
+	for i := range expected {
+		if bus.commands[i] != expected[i] {
+			t.Errorf("Command %d: expected 0x%04x, got 0x%04x", i, expected[i], bus.commands[i])
+		}
+	}
+}
+
+func TestSCD4x_CalibrateFailed(t *testing.T) {
+	bus := &fakeSCD4xBus{responses: map[uint16][]uint16{
+		scd4xCmdForcedRecalibrate: {scd4xFRCFailed},
+	}}
+	sensor := newTestSCD4x(t, bus)
+
+	if err := sensor.Calibrate(400); err == nil {
+		t.Error("Expected error for failed recalibration, got nil")
+	}
+
+	// Periodic measurement must be restarted even when calibration fails
+	if last := bus.commands[len(bus.commands)-1]; last != scd4xCmdStartPeriodic {
+		t.Errorf("Expected last command 0x%04x, got 0x%04x", scd4xCmdStartPeriodic, last)
+	}
+}
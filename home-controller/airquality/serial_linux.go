@@ -0,0 +1,34 @@
+//go:build linux
+
+package airquality
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+// openSerial opens a serial device configured for 9600 baud 8N1 with a 1 second read timeout
+func openSerial(path string) (io.ReadWriteCloser, error) {
+	file, err := os.OpenFile(path, os.O_RDWR|syscall.O_NOCTTY, 0)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %w", path, err)
+	}
+
+	termios := syscall.Termios{
+		Cflag:  syscall.B9600 | syscall.CS8 | syscall.CREAD | syscall.CLOCAL,
+		Ispeed: syscall.B9600,
+		Ospeed: syscall.B9600,
+	}
+	termios.Cc[syscall.VMIN] = 0
+	termios.Cc[syscall.VTIME] = 10 // Tenths of a second
+
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, file.Fd(), syscall.TCSETS, uintptr(unsafe.Pointer(&termios))); errno != 0 {
+		file.Close()
+		return nil, fmt.Errorf("failed to configure %s: %w", path, errno)
+	}
+
+	return file, nil
+}
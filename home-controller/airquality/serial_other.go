@@ -0,0 +1,13 @@
+//go:build !linux
+
+package airquality
+
+import (
+	"fmt"
+	"io"
+)
+
+// openSerial is only supported on Linux
+func openSerial(path string) (io.ReadWriteCloser, error) {
+	return nil, fmt.Errorf("serial sensors are only supported on Linux")
+}
@@ -0,0 +1,66 @@
+package airquality
+
+import (
+	"fmt"
+
+	"github.com/mjasion/balena-home/thermostats/i2csensor"
+)
+
+// Supported sensor models
+const (
+	ModelMHZ19 = "mhz19"
+	ModelSCD4x = "scd4x"
+)
+
+// SensorConfig represents configuration for a single CO2 sensor
+type SensorConfig struct {
+	Name    string
+	ID      int
+	Model   string
+	Device  string // Serial device path (mhz19), e.g. /dev/serial0
+	Bus     int    // I2C bus number (scd4x)
+	Address int    // I2C address (scd4x)
+}
+
+// Measurement contains the values of a single sensor read
+type Measurement struct {
+	CO2PPM             float64
+	TemperatureCelsius float64
+	HumidityPercent    float64
+	HasClimate         bool // Temperature and humidity are only reported by SCD4x
+}
+
+// Sensor is a CO2 sensor supporting calibration commands
+type Sensor interface {
+	Read() (*Measurement, error)
+	// Calibrate performs a forced calibration assuming the sensor is exposed to targetPPM
+	Calibrate(targetPPM int) error
+	// SetAutoCalibration enables or disables automatic baseline correction
+	SetAutoCalibration(enabled bool) error
+	Close() error
+}
+
+// Open opens the sensor's transport and initializes the model
+func Open(cfg SensorConfig) (Sensor, error) {
+	switch cfg.Model {
+	case ModelMHZ19:
+		port, err := openSerial(cfg.Device)
+		if err != nil {
+			return nil, err
+		}
+		return NewMHZ19(port), nil
+	case ModelSCD4x:
+		bus, err := i2csensor.OpenBus(cfg.Bus, cfg.Address)
+		if err != nil {
+			return nil, err
+		}
+		sensor, err := NewSCD4x(bus)
+		if err != nil {
+			bus.Close()
+			return nil, err
+		}
+		return sensor, nil
+	default:
+		return nil, fmt.Errorf("unsupported sensor model: %s", cfg.Model)
+	}
+}
@@ -0,0 +1,80 @@
+package atc
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"go.uber.org/zap"
+	"tinygo.org/x/bluetooth"
+)
+
+// The ATC_MiThermometer firmware exposes its settings as a single writable characteristic
+var (
+	settingsServiceUUID        = bluetooth.New16BitUUID(0x1F10)
+	settingsCharacteristicUUID = bluetooth.New16BitUUID(0x1F1F)
+)
+
+// Configure finds the sensor with the given MAC address, connects to it over GATT
+// and writes each command to its settings characteristic.
+// The sensor must be advertising; ctx bounds the whole operation.
+func Configure(ctx context.Context, mac string, commands [][]byte, logger *zap.Logger) error {
+	adapter := bluetooth.DefaultAdapter
+	if err := adapter.Enable(); err != nil {
+		return fmt.Errorf("failed to enable BLE adapter: %w", err)
+	}
+
+	address, err := find(ctx, adapter, mac)
+	if err != nil {
+		return err
+	}
+	logger.Info("connecting to sensor", zap.String("mac", mac))
+
+	device, err := adapter.Connect(address, bluetooth.ConnectionParams{})
+	if err != nil {
+		return fmt.Errorf("failed to connect to %s: %w", mac, err)
+	}
+	defer device.Disconnect()
+
+	services, err := device.DiscoverServices([]bluetooth.UUID{settingsServiceUUID})
+	if err != nil || len(services) == 0 {
+		return fmt.Errorf("sensor %s has no ATC settings service, is it running the ATC firmware: %v", mac, err)
+	}
+	characteristics, err := services[0].DiscoverCharacteristics([]bluetooth.UUID{settingsCharacteristicUUID})
+	if err != nil || len(characteristics) == 0 {
+		return fmt.Errorf("sensor %s has no ATC settings characteristic: %v", mac, err)
+	}
+
+	for _, command := range commands {
+		if _, err := characteristics[0].WriteWithoutResponse(command); err != nil {
+			return fmt.Errorf("failed to write command %x to %s: %w", command, mac, err)
+		}
+		logger.Info("wrote sensor setting", zap.String("mac", mac), zap.String("command", fmt.Sprintf("%x", command)))
+	}
+	return nil
+}
+
+// find scans until the sensor advertises, so the address is known to the adapter before connecting
+func find(ctx context.Context, adapter *bluetooth.Adapter, mac string) (bluetooth.Address, error) {
+	var (
+		address bluetooth.Address
+		found   bool
+	)
+	stop := context.AfterFunc(ctx, func() { adapter.StopScan() })
+	defer stop()
+
+	err := adapter.Scan(func(adapter *bluetooth.Adapter, result bluetooth.ScanResult) {
+		if strings.EqualFold(result.Address.String(), mac) {
+			address = result.Address
+			found = true
+			adapter.StopScan()
+		}
+	})
+	if err != nil {
+		return address, fmt.Errorf("failed to scan for %s: %w", mac, err)
+	}
+	if !found {
+		return address, fmt.Errorf("sensor %s not found: %w", mac, ctx.Err())
+	}
+	return address, nil
+}
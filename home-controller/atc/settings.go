@@ -0,0 +1,104 @@
+package atc
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// setting is a writable ATC_MiThermometer setting; the firmware takes an opcode followed by one value byte
+type setting struct {
+	opcode byte
+	help   string
+	parse  func(value string) (byte, error)
+}
+
+// settings are the commands understood by the ATC_MiThermometer settings characteristic
+var settings = map[string]setting{
+	"interval": {0xFE, "advertising interval in seconds, 10-2550 in steps of 10", parseInterval},
+	"tempOffset": {0xFD, "temperature offset in °C, -12.5 to 12.5", func(value string) (byte, error) {
+		return parseOffset(value, 12.5, 10)
+	}},
+	"humidityOffset": {0xFC, "humidity offset in %, -50 to 50", func(value string) (byte, error) {
+		return parseOffset(value, 50, 1)
+	}},
+	"battery": {0xFB, "show the battery level on the display: on or off", choice(map[string]byte{"off": 0, "on": 1})},
+	"unit":    {0xFA, "display unit: c or f", choice(map[string]byte{"c": 0, "f": 1})},
+	"format":  {0xF9, "advertising format: atc or mi (only atc is decoded by the scanner)", choice(map[string]byte{"atc": 0, "mi": 1})},
+	"smiley":  {0xF8, "smiley on the display: off, happy or sad", choice(map[string]byte{"off": 0, "happy": 1, "sad": 2})},
+	"comfort": {0xF7, "comfort smiley, following the temperature and humidity: on or off", choice(map[string]byte{"off": 0, "on": 1})},
+}
+
+// ParseCommand converts a name=value argument to the bytes written to the settings characteristic
+func ParseCommand(arg string) ([]byte, error) {
+	name, value, ok := strings.Cut(arg, "=")
+	if !ok {
+		return nil, fmt.Errorf("setting %q must be name=value", arg)
+	}
+	s, ok := settings[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown setting %q", name)
+	}
+	b, err := s.parse(strings.TrimSpace(value))
+	if err != nil {
+		return nil, fmt.Errorf("setting %s: %w", name, err)
+	}
+	return []byte{s.opcode, b}, nil
+}
+
+// Usage lists the available settings, one per line
+func Usage() string {
+	names := make([]string, 0, len(settings))
+	for name := range settings {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var b strings.Builder
+	for _, name := range names {
+		fmt.Fprintf(&b, "  %-15s %s\n", name, settings[name].help)
+	}
+	return b.String()
+}
+
+// parseInterval converts seconds to the firmware's 10 second units
+func parseInterval(value string) (byte, error) {
+	seconds, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid interval %q", value)
+	}
+	if seconds < 10 || seconds > 2550 || seconds%10 != 0 {
+		return 0, fmt.Errorf("interval must be 10-2550 seconds in steps of 10, got %d", seconds)
+	}
+	return byte(seconds / 10), nil
+}
+
+// parseOffset converts a signed offset to a two's complement byte in 1/scale units
+func parseOffset(value string, limit, scale float64) (byte, error) {
+	offset, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid offset %q", value)
+	}
+	if math.Abs(offset) > limit {
+		return 0, fmt.Errorf("offset must be between -%g and %g, got %g", limit, limit, offset)
+	}
+	return byte(int8(math.Round(offset * scale))), nil
+}
+
+// choice returns a parser accepting one of the given values
+func choice(values map[string]byte) func(string) (byte, error) {
+	return func(value string) (byte, error) {
+		b, ok := values[strings.ToLower(value)]
+		if !ok {
+			names := make([]string, 0, len(values))
+			for name := range values {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			return 0, fmt.Errorf("invalid value %q, expected one of %s", value, strings.Join(names, ", "))
+		}
+		return b, nil
+	}
+}
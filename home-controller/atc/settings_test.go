@@ -0,0 +1,74 @@
+package atc
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestParseCommand(t *testing.T) {
+	tests := []struct {
+		arg      string
+		expected []byte
+	}{
+		{"interval=60", []byte{0xFE, 6}},
+		{"interval=2550", []byte{0xFE, 255}},
+		{"tempOffset=-0.5", []byte{0xFD, 0xFB}},
+		{"tempOffset=12.5", []byte{0xFD, 125}},
+		{"humidityOffset=-3", []byte{0xFC, 0xFD}},
+		{"battery=on", []byte{0xFB, 1}},
+		{"unit=F", []byte{0xFA, 1}},
+		{"format=atc", []byte{0xF9, 0}},
+		{"smiley=sad", []byte{0xF8, 2}},
+		{"comfort=off", []byte{0xF7, 0}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.arg, func(t *testing.T) {
+			command, err := ParseCommand(tt.arg)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if !bytes.Equal(command, tt.expected) {
+				t.Errorf("Expected %x, got %x", tt.expected, command)
+			}
+		})
+	}
+}
+
+func TestParseCommand_Errors(t *testing.T) {
+	tests := []struct {
+		arg    string
+		errMsg string
+	}{
+		{"interval", "must be name=value"},
+		{"brightness=5", "unknown setting"},
+		{"interval=5", "10-2550 seconds"},
+		{"interval=65", "steps of 10"},
+		{"interval=soon", "invalid interval"},
+		{"tempOffset=13", "between -12.5 and 12.5"},
+		{"humidityOffset=-51", "between -50 and 50"},
+		{"smiley=wink", "expected one of happy, off, sad"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.arg, func(t *testing.T) {
+			_, err := ParseCommand(tt.arg)
+			if err == nil {
+				t.Fatal("Expected error, got nil")
+			}
+			if !strings.Contains(err.Error(), tt.errMsg) {
+				t.Errorf("Expected error containing %q, got %v", tt.errMsg, err)
+			}
+		})
+	}
+}
+
+func TestUsage(t *testing.T) {
+	usage := Usage()
+	for name := range settings {
+		if !strings.Contains(usage, name) {
+			t.Errorf("Expected usage to list %s, got:\n%s", name, usage)
+		}
+	}
+}
@@ -0,0 +1,298 @@
+package automation
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/mjasion/balena-home/thermostats/buffer"
+	"github.com/mjasion/balena-home/thermostats/events"
+	"github.com/mjasion/balena-home/thermostats/signal"
+	"go.uber.org/zap"
+)
+
+// ExpressionRule evaluates an expression whenever one of its variables receives a new sample
+// A rule either derives a metric from a numeric expression or calls a webhook when a
+// boolean expression becomes true
+type ExpressionRule struct {
+	Name     string
+	Vars     map[string]Selector // Variable name to the samples it takes its value from
+	Expr     string
+	Metric   string   // Derived metric name
+	Webhook  *Webhook // Called on each false to true transition
+	Critical bool     // Webhook is called even while away or in vacation or maintenance mode
+
+	// Filters smooth variables before evaluation, e.g. a moving average of 2-second power readings
+	Filters map[string]signal.Spec
+	// Output filters the result, 1 or 0 for a webhook rule, e.g. a debounce so the webhook doesn't flap
+	Output *signal.Spec
+}
+
+// compiledRule is an expression rule with its evaluation state
+type compiledRule struct {
+	ExpressionRule
+	expr    *Expression
+	values  map[string]float64       // Latest value of each variable
+	filters map[string]signal.Filter // By variable
+	output  signal.Filter            // Nil without an output filter
+	active  bool                     // Last result of a webhook rule
+}
+
+// Occupancy reports whether someone is home; occupancy.Tracker implements it
+type Occupancy interface {
+	Home() bool
+}
+
+// Mode reports whether non-critical alerts are suppressed; mode.Switch implements it
+type Mode interface {
+	SuppressAlerts() bool
+}
+
+// queuedWebhook is a webhook call waiting for execution
+type queuedWebhook struct {
+	rule    string
+	webhook Webhook
+}
+
+// Engine evaluates expression rules against the live reading stream
+type Engine struct {
+	caller    *WebhookCaller
+	buffer    *buffer.RingBuffer
+	eventLog  *events.Log
+	occupancy Occupancy // Nil never mutes webhooks
+	mode      Mode      // Nil never mutes webhooks
+	logger    *zap.Logger
+
+	mu       sync.Mutex
+	rules    []*compiledRule
+	webhooks chan queuedWebhook
+}
+
+// NewEngine compiles the rules and creates an engine
+func NewEngine(rules []ExpressionRule, caller *WebhookCaller, buf *buffer.RingBuffer, logger *zap.Logger) (*Engine, error) {
+	compiled := make([]*compiledRule, len(rules))
+	for i, rule := range rules {
+		vars := make([]string, 0, len(rule.Vars))
+		for name := range rule.Vars {
+			vars = append(vars, name)
+		}
+		expr, err := Compile(rule.Expr, vars)
+		if err != nil {
+			return nil, fmt.Errorf("rule %s: %w", rule.Name, err)
+		}
+		compiled[i] = &compiledRule{
+			ExpressionRule: rule,
+			expr:           expr,
+			values:         make(map[string]float64, len(rule.Vars)),
+			filters:        make(map[string]signal.Filter, len(rule.Filters)),
+		}
+		for name, spec := range rule.Filters {
+			if _, ok := rule.Vars[name]; !ok {
+				return nil, fmt.Errorf("rule %s: filter for unknown variable %s", rule.Name, name)
+			}
+			if err := spec.Validate(); err != nil {
+				return nil, fmt.Errorf("rule %s: variable %s: %w", rule.Name, name, err)
+			}
+			compiled[i].filters[name] = spec.New()
+		}
+		if rule.Output != nil {
+			if err := rule.Output.Validate(); err != nil {
+				return nil, fmt.Errorf("rule %s: output: %w", rule.Name, err)
+			}
+			compiled[i].output = rule.Output.New()
+		}
+	}
+
+	return &Engine{
+		caller:   caller,
+		buffer:   buf,
+		logger:   logger,
+		rules:    compiled,
+		webhooks: make(chan queuedWebhook, len(rules)*2+1),
+	}, nil
+}
+
+// SetEventLog sets the event log used to record triggered rules
+func (e *Engine) SetEventLog(eventLog *events.Log) {
+	e.eventLog = eventLog
+}
+
+// SetOccupancy mutes webhooks of expression rules while nobody is home; rule states are still pushed
+func (e *Engine) SetOccupancy(occupancy Occupancy) {
+	e.occupancy = occupancy
+}
+
+// SetMode mutes webhooks of non-critical expression rules outside normal mode, e.g. on vacation
+func (e *Engine) SetMode(mode Mode) {
+	e.mode = mode
+}
+
+// Observe updates rule variables from the reading and evaluates affected rules
+// Derived readings are not observed, so rules cannot feed back into themselves
+func (e *Engine) Observe(reading *buffer.Reading) {
+	samples := Samples(reading)
+	if len(samples) == 0 {
+		return
+	}
+
+	timestamp := TimestampOf(reading)
+	if timestamp.IsZero() {
+		timestamp = time.Now()
+	}
+
+	var derived []*buffer.Reading
+	e.mu.Lock()
+	for _, rule := range e.rules {
+		if !rule.update(samples, timestamp) || len(rule.values) < len(rule.Vars) {
+			continue
+		}
+		if rule.Webhook != nil {
+			if r := e.evaluateCondition(rule, timestamp); r != nil {
+				derived = append(derived, r)
+			}
+		} else if r := e.evaluateMetric(rule, timestamp); r != nil {
+			derived = append(derived, r)
+		}
+	}
+	e.mu.Unlock()
+
+	// Derived readings are passed to the other listeners inside Add, which shouldn't run under e.mu
+	for _, r := range derived {
+		e.buffer.Add(r)
+	}
+}
+
+// update stores matching sample values, filtered when the variable has a filter, and reports
+// whether any variable changed
+func (r *compiledRule) update(samples []Sample, timestamp time.Time) bool {
+	updated := false
+	for name, selector := range r.Vars {
+		for _, sample := range samples {
+			if !selector.Matches(sample) {
+				continue
+			}
+			value := sample.Value
+			if filter, ok := r.filters[name]; ok {
+				value = filter.Update(value, timestamp)
+			}
+			r.values[name] = value
+			updated = true
+		}
+	}
+	return updated
+}
+
+// evaluateMetric computes a derived metric reading
+func (e *Engine) evaluateMetric(rule *compiledRule, timestamp time.Time) *buffer.Reading {
+	value, err := rule.expr.EvalNumber(rule.values)
+	if err != nil {
+		e.logger.Debug("failed to evaluate expression rule",
+			zap.String("rule", rule.Name),
+			zap.Error(err),
+		)
+		return nil
+	}
+	if rule.output != nil {
+		value = rule.output.Update(value, timestamp)
+	}
+	return &buffer.Reading{
+		Type: buffer.ReadingTypeDerived,
+		Derived: &buffer.DerivedReading{
+			Timestamp: timestamp,
+			Name:      rule.Metric,
+			Rule:      rule.Name,
+			Value:     value,
+		},
+	}
+}
+
+// evaluateCondition queues the webhook on a false to true transition and returns a state reading on changes
+func (e *Engine) evaluateCondition(rule *compiledRule, timestamp time.Time) *buffer.Reading {
+	active, err := rule.expr.EvalBool(rule.values)
+	if err != nil {
+		e.logger.Debug("failed to evaluate expression rule",
+			zap.String("rule", rule.Name),
+			zap.Error(err),
+		)
+		return nil
+	}
+	if rule.output != nil {
+		result := 0.0
+		if active {
+			result = 1
+		}
+		active = rule.output.Update(result, timestamp) != 0
+	}
+	if active == rule.active {
+		return nil
+	}
+	rule.active = active
+
+	if reason := e.muted(rule); active && reason != "" {
+		e.logger.Info("muting automation webhook", zap.String("rule", rule.Name), zap.String("reason", reason))
+	} else if active {
+		select {
+		case e.webhooks <- queuedWebhook{rule: rule.Name, webhook: *rule.Webhook}:
+		default:
+			e.logger.Warn("automation webhook queue full, dropping webhook", zap.String("rule", rule.Name))
+		}
+	}
+
+	return &buffer.Reading{
+		Type: buffer.ReadingTypeAutomation,
+		Automation: &buffer.AutomationReading{
+			Timestamp: timestamp,
+			Rule:      rule.Name,
+			Active:    active,
+		},
+	}
+}
+
+// muted returns why the webhook of a rule is muted, empty when it is called
+func (e *Engine) muted(rule *compiledRule) string {
+	switch {
+	case rule.Critical:
+		return ""
+	case e.mode != nil && e.mode.SuppressAlerts():
+		return "vacation or maintenance mode"
+	case e.occupancy != nil && !e.occupancy.Home():
+		return "nobody home"
+	}
+	return ""
+}
+
+// Start executes queued webhooks until the context is cancelled
+func (e *Engine) Start(ctx context.Context) {
+	e.logger.Info("starting automation engine", zap.Int("rule_count", len(e.rules)))
+
+	for {
+		select {
+		case <-ctx.Done():
+			e.logger.Info("stopping automation engine")
+			return
+		case queued := <-e.webhooks:
+			e.execute(ctx, queued)
+		}
+	}
+}
+
+// execute calls a webhook and records the outcome
+func (e *Engine) execute(ctx context.Context, queued queuedWebhook) {
+	fields := map[string]string{"rule": queued.rule}
+
+	if err := e.caller.Call(ctx, queued.webhook); err != nil {
+		e.logger.Error("automation webhook failed",
+			zap.String("rule", queued.rule),
+			zap.Error(err),
+		)
+		fields["error"] = err.Error()
+		e.eventLog.Record(events.TypeAutomationFailed, "automation",
+			fmt.Sprintf("webhook for rule %s failed", queued.rule), fields)
+		return
+	}
+
+	e.logger.Info("automation webhook executed", zap.String("rule", queued.rule))
+	e.eventLog.Record(events.TypeAutomationTriggered, "automation",
+		fmt.Sprintf("rule %s triggered", queued.rule), fields)
+}
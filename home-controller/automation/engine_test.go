@@ -0,0 +1,218 @@
+package automation
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/mjasion/balena-home/thermostats/buffer"
+	"github.com/mjasion/balena-home/thermostats/events"
+	"github.com/mjasion/balena-home/thermostats/signal"
+	"go.uber.org/zap"
+)
+
+func bleReading(sensorName string, temperature float64, humidity int) *buffer.Reading {
+	return &buffer.Reading{
+		Type: buffer.ReadingTypeBLE,
+		BLE: &buffer.SensorReading{
+			Timestamp:          time.Now(),
+			SensorName:         sensorName,
+			TemperatureCelsius: temperature,
+			HumidityPercent:    humidity,
+		},
+	}
+}
+
+func TestEngine_DerivedMetric(t *testing.T) {
+	logger := zap.NewNop()
+	buf := buffer.New(100, logger)
+	engine, err := NewEngine([]ExpressionRule{{
+		Name: "temperature-delta",
+		Vars: map[string]Selector{
+			"inside":  {Metric: "ble_temperature_celsius", Labels: map[string]string{"sensor_name": "living"}},
+			"outside": {Metric: "ble_temperature_celsius", Labels: map[string]string{"sensor_name": "garden"}},
+		},
+		Expr:   "inside - outside",
+		Metric: "temperature_delta_celsius",
+	}}, NewWebhookCaller(time.Second), buf, logger)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	buf.AddListener(engine.Observe)
+
+	buf.Add(bleReading("living", 21.5, 40))
+	if derived := derivedReadings(buf); len(derived) != 0 {
+		t.Fatalf("Expected no derived readings before all variables are set, got %d", len(derived))
+	}
+
+	buf.Add(bleReading("garden", 5, 80))
+	buf.Add(bleReading("kitchen", 30, 50)) // Not referenced by the rule
+
+	derived := derivedReadings(buf)
+	if len(derived) != 1 {
+		t.Fatalf("Expected 1 derived reading, got %d", len(derived))
+	}
+	if derived[0].Name != "temperature_delta_celsius" || derived[0].Rule != "temperature-delta" || derived[0].Value != 16.5 {
+		t.Errorf("Unexpected derived reading: %+v", derived[0])
+	}
+}
+
+func TestEngine_WebhookOnTransition(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	logger := zap.NewNop()
+	buf := buffer.New(100, logger)
+	eventLog := events.NewLog(10, logger)
+	engine, err := NewEngine([]ExpressionRule{{
+		Name:    "humid",
+		Vars:    map[string]Selector{"h": {Metric: "ble_humidity_percent"}},
+		Expr:    "h > 70",
+		Webhook: &Webhook{Method: http.MethodPost, URL: server.URL},
+	}}, NewWebhookCaller(time.Second), buf, logger)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	engine.SetEventLog(eventLog)
+
+	for _, humidity := range []int{60, 75, 80, 65, 72} {
+		engine.Observe(bleReading("bathroom", 22, humidity))
+	}
+
+	// Two false to true transitions queue two webhooks
+	for len(engine.webhooks) > 0 {
+		engine.execute(context.Background(), <-engine.webhooks)
+	}
+	if calls.Load() != 2 {
+		t.Errorf("Expected 2 webhook calls, got %d", calls.Load())
+	}
+
+	recorded := eventLog.List(events.Filter{Type: events.TypeAutomationTriggered})
+	if len(recorded) != 2 || recorded[0].Fields["rule"] != "humid" {
+		t.Errorf("Expected 2 automation_triggered events for humid, got %v", recorded)
+	}
+
+	// State changes: true, false, true
+	var states []bool
+	for _, reading := range buf.GetAll() {
+		if reading.Type == buffer.ReadingTypeAutomation {
+			states = append(states, reading.Automation.Active)
+		}
+	}
+	if len(states) != 3 || !states[0] || states[1] || !states[2] {
+		t.Errorf("Expected states [true false true], got %v", states)
+	}
+}
+
+type suppressed bool
+
+func (s suppressed) SuppressAlerts() bool {
+	return bool(s)
+}
+
+func TestEngine_SuppressedModeMutesNonCriticalWebhooks(t *testing.T) {
+	logger := zap.NewNop()
+	buf := buffer.New(100, logger)
+	webhook := &Webhook{Method: http.MethodPost, URL: "http://127.0.0.1:1"}
+	engine, err := NewEngine([]ExpressionRule{
+		{Name: "humid", Vars: map[string]Selector{"h": {Metric: "ble_humidity_percent"}}, Expr: "h > 70", Webhook: webhook},
+		{Name: "flooded", Vars: map[string]Selector{"h": {Metric: "ble_humidity_percent"}}, Expr: "h > 90", Webhook: webhook, Critical: true},
+	}, NewWebhookCaller(time.Second), buf, logger)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	engine.SetMode(suppressed(true))
+
+	engine.Observe(bleReading("bathroom", 22, 95))
+
+	// Only the critical rule's webhook is queued; both rule states are still recorded
+	if len(engine.webhooks) != 1 || (<-engine.webhooks).rule != "flooded" {
+		t.Errorf("Expected only the critical webhook to be queued")
+	}
+	states := 0
+	for _, reading := range buf.GetAll() {
+		if reading.Type == buffer.ReadingTypeAutomation && reading.Automation.Active {
+			states++
+		}
+	}
+	if states != 2 {
+		t.Errorf("Expected 2 active rule states, got %d", states)
+	}
+}
+
+func TestEngine_Filters(t *testing.T) {
+	logger := zap.NewNop()
+	buf := buffer.New(100, logger)
+	webhook := &Webhook{Method: http.MethodPost, URL: "http://127.0.0.1:1"}
+	engine, err := NewEngine([]ExpressionRule{
+		{
+			Name:    "humid-hysteresis",
+			Vars:    map[string]Selector{"h": {Metric: "ble_humidity_percent"}},
+			Expr:    "h == 1.0",
+			Webhook: webhook,
+			Filters: map[string]signal.Spec{"h": {Type: signal.TypeHysteresis, Low: 60, High: 70}},
+		},
+		{
+			Name:    "humid-debounced",
+			Vars:    map[string]Selector{"h": {Metric: "ble_humidity_percent"}},
+			Expr:    "h > 70",
+			Webhook: webhook,
+			Output:  &signal.Spec{Type: signal.TypeDebounce, Hold: 4 * time.Second},
+		},
+	}, NewWebhookCaller(time.Second), buf, logger)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	// Readings two seconds apart flapping around 70% before settling
+	start := time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC)
+	for i, humidity := range []int{65, 71, 69, 71, 69, 71, 71, 71, 59} {
+		reading := bleReading("bathroom", 22, humidity)
+		reading.BLE.Timestamp = start.Add(time.Duration(i) * 2 * time.Second)
+		engine.Observe(reading)
+	}
+
+	states := make(map[string][]bool)
+	for _, reading := range buf.GetAll() {
+		if reading.Type == buffer.ReadingTypeAutomation {
+			states[reading.Automation.Rule] = append(states[reading.Automation.Rule], reading.Automation.Active)
+		}
+	}
+	// Hysteresis turns on at 71 and off only at 59
+	if got := states["humid-hysteresis"]; len(got) != 2 || !got[0] || got[1] {
+		t.Errorf("Expected hysteresis states [true false], got %v", got)
+	}
+	// The debounced rule turns on once 71 held for 4s, and 59 hasn't held long enough to turn it off
+	if got := states["humid-debounced"]; len(got) != 1 || !got[0] {
+		t.Errorf("Expected debounced states [true], got %v", got)
+	}
+}
+
+func TestNewEngine_InvalidExpression(t *testing.T) {
+	_, err := NewEngine([]ExpressionRule{{
+		Name:   "broken",
+		Vars:   map[string]Selector{"x": {Metric: "ble_temperature_celsius"}},
+		Expr:   "x + y",
+		Metric: "broken_value",
+	}}, NewWebhookCaller(time.Second), buffer.New(10, zap.NewNop()), zap.NewNop())
+	if err == nil {
+		t.Error("Expected error for unknown variable")
+	}
+}
+
+func derivedReadings(buf *buffer.RingBuffer) []*buffer.DerivedReading {
+	var derived []*buffer.DerivedReading
+	for _, reading := range buf.GetAll() {
+		if reading.Type == buffer.ReadingTypeDerived {
+			derived = append(derived, reading.Derived)
+		}
+	}
+	return derived
+}
@@ -0,0 +1,121 @@
+package automation
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/google/cel-go/cel"
+	"github.com/google/cel-go/common/types"
+	"github.com/google/cel-go/common/types/ref"
+	"github.com/google/cel-go/ext"
+)
+
+// Expression is a compiled CEL expression over numeric variables
+//
+// Variables are doubles. Ordering comparisons mix ints and doubles, but equality and arithmetic
+// don't, as in CEL, so literals combined with variables are written as doubles: t - 20.0.
+// Besides the standard CEL operators and macros, the math extension (math.least, math.greatest,
+// math.abs, math.round, math.sqrt, ...) is available, with math.pow, math.exp, math.log and
+// math.log10 added.
+type Expression struct {
+	source  string
+	program cel.Program
+}
+
+// mathFunctions declares the math functions the CEL math extension lacks
+var mathFunctions = []cel.EnvOption{
+	cel.Function("math.pow",
+		cel.Overload("math_pow_double_double", []*cel.Type{cel.DoubleType, cel.DoubleType}, cel.DoubleType,
+			cel.BinaryBinding(func(x, y ref.Val) ref.Val {
+				return types.Double(math.Pow(float64(x.(types.Double)), float64(y.(types.Double))))
+			}))),
+	unaryMathFunction("math.exp", math.Exp),
+	unaryMathFunction("math.log", math.Log),
+	unaryMathFunction("math.log10", math.Log10),
+}
+
+// unaryMathFunction declares a math function of a double
+func unaryMathFunction(name string, fn func(float64) float64) cel.EnvOption {
+	return cel.Function(name,
+		cel.Overload(name+"_double", []*cel.Type{cel.DoubleType}, cel.DoubleType,
+			cel.UnaryBinding(func(x ref.Val) ref.Val {
+				return types.Double(fn(float64(x.(types.Double))))
+			})))
+}
+
+// Compile parses and type-checks an expression, checking that it only references the given variables
+func Compile(source string, vars []string) (*Expression, error) {
+	options := []cel.EnvOption{cel.CrossTypeNumericComparisons(true), ext.Math()}
+	options = append(options, mathFunctions...)
+	for _, name := range vars {
+		options = append(options, cel.Variable(name, cel.DoubleType))
+	}
+	env, err := cel.NewEnv(options...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create expression environment: %w", err)
+	}
+
+	ast, issues := env.Compile(source)
+	if issues.Err() != nil {
+		return nil, issues.Err()
+	}
+	program, err := env.Program(ast)
+	if err != nil {
+		return nil, err
+	}
+	return &Expression{source: source, program: program}, nil
+}
+
+// String returns the expression source
+func (e *Expression) String() string {
+	return e.source
+}
+
+// eval evaluates the expression with the variables
+func (e *Expression) eval(vars map[string]float64) (interface{}, error) {
+	activation := make(map[string]any, len(vars))
+	for name, value := range vars {
+		activation[name] = value
+	}
+	result, _, err := e.program.Eval(activation)
+	if err != nil {
+		return nil, err
+	}
+	return result.Value(), nil
+}
+
+// EvalNumber evaluates an expression expected to return a number
+func (e *Expression) EvalNumber(vars map[string]float64) (float64, error) {
+	result, err := e.eval(vars)
+	if err != nil {
+		return 0, err
+	}
+	var number float64
+	switch value := result.(type) {
+	case float64:
+		number = value
+	case int64:
+		number = float64(value)
+	case uint64:
+		number = float64(value)
+	default:
+		return 0, fmt.Errorf("expression returned %T, expected a number", result)
+	}
+	if math.IsNaN(number) || math.IsInf(number, 0) {
+		return 0, fmt.Errorf("expression returned %v", number)
+	}
+	return number, nil
+}
+
+// EvalBool evaluates an expression expected to return a boolean
+func (e *Expression) EvalBool(vars map[string]float64) (bool, error) {
+	result, err := e.eval(vars)
+	if err != nil {
+		return false, err
+	}
+	b, ok := result.(bool)
+	if !ok {
+		return false, fmt.Errorf("expression returned %T, expected a boolean", result)
+	}
+	return b, nil
+}
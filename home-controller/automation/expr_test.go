@@ -0,0 +1,135 @@
+package automation
+
+import (
+	"math"
+	"testing"
+)
+
+func TestExpression_EvalNumber(t *testing.T) {
+	vars := map[string]float64{"t": 21.5, "h": 40, "w": 1200}
+
+	tests := []struct {
+		expr     string
+		expected float64
+	}{
+		{"1 + 2 * 3", 7},
+		{"(1 + 2) * 3", 9},
+		{"10 % 4", 2},
+		{"-t + 1.5", -20},
+		{"w / 1000.0", 1.2},
+		{"1e3 + 1.0", 1001},
+		{"double(w) / double(1000)", 1.2},
+		{"math.greatest(t, h, 30)", 40},
+		{"math.least(t, h)", 21.5},
+		{"math.round(t)", 22},
+		{"math.pow(2.0, 10.0)", 1024},
+		{"math.abs(t - h)", 18.5},
+		{"math.log10(w / 12.0)", 2},
+		{"w > 1000 ? w : 0.0", 1200},
+		{"t > 25 ? 1 : t > 20 ? 2 : 3", 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			expr, err := Compile(tt.expr, []string{"t", "h", "w"})
+			if err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+			value, err := expr.EvalNumber(vars)
+			if err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+			if math.Abs(value-tt.expected) > 1e-9 {
+				t.Errorf("Expected %v, got %v", tt.expected, value)
+			}
+		})
+	}
+}
+
+func TestExpression_EvalBool(t *testing.T) {
+	vars := map[string]float64{"t": 21.5, "h": 40}
+
+	tests := []struct {
+		expr     string
+		expected bool
+	}{
+		{"t > 20", true},
+		{"t > 20 && h > 50", false},
+		{"t > 20 || h > 50", true},
+		{"!(h >= 40)", false},
+		{"t != h", true},
+		{"(t > 20) == true", true},
+		{"h == 40.0", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			expr, err := Compile(tt.expr, []string{"t", "h"})
+			if err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+			value, err := expr.EvalBool(vars)
+			if err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+			if value != tt.expected {
+				t.Errorf("Expected %v, got %v", tt.expected, value)
+			}
+		})
+	}
+}
+
+func TestCompile_Errors(t *testing.T) {
+	tests := []string{
+		"",
+		"1 +",
+		"(1 + 2",
+		"1 2",
+		"x + 1",
+		"unknown(1)",
+		"math.pow(1.0)",
+		"1 $ 2",
+		"t - 20",
+		"t && true",
+	}
+
+	for _, source := range tests {
+		t.Run(source, func(t *testing.T) {
+			if _, err := Compile(source, []string{"t"}); err == nil {
+				t.Errorf("Expected error for %q", source)
+			}
+		})
+	}
+}
+
+func TestExpression_EvalErrors(t *testing.T) {
+	vars := map[string]float64{"t": 0}
+
+	tests := []struct {
+		expr string
+		bool bool
+	}{
+		{"1.0 / t", false},
+		{"math.log(t)", false},
+		{"1 / int(t)", false},
+		{"t > 1", false},
+		{"t + 1.0", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			expr, err := Compile(tt.expr, []string{"t"})
+			if err != nil {
+				t.Fatalf("Expected no compile error, got %v", err)
+			}
+			if tt.bool {
+				_, err = expr.EvalBool(vars)
+			} else {
+				_, err = expr.EvalNumber(vars)
+			}
+			if err == nil {
+				t.Errorf("Expected evaluation error")
+			}
+		})
+	}
+}
@@ -0,0 +1,217 @@
+package automation
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/mjasion/balena-home/thermostats/buffer"
+	"github.com/mjasion/balena-home/thermostats/events"
+	"github.com/mjasion/balena-home/thermostats/power"
+	"go.uber.org/zap"
+)
+
+// Load shedding actions
+const (
+	ActionShed    = "shed"
+	ActionRestore = "restore"
+)
+
+// LoadShedRule turns off a load when active power stays above a threshold
+type LoadShedRule struct {
+	Name              string
+	SensorID          int
+	ThresholdWatts    float64
+	For               time.Duration // How long power must stay above the threshold
+	Shed              Webhook
+	RestoreBelowWatts float64       // 0 disables restoring
+	RestoreFor        time.Duration // How long power must stay below RestoreBelowWatts
+	Restore           Webhook
+}
+
+// ruleState tracks the evaluation state of a rule
+type ruleState struct {
+	shed       bool
+	crossingAt time.Time // When power first crossed the threshold relevant to the current state
+}
+
+// action is a webhook call queued for execution
+type action struct {
+	index   int // Rule index, used to revert the state when the webhook fails
+	rule    LoadShedRule
+	name    string // ActionShed or ActionRestore
+	webhook Webhook
+	watts   float64
+}
+
+// LoadShedder evaluates power readings against load shedding rules
+type LoadShedder struct {
+	rules    []LoadShedRule
+	caller   *WebhookCaller
+	buffer   *buffer.RingBuffer
+	eventLog *events.Log
+	logger   *zap.Logger
+
+	mu      sync.Mutex
+	states  []ruleState
+	actions chan action
+}
+
+// NewLoadShedder creates a load shedder for the given rules
+func NewLoadShedder(rules []LoadShedRule, caller *WebhookCaller, buf *buffer.RingBuffer, logger *zap.Logger) *LoadShedder {
+	return &LoadShedder{
+		rules:   rules,
+		caller:  caller,
+		buffer:  buf,
+		logger:  logger,
+		states:  make([]ruleState, len(rules)),
+		actions: make(chan action, len(rules)*2),
+	}
+}
+
+// SetEventLog sets the event log used to record shed and restore actions
+func (s *LoadShedder) SetEventLog(eventLog *events.Log) {
+	s.eventLog = eventLog
+}
+
+// ObservePower evaluates the rules for the reading's sensor
+// Actions are queued and executed by Start, so the caller is never blocked by webhooks
+func (s *LoadShedder) ObservePower(reading power.ActivePowerReading) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i, rule := range s.rules {
+		if rule.SensorID != reading.SensorID {
+			continue
+		}
+		if name, webhook, ok := s.evaluate(i, reading.Value, reading.Timestamp); ok {
+			s.enqueue(action{index: i, rule: rule, name: name, webhook: webhook, watts: reading.Value})
+		}
+	}
+}
+
+// evaluate advances the state of rule i and returns the action to run, if any; caller holds the lock
+func (s *LoadShedder) evaluate(i int, watts float64, now time.Time) (string, Webhook, bool) {
+	rule := s.rules[i]
+	state := &s.states[i]
+
+	if !state.shed {
+		if watts <= rule.ThresholdWatts {
+			state.crossingAt = time.Time{}
+			return "", Webhook{}, false
+		}
+		if state.crossingAt.IsZero() {
+			state.crossingAt = now
+		}
+		if now.Sub(state.crossingAt) < rule.For {
+			return "", Webhook{}, false
+		}
+		state.shed = true
+		state.crossingAt = time.Time{}
+		return ActionShed, rule.Shed, true
+	}
+
+	// Without a restore webhook the rule re-arms once power drops below the threshold
+	if rule.RestoreBelowWatts <= 0 {
+		if watts <= rule.ThresholdWatts {
+			state.shed = false
+		}
+		return "", Webhook{}, false
+	}
+
+	if watts >= rule.RestoreBelowWatts {
+		state.crossingAt = time.Time{}
+		return "", Webhook{}, false
+	}
+	if state.crossingAt.IsZero() {
+		state.crossingAt = now
+	}
+	if now.Sub(state.crossingAt) < rule.RestoreFor {
+		return "", Webhook{}, false
+	}
+	state.shed = false
+	state.crossingAt = time.Time{}
+	return ActionRestore, rule.Restore, true
+}
+
+// enqueue queues an action without blocking; caller holds the lock
+func (s *LoadShedder) enqueue(a action) {
+	select {
+	case s.actions <- a:
+	default:
+		// Undo the state change so the rule triggers again
+		s.states[a.index] = ruleState{shed: a.name == ActionRestore}
+		s.logger.Warn("load shedding action queue full, dropping action",
+			zap.String("rule", a.rule.Name),
+			zap.String("action", a.name),
+		)
+	}
+}
+
+// revert undoes the state change of an action that was not executed, so the rule triggers again
+func (s *LoadShedder) revert(a action) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.states[a.index] = ruleState{shed: a.name == ActionRestore}
+}
+
+// Start executes queued actions until the context is cancelled
+func (s *LoadShedder) Start(ctx context.Context) {
+	s.logger.Info("starting load shedder", zap.Int("rule_count", len(s.rules)))
+
+	for {
+		select {
+		case <-ctx.Done():
+			s.logger.Info("stopping load shedder")
+			return
+		case a := <-s.actions:
+			s.execute(ctx, a)
+		}
+	}
+}
+
+// execute calls the action's webhook and records the outcome
+func (s *LoadShedder) execute(ctx context.Context, a action) {
+	err := s.caller.Call(ctx, a.webhook)
+
+	fields := map[string]string{
+		"rule":      a.rule.Name,
+		"sensor_id": fmt.Sprintf("%d", a.rule.SensorID),
+		"watts":     fmt.Sprintf("%.0f", a.watts),
+	}
+	if err != nil {
+		s.logger.Error("load shedding webhook failed",
+			zap.String("rule", a.rule.Name),
+			zap.String("action", a.name),
+			zap.Error(err),
+		)
+		s.revert(a)
+		fields["error"] = err.Error()
+		s.eventLog.Record(events.TypeLoadShedFailed, "loadshed",
+			fmt.Sprintf("%s action for rule %s failed", a.name, a.rule.Name), fields)
+		return
+	}
+
+	s.logger.Info("load shedding action executed",
+		zap.String("rule", a.rule.Name),
+		zap.String("action", a.name),
+		zap.Float64("watts", a.watts),
+	)
+
+	eventType := events.TypeLoadShed
+	if a.name == ActionRestore {
+		eventType = events.TypeLoadRestored
+	}
+	s.eventLog.Record(eventType, "loadshed",
+		fmt.Sprintf("%s action for rule %s at %.0f W", a.name, a.rule.Name, a.watts), fields)
+
+	s.buffer.Add(&buffer.Reading{
+		Type: buffer.ReadingTypeAutomation,
+		Automation: &buffer.AutomationReading{
+			Timestamp: time.Now(),
+			Rule:      a.rule.Name,
+			Active:    a.name == ActionShed,
+		},
+	})
+}
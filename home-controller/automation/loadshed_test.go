@@ -0,0 +1,152 @@
+package automation
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mjasion/balena-home/thermostats/buffer"
+	"github.com/mjasion/balena-home/thermostats/events"
+	"github.com/mjasion/balena-home/thermostats/power"
+	"go.uber.org/zap"
+)
+
+func newTestShedder(rule LoadShedRule) *LoadShedder {
+	logger := zap.NewNop()
+	return NewLoadShedder([]LoadShedRule{rule}, NewWebhookCaller(time.Second), buffer.New(100, logger), logger)
+}
+
+// observe feeds a reading and returns the queued action name, or "" if none
+func observe(s *LoadShedder, watts float64, at time.Time) string {
+	s.ObservePower(power.ActivePowerReading{SensorID: 0, Value: watts, Timestamp: at})
+	select {
+	case a := <-s.actions:
+		return a.name
+	default:
+		return ""
+	}
+}
+
+func TestLoadShedder_ShedAfterDuration(t *testing.T) {
+	shedder := newTestShedder(LoadShedRule{
+		Name:              "water-heater",
+		ThresholdWatts:    7000,
+		For:               30 * time.Second,
+		RestoreBelowWatts: 4000,
+		RestoreFor:        60 * time.Second,
+	})
+	start := time.Unix(1700000000, 0)
+
+	steps := []struct {
+		offset time.Duration
+		watts  float64
+		action string
+	}{
+		{0, 7500, ""},
+		{10 * time.Second, 6000, ""}, // Dip resets the timer
+		{20 * time.Second, 7500, ""},
+		{40 * time.Second, 7500, ""},
+		{50 * time.Second, 7600, ActionShed},
+		{60 * time.Second, 7600, ""}, // Already shed
+		{70 * time.Second, 3000, ""},
+		{100 * time.Second, 4500, ""}, // Above restore threshold resets the timer
+		{110 * time.Second, 3000, ""},
+		{170 * time.Second, 3000, ActionRestore},
+	}
+	for _, step := range steps {
+		if action := observe(shedder, step.watts, start.Add(step.offset)); action != step.action {
+			t.Errorf("At +%v with %.0f W: expected action %q, got %q", step.offset, step.watts, step.action, action)
+		}
+	}
+}
+
+func TestLoadShedder_RearmWithoutRestore(t *testing.T) {
+	shedder := newTestShedder(LoadShedRule{Name: "oven", ThresholdWatts: 3000})
+	start := time.Unix(1700000000, 0)
+
+	if action := observe(shedder, 3500, start); action != ActionShed {
+		t.Fatalf("Expected immediate shed with zero duration, got %q", action)
+	}
+	if action := observe(shedder, 3500, start.Add(time.Second)); action != "" {
+		t.Errorf("Expected no action while shed, got %q", action)
+	}
+	observe(shedder, 1000, start.Add(2*time.Second))
+	if action := observe(shedder, 3500, start.Add(3*time.Second)); action != ActionShed {
+		t.Errorf("Expected shed after re-arming, got %q", action)
+	}
+}
+
+func TestLoadShedder_IgnoresOtherSensors(t *testing.T) {
+	shedder := newTestShedder(LoadShedRule{Name: "oven", SensorID: 1, ThresholdWatts: 3000})
+
+	if action := observe(shedder, 9000, time.Now()); action != "" {
+		t.Errorf("Expected no action for another sensor, got %q", action)
+	}
+}
+
+func TestLoadShedder_Execute(t *testing.T) {
+	var mu sync.Mutex
+	var method, body string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		method, body = r.Method, string(data)
+		mu.Unlock()
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	logger := zap.NewNop()
+	buf := buffer.New(100, logger)
+	eventLog := events.NewLog(10, logger)
+	shedder := NewLoadShedder([]LoadShedRule{{
+		Name:           "water-heater",
+		ThresholdWatts: 7000,
+		Shed:           Webhook{Method: http.MethodPost, URL: server.URL, Body: `{"on":false}`},
+	}}, NewWebhookCaller(time.Second), buf, logger)
+	shedder.SetEventLog(eventLog)
+
+	shedder.ObservePower(power.ActivePowerReading{Value: 8000, Timestamp: time.Now()})
+	shedder.execute(context.Background(), <-shedder.actions)
+
+	mu.Lock()
+	if method != http.MethodPost || body != `{"on":false}` {
+		t.Errorf("Unexpected webhook request: %s %s", method, body)
+	}
+	mu.Unlock()
+
+	recorded := eventLog.List(events.Filter{Type: events.TypeLoadShed})
+	if len(recorded) != 1 || recorded[0].Fields["rule"] != "water-heater" {
+		t.Errorf("Expected load_shed event for water-heater, got %v", recorded)
+	}
+
+	readings := buf.GetAll()
+	if len(readings) != 1 || readings[0].Type != buffer.ReadingTypeAutomation || !readings[0].Automation.Active {
+		t.Errorf("Expected active automation reading, got %v", readings)
+	}
+}
+
+func TestLoadShedder_ExecuteFailureRetriggers(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	shedder := newTestShedder(LoadShedRule{
+		Name:           "water-heater",
+		ThresholdWatts: 7000,
+		Shed:           Webhook{URL: server.URL},
+	})
+	start := time.Unix(1700000000, 0)
+
+	shedder.ObservePower(power.ActivePowerReading{Value: 8000, Timestamp: start})
+	shedder.execute(context.Background(), <-shedder.actions)
+
+	if action := observe(shedder, 8000, start.Add(time.Second)); action != ActionShed {
+		t.Errorf("Expected shed to be retried after webhook failure, got %q", action)
+	}
+}
@@ -0,0 +1,205 @@
+package automation
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/mjasion/balena-home/thermostats/buffer"
+	"github.com/mjasion/balena-home/thermostats/events"
+	"github.com/mjasion/balena-home/thermostats/prices"
+	"go.uber.org/zap"
+)
+
+// Price rule actions
+const (
+	ActionEnable  = "enable"
+	ActionDisable = "disable"
+)
+
+// PriceRule switches a load on while electricity is cheap
+type PriceRule struct {
+	Name          string
+	BelowPrice    *float64 // Active while the current price is below this in PLN/kWh; nil disables
+	CheapestHours int      // Active during the N cheapest hours of the local day; 0 disables
+	Enable        Webhook
+	Disable       Webhook // Optional, an empty URL leaves the load to switch itself off
+}
+
+// PriceSchedule provides the known hourly electricity prices
+type PriceSchedule interface {
+	Prices() []prices.HourPrice
+}
+
+// PriceScheduler evaluates price rules against the current hour's price
+type PriceScheduler struct {
+	rules    []PriceRule
+	caller   *WebhookCaller
+	schedule PriceSchedule
+	buffer   *buffer.RingBuffer
+	eventLog *events.Log
+	interval time.Duration
+	logger   *zap.Logger
+	now      func() time.Time
+
+	active []bool // Last state of each rule
+}
+
+// NewPriceScheduler creates a scheduler evaluating the rules every intervalSeconds
+func NewPriceScheduler(rules []PriceRule, caller *WebhookCaller, schedule PriceSchedule, buf *buffer.RingBuffer, intervalSeconds int, logger *zap.Logger) *PriceScheduler {
+	return &PriceScheduler{
+		rules:    rules,
+		caller:   caller,
+		schedule: schedule,
+		buffer:   buf,
+		interval: time.Duration(intervalSeconds) * time.Second,
+		logger:   logger,
+		now:      time.Now,
+		active:   make([]bool, len(rules)),
+	}
+}
+
+// SetEventLog sets the event log used to record executed actions
+func (s *PriceScheduler) SetEventLog(eventLog *events.Log) {
+	s.eventLog = eventLog
+}
+
+// Start evaluates the rules every interval until the context is cancelled
+func (s *PriceScheduler) Start(ctx context.Context) {
+	s.logger.Info("starting price scheduler",
+		zap.Int("rule_count", len(s.rules)),
+		zap.Duration("interval", s.interval),
+	)
+
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			s.logger.Info("stopping price scheduler")
+			return
+		case <-ticker.C:
+			s.Evaluate(ctx)
+		}
+	}
+}
+
+// Evaluate runs the webhook of each rule whose state changed; rules keep their state while the
+// current price is unknown
+func (s *PriceScheduler) Evaluate(ctx context.Context) {
+	now := s.now()
+	hour := now.UTC().Truncate(time.Hour)
+
+	hourly := s.schedule.Prices()
+	var price float64
+	known := false
+	for _, p := range hourly {
+		if p.Start.Equal(hour) {
+			price, known = p.PLNPerKWh, true
+			break
+		}
+	}
+	if !known {
+		s.logger.Debug("no electricity price for the current hour, keeping price rule states")
+		return
+	}
+
+	for i, rule := range s.rules {
+		active, reason := s.matches(rule, hourly, now, price)
+		if active == s.active[i] {
+			continue
+		}
+		s.execute(ctx, i, active, price, reason)
+	}
+}
+
+// matches reports whether a rule is active at the current price and why
+func (s *PriceScheduler) matches(rule PriceRule, hourly []prices.HourPrice, now time.Time, price float64) (bool, string) {
+	if rule.BelowPrice != nil && price < *rule.BelowPrice {
+		return true, fmt.Sprintf("price below %.4f PLN/kWh", *rule.BelowPrice)
+	}
+	if rule.CheapestHours > 0 && cheapestHour(hourly, now, rule.CheapestHours) {
+		return true, fmt.Sprintf("one of the %d cheapest hours today", rule.CheapestHours)
+	}
+	return false, "price no longer cheap"
+}
+
+// cheapestHour reports whether the hour of now is among the n cheapest known hours of its local day
+func cheapestHour(hourly []prices.HourPrice, now time.Time, n int) bool {
+	year, month, day := now.Date()
+	dayStart := time.Date(year, month, day, 0, 0, 0, 0, now.Location())
+	dayEnd := dayStart.AddDate(0, 0, 1)
+
+	var today []prices.HourPrice
+	for _, p := range hourly {
+		if !p.Start.Before(dayStart) && p.Start.Before(dayEnd) {
+			today = append(today, p)
+		}
+	}
+	// Ties go to the earlier hour
+	sort.SliceStable(today, func(i, j int) bool {
+		if today[i].PLNPerKWh != today[j].PLNPerKWh {
+			return today[i].PLNPerKWh < today[j].PLNPerKWh
+		}
+		return today[i].Start.Before(today[j].Start)
+	})
+
+	hour := now.UTC().Truncate(time.Hour)
+	for i := 0; i < n && i < len(today); i++ {
+		if today[i].Start.Equal(hour) {
+			return true
+		}
+	}
+	return false
+}
+
+// execute calls the webhook of a state change and records the outcome; a failed webhook leaves
+// the state unchanged so the next evaluation retries
+func (s *PriceScheduler) execute(ctx context.Context, i int, active bool, price float64, reason string) {
+	rule := s.rules[i]
+	action, webhook := ActionEnable, rule.Enable
+	if !active {
+		action, webhook = ActionDisable, rule.Disable
+	}
+
+	fields := map[string]string{
+		"rule":        rule.Name,
+		"action":      action,
+		"pln_per_kwh": fmt.Sprintf("%.4f", price),
+		"reason":      reason,
+	}
+	if webhook.URL != "" {
+		if err := s.caller.Call(ctx, webhook); err != nil {
+			s.logger.Error("price rule webhook failed",
+				zap.String("rule", rule.Name),
+				zap.String("action", action),
+				zap.Error(err),
+			)
+			fields["error"] = err.Error()
+			s.eventLog.Record(events.TypeAutomationFailed, "price",
+				fmt.Sprintf("%s action for price rule %s failed", action, rule.Name), fields)
+			return
+		}
+	}
+	s.active[i] = active
+
+	s.logger.Info("price rule action executed",
+		zap.String("rule", rule.Name),
+		zap.String("action", action),
+		zap.Float64("pln_per_kwh", price),
+		zap.String("reason", reason),
+	)
+	s.eventLog.Record(events.TypeAutomationTriggered, "price",
+		fmt.Sprintf("%s action for price rule %s at %.4f PLN/kWh: %s", action, rule.Name, price, reason), fields)
+
+	s.buffer.Add(&buffer.Reading{
+		Type: buffer.ReadingTypeAutomation,
+		Automation: &buffer.AutomationReading{
+			Timestamp: s.now(),
+			Rule:      rule.Name,
+			Active:    active,
+		},
+	})
+}
@@ -0,0 +1,117 @@
+package automation
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/mjasion/balena-home/thermostats/buffer"
+	"github.com/mjasion/balena-home/thermostats/events"
+	"github.com/mjasion/balena-home/thermostats/prices"
+	"go.uber.org/zap"
+)
+
+// staticSchedule returns fixed hourly prices
+type staticSchedule []prices.HourPrice
+
+func (s staticSchedule) Prices() []prices.HourPrice {
+	return s
+}
+
+// daySchedule returns a price per UTC hour of the given day
+func daySchedule(day time.Time, hourly ...float64) staticSchedule {
+	var schedule staticSchedule
+	for i, price := range hourly {
+		schedule = append(schedule, prices.HourPrice{Start: day.Add(time.Duration(i) * time.Hour), PLNPerKWh: price})
+	}
+	return schedule
+}
+
+func TestCheapestHour(t *testing.T) {
+	day := time.Date(2026, 3, 10, 0, 0, 0, 0, time.UTC)
+	schedule := daySchedule(day, 0.5, 0.2, 0.3, 0.2, 0.9)
+	// The day before isn't ranked
+	schedule = append(schedule, prices.HourPrice{Start: day.Add(-time.Hour), PLNPerKWh: 0.01})
+
+	tests := []struct {
+		hour     int
+		cheapest bool
+	}{
+		{0, false},
+		{1, true},
+		{2, false}, // Third cheapest
+		{3, true},  // Tied with hour 1
+		{4, false},
+	}
+	for _, tt := range tests {
+		now := day.Add(time.Duration(tt.hour)*time.Hour + 30*time.Minute)
+		if got := cheapestHour(schedule, now, 2); got != tt.cheapest {
+			t.Errorf("Hour %d: expected cheapest %v, got %v", tt.hour, tt.cheapest, got)
+		}
+	}
+}
+
+func TestPriceScheduler_Evaluate(t *testing.T) {
+	var calls []string
+	failing := false
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if failing {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		calls = append(calls, r.URL.Path)
+	}))
+	defer server.Close()
+
+	day := time.Date(2026, 3, 10, 0, 0, 0, 0, time.UTC)
+	below := 0.25
+	rules := []PriceRule{{
+		Name:          "boiler",
+		BelowPrice:    &below,
+		CheapestHours: 1,
+		Enable:        Webhook{URL: server.URL + "/on"},
+		Disable:       Webhook{URL: server.URL + "/off"},
+	}}
+	logger := zap.NewNop()
+	buf := buffer.New(10, logger)
+	eventLog := events.NewLog(10, logger)
+	scheduler := NewPriceScheduler(rules, NewWebhookCaller(time.Second), daySchedule(day, 0.4, 0.3, 0.2, 0.5), buf, 60, logger)
+	scheduler.SetEventLog(eventLog)
+
+	steps := []struct {
+		hour    int
+		failing bool
+		calls   int
+		active  bool
+	}{
+		{0, false, 0, false},
+		{1, false, 0, false},
+		{2, true, 0, false}, // Below the price, but the webhook fails
+		{2, false, 1, true}, // Retried
+		{3, false, 2, false},
+		{5, false, 2, false}, // Unknown price keeps the state
+	}
+	for _, step := range steps {
+		failing = step.failing
+		scheduler.now = func() time.Time { return day.Add(time.Duration(step.hour) * time.Hour) }
+		scheduler.Evaluate(context.Background())
+		if len(calls) != step.calls || scheduler.active[0] != step.active {
+			t.Errorf("Hour %d: expected %d calls and active %v, got %v and %v", step.hour, step.calls, step.active, calls, scheduler.active[0])
+		}
+	}
+
+	if len(calls) != 2 || calls[0] != "/on" || calls[1] != "/off" {
+		t.Errorf("Expected /on then /off, got %v", calls)
+	}
+	if list := eventLog.List(events.Filter{Type: events.TypeAutomationTriggered}); len(list) != 2 {
+		t.Errorf("Expected 2 triggered events, got %d", len(list))
+	}
+	if list := eventLog.List(events.Filter{Type: events.TypeAutomationFailed}); len(list) != 1 {
+		t.Errorf("Expected 1 failed event, got %d", len(list))
+	}
+	if readings := buf.GetAll(); len(readings) != 2 || !readings[0].Automation.Active || readings[1].Automation.Active {
+		t.Errorf("Expected active then inactive automation readings, got %d readings", len(readings))
+	}
+}
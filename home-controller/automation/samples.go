@@ -0,0 +1,175 @@
+package automation
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/mjasion/balena-home/thermostats/buffer"
+)
+
+// Sample is a single metric value taken from a reading, named as it is pushed
+type Sample struct {
+	Metric string
+	Labels map[string]string
+	Value  float64
+}
+
+// Selector matches samples by metric name and label values
+type Selector struct {
+	Metric string
+	Labels map[string]string
+}
+
+// Matches reports whether the sample has the metric name and all selector labels
+func (s Selector) Matches(sample Sample) bool {
+	if s.Metric != sample.Metric {
+		return false
+	}
+	for name, value := range s.Labels {
+		if sample.Labels[name] != value {
+			return false
+		}
+	}
+	return true
+}
+
+// Samples flattens a reading into the samples pushed for it
+func Samples(reading *buffer.Reading) []Sample {
+	switch {
+	case reading.BLE != nil:
+		r := reading.BLE
+		labels := map[string]string{"sensor_name": r.SensorName, "sensor_id": fmt.Sprintf("%d", r.SensorID), "mac": r.MAC}
+		return []Sample{
+			{Metric: "ble_temperature_celsius", Labels: labels, Value: r.TemperatureCelsius},
+			{Metric: "ble_humidity_percent", Labels: labels, Value: float64(r.HumidityPercent)},
+			{Metric: "ble_battery_percent", Labels: labels, Value: float64(r.BatteryPercent)},
+		}
+	case reading.Thermostat != nil:
+		r := reading.Thermostat
+		if r.Stale || r.NoValues || r.Historical {
+			return nil
+		}
+		labels := map[string]string{"home_id": r.HomeID, "room_id": r.RoomID, "room_name": r.RoomName}
+		return []Sample{
+			{Metric: "netatmo_measured_temperature_celsius", Labels: labels, Value: r.MeasuredTemperature},
+			{Metric: "netatmo_setpoint_temperature_celsius", Labels: labels, Value: r.SetpointTemperature},
+			{Metric: "netatmo_heating_power_request", Labels: labels, Value: float64(r.HeatingPowerRequest)},
+		}
+	case reading.Power != nil:
+		r := reading.Power
+		if r.Stale {
+			return nil
+		}
+		return []Sample{
+			{Metric: "active_power_watts", Labels: map[string]string{"sensor_id": fmt.Sprintf("%d", r.SensorID)}, Value: r.Value},
+		}
+	case reading.HeatPump != nil:
+		return []Sample{{Metric: "heatpump_" + reading.HeatPump.Name, Value: reading.HeatPump.Value}}
+	case reading.Water != nil:
+		return []Sample{{Metric: "water_consumption_liters_total", Value: reading.Water.TotalLiters}}
+	case reading.OneWire != nil:
+		r := reading.OneWire
+		labels := map[string]string{"sensor_name": r.SensorName, "sensor_id": fmt.Sprintf("%d", r.SensorID), "device_id": r.DeviceID}
+		return []Sample{{Metric: "onewire_temperature_celsius", Labels: labels, Value: r.TemperatureCelsius}}
+	case reading.I2C != nil:
+		r := reading.I2C
+		labels := map[string]string{"sensor_name": r.SensorName, "sensor_id": fmt.Sprintf("%d", r.SensorID), "model": r.Model}
+		samples := []Sample{
+			{Metric: "i2c_temperature_celsius", Labels: labels, Value: r.TemperatureCelsius},
+			{Metric: "i2c_humidity_percent", Labels: labels, Value: r.HumidityPercent},
+		}
+		if r.HasPressure {
+			samples = append(samples, Sample{Metric: "i2c_pressure_hpa", Labels: labels, Value: r.PressureHPa})
+		}
+		return samples
+	case reading.AirQuality != nil:
+		r := reading.AirQuality
+		labels := map[string]string{"sensor_name": r.SensorName, "sensor_id": fmt.Sprintf("%d", r.SensorID), "model": r.Model}
+		samples := []Sample{{Metric: "air_co2_ppm", Labels: labels, Value: r.CO2PPM}}
+		if r.HasClimate {
+			samples = append(samples,
+				Sample{Metric: "air_temperature_celsius", Labels: labels, Value: r.TemperatureCelsius},
+				Sample{Metric: "air_humidity_percent", Labels: labels, Value: r.HumidityPercent},
+			)
+		}
+		return samples
+	case reading.Zigbee != nil:
+		r := reading.Zigbee
+		labels := map[string]string{"device": r.Device, "ieee_address": r.IEEEAddress, "model": r.Model, "vendor": r.Vendor, "class": r.Class}
+		return []Sample{{Metric: "zigbee_" + r.Metric, Labels: labels, Value: r.Value}}
+	case reading.Room != nil:
+		r := reading.Room
+		labels := map[string]string{"room": r.Room, "source": r.Source}
+		return []Sample{{Metric: "room_temperature_celsius", Labels: labels, Value: r.TemperatureCelsius}}
+	case reading.Remote != nil:
+		r := reading.Remote
+		return []Sample{{Metric: r.Metric, Labels: r.Labels, Value: r.Value}}
+	case reading.Battery != nil:
+		r := reading.Battery
+		labels := map[string]string{"sensor_name": r.SensorName, "sensor_id": fmt.Sprintf("%d", r.SensorID), "mac": r.MAC}
+		return []Sample{{Metric: "ble_battery_days_remaining", Labels: labels, Value: r.DaysRemaining}}
+	case reading.Reconciliation != nil:
+		r := reading.Reconciliation
+		labels := map[string]string{"source": r.Source}
+		return []Sample{{Metric: "energy_reconciliation_drift_percent", Labels: labels, Value: r.DriftPercent}}
+	case reading.Price != nil:
+		r := reading.Price
+		labels := map[string]string{"source": r.Source, "hours_ahead": fmt.Sprintf("%d", r.HoursAhead)}
+		return []Sample{{Metric: "electricity_price_pln_per_kwh", Labels: labels, Value: r.PLNPerKWh}}
+	case reading.Occupancy != nil:
+		r := reading.Occupancy
+		value := 0.0
+		if r.Home {
+			value = 1
+		}
+		return []Sample{{Metric: "occupancy_state", Labels: map[string]string{"source": r.Source}, Value: value}}
+	case reading.Ventilation != nil:
+		value := 0.0
+		if reading.Ventilation.Recommended {
+			value = 1
+		}
+		return []Sample{{Metric: "ventilation_recommended", Value: value}}
+	}
+	return nil
+}
+
+// TimestampOf returns the timestamp of the reading's populated variant, or the zero time
+func TimestampOf(reading *buffer.Reading) time.Time {
+	switch {
+	case reading.BLE != nil:
+		return reading.BLE.Timestamp
+	case reading.Thermostat != nil:
+		return reading.Thermostat.Timestamp
+	case reading.Power != nil:
+		return reading.Power.Timestamp
+	case reading.HeatPump != nil:
+		return reading.HeatPump.Timestamp
+	case reading.Water != nil:
+		return reading.Water.Timestamp
+	case reading.OneWire != nil:
+		return reading.OneWire.Timestamp
+	case reading.I2C != nil:
+		return reading.I2C.Timestamp
+	case reading.AirQuality != nil:
+		return reading.AirQuality.Timestamp
+	case reading.Zigbee != nil:
+		return reading.Zigbee.Timestamp
+	case reading.Room != nil:
+		return reading.Room.Timestamp
+	case reading.Remote != nil:
+		return reading.Remote.Timestamp
+	case reading.Battery != nil:
+		return reading.Battery.Timestamp
+	case reading.Location != nil:
+		return reading.Location.Timestamp
+	case reading.Reconciliation != nil:
+		return reading.Reconciliation.Timestamp
+	case reading.Price != nil:
+		return reading.Price.Timestamp
+	case reading.Occupancy != nil:
+		return reading.Occupancy.Timestamp
+	case reading.Ventilation != nil:
+		return reading.Ventilation.Timestamp
+	}
+	return time.Time{}
+}
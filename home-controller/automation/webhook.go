@@ -0,0 +1,65 @@
+package automation
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/mjasion/balena-home/thermostats/httpclient"
+)
+
+// Webhook is an HTTP request triggered by an automation, e.g. a Shelly relay switch
+type Webhook struct {
+	Method  string
+	URL     string
+	Body    string
+	Headers map[string]string
+}
+
+// WebhookCaller sends webhook requests
+type WebhookCaller struct {
+	client *http.Client
+}
+
+// NewWebhookCaller creates a caller with the given request timeout
+func NewWebhookCaller(timeout time.Duration) *WebhookCaller {
+	return &WebhookCaller{
+		client: httpclient.NewDefault(httpclient.Config{Timeout: timeout}),
+	}
+}
+
+// Call sends the webhook request and checks for a 2xx response
+func (c *WebhookCaller) Call(ctx context.Context, webhook Webhook) error {
+	method := webhook.Method
+	if method == "" {
+		method = http.MethodPost
+	}
+
+	var body io.Reader
+	if webhook.Body != "" {
+		body = strings.NewReader(webhook.Body)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, webhook.URL, body)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	for name, value := range webhook.Headers {
+		req.Header.Set(name, value)
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		respBody, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("unexpected status code %d: %s", resp.StatusCode, string(respBody))
+	}
+	return nil
+}
@@ -0,0 +1,327 @@
+package battery
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mjasion/balena-home/thermostats/buffer"
+	"github.com/mjasion/balena-home/thermostats/events"
+	"go.uber.org/zap"
+)
+
+// sampleInterval is the minimum spacing of stored samples per sensor;
+// battery levels change over weeks, so hourly samples keep the state file small
+const sampleInterval = time.Hour
+
+// minHistory is the span of samples needed before a sensor gets an estimate
+const minHistory = 48 * time.Hour
+
+// replacementJump is the rise in battery percent taken as a battery replacement, which restarts the history
+const replacementJump = 20
+
+// Sample is a stored battery level of a sensor
+type Sample struct {
+	Timestamp time.Time `json:"timestamp"`
+	Percent   int       `json:"percent"`
+	VoltageMV int       `json:"voltage_mv"`
+}
+
+// history is the persisted battery history of one sensor, keyed by MAC address
+type history struct {
+	SensorName string   `json:"sensor_name"`
+	SensorID   int      `json:"sensor_id"`
+	Samples    []Sample `json:"samples"`
+}
+
+// Estimate is the projected battery life of a sensor
+type Estimate struct {
+	MAC           string  `json:"mac"`
+	SensorName    string  `json:"sensor_name"`
+	SensorID      int     `json:"sensor_id"`
+	Percent       int     `json:"percent"`
+	PercentPerDay float64 `json:"percent_per_day"`
+	DaysRemaining float64 `json:"days_remaining"`
+	HistoryDays   float64 `json:"history_days"`
+}
+
+// Estimator fits a line to each BLE sensor's battery percent over a sliding window
+// and projects the days until it reaches zero
+type Estimator struct {
+	buffer    *buffer.RingBuffer
+	window    time.Duration
+	alertDays float64
+	statePath string
+	interval  time.Duration
+	logger    *zap.Logger
+	eventLog  *events.Log
+	now       func() time.Time
+
+	mu      sync.Mutex
+	sensors map[string]*history // By MAC address
+	alerted map[string]bool     // Sensors below alertDays, so the event is recorded once
+}
+
+// New creates an estimator fitting the last windowDays of history and reporting estimates every
+// intervalSeconds; register Observe as a buffer listener to feed it
+func New(buf *buffer.RingBuffer, windowDays, alertDays int, statePath string, intervalSeconds int, logger *zap.Logger) *Estimator {
+	return &Estimator{
+		buffer:    buf,
+		window:    time.Duration(windowDays) * 24 * time.Hour,
+		alertDays: float64(alertDays),
+		statePath: statePath,
+		interval:  time.Duration(intervalSeconds) * time.Second,
+		logger:    logger,
+		now:       time.Now,
+		sensors:   make(map[string]*history),
+		alerted:   make(map[string]bool),
+	}
+}
+
+// SetEventLog sets the event log used to record sensors whose battery is running out
+func (e *Estimator) SetEventLog(eventLog *events.Log) {
+	e.eventLog = eventLog
+}
+
+// Observe stores the battery level of BLE readings, at most once per sampleInterval per sensor
+func (e *Estimator) Observe(reading *buffer.Reading) {
+	r := reading.BLE
+	if r == nil {
+		return
+	}
+	mac := strings.ToUpper(r.MAC)
+	sample := Sample{Timestamp: r.Timestamp, Percent: r.BatteryPercent, VoltageMV: r.BatteryVoltageMV}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	h, ok := e.sensors[mac]
+	if !ok {
+		h = &history{}
+		e.sensors[mac] = h
+	}
+	h.SensorName = r.SensorName
+	h.SensorID = r.SensorID
+
+	if n := len(h.Samples); n > 0 {
+		last := h.Samples[n-1]
+		if sample.Percent >= last.Percent+replacementJump {
+			e.logger.Info("battery replaced, restarting history",
+				zap.String("sensor_name", r.SensorName),
+				zap.String("mac", mac),
+				zap.Int("previous_percent", last.Percent),
+				zap.Int("percent", sample.Percent),
+			)
+			h.Samples = nil
+			delete(e.alerted, mac)
+		} else if sample.Timestamp.Sub(last.Timestamp) < sampleInterval {
+			return
+		}
+	}
+	h.Samples = append(h.Samples, sample)
+}
+
+// Estimates returns the current estimate of every sensor with enough draining history, by sensor name
+func (e *Estimator) Estimates() []Estimate {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.estimates()
+}
+
+// estimates fits each sensor's history; the caller must hold the lock
+func (e *Estimator) estimates() []Estimate {
+	var estimates []Estimate
+	for mac, h := range e.sensors {
+		estimate, ok := fit(h.Samples)
+		if !ok {
+			continue
+		}
+		estimate.MAC = mac
+		estimate.SensorName = h.SensorName
+		estimate.SensorID = h.SensorID
+		estimates = append(estimates, estimate)
+	}
+	sort.Slice(estimates, func(i, j int) bool {
+		if estimates[i].SensorName != estimates[j].SensorName {
+			return estimates[i].SensorName < estimates[j].SensorName
+		}
+		return estimates[i].MAC < estimates[j].MAC
+	})
+	return estimates
+}
+
+// fit projects the battery life of a history with a least-squares line of percent over time;
+// it reports false without minHistory of samples or when the level isn't falling
+func fit(samples []Sample) (Estimate, bool) {
+	if len(samples) < 2 {
+		return Estimate{}, false
+	}
+	first, last := samples[0].Timestamp, samples[len(samples)-1].Timestamp
+	if last.Sub(first) < minHistory {
+		return Estimate{}, false
+	}
+
+	// Days since the first sample against percent
+	var sumX, sumY, sumXX, sumXY float64
+	for _, s := range samples {
+		x := s.Timestamp.Sub(first).Hours() / 24
+		y := float64(s.Percent)
+		sumX += x
+		sumY += y
+		sumXX += x * x
+		sumXY += x * y
+	}
+	n := float64(len(samples))
+	denominator := n*sumXX - sumX*sumX
+	if denominator == 0 {
+		return Estimate{}, false
+	}
+	slope := (n*sumXY - sumX*sumY) / denominator
+	if slope >= 0 {
+		return Estimate{}, false
+	}
+	intercept := (sumY - slope*sumX) / n
+
+	// Project from the fitted level rather than the last sample, which jitters with temperature
+	historyDays := last.Sub(first).Hours() / 24
+	level := intercept + slope*historyDays
+	days := -level / slope
+	if days < 0 {
+		days = 0
+	}
+	return Estimate{
+		Percent:       samples[len(samples)-1].Percent,
+		PercentPerDay: slope,
+		DaysRemaining: days,
+		HistoryDays:   historyDays,
+	}, true
+}
+
+// Start periodically reports estimates until the context is cancelled, then saves the state
+func (e *Estimator) Start(ctx context.Context) {
+	e.logger.Info("starting battery estimator",
+		zap.Duration("interval", e.interval),
+		zap.Duration("window", e.window),
+	)
+
+	ticker := time.NewTicker(e.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			e.logger.Info("stopping battery estimator")
+			if err := e.Save(); err != nil {
+				e.logger.Warn("failed to save battery state", zap.Error(err))
+			}
+			return
+		case <-ticker.C:
+			e.report()
+			if err := e.Save(); err != nil {
+				e.logger.Warn("failed to save battery state", zap.Error(err))
+			}
+		}
+	}
+}
+
+// report drops samples outside the window, adds a reading per estimate and records
+// an event for sensors dropping below alertDays
+func (e *Estimator) report() {
+	now := e.now()
+	e.mu.Lock()
+	cutoff := now.Add(-e.window)
+	for mac, h := range e.sensors {
+		i := sort.Search(len(h.Samples), func(i int) bool { return !h.Samples[i].Timestamp.Before(cutoff) })
+		h.Samples = h.Samples[i:]
+		if len(h.Samples) == 0 {
+			delete(e.sensors, mac)
+			delete(e.alerted, mac)
+		}
+	}
+	estimates := e.estimates()
+	var low []Estimate
+	for _, estimate := range estimates {
+		below := estimate.DaysRemaining < e.alertDays
+		if below && !e.alerted[estimate.MAC] {
+			low = append(low, estimate)
+		}
+		e.alerted[estimate.MAC] = below
+	}
+	e.mu.Unlock()
+
+	for _, estimate := range low {
+		e.logger.Warn("sensor battery running out",
+			zap.String("sensor_name", estimate.SensorName),
+			zap.String("mac", estimate.MAC),
+			zap.Int("battery_percent", estimate.Percent),
+			zap.Float64("days_remaining", estimate.DaysRemaining),
+		)
+		e.eventLog.Record(events.TypeBatteryLow, "battery",
+			fmt.Sprintf("sensor %s battery empty in %.0f days", estimate.SensorName, estimate.DaysRemaining),
+			map[string]string{
+				"mac":            estimate.MAC,
+				"sensor_id":      fmt.Sprintf("%d", estimate.SensorID),
+				"days_remaining": fmt.Sprintf("%.1f", estimate.DaysRemaining),
+			},
+		)
+	}
+
+	// Estimates were copied under e.mu; adding them doesn't need it
+	for _, estimate := range estimates {
+		e.buffer.Add(&buffer.Reading{
+			Type: buffer.ReadingTypeBattery,
+			Battery: &buffer.BatteryReading{
+				Timestamp:     now,
+				MAC:           estimate.MAC,
+				SensorName:    estimate.SensorName,
+				SensorID:      estimate.SensorID,
+				DaysRemaining: estimate.DaysRemaining,
+			},
+		})
+	}
+}
+
+// Load restores the battery history from the state file; a missing file starts empty
+func (e *Estimator) Load() error {
+	data, err := os.ReadFile(e.statePath)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read state file: %w", err)
+	}
+
+	sensors := make(map[string]*history)
+	if err := json.Unmarshal(data, &sensors); err != nil {
+		return fmt.Errorf("failed to parse state file: %w", err)
+	}
+
+	e.mu.Lock()
+	e.sensors = sensors
+	e.mu.Unlock()
+	return nil
+}
+
+// Save persists the battery history to the state file atomically
+func (e *Estimator) Save() error {
+	e.mu.Lock()
+	data, err := json.Marshal(e.sensors)
+	e.mu.Unlock()
+	if err != nil {
+		return fmt.Errorf("failed to marshal state: %w", err)
+	}
+
+	tmpPath := e.statePath + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0644); err != nil {
+		return fmt.Errorf("failed to write state file: %w", err)
+	}
+	if err := os.Rename(tmpPath, e.statePath); err != nil {
+		return fmt.Errorf("failed to replace state file: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,160 @@
+package battery
+
+import (
+	"math"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/mjasion/balena-home/thermostats/buffer"
+	"github.com/mjasion/balena-home/thermostats/events"
+	"go.uber.org/zap"
+)
+
+func bleReading(ts time.Time, percent int) *buffer.Reading {
+	return &buffer.Reading{
+		Type: buffer.ReadingTypeBLE,
+		BLE: &buffer.SensorReading{
+			Timestamp:      ts,
+			MAC:            "a4:c1:38:00:00:01",
+			SensorName:     "kitchen",
+			SensorID:       1,
+			BatteryPercent: percent,
+		},
+	}
+}
+
+func TestEstimator_LinearDrain(t *testing.T) {
+	buf := buffer.New(100, zap.NewNop())
+	estimator := New(buf, 30, 14, filepath.Join(t.TempDir(), "battery.json"), 60, zap.NewNop())
+
+	// 1% per day from 80%, sampled every 6 hours over 10 days
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	for h := 0; h <= 240; h += 6 {
+		estimator.Observe(bleReading(start.Add(time.Duration(h)*time.Hour), 80-h/24))
+	}
+
+	estimates := estimator.Estimates()
+	if len(estimates) != 1 {
+		t.Fatalf("Expected 1 estimate, got %d", len(estimates))
+	}
+	estimate := estimates[0]
+	if estimate.MAC != "A4:C1:38:00:00:01" || estimate.SensorName != "kitchen" {
+		t.Errorf("Expected kitchen A4:C1:38:00:00:01, got %s %s", estimate.SensorName, estimate.MAC)
+	}
+	if math.Abs(estimate.PercentPerDay+1) > 0.1 {
+		t.Errorf("Expected about -1%%/day, got %.2f", estimate.PercentPerDay)
+	}
+	if math.Abs(estimate.DaysRemaining-70) > 2 {
+		t.Errorf("Expected about 70 days remaining, got %.1f", estimate.DaysRemaining)
+	}
+}
+
+func TestEstimator_NoEstimate(t *testing.T) {
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name     string
+		hours    int
+		percents func(h int) int
+	}{
+		{"short history", 24, func(h int) int { return 80 - h }},
+		{"flat level", 240, func(h int) int { return 80 }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			estimator := New(buffer.New(10, zap.NewNop()), 30, 14, "", 60, zap.NewNop())
+			for h := 0; h <= tt.hours; h += 6 {
+				estimator.Observe(bleReading(start.Add(time.Duration(h)*time.Hour), tt.percents(h)))
+			}
+			if estimates := estimator.Estimates(); len(estimates) != 0 {
+				t.Errorf("Expected no estimate, got %+v", estimates)
+			}
+		})
+	}
+}
+
+func TestEstimator_SampleSpacingAndReplacement(t *testing.T) {
+	estimator := New(buffer.New(10, zap.NewNop()), 30, 14, "", 60, zap.NewNop())
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	estimator.Observe(bleReading(start, 30))
+	estimator.Observe(bleReading(start.Add(10*time.Minute), 30))
+	estimator.Observe(bleReading(start.Add(2*time.Hour), 29))
+	if got := len(estimator.sensors["A4:C1:38:00:00:01"].Samples); got != 2 {
+		t.Errorf("Expected 2 samples at least an hour apart, got %d", got)
+	}
+
+	estimator.Observe(bleReading(start.Add(3*time.Hour), 100))
+	samples := estimator.sensors["A4:C1:38:00:00:01"].Samples
+	if len(samples) != 1 || samples[0].Percent != 100 {
+		t.Errorf("Expected history restarted at 100%%, got %+v", samples)
+	}
+}
+
+func TestEstimator_ReportAndAlert(t *testing.T) {
+	buf := buffer.New(100, zap.NewNop())
+	eventLog := events.NewLog(10, zap.NewNop())
+	estimator := New(buf, 30, 14, "", 60, zap.NewNop())
+	estimator.SetEventLog(eventLog)
+
+	// 2% per day from 30%, leaving about 10 days
+	start := time.Now().Add(-10 * 24 * time.Hour)
+	for h := 0; h <= 240; h += 6 {
+		estimator.Observe(bleReading(start.Add(time.Duration(h)*time.Hour), 30-h/12))
+	}
+
+	estimator.report()
+	estimator.report()
+
+	readings := buf.GetAll()
+	if len(readings) != 2 || readings[0].Battery == nil {
+		t.Fatalf("Expected 2 battery readings, got %+v", readings)
+	}
+	if days := readings[0].Battery.DaysRemaining; days < 5 || days > 15 {
+		t.Errorf("Expected about 10 days remaining, got %.1f", days)
+	}
+	if got := len(eventLog.List(events.Filter{Type: events.TypeBatteryLow})); got != 1 {
+		t.Errorf("Expected 1 battery_low event, got %d", got)
+	}
+}
+
+func TestEstimator_WindowPrunes(t *testing.T) {
+	estimator := New(buffer.New(10, zap.NewNop()), 3, 14, "", 60, zap.NewNop())
+	start := time.Now().Add(-10 * 24 * time.Hour)
+	for h := 0; h <= 24; h += 6 {
+		estimator.Observe(bleReading(start.Add(time.Duration(h)*time.Hour), 80))
+	}
+
+	estimator.report()
+
+	if len(estimator.sensors) != 0 {
+		t.Errorf("Expected sensors without recent samples to be dropped, got %d", len(estimator.sensors))
+	}
+}
+
+func TestEstimator_SaveLoad(t *testing.T) {
+	statePath := filepath.Join(t.TempDir(), "battery.json")
+	estimator := New(buffer.New(10, zap.NewNop()), 30, 14, statePath, 60, zap.NewNop())
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	estimator.Observe(bleReading(start, 80))
+	estimator.Observe(bleReading(start.Add(time.Hour), 79))
+
+	if err := estimator.Save(); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+
+	restored := New(buffer.New(10, zap.NewNop()), 30, 14, statePath, 60, zap.NewNop())
+	if err := restored.Load(); err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	h := restored.sensors["A4:C1:38:00:00:01"]
+	if h == nil || h.SensorName != "kitchen" || len(h.Samples) != 2 || h.Samples[1].Percent != 79 {
+		t.Errorf("Expected restored kitchen history with 2 samples, got %+v", h)
+	}
+
+	missing := New(buffer.New(10, zap.NewNop()), 30, 14, filepath.Join(t.TempDir(), "missing.json"), 60, zap.NewNop())
+	if err := missing.Load(); err != nil {
+		t.Errorf("Expected missing state file to start empty, got %v", err)
+	}
+}
@@ -0,0 +1,26 @@
+package battery
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/mjasion/balena-home/thermostats/admin"
+)
+
+// RegisterHandlers registers the battery estimates on the admin server
+func (e *Estimator) RegisterHandlers(server *admin.Server) {
+	server.HandleFunc("GET /api/sensors/battery", e.handleEstimates)
+}
+
+// handleEstimates handles GET /api/sensors/battery
+func (e *Estimator) handleEstimates(w http.ResponseWriter, r *http.Request) {
+	estimates := e.Estimates()
+	if estimates == nil {
+		estimates = []Estimate{}
+	}
+	admin.WriteJSON(w, http.StatusOK, admin.Response{
+		Success: true,
+		Message: fmt.Sprintf("%d battery estimates", len(estimates)),
+		Data:    estimates,
+	})
+}
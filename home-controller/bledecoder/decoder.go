@@ -1,4 +1,4 @@
-package decoder
+package bledecoder
 
 import (
 	"encoding/binary"
@@ -6,8 +6,8 @@ import (
 	"time"
 )
 
-// SensorReading represents a decoded BLE sensor advertisement
-type SensorReading struct {
+// Reading represents a decoded BLE sensor advertisement
+type Reading struct {
 	Timestamp          time.Time
 	MAC                string
 	TemperatureCelsius float64
@@ -26,7 +26,7 @@ type SensorReading struct {
 // - Byte 9: Battery percentage (unsigned int8)
 // - Bytes 10-11: Battery voltage in mV (little endian unsigned int16)
 // - Byte 12: Frame counter (unsigned int8)
-func DecodeATCAdvertisement(data []byte, rssi int16) (*SensorReading, error) {
+func DecodeATCAdvertisement(data []byte, rssi int16) (*Reading, error) {
 	if len(data) < 13 {
 		return nil, fmt.Errorf("invalid ATC advertisement length: expected at least 13 bytes, got %d", len(data))
 	}
@@ -51,7 +51,7 @@ func DecodeATCAdvertisement(data []byte, rssi int16) (*SensorReading, error) {
 	// Extract frame counter (byte 12, unsigned int8)
 	frameCounter := int(data[12])
 
-	reading := &SensorReading{
+	reading := &Reading{
 		Timestamp:          time.Now(),
 		MAC:                mac,
 		TemperatureCelsius: temperature,
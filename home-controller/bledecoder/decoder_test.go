@@ -1,6 +1,7 @@
-package decoder
+package bledecoder
 
 import (
+	"errors"
 	"testing"
 	"time"
 )
@@ -243,3 +244,25 @@ func TestDecodeATCAdvertisement_LongerData(t *testing.T) {
 		t.Errorf("Expected MAC A4:C1:38:12:34:56, got %s", reading.MAC)
 	}
 }
+
+func TestDecode(t *testing.T) {
+	data := []byte{0xA4, 0xC1, 0x38, 0x12, 0x34, 0x56, 0x00, 0xE1, 0x41, 0x5F, 0xB8, 0x0B, 0x2A}
+
+	reading, format, err := Decode(ServiceUUIDATC, data, -65)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if format != FormatATC || reading.TemperatureCelsius != 22.5 {
+		t.Errorf("Expected an ATC reading of 22.5°C, got %s %+v", format, reading)
+	}
+
+	// A known format reports itself alongside its decode error
+	if _, format, err := Decode(ServiceUUIDATC, data[:5], -65); err == nil || format != FormatATC {
+		t.Errorf("Expected an ATC decode error, got %s %v", format, err)
+	}
+
+	// Service data of other UUIDs, e.g. Xiaomi's 0xFE95, is not decoded
+	if _, _, err := Decode(0xFE95, data, -65); !errors.Is(err, ErrUnknownFormat) {
+		t.Errorf("Expected ErrUnknownFormat, got %v", err)
+	}
+}
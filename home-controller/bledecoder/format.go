@@ -0,0 +1,43 @@
+package bledecoder
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Format identifies a sensor advertisement format
+type Format string
+
+// Advertisement formats
+const (
+	FormatATC Format = "atc" // ATC_MiThermometer custom format
+)
+
+// ServiceUUIDATC is the 16-bit service UUID used by ATC_MiThermometer firmware
+const ServiceUUIDATC uint16 = 0x181A
+
+// ErrUnknownFormat is returned for service data of a UUID no format is decoded from, which
+// callers skip as it belongs to another device or service
+var ErrUnknownFormat = errors.New("unknown advertisement format")
+
+// decoders decode the data of each service UUID; a new format registers its UUID here
+var decoders = map[uint16]struct {
+	format Format
+	decode func(data []byte, rssi int16) (*Reading, error)
+}{
+	ServiceUUIDATC: {FormatATC, DecodeATCAdvertisement},
+}
+
+// Decode decodes the service data of a sensor advertisement, choosing the format by its 16-bit
+// service UUID
+func Decode(serviceUUID uint16, data []byte, rssi int16) (*Reading, Format, error) {
+	decoder, ok := decoders[serviceUUID]
+	if !ok {
+		return nil, "", fmt.Errorf("%w: service UUID 0x%04X", ErrUnknownFormat, serviceUUID)
+	}
+	reading, err := decoder.decode(data, rssi)
+	if err != nil {
+		return nil, decoder.format, err
+	}
+	return reading, decoder.format, nil
+}
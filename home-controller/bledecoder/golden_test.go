@@ -0,0 +1,116 @@
+package bledecoder
+
+import (
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "rewrite golden files with the current output")
+
+// macRegex matches the MAC format produced by the decoder
+var macRegex = regexp.MustCompile(`^([0-9A-F]{2}:){5}[0-9A-F]{2}$`)
+
+// readHex reads a testdata advertisement; whitespace separates fields for readability
+func readHex(t *testing.T, path string) []byte {
+	t.Helper()
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read %s: %v", path, err)
+	}
+	data, err := hex.DecodeString(strings.Join(strings.Fields(string(content)), ""))
+	if err != nil {
+		t.Fatalf("Invalid hex in %s: %v", path, err)
+	}
+	return data
+}
+
+// formatReading renders a decode result without the timestamp
+func formatReading(reading *Reading, err error) string {
+	if err != nil {
+		return fmt.Sprintf("error: %v\n", err)
+	}
+	return fmt.Sprintf("mac: %s\ntemperature_celsius: %.1f\nhumidity_percent: %d\nbattery_percent: %d\nbattery_voltage_mv: %d\nframe_counter: %d\nrssi: %d\n",
+		reading.MAC, reading.TemperatureCelsius, reading.HumidityPercent, reading.BatteryPercent,
+		reading.BatteryVoltageMV, reading.FrameCounter, reading.RSSI)
+}
+
+func TestDecodeATCAdvertisement_Golden(t *testing.T) {
+	inputs, err := filepath.Glob(filepath.Join("testdata", "atc_*.hex"))
+	if err != nil || len(inputs) == 0 {
+		t.Fatalf("Expected golden inputs in testdata, got %v (%v)", inputs, err)
+	}
+
+	for _, input := range inputs {
+		name := strings.TrimSuffix(filepath.Base(input), ".hex")
+		t.Run(name, func(t *testing.T) {
+			output := formatReading(DecodeATCAdvertisement(readHex(t, input), -65))
+
+			golden := strings.TrimSuffix(input, ".hex") + ".golden"
+			if *update {
+				if err := os.WriteFile(golden, []byte(output), 0o644); err != nil {
+					t.Fatalf("Failed to update golden file: %v", err)
+				}
+				return
+			}
+			expected, err := os.ReadFile(golden)
+			if err != nil {
+				t.Fatalf("Failed to read golden file (run with -update to create it): %v", err)
+			}
+			if string(expected) != output {
+				t.Errorf("Output does not match %s:\nexpected:\n%s\ngot:\n%s", golden, expected, output)
+			}
+		})
+	}
+}
+
+// FuzzDecodeATCAdvertisement checks that arbitrary radio payloads never panic
+// and that decoded values stay within what the wire format can represent
+func FuzzDecodeATCAdvertisement(f *testing.F) {
+	inputs, _ := filepath.Glob(filepath.Join("testdata", "atc_*.hex"))
+	for _, input := range inputs {
+		content, _ := os.ReadFile(input)
+		data, _ := hex.DecodeString(strings.Join(strings.Fields(string(content)), ""))
+		f.Add(data, int16(-65))
+	}
+
+	f.Fuzz(func(t *testing.T, data []byte, rssi int16) {
+		reading, err := DecodeATCAdvertisement(data, rssi)
+		if len(data) < 13 {
+			if err == nil {
+				t.Fatalf("Expected error for %d byte payload", len(data))
+			}
+			return
+		}
+		if err != nil {
+			t.Fatalf("Expected no error for %d byte payload, got: %v", len(data), err)
+		}
+
+		if !macRegex.MatchString(reading.MAC) {
+			t.Errorf("Invalid MAC %q", reading.MAC)
+		}
+		if reading.TemperatureCelsius < -3276.8 || reading.TemperatureCelsius > 3276.7 {
+			t.Errorf("Temperature %v outside int16 range", reading.TemperatureCelsius)
+		}
+		if reading.HumidityPercent < 0 || reading.HumidityPercent > 255 {
+			t.Errorf("Humidity %d outside byte range", reading.HumidityPercent)
+		}
+		if reading.BatteryPercent < 0 || reading.BatteryPercent > 255 {
+			t.Errorf("Battery %d outside byte range", reading.BatteryPercent)
+		}
+		if reading.BatteryVoltageMV < 0 || reading.BatteryVoltageMV > 65535 {
+			t.Errorf("Battery voltage %d outside uint16 range", reading.BatteryVoltageMV)
+		}
+		if reading.FrameCounter < 0 || reading.FrameCounter > 255 {
+			t.Errorf("Frame counter %d outside byte range", reading.FrameCounter)
+		}
+		if reading.RSSI != rssi {
+			t.Errorf("Expected RSSI %d, got %d", rssi, reading.RSSI)
+		}
+	})
+}
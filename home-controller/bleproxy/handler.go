@@ -0,0 +1,69 @@
+package bleproxy
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/mjasion/balena-home/thermostats/admin"
+	"go.uber.org/zap"
+)
+
+// maxRequestBytes limits the size of a relayed advertisement batch
+const maxRequestBytes = 1 << 20
+
+// Handler accepts advertisements from ESP32 proxies that post over HTTP instead of MQTT
+type Handler struct {
+	proxy *Proxy
+	token string
+}
+
+// NewHandler creates an HTTP handler; an empty token accepts unauthenticated requests
+func NewHandler(proxy *Proxy, token string) *Handler {
+	return &Handler{
+		proxy: proxy,
+		token: token,
+	}
+}
+
+// RegisterHandlers registers the advertisement endpoint on the admin server
+func (h *Handler) RegisterHandlers(server *admin.Server) {
+	server.HandleFunc("POST /api/ble/advertisements", h.handleAdvertisements)
+}
+
+// handleAdvertisements handles POST /api/ble/advertisements
+// The proxy name is taken from the X-Proxy-Name header, falling back to the remote address
+func (h *Handler) handleAdvertisements(w http.ResponseWriter, r *http.Request) {
+	if !admin.BearerAuthorized(r, h.token) {
+		admin.WriteError(w, http.StatusUnauthorized, "invalid or missing bearer token")
+		return
+	}
+
+	data, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxRequestBytes))
+	if err != nil {
+		admin.WriteError(w, http.StatusRequestEntityTooLarge, "request too large")
+		return
+	}
+
+	advertisements, err := parseAdvertisements(data)
+	if err != nil {
+		admin.WriteError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	source := r.Header.Get("X-Proxy-Name")
+	if source == "" {
+		source = r.RemoteAddr
+	}
+	added := h.proxy.Ingest(source, advertisements)
+
+	h.proxy.logger.Debug("received BLE proxy advertisements",
+		zap.String("proxy", source),
+		zap.Int("advertisement_count", len(advertisements)),
+		zap.Int("reading_count", added),
+	)
+	admin.WriteJSON(w, http.StatusOK, admin.Response{
+		Success: true,
+		Message: fmt.Sprintf("Accepted %d of %d advertisements.", added, len(advertisements)),
+	})
+}
@@ -0,0 +1,267 @@
+package bleproxy
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mjasion/balena-home/thermostats/bledecoder"
+	"github.com/mjasion/balena-home/thermostats/buffer"
+	"github.com/mjasion/balena-home/thermostats/events"
+	"github.com/mjasion/balena-home/thermostats/locator"
+	"go.uber.org/zap"
+)
+
+// Advertisement is a raw BLE advertisement relayed by an ESP32 proxy
+type Advertisement struct {
+	Address     string            `json:"address"`      // Advertiser MAC address
+	RSSI        int16             `json:"rssi"`         // Signal strength at the proxy
+	ServiceData map[string]string `json:"service_data"` // Hex payloads keyed by service UUID
+}
+
+// SensorConfig represents configuration for a single sensor
+type SensorConfig struct {
+	Name       string
+	ID         int
+	MACAddress string
+}
+
+// sensorInfo contains metadata about a sensor
+type sensorInfo struct {
+	Name string
+	ID   int
+}
+
+// Proxy decodes advertisements relayed by ESP32 BLE proxies into buffered readings
+type Proxy struct {
+	baseTopic  string
+	sensorMACs map[string]sensorInfo // Map of MAC address to sensor info
+	buffer     *buffer.RingBuffer
+	logger     *zap.Logger
+	eventLog   *events.Log
+	locator    *locator.Locator
+
+	mu         sync.Mutex
+	lastFrames map[string]int // Last frame counter per MAC, drops copies heard by several proxies
+}
+
+// New creates a proxy ingester for sensors whose advertisements are published under baseTopic
+func New(sensors []SensorConfig, baseTopic string, buf *buffer.RingBuffer, logger *zap.Logger) *Proxy {
+	macMap := make(map[string]sensorInfo)
+	for _, sensor := range sensors {
+		macMap[normalizeMAC(sensor.MACAddress)] = sensorInfo{
+			Name: sensor.Name,
+			ID:   sensor.ID,
+		}
+	}
+
+	return &Proxy{
+		baseTopic:  strings.TrimSuffix(baseTopic, "/"),
+		sensorMACs: macMap,
+		buffer:     buf,
+		logger:     logger,
+		lastFrames: make(map[string]int),
+	}
+}
+
+// SetEventLog sets the event log used to record the first reading of each sensor
+func (p *Proxy) SetEventLog(eventLog *events.Log) {
+	p.eventLog = eventLog
+}
+
+// SetLocator sets the locator fed with the signal strength of beacons and of sensor frames
+// already delivered by another proxy
+func (p *Proxy) SetLocator(l *locator.Locator) {
+	p.locator = l
+}
+
+// Topics returns the MQTT topic filters the proxy needs
+// Each ESP32 publishes to <baseTopic>/<proxy name>
+func (p *Proxy) Topics() []string {
+	return []string{p.baseTopic + "/#"}
+}
+
+// HandleMessage processes a message from the broker
+// The payload is a single advertisement object or an array of them
+func (p *Proxy) HandleMessage(topic string, payload []byte) {
+	if !strings.HasPrefix(topic, p.baseTopic+"/") {
+		return
+	}
+	source := strings.TrimPrefix(topic, p.baseTopic+"/")
+
+	advertisements, err := parseAdvertisements(payload)
+	if err != nil {
+		p.logger.Warn("failed to parse BLE proxy message",
+			zap.String("topic", topic),
+			zap.Error(err),
+		)
+		return
+	}
+	p.Ingest(source, advertisements)
+}
+
+// Ingest decodes advertisements from the named proxy and returns how many readings were added
+func (p *Proxy) Ingest(source string, advertisements []Advertisement) int {
+	added := 0
+	for _, advertisement := range advertisements {
+		if p.ingest(source, advertisement) {
+			added++
+		}
+	}
+	return added
+}
+
+// ingest decodes a single advertisement and adds it to the buffer
+func (p *Proxy) ingest(source string, advertisement Advertisement) bool {
+	mac := normalizeMAC(advertisement.Address)
+	if p.locator.IsBeacon(mac) {
+		p.locator.Heard(mac, source, advertisement.RSSI, time.Now())
+		return false
+	}
+	info, found := p.sensorMACs[mac]
+	if !found {
+		return false
+	}
+
+	reading, format, err := decodeServiceData(advertisement.ServiceData, advertisement.RSSI)
+	if errors.Is(err, bledecoder.ErrUnknownFormat) {
+		return false
+	}
+	if err != nil {
+		p.logger.Warn("failed to decode advertisement",
+			zap.String("mac", mac),
+			zap.String("proxy", source),
+			zap.String("format", string(format)),
+			zap.Error(err),
+		)
+		return false
+	}
+
+	p.mu.Lock()
+	lastFrame, seen := p.lastFrames[mac]
+	duplicate := seen && lastFrame == reading.FrameCounter
+	p.lastFrames[mac] = reading.FrameCounter
+	p.mu.Unlock()
+	if duplicate {
+		p.locator.Heard(mac, source, reading.RSSI, reading.Timestamp)
+		return false
+	}
+
+	p.buffer.Add(&buffer.Reading{
+		Type: buffer.ReadingTypeBLE,
+		BLE: &buffer.SensorReading{
+			Timestamp:          reading.Timestamp,
+			MAC:                reading.MAC,
+			SensorName:         info.Name,
+			SensorID:           info.ID,
+			TemperatureCelsius: reading.TemperatureCelsius,
+			HumidityPercent:    reading.HumidityPercent,
+			BatteryPercent:     reading.BatteryPercent,
+			BatteryVoltageMV:   reading.BatteryVoltageMV,
+			FrameCounter:       reading.FrameCounter,
+			RSSI:               reading.RSSI,
+			Receiver:           source,
+		},
+	})
+
+	if !seen {
+		p.eventLog.Record(events.TypeSensorFirstSeen, "ble_proxy",
+			fmt.Sprintf("sensor %s first seen via proxy %s", info.Name, source),
+			map[string]string{
+				"mac":       mac,
+				"sensor_id": fmt.Sprintf("%d", info.ID),
+				"proxy":     source,
+			},
+		)
+	}
+
+	p.logger.Info("Read sensor data via BLE proxy",
+		zap.String("sensor_name", info.Name),
+		zap.Int("sensor_id", info.ID),
+		zap.String("mac", reading.MAC),
+		zap.String("proxy", source),
+		zap.Float64("temperature_celsius", reading.TemperatureCelsius),
+		zap.Int("humidity_percent", reading.HumidityPercent),
+		zap.Int("battery_percent", reading.BatteryPercent),
+		zap.Int16("rssi_dbm", reading.RSSI),
+	)
+	return true
+}
+
+// parseAdvertisements accepts either a single advertisement or an array
+func parseAdvertisements(payload []byte) ([]Advertisement, error) {
+	trimmed := strings.TrimSpace(string(payload))
+	if strings.HasPrefix(trimmed, "[") {
+		var advertisements []Advertisement
+		if err := json.Unmarshal(payload, &advertisements); err != nil {
+			return nil, fmt.Errorf("failed to decode advertisements: %w", err)
+		}
+		return advertisements, nil
+	}
+
+	var advertisement Advertisement
+	if err := json.Unmarshal(payload, &advertisement); err != nil {
+		return nil, fmt.Errorf("failed to decode advertisement: %w", err)
+	}
+	return []Advertisement{advertisement}, nil
+}
+
+// decodeServiceData decodes the first service data entry of a known sensor format,
+// returning bledecoder.ErrUnknownFormat when there is none
+func decodeServiceData(serviceData map[string]string, rssi int16) (*bledecoder.Reading, bledecoder.Format, error) {
+	for key, value := range serviceData {
+		uuid, ok := parseUUID16(key)
+		if !ok {
+			continue
+		}
+		data, err := hex.DecodeString(strings.ReplaceAll(value, ":", ""))
+		if err != nil {
+			continue
+		}
+		reading, format, err := bledecoder.Decode(uuid, data, rssi)
+		if errors.Is(err, bledecoder.ErrUnknownFormat) {
+			continue
+		}
+		return reading, format, err
+	}
+	return nil, "", bledecoder.ErrUnknownFormat
+}
+
+// parseUUID16 parses a 16-bit service UUID, accepting both the short form
+// ("181a") and the full 128-bit Bluetooth base UUID ESPHome reports
+func parseUUID16(key string) (uint16, bool) {
+	key = strings.TrimPrefix(strings.ToLower(key), "0x")
+	if short, ok := strings.CutSuffix(key, "-0000-1000-8000-00805f9b34fb"); ok {
+		if len(short) != 8 || !strings.HasPrefix(short, "0000") {
+			return 0, false
+		}
+		key = short[4:]
+	}
+	if len(key) != 4 {
+		return 0, false
+	}
+	uuid, err := strconv.ParseUint(key, 16, 16)
+	if err != nil {
+		return 0, false
+	}
+	return uint16(uuid), true
+}
+
+// normalizeMAC formats a MAC address as uppercase colon-separated octets,
+// accepting dashes or no separators as ESP32 firmwares vary
+func normalizeMAC(mac string) string {
+	digits := strings.ToUpper(strings.NewReplacer(":", "", "-", "", " ", "").Replace(mac))
+	if len(digits) != 12 {
+		return strings.ToUpper(strings.TrimSpace(mac))
+	}
+	octets := make([]string, 6)
+	for i := range octets {
+		octets[i] = digits[i*2 : i*2+2]
+	}
+	return strings.Join(octets, ":")
+}
@@ -0,0 +1,93 @@
+package bleproxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/mjasion/balena-home/thermostats/admin"
+	"github.com/mjasion/balena-home/thermostats/buffer"
+	"go.uber.org/zap"
+)
+
+// atcPayload is an ATC advertisement for A4:C1:38:12:34:56 at 22.5°C, 65%, frame 42
+const atcPayload = "a4c138123456" + "00e1" + "41" + "5f" + "b80b" + "2a"
+
+func newTestProxy(buf *buffer.RingBuffer) *Proxy {
+	sensors := []SensorConfig{{Name: "Bedroom", ID: 1, MACAddress: "a4:c1:38:12:34:56"}}
+	return New(sensors, "ble_proxy/", buf, zap.NewNop())
+}
+
+func TestHandleMessage_DecodesATC(t *testing.T) {
+	buf := buffer.New(10, zap.NewNop())
+	proxy := newTestProxy(buf)
+
+	payload := `{"address":"A4C138123456","rssi":-70,"service_data":{"0000181a-0000-1000-8000-00805f9b34fb":"` + atcPayload + `"}}`
+	proxy.HandleMessage("ble_proxy/living-room", []byte(payload))
+
+	readings := buf.GetAll()
+	if len(readings) != 1 {
+		t.Fatalf("Expected 1 reading, got %d", len(readings))
+	}
+	ble := readings[0].BLE
+	if ble == nil || ble.SensorName != "Bedroom" || ble.SensorID != 1 {
+		t.Fatalf("Expected reading for Bedroom, got %+v", readings[0])
+	}
+	if ble.TemperatureCelsius != 22.5 || ble.HumidityPercent != 65 || ble.RSSI != -70 {
+		t.Errorf("Unexpected decoded values: %+v", ble)
+	}
+}
+
+func TestHandleMessage_IgnoresUnknownAndDuplicates(t *testing.T) {
+	buf := buffer.New(10, zap.NewNop())
+	proxy := newTestProxy(buf)
+
+	advertisement := `{"address":"A4:C1:38:12:34:56","rssi":-70,"service_data":{"181a":"` + atcPayload + `"}}`
+	unknown := `{"address":"A4:C1:38:00:00:01","rssi":-70,"service_data":{"181a":"` + atcPayload + `"}}`
+	otherService := `{"address":"A4:C1:38:12:34:56","rssi":-70,"service_data":{"fcd2":"40"}}`
+
+	proxy.HandleMessage("ble_proxy/kitchen", []byte("["+advertisement+","+unknown+","+otherService+"]"))
+	// The same frame heard by a second proxy must not be counted twice
+	proxy.HandleMessage("ble_proxy/hall", []byte(advertisement))
+	proxy.HandleMessage("other/topic", []byte(advertisement))
+	proxy.HandleMessage("ble_proxy/hall", []byte("not json"))
+
+	if buf.Size() != 1 {
+		t.Errorf("Expected 1 reading, got %d", buf.Size())
+	}
+}
+
+func TestHandler_RequiresToken(t *testing.T) {
+	buf := buffer.New(10, zap.NewNop())
+	server := admin.New(":0", zap.NewNop())
+	NewHandler(newTestProxy(buf), "secret").RegisterHandlers(server)
+	ts := httptest.NewServer(server)
+	defer ts.Close()
+
+	body := `[{"address":"A4:C1:38:12:34:56","rssi":-60,"service_data":{"181A":"` + atcPayload + `"}}]`
+
+	resp, err := http.Post(ts.URL+"/api/ble/advertisements", "application/json", strings.NewReader(body))
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("Expected status %d, got %d", http.StatusUnauthorized, resp.StatusCode)
+	}
+
+	req, _ := http.NewRequest(http.MethodPost, ts.URL+"/api/ble/advertisements", strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer secret")
+	req.Header.Set("X-Proxy-Name", "garage")
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("Expected status %d, got %d", http.StatusOK, resp.StatusCode)
+	}
+	if buf.Size() != 1 {
+		t.Errorf("Expected 1 reading, got %d", buf.Size())
+	}
+}
@@ -1,8 +1,11 @@
 package buffer
 
 import (
+	"errors"
 	"sync"
+	"time"
 
+	"github.com/mjasion/balena-home/thermostats/clock"
 	"go.uber.org/zap"
 )
 
@@ -10,15 +13,35 @@ import (
 type ReadingType string
 
 const (
-	ReadingTypeBLE     ReadingType = "ble"
-	ReadingTypeNetatmo ReadingType = "netatmo"
-	ReadingTypePower   ReadingType = "power"
+	ReadingTypeBLE            ReadingType = "ble"
+	ReadingTypeNetatmo        ReadingType = "netatmo"
+	ReadingTypePower          ReadingType = "power"
+	ReadingTypeHeatPump       ReadingType = "heatpump"
+	ReadingTypeWater          ReadingType = "water"
+	ReadingTypeOneWire        ReadingType = "onewire"
+	ReadingTypeI2C            ReadingType = "i2c"
+	ReadingTypeAirQuality     ReadingType = "airquality"
+	ReadingTypeZigbee         ReadingType = "zigbee"
+	ReadingTypeDependency     ReadingType = "dependency"
+	ReadingTypeAutomation     ReadingType = "automation"
+	ReadingTypeDerived        ReadingType = "derived"
+	ReadingTypeRoom           ReadingType = "room"
+	ReadingTypeSummary        ReadingType = "summary"
+	ReadingTypeRemote         ReadingType = "remote"
+	ReadingTypeHTTP           ReadingType = "http"
+	ReadingTypeConflict       ReadingType = "conflict"
+	ReadingTypeBattery        ReadingType = "battery"
+	ReadingTypeLocation       ReadingType = "location"
+	ReadingTypeReconciliation ReadingType = "reconciliation"
+	ReadingTypePrice          ReadingType = "price"
+	ReadingTypeOccupancy      ReadingType = "occupancy"
+	ReadingTypeVentilation    ReadingType = "ventilation"
 )
 
 // SensorReading represents a single temperature sensor reading from BLE
 // This is duplicated here to avoid circular imports
 type SensorReading struct {
-	Timestamp          interface{} // time.Time
+	Timestamp          time.Time
 	MAC                string
 	SensorName         string // Friendly name from config
 	SensorID           int    // Numeric ID from config
@@ -28,11 +51,12 @@ type SensorReading struct {
 	BatteryVoltageMV   int
 	FrameCounter       int
 	RSSI               int16
+	Receiver           string // Scanner that heard the advertisement: this device, a satellite or a BLE proxy
 }
 
 // ThermostatReading represents a thermostat reading from Netatmo
 type ThermostatReading struct {
-	Timestamp           interface{} // time.Time
+	Timestamp           time.Time
 	HomeID              string
 	HomeName            string
 	RoomID              string
@@ -43,31 +67,284 @@ type ThermostatReading struct {
 	HeatingPowerRequest int
 	OpenWindow          bool
 	Reachable           bool
+	ErrorCode           int  // Netatmo error code of one of the room's modules, 0 when none
+	Stale               bool // Last known values repeated while the room's modules report an error
+	NoValues            bool // Only the error is known, the values are zero
+	Historical          bool // Backfilled from the measure history; only the temperatures are set
 }
 
 // PowerReading represents an active power measurement from energy meter
 type PowerReading struct {
-	Timestamp interface{} // time.Time
+	Timestamp time.Time
 	SensorID  int
 	Value     float64
+	Stale     bool // Last known value repeated after a failed scrape
 }
 
-// Reading is a union type that can hold BLE sensor, Netatmo thermostat, or power readings
+// HeatPumpReading represents a single value read from a heat pump adapter
+type HeatPumpReading struct {
+	Timestamp time.Time
+	Name      string // Metric name suffix from config
+	Value     float64
+}
+
+// WaterReading represents the cumulative consumption counted from water meter pulses
+type WaterReading struct {
+	Timestamp   time.Time
+	TotalLiters float64
+}
+
+// OneWireReading represents a temperature reading from a wired DS18B20 sensor
+type OneWireReading struct {
+	Timestamp          time.Time
+	DeviceID           string // 1-Wire device ID, e.g. 28-0316a2796bff
+	SensorName         string // Friendly name from config
+	SensorID           int    // Numeric ID from config
+	TemperatureCelsius float64
+}
+
+// I2CReading represents an environmental reading from an I2C sensor (BME280, SHT31)
+type I2CReading struct {
+	Timestamp          time.Time
+	SensorName         string // Friendly name from config
+	SensorID           int    // Numeric ID from config
+	Model              string // Sensor model, e.g. bme280
+	TemperatureCelsius float64
+	HumidityPercent    float64
+	PressureHPa        float64
+	HasPressure        bool // Only BME280 measures pressure
+}
+
+// AirQualityReading represents a reading from a CO2 sensor (MH-Z19, SCD4x)
+type AirQualityReading struct {
+	Timestamp          time.Time
+	SensorName         string // Friendly name from config
+	SensorID           int    // Numeric ID from config
+	Model              string // Sensor model, e.g. scd4x
+	CO2PPM             float64
+	TemperatureCelsius float64
+	HumidityPercent    float64
+	HasClimate         bool // Only SCD4x measures temperature and humidity
+}
+
+// ZigbeeReading represents a single metric value reported by a Zigbee2MQTT device
+type ZigbeeReading struct {
+	Timestamp   time.Time
+	Device      string // Zigbee2MQTT friendly name
+	IEEEAddress string
+	Model       string
+	Vendor      string
+	Class       string // Device class from the model profile, e.g. climate
+	Metric      string // Metric name without prefix, e.g. temperature_celsius
+	Value       float64
+}
+
+// HistogramBucket is a cumulative histogram bucket
+type HistogramBucket struct {
+	UpperBound float64
+	Count      uint64
+}
+
+// DependencyReading represents cumulative request statistics for an outbound HTTP dependency
+type DependencyReading struct {
+	Timestamp          time.Time
+	Dependency         string // e.g. prometheus, netatmo
+	Requests           uint64
+	Errors             uint64 // Transport errors, 5xx and 429 responses
+	SentBytes          uint64 // Request body bytes, excluding headers and TLS overhead
+	ReceivedBytes      uint64 // Response body bytes read, excluding headers and TLS overhead
+	DurationSumSeconds float64
+	DurationBuckets    []HistogramBucket // Excluding +Inf, which equals Requests
+
+	// Connection statistics, zero unless the dependency's connections are traced
+	ConnectionsOpened      uint64
+	ConnectionsReused      uint64
+	ConnectSecondsSum      float64 // Setting up the opened connections: DNS, TCP and TLS
+	TLSHandshakes          uint64
+	TLSHandshakeSecondsSum float64
+	FirstBytes             uint64 // Responses whose first byte arrived
+	FirstByteSecondsSum    float64
+}
+
+// AutomationReading represents the state of an automation rule after it acted
+type AutomationReading struct {
+	Timestamp time.Time
+	Rule      string // Rule name from config
+	Active    bool   // Whether the rule's action is in effect, e.g. load shed
+}
+
+// DerivedReading represents a metric computed by an automation expression rule
+type DerivedReading struct {
+	Timestamp time.Time
+	Name      string // Metric name from config
+	Rule      string // Rule that computed the value
+	Value     float64
+}
+
+// RoomReading represents a room temperature fused from a primary and a fallback source
+type RoomReading struct {
+	Timestamp          time.Time
+	Room               string // Room name from config
+	Source             string // Source that provided the value
+	TemperatureCelsius float64
+}
+
+// SummaryReading represents the hourly aggregate of a BLE sensor metric
+type SummaryReading struct {
+	Timestamp  time.Time // End of the hour
+	Metric     string    // Summarized metric, e.g. ble_temperature_celsius
+	MAC        string
+	SensorName string
+	SensorID   int
+	Min        float64
+	Max        float64
+	Avg        float64
+	Count      int
+}
+
+// RemoteReading represents a sample received over remote_write from another device
+type RemoteReading struct {
+	Timestamp time.Time
+	Metric    string            // __name__ label
+	Labels    map[string]string // Remaining labels as received
+	Value     float64
+}
+
+// HTTPReading represents cumulative request statistics for a route of an embedded HTTP server
+type HTTPReading struct {
+	Timestamp          time.Time
+	Route              string // Matched pattern, e.g. "GET /api/buffer", or "unmatched"
+	Code               int    // Response status code
+	Requests           uint64
+	DurationSumSeconds float64
+	DurationBuckets    []HistogramBucket // Excluding +Inf, which equals Requests
+}
+
+// ConflictReading represents a sensor label claimed by more than one sensor identity
+type ConflictReading struct {
+	Timestamp  time.Time
+	Kind       string // Conflicting label: sensor_id, sensor_name or mac
+	Key        string // Value of the conflicting label
+	Identities int    // Number of distinct identities claiming it
+}
+
+// BatteryReading represents the projected battery life of a BLE sensor
+type BatteryReading struct {
+	Timestamp     time.Time
+	MAC           string
+	SensorName    string
+	SensorID      int
+	DaysRemaining float64
+}
+
+// LocationReading represents the receiver nearest to a BLE sensor or beacon
+type LocationReading struct {
+	Timestamp time.Time
+	MAC       string
+	Name      string  // Sensor or beacon name from config
+	Receiver  string  // Receiver with the strongest smoothed signal
+	Room      string  // Room of the receiver, empty when not configured
+	RSSI      float64 // Smoothed signal strength at the receiver in dBm
+}
+
+// ReconciliationReading compares locally integrated energy with the official smart meter
+// consumption over the same complete hours
+type ReconciliationReading struct {
+	Timestamp    time.Time
+	Source       string  // Official data source, e.g. "pstryk"
+	Hours        int     // Complete hours compared
+	OfficialKWh  float64 // Consumption reported by the utility meter
+	LocalKWh     float64 // Consumption integrated from active power readings
+	DriftPercent float64 // (local - official) / official * 100
+}
+
+// PriceReading represents the day-ahead electricity price of an hour, relative to the current one
+type PriceReading struct {
+	Timestamp  time.Time
+	Source     string // Price source, "pse" or "entsoe"
+	HoursAhead int    // 0 for the current hour
+	PLNPerKWh  float64
+}
+
+// OccupancyReading represents whether someone is home
+type OccupancyReading struct {
+	Timestamp time.Time
+	Home      bool
+	Source    string // "schedule" or "presence"
+}
+
+// VentilationReading represents whether ventilating would lower the indoor humidity
+type VentilationReading struct {
+	Timestamp   time.Time
+	Recommended bool
+}
+
+// Reading is a union type that can hold BLE sensor, Netatmo thermostat, power, heat pump, water meter, 1-Wire, I2C, air quality, Zigbee, dependency, automation, derived, room, summary, remote, HTTP, conflict, battery, location, reconciliation, price, occupancy, or ventilation readings
 type Reading struct {
-	Type       ReadingType
-	BLE        *SensorReading
-	Thermostat *ThermostatReading
-	Power      *PowerReading
+	Type           ReadingType
+	BLE            *SensorReading
+	Thermostat     *ThermostatReading
+	Power          *PowerReading
+	HeatPump       *HeatPumpReading
+	Water          *WaterReading
+	OneWire        *OneWireReading
+	I2C            *I2CReading
+	AirQuality     *AirQualityReading
+	Zigbee         *ZigbeeReading
+	Dependency     *DependencyReading
+	Automation     *AutomationReading
+	Derived        *DerivedReading
+	Room           *RoomReading
+	Summary        *SummaryReading
+	Remote         *RemoteReading
+	HTTP           *HTTPReading
+	Conflict       *ConflictReading
+	Battery        *BatteryReading
+	Location       *LocationReading
+	Reconciliation *ReconciliationReading
+	Price          *PriceReading
+	Occupancy      *OccupancyReading
+	Ventilation    *VentilationReading
 }
 
+// Timestamp returns the timestamp of the populated field, or the zero time
+func (r *Reading) Timestamp() time.Time {
+	return variantOf(r).timestamp
+}
+
+// Payload returns the populated field, e.g. the *SensorReading of a BLE reading, or nil
+func (r *Reading) Payload() interface{} {
+	return variantOf(r).payload
+}
+
+// ErrClosed is returned by Add once the buffer is closed; producers treat it as a stop signal
+var ErrClosed = errors.New("buffer closed")
+
+// overwriteReportInterval is the minimum time between warnings about overwritten readings
+const overwriteReportInterval = time.Minute
+
 // RingBuffer is a thread-safe circular buffer for sensor readings
 type RingBuffer struct {
-	data     []*Reading
-	capacity int
-	size     int
-	head     int
-	mu       sync.RWMutex
-	logger   *zap.Logger
+	data       []*Reading
+	capacity   int
+	size       int
+	head       int
+	mu         sync.RWMutex
+	logger     *zap.Logger
+	listeners  []func(reading *Reading)
+	now        func() time.Time
+	closed     bool // Set by Close before the final drain
+	watermarks []watermark
+
+	overwritten int64               // Readings overwritten since start
+	unreported  map[ReadingType]int // Overwritten readings by type since the last warning
+	lastReport  time.Time           // Time of the last warning
+}
+
+// watermark signals its channel when an Add fills the buffer to threshold readings
+type watermark struct {
+	threshold int
+	ch        chan struct{}
 }
 
 // New creates a new ring buffer with the specified capacity
@@ -78,22 +355,49 @@ func New(capacity int, logger *zap.Logger) *RingBuffer {
 		size:     0,
 		head:     0,
 		logger:   logger,
+		now:      time.Now,
 	}
 }
 
+// SetClock sets the clock used to pace overwrite warnings; must be called before readings are added
+func (rb *RingBuffer) SetClock(c clock.Clock) {
+	rb.now = c.Now
+}
+
+// AddListener registers a function called with every reading passed to Add
+// Listeners run synchronously after the reading is stored and may call Add themselves;
+// readings re-added with AddMultiple are not passed to listeners
+// Must be called before readings are added
+func (rb *RingBuffer) AddListener(listener func(reading *Reading)) {
+	rb.listeners = append(rb.listeners, listener)
+}
+
 // Add adds a new reading to the buffer
 // If the buffer is full, it overwrites the oldest entry
-func (rb *RingBuffer) Add(reading *Reading) {
+// Returns ErrClosed without storing the reading once the buffer is closed
+func (rb *RingBuffer) Add(reading *Reading) error {
+	if err := rb.add(reading); err != nil {
+		return err
+	}
+
+	for _, listener := range rb.listeners {
+		listener(reading)
+	}
+	return nil
+}
+
+// add stores a reading under the lock
+func (rb *RingBuffer) add(reading *Reading) error {
 	rb.mu.Lock()
 	defer rb.mu.Unlock()
 
+	if rb.closed {
+		return ErrClosed
+	}
+
 	// Check if we're about to overwrite data
 	if rb.size == rb.capacity {
-		overwrittenType := rb.data[rb.head].Type
-		rb.logger.Warn("ring buffer full, overwriting oldest data",
-			zap.Int("capacity", rb.capacity),
-			zap.String("overwritten_type", string(overwrittenType)),
-		)
+		rb.overwrite(rb.data[rb.head].Type)
 	}
 
 	// Add the reading
@@ -103,7 +407,47 @@ func (rb *RingBuffer) Add(reading *Reading) {
 	// Update size
 	if rb.size < rb.capacity {
 		rb.size++
+		for _, w := range rb.watermarks {
+			if rb.size == w.threshold {
+				select {
+				case w.ch <- struct{}{}:
+				default:
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// Watermark returns a channel signalled each time an Add fills the buffer to percent of its
+// capacity, so an output can push early instead of waiting for its next tick
+// Only crossing the watermark signals: readings re-added with AddMultiple after a failed push
+// don't, so an unreachable endpoint isn't retried on every reading
+func (rb *RingBuffer) Watermark(percent int) <-chan struct{} {
+	w := watermark{
+		threshold: max(1, rb.capacity*percent/100),
+		ch:        make(chan struct{}, 1),
 	}
+	rb.mu.Lock()
+	defer rb.mu.Unlock()
+	rb.watermarks = append(rb.watermarks, w)
+	return w.ch
+}
+
+// Close makes subsequent Adds fail with ErrClosed, so readings can't arrive after the final
+// drain on shutdown and be lost; buffered readings stay readable
+// AddMultiple keeps working, so outputs can still re-add readings of a failed final push
+func (rb *RingBuffer) Close() {
+	rb.mu.Lock()
+	defer rb.mu.Unlock()
+	rb.closed = true
+}
+
+// Closed reports whether Close was called
+func (rb *RingBuffer) Closed() bool {
+	rb.mu.RLock()
+	defer rb.mu.RUnlock()
+	return rb.closed
 }
 
 // GetAll returns all buffered readings
@@ -111,7 +455,32 @@ func (rb *RingBuffer) Add(reading *Reading) {
 func (rb *RingBuffer) GetAll() []*Reading {
 	rb.mu.RLock()
 	defer rb.mu.RUnlock()
+	return rb.readings()
+}
+
+// Snapshot is the state of the buffer at one moment, for health and debug endpoints
+type Snapshot struct {
+	Readings    []*Reading // Oldest first
+	Capacity    int
+	Overwritten int64
+	Closed      bool
+}
 
+// Snapshot returns the buffered readings and counters taken under one lock, without clearing
+// the buffer, so inspecting it doesn't consume what the next push sends
+func (rb *RingBuffer) Snapshot() Snapshot {
+	rb.mu.RLock()
+	defer rb.mu.RUnlock()
+	return Snapshot{
+		Readings:    rb.readings(),
+		Capacity:    rb.capacity,
+		Overwritten: rb.overwritten,
+		Closed:      rb.closed,
+	}
+}
+
+// readings returns a copy of the buffered readings, oldest first; the caller holds the lock
+func (rb *RingBuffer) readings() []*Reading {
 	if rb.size == 0 {
 		return nil
 	}
@@ -137,29 +506,70 @@ func (rb *RingBuffer) GetAllAndClear() []*Reading {
 	rb.mu.Lock()
 	defer rb.mu.Unlock()
 
-	if rb.size == 0 {
+	result := rb.readings()
+	if result == nil {
 		return nil
 	}
 
-	result := make([]*Reading, rb.size)
-
-	if rb.size < rb.capacity {
-		// Buffer is not full yet, readings are from 0 to head-1
-		copy(result, rb.data[:rb.size])
-	} else {
-		// Buffer is full, readings are from head to end, then 0 to head-1
-		n := copy(result, rb.data[rb.head:])
-		copy(result[n:], rb.data[:rb.head])
-	}
-
 	// Clear the buffer atomically
 	rb.size = 0
 	rb.head = 0
 	rb.data = make([]*Reading, rb.capacity)
 
+	// The buffer drained, so report the overwrites of the end of the outage now
+	rb.reportOverwrites()
+
 	return result
 }
 
+// overwrite counts a reading of the given type about to be overwritten, warning at most once per
+// overwriteReportInterval, since a full buffer during an outage overwrites on every scrape
+// Must be called with the lock held
+func (rb *RingBuffer) overwrite(readingType ReadingType) {
+	rb.overwritten++
+	if rb.unreported == nil {
+		rb.unreported = make(map[ReadingType]int)
+	}
+	rb.unreported[readingType]++
+	if rb.now().Sub(rb.lastReport) >= overwriteReportInterval {
+		rb.reportOverwrites()
+	}
+}
+
+// reportOverwrites warns about the readings overwritten since the last warning, if any
+// Must be called with the lock held
+func (rb *RingBuffer) reportOverwrites() {
+	if len(rb.unreported) == 0 {
+		return
+	}
+	count := 0
+	byType := make(map[string]int, len(rb.unreported))
+	for readingType, n := range rb.unreported {
+		count += n
+		byType[string(readingType)] = n
+	}
+	since := "start"
+	if !rb.lastReport.IsZero() {
+		since = rb.lastReport.Format(time.RFC3339)
+	}
+	rb.logger.Warn("ring buffer full, overwrote oldest readings",
+		zap.Int("capacity", rb.capacity),
+		zap.Int("overwritten_count", count),
+		zap.Any("overwritten_by_type", byType),
+		zap.Int64("overwritten_total", rb.overwritten),
+		zap.String("since", since),
+	)
+	rb.unreported = nil
+	rb.lastReport = rb.now()
+}
+
+// Overwritten returns the number of readings overwritten since start because the buffer was full
+func (rb *RingBuffer) Overwritten() int64 {
+	rb.mu.RLock()
+	defer rb.mu.RUnlock()
+	return rb.overwritten
+}
+
 // Size returns the current number of readings in the buffer
 func (rb *RingBuffer) Size() int {
 	rb.mu.RLock()
@@ -167,6 +577,11 @@ func (rb *RingBuffer) Size() int {
 	return rb.size
 }
 
+// Capacity returns the maximum number of readings the buffer holds
+func (rb *RingBuffer) Capacity() int {
+	return rb.capacity
+}
+
 // AddMultiple adds multiple readings to the buffer at once
 // Useful for re-adding readings after a failed push attempt
 func (rb *RingBuffer) AddMultiple(readings []*Reading) {
@@ -180,10 +595,7 @@ func (rb *RingBuffer) AddMultiple(readings []*Reading) {
 	for _, reading := range readings {
 		// Check if we're about to overwrite data
 		if rb.size == rb.capacity {
-			rb.logger.Warn("ring buffer full during AddMultiple, overwriting oldest data",
-				zap.Int("capacity", rb.capacity),
-				zap.String("overwritten_type", string(rb.data[rb.head].Type)),
-			)
+			rb.overwrite(rb.data[rb.head].Type)
 		}
 
 		// Add the reading
@@ -3,8 +3,10 @@ package buffer
 import (
 	"sync"
 	"testing"
+	"time"
 
 	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
 )
 
 func TestRingBuffer_AddAndGet(t *testing.T) {
@@ -79,6 +81,137 @@ func TestRingBuffer_Overflow(t *testing.T) {
 	}
 }
 
+func TestRingBuffer_OverwriteWarningRateLimited(t *testing.T) {
+	core, logs := observer.New(zap.WarnLevel)
+	rb := New(2, zap.New(core))
+	now := time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC)
+	rb.now = func() time.Time { return now }
+
+	add := func(n int) {
+		for i := 0; i < n; i++ {
+			rb.Add(&Reading{Type: ReadingTypePower, Power: &PowerReading{Value: float64(i)}})
+		}
+	}
+
+	// The first overwrite warns at once, the following ones within a minute are only counted
+	add(10)
+	if logs.Len() != 1 {
+		t.Fatalf("expected 1 warning, got %d", logs.Len())
+	}
+
+	// The next overwrite after a minute reports everything since the last warning
+	now = now.Add(time.Minute)
+	add(1)
+	if logs.Len() != 2 {
+		t.Fatalf("expected 2 warnings, got %d", logs.Len())
+	}
+	fields := logs.All()[1].ContextMap()
+	if fields["overwritten_count"] != int64(8) || fields["overwritten_total"] != int64(9) {
+		t.Errorf("expected 8 overwrites since the last warning and 9 in total, got %v", fields)
+	}
+
+	// Draining the buffer reports the overwrites not yet reported
+	now = now.Add(time.Second)
+	add(2)
+	rb.GetAllAndClear()
+	if logs.Len() != 3 || logs.All()[2].ContextMap()["overwritten_count"] != int64(2) {
+		t.Errorf("expected a warning for the last 2 overwrites on drain, got %d warnings", logs.Len())
+	}
+	if rb.Overwritten() != 11 {
+		t.Errorf("expected 11 overwritten readings, got %d", rb.Overwritten())
+	}
+}
+
+func TestRingBuffer_Close(t *testing.T) {
+	rb := New(5, zap.NewNop())
+	listened := 0
+	rb.AddListener(func(reading *Reading) { listened++ })
+
+	if err := rb.Add(&Reading{Type: ReadingTypeWater, Water: &WaterReading{TotalLiters: 1}}); err != nil {
+		t.Fatalf("expected no error before Close, got %v", err)
+	}
+	rb.Close()
+	if !rb.Closed() {
+		t.Error("expected the buffer to report being closed")
+	}
+	if err := rb.Add(&Reading{Type: ReadingTypeWater, Water: &WaterReading{TotalLiters: 2}}); err != ErrClosed {
+		t.Errorf("expected ErrClosed after Close, got %v", err)
+	}
+	if rb.Size() != 1 || listened != 1 {
+		t.Errorf("expected only the reading added before Close to be stored and listened to, got %d and %d", rb.Size(), listened)
+	}
+
+	// Outputs can still re-add readings of a failed final push
+	rb.AddMultiple(rb.GetAllAndClear())
+	if rb.Size() != 1 {
+		t.Errorf("expected AddMultiple to work after Close, got size %d", rb.Size())
+	}
+}
+
+func TestRingBuffer_Watermark(t *testing.T) {
+	rb := New(10, zap.NewNop())
+	watermark := rb.Watermark(50)
+	signalled := func() bool {
+		select {
+		case <-watermark:
+			return true
+		default:
+			return false
+		}
+	}
+	add := func(n int) {
+		for i := 0; i < n; i++ {
+			rb.Add(&Reading{Type: ReadingTypeWater, Water: &WaterReading{TotalLiters: float64(i)}})
+		}
+	}
+
+	add(4)
+	if signalled() {
+		t.Error("expected no signal below the watermark")
+	}
+	add(1)
+	if !signalled() {
+		t.Error("expected a signal at 50% full")
+	}
+	add(3)
+	if signalled() {
+		t.Error("expected a single signal per crossing")
+	}
+
+	// Readings re-added after a failed push don't signal, nor do Adds above the watermark
+	rb.AddMultiple(rb.GetAllAndClear())
+	add(1)
+	if signalled() {
+		t.Error("expected no signal after re-adding readings above the watermark")
+	}
+
+	// After a push empties the buffer the next crossing signals again
+	rb.GetAllAndClear()
+	add(5)
+	if !signalled() {
+		t.Error("expected a signal on the next crossing")
+	}
+}
+
+func TestRingBuffer_Snapshot(t *testing.T) {
+	rb := New(2, zap.NewNop())
+	for i := 1; i <= 3; i++ {
+		rb.Add(&Reading{Type: ReadingTypeWater, Water: &WaterReading{TotalLiters: float64(i)}})
+	}
+	rb.Close()
+
+	snapshot := rb.Snapshot()
+	if len(snapshot.Readings) != 2 || snapshot.Readings[0].Water.TotalLiters != 2 || snapshot.Readings[1].Water.TotalLiters != 3 {
+		t.Fatalf("expected the 2 newest readings oldest first, got %d readings", len(snapshot.Readings))
+	}
+	if snapshot.Capacity != 2 || snapshot.Overwritten != 1 || !snapshot.Closed {
+		t.Errorf("expected capacity 2, 1 overwritten and closed, got %+v", snapshot)
+	}
+	if rb.Size() != 2 {
+		t.Errorf("expected Snapshot not to clear the buffer, got size %d", rb.Size())
+	}
+}
+
 func TestRingBuffer_GetAllAndClear(t *testing.T) {
 	logger, _ := zap.NewDevelopment()
 	rb := New(5, logger)
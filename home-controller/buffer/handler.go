@@ -0,0 +1,148 @@
+package buffer
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/mjasion/balena-home/thermostats/admin"
+)
+
+// defaultInspectLimit is the number of readings returned by GET /api/buffer without a limit
+const defaultInspectLimit = 100
+
+// bufferedReading is a reading as returned by GET /api/buffer
+type bufferedReading struct {
+	Type      ReadingType `json:"type"`
+	Timestamp *time.Time  `json:"timestamp,omitempty"`
+	Reading   interface{} `json:"reading"`
+}
+
+// inspection is the body of GET /api/buffer
+type inspection struct {
+	Size        int                 `json:"size"`
+	Capacity    int                 `json:"capacity"`
+	Overwritten int64               `json:"overwritten"` // Oldest readings dropped since start
+	Closed      bool                `json:"closed"`      // Shutting down, no longer accepting readings
+	Counts      map[ReadingType]int `json:"counts"`
+	Oldest      *time.Time          `json:"oldest,omitempty"`
+	Newest      *time.Time          `json:"newest,omitempty"`
+	Readings    []bufferedReading   `json:"readings"` // Newest first
+}
+
+// RegisterHandlers registers the buffer inspection endpoint on the admin server
+func (rb *RingBuffer) RegisterHandlers(server *admin.Server) {
+	server.HandleFunc("GET /api/buffer", rb.handleInspect)
+}
+
+// handleInspect handles GET /api/buffer?limit=<n>, showing what the next push will send
+func (rb *RingBuffer) handleInspect(w http.ResponseWriter, r *http.Request) {
+	limit := defaultInspectLimit
+	if value := r.URL.Query().Get("limit"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 0 {
+			admin.WriteError(w, http.StatusBadRequest, "limit must be a non-negative integer")
+			return
+		}
+		limit = n
+	}
+
+	snapshot := rb.Snapshot()
+	readings := snapshot.Readings
+	result := inspection{
+		Size:        len(readings),
+		Capacity:    snapshot.Capacity,
+		Overwritten: snapshot.Overwritten,
+		Closed:      snapshot.Closed,
+		Counts:      make(map[ReadingType]int),
+		Readings:    []bufferedReading{},
+	}
+	for _, reading := range readings {
+		result.Counts[reading.Type]++
+		timestamp := variantOf(reading).timestamp
+		if timestamp.IsZero() {
+			continue
+		}
+		if result.Oldest == nil || timestamp.Before(*result.Oldest) {
+			result.Oldest = &timestamp
+		}
+		if result.Newest == nil || timestamp.After(*result.Newest) {
+			result.Newest = &timestamp
+		}
+	}
+	for i := len(readings) - 1; i >= 0 && len(result.Readings) < limit; i-- {
+		v := variantOf(readings[i])
+		buffered := bufferedReading{
+			Type:    readings[i].Type,
+			Reading: v.payload,
+		}
+		if !v.timestamp.IsZero() {
+			buffered.Timestamp = &v.timestamp
+		}
+		result.Readings = append(result.Readings, buffered)
+	}
+
+	admin.WriteJSON(w, http.StatusOK, admin.Response{
+		Success: true,
+		Data:    result,
+	})
+}
+
+// variant is the populated field of a reading
+type variant struct {
+	payload   interface{}
+	timestamp time.Time
+}
+
+// variantOf returns the populated field of a reading and its timestamp
+func variantOf(reading *Reading) variant {
+	switch {
+	case reading.BLE != nil:
+		return variant{reading.BLE, reading.BLE.Timestamp}
+	case reading.Thermostat != nil:
+		return variant{reading.Thermostat, reading.Thermostat.Timestamp}
+	case reading.Power != nil:
+		return variant{reading.Power, reading.Power.Timestamp}
+	case reading.HeatPump != nil:
+		return variant{reading.HeatPump, reading.HeatPump.Timestamp}
+	case reading.Water != nil:
+		return variant{reading.Water, reading.Water.Timestamp}
+	case reading.OneWire != nil:
+		return variant{reading.OneWire, reading.OneWire.Timestamp}
+	case reading.I2C != nil:
+		return variant{reading.I2C, reading.I2C.Timestamp}
+	case reading.AirQuality != nil:
+		return variant{reading.AirQuality, reading.AirQuality.Timestamp}
+	case reading.Zigbee != nil:
+		return variant{reading.Zigbee, reading.Zigbee.Timestamp}
+	case reading.Dependency != nil:
+		return variant{reading.Dependency, reading.Dependency.Timestamp}
+	case reading.Automation != nil:
+		return variant{reading.Automation, reading.Automation.Timestamp}
+	case reading.Derived != nil:
+		return variant{reading.Derived, reading.Derived.Timestamp}
+	case reading.Room != nil:
+		return variant{reading.Room, reading.Room.Timestamp}
+	case reading.Summary != nil:
+		return variant{reading.Summary, reading.Summary.Timestamp}
+	case reading.Remote != nil:
+		return variant{reading.Remote, reading.Remote.Timestamp}
+	case reading.HTTP != nil:
+		return variant{reading.HTTP, reading.HTTP.Timestamp}
+	case reading.Conflict != nil:
+		return variant{reading.Conflict, reading.Conflict.Timestamp}
+	case reading.Battery != nil:
+		return variant{reading.Battery, reading.Battery.Timestamp}
+	case reading.Location != nil:
+		return variant{reading.Location, reading.Location.Timestamp}
+	case reading.Reconciliation != nil:
+		return variant{reading.Reconciliation, reading.Reconciliation.Timestamp}
+	case reading.Price != nil:
+		return variant{reading.Price, reading.Price.Timestamp}
+	case reading.Occupancy != nil:
+		return variant{reading.Occupancy, reading.Occupancy.Timestamp}
+	case reading.Ventilation != nil:
+		return variant{reading.Ventilation, reading.Ventilation.Timestamp}
+	}
+	return variant{}
+}
@@ -0,0 +1,79 @@
+package buffer
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/mjasion/balena-home/thermostats/admin"
+	"go.uber.org/zap"
+)
+
+func TestHandleInspect(t *testing.T) {
+	rb := New(10, zap.NewNop())
+	start := time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)
+	for i := 0; i < 3; i++ {
+		rb.Add(&Reading{
+			Type: ReadingTypeBLE,
+			BLE:  &SensorReading{Timestamp: start.Add(time.Duration(i) * time.Second), MAC: "A4:C1:38:00:00:01", TemperatureCelsius: float64(20 + i)},
+		})
+	}
+	rb.Add(&Reading{
+		Type:  ReadingTypePower,
+		Power: &PowerReading{Timestamp: start.Add(time.Minute), SensorID: 1, Value: 350},
+	})
+
+	server := admin.New(":0", zap.NewNop())
+	rb.RegisterHandlers(server)
+
+	recorder := httptest.NewRecorder()
+	server.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/api/buffer?limit=2", nil))
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", recorder.Code)
+	}
+
+	var response struct {
+		Data struct {
+			Size     int            `json:"size"`
+			Capacity int            `json:"capacity"`
+			Counts   map[string]int `json:"counts"`
+			Oldest   time.Time      `json:"oldest"`
+			Newest   time.Time      `json:"newest"`
+			Readings []struct {
+				Type    string                 `json:"type"`
+				Reading map[string]interface{} `json:"reading"`
+			} `json:"readings"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(recorder.Body).Decode(&response); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+
+	data := response.Data
+	if data.Size != 4 || data.Capacity != 10 {
+		t.Errorf("Expected size 4 of 10, got %d of %d", data.Size, data.Capacity)
+	}
+	if data.Counts["ble"] != 3 || data.Counts["power"] != 1 {
+		t.Errorf("Expected 3 ble and 1 power reading, got %v", data.Counts)
+	}
+	if !data.Oldest.Equal(start) || !data.Newest.Equal(start.Add(time.Minute)) {
+		t.Errorf("Expected range %v to %v, got %v to %v", start, start.Add(time.Minute), data.Oldest, data.Newest)
+	}
+	if len(data.Readings) != 2 {
+		t.Fatalf("Expected 2 readings, got %d", len(data.Readings))
+	}
+	if data.Readings[0].Type != "power" || data.Readings[1].Reading["TemperatureCelsius"] != 22.0 {
+		t.Errorf("Expected newest readings first, got %+v", data.Readings)
+	}
+	if rb.Size() != 4 {
+		t.Errorf("Expected inspection to leave the buffer intact, got size %d", rb.Size())
+	}
+
+	recorder = httptest.NewRecorder()
+	server.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/api/buffer?limit=x", nil))
+	if recorder.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for an invalid limit, got %d", recorder.Code)
+	}
+}
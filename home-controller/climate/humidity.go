@@ -0,0 +1,35 @@
+package climate
+
+import "math"
+
+// Magnus formula coefficients over water (Alduchov and Eskridge, 1996)
+const (
+	magnusA = 17.625
+	magnusB = 243.04 // °C
+)
+
+// SaturationVaporPressure returns the saturation vapor pressure in hPa at the given temperature
+func SaturationVaporPressure(temperatureCelsius float64) float64 {
+	return 6.1094 * math.Exp(magnusA*temperatureCelsius/(magnusB+temperatureCelsius))
+}
+
+// DewPoint returns the dew point in °C for the given temperature and relative humidity
+// Returns false when the relative humidity is outside (0, 100]
+func DewPoint(temperatureCelsius, humidityPercent float64) (float64, bool) {
+	if humidityPercent <= 0 || humidityPercent > 100 {
+		return 0, false
+	}
+	gamma := math.Log(humidityPercent/100) + magnusA*temperatureCelsius/(magnusB+temperatureCelsius)
+	return magnusB * gamma / (magnusA - gamma), true
+}
+
+// AbsoluteHumidity returns the water vapor density in g/m³ for the given temperature and relative humidity
+// Returns false when the relative humidity is outside [0, 100]
+func AbsoluteHumidity(temperatureCelsius, humidityPercent float64) (float64, bool) {
+	if humidityPercent < 0 || humidityPercent > 100 {
+		return 0, false
+	}
+	// Vapor pressure in Pa divided by the specific gas constant of water vapor (461.5 J/(kg·K)), in g/m³
+	vaporPressure := SaturationVaporPressure(temperatureCelsius) * humidityPercent
+	return vaporPressure / (461.5 * (temperatureCelsius + 273.15)) * 1000, true
+}
@@ -0,0 +1,62 @@
+package climate
+
+import (
+	"math"
+	"testing"
+)
+
+func TestDewPoint(t *testing.T) {
+	tests := []struct {
+		temperature float64
+		humidity    float64
+		expected    float64
+	}{
+		{20, 50, 9.3},
+		{25, 60, 16.7},
+		{0, 80, -3.0},
+		{21.5, 100, 21.5},
+	}
+
+	for _, tt := range tests {
+		dewPoint, ok := DewPoint(tt.temperature, tt.humidity)
+		if !ok {
+			t.Fatalf("Expected dew point for %.1f°C %.0f%%", tt.temperature, tt.humidity)
+		}
+		if math.Abs(dewPoint-tt.expected) > 0.1 {
+			t.Errorf("Expected dew point %.1f for %.1f°C %.0f%%, got %.2f", tt.expected, tt.temperature, tt.humidity, dewPoint)
+		}
+	}
+
+	for _, humidity := range []float64{0, -5, 101} {
+		if _, ok := DewPoint(20, humidity); ok {
+			t.Errorf("Expected no dew point for %.0f%% humidity", humidity)
+		}
+	}
+}
+
+func TestAbsoluteHumidity(t *testing.T) {
+	tests := []struct {
+		temperature float64
+		humidity    float64
+		expected    float64
+	}{
+		{20, 50, 8.65},
+		{25, 100, 23.0},
+		{0, 100, 4.85},
+		{20, 0, 0},
+	}
+
+	for _, tt := range tests {
+		absolute, ok := AbsoluteHumidity(tt.temperature, tt.humidity)
+		if !ok {
+			t.Fatalf("Expected absolute humidity for %.1f°C %.0f%%", tt.temperature, tt.humidity)
+		}
+		if math.Abs(absolute-tt.expected) > 0.1 {
+			t.Errorf("Expected absolute humidity %.2f for %.1f°C %.0f%%, got %.2f", tt.expected, tt.temperature, tt.humidity, absolute)
+		}
+	}
+
+	if _, ok := AbsoluteHumidity(20, 120); ok {
+		t.Error("Expected no absolute humidity for 120% humidity")
+	}
+}
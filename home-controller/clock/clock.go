@@ -0,0 +1,201 @@
+package clock
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// Clock tells the time and creates timers and tickers
+// Components default to Real; tests inject a Fake to drive timing without sleeping
+type Clock interface {
+	Now() time.Time
+	NewTimer(d time.Duration) Timer
+	NewTicker(d time.Duration) Ticker
+}
+
+// Timer is a time.Timer created by a Clock
+type Timer interface {
+	C() <-chan time.Time
+	Stop() bool
+	Reset(d time.Duration) bool
+}
+
+// Ticker is a time.Ticker created by a Clock
+type Ticker interface {
+	C() <-chan time.Time
+	Stop()
+}
+
+// Real is the system clock
+var Real Clock = realClock{}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time { return time.Now() }
+
+func (realClock) NewTimer(d time.Duration) Timer { return realTimer{time.NewTimer(d)} }
+
+func (realClock) NewTicker(d time.Duration) Ticker { return realTicker{time.NewTicker(d)} }
+
+type realTimer struct{ *time.Timer }
+
+func (t realTimer) C() <-chan time.Time { return t.Timer.C }
+
+type realTicker struct{ *time.Ticker }
+
+func (t realTicker) C() <-chan time.Time { return t.Ticker.C }
+
+// Fake is a clock that only moves when advanced, firing the timers and tickers that fall due in
+// order of their deadlines; like time.Ticker, a ticker whose reader lags drops ticks
+type Fake struct {
+	mu      sync.Mutex
+	cond    *sync.Cond // Broadcast when a timer or ticker is armed
+	now     time.Time
+	waiters []*waiter
+}
+
+// waiter is an armed timer or ticker
+type waiter struct {
+	clock  *Fake
+	at     time.Time
+	period time.Duration // 0 for timers
+	ch     chan time.Time
+}
+
+// NewFake creates a fake clock showing start
+func NewFake(start time.Time) *Fake {
+	f := &Fake{now: start}
+	f.cond = sync.NewCond(&f.mu)
+	return f
+}
+
+// Now returns the fake time
+func (f *Fake) Now() time.Time {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.now
+}
+
+// NewTimer creates a timer firing once the clock was advanced by d
+func (f *Fake) NewTimer(d time.Duration) Timer {
+	w := &waiter{clock: f, ch: make(chan time.Time, 1)}
+	w.Reset(d)
+	return w
+}
+
+// NewTicker creates a ticker firing every time the clock was advanced by another d
+func (f *Fake) NewTicker(d time.Duration) Ticker {
+	if d <= 0 {
+		panic("clock: non-positive interval for NewTicker")
+	}
+	w := &waiter{clock: f, period: d, ch: make(chan time.Time, 1)}
+	w.Reset(d)
+	return fakeTicker{w}
+}
+
+// Advance moves the clock forward by d, firing what falls due on the way
+func (f *Fake) Advance(d time.Duration) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.advanceTo(f.now.Add(d))
+}
+
+// Set moves the clock forward to t; an earlier t only sets the time and fires nothing
+func (f *Fake) Set(t time.Time) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if t.Before(f.now) {
+		f.now = t
+		return
+	}
+	f.advanceTo(t)
+}
+
+// advanceTo fires due timers and tickers in order up to target; the caller must hold the lock
+func (f *Fake) advanceTo(target time.Time) {
+	for {
+		sort.SliceStable(f.waiters, func(i, j int) bool { return f.waiters[i].at.Before(f.waiters[j].at) })
+		if len(f.waiters) == 0 || f.waiters[0].at.After(target) {
+			break
+		}
+		w := f.waiters[0]
+		f.now = w.at
+		select {
+		case w.ch <- w.at:
+		default:
+		}
+		if w.period > 0 {
+			w.at = w.at.Add(w.period)
+		} else {
+			f.waiters = f.waiters[1:]
+		}
+	}
+	f.now = target
+}
+
+// Waiters returns the number of armed timers and tickers
+func (f *Fake) Waiters() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.waiters)
+}
+
+// BlockUntil blocks until at least n timers and tickers are armed, e.g. until a component has
+// gone back to waiting after reacting to the last Advance
+func (f *Fake) BlockUntil(n int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for len(f.waiters) < n {
+		f.cond.Wait()
+	}
+}
+
+// C returns the channel the timer or ticker fires on
+func (w *waiter) C() <-chan time.Time {
+	return w.ch
+}
+
+// Reset re-arms the timer or ticker to fire after d; reports whether it was armed
+func (w *waiter) Reset(d time.Duration) bool {
+	f := w.clock
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	armed := f.remove(w)
+	w.at = f.now.Add(d)
+	if d <= 0 && w.period == 0 {
+		// Like time.Timer, a timer for the past fires at once
+		select {
+		case w.ch <- f.now:
+		default:
+		}
+		return armed
+	}
+	f.waiters = append(f.waiters, w)
+	f.cond.Broadcast()
+	return armed
+}
+
+// Stop disarms the timer or ticker; reports whether it was armed
+func (w *waiter) Stop() bool {
+	f := w.clock
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.remove(w)
+}
+
+// fakeTicker adapts a waiter to Ticker, whose Stop reports nothing
+type fakeTicker struct{ *waiter }
+
+func (t fakeTicker) Stop() { t.waiter.Stop() }
+
+// remove disarms w and reports whether it was armed; the caller must hold the lock
+func (f *Fake) remove(w *waiter) bool {
+	for i, armed := range f.waiters {
+		if armed == w {
+			f.waiters = append(f.waiters[:i], f.waiters[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,96 @@
+package clock
+
+import (
+	"testing"
+	"time"
+)
+
+var start = time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC)
+
+// fired returns the time received on ch, or the zero time if nothing is pending
+func fired(ch <-chan time.Time) time.Time {
+	select {
+	case at := <-ch:
+		return at
+	default:
+		return time.Time{}
+	}
+}
+
+func TestFake_Timer(t *testing.T) {
+	clock := NewFake(start)
+	timer := clock.NewTimer(10 * time.Second)
+
+	clock.Advance(9 * time.Second)
+	if at := fired(timer.C()); !at.IsZero() {
+		t.Errorf("Expected the timer not to fire early, got %v", at)
+	}
+	clock.Advance(time.Second)
+	if at := fired(timer.C()); !at.Equal(start.Add(10 * time.Second)) {
+		t.Errorf("Expected the timer to fire at its deadline, got %v", at)
+	}
+	if clock.Waiters() != 0 {
+		t.Errorf("Expected a fired timer to be disarmed, got %d waiters", clock.Waiters())
+	}
+
+	if timer.Reset(5 * time.Second) {
+		t.Error("Expected Reset of a fired timer to report it wasn't armed")
+	}
+	if !timer.Stop() {
+		t.Error("Expected Stop of a reset timer to report it was armed")
+	}
+	clock.Advance(time.Minute)
+	if at := fired(timer.C()); !at.IsZero() {
+		t.Errorf("Expected a stopped timer not to fire, got %v", at)
+	}
+}
+
+func TestFake_TickerDropsTicksOfALaggingReader(t *testing.T) {
+	clock := NewFake(start)
+	ticker := clock.NewTicker(15 * time.Second)
+	defer ticker.Stop()
+
+	clock.Advance(time.Minute)
+	if at := fired(ticker.C()); !at.Equal(start.Add(15 * time.Second)) {
+		t.Errorf("Expected the first tick to be kept, got %v", at)
+	}
+	if at := fired(ticker.C()); !at.IsZero() {
+		t.Errorf("Expected later ticks to be dropped, got %v", at)
+	}
+	if !clock.Now().Equal(start.Add(time.Minute)) {
+		t.Errorf("Expected the clock to end at the target, got %v", clock.Now())
+	}
+
+	clock.Advance(15 * time.Second)
+	if at := fired(ticker.C()); !at.Equal(start.Add(75 * time.Second)) {
+		t.Errorf("Expected the ticker to keep its period, got %v", at)
+	}
+}
+
+func TestFake_FiresInDeadlineOrder(t *testing.T) {
+	clock := NewFake(start)
+	late := clock.NewTimer(20 * time.Second)
+	early := clock.NewTimer(10 * time.Second)
+
+	clock.Advance(30 * time.Second)
+	if at := fired(early.C()); !at.Equal(start.Add(10 * time.Second)) {
+		t.Errorf("Expected the early timer to fire at 10s, got %v", at)
+	}
+	if at := fired(late.C()); !at.Equal(start.Add(20 * time.Second)) {
+		t.Errorf("Expected the late timer to fire at 20s, got %v", at)
+	}
+}
+
+func TestFake_BlockUntil(t *testing.T) {
+	clock := NewFake(start)
+	armed := make(chan Timer)
+	go func() {
+		armed <- clock.NewTimer(time.Second)
+	}()
+
+	clock.BlockUntil(1)
+	<-armed
+	if clock.Waiters() != 1 {
+		t.Errorf("Expected 1 waiter, got %d", clock.Waiters())
+	}
+}
@@ -0,0 +1,122 @@
+package collector
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/mjasion/balena-home/thermostats/buffer"
+	"github.com/mjasion/balena-home/thermostats/schedule"
+	"go.uber.org/zap"
+	"gopkg.in/yaml.v3"
+)
+
+// Collector is a data source adding readings to the buffer until its context is cancelled
+type Collector interface {
+	Start(ctx context.Context)
+}
+
+// Spec is the configuration of an enabled collector
+type Spec struct {
+	Interval time.Duration // Scrape or report interval, also used to align the start to the wall clock
+	Options  Options
+}
+
+// Deps are the shared components a collector is built with
+type Deps struct {
+	Buffer  *buffer.RingBuffer
+	Cadence schedule.Cadence // Nil scrapes every interval
+	Logger  *zap.Logger
+}
+
+// Factory builds a collector from its configuration
+type Factory func(spec Spec, deps Deps) (Collector, error)
+
+var (
+	mu        sync.RWMutex
+	factories = make(map[string]Factory)
+)
+
+// Register makes a collector available under the name; packages call it from init, so linking a
+// package into the binary is all a new data source needs. Registering a name twice panics.
+func Register(name string, factory Factory) {
+	mu.Lock()
+	defer mu.Unlock()
+	if factory == nil {
+		panic("collector: Register factory is nil for " + name)
+	}
+	if _, ok := factories[name]; ok {
+		panic("collector: Register called twice for " + name)
+	}
+	factories[name] = factory
+}
+
+// Names returns the registered collector names, sorted
+func Names() []string {
+	mu.RLock()
+	defer mu.RUnlock()
+	names := make([]string, 0, len(factories))
+	for name := range factories {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// New builds the named collector
+func New(name string, spec Spec, deps Deps) (Collector, error) {
+	mu.RLock()
+	factory, ok := factories[name]
+	mu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown collector %q, registered: %v", name, Names())
+	}
+	if spec.Interval <= 0 {
+		return nil, fmt.Errorf("collector %s: interval must be positive", name)
+	}
+	if deps.Logger == nil {
+		deps.Logger = zap.NewNop()
+	}
+	collector, err := factory(spec, deps.withName(name))
+	if err != nil {
+		return nil, fmt.Errorf("collector %s: %w", name, err)
+	}
+	return collector, nil
+}
+
+// withName scopes the logger to the collector
+func (d Deps) withName(name string) Deps {
+	d.Logger = d.Logger.With(zap.String("collector", name))
+	return d
+}
+
+// Options are the collector specific settings of a config section, e.g. its sensors
+type Options struct {
+	value interface{}
+}
+
+// NewOptions wraps settings as parsed from YAML, or any value marshalling to the same YAML
+func NewOptions(value interface{}) Options {
+	return Options{value: value}
+}
+
+// Decode fills target, a pointer to a struct with yaml tags; unknown keys are rejected so a typo
+// doesn't silently fall back to a default
+func (o Options) Decode(target interface{}) error {
+	if o.value == nil {
+		return nil
+	}
+	data, err := yaml.Marshal(o.value)
+	if err != nil {
+		return fmt.Errorf("failed to encode options: %w", err)
+	}
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(target); err != nil {
+		return fmt.Errorf("invalid options: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,87 @@
+package collector
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+type fakeCollector struct {
+	interval time.Duration
+	options  fakeOptions
+}
+
+func (c *fakeCollector) Start(ctx context.Context) {}
+
+type fakeSensor struct {
+	Name string `yaml:"name"`
+	ID   int    `yaml:"id"`
+}
+
+type fakeOptions struct {
+	Path    string       `yaml:"path"`
+	Sensors []fakeSensor `yaml:"sensors"`
+}
+
+func init() {
+	Register("fake", func(spec Spec, deps Deps) (Collector, error) {
+		options := fakeOptions{Path: "/default"}
+		if err := spec.Options.Decode(&options); err != nil {
+			return nil, err
+		}
+		return &fakeCollector{interval: spec.Interval, options: options}, nil
+	})
+}
+
+func TestNew(t *testing.T) {
+	// Options as parsed from YAML
+	options := NewOptions(map[string]interface{}{
+		"sensors": []interface{}{map[string]interface{}{"name": "attic", "id": 1}},
+	})
+	c, err := New("fake", Spec{Interval: 30 * time.Second, Options: options}, Deps{})
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	fake := c.(*fakeCollector)
+	if fake.interval != 30*time.Second || fake.options.Path != "/default" || len(fake.options.Sensors) != 1 || fake.options.Sensors[0].Name != "attic" {
+		t.Errorf("Expected decoded options with the default path, got %+v", fake)
+	}
+
+	// Options built from typed config structs decode the same way
+	options = NewOptions(map[string]interface{}{"path": "/sys", "sensors": []fakeSensor{{Name: "cellar", ID: 2}}})
+	c, err = New("fake", Spec{Interval: time.Second, Options: options}, Deps{})
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if fake := c.(*fakeCollector); fake.options.Path != "/sys" || fake.options.Sensors[0].ID != 2 {
+		t.Errorf("Expected options from structs, got %+v", fake.options)
+	}
+}
+
+func TestNew_Errors(t *testing.T) {
+	tests := []struct {
+		name      string
+		collector string
+		spec      Spec
+		want      string
+	}{
+		{"unknown collector", "radar", Spec{Interval: time.Second}, "unknown collector"},
+		{"no interval", "fake", Spec{}, "interval must be positive"},
+		{"unknown option", "fake", Spec{Interval: time.Second, Options: NewOptions(map[string]interface{}{"pth": "/sys"})}, "field pth not found"},
+	}
+	for _, tt := range tests {
+		if _, err := New(tt.collector, tt.spec, Deps{}); err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: expected error containing %q, got %v", tt.name, tt.want, err)
+		}
+	}
+}
+
+func TestRegister_Twice(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("Expected a panic when registering a name twice")
+		}
+	}()
+	Register("fake", func(spec Spec, deps Deps) (Collector, error) { return nil, nil })
+}
@@ -5,25 +5,90 @@ import (
 	"os"
 	"regexp"
 	"strings"
+	"time"
 
 	"github.com/ilyakaznacheev/cleanenv"
 	"github.com/jsternberg/zap-logfmt"
+	"github.com/mjasion/balena-home/thermostats/features"
+	"github.com/mjasion/balena-home/thermostats/httpauth"
+	"github.com/mjasion/balena-home/thermostats/httpclient"
+	"github.com/mjasion/balena-home/thermostats/network"
+	"github.com/mjasion/balena-home/thermostats/occupancy"
+	"github.com/mjasion/balena-home/thermostats/remoteconfig"
+	"github.com/mjasion/balena-home/thermostats/signal"
+	"github.com/mjasion/balena-home/thermostats/tuning"
+	"github.com/mjasion/balena-home/thermostats/units"
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
 )
 
 // Config represents the application configuration
 type Config struct {
-	BLE        BLEConfig        `yaml:"ble"`
-	Netatmo    NetatmoConfig    `yaml:"netatmo"`
-	Power      PowerConfig      `yaml:"power"`
-	Prometheus PrometheusConfig `yaml:"prometheus"`
-	Logging    LoggingConfig    `yaml:"logging"`
+	BLE             BLEConfig             `yaml:"ble"`
+	Netatmo         NetatmoConfig         `yaml:"netatmo"`
+	Power           PowerConfig           `yaml:"power"`
+	Pstryk          PstrykConfig          `yaml:"pstryk"`
+	Prices          PricesConfig          `yaml:"prices"`
+	HeatPump        HeatPumpConfig        `yaml:"heatPump"`
+	Water           WaterConfig           `yaml:"water"`
+	OneWire         OneWireConfig         `yaml:"oneWire"`
+	I2C             I2CConfig             `yaml:"i2c"`
+	AirQuality      AirQualityConfig      `yaml:"airQuality"`
+	Collectors      []CollectorConfig     `yaml:"collectors"`
+	Admin           AdminConfig           `yaml:"admin"`
+	Zigbee2MQTT     Zigbee2MQTTConfig     `yaml:"zigbee2mqtt"`
+	BLEProxy        BLEProxyConfig        `yaml:"bleProxy"`
+	Events          EventsConfig          `yaml:"events"`
+	Telemetry       TelemetryConfig       `yaml:"telemetry"`
+	Automation      AutomationConfig      `yaml:"automation"`
+	RoomFusion      RoomFusionConfig      `yaml:"roomFusion"`
+	Summary         SummaryConfig         `yaml:"summary"`
+	IdentityCheck   IdentityCheckConfig   `yaml:"identityCheck"`
+	BatteryEstimate BatteryEstimateConfig `yaml:"batteryEstimate"`
+	Locator         LocatorConfig         `yaml:"locator"`
+	Report          ReportConfig          `yaml:"report"`
+	Notify          NotifyConfig          `yaml:"notify"`
+	History         HistoryConfig         `yaml:"history"`
+	Ingest          IngestConfig          `yaml:"ingest"`
+	RemoteWrite     RemoteWriteConfig     `yaml:"remoteWriteReceiver"`
+	Pushgateway     PushgatewayConfig     `yaml:"pushgateway"`
+	Forward         ForwardConfig         `yaml:"forward"`
+	NATS            NATSConfig            `yaml:"nats"`
+	Leader          LeaderConfig          `yaml:"leader"`
+	Fleet           FleetConfig           `yaml:"fleet"`
+	Connectivity    ConnectivityConfig    `yaml:"connectivity"`
+	DNS             DNSConfig             `yaml:"dns"`
+	Network         NetworkConfig         `yaml:"network"`
+	HTTPClient      HTTPClientConfig      `yaml:"httpClient"`
+	Scheduling      SchedulingConfig      `yaml:"scheduling"`
+	Occupancy       OccupancyConfig       `yaml:"occupancy"`
+	Mode            ModeConfig            `yaml:"mode"`
+	FrostProtection FrostProtectionConfig `yaml:"frostProtection"`
+	RemoteConfig    RemoteConfigConfig    `yaml:"remoteConfig"`
+	Features        FeaturesConfig        `yaml:"features"`
+	FaultInjection  FaultInjectionConfig  `yaml:"faultInjection"`
+	Runtime         RuntimeConfig         `yaml:"runtime"`
+	Watchdog        WatchdogConfig        `yaml:"watchdog"`
+	Startup         StartupConfig         `yaml:"startup"`
+	Prometheus      PrometheusConfig      `yaml:"prometheus"`
+	Logging         LoggingConfig         `yaml:"logging"`
 }
 
 // BLEConfig contains BLE scanning configuration
 type BLEConfig struct {
-	Sensors []SensorConfig `yaml:"sensors"`
+	Sensors         []SensorConfig   `yaml:"sensors"`
+	DerivedHumidity bool             `yaml:"derivedHumidity" env:"BLE_DERIVED_HUMIDITY" env-default:"false"`
+	ReceiverName    string           `yaml:"receiverName" env:"BLE_RECEIVER_NAME"` // Defaults to the device name, then "local"
+	Backlog         BLEBacklogConfig `yaml:"backlog" env-prefix:"BLE_BACKLOG_"`
+}
+
+// BLEBacklogConfig controls the advertisements delivered in a burst once the process resumes from
+// a hang: spread retimes them by their frame counters, drop drops those older than MaxAgeSeconds
+type BLEBacklogConfig struct {
+	Mode          string `yaml:"mode" env:"MODE" env-default:"off"`             // off, spread or drop
+	GapSeconds    int    `yaml:"gapSeconds" env:"GAP" env-default:"10"`         // Monotonic clock gap taken as a hang
+	SettleSeconds int    `yaml:"settleSeconds" env:"SETTLE" env-default:"30"`   // How long after a hang readings are backlog
+	MaxAgeSeconds int    `yaml:"maxAgeSeconds" env:"MAX_AGE" env-default:"120"` // Drop mode only
 }
 
 // SensorConfig contains configuration for a single sensor
@@ -40,35 +105,900 @@ type NetatmoConfig struct {
 	ClientSecret  string `yaml:"clientSecret" env:"NETATMO_CLIENT_SECRET"`
 	RefreshToken  string `yaml:"refreshToken" env:"NETATMO_REFRESH_TOKEN"`
 	FetchInterval int    `yaml:"fetchIntervalSeconds" env:"NETATMO_FETCH_INTERVAL" env-default:"60"`
+	// Where reading timestamps come from: local, server (the API's time_server) or module (when the
+	// room's modules last reported)
+	TimestampSource string `yaml:"timestampSource" env:"NETATMO_TIMESTAMP_SOURCE" env-default:"local"`
+
+	Backfill NetatmoBackfillConfig `yaml:"backfill" env-prefix:"NETATMO_BACKFILL_"`
+}
+
+// NetatmoBackfillConfig fills the heating history lost during downtime from the rooms' measure
+// history on startup
+type NetatmoBackfillConfig struct {
+	Enabled   bool   `yaml:"enabled" env:"ENABLED" env-default:"false"`
+	StateFile string `yaml:"stateFile" env:"STATE_FILE" env-default:"/data/netatmo_backfill.json"` // Records the last successful push
+	// How far back the endpoint accepts samples out of order, bounding the backfilled gap; also
+	// bounded by maxSampleAgeSeconds of the Prometheus endpoint
+	WindowSeconds int `yaml:"windowSeconds" env:"WINDOW" env-default:"3600"`
 }
 
 // PowerConfig contains power meter scraping configuration
 type PowerConfig struct {
-	Enabled               bool    `yaml:"enabled" env:"POWER_ENABLED" env-default:"false"`
-	ScrapeURL             string  `yaml:"scrapeUrl" env:"POWER_SCRAPE_URL"`
-	ScrapeIntervalSeconds int     `yaml:"scrapeIntervalSeconds" env:"POWER_SCRAPE_INTERVAL" env-default:"2"`
-	ScrapeTimeoutSeconds  float64 `yaml:"scrapeTimeoutSeconds" env:"POWER_SCRAPE_TIMEOUT" env-default:"1.5"`
+	Enabled               bool           `yaml:"enabled" env:"POWER_ENABLED" env-default:"false"`
+	ScrapeURL             string         `yaml:"scrapeUrl" env:"POWER_SCRAPE_URL"`
+	ScrapeIntervalSeconds int            `yaml:"scrapeIntervalSeconds" env:"POWER_SCRAPE_INTERVAL" env-default:"2"`
+	ScrapeTimeoutSeconds  float64        `yaml:"scrapeTimeoutSeconds" env:"POWER_SCRAPE_TIMEOUT" env-default:"1.5"`
+	StaleRepeatIntervals  int            `yaml:"staleRepeatIntervals" env:"POWER_STALE_REPEAT_INTERVALS" env-default:"0"`
+	Auth                  HTTPAuthConfig `yaml:"auth" env-prefix:"POWER_AUTH_"`
+	TLS                   HTTPTLSConfig  `yaml:"tls" env-prefix:"POWER_TLS_"`
+}
+
+// PstrykConfig contains configuration for reconciling local energy with the official smart meter
+// consumption from the Pstryk API
+type PstrykConfig struct {
+	Enabled           bool    `yaml:"enabled" env:"PSTRYK_ENABLED" env-default:"false"`
+	Token             string  `yaml:"token" env:"PSTRYK_TOKEN"`
+	SensorIDs         []int   `yaml:"sensorIds" env:"PSTRYK_SENSOR_IDS" env-separator:","` // Power sensors summed into the local consumption; empty means all
+	IntervalSeconds   int     `yaml:"intervalSeconds" env:"PSTRYK_INTERVAL" env-default:"3600"`
+	LookbackHours     int     `yaml:"lookbackHours" env:"PSTRYK_LOOKBACK_HOURS" env-default:"48"`
+	DriftAlertPercent float64 `yaml:"driftAlertPercent" env:"PSTRYK_DRIFT_ALERT_PERCENT" env-default:"10"`
+}
+
+// PricesConfig contains configuration for fetching day-ahead electricity prices
+type PricesConfig struct {
+	Enabled               bool    `yaml:"enabled" env:"PRICES_ENABLED" env-default:"false"`
+	Source                string  `yaml:"source" env:"PRICES_SOURCE" env-default:"pse"` // pse (RCE) or entsoe
+	EntsoeToken           string  `yaml:"entsoeToken" env:"PRICES_ENTSOE_TOKEN"`
+	EntsoeArea            string  `yaml:"entsoeArea" env:"PRICES_ENTSOE_AREA" env-default:"10YPL-AREA-----S"`
+	EURPLNRate            float64 `yaml:"eurPlnRate" env:"PRICES_EUR_PLN_RATE" env-default:"4.25"` // For ENTSO-E prices published in EUR
+	FetchIntervalSeconds  int     `yaml:"fetchIntervalSeconds" env:"PRICES_FETCH_INTERVAL" env-default:"3600"`
+	ReportIntervalSeconds int     `yaml:"reportIntervalSeconds" env:"PRICES_REPORT_INTERVAL" env-default:"300"`
+	HorizonHours          int     `yaml:"horizonHours" env:"PRICES_HORIZON_HOURS" env-default:"24"`
+}
+
+// HTTPAuthConfig contains authentication settings for an HTTP scrape target
+type HTTPAuthConfig struct {
+	Type        string `yaml:"type" env:"TYPE" env-default:"none"` // none, basic, bearer, header or digest
+	Username    string `yaml:"username" env:"USERNAME"`
+	Password    string `yaml:"password" env:"PASSWORD"`
+	Token       string `yaml:"token" env:"TOKEN"`
+	HeaderName  string `yaml:"headerName" env:"HEADER_NAME"`
+	HeaderValue string `yaml:"headerValue" env:"HEADER_VALUE"`
+}
+
+// HTTPTLSConfig contains TLS settings for an HTTP scrape target
+type HTTPTLSConfig struct {
+	CAFile             string `yaml:"caFile" env:"CA_FILE"`
+	CertFile           string `yaml:"certFile" env:"CERT_FILE"`
+	KeyFile            string `yaml:"keyFile" env:"KEY_FILE"`
+	InsecureSkipVerify bool   `yaml:"insecureSkipVerify" env:"INSECURE_SKIP_VERIFY" env-default:"false"`
+}
+
+// HeatPumpConfig contains heat pump adapter polling configuration
+type HeatPumpConfig struct {
+	Enabled             bool                   `yaml:"enabled" env:"HEATPUMP_ENABLED" env-default:"false"`
+	Protocol            string                 `yaml:"protocol" env:"HEATPUMP_PROTOCOL" env-default:"http"`
+	Address             string                 `yaml:"address" env:"HEATPUMP_ADDRESS"`
+	UnitID              int                    `yaml:"unitId" env:"HEATPUMP_UNIT_ID" env-default:"1"`
+	PollIntervalSeconds int                    `yaml:"pollIntervalSeconds" env:"HEATPUMP_POLL_INTERVAL" env-default:"30"`
+	TimeoutSeconds      float64                `yaml:"timeoutSeconds" env:"HEATPUMP_TIMEOUT" env-default:"5"`
+	Auth                HTTPAuthConfig         `yaml:"auth" env-prefix:"HEATPUMP_AUTH_"`
+	TLS                 HTTPTLSConfig          `yaml:"tls" env-prefix:"HEATPUMP_TLS_"`
+	Metrics             []HeatPumpMetricConfig `yaml:"metrics"`
+}
+
+// HeatPumpMetricConfig maps a heat pump register or JSON field to a metric
+type HeatPumpMetricConfig struct {
+	Name     string  `yaml:"name"`
+	Path     string  `yaml:"path"`
+	Register uint16  `yaml:"register"`
+	Words    int     `yaml:"words"`
+	Signed   bool    `yaml:"signed"`
+	Scale    float64 `yaml:"scale"`
+	Unit     string  `yaml:"unit"` // Unit of the raw value, e.g. dC or mV; sets Scale from the name's unit suffix
+}
+
+// WaterConfig contains water meter GPIO pulse counting configuration
+type WaterConfig struct {
+	Enabled               bool    `yaml:"enabled" env:"WATER_ENABLED" env-default:"false"`
+	GPIOPin               int     `yaml:"gpioPin" env:"WATER_GPIO_PIN" env-default:"17"`
+	GPIOBasePath          string  `yaml:"gpioBasePath" env:"WATER_GPIO_BASE_PATH" env-default:"/sys/class/gpio"`
+	ActiveLow             bool    `yaml:"activeLow" env:"WATER_ACTIVE_LOW" env-default:"true"`
+	LitersPerPulse        float64 `yaml:"litersPerPulse" env:"WATER_LITERS_PER_PULSE" env-default:"1"`
+	DebounceMs            int     `yaml:"debounceMs" env:"WATER_DEBOUNCE_MS" env-default:"50"`
+	SampleIntervalMs      int     `yaml:"sampleIntervalMs" env:"WATER_SAMPLE_INTERVAL_MS" env-default:"10"`
+	ReportIntervalSeconds int     `yaml:"reportIntervalSeconds" env:"WATER_REPORT_INTERVAL" env-default:"15"`
+	StateFile             string  `yaml:"stateFile" env:"WATER_STATE_FILE" env-default:"/data/water_counter.json"`
+}
+
+// OneWireConfig contains 1-Wire DS18B20 sensor configuration
+type OneWireConfig struct {
+	Enabled             bool                  `yaml:"enabled" env:"ONEWIRE_ENABLED" env-default:"false"`
+	DevicesPath         string                `yaml:"devicesPath" env:"ONEWIRE_DEVICES_PATH" env-default:"/sys/bus/w1/devices"`
+	ReadIntervalSeconds int                   `yaml:"readIntervalSeconds" env:"ONEWIRE_READ_INTERVAL" env-default:"30"`
+	Sensors             []OneWireSensorConfig `yaml:"sensors"`
+}
+
+// OneWireSensorConfig contains configuration for a single DS18B20 sensor
+type OneWireSensorConfig struct {
+	Name     string `yaml:"name"`
+	ID       int    `yaml:"id"`
+	DeviceID string `yaml:"deviceId"`
+}
+
+// I2CConfig contains I2C environmental sensor configuration
+type I2CConfig struct {
+	Enabled             bool              `yaml:"enabled" env:"I2C_ENABLED" env-default:"false"`
+	ReadIntervalSeconds int               `yaml:"readIntervalSeconds" env:"I2C_READ_INTERVAL" env-default:"30"`
+	Sensors             []I2CSensorConfig `yaml:"sensors"`
+}
+
+// I2CSensorConfig contains configuration for a single I2C sensor
+type I2CSensorConfig struct {
+	Name    string `yaml:"name"`
+	ID      int    `yaml:"id"`
+	Model   string `yaml:"model"`
+	Bus     int    `yaml:"bus"`
+	Address int    `yaml:"address"`
+}
+
+// CollectorConfig enables a registered collector by name; its options are decoded by the collector
+// itself, so a new data source needs no config changes
+type CollectorConfig struct {
+	Name            string                 `yaml:"name"`
+	IntervalSeconds int                    `yaml:"intervalSeconds"`
+	Options         map[string]interface{} `yaml:"options"`
+}
+
+// legacyCollectors maps the collectors that also have their own config section to whether it is enabled
+func (c *Config) legacyCollectors() map[string]bool {
+	return map[string]bool{
+		"water":   c.Water.Enabled,
+		"onewire": c.OneWire.Enabled,
+		"i2c":     c.I2C.Enabled,
+	}
+}
+
+// EnabledCollectors returns the collectors listed under collectors and those enabled through their
+// own config section, which is kept for existing configurations
+func (c *Config) EnabledCollectors() []CollectorConfig {
+	var collectors []CollectorConfig
+	if c.Water.Enabled {
+		collectors = append(collectors, CollectorConfig{
+			Name:            "water",
+			IntervalSeconds: c.Water.ReportIntervalSeconds,
+			Options: map[string]interface{}{
+				"gpioPin":          c.Water.GPIOPin,
+				"gpioBasePath":     c.Water.GPIOBasePath,
+				"activeLow":        c.Water.ActiveLow,
+				"litersPerPulse":   c.Water.LitersPerPulse,
+				"debounceMs":       c.Water.DebounceMs,
+				"sampleIntervalMs": c.Water.SampleIntervalMs,
+				"stateFile":        c.Water.StateFile,
+			},
+		})
+	}
+	if c.OneWire.Enabled {
+		collectors = append(collectors, CollectorConfig{
+			Name:            "onewire",
+			IntervalSeconds: c.OneWire.ReadIntervalSeconds,
+			Options:         map[string]interface{}{"devicesPath": c.OneWire.DevicesPath, "sensors": c.OneWire.Sensors},
+		})
+	}
+	if c.I2C.Enabled {
+		collectors = append(collectors, CollectorConfig{
+			Name:            "i2c",
+			IntervalSeconds: c.I2C.ReadIntervalSeconds,
+			Options:         map[string]interface{}{"sensors": c.I2C.Sensors},
+		})
+	}
+	return append(collectors, c.Collectors...)
+}
+
+// AirQualityConfig contains CO2 sensor configuration
+type AirQualityConfig struct {
+	Enabled             bool                     `yaml:"enabled" env:"AIR_QUALITY_ENABLED" env-default:"false"`
+	ReadIntervalSeconds int                      `yaml:"readIntervalSeconds" env:"AIR_QUALITY_READ_INTERVAL" env-default:"30"`
+	Sensors             []AirQualitySensorConfig `yaml:"sensors"`
+}
+
+// AirQualitySensorConfig contains configuration for a single CO2 sensor
+type AirQualitySensorConfig struct {
+	Name    string `yaml:"name"`
+	ID      int    `yaml:"id"`
+	Model   string `yaml:"model"`
+	Device  string `yaml:"device"`
+	Bus     int    `yaml:"bus"`
+	Address int    `yaml:"address"`
+}
+
+// Zigbee2MQTTConfig contains Zigbee2MQTT ingestion configuration
+type Zigbee2MQTTConfig struct {
+	Enabled   bool       `yaml:"enabled" env:"ZIGBEE2MQTT_ENABLED" env-default:"false"`
+	BaseTopic string     `yaml:"baseTopic" env:"ZIGBEE2MQTT_BASE_TOPIC" env-default:"zigbee2mqtt"`
+	MQTT      MQTTConfig `yaml:"mqtt" env-prefix:"ZIGBEE2MQTT_MQTT_"`
+}
+
+// BLEProxyConfig contains configuration for advertisements relayed by ESP32 BLE proxies
+// MQTT is used when a broker is set; the HTTP endpoint is served when the admin server is enabled
+type BLEProxyConfig struct {
+	Enabled   bool       `yaml:"enabled" env:"BLE_PROXY_ENABLED" env-default:"false"`
+	BaseTopic string     `yaml:"baseTopic" env:"BLE_PROXY_BASE_TOPIC" env-default:"ble_proxy"`
+	Token     string     `yaml:"token" env:"BLE_PROXY_TOKEN"`
+	MQTT      MQTTConfig `yaml:"mqtt" env-prefix:"BLE_PROXY_MQTT_"`
+}
+
+// MQTTConfig contains MQTT broker connection settings
+type MQTTConfig struct {
+	Broker           string        `yaml:"broker" env:"BROKER"`
+	ClientID         string        `yaml:"clientId" env:"CLIENT_ID" env-default:"home-controller"`
+	Username         string        `yaml:"username" env:"USERNAME"`
+	Password         string        `yaml:"password" env:"PASSWORD"`
+	KeepAliveSeconds int           `yaml:"keepAliveSeconds" env:"KEEP_ALIVE" env-default:"60"`
+	TLS              HTTPTLSConfig `yaml:"tls" env-prefix:"TLS_"` // Used with ssl:// and wss:// brokers
+}
+
+// EventsConfig contains event log configuration
+type EventsConfig struct {
+	Capacity               int                      `yaml:"capacity" env:"EVENTS_CAPACITY" env-default:"500"`
+	IPCheckIntervalSeconds int                      `yaml:"ipCheckIntervalSeconds" env:"EVENTS_IP_CHECK_INTERVAL" env-default:"60"`
+	GrafanaAnnotations     GrafanaAnnotationsConfig `yaml:"grafanaAnnotations" env-prefix:"EVENTS_GRAFANA_"`
+	Loki                   EventsLokiConfig         `yaml:"loki" env-prefix:"EVENTS_LOKI_"`
+}
+
+// EventsLokiConfig contains settings for pushing events to the Loki instance of logging.loki
+// Events are pushed whether or not log shipping is enabled and whatever its level
+type EventsLokiConfig struct {
+	Enabled bool `yaml:"enabled" env:"ENABLED" env-default:"false"`
+}
+
+// GrafanaAnnotationsConfig contains settings for publishing events as Grafana annotations
+type GrafanaAnnotationsConfig struct {
+	Enabled bool   `yaml:"enabled" env:"ENABLED" env-default:"false"`
+	URL     string `yaml:"url" env:"URL"`
+	Token   string `yaml:"token" env:"TOKEN"`
+}
+
+// TelemetryConfig contains self-instrumentation settings
+type TelemetryConfig struct {
+	DependencyMetrics     bool `yaml:"dependencyMetrics" env:"TELEMETRY_DEPENDENCY_METRICS" env-default:"false"`
+	HTTPServerMetrics     bool `yaml:"httpServerMetrics" env:"TELEMETRY_HTTP_SERVER_METRICS" env-default:"false"`
+	ReportIntervalSeconds int  `yaml:"reportIntervalSeconds" env:"TELEMETRY_REPORT_INTERVAL" env-default:"60"`
+	ConnectionMetrics     bool `yaml:"connectionMetrics" env:"TELEMETRY_CONNECTION_METRICS" env-default:"false"` // Trace push connections, requires dependency metrics
+}
+
+// AutomationConfig contains automation rule configuration
+type AutomationConfig struct {
+	WebhookTimeoutSeconds float64            `yaml:"webhookTimeoutSeconds" env:"AUTOMATION_WEBHOOK_TIMEOUT" env-default:"5"`
+	LoadShedding          LoadSheddingConfig `yaml:"loadShedding"`
+	Expressions           ExpressionsConfig  `yaml:"expressions"`
+	PriceRules            PriceRulesConfig   `yaml:"priceRules"`
+	Ventilation           VentilationConfig  `yaml:"ventilation"`
+}
+
+// LoadSheddingConfig contains power load shedding rules
+type LoadSheddingConfig struct {
+	Enabled bool                 `yaml:"enabled" env:"LOAD_SHEDDING_ENABLED" env-default:"false"`
+	Rules   []LoadShedRuleConfig `yaml:"rules"`
+}
+
+// LoadShedRuleConfig turns off a load when active power stays above a threshold
+type LoadShedRuleConfig struct {
+	Name              string        `yaml:"name"`
+	SensorID          int           `yaml:"sensorId"`
+	ThresholdWatts    float64       `yaml:"thresholdWatts"`
+	ForSeconds        int           `yaml:"forSeconds"`
+	Shed              WebhookConfig `yaml:"shed"`
+	RestoreBelowWatts float64       `yaml:"restoreBelowWatts"`
+	RestoreForSeconds int           `yaml:"restoreForSeconds"`
+	Restore           WebhookConfig `yaml:"restore"`
+}
+
+// ExpressionsConfig contains expression rules evaluated against the reading stream
+type ExpressionsConfig struct {
+	Enabled bool                   `yaml:"enabled" env:"AUTOMATION_EXPRESSIONS_ENABLED" env-default:"false"`
+	Filters []SignalFilterConfig   `yaml:"filters"` // Referenced by name from rule variables and outputs
+	Rules   []ExpressionRuleConfig `yaml:"rules"`
+}
+
+// SignalFilterConfig is a named filter smoothing a series, e.g. 2-second power readings
+type SignalFilterConfig struct {
+	Name                string  `yaml:"name"`
+	Type                string  `yaml:"type"`                // moving_average, ewma, hysteresis or debounce
+	WindowSeconds       float64 `yaml:"windowSeconds"`       // moving_average
+	TimeConstantSeconds float64 `yaml:"timeConstantSeconds"` // ewma
+	Low                 float64 `yaml:"low"`                 // hysteresis: output turns 0 at or below
+	High                float64 `yaml:"high"`                // hysteresis: output turns 1 at or above
+	HoldSeconds         float64 `yaml:"holdSeconds"`         // debounce
+}
+
+// Spec returns the filter spec of the config
+func (f SignalFilterConfig) Spec() signal.Spec {
+	return signal.Spec{
+		Type:         f.Type,
+		Window:       time.Duration(f.WindowSeconds * float64(time.Second)),
+		TimeConstant: time.Duration(f.TimeConstantSeconds * float64(time.Second)),
+		Low:          f.Low,
+		High:         f.High,
+		Hold:         time.Duration(f.HoldSeconds * float64(time.Second)),
+	}
+}
+
+// filterSpec returns the spec of the named filter
+func (e *ExpressionsConfig) filterSpec(name string) (signal.Spec, bool) {
+	for _, filter := range e.Filters {
+		if filter.Name == name {
+			return filter.Spec(), true
+		}
+	}
+	return signal.Spec{}, false
+}
+
+// VarFilters returns the filter specs of the rule's variables that reference a filter
+func (e *ExpressionsConfig) VarFilters(rule ExpressionRuleConfig) map[string]signal.Spec {
+	specs := make(map[string]signal.Spec)
+	for name, selector := range rule.Vars {
+		if spec, ok := e.filterSpec(selector.Filter); ok {
+			specs[name] = spec
+		}
+	}
+	return specs
+}
+
+// OutputFilter returns the filter spec of the rule's result, or nil without a filter
+func (e *ExpressionsConfig) OutputFilter(rule ExpressionRuleConfig) *signal.Spec {
+	if spec, ok := e.filterSpec(rule.Filter); ok {
+		return &spec
+	}
+	return nil
+}
+
+// ExpressionRuleConfig derives a metric or calls a webhook from an expression over pushed samples
+type ExpressionRuleConfig struct {
+	Name     string                    `yaml:"name"`
+	Vars     map[string]SelectorConfig `yaml:"vars"`
+	Expr     string                    `yaml:"expr"`
+	Metric   string                    `yaml:"metric"`   // Derived metric name
+	Webhook  WebhookConfig             `yaml:"webhook"`  // Called when the expression becomes true
+	Critical bool                      `yaml:"critical"` // Called even while away or in vacation or maintenance mode
+	Filter   string                    `yaml:"filter"`   // Named filter of the result, e.g. a debounce
+}
+
+// PriceRulesConfig contains rules switching loads by the electricity price
+type PriceRulesConfig struct {
+	Enabled                 bool              `yaml:"enabled" env:"PRICE_RULES_ENABLED" env-default:"false"`
+	EvaluateIntervalSeconds int               `yaml:"evaluateIntervalSeconds" env:"PRICE_RULES_EVALUATE_INTERVAL" env-default:"60"`
+	Rules                   []PriceRuleConfig `yaml:"rules"`
+}
+
+// PriceRuleConfig enables a load while the price is below a threshold or in the cheapest hours of the day
+type PriceRuleConfig struct {
+	Name           string        `yaml:"name"`
+	BelowPLNPerKWh *float64      `yaml:"belowPlnPerKwh"` // Unset disables the price threshold
+	CheapestHours  int           `yaml:"cheapestHours"`  // 0 disables the cheapest hours
+	Enable         WebhookConfig `yaml:"enable"`
+	Disable        WebhookConfig `yaml:"disable"` // Optional
+}
+
+// VentilationConfig recommends ventilating, and optionally switches an HRV or fan, while the most humid
+// indoor sensor holds more water per cubic meter of air than the outdoor sensor
+type VentilationConfig struct {
+	Enabled                    bool          `yaml:"enabled" env:"VENTILATION_ENABLED" env-default:"false"`
+	IndoorSensors              []string      `yaml:"indoorSensors" env:"VENTILATION_INDOOR_SENSORS" env-separator:","` // BLE, I2C, air quality sensor or Zigbee device names
+	OutdoorSensor              string        `yaml:"outdoorSensor" env:"VENTILATION_OUTDOOR_SENSOR"`
+	OnDeltaGramsPerCubicMeter  float64       `yaml:"onDeltaGramsPerCubicMeter" env:"VENTILATION_ON_DELTA" env-default:"2"`
+	OffDeltaGramsPerCubicMeter float64       `yaml:"offDeltaGramsPerCubicMeter" env:"VENTILATION_OFF_DELTA" env-default:"0.5"`
+	MinIndoorHumidityPercent   float64       `yaml:"minIndoorHumidityPercent" env:"VENTILATION_MIN_INDOOR_HUMIDITY" env-default:"45"`
+	EvaluateIntervalSeconds    int           `yaml:"evaluateIntervalSeconds" env:"VENTILATION_EVALUATE_INTERVAL" env-default:"60"`
+	On                         WebhookConfig `yaml:"on"`  // Optional
+	Off                        WebhookConfig `yaml:"off"` // Optional
+}
+
+// SelectorConfig selects samples by metric name and labels
+type SelectorConfig struct {
+	Metric string            `yaml:"metric"`
+	Labels map[string]string `yaml:"labels"`
+	Filter string            `yaml:"filter"` // Named filter applied to the selected samples
+}
+
+// RoomFusionConfig contains configuration for canonical per-room temperatures
+type RoomFusionConfig struct {
+	Enabled      bool         `yaml:"enabled" env:"ROOM_FUSION_ENABLED" env-default:"false"`
+	StaleSeconds int          `yaml:"staleSeconds" env:"ROOM_FUSION_STALE_SECONDS" env-default:"600"`
+	Rooms        []RoomConfig `yaml:"rooms"`
+}
+
+// SummaryConfig contains configuration for hourly BLE sensor aggregates
+type SummaryConfig struct {
+	Enabled bool `yaml:"enabled" env:"SUMMARY_ENABLED" env-default:"false"`
+}
+
+// IdentityCheckConfig contains configuration for detecting BLE sensors whose labels collide
+type IdentityCheckConfig struct {
+	Enabled               bool `yaml:"enabled" env:"IDENTITY_CHECK_ENABLED" env-default:"true"`
+	ReportIntervalSeconds int  `yaml:"reportIntervalSeconds" env:"IDENTITY_CHECK_REPORT_INTERVAL" env-default:"60"`
+}
+
+// BatteryEstimateConfig contains configuration for projecting BLE sensor battery life
+type BatteryEstimateConfig struct {
+	Enabled               bool   `yaml:"enabled" env:"BATTERY_ESTIMATE_ENABLED" env-default:"false"`
+	StateFile             string `yaml:"stateFile" env:"BATTERY_ESTIMATE_STATE_FILE" env-default:"/data/battery_history.json"`
+	WindowDays            int    `yaml:"windowDays" env:"BATTERY_ESTIMATE_WINDOW_DAYS" env-default:"30"`
+	AlertDays             int    `yaml:"alertDays" env:"BATTERY_ESTIMATE_ALERT_DAYS" env-default:"14"`
+	ReportIntervalSeconds int    `yaml:"reportIntervalSeconds" env:"BATTERY_ESTIMATE_REPORT_INTERVAL" env-default:"300"`
+}
+
+// LocatorConfig contains configuration for inferring the receiver nearest to BLE sensors and beacons
+type LocatorConfig struct {
+	Enabled               bool              `yaml:"enabled" env:"LOCATOR_ENABLED" env-default:"false"`
+	ReportIntervalSeconds int               `yaml:"reportIntervalSeconds" env:"LOCATOR_REPORT_INTERVAL" env-default:"30"`
+	StaleSeconds          int               `yaml:"staleSeconds" env:"LOCATOR_STALE_SECONDS" env-default:"120"`
+	HysteresisDB          float64           `yaml:"hysteresisDb" env:"LOCATOR_HYSTERESIS_DB" env-default:"5"`
+	Rooms                 map[string]string `yaml:"rooms"` // Room label by receiver name
+	Beacons               []BeaconConfig    `yaml:"beacons"`
+}
+
+// BeaconConfig contains configuration for a BLE device tracked for presence only
+type BeaconConfig struct {
+	Name       string `yaml:"name"`
+	MACAddress string `yaml:"macAddress"`
+}
+
+// ReportConfig contains configuration for the daily summary report
+type ReportConfig struct {
+	Enabled        bool    `yaml:"enabled" env:"REPORT_ENABLED" env-default:"false"`
+	SendAt         string  `yaml:"sendAt" env:"REPORT_SEND_AT" env-default:"07:00"` // Local time the previous day's report is sent
+	Title          string  `yaml:"title" env:"REPORT_TITLE" env-default:"Home report"`
+	EnergyPriceKWh float64 `yaml:"energyPricePerKWh" env:"REPORT_ENERGY_PRICE_PER_KWH" env-default:"0"` // 0 leaves out the cost
+	Currency       string  `yaml:"currency" env:"REPORT_CURRENCY" env-default:"PLN"`
+}
+
+// HistoryConfig contains configuration for the local reading history served by the export endpoint
+type HistoryConfig struct {
+	Enabled              bool   `yaml:"enabled" env:"HISTORY_ENABLED" env-default:"false"`
+	Backend              string `yaml:"backend" env:"HISTORY_BACKEND" env-default:"files"` // files or sqlite
+	Dir                  string `yaml:"dir" env:"HISTORY_DIR" env-default:"/data/history"`
+	RetentionDays        int    `yaml:"retentionDays" env:"HISTORY_RETENTION_DAYS" env-default:"30"`
+	FlushIntervalSeconds int    `yaml:"flushIntervalSeconds" env:"HISTORY_FLUSH_INTERVAL" env-default:"60"`
+}
+
+// NotifyConfig contains the channels notifications such as the daily report are delivered through
+type NotifyConfig struct {
+	Telegram TelegramConfig      `yaml:"telegram" env-prefix:"NOTIFY_TELEGRAM_"`
+	Email    EmailConfig         `yaml:"email" env-prefix:"NOTIFY_EMAIL_"`
+	Webhook  NotifyWebhookConfig `yaml:"webhook" env-prefix:"NOTIFY_WEBHOOK_"`
+}
+
+// TelegramConfig contains settings for sending notifications through a Telegram bot
+type TelegramConfig struct {
+	Enabled  bool   `yaml:"enabled" env:"ENABLED" env-default:"false"`
+	BotToken string `yaml:"botToken" env:"BOT_TOKEN"`
+	ChatID   string `yaml:"chatId" env:"CHAT_ID"`
+}
+
+// EmailConfig contains settings for sending notifications over SMTP
+type EmailConfig struct {
+	Enabled  bool     `yaml:"enabled" env:"ENABLED" env-default:"false"`
+	Host     string   `yaml:"host" env:"HOST"`
+	Port     int      `yaml:"port" env:"PORT" env-default:"587"`
+	Username string   `yaml:"username" env:"USERNAME"`
+	Password string   `yaml:"password" env:"PASSWORD"`
+	From     string   `yaml:"from" env:"FROM"`
+	To       []string `yaml:"to" env:"TO" env-separator:","`
+}
+
+// NotifyWebhookConfig contains settings for posting notifications as JSON
+type NotifyWebhookConfig struct {
+	Enabled bool   `yaml:"enabled" env:"ENABLED" env-default:"false"`
+	URL     string `yaml:"url" env:"URL"`
+	Token   string `yaml:"token" env:"TOKEN"`
+}
+
+// Enabled reports whether any notification channel is enabled
+func (n *NotifyConfig) Enabled() bool {
+	return n.Telegram.Enabled || n.Email.Enabled || n.Webhook.Enabled
+}
+
+// RoomConfig selects the preferred and fallback temperature sources of a room
+type RoomConfig struct {
+	Name         string           `yaml:"name"`
+	Primary      RoomSourceConfig `yaml:"primary"`
+	Secondary    RoomSourceConfig `yaml:"secondary"`
+	StaleSeconds int              `yaml:"staleSeconds"` // Overrides the default when set
+}
+
+// RoomSourceConfig selects a temperature sample; name is the source label and defaults to the metric prefix
+type RoomSourceConfig struct {
+	Name   string            `yaml:"name"`
+	Metric string            `yaml:"metric"`
+	Labels map[string]string `yaml:"labels"`
+}
+
+// WebhookConfig describes an HTTP request sent by an automation
+type WebhookConfig struct {
+	Method  string            `yaml:"method"`
+	URL     string            `yaml:"url"`
+	Body    string            `yaml:"body"`
+	Headers map[string]string `yaml:"headers"`
+}
+
+// IngestConfig contains configuration for accepting readings from satellite instances
+type IngestConfig struct {
+	Enabled bool   `yaml:"enabled" env:"INGEST_ENABLED" env-default:"false"`
+	Token   string `yaml:"token" env:"INGEST_TOKEN"`
+}
+
+// RemoteWriteConfig contains configuration for accepting Prometheus remote_write from other devices
+type RemoteWriteConfig struct {
+	Enabled bool   `yaml:"enabled" env:"REMOTE_WRITE_RECEIVER_ENABLED" env-default:"false"`
+	Token   string `yaml:"token" env:"REMOTE_WRITE_RECEIVER_TOKEN"`
+}
+
+// PushgatewayConfig contains configuration for accepting Pushgateway-style pushes from local scripts
+type PushgatewayConfig struct {
+	Enabled bool   `yaml:"enabled" env:"PUSHGATEWAY_ENABLED" env-default:"false"`
+	Token   string `yaml:"token" env:"PUSHGATEWAY_TOKEN"`
+}
+
+// ForwardConfig contains configuration for relaying readings to a main instance instead of Prometheus
+type ForwardConfig struct {
+	Enabled         bool   `yaml:"enabled" env:"FORWARD_ENABLED" env-default:"false"`
+	URL             string `yaml:"url" env:"FORWARD_URL"`
+	Token           string `yaml:"token" env:"FORWARD_TOKEN"`
+	IntervalSeconds int    `yaml:"intervalSeconds" env:"FORWARD_INTERVAL" env-default:"10"`
+}
+
+// NATSConfig contains configuration for publishing readings to a NATS server alongside the Prometheus push
+type NATSConfig struct {
+	Enabled           bool   `yaml:"enabled" env:"NATS_ENABLED" env-default:"false"`
+	Server            string `yaml:"server" env:"NATS_SERVER" env-default:"nats://localhost:4222"` // URL, tls:// for TLS
+	Username          string `yaml:"username" env:"NATS_USERNAME"`
+	Password          string `yaml:"password" env:"NATS_PASSWORD"`
+	Token             string `yaml:"token" env:"NATS_TOKEN"`
+	SubjectPrefix     string `yaml:"subjectPrefix" env:"NATS_SUBJECT_PREFIX" env-default:"home.readings"` // Subjects are <prefix>.<type>
+	Encoding          string `yaml:"encoding" env:"NATS_ENCODING" env-default:"json"`                     // json or protobuf
+	JetStream         bool   `yaml:"jetStream" env:"NATS_JETSTREAM" env-default:"true"`                   // Wait for stream acknowledgements
+	AckTimeoutSeconds int    `yaml:"ackTimeoutSeconds" env:"NATS_ACK_TIMEOUT" env-default:"10"`
+	IntervalSeconds   int    `yaml:"intervalSeconds" env:"NATS_INTERVAL" env-default:"10"`
+	QueueSize         int    `yaml:"queueSize" env:"NATS_QUEUE_SIZE" env-default:"10000"` // Readings kept until the server confirms them
+}
+
+// LeaderConfig contains configuration for leader election between redundant collectors
+// One instance hosts the lease on its admin server (empty LeaseURL), the other claims it over HTTP
+type LeaderConfig struct {
+	Enabled    bool   `yaml:"enabled" env:"LEADER_ENABLED" env-default:"false"`
+	ID         string `yaml:"id" env:"LEADER_ID"`              // Defaults to the hostname
+	LeaseURL   string `yaml:"leaseUrl" env:"LEADER_LEASE_URL"` // e.g. http://pi-1:8080/api/lease
+	Token      string `yaml:"token" env:"LEADER_TOKEN"`
+	TTLSeconds int    `yaml:"ttlSeconds" env:"LEADER_TTL_SECONDS" env-default:"30"`
+}
+
+// FleetConfig identifies the device within a balena fleet; the balena supervisor sets the variables
+type FleetConfig struct {
+	Labels     bool   `yaml:"labels" env:"FLEET_LABELS" env-default:"true"`
+	DeviceUUID string `yaml:"deviceUuid" env:"BALENA_DEVICE_UUID"`
+	DeviceName string `yaml:"deviceName" env:"BALENA_DEVICE_NAME_AT_INIT"`
+	AppName    string `yaml:"appName" env:"BALENA_APP_NAME"`
+}
+
+// BLEReceiverName returns the receiver name stamped on BLE readings heard by this device's scanner:
+// the configured name, else the balena device name, else "local"
+func (c *Config) BLEReceiverName() string {
+	if c.BLE.ReceiverName != "" {
+		return c.BLE.ReceiverName
+	}
+	if c.Fleet.DeviceName != "" {
+		return c.Fleet.DeviceName
+	}
+	return "local"
+}
+
+// DeviceID returns the balena device UUID, else the device name; empty outside balena
+func (f FleetConfig) DeviceID() string {
+	if f.DeviceUUID != "" {
+		return f.DeviceUUID
+	}
+	return f.DeviceName
+}
+
+// ExternalLabels returns the device_uuid, device and fleet labels that have a value,
+// or nil when fleet labels are disabled
+func (f FleetConfig) ExternalLabels() map[string]string {
+	if !f.Labels {
+		return nil
+	}
+	labels := make(map[string]string)
+	for name, value := range map[string]string{"device_uuid": f.DeviceUUID, "device": f.DeviceName, "fleet": f.AppName} {
+		if value != "" {
+			labels[name] = value
+		}
+	}
+	return labels
+}
+
+// ConnectivityConfig contains the low-bandwidth profile used while on a metered link, such as an LTE backup
+type ConnectivityConfig struct {
+	Mode                       string   `yaml:"mode" env:"CONNECTIVITY_MODE" env-default:"unmetered"` // auto, metered or unmetered
+	MeteredInterfaces          []string `yaml:"meteredInterfaces" env:"CONNECTIVITY_METERED_INTERFACES" env-separator:"," env-default:"wwan*,wwp*,ppp*"`
+	CheckIntervalSeconds       int      `yaml:"checkIntervalSeconds" env:"CONNECTIVITY_CHECK_INTERVAL" env-default:"30"`
+	MeteredPushIntervalSeconds int      `yaml:"meteredPushIntervalSeconds" env:"CONNECTIVITY_METERED_PUSH_INTERVAL" env-default:"600"`
+	AggregatesOnly             bool     `yaml:"aggregatesOnly" env:"CONNECTIVITY_AGGREGATES_ONLY" env-default:"false"`
+}
+
+// DNSConfig controls how the push and forward clients resolve and connect, so they move to a new
+// address once DNS recovers instead of reusing connections to a dead one
+type DNSConfig struct {
+	CacheEnabled         bool `yaml:"cacheEnabled" env:"DNS_CACHE_ENABLED" env-default:"false"`
+	CacheTTLSeconds      int  `yaml:"cacheTTLSeconds" env:"DNS_CACHE_TTL" env-default:"60"`
+	FallbackDelayMs      int  `yaml:"fallbackDelayMs" env:"DNS_FALLBACK_DELAY_MS" env-default:"300"`         // Happy eyeballs delay before trying the next address
+	RecycleAfterFailures int  `yaml:"recycleAfterFailures" env:"DNS_RECYCLE_AFTER_FAILURES" env-default:"3"` // 0 never recycles connections
+}
+
+// NetworkConfig controls outbound connections of the pushers, scrapers and other HTTP clients
+type NetworkConfig struct {
+	AddressFamily string `yaml:"addressFamily" env:"NETWORK_ADDRESS_FAMILY" env-default:"dual"` // dual, v4 or v6
+}
+
+// HTTPClientConfig contains defaults of the outbound HTTP clients: the pushers, scrapers, the
+// Netatmo client, Loki and the webhooks
+type HTTPClientConfig struct {
+	UserAgent string `yaml:"userAgent" env:"HTTP_USER_AGENT"` // Empty uses balena-home/<service>/<version>
+	ProxyURL  string `yaml:"proxyURL" env:"HTTP_PROXY_URL"`   // Empty uses HTTP_PROXY and HTTPS_PROXY
+}
+
+// SchedulingConfig aligns pushes and scrapes to wall-clock boundaries
+// Pushes land on multiples of the push interval, e.g. :00, :15, :30 and :45 for 15s, and each poller
+// scrapes on multiples of its own interval shifted by ScrapeOffsetSeconds, so readings are fresh at push time
+type SchedulingConfig struct {
+	AlignToWallClock    bool `yaml:"alignToWallClock" env:"ALIGN_TO_WALL_CLOCK" env-default:"false"`
+	ScrapeOffsetSeconds int  `yaml:"scrapeOffsetSeconds" env:"SCRAPE_OFFSET_SECONDS" env-default:"-2"` // Negative scrapes before the boundary
+}
+
+// OccupancyConfig contains configuration for tracking whether someone is home, from a weekly
+// schedule or from locator beacons; while away pollers scrape less often and automation webhooks can be muted
+type OccupancyConfig struct {
+	Enabled               bool                    `yaml:"enabled" env:"OCCUPANCY_ENABLED" env-default:"false"`
+	Source                string                  `yaml:"source" env:"OCCUPANCY_SOURCE" env-default:"schedule"`          // schedule or presence
+	Schedule              []OccupancyPeriodConfig `yaml:"schedule"`                                                      // Periods someone is home
+	AwayAfterSeconds      int                     `yaml:"awayAfterSeconds" env:"OCCUPANCY_AWAY_AFTER" env-default:"600"` // Presence: time without beacons before away
+	AwayScrapeMultiplier  int                     `yaml:"awayScrapeMultiplier" env:"OCCUPANCY_AWAY_SCRAPE_MULTIPLIER" env-default:"4"`
+	MuteAlertsWhenAway    bool                    `yaml:"muteAlertsWhenAway" env:"OCCUPANCY_MUTE_ALERTS_WHEN_AWAY" env-default:"true"`
+	ReportIntervalSeconds int                     `yaml:"reportIntervalSeconds" env:"OCCUPANCY_REPORT_INTERVAL" env-default:"60"`
+}
+
+// OccupancyPeriodConfig is a weekly period during which someone is home
+type OccupancyPeriodConfig struct {
+	Days string `yaml:"days"` // e.g. mon-fri or sat,sun; empty is every day
+	From string `yaml:"from"` // Local HH:MM
+	To   string `yaml:"to"`   // Local HH:MM; not after From ends the next day
+}
+
+// ModeConfig contains configuration for the vacation and maintenance mode switched on the admin server
+type ModeConfig struct {
+	Enabled   bool   `yaml:"enabled" env:"MODE_ENABLED" env-default:"false"`
+	StateFile string `yaml:"stateFile" env:"MODE_STATE_FILE" env-default:"/data/mode.json"`
+	// Netatmo thermostat mode while on vacation: away or hg (frost guard); empty leaves the heating alone
+	VacationHeatingMode string `yaml:"vacationHeatingMode" env:"MODE_VACATION_HEATING_MODE" env-default:"away"`
+}
+
+// FrostProtectionConfig contains the failsafe forcing Netatmo rooms to a safe setpoint when any room
+// falls below a floor, independent of automation rules and of the vacation or maintenance mode
+type FrostProtectionConfig struct {
+	Enabled         bool     `yaml:"enabled" env:"FROST_PROTECTION_ENABLED" env-default:"false"`
+	FloorCelsius    float64  `yaml:"floorCelsius" env:"FROST_PROTECTION_FLOOR" env-default:"7"`
+	SetpointCelsius float64  `yaml:"setpointCelsius" env:"FROST_PROTECTION_SETPOINT" env-default:"12"`
+	HoldMinutes     int      `yaml:"holdMinutes" env:"FROST_PROTECTION_HOLD_MINUTES" env-default:"60"` // Refreshed while it is cold
+	Exclude         []string `yaml:"exclude" env:"FROST_PROTECTION_EXCLUDE" env-separator:","`         // Outdoor sensors and rooms
+}
+
+// RemoteConfigConfig contains the configuration fetched from a remote URL, e.g. a raw file in a
+// git repository, so fleet-wide changes don't need a redeploy; the local file only bootstraps it
+// A new configuration is verified with the checksum and/or signature, validated, cached and
+// applied by a graceful restart, the same as on SIGHUP
+type RemoteConfigConfig struct {
+	Enabled         bool   `yaml:"enabled" env:"REMOTE_CONFIG_ENABLED" env-default:"false"`
+	URL             string `yaml:"url" env:"REMOTE_CONFIG_URL"`
+	Token           string `yaml:"token" env:"REMOTE_CONFIG_TOKEN"`                // Bearer token for private repositories
+	ChecksumURL     string `yaml:"checksumUrl" env:"REMOTE_CONFIG_CHECKSUM_URL"`   // sha256sum output for the file
+	SignatureURL    string `yaml:"signatureUrl" env:"REMOTE_CONFIG_SIGNATURE_URL"` // Defaults to the URL with .sig appended
+	PublicKey       string `yaml:"publicKey" env:"REMOTE_CONFIG_PUBLIC_KEY"`       // Base64 Ed25519 key verifying the signature
+	IntervalSeconds int    `yaml:"intervalSeconds" env:"REMOTE_CONFIG_INTERVAL" env-default:"300"`
+	CacheFile       string `yaml:"cacheFile" env:"REMOTE_CONFIG_CACHE_FILE" env-default:"/data/remote-config.yaml"`
+}
+
+// FeaturesConfig enables experimental features per device during a rollout, e.g. with a balena
+// device variable; flags are listed in the health output and labelled on the build info series
+type FeaturesConfig struct {
+	Enabled []string `yaml:"enabled" env:"FEATURES" env-separator:","`
+}
+
+// FaultInjectionConfig enables the admin endpoints simulating push failures, scrape timeouts,
+// Netatmo rate limits and buffer pressure; meant for rehearsing alerts on a test device
+type FaultInjectionConfig struct {
+	Enabled            bool `yaml:"enabled" env:"FAULT_INJECTION_ENABLED" env-default:"false"`
+	MaxDurationSeconds int  `yaml:"maxDurationSeconds" env:"FAULT_INJECTION_MAX_DURATION" env-default:"3600"` // Longest a fault may be injected for
+}
+
+// RuntimeConfig tunes the Go garbage collector at startup; a preset picks values for a device
+// and the other fields override it, zero keeping the preset or runtime default
+type RuntimeConfig struct {
+	Preset        string `yaml:"preset" env:"RUNTIME_PRESET"`                 // pi-zero or pi4, empty for Go defaults
+	GCPercent     int    `yaml:"gcPercent" env:"RUNTIME_GC_PERCENT"`          // GOGC, -1 disables collection until the memory limit
+	MemoryLimitMB int    `yaml:"memoryLimitMB" env:"RUNTIME_MEMORY_LIMIT_MB"` // GOMEMLIMIT in MiB
+	BallastMB     int    `yaml:"ballastMB" env:"RUNTIME_BALLAST_MB"`          // Untouched allocation raising the heap target
+}
+
+// Settings returns the resolved garbage collector settings
+func (r RuntimeConfig) Settings() (tuning.Settings, error) {
+	return tuning.Resolve(r.Preset, tuning.Settings{
+		GCPercent:        r.GCPercent,
+		MemoryLimitBytes: int64(r.MemoryLimitMB) << 20,
+		BallastBytes:     r.BallastMB << 20,
+	})
+}
+
+// WatchdogConfig restarts the service when the push loop or the loops adding the listed reading
+// types stall: systemd through sd_notify when run with WatchdogSec, a container through the
+// healthcheck command reading the heartbeat file, or anything else by exiting
+type WatchdogConfig struct {
+	Enabled              bool     `yaml:"enabled" env:"WATCHDOG_ENABLED" env-default:"false"`
+	CheckIntervalSeconds int      `yaml:"checkIntervalSeconds" env:"WATCHDOG_CHECK_INTERVAL" env-default:"10"`
+	StallAfterSeconds    int      `yaml:"stallAfterSeconds" env:"WATCHDOG_STALL_AFTER" env-default:"300"`                       // Silence after which a loop counts as hung
+	ReadingTypes         []string `yaml:"readingTypes" env:"WATCHDOG_READING_TYPES" env-separator:"," env-default:"ble"`        // Reading types expected at least every stallAfterSeconds
+	HeartbeatFile        string   `yaml:"heartbeatFile" env:"WATCHDOG_HEARTBEAT_FILE" env-default:"/tmp/home-controller-alive"` // Touched while alive, empty disables
+	ExitOnStall          bool     `yaml:"exitOnStall" env:"WATCHDOG_EXIT_ON_STALL" env-default:"false"`                         // Exit so the restart policy restarts the service
+}
+
+// StartupConfig holds startup until dependencies that come up after the container are available,
+// e.g. Wi-Fi and Bluetooth on a booting Pi, retrying with backoff instead of crash-looping
+type StartupConfig struct {
+	MaxWaitSeconds    int  `yaml:"maxWaitSeconds" env:"STARTUP_MAX_WAIT" env-default:"120"` // 0 starts without waiting
+	MaxBackoffSeconds int  `yaml:"maxBackoffSeconds" env:"STARTUP_MAX_BACKOFF" env-default:"15"`
+	WaitForBLE        bool `yaml:"waitForBle" env:"STARTUP_WAIT_FOR_BLE" env-default:"true"`
+	WaitForDNS        bool `yaml:"waitForDns" env:"STARTUP_WAIT_FOR_DNS" env-default:"true"`                // Resolve the push or forward endpoint
+	WaitForPowerMeter bool `yaml:"waitForPowerMeter" env:"STARTUP_WAIT_FOR_POWER_METER" env-default:"true"` // Scrape the meter when power monitoring is enabled
+	FailOnTimeout     bool `yaml:"failOnTimeout" env:"STARTUP_FAIL_ON_TIMEOUT" env-default:"false"`         // Exit instead of starting without them
+}
+
+// AdminConfig contains configuration for the admin HTTP server
+type AdminConfig struct {
+	Enabled       bool            `yaml:"enabled" env:"ADMIN_ENABLED" env-default:"false"`
+	ListenAddress string          `yaml:"listenAddress" env:"ADMIN_LISTEN_ADDRESS" env-default:":8080"`
+	TLS           AdminTLSConfig  `yaml:"tls" env-prefix:"ADMIN_TLS_"`
+	Auth          AdminAuthConfig `yaml:"auth" env-prefix:"ADMIN_AUTH_"` // Required by every endpoint unless a group overrides it
+
+	// AuthGroups override Auth for endpoints under a path prefix, e.g. /api/v1/write; the longest prefix wins
+	AuthGroups []AdminAuthGroupConfig `yaml:"authGroups"`
+}
+
+// AdminAuthConfig contains the credentials required by admin endpoints
+type AdminAuthConfig struct {
+	Type     string `yaml:"type" env:"TYPE" env-default:"none"` // none, bearer, basic or mtls
+	Token    string `yaml:"token" env:"TOKEN"`                  // Supports ${VAR} environment variable references
+	Username string `yaml:"username" env:"USERNAME"`
+	Password string `yaml:"password" env:"PASSWORD"` // Supports ${VAR} environment variable references
+}
+
+// AdminAuthGroupConfig applies an auth to admin endpoints under a path prefix
+type AdminAuthGroupConfig struct {
+	PathPrefix string          `yaml:"pathPrefix"`
+	Auth       AdminAuthConfig `yaml:"auth"`
+}
+
+// AdminTLSConfig contains TLS settings for the admin server; the client CA enables mtls auth
+type AdminTLSConfig struct {
+	CertFile     string `yaml:"certFile" env:"CERT_FILE"`
+	KeyFile      string `yaml:"keyFile" env:"KEY_FILE"`
+	SelfSigned   bool   `yaml:"selfSigned" env:"SELF_SIGNED" env-default:"false"` // Generate a certificate on start instead of loading one
+	ClientCAFile string `yaml:"clientCaFile" env:"CLIENT_CA_FILE"`
+}
+
+// enabled reports whether the admin server serves HTTPS
+func (t AdminTLSConfig) enabled() bool {
+	return t.CertFile != "" || t.SelfSigned
 }
 
 // PrometheusConfig contains Prometheus metrics push configuration
 type PrometheusConfig struct {
 	PushIntervalSeconds int    `yaml:"pushIntervalSeconds" env:"PUSH_INTERVAL_SECONDS" env-default:"15"`
-	URL                 string `yaml:"prometheusUrl" env:"PROMETHEUS_URL" env-required:"true"`
-	Username            string `yaml:"prometheusUsername" env:"PROMETHEUS_USERNAME" env-required:"true"`
+	URL                 string `yaml:"prometheusUrl" env:"PROMETHEUS_URL"`           // Required unless forwarding
+	Username            string `yaml:"prometheusUsername" env:"PROMETHEUS_USERNAME"` // Required unless forwarding
 	Password            string `yaml:"prometheusPassword" env:"PROMETHEUS_PASSWORD"`
 	StartAtEvenSecond   bool   `yaml:"startAtEvenSecond" env:"START_AT_EVEN_SECOND" env-default:"true"`
 	BufferSize          int    `yaml:"bufferSize" env:"BUFFER_SIZE" env-default:"1000"`
 	BatchSize           int    `yaml:"batchSize" env:"BATCH_SIZE" env-default:"1000"`
+	TenantID            string `yaml:"tenantId" env:"PROMETHEUS_TENANT_ID"`
+	Protocol            string `yaml:"protocol" env:"PROMETHEUS_PROTOCOL" env-default:"remote_write"`
+
+	// Samples older than MaxSampleAgeSeconds are dropped, or with RetimestampOldSamples the newest
+	// one per series is pushed at the age limit; 0 disables
+	MaxSampleAgeSeconds   int  `yaml:"maxSampleAgeSeconds" env:"PROMETHEUS_MAX_SAMPLE_AGE" env-default:"0"`
+	RetimestampOldSamples bool `yaml:"retimestampOldSamples" env:"PROMETHEUS_RETIMESTAMP_OLD_SAMPLES" env-default:"false"`
+
+	// Samples with the same series and timestamp as one pushed within DedupWindowSeconds are dropped; 0 disables
+	DedupWindowSeconds int `yaml:"dedupWindowSeconds" env:"PROMETHEUS_DEDUP_WINDOW" env-default:"0"`
+
+	// Samples of the CoalesceMetrics repeating the last pushed value of their series are skipped until
+	// CoalesceMaxStalenessSeconds passed since it; keep the bound under 5 minutes so the series doesn't go stale
+	CoalesceMetrics             []string `yaml:"coalesceMetrics" env:"PROMETHEUS_COALESCE_METRICS" env-separator:","`
+	CoalesceMaxStalenessSeconds int      `yaml:"coalesceMaxStalenessSeconds" env:"PROMETHEUS_COALESCE_MAX_STALENESS" env-default:"240"`
+
+	// New series of a metric name beyond MaxSeriesPerMetric are dropped, protecting the endpoint against
+	// label explosions; SeriesLimitOverrides sets the limit per metric name, 0 disables
+	MaxSeriesPerMetric   int            `yaml:"maxSeriesPerMetric" env:"PROMETHEUS_MAX_SERIES_PER_METRIC" env-default:"0"`
+	SeriesLimitOverrides map[string]int `yaml:"seriesLimitOverrides"`
+
+	// Filling the buffer to PushWatermarkPercent of BufferSize triggers an early push, bounding data
+	// loss with long push intervals; 0 disables
+	PushWatermarkPercent int `yaml:"pushWatermarkPercent" env:"PUSH_WATERMARK_PERCENT" env-default:"0"`
+
+	// While pushes fail, the time between scheduled pushes doubles up to PushMaxBackoffSeconds and
+	// returns to PushIntervalSeconds after a success; 0 keeps the push interval
+	PushMaxBackoffSeconds int `yaml:"pushMaxBackoffSeconds" env:"PUSH_MAX_BACKOFF_SECONDS" env-default:"300"`
+
+	// Up to MaxIdleConns connections per endpoint stay open for IdleConnTimeoutSeconds between
+	// pushes, and TLS sessions are resumed, so pushes don't pay for a full handshake each time;
+	// 0 keeps Go's default of 2 connections and 90 seconds
+	MaxIdleConns           int `yaml:"maxIdleConns" env:"PUSH_MAX_IDLE_CONNS" env-default:"2"`
+	IdleConnTimeoutSeconds int `yaml:"idleConnTimeoutSeconds" env:"PUSH_IDLE_CONN_TIMEOUT" env-default:"90"`
+
+	// Every push attempt of the last PushLogRetentionDays is kept in the file at PushLogPath; empty disables
+	PushLogPath          string `yaml:"pushLogPath" env:"PUSH_LOG_PATH"`
+	PushLogRetentionDays int    `yaml:"pushLogRetentionDays" env:"PUSH_LOG_RETENTION_DAYS" env-default:"7"`
+
+	// Every push carries X-Collector-* headers with the collector version, the device, a batch ID
+	// shared by its retries and the reading counts per type, to debug ingestion server-side
+	ProvenanceHeaders bool `yaml:"provenanceHeaders" env:"PROMETHEUS_PROVENANCE_HEADERS" env-default:"true"`
+
+	// TenantOverrides maps reading types (ble, power, ...) to the tenant they are pushed to
+	TenantOverrides map[string]string `yaml:"tenantOverrides"`
+
+	// AdditionalEndpoints receive the same readings as the primary endpoint, each with its own queue and retries
+	AdditionalEndpoints []RemoteWriteEndpointConfig `yaml:"additionalEndpoints"`
+}
+
+// RemoteWriteEndpointConfig contains an additional remote_write endpoint
+type RemoteWriteEndpointConfig struct {
+	Name       string `yaml:"name"`
+	URL        string `yaml:"url"`
+	Username   string `yaml:"username"`
+	Password   string `yaml:"password"` // Supports ${VAR} environment variable references
+	TenantID   string `yaml:"tenantId"`
+	BufferSize int    `yaml:"bufferSize"` // Queue size, defaults to the primary buffer size
+	Protocol   string `yaml:"protocol"`   // remote_write (default) or vm_import
+
+	MaxSampleAgeSeconds   int  `yaml:"maxSampleAgeSeconds"`
+	RetimestampOldSamples bool `yaml:"retimestampOldSamples"`
+	DedupWindowSeconds    int  `yaml:"dedupWindowSeconds"`
 }
 
 // LoggingConfig contains logging configuration
 type LoggingConfig struct {
-	Format string `yaml:"logFormat" env:"LOG_FORMAT" env-default:"console"`
-	Level  string `yaml:"logLevel" env:"LOG_LEVEL" env-default:"info"`
+	Format string     `yaml:"logFormat" env:"LOG_FORMAT" env-default:"console"`
+	Level  string     `yaml:"logLevel" env:"LOG_LEVEL" env-default:"info"`
+	Loki   LokiConfig `yaml:"loki" env-prefix:"LOKI_"`
+}
+
+// LokiConfig contains settings for shipping log entries to Loki
+type LokiConfig struct {
+	Enabled              bool   `yaml:"enabled" env:"ENABLED" env-default:"false"`
+	URL                  string `yaml:"url" env:"URL"`
+	Username             string `yaml:"username" env:"USERNAME"`
+	Password             string `yaml:"password" env:"PASSWORD"`
+	Level                string `yaml:"level" env:"LEVEL" env-default:"warn"`
+	Service              string `yaml:"service" env:"SERVICE" env-default:"home-controller"`
+	Device               string `yaml:"device" env:"DEVICE,BALENA_DEVICE_NAME_AT_INIT"`
+	BatchSize            int    `yaml:"batchSize" env:"BATCH_SIZE" env-default:"100"`
+	QueueSize            int    `yaml:"queueSize" env:"QUEUE_SIZE" env-default:"1000"`
+	FlushIntervalSeconds int    `yaml:"flushIntervalSeconds" env:"FLUSH_INTERVAL" env-default:"10"`
 }
 
 var macAddressRegex = regexp.MustCompile(`^([0-9A-Fa-f]{2}:){5}[0-9A-Fa-f]{2}$`)
 
+var oneWireDeviceIDRegex = regexp.MustCompile(`^[0-9a-f]{2}-[0-9a-f]{12}$`)
+
+var metricNameRegex = regexp.MustCompile(`^[a-z][a-z0-9_]*$`)
+
 // Load loads configuration from a YAML file with environment variable overrides
 func Load(configPath string) (*Config, error) {
 	var cfg Config
@@ -92,8 +1022,9 @@ func (c *Config) Validate() error {
 		return fmt.Errorf("at least one sensor must be configured")
 	}
 
-	// Track unique IDs and MACs
+	// Track unique IDs, names and MACs; a shared label would merge the sensors' series
 	seenIDs := make(map[int]bool)
+	seenNames := make(map[string]bool)
 	seenMACs := make(map[string]bool)
 
 	for i, sensor := range c.BLE.Sensors {
@@ -101,6 +1032,10 @@ func (c *Config) Validate() error {
 		if sensor.Name == "" {
 			return fmt.Errorf("sensor %d: name is required", i)
 		}
+		if seenNames[sensor.Name] {
+			return fmt.Errorf("sensor %s: duplicate name", sensor.Name)
+		}
+		seenNames[sensor.Name] = true
 
 		// Validate ID
 		if sensor.ID < 1 {
@@ -122,6 +1057,27 @@ func (c *Config) Validate() error {
 		seenMACs[macUpper] = true
 	}
 
+	// Validate the BLE backlog sanitizer
+	c.BLE.Backlog.Mode = strings.ToLower(c.BLE.Backlog.Mode)
+	switch c.BLE.Backlog.Mode {
+	case "":
+		c.BLE.Backlog.Mode = "off"
+	case "off":
+	case "spread", "drop":
+		// The heartbeat beats every second, so a shorter gap would be taken for a hang
+		if c.BLE.Backlog.GapSeconds < 2 {
+			return fmt.Errorf("BLE backlog gap must be at least 2 seconds, got %d", c.BLE.Backlog.GapSeconds)
+		}
+		if c.BLE.Backlog.SettleSeconds < 1 {
+			return fmt.Errorf("BLE backlog settle period must be at least 1 second, got %d", c.BLE.Backlog.SettleSeconds)
+		}
+		if c.BLE.Backlog.Mode == "drop" && c.BLE.Backlog.MaxAgeSeconds < 1 {
+			return fmt.Errorf("BLE backlog max age must be at least 1 second, got %d", c.BLE.Backlog.MaxAgeSeconds)
+		}
+	default:
+		return fmt.Errorf("BLE backlog mode must be 'off', 'spread' or 'drop', got: %s", c.BLE.Backlog.Mode)
+	}
+
 	// Validate Netatmo configuration if enabled
 	if c.Netatmo.Enabled {
 		if c.Netatmo.ClientID == "" {
@@ -136,6 +1092,25 @@ func (c *Config) Validate() error {
 		if c.Netatmo.FetchInterval < 1 {
 			return fmt.Errorf("netatmo fetch interval must be at least 1 second")
 		}
+		c.Netatmo.TimestampSource = strings.ToLower(c.Netatmo.TimestampSource)
+		switch c.Netatmo.TimestampSource {
+		case "":
+			c.Netatmo.TimestampSource = "local"
+		case "local", "server", "module":
+		default:
+			return fmt.Errorf("netatmo timestamp source must be 'local', 'server' or 'module', got: %s", c.Netatmo.TimestampSource)
+		}
+		if c.Netatmo.Backfill.Enabled {
+			if c.Netatmo.Backfill.StateFile == "" {
+				return fmt.Errorf("netatmo backfill state file is required when the backfill is enabled")
+			}
+			if c.Netatmo.Backfill.WindowSeconds < 1 {
+				return fmt.Errorf("netatmo backfill window must be at least 1 second")
+			}
+			if c.Forward.Enabled {
+				return fmt.Errorf("netatmo backfill tracks pushes of this device and can't be used while forwarding")
+			}
+		}
 	}
 
 	// Validate Power configuration if enabled
@@ -149,45 +1124,1305 @@ func (c *Config) Validate() error {
 		if c.Power.ScrapeTimeoutSeconds <= 0 {
 			return fmt.Errorf("power scrape timeout must be positive")
 		}
+		if c.Power.StaleRepeatIntervals < 0 {
+			return fmt.Errorf("power stale repeat intervals must not be negative")
+		}
+		c.Power.Auth.Type = strings.ToLower(c.Power.Auth.Type)
+		if err := httpauth.Config(c.Power.Auth).Validate(); err != nil {
+			return fmt.Errorf("power auth: %w", err)
+		}
 	}
 
-	// Validate Prometheus URL
-	if c.Prometheus.URL == "" {
-		return fmt.Errorf("prometheus URL is required")
+	// Validate Pstryk reconciliation configuration if enabled
+	if c.Pstryk.Enabled {
+		if !c.Power.Enabled {
+			return fmt.Errorf("pstryk reconciliation requires power monitoring to be enabled")
+		}
+		if c.Pstryk.Token == "" {
+			return fmt.Errorf("pstryk token is required when reconciliation is enabled")
+		}
+		if c.Pstryk.IntervalSeconds < 60 {
+			return fmt.Errorf("pstryk interval must be at least 60 seconds")
+		}
+		if c.Pstryk.LookbackHours < 1 {
+			return fmt.Errorf("pstryk lookback must be at least 1 hour")
+		}
+		if c.Pstryk.DriftAlertPercent <= 0 {
+			return fmt.Errorf("pstryk drift alert percent must be positive")
+		}
 	}
 
-	if c.Prometheus.Username == "" {
-		return fmt.Errorf("prometheus username is required")
+	// Validate electricity price configuration if enabled
+	if c.Prices.Enabled {
+		c.Prices.Source = strings.ToLower(c.Prices.Source)
+		switch c.Prices.Source {
+		case "pse":
+		case "entsoe":
+			if c.Prices.EntsoeToken == "" {
+				return fmt.Errorf("ENTSO-E token is required for the entsoe price source")
+			}
+			if c.Prices.EntsoeArea == "" {
+				return fmt.Errorf("ENTSO-E area is required for the entsoe price source")
+			}
+			if c.Prices.EURPLNRate <= 0 {
+				return fmt.Errorf("EUR/PLN rate must be positive")
+			}
+		default:
+			return fmt.Errorf("invalid price source: %s (must be pse or entsoe)", c.Prices.Source)
+		}
+		if c.Prices.FetchIntervalSeconds < 60 {
+			return fmt.Errorf("price fetch interval must be at least 60 seconds")
+		}
+		if c.Prices.ReportIntervalSeconds < 1 {
+			return fmt.Errorf("price report interval must be at least 1 second")
+		}
+		if c.Prices.HorizonHours < 0 || c.Prices.HorizonHours > 47 {
+			return fmt.Errorf("price horizon must be between 0 and 47 hours")
+		}
 	}
 
-	// Validate push interval
-	if c.Prometheus.PushIntervalSeconds < 1 {
-		return fmt.Errorf("push interval must be at least 1 second")
+	// Validate HeatPump configuration if enabled
+	if c.HeatPump.Enabled {
+		if err := c.HeatPump.validate(); err != nil {
+			return err
+		}
 	}
 
-	// Validate buffer size
-	if c.Prometheus.BufferSize < 1 {
-		return fmt.Errorf("buffer size must be at least 1")
+	// Validate Water configuration if enabled
+	if c.Water.Enabled {
+		if c.Water.GPIOPin < 0 {
+			return fmt.Errorf("water GPIO pin must not be negative")
+		}
+		if c.Water.LitersPerPulse <= 0 {
+			return fmt.Errorf("water liters per pulse must be positive")
+		}
+		if c.Water.DebounceMs < 0 {
+			return fmt.Errorf("water debounce must not be negative")
+		}
+		if c.Water.SampleIntervalMs < 1 {
+			return fmt.Errorf("water sample interval must be at least 1 millisecond")
+		}
+		if c.Water.DebounceMs > 0 && c.Water.SampleIntervalMs > c.Water.DebounceMs {
+			return fmt.Errorf("water sample interval (%dms) must not exceed debounce (%dms)", c.Water.SampleIntervalMs, c.Water.DebounceMs)
+		}
+		if c.Water.ReportIntervalSeconds < 1 {
+			return fmt.Errorf("water report interval must be at least 1 second")
+		}
+		if c.Water.StateFile == "" {
+			return fmt.Errorf("water state file is required when water metering is enabled")
+		}
 	}
 
-	// Validate batch size
-	if c.Prometheus.BatchSize < 1 {
-		return fmt.Errorf("batch size must be at least 1")
+	// Validate OneWire configuration if enabled
+	if c.OneWire.Enabled {
+		if err := c.OneWire.validate(); err != nil {
+			return err
+		}
 	}
 
-	// Validate log format
-	c.Logging.Format = strings.ToLower(c.Logging.Format)
-	if c.Logging.Format != "console" && c.Logging.Format != "json" && c.Logging.Format != "logfmt" {
-		return fmt.Errorf("log format must be 'console', 'json', or 'logfmt', got: %s", c.Logging.Format)
+	// Validate I2C configuration if enabled
+	if c.I2C.Enabled {
+		if err := c.I2C.validate(); err != nil {
+			return err
+		}
 	}
 
-	// Validate log level
+	// Validate the collectors list; names and options are checked against the registry on startup
+	seenCollectors := c.legacyCollectors()
+	for i, collector := range c.Collectors {
+		if collector.Name == "" {
+			return fmt.Errorf("collector %d: name is required", i)
+		}
+		if seenCollectors[collector.Name] {
+			return fmt.Errorf("collector %s: listed twice or also enabled in its own section", collector.Name)
+		}
+		seenCollectors[collector.Name] = true
+		if collector.IntervalSeconds < 1 {
+			return fmt.Errorf("collector %s: interval must be at least 1 second", collector.Name)
+		}
+	}
+
+	// Validate AirQuality configuration if enabled
+	if c.AirQuality.Enabled {
+		if err := c.AirQuality.validate(); err != nil {
+			return err
+		}
+	}
+
+	// Validate Zigbee2MQTT configuration if enabled
+	if c.Zigbee2MQTT.Enabled {
+		if c.Zigbee2MQTT.BaseTopic == "" {
+			return fmt.Errorf("zigbee2mqtt base topic is required when Zigbee2MQTT is enabled")
+		}
+		if err := c.Zigbee2MQTT.MQTT.validate(); err != nil {
+			return fmt.Errorf("zigbee2mqtt: %w", err)
+		}
+	}
+
+	// Validate BLE proxy configuration if enabled
+	if c.BLEProxy.Enabled {
+		if c.BLEProxy.MQTT.Broker == "" && !c.Admin.Enabled {
+			return fmt.Errorf("BLE proxy requires an MQTT broker or the admin server to be enabled")
+		}
+		if c.BLEProxy.MQTT.Broker != "" {
+			if c.BLEProxy.BaseTopic == "" {
+				return fmt.Errorf("BLE proxy base topic is required when using MQTT")
+			}
+			if err := c.BLEProxy.MQTT.validate(); err != nil {
+				return fmt.Errorf("BLE proxy: %w", err)
+			}
+			// A broker disconnects the older session when a client ID is reused
+			if c.Zigbee2MQTT.Enabled && c.Zigbee2MQTT.MQTT.Broker == c.BLEProxy.MQTT.Broker && c.Zigbee2MQTT.MQTT.ClientID == c.BLEProxy.MQTT.ClientID {
+				return fmt.Errorf("BLE proxy MQTT client ID must differ from the Zigbee2MQTT client ID on the same broker")
+			}
+		}
+	}
+
+	// Validate room fusion configuration if enabled
+	if c.RoomFusion.Enabled {
+		if err := c.RoomFusion.validate(); err != nil {
+			return fmt.Errorf("room fusion: %w", err)
+		}
+	}
+
+	// Validate Events configuration
+	if c.Events.Capacity == 0 {
+		c.Events.Capacity = 500
+	}
+	if c.Events.Capacity < 1 {
+		return fmt.Errorf("events capacity must be at least 1")
+	}
+	if c.Events.IPCheckIntervalSeconds < 0 {
+		return fmt.Errorf("events IP check interval must not be negative")
+	}
+	if c.Events.GrafanaAnnotations.Enabled {
+		if c.Events.GrafanaAnnotations.URL == "" {
+			return fmt.Errorf("grafana URL is required when event annotations are enabled")
+		}
+		if c.Events.GrafanaAnnotations.Token == "" {
+			return fmt.Errorf("grafana token is required when event annotations are enabled")
+		}
+	}
+	if c.Events.Loki.Enabled && c.Logging.Loki.URL == "" {
+		return fmt.Errorf("logging loki URL is required when events are pushed to Loki")
+	}
+
+	if c.IdentityCheck.Enabled && c.IdentityCheck.ReportIntervalSeconds < 1 {
+		return fmt.Errorf("identity check report interval must be at least 1 second")
+	}
+
+	// Validate battery estimation if enabled
+	if c.BatteryEstimate.Enabled {
+		if c.BatteryEstimate.StateFile == "" {
+			return fmt.Errorf("battery estimate state file is required when battery estimation is enabled")
+		}
+		if c.BatteryEstimate.WindowDays < 3 {
+			return fmt.Errorf("battery estimate window must be at least 3 days")
+		}
+		if c.BatteryEstimate.AlertDays < 0 {
+			return fmt.Errorf("battery estimate alert days must not be negative")
+		}
+		if c.BatteryEstimate.ReportIntervalSeconds < 1 {
+			return fmt.Errorf("battery estimate report interval must be at least 1 second")
+		}
+	}
+
+	// Validate locator configuration if enabled
+	if c.Locator.Enabled {
+		if c.Locator.ReportIntervalSeconds < 1 {
+			return fmt.Errorf("locator report interval must be at least 1 second")
+		}
+		if c.Locator.StaleSeconds < 1 {
+			return fmt.Errorf("locator stale seconds must be at least 1")
+		}
+		if c.Locator.HysteresisDB < 0 {
+			return fmt.Errorf("locator hysteresis must not be negative")
+		}
+		seenBeacons := make(map[string]bool)
+		for i, beacon := range c.Locator.Beacons {
+			if beacon.Name == "" {
+				return fmt.Errorf("locator beacon %d: name is required", i)
+			}
+			if !macAddressRegex.MatchString(beacon.MACAddress) {
+				return fmt.Errorf("locator beacon %s: invalid MAC address format: %s (expected format: XX:XX:XX:XX:XX:XX)", beacon.Name, beacon.MACAddress)
+			}
+			mac := strings.ToUpper(beacon.MACAddress)
+			if seenMACs[mac] || seenBeacons[mac] {
+				return fmt.Errorf("locator beacon %s: duplicate MAC address %s", beacon.Name, beacon.MACAddress)
+			}
+			seenBeacons[mac] = true
+		}
+	}
+
+	if err := c.Notify.validate(); err != nil {
+		return err
+	}
+
+	// Validate the daily report if enabled
+	if c.Report.Enabled {
+		if _, err := time.Parse("15:04", c.Report.SendAt); err != nil {
+			return fmt.Errorf("report send time must be in HH:MM format, got: %s", c.Report.SendAt)
+		}
+		if c.Report.EnergyPriceKWh < 0 {
+			return fmt.Errorf("report energy price must not be negative")
+		}
+		if !c.Notify.Enabled() {
+			return fmt.Errorf("at least one notification channel is required when the daily report is enabled")
+		}
+	}
+
+	// Validate local history if enabled
+	if c.History.Enabled {
+		c.History.Backend = strings.ToLower(c.History.Backend)
+		switch c.History.Backend {
+		case "":
+			c.History.Backend = "files"
+		case "files", "sqlite":
+		default:
+			return fmt.Errorf("history backend must be 'files' or 'sqlite', got: %s", c.History.Backend)
+		}
+		if c.History.Dir == "" {
+			return fmt.Errorf("history directory is required when history is enabled")
+		}
+		if c.History.RetentionDays < 1 {
+			return fmt.Errorf("history retention must be at least 1 day")
+		}
+		if c.History.FlushIntervalSeconds < 1 {
+			return fmt.Errorf("history flush interval must be at least 1 second")
+		}
+	}
+
+	// Validate Telemetry configuration if enabled
+	if (c.Telemetry.DependencyMetrics || c.Telemetry.HTTPServerMetrics) && c.Telemetry.ReportIntervalSeconds < 1 {
+		return fmt.Errorf("telemetry report interval must be at least 1 second")
+	}
+	if c.Telemetry.ConnectionMetrics && !c.Telemetry.DependencyMetrics {
+		return fmt.Errorf("telemetry connection metrics require dependency metrics to be enabled")
+	}
+	// Validate connectivity configuration
+	c.Connectivity.Mode = strings.ToLower(c.Connectivity.Mode)
+	switch c.Connectivity.Mode {
+	case "":
+		c.Connectivity.Mode = "unmetered"
+	case "auto", "metered", "unmetered":
+	default:
+		return fmt.Errorf("connectivity mode must be 'auto', 'metered' or 'unmetered', got: %s", c.Connectivity.Mode)
+	}
+	if c.Connectivity.Mode != "unmetered" {
+		if c.Connectivity.Mode == "auto" && len(c.Connectivity.MeteredInterfaces) == 0 {
+			return fmt.Errorf("connectivity auto mode requires at least one metered interface pattern")
+		}
+		if c.Connectivity.Mode == "auto" && c.Connectivity.CheckIntervalSeconds < 1 {
+			return fmt.Errorf("connectivity check interval must be at least 1 second")
+		}
+		if c.Connectivity.MeteredPushIntervalSeconds < c.Prometheus.PushIntervalSeconds {
+			return fmt.Errorf("metered push interval must be at least the push interval of %d seconds", c.Prometheus.PushIntervalSeconds)
+		}
+		if c.Connectivity.AggregatesOnly && !c.Summary.Enabled {
+			return fmt.Errorf("aggregates only on metered links requires summaries to be enabled")
+		}
+	}
+
+	c.Network.AddressFamily = strings.ToLower(c.Network.AddressFamily)
+	if c.Network.AddressFamily == "" {
+		c.Network.AddressFamily = network.Dual
+	}
+	if err := network.Validate(c.Network.AddressFamily); err != nil {
+		return err
+	}
+
+	if c.HTTPClient.ProxyURL != "" {
+		if err := httpclient.ValidateProxy(c.HTTPClient.ProxyURL); err != nil {
+			return err
+		}
+	}
+
+	// Validate DNS caching if enabled
+	if c.DNS.CacheEnabled {
+		if c.DNS.CacheTTLSeconds < 1 {
+			return fmt.Errorf("DNS cache TTL must be at least 1 second")
+		}
+		if c.DNS.FallbackDelayMs < 1 {
+			return fmt.Errorf("DNS fallback delay must be at least 1 millisecond")
+		}
+		if c.DNS.RecycleAfterFailures < 0 {
+			return fmt.Errorf("DNS recycle after failures must not be negative")
+		}
+	}
+
+	if c.Scheduling.AlignToWallClock && (c.Scheduling.ScrapeOffsetSeconds <= -60 || c.Scheduling.ScrapeOffsetSeconds >= 60) {
+		return fmt.Errorf("scrape offset must be within a minute of the boundary, got: %d seconds", c.Scheduling.ScrapeOffsetSeconds)
+	}
+
+	// Validate the mode switch if enabled
+	if c.Mode.Enabled {
+		if !c.Admin.Enabled {
+			return fmt.Errorf("mode switch requires the admin server to be enabled")
+		}
+		if c.Mode.StateFile == "" {
+			return fmt.Errorf("mode state file is required when the mode switch is enabled")
+		}
+		switch c.Mode.VacationHeatingMode {
+		case "", "away", "hg":
+		default:
+			return fmt.Errorf("vacation heating mode must be 'away', 'hg' or empty, got: %s", c.Mode.VacationHeatingMode)
+		}
+	}
+
+	// Validate frost protection if enabled
+	if c.FrostProtection.Enabled {
+		if !c.Netatmo.Enabled {
+			return fmt.Errorf("frost protection requires the Netatmo integration to force setpoints")
+		}
+		// Netatmo accepts manual setpoints between 7 and 30°C
+		if c.FrostProtection.SetpointCelsius < 7 || c.FrostProtection.SetpointCelsius > 30 {
+			return fmt.Errorf("frost protection setpoint must be between 7 and 30°C, got: %.1f", c.FrostProtection.SetpointCelsius)
+		}
+		if c.FrostProtection.FloorCelsius >= c.FrostProtection.SetpointCelsius {
+			return fmt.Errorf("frost protection floor must be below the setpoint")
+		}
+		if c.FrostProtection.HoldMinutes < 2 {
+			return fmt.Errorf("frost protection hold must be at least 2 minutes")
+		}
+	}
+
+	// Validate remote configuration if enabled
+	if c.RemoteConfig.Enabled {
+		if c.RemoteConfig.URL == "" {
+			return fmt.Errorf("remote config URL is required when remote config is enabled")
+		}
+		if c.RemoteConfig.ChecksumURL == "" && c.RemoteConfig.PublicKey == "" {
+			return fmt.Errorf("remote config requires a checksum URL or a public key to verify the configuration")
+		}
+		if c.RemoteConfig.PublicKey != "" {
+			if _, err := remoteconfig.ParsePublicKey(c.RemoteConfig.PublicKey); err != nil {
+				return fmt.Errorf("remote config: %w", err)
+			}
+		}
+		if c.RemoteConfig.IntervalSeconds < 30 {
+			return fmt.Errorf("remote config interval must be at least 30 seconds")
+		}
+		if c.RemoteConfig.CacheFile == "" {
+			return fmt.Errorf("remote config cache file is required when remote config is enabled")
+		}
+	}
+
+	// Validate feature flags
+	if _, err := features.Parse(c.Features.Enabled); err != nil {
+		return err
+	}
+
+	// Validate runtime tuning
+	runtimeSettings, err := c.Runtime.Settings()
+	if err != nil {
+		return err
+	}
+	if c.Runtime.GCPercent < -1 {
+		return fmt.Errorf("runtime GC percent must be -1 (off) or positive")
+	}
+	if c.Runtime.MemoryLimitMB < 0 || c.Runtime.BallastMB < 0 {
+		return fmt.Errorf("runtime memory limit and ballast must not be negative")
+	}
+	if runtimeSettings.GCPercent == -1 && runtimeSettings.MemoryLimitBytes == 0 {
+		return fmt.Errorf("runtime GC percent -1 requires a memory limit, otherwise the heap grows unbounded")
+	}
+
+	// Validate startup gating
+	if c.Startup.MaxWaitSeconds < 0 {
+		return fmt.Errorf("startup max wait must not be negative")
+	}
+	if c.Startup.MaxWaitSeconds > 0 && c.Startup.MaxBackoffSeconds < 1 {
+		return fmt.Errorf("startup max backoff must be at least 1 second")
+	}
+
+	// Validate fault injection if enabled
+	if c.FaultInjection.Enabled {
+		if !c.Admin.Enabled {
+			return fmt.Errorf("fault injection requires the admin server to be enabled")
+		}
+		if c.FaultInjection.MaxDurationSeconds < 1 {
+			return fmt.Errorf("fault injection max duration must be at least 1 second")
+		}
+	}
+
+	// Validate occupancy tracking if enabled
+	if c.Occupancy.Enabled {
+		switch c.Occupancy.Source {
+		case occupancy.SourceSchedule:
+			if len(c.Occupancy.Schedule) == 0 {
+				return fmt.Errorf("occupancy schedule needs at least one period")
+			}
+			for i, period := range c.Occupancy.Schedule {
+				if err := (occupancy.Period{Days: period.Days, From: period.From, To: period.To}).Validate(); err != nil {
+					return fmt.Errorf("occupancy schedule period %d: %w", i, err)
+				}
+			}
+		case occupancy.SourcePresence:
+			if !c.Locator.Enabled || len(c.Locator.Beacons) == 0 {
+				return fmt.Errorf("occupancy presence source requires the locator with at least one beacon")
+			}
+			if c.Occupancy.AwayAfterSeconds < 0 {
+				return fmt.Errorf("occupancy away after must not be negative")
+			}
+		default:
+			return fmt.Errorf("occupancy source must be 'schedule' or 'presence', got: %s", c.Occupancy.Source)
+		}
+		if c.Occupancy.AwayScrapeMultiplier < 1 {
+			return fmt.Errorf("occupancy away scrape multiplier must be at least 1")
+		}
+		if c.Occupancy.ReportIntervalSeconds < 1 {
+			return fmt.Errorf("occupancy report interval must be at least 1 second")
+		}
+	}
+
+	if c.Telemetry.HTTPServerMetrics && !c.Admin.Enabled {
+		return fmt.Errorf("HTTP server metrics require the admin server to be enabled")
+	}
+
+	// Validate Automation configuration
+	if c.Automation.LoadShedding.Enabled && !c.Power.Enabled {
+		return fmt.Errorf("load shedding requires power monitoring to be enabled")
+	}
+	if c.Automation.PriceRules.Enabled && !c.Prices.Enabled {
+		return fmt.Errorf("price rules require electricity prices to be enabled")
+	}
+	if c.Automation.LoadShedding.Enabled || c.Automation.Expressions.Enabled || c.Automation.PriceRules.Enabled || c.Automation.Ventilation.Enabled {
+		if err := c.Automation.validate(); err != nil {
+			return err
+		}
+	}
+
+	// Validate Admin configuration if enabled
+	if c.Admin.Enabled && c.Admin.ListenAddress == "" {
+		return fmt.Errorf("admin listen address is required when admin server is enabled")
+	}
+	if c.Admin.Enabled {
+		if (c.Admin.TLS.CertFile == "") != (c.Admin.TLS.KeyFile == "") {
+			return fmt.Errorf("admin TLS requires both a certificate and a key file")
+		}
+		if c.Admin.TLS.SelfSigned && c.Admin.TLS.CertFile != "" {
+			return fmt.Errorf("admin TLS takes either certificate files or a self-signed certificate, not both")
+		}
+		if c.Admin.TLS.ClientCAFile != "" && !c.Admin.TLS.enabled() {
+			return fmt.Errorf("admin client CA requires TLS to be enabled")
+		}
+		if err := c.Admin.Auth.validate(c.Admin.TLS); err != nil {
+			return fmt.Errorf("admin auth: %w", err)
+		}
+		for i := range c.Admin.AuthGroups {
+			group := &c.Admin.AuthGroups[i]
+			if !strings.HasPrefix(group.PathPrefix, "/") {
+				return fmt.Errorf("admin auth group %d: path prefix must start with /", i)
+			}
+			if err := group.Auth.validate(c.Admin.TLS); err != nil {
+				return fmt.Errorf("admin auth group %s: %w", group.PathPrefix, err)
+			}
+		}
+	}
+
+	// Validate ingestion from satellite instances
+	if c.Ingest.Enabled && !c.Admin.Enabled {
+		return fmt.Errorf("ingest requires the admin server to be enabled")
+	}
+
+	// Validate leader election; only the leader pushes, so it doesn't apply to forwarding satellites
+	if c.Leader.Enabled {
+		if c.Forward.Enabled {
+			return fmt.Errorf("leader election cannot be combined with forwarding")
+		}
+		if c.Leader.LeaseURL == "" && !c.Admin.Enabled {
+			return fmt.Errorf("leader election requires a lease URL or the admin server to host the lease")
+		}
+		if c.Leader.TTLSeconds < 3 {
+			return fmt.Errorf("leader TTL must be at least 3 seconds")
+		}
+	}
+
+	// Validate the remote_write receiver
+	if c.RemoteWrite.Enabled && !c.Admin.Enabled {
+		return fmt.Errorf("remote_write receiver requires the admin server to be enabled")
+	}
+
+	// Validate the Pushgateway receiver
+	if c.Pushgateway.Enabled && !c.Admin.Enabled {
+		return fmt.Errorf("pushgateway receiver requires the admin server to be enabled")
+	}
+
+	if c.NATS.Enabled {
+		if c.NATS.Server == "" {
+			return fmt.Errorf("NATS server is required when NATS publishing is enabled")
+		}
+		if c.NATS.SubjectPrefix == "" || strings.ContainsAny(c.NATS.SubjectPrefix, " \t*>") {
+			return fmt.Errorf("NATS subject prefix must be a non-empty subject without wildcards, got: %q", c.NATS.SubjectPrefix)
+		}
+		if c.NATS.Encoding != "json" && c.NATS.Encoding != "protobuf" {
+			return fmt.Errorf("NATS encoding must be 'json' or 'protobuf', got: %s", c.NATS.Encoding)
+		}
+		if c.NATS.AckTimeoutSeconds < 1 {
+			return fmt.Errorf("NATS ack timeout must be at least 1 second")
+		}
+		if c.NATS.IntervalSeconds < 1 {
+			return fmt.Errorf("NATS interval must be at least 1 second")
+		}
+		if c.NATS.QueueSize < 1 {
+			return fmt.Errorf("NATS queue size must be at least 1")
+		}
+	}
+
+	// Validate forwarding to a main instance; satellites don't push to Prometheus
+	if c.Forward.Enabled {
+		if c.Forward.URL == "" {
+			return fmt.Errorf("forward URL is required when forwarding is enabled")
+		}
+		if c.Forward.IntervalSeconds < 1 {
+			return fmt.Errorf("forward interval must be at least 1 second")
+		}
+	} else {
+		// Validate Prometheus URL
+		if c.Prometheus.URL == "" {
+			return fmt.Errorf("prometheus URL is required")
+		}
+
+		if c.Prometheus.Username == "" {
+			return fmt.Errorf("prometheus username is required")
+		}
+	}
+
+	// Validate push interval
+	if c.Prometheus.PushIntervalSeconds < 1 {
+		return fmt.Errorf("push interval must be at least 1 second")
+	}
+
+	// Validate buffer size
+	if c.Prometheus.BufferSize < 1 {
+		return fmt.Errorf("buffer size must be at least 1")
+	}
+
+	// Validate batch size
+	if c.Prometheus.BatchSize < 1 {
+		return fmt.Errorf("batch size must be at least 1")
+	}
+
+	// Validate push watermark
+	if c.Prometheus.PushWatermarkPercent < 0 || c.Prometheus.PushWatermarkPercent > 100 {
+		return fmt.Errorf("push watermark must be between 0 and 100 percent, got %d", c.Prometheus.PushWatermarkPercent)
+	}
+
+	// Validate push backoff
+	if c.Prometheus.PushMaxBackoffSeconds < 0 {
+		return fmt.Errorf("push max backoff must not be negative, got %d", c.Prometheus.PushMaxBackoffSeconds)
+	}
+	if c.Prometheus.MaxIdleConns < 0 || c.Prometheus.IdleConnTimeoutSeconds < 0 {
+		return fmt.Errorf("push idle connections and their timeout must not be negative")
+	}
+
+	// Validate push protocol
+	if err := validateProtocol(&c.Prometheus.Protocol); err != nil {
+		return err
+	}
+	if err := validateSampleAge(c.Prometheus.MaxSampleAgeSeconds, c.Prometheus.RetimestampOldSamples); err != nil {
+		return err
+	}
+	if c.Prometheus.DedupWindowSeconds < 0 {
+		return fmt.Errorf("dedup window must not be negative")
+	}
+	for _, name := range c.Prometheus.CoalesceMetrics {
+		if !metricNameRegex.MatchString(name) {
+			return fmt.Errorf("coalesced metric %q must be lowercase letters, digits and underscores", name)
+		}
+	}
+	if len(c.Prometheus.CoalesceMetrics) > 0 && c.Prometheus.CoalesceMaxStalenessSeconds < c.Prometheus.PushIntervalSeconds {
+		return fmt.Errorf("coalesce max staleness must be at least the push interval of %d seconds", c.Prometheus.PushIntervalSeconds)
+	}
+	if c.Prometheus.MaxSeriesPerMetric < 0 {
+		return fmt.Errorf("max series per metric must not be negative")
+	}
+	for name, limit := range c.Prometheus.SeriesLimitOverrides {
+		if limit < 0 {
+			return fmt.Errorf("series limit for %s must not be negative", name)
+		}
+	}
+	if c.Prometheus.PushLogPath != "" && c.Prometheus.PushLogRetentionDays < 1 {
+		return fmt.Errorf("push log retention must be at least 1 day")
+	}
+
+	// Validate additional remote_write endpoints
+	seenEndpoints := make(map[string]bool)
+	for i := range c.Prometheus.AdditionalEndpoints {
+		endpoint := &c.Prometheus.AdditionalEndpoints[i]
+		if endpoint.Name == "" {
+			return fmt.Errorf("remote_write endpoint %d: name is required", i)
+		}
+		if !metricNameRegex.MatchString(endpoint.Name) {
+			return fmt.Errorf("remote_write endpoint %s: name must be lowercase letters, digits and underscores", endpoint.Name)
+		}
+		if seenEndpoints[endpoint.Name] {
+			return fmt.Errorf("remote_write endpoint %s: duplicate name", endpoint.Name)
+		}
+		seenEndpoints[endpoint.Name] = true
+
+		if endpoint.URL == "" {
+			return fmt.Errorf("remote_write endpoint %s: URL is required", endpoint.Name)
+		}
+		endpoint.Password = os.ExpandEnv(endpoint.Password)
+		if endpoint.BufferSize == 0 {
+			endpoint.BufferSize = c.Prometheus.BufferSize
+		}
+		if endpoint.BufferSize < 1 {
+			return fmt.Errorf("remote_write endpoint %s: buffer size must be at least 1", endpoint.Name)
+		}
+		if err := validateProtocol(&endpoint.Protocol); err != nil {
+			return fmt.Errorf("remote_write endpoint %s: %w", endpoint.Name, err)
+		}
+		if err := validateSampleAge(endpoint.MaxSampleAgeSeconds, endpoint.RetimestampOldSamples); err != nil {
+			return fmt.Errorf("remote_write endpoint %s: %w", endpoint.Name, err)
+		}
+		if endpoint.DedupWindowSeconds < 0 {
+			return fmt.Errorf("remote_write endpoint %s: dedup window must not be negative", endpoint.Name)
+		}
+	}
+
+	// Validate tenant overrides
+	validReadingTypes := map[string]bool{
+		"ble": true, "netatmo": true, "power": true, "heatpump": true, "water": true, "onewire": true,
+		"i2c": true, "airquality": true, "zigbee": true, "dependency": true, "automation": true, "derived": true, "room": true, "summary": true, "remote": true, "http": true, "conflict": true, "battery": true, "location": true, "reconciliation": true, "price": true, "occupancy": true, "ventilation": true,
+	}
+	for readingType := range c.Prometheus.TenantOverrides {
+		if !validReadingTypes[readingType] {
+			return fmt.Errorf("unknown reading type in tenant overrides: %s", readingType)
+		}
+	}
+
+	// Validate watchdog if enabled
+	if c.Watchdog.Enabled {
+		if c.Watchdog.CheckIntervalSeconds < 1 {
+			return fmt.Errorf("watchdog check interval must be at least 1 second")
+		}
+		if c.Watchdog.StallAfterSeconds < 2*c.Watchdog.CheckIntervalSeconds {
+			return fmt.Errorf("watchdog stall timeout must be at least twice the check interval")
+		}
+		for _, readingType := range c.Watchdog.ReadingTypes {
+			if !validReadingTypes[readingType] {
+				return fmt.Errorf("unknown reading type in watchdog: %s", readingType)
+			}
+		}
+	}
+
+	// Validate log format
+	c.Logging.Format = strings.ToLower(c.Logging.Format)
+	if c.Logging.Format != "console" && c.Logging.Format != "json" && c.Logging.Format != "logfmt" {
+		return fmt.Errorf("log format must be 'console', 'json', or 'logfmt', got: %s", c.Logging.Format)
+	}
+
+	// Validate log level
 	c.Logging.Level = strings.ToLower(c.Logging.Level)
 	validLevels := map[string]bool{"debug": true, "info": true, "warn": true, "error": true}
 	if !validLevels[c.Logging.Level] {
 		return fmt.Errorf("log level must be one of: debug, info, warn, error, got: %s", c.Logging.Level)
 	}
 
+	// Validate Loki configuration if enabled
+	if c.Logging.Loki.Enabled {
+		if err := c.Logging.Loki.validate(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// validate validates the heat pump configuration
+func (h *HeatPumpConfig) validate() error {
+	h.Protocol = strings.ToLower(h.Protocol)
+	if h.Protocol != "http" && h.Protocol != "modbus" {
+		return fmt.Errorf("heat pump protocol must be 'http' or 'modbus', got: %s", h.Protocol)
+	}
+	if h.Address == "" {
+		return fmt.Errorf("heat pump address is required when heat pump monitoring is enabled")
+	}
+	if h.Protocol == "modbus" && (h.UnitID < 0 || h.UnitID > 255) {
+		return fmt.Errorf("heat pump modbus unit ID must be between 0 and 255, got %d", h.UnitID)
+	}
+	if h.PollIntervalSeconds < 1 {
+		return fmt.Errorf("heat pump poll interval must be at least 1 second")
+	}
+	if h.TimeoutSeconds <= 0 {
+		return fmt.Errorf("heat pump timeout must be positive")
+	}
+	h.Auth.Type = strings.ToLower(h.Auth.Type)
+	if err := httpauth.Config(h.Auth).Validate(); err != nil {
+		return fmt.Errorf("heat pump auth: %w", err)
+	}
+	if len(h.Metrics) == 0 {
+		return fmt.Errorf("at least one heat pump metric must be configured")
+	}
+
+	seenNames := make(map[string]bool)
+	for i := range h.Metrics {
+		metric := &h.Metrics[i]
+		if !metricNameRegex.MatchString(metric.Name) {
+			return fmt.Errorf("heat pump metric %d: invalid name %q (expected lowercase letters, digits and underscores)", i, metric.Name)
+		}
+		if seenNames[metric.Name] {
+			return fmt.Errorf("heat pump metric %s: duplicate name", metric.Name)
+		}
+		seenNames[metric.Name] = true
+
+		if h.Protocol == "http" && metric.Path == "" {
+			return fmt.Errorf("heat pump metric %s: path is required for http protocol", metric.Name)
+		}
+		if metric.Words == 0 {
+			metric.Words = 1
+		}
+		if metric.Words != 1 && metric.Words != 2 {
+			return fmt.Errorf("heat pump metric %s: words must be 1 or 2, got %d", metric.Name, metric.Words)
+		}
+		if metric.Unit != "" {
+			if metric.Scale != 0 {
+				return fmt.Errorf("heat pump metric %s: set either scale or unit, not both", metric.Name)
+			}
+			unit := units.FromName(metric.Name)
+			if unit == units.None {
+				return fmt.Errorf("heat pump metric %s: name must end with a unit suffix such as _celsius when unit is set", metric.Name)
+			}
+			factor, err := units.Factor(metric.Unit, unit)
+			if err != nil {
+				return fmt.Errorf("heat pump metric %s: %w", metric.Name, err)
+			}
+			metric.Scale = factor
+		}
+		if metric.Scale == 0 {
+			metric.Scale = 1
+		}
+	}
+
+	return nil
+}
+
+// validate validates the 1-Wire configuration
+func (o *OneWireConfig) validate() error {
+	if o.DevicesPath == "" {
+		return fmt.Errorf("1-Wire devices path is required when 1-Wire is enabled")
+	}
+	if o.ReadIntervalSeconds < 1 {
+		return fmt.Errorf("1-Wire read interval must be at least 1 second")
+	}
+	if len(o.Sensors) == 0 {
+		return fmt.Errorf("at least one 1-Wire sensor must be configured")
+	}
+
+	seenIDs := make(map[int]bool)
+	seenDevices := make(map[string]bool)
+	for i := range o.Sensors {
+		sensor := &o.Sensors[i]
+		if sensor.Name == "" {
+			return fmt.Errorf("1-Wire sensor %d: name is required", i)
+		}
+		if sensor.ID < 1 {
+			return fmt.Errorf("1-Wire sensor %s: ID must be >= 1, got %d", sensor.Name, sensor.ID)
+		}
+		if seenIDs[sensor.ID] {
+			return fmt.Errorf("1-Wire sensor %s: duplicate ID %d", sensor.Name, sensor.ID)
+		}
+		seenIDs[sensor.ID] = true
+
+		sensor.DeviceID = strings.ToLower(sensor.DeviceID)
+		if !oneWireDeviceIDRegex.MatchString(sensor.DeviceID) {
+			return fmt.Errorf("1-Wire sensor %s: invalid device ID format: %s (expected format: 28-xxxxxxxxxxxx)", sensor.Name, sensor.DeviceID)
+		}
+		if seenDevices[sensor.DeviceID] {
+			return fmt.Errorf("1-Wire sensor %s: duplicate device ID %s", sensor.Name, sensor.DeviceID)
+		}
+		seenDevices[sensor.DeviceID] = true
+	}
+
+	return nil
+}
+
+// validate validates the I2C sensor configuration
+func (i *I2CConfig) validate() error {
+	if i.ReadIntervalSeconds < 1 {
+		return fmt.Errorf("I2C read interval must be at least 1 second")
+	}
+	if len(i.Sensors) == 0 {
+		return fmt.Errorf("at least one I2C sensor must be configured")
+	}
+
+	type busAddress struct{ bus, address int }
+	seenIDs := make(map[int]bool)
+	seenAddresses := make(map[busAddress]bool)
+	for idx := range i.Sensors {
+		sensor := &i.Sensors[idx]
+		if sensor.Name == "" {
+			return fmt.Errorf("I2C sensor %d: name is required", idx)
+		}
+		if sensor.ID < 1 {
+			return fmt.Errorf("I2C sensor %s: ID must be >= 1, got %d", sensor.Name, sensor.ID)
+		}
+		if seenIDs[sensor.ID] {
+			return fmt.Errorf("I2C sensor %s: duplicate ID %d", sensor.Name, sensor.ID)
+		}
+		seenIDs[sensor.ID] = true
+
+		sensor.Model = strings.ToLower(sensor.Model)
+		if sensor.Model != "bme280" && sensor.Model != "sht31" {
+			return fmt.Errorf("I2C sensor %s: model must be 'bme280' or 'sht31', got: %s", sensor.Name, sensor.Model)
+		}
+		if sensor.Bus < 0 {
+			return fmt.Errorf("I2C sensor %s: bus must not be negative", sensor.Name)
+		}
+		if sensor.Address < 0x03 || sensor.Address > 0x77 {
+			return fmt.Errorf("I2C sensor %s: address must be between 0x03 and 0x77, got 0x%02x", sensor.Name, sensor.Address)
+		}
+		key := busAddress{bus: sensor.Bus, address: sensor.Address}
+		if seenAddresses[key] {
+			return fmt.Errorf("I2C sensor %s: duplicate address 0x%02x on bus %d", sensor.Name, sensor.Address, sensor.Bus)
+		}
+		seenAddresses[key] = true
+	}
+
+	return nil
+}
+
+// validate validates the enabled notification channels
+func (n *NotifyConfig) validate() error {
+	if n.Telegram.Enabled {
+		if n.Telegram.BotToken == "" {
+			return fmt.Errorf("telegram bot token is required when Telegram notifications are enabled")
+		}
+		if n.Telegram.ChatID == "" {
+			return fmt.Errorf("telegram chat ID is required when Telegram notifications are enabled")
+		}
+	}
+	if n.Email.Enabled {
+		if n.Email.Host == "" {
+			return fmt.Errorf("SMTP host is required when e-mail notifications are enabled")
+		}
+		if n.Email.Port < 1 || n.Email.Port > 65535 {
+			return fmt.Errorf("SMTP port must be between 1 and 65535, got %d", n.Email.Port)
+		}
+		if n.Email.From == "" {
+			return fmt.Errorf("e-mail sender is required when e-mail notifications are enabled")
+		}
+		if len(n.Email.To) == 0 {
+			return fmt.Errorf("at least one e-mail recipient is required when e-mail notifications are enabled")
+		}
+	}
+	if n.Webhook.Enabled && n.Webhook.URL == "" {
+		return fmt.Errorf("webhook URL is required when webhook notifications are enabled")
+	}
+	return nil
+}
+
+// validate validates the CO2 sensor configuration
+func (a *AirQualityConfig) validate() error {
+	if a.ReadIntervalSeconds < 1 {
+		return fmt.Errorf("air quality read interval must be at least 1 second")
+	}
+	if len(a.Sensors) == 0 {
+		return fmt.Errorf("at least one air quality sensor must be configured")
+	}
+
+	seenIDs := make(map[int]bool)
+	for idx := range a.Sensors {
+		sensor := &a.Sensors[idx]
+		if sensor.Name == "" {
+			return fmt.Errorf("air quality sensor %d: name is required", idx)
+		}
+		if sensor.ID < 1 {
+			return fmt.Errorf("air quality sensor %s: ID must be >= 1, got %d", sensor.Name, sensor.ID)
+		}
+		if seenIDs[sensor.ID] {
+			return fmt.Errorf("air quality sensor %s: duplicate ID %d", sensor.Name, sensor.ID)
+		}
+		seenIDs[sensor.ID] = true
+
+		sensor.Model = strings.ToLower(sensor.Model)
+		switch sensor.Model {
+		case "mhz19":
+			if sensor.Device == "" {
+				return fmt.Errorf("air quality sensor %s: device is required for mhz19", sensor.Name)
+			}
+		case "scd4x":
+			if sensor.Bus < 0 {
+				return fmt.Errorf("air quality sensor %s: bus must not be negative", sensor.Name)
+			}
+			// SCD40/SCD41 have a fixed address
+			if sensor.Address == 0 {
+				sensor.Address = 0x62
+			}
+			if sensor.Address < 0x03 || sensor.Address > 0x77 {
+				return fmt.Errorf("air quality sensor %s: address must be between 0x03 and 0x77, got 0x%02x", sensor.Name, sensor.Address)
+			}
+		default:
+			return fmt.Errorf("air quality sensor %s: model must be 'mhz19' or 'scd4x', got: %s", sensor.Name, sensor.Model)
+		}
+	}
+
+	return nil
+}
+
+// validate validates the automation rules
+func (a *AutomationConfig) validate() error {
+	if a.WebhookTimeoutSeconds <= 0 {
+		return fmt.Errorf("automation webhook timeout must be positive")
+	}
+	if a.LoadShedding.Enabled {
+		if err := a.LoadShedding.validate(); err != nil {
+			return err
+		}
+	}
+	if a.Expressions.Enabled {
+		if err := a.Expressions.validate(); err != nil {
+			return err
+		}
+	}
+	if a.PriceRules.Enabled {
+		if err := a.PriceRules.validate(); err != nil {
+			return err
+		}
+	}
+	if a.Ventilation.Enabled {
+		if err := a.Ventilation.validate(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// validate validates the load shedding rules
+func (l *LoadSheddingConfig) validate() error {
+	if len(l.Rules) == 0 {
+		return fmt.Errorf("at least one load shedding rule must be configured")
+	}
+
+	seenNames := make(map[string]bool)
+	for i := range l.Rules {
+		rule := &l.Rules[i]
+		if rule.Name == "" {
+			return fmt.Errorf("load shedding rule %d: name is required", i)
+		}
+		if seenNames[rule.Name] {
+			return fmt.Errorf("load shedding rule %s: duplicate name", rule.Name)
+		}
+		seenNames[rule.Name] = true
+
+		if rule.ThresholdWatts <= 0 {
+			return fmt.Errorf("load shedding rule %s: threshold must be positive", rule.Name)
+		}
+		if rule.ForSeconds < 0 || rule.RestoreForSeconds < 0 {
+			return fmt.Errorf("load shedding rule %s: durations must not be negative", rule.Name)
+		}
+		if err := rule.Shed.validate(); err != nil {
+			return fmt.Errorf("load shedding rule %s: shed webhook: %w", rule.Name, err)
+		}
+		if rule.RestoreBelowWatts < 0 {
+			return fmt.Errorf("load shedding rule %s: restore threshold must not be negative", rule.Name)
+		}
+		if rule.RestoreBelowWatts > 0 {
+			if rule.RestoreBelowWatts >= rule.ThresholdWatts {
+				return fmt.Errorf("load shedding rule %s: restore threshold (%.0f W) must be below the shed threshold (%.0f W)", rule.Name, rule.RestoreBelowWatts, rule.ThresholdWatts)
+			}
+			if err := rule.Restore.validate(); err != nil {
+				return fmt.Errorf("load shedding rule %s: restore webhook: %w", rule.Name, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// validate validates the expression rules
+// Expressions are compiled when the automation engine is created
+func (e *ExpressionsConfig) validate() error {
+	if len(e.Rules) == 0 {
+		return fmt.Errorf("at least one expression rule must be configured")
+	}
+
+	seenFilters := make(map[string]bool)
+	for i, filter := range e.Filters {
+		if filter.Name == "" {
+			return fmt.Errorf("expression filter %d: name is required", i)
+		}
+		if seenFilters[filter.Name] {
+			return fmt.Errorf("expression filter %s: duplicate name", filter.Name)
+		}
+		seenFilters[filter.Name] = true
+		if err := filter.Spec().Validate(); err != nil {
+			return fmt.Errorf("expression filter %s: %w", filter.Name, err)
+		}
+	}
+
+	seenNames := make(map[string]bool)
+	for i := range e.Rules {
+		rule := &e.Rules[i]
+		if rule.Name == "" {
+			return fmt.Errorf("expression rule %d: name is required", i)
+		}
+		if seenNames[rule.Name] {
+			return fmt.Errorf("expression rule %s: duplicate name", rule.Name)
+		}
+		seenNames[rule.Name] = true
+
+		if rule.Expr == "" {
+			return fmt.Errorf("expression rule %s: expr is required", rule.Name)
+		}
+		if len(rule.Vars) == 0 {
+			return fmt.Errorf("expression rule %s: at least one variable is required", rule.Name)
+		}
+		for name, selector := range rule.Vars {
+			if selector.Metric == "" {
+				return fmt.Errorf("expression rule %s: variable %s: metric is required", rule.Name, name)
+			}
+			if selector.Filter != "" && !seenFilters[selector.Filter] {
+				return fmt.Errorf("expression rule %s: variable %s: unknown filter %s", rule.Name, name, selector.Filter)
+			}
+		}
+		if rule.Filter != "" && !seenFilters[rule.Filter] {
+			return fmt.Errorf("expression rule %s: unknown filter %s", rule.Name, rule.Filter)
+		}
+
+		hasMetric := rule.Metric != ""
+		hasWebhook := rule.Webhook.URL != ""
+		if hasMetric == hasWebhook {
+			return fmt.Errorf("expression rule %s: exactly one of metric or webhook must be set", rule.Name)
+		}
+		if hasMetric && !metricNameRegex.MatchString(rule.Metric) {
+			return fmt.Errorf("expression rule %s: invalid metric name: %s", rule.Name, rule.Metric)
+		}
+		if hasWebhook {
+			if err := rule.Webhook.validate(); err != nil {
+				return fmt.Errorf("expression rule %s: webhook: %w", rule.Name, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// validate validates the price rules
+func (p *PriceRulesConfig) validate() error {
+	if len(p.Rules) == 0 {
+		return fmt.Errorf("at least one price rule must be configured")
+	}
+	if p.EvaluateIntervalSeconds < 1 {
+		return fmt.Errorf("price rules evaluate interval must be at least 1 second")
+	}
+
+	seenNames := make(map[string]bool)
+	for i := range p.Rules {
+		rule := &p.Rules[i]
+		if rule.Name == "" {
+			return fmt.Errorf("price rule %d: name is required", i)
+		}
+		if seenNames[rule.Name] {
+			return fmt.Errorf("price rule %s: duplicate name", rule.Name)
+		}
+		seenNames[rule.Name] = true
+
+		if rule.BelowPLNPerKWh == nil && rule.CheapestHours == 0 {
+			return fmt.Errorf("price rule %s: belowPlnPerKwh or cheapestHours is required", rule.Name)
+		}
+		if rule.CheapestHours < 0 || rule.CheapestHours > 24 {
+			return fmt.Errorf("price rule %s: cheapest hours must be between 0 and 24", rule.Name)
+		}
+		if err := rule.Enable.validate(); err != nil {
+			return fmt.Errorf("price rule %s: enable webhook: %w", rule.Name, err)
+		}
+		if rule.Disable.URL != "" {
+			if err := rule.Disable.validate(); err != nil {
+				return fmt.Errorf("price rule %s: disable webhook: %w", rule.Name, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// validate validates the ventilation sensors, thresholds and webhooks
+func (v *VentilationConfig) validate() error {
+	if len(v.IndoorSensors) == 0 {
+		return fmt.Errorf("ventilation requires at least one indoor sensor")
+	}
+	if v.OutdoorSensor == "" {
+		return fmt.Errorf("ventilation requires an outdoor sensor")
+	}
+	for _, name := range v.IndoorSensors {
+		if name == v.OutdoorSensor {
+			return fmt.Errorf("ventilation sensor %s cannot be both indoor and outdoor", name)
+		}
+	}
+	if v.OnDeltaGramsPerCubicMeter <= 0 {
+		return fmt.Errorf("ventilation on delta must be positive")
+	}
+	if v.OffDeltaGramsPerCubicMeter >= v.OnDeltaGramsPerCubicMeter {
+		return fmt.Errorf("ventilation off delta must be below the on delta of %.2f g/m³", v.OnDeltaGramsPerCubicMeter)
+	}
+	if v.MinIndoorHumidityPercent < 0 || v.MinIndoorHumidityPercent > 100 {
+		return fmt.Errorf("ventilation min indoor humidity must be between 0 and 100 percent")
+	}
+	if v.EvaluateIntervalSeconds < 1 {
+		return fmt.Errorf("ventilation evaluate interval must be at least 1 second")
+	}
+	if v.On.URL != "" {
+		if err := v.On.validate(); err != nil {
+			return fmt.Errorf("ventilation on webhook: %w", err)
+		}
+	}
+	if v.Off.URL != "" {
+		if err := v.Off.validate(); err != nil {
+			return fmt.Errorf("ventilation off webhook: %w", err)
+		}
+	}
+	return nil
+}
+
+// validateProtocol validates a push protocol, defaulting to remote_write
+func validateProtocol(protocol *string) error {
+	*protocol = strings.ToLower(*protocol)
+	if *protocol == "" {
+		*protocol = "remote_write"
+	}
+	if *protocol != "remote_write" && *protocol != "vm_import" {
+		return fmt.Errorf("protocol must be 'remote_write' or 'vm_import', got: %s", *protocol)
+	}
+	return nil
+}
+
+// validateSampleAge validates the max sample age settings
+func validateSampleAge(maxSampleAgeSeconds int, retimestamp bool) error {
+	if maxSampleAgeSeconds < 0 {
+		return fmt.Errorf("max sample age must not be negative")
+	}
+	if retimestamp && maxSampleAgeSeconds == 0 {
+		return fmt.Errorf("re-timestamping old samples requires a max sample age")
+	}
+	return nil
+}
+
+// validate checks the fields required by the auth type, expanding ${VAR} references in secrets
+func (a *AdminAuthConfig) validate(tlsConfig AdminTLSConfig) error {
+	a.Token = os.ExpandEnv(a.Token)
+	a.Password = os.ExpandEnv(a.Password)
+	switch a.Type {
+	case "", "none":
+	case "bearer":
+		if a.Token == "" {
+			return fmt.Errorf("token is required for bearer auth")
+		}
+	case "basic":
+		if a.Username == "" || a.Password == "" {
+			return fmt.Errorf("username and password are required for basic auth")
+		}
+	case "mtls":
+		if tlsConfig.ClientCAFile == "" {
+			return fmt.Errorf("mtls auth requires a TLS client CA file")
+		}
+	default:
+		return fmt.Errorf("type must be 'none', 'bearer', 'basic', or 'mtls', got: %s", a.Type)
+	}
+	return nil
+}
+
+// validate validates a webhook, defaulting the method to POST
+func (w *WebhookConfig) validate() error {
+	if w.URL == "" {
+		return fmt.Errorf("URL is required")
+	}
+	w.Method = strings.ToUpper(w.Method)
+	if w.Method == "" {
+		w.Method = "POST"
+	}
+	switch w.Method {
+	case "GET", "POST", "PUT":
+	default:
+		return fmt.Errorf("method must be GET, POST or PUT, got: %s", w.Method)
+	}
+	return nil
+}
+
+// validate validates the MQTT broker settings
+func (m *MQTTConfig) validate() error {
+	if m.Broker == "" {
+		return fmt.Errorf("MQTT broker address is required")
+	}
+	if m.ClientID == "" {
+		return fmt.Errorf("MQTT client ID is required")
+	}
+	if m.KeepAliveSeconds < 1 || m.KeepAliveSeconds > 65535 {
+		return fmt.Errorf("MQTT keep-alive must be between 1 and 65535 seconds, got %d", m.KeepAliveSeconds)
+	}
+	return nil
+}
+
+// validate validates the fused rooms and their sources
+func (r *RoomFusionConfig) validate() error {
+	if r.StaleSeconds < 1 {
+		return fmt.Errorf("stale seconds must be at least 1, got %d", r.StaleSeconds)
+	}
+	if len(r.Rooms) == 0 {
+		return fmt.Errorf("at least one room must be configured")
+	}
+
+	seenNames := make(map[string]bool)
+	for i := range r.Rooms {
+		room := &r.Rooms[i]
+		if room.Name == "" {
+			return fmt.Errorf("room %d: name is required", i)
+		}
+		if seenNames[room.Name] {
+			return fmt.Errorf("room %s: duplicate name", room.Name)
+		}
+		seenNames[room.Name] = true
+
+		if room.Primary.Metric == "" || room.Secondary.Metric == "" {
+			return fmt.Errorf("room %s: primary and secondary metrics are required", room.Name)
+		}
+		if room.Primary.Metric == "room_temperature_celsius" || room.Secondary.Metric == "room_temperature_celsius" {
+			return fmt.Errorf("room %s: sources cannot select fused room temperatures", room.Name)
+		}
+		if room.Primary.sourceName() == room.Secondary.sourceName() {
+			return fmt.Errorf("room %s: primary and secondary need distinct source names", room.Name)
+		}
+		if room.StaleSeconds < 0 {
+			return fmt.Errorf("room %s: stale seconds must not be negative", room.Name)
+		}
+	}
+
+	return nil
+}
+
+// sourceName returns the source label, defaulting to the metric prefix
+func (s *RoomSourceConfig) sourceName() string {
+	if s.Name != "" {
+		return s.Name
+	}
+	name, _, _ := strings.Cut(s.Metric, "_")
+	return name
+}
+
+// validate validates the Loki log shipping settings
+func (l *LokiConfig) validate() error {
+	if l.URL == "" {
+		return fmt.Errorf("loki URL is required when log shipping is enabled")
+	}
+	l.Level = strings.ToLower(l.Level)
+	validLevels := map[string]bool{"debug": true, "info": true, "warn": true, "error": true}
+	if !validLevels[l.Level] {
+		return fmt.Errorf("loki level must be one of: debug, info, warn, error, got: %s", l.Level)
+	}
+	if l.Service == "" {
+		return fmt.Errorf("loki service label is required when log shipping is enabled")
+	}
+	if l.BatchSize < 1 {
+		return fmt.Errorf("loki batch size must be at least 1")
+	}
+	if l.QueueSize < l.BatchSize {
+		return fmt.Errorf("loki queue size (%d) must be at least the batch size (%d)", l.QueueSize, l.BatchSize)
+	}
+	if l.FlushIntervalSeconds < 1 {
+		return fmt.Errorf("loki flush interval must be at least 1 second")
+	}
 	return nil
 }
 
@@ -285,13 +2520,194 @@ func (c *Config) PrintConfig(logger *zap.Logger) {
 	logger.Info("configuration loaded",
 		zap.Int("sensor_count", len(c.BLE.Sensors)),
 		zap.Strings("sensors", sensorInfo),
+		zap.Bool("ble_derived_humidity", c.BLE.DerivedHumidity),
+		zap.String("ble_backlog_mode", c.BLE.Backlog.Mode),
 		zap.Bool("netatmo_enabled", c.Netatmo.Enabled),
 		zap.Bool("netatmo_configured", c.Netatmo.ClientID != "" && c.Netatmo.RefreshToken != ""),
 		zap.Int("netatmo_fetch_interval_seconds", c.Netatmo.FetchInterval),
+		zap.String("netatmo_timestamp_source", c.Netatmo.TimestampSource),
+		zap.Bool("netatmo_backfill_enabled", c.Netatmo.Backfill.Enabled),
+		zap.String("netatmo_backfill_state_file", c.Netatmo.Backfill.StateFile),
+		zap.Int("netatmo_backfill_window_seconds", c.Netatmo.Backfill.WindowSeconds),
 		zap.Bool("power_enabled", c.Power.Enabled),
 		zap.String("power_scrape_url", c.Power.ScrapeURL),
 		zap.Int("power_scrape_interval_seconds", c.Power.ScrapeIntervalSeconds),
 		zap.Float64("power_scrape_timeout_seconds", c.Power.ScrapeTimeoutSeconds),
+		zap.Int("power_stale_repeat_intervals", c.Power.StaleRepeatIntervals),
+		zap.String("power_auth_type", c.Power.Auth.Type),
+		zap.Bool("power_tls_insecure_skip_verify", c.Power.TLS.InsecureSkipVerify),
+		zap.Bool("pstryk_enabled", c.Pstryk.Enabled),
+		zap.Ints("pstryk_sensor_ids", c.Pstryk.SensorIDs),
+		zap.Int("pstryk_interval_seconds", c.Pstryk.IntervalSeconds),
+		zap.Int("pstryk_lookback_hours", c.Pstryk.LookbackHours),
+		zap.Float64("pstryk_drift_alert_percent", c.Pstryk.DriftAlertPercent),
+		zap.Bool("prices_enabled", c.Prices.Enabled),
+		zap.String("prices_source", c.Prices.Source),
+		zap.String("prices_entsoe_area", c.Prices.EntsoeArea),
+		zap.Float64("prices_eur_pln_rate", c.Prices.EURPLNRate),
+		zap.Int("prices_fetch_interval_seconds", c.Prices.FetchIntervalSeconds),
+		zap.Int("prices_report_interval_seconds", c.Prices.ReportIntervalSeconds),
+		zap.Int("prices_horizon_hours", c.Prices.HorizonHours),
+		zap.Bool("heatpump_enabled", c.HeatPump.Enabled),
+		zap.String("heatpump_protocol", c.HeatPump.Protocol),
+		zap.String("heatpump_address", c.HeatPump.Address),
+		zap.Int("heatpump_poll_interval_seconds", c.HeatPump.PollIntervalSeconds),
+		zap.Int("heatpump_metric_count", len(c.HeatPump.Metrics)),
+		zap.Bool("water_enabled", c.Water.Enabled),
+		zap.Int("water_gpio_pin", c.Water.GPIOPin),
+		zap.Float64("water_liters_per_pulse", c.Water.LitersPerPulse),
+		zap.String("water_state_file", c.Water.StateFile),
+		zap.Bool("onewire_enabled", c.OneWire.Enabled),
+		zap.Int("onewire_sensor_count", len(c.OneWire.Sensors)),
+		zap.Int("onewire_read_interval_seconds", c.OneWire.ReadIntervalSeconds),
+		zap.Bool("i2c_enabled", c.I2C.Enabled),
+		zap.Int("i2c_sensor_count", len(c.I2C.Sensors)),
+		zap.Int("i2c_read_interval_seconds", c.I2C.ReadIntervalSeconds),
+		zap.Int("collector_count", len(c.Collectors)),
+		zap.Bool("air_quality_enabled", c.AirQuality.Enabled),
+		zap.Int("air_quality_sensor_count", len(c.AirQuality.Sensors)),
+		zap.Int("air_quality_read_interval_seconds", c.AirQuality.ReadIntervalSeconds),
+		zap.Bool("zigbee2mqtt_enabled", c.Zigbee2MQTT.Enabled),
+		zap.String("zigbee2mqtt_base_topic", c.Zigbee2MQTT.BaseTopic),
+		zap.String("zigbee2mqtt_mqtt_broker", c.Zigbee2MQTT.MQTT.Broker),
+		zap.String("zigbee2mqtt_mqtt_client_id", c.Zigbee2MQTT.MQTT.ClientID),
+		zap.Bool("zigbee2mqtt_mqtt_password_set", c.Zigbee2MQTT.MQTT.Password != ""),
+		zap.Bool("ble_proxy_enabled", c.BLEProxy.Enabled),
+		zap.String("ble_proxy_base_topic", c.BLEProxy.BaseTopic),
+		zap.String("ble_proxy_mqtt_broker", c.BLEProxy.MQTT.Broker),
+		zap.Bool("ble_proxy_token_set", c.BLEProxy.Token != ""),
+		zap.Int("events_capacity", c.Events.Capacity),
+		zap.Int("events_ip_check_interval_seconds", c.Events.IPCheckIntervalSeconds),
+		zap.Bool("events_grafana_annotations_enabled", c.Events.GrafanaAnnotations.Enabled),
+		zap.String("events_grafana_url", c.Events.GrafanaAnnotations.URL),
+		zap.Bool("events_loki_enabled", c.Events.Loki.Enabled),
+		zap.Bool("telemetry_dependency_metrics", c.Telemetry.DependencyMetrics),
+		zap.Bool("telemetry_http_server_metrics", c.Telemetry.HTTPServerMetrics),
+		zap.Int("telemetry_report_interval_seconds", c.Telemetry.ReportIntervalSeconds),
+		zap.Bool("telemetry_connection_metrics", c.Telemetry.ConnectionMetrics),
+		zap.Bool("load_shedding_enabled", c.Automation.LoadShedding.Enabled),
+		zap.Int("load_shedding_rule_count", len(c.Automation.LoadShedding.Rules)),
+		zap.Bool("expression_rules_enabled", c.Automation.Expressions.Enabled),
+		zap.Int("expression_rule_count", len(c.Automation.Expressions.Rules)),
+		zap.Bool("price_rules_enabled", c.Automation.PriceRules.Enabled),
+		zap.Int("price_rules_evaluate_interval_seconds", c.Automation.PriceRules.EvaluateIntervalSeconds),
+		zap.Int("price_rule_count", len(c.Automation.PriceRules.Rules)),
+		zap.Bool("ventilation_enabled", c.Automation.Ventilation.Enabled),
+		zap.Strings("ventilation_indoor_sensors", c.Automation.Ventilation.IndoorSensors),
+		zap.String("ventilation_outdoor_sensor", c.Automation.Ventilation.OutdoorSensor),
+		zap.Bool("ventilation_webhooks", c.Automation.Ventilation.On.URL != "" || c.Automation.Ventilation.Off.URL != ""),
+		zap.Bool("room_fusion_enabled", c.RoomFusion.Enabled),
+		zap.Int("room_fusion_stale_seconds", c.RoomFusion.StaleSeconds),
+		zap.Int("room_fusion_room_count", len(c.RoomFusion.Rooms)),
+		zap.Bool("summary_enabled", c.Summary.Enabled),
+		zap.Bool("identity_check_enabled", c.IdentityCheck.Enabled),
+		zap.Int("identity_check_report_interval_seconds", c.IdentityCheck.ReportIntervalSeconds),
+		zap.Bool("battery_estimate_enabled", c.BatteryEstimate.Enabled),
+		zap.String("battery_estimate_state_file", c.BatteryEstimate.StateFile),
+		zap.Int("battery_estimate_window_days", c.BatteryEstimate.WindowDays),
+		zap.Int("battery_estimate_alert_days", c.BatteryEstimate.AlertDays),
+		zap.Int("battery_estimate_report_interval_seconds", c.BatteryEstimate.ReportIntervalSeconds),
+		zap.String("ble_receiver_name", c.BLEReceiverName()),
+		zap.Bool("locator_enabled", c.Locator.Enabled),
+		zap.Int("locator_report_interval_seconds", c.Locator.ReportIntervalSeconds),
+		zap.Int("locator_stale_seconds", c.Locator.StaleSeconds),
+		zap.Float64("locator_hysteresis_db", c.Locator.HysteresisDB),
+		zap.Int("locator_room_count", len(c.Locator.Rooms)),
+		zap.Int("locator_beacon_count", len(c.Locator.Beacons)),
+		zap.Bool("report_enabled", c.Report.Enabled),
+		zap.String("report_send_at", c.Report.SendAt),
+		zap.Float64("report_energy_price_per_kwh", c.Report.EnergyPriceKWh),
+		zap.String("report_currency", c.Report.Currency),
+		zap.Bool("notify_telegram_enabled", c.Notify.Telegram.Enabled),
+		zap.Bool("notify_email_enabled", c.Notify.Email.Enabled),
+		zap.String("notify_email_host", c.Notify.Email.Host),
+		zap.Int("notify_email_recipient_count", len(c.Notify.Email.To)),
+		zap.Bool("notify_webhook_enabled", c.Notify.Webhook.Enabled),
+		zap.Bool("history_enabled", c.History.Enabled),
+		zap.String("history_backend", c.History.Backend),
+		zap.String("history_dir", c.History.Dir),
+		zap.Int("history_retention_days", c.History.RetentionDays),
+		zap.Int("history_flush_interval_seconds", c.History.FlushIntervalSeconds),
+		zap.String("connectivity_mode", c.Connectivity.Mode),
+		zap.Strings("connectivity_metered_interfaces", c.Connectivity.MeteredInterfaces),
+		zap.Int("connectivity_metered_push_interval_seconds", c.Connectivity.MeteredPushIntervalSeconds),
+		zap.Bool("connectivity_aggregates_only", c.Connectivity.AggregatesOnly),
+		zap.String("network_address_family", c.Network.AddressFamily),
+		zap.String("http_user_agent", c.HTTPClient.UserAgent),
+		zap.Bool("http_proxy_set", c.HTTPClient.ProxyURL != ""),
+		zap.Bool("dns_cache_enabled", c.DNS.CacheEnabled),
+		zap.Int("dns_cache_ttl_seconds", c.DNS.CacheTTLSeconds),
+		zap.Int("dns_fallback_delay_ms", c.DNS.FallbackDelayMs),
+		zap.Int("dns_recycle_after_failures", c.DNS.RecycleAfterFailures),
+		zap.Bool("scheduling_align_to_wall_clock", c.Scheduling.AlignToWallClock),
+		zap.Int("scheduling_scrape_offset_seconds", c.Scheduling.ScrapeOffsetSeconds),
+		zap.Bool("occupancy_enabled", c.Occupancy.Enabled),
+		zap.String("occupancy_source", c.Occupancy.Source),
+		zap.Int("occupancy_period_count", len(c.Occupancy.Schedule)),
+		zap.Int("occupancy_away_after_seconds", c.Occupancy.AwayAfterSeconds),
+		zap.Int("occupancy_away_scrape_multiplier", c.Occupancy.AwayScrapeMultiplier),
+		zap.Bool("occupancy_mute_alerts_when_away", c.Occupancy.MuteAlertsWhenAway),
+		zap.Bool("frost_protection_enabled", c.FrostProtection.Enabled),
+		zap.Float64("frost_protection_floor_celsius", c.FrostProtection.FloorCelsius),
+		zap.Float64("frost_protection_setpoint_celsius", c.FrostProtection.SetpointCelsius),
+		zap.Int("frost_protection_hold_minutes", c.FrostProtection.HoldMinutes),
+		zap.Strings("frost_protection_exclude", c.FrostProtection.Exclude),
+		zap.Bool("remote_config_enabled", c.RemoteConfig.Enabled),
+		zap.String("remote_config_url", c.RemoteConfig.URL),
+		zap.Bool("remote_config_token_set", c.RemoteConfig.Token != ""),
+		zap.Bool("remote_config_checksum", c.RemoteConfig.ChecksumURL != ""),
+		zap.Bool("remote_config_signature", c.RemoteConfig.PublicKey != ""),
+		zap.Int("remote_config_interval_seconds", c.RemoteConfig.IntervalSeconds),
+		zap.String("remote_config_cache_file", c.RemoteConfig.CacheFile),
+		zap.Strings("features_enabled", c.Features.Enabled),
+		zap.Bool("fault_injection_enabled", c.FaultInjection.Enabled),
+		zap.String("runtime_preset", c.Runtime.Preset),
+		zap.Int("startup_max_wait_seconds", c.Startup.MaxWaitSeconds),
+		zap.Bool("startup_wait_for_ble", c.Startup.WaitForBLE),
+		zap.Bool("startup_wait_for_dns", c.Startup.WaitForDNS),
+		zap.Bool("startup_wait_for_power_meter", c.Startup.WaitForPowerMeter),
+		zap.Bool("startup_fail_on_timeout", c.Startup.FailOnTimeout),
+		zap.Bool("watchdog_enabled", c.Watchdog.Enabled),
+		zap.Int("watchdog_check_interval_seconds", c.Watchdog.CheckIntervalSeconds),
+		zap.Int("watchdog_stall_after_seconds", c.Watchdog.StallAfterSeconds),
+		zap.Strings("watchdog_reading_types", c.Watchdog.ReadingTypes),
+		zap.String("watchdog_heartbeat_file", c.Watchdog.HeartbeatFile),
+		zap.Bool("watchdog_exit_on_stall", c.Watchdog.ExitOnStall),
+		zap.Int("runtime_gc_percent", c.Runtime.GCPercent),
+		zap.Int("runtime_memory_limit_mb", c.Runtime.MemoryLimitMB),
+		zap.Int("runtime_ballast_mb", c.Runtime.BallastMB),
+		zap.Int("fault_injection_max_duration_seconds", c.FaultInjection.MaxDurationSeconds),
+		zap.Bool("mode_enabled", c.Mode.Enabled),
+		zap.String("mode_state_file", c.Mode.StateFile),
+		zap.String("mode_vacation_heating_mode", c.Mode.VacationHeatingMode),
+		zap.Bool("admin_enabled", c.Admin.Enabled),
+		zap.Bool("ingest_enabled", c.Ingest.Enabled),
+		zap.Bool("ingest_token_set", c.Ingest.Token != ""),
+		zap.Bool("remote_write_receiver_enabled", c.RemoteWrite.Enabled),
+		zap.Bool("remote_write_receiver_token_set", c.RemoteWrite.Token != ""),
+		zap.Bool("pushgateway_enabled", c.Pushgateway.Enabled),
+		zap.Bool("pushgateway_token_set", c.Pushgateway.Token != ""),
+		zap.Bool("forward_enabled", c.Forward.Enabled),
+		zap.String("forward_url", c.Forward.URL),
+		zap.Int("forward_interval_seconds", c.Forward.IntervalSeconds),
+		zap.Bool("nats_enabled", c.NATS.Enabled),
+		zap.String("nats_server", c.NATS.Server),
+		zap.Bool("nats_credentials_set", c.NATS.Password != "" || c.NATS.Token != ""),
+		zap.String("nats_subject_prefix", c.NATS.SubjectPrefix),
+		zap.String("nats_encoding", c.NATS.Encoding),
+		zap.Bool("nats_jetstream", c.NATS.JetStream),
+		zap.Int("nats_interval_seconds", c.NATS.IntervalSeconds),
+		zap.Bool("leader_enabled", c.Leader.Enabled),
+		zap.String("leader_id", c.Leader.ID),
+		zap.String("leader_lease_url", c.Leader.LeaseURL),
+		zap.Bool("leader_token_set", c.Leader.Token != ""),
+		zap.Int("leader_ttl_seconds", c.Leader.TTLSeconds),
+		zap.Any("fleet_labels", c.Fleet.ExternalLabels()),
+		zap.String("admin_listen_address", c.Admin.ListenAddress),
+		zap.Bool("admin_tls_enabled", c.Admin.TLS.enabled()),
+		zap.Bool("admin_tls_self_signed", c.Admin.TLS.SelfSigned),
+		zap.String("admin_auth_type", c.Admin.Auth.Type),
+		zap.Int("admin_auth_group_count", len(c.Admin.AuthGroups)),
 		zap.Int("push_interval_seconds", c.Prometheus.PushIntervalSeconds),
 		zap.String("prometheus_url", c.Prometheus.URL),
 		zap.String("prometheus_username", c.Prometheus.Username),
@@ -299,7 +2715,30 @@ func (c *Config) PrintConfig(logger *zap.Logger) {
 		zap.Bool("start_at_even_second", c.Prometheus.StartAtEvenSecond),
 		zap.Int("buffer_size", c.Prometheus.BufferSize),
 		zap.Int("batch_size", c.Prometheus.BatchSize),
+		zap.Int("push_watermark_percent", c.Prometheus.PushWatermarkPercent),
+		zap.Int("push_max_backoff_seconds", c.Prometheus.PushMaxBackoffSeconds),
+		zap.Int("push_max_idle_conns", c.Prometheus.MaxIdleConns),
+		zap.Int("push_idle_conn_timeout_seconds", c.Prometheus.IdleConnTimeoutSeconds),
+		zap.String("prometheus_tenant_id", c.Prometheus.TenantID),
+		zap.String("prometheus_protocol", c.Prometheus.Protocol),
+		zap.Int("prometheus_max_sample_age_seconds", c.Prometheus.MaxSampleAgeSeconds),
+		zap.Bool("prometheus_retimestamp_old_samples", c.Prometheus.RetimestampOldSamples),
+		zap.Int("prometheus_dedup_window_seconds", c.Prometheus.DedupWindowSeconds),
+		zap.Strings("prometheus_coalesce_metrics", c.Prometheus.CoalesceMetrics),
+		zap.Int("prometheus_coalesce_max_staleness_seconds", c.Prometheus.CoalesceMaxStalenessSeconds),
+		zap.Int("prometheus_max_series_per_metric", c.Prometheus.MaxSeriesPerMetric),
+		zap.Int("prometheus_series_limit_overrides", len(c.Prometheus.SeriesLimitOverrides)),
+		zap.String("push_log_path", c.Prometheus.PushLogPath),
+		zap.Bool("provenance_headers", c.Prometheus.ProvenanceHeaders),
+		zap.Int("push_log_retention_days", c.Prometheus.PushLogRetentionDays),
+		zap.Int("prometheus_tenant_override_count", len(c.Prometheus.TenantOverrides)),
+		zap.Int("prometheus_additional_endpoint_count", len(c.Prometheus.AdditionalEndpoints)),
 		zap.String("log_format", c.Logging.Format),
 		zap.String("log_level", c.Logging.Level),
+		zap.Bool("loki_enabled", c.Logging.Loki.Enabled),
+		zap.String("loki_url", c.Logging.Loki.URL),
+		zap.String("loki_level", c.Logging.Loki.Level),
+		zap.String("loki_device", c.Logging.Loki.Device),
+		zap.Bool("loki_password_set", c.Logging.Loki.Password != ""),
 	)
 }
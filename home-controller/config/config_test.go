@@ -5,6 +5,7 @@ import (
 	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 
 	"go.uber.org/zap"
 )
@@ -184,6 +185,29 @@ func TestValidate_MissingRequiredFields(t *testing.T) {
 			},
 			expectedErr: "prometheus username is required",
 		},
+		{
+			name: "Duplicate sensor name",
+			config: Config{
+				BLE: BLEConfig{
+					Sensors: []SensorConfig{
+						{Name: "Test", ID: 1, MACAddress: "A4:C1:38:00:00:01"},
+						{Name: "Test", ID: 2, MACAddress: "A4:C1:38:00:00:02"},
+					},
+				},
+				Prometheus: PrometheusConfig{
+					PushIntervalSeconds: 15,
+					URL:                 "https://example.com",
+					Username:            "test",
+					BufferSize:          1000,
+					BatchSize:           1000,
+				},
+				Logging: LoggingConfig{
+					Format: "console",
+					Level:  "info",
+				},
+			},
+			expectedErr: "duplicate name",
+		},
 	}
 
 	for _, tt := range tests {
@@ -581,3 +605,1292 @@ logging:
 		t.Errorf("Expected buffer size 2000 from env, got %d", cfg.Prometheus.BufferSize)
 	}
 }
+
+func TestLoad_PowerAuth(t *testing.T) {
+	tmpDir := t.TempDir()
+	configPath := filepath.Join(tmpDir, "config.yaml")
+
+	configContent := `
+ble:
+  sensors:
+    - name: Sensor1
+      id: 1
+      macAddress: "A4:C1:38:00:00:01"
+power:
+  enabled: true
+  scrapeUrl: "https://192.168.1.100/state"
+  auth:
+    type: Bearer
+  tls:
+    insecureSkipVerify: true
+prometheus:
+  pushIntervalSeconds: 15
+  prometheusUrl: "https://example.com"
+  prometheusUsername: "user"
+logging:
+  logFormat: "console"
+  logLevel: "info"
+`
+
+	err := os.WriteFile(configPath, []byte(configContent), 0644)
+	if err != nil {
+		t.Fatalf("Failed to create test config file: %v", err)
+	}
+
+	// Secrets are expected to come from the environment
+	t.Setenv("POWER_AUTH_TOKEN", "env-token")
+
+	cfg, err := Load(configPath)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	if cfg.Power.Auth.Type != "bearer" {
+		t.Errorf("Expected auth type 'bearer', got %s", cfg.Power.Auth.Type)
+	}
+
+	if cfg.Power.Auth.Token != "env-token" {
+		t.Errorf("Expected token 'env-token' from env, got %s", cfg.Power.Auth.Token)
+	}
+
+	if !cfg.Power.TLS.InsecureSkipVerify {
+		t.Error("Expected insecureSkipVerify to be true")
+	}
+}
+
+// validConfig returns a minimal valid configuration, which section tests change
+func validConfig() *Config {
+	return &Config{
+		BLE: BLEConfig{Sensors: []SensorConfig{{Name: "living_room", ID: 1, MACAddress: "A4:C1:38:00:00:01"}}},
+		Prometheus: PrometheusConfig{
+			URL:                 "https://example.com",
+			Username:            "user",
+			PushIntervalSeconds: 15,
+			BufferSize:          1000,
+			BatchSize:           1000,
+		},
+		Logging: LoggingConfig{Format: "console", Level: "info"},
+	}
+}
+
+func TestValidate_PowerAuth(t *testing.T) {
+	cfg := validConfig()
+	cfg.Power = PowerConfig{
+		Enabled:               true,
+		ScrapeURL:             "http://192.168.1.100/state",
+		ScrapeIntervalSeconds: 2,
+		ScrapeTimeoutSeconds:  1.5,
+		Auth:                  HTTPAuthConfig{Type: "basic"},
+	}
+
+	err := cfg.Validate()
+	if err == nil {
+		t.Fatal("Expected error for basic auth without username, got nil")
+	}
+
+	if !strings.Contains(err.Error(), "power auth") {
+		t.Errorf("Expected power auth error, got: %v", err)
+	}
+}
+
+func TestValidate_HeatPump(t *testing.T) {
+	newConfig := func(heatPump HeatPumpConfig) Config {
+		cfg := validConfig()
+		cfg.HeatPump = heatPump
+		return *cfg
+	}
+
+	tests := []struct {
+		name     string
+		heatPump HeatPumpConfig
+		wantErr  string
+	}{
+		{
+			name: "valid modbus",
+			heatPump: HeatPumpConfig{
+				Enabled: true, Protocol: "modbus", Address: "192.168.1.50:502", UnitID: 1,
+				PollIntervalSeconds: 30, TimeoutSeconds: 5,
+				Metrics: []HeatPumpMetricConfig{{Name: "flow_temperature_celsius", Register: 100, Scale: 0.1}},
+			},
+		},
+		{
+			name: "unknown protocol",
+			heatPump: HeatPumpConfig{
+				Enabled: true, Protocol: "bacnet", Address: "192.168.1.50", PollIntervalSeconds: 30, TimeoutSeconds: 5,
+				Metrics: []HeatPumpMetricConfig{{Name: "flow_temperature_celsius"}},
+			},
+			wantErr: "protocol",
+		},
+		{
+			name: "http metric without path",
+			heatPump: HeatPumpConfig{
+				Enabled: true, Protocol: "http", Address: "http://192.168.1.50/status", PollIntervalSeconds: 30, TimeoutSeconds: 5,
+				Metrics: []HeatPumpMetricConfig{{Name: "flow_temperature_celsius"}},
+			},
+			wantErr: "path is required",
+		},
+		{
+			name: "invalid metric name",
+			heatPump: HeatPumpConfig{
+				Enabled: true, Protocol: "modbus", Address: "192.168.1.50:502", PollIntervalSeconds: 30, TimeoutSeconds: 5,
+				Metrics: []HeatPumpMetricConfig{{Name: "Flow Temperature"}},
+			},
+			wantErr: "invalid name",
+		},
+		{
+			name: "no metrics",
+			heatPump: HeatPumpConfig{
+				Enabled: true, Protocol: "modbus", Address: "192.168.1.50:502", PollIntervalSeconds: 30, TimeoutSeconds: 5,
+			},
+			wantErr: "at least one heat pump metric",
+		},
+		{
+			name: "valid unit",
+			heatPump: HeatPumpConfig{
+				Enabled: true, Protocol: "modbus", Address: "192.168.1.50:502", PollIntervalSeconds: 30, TimeoutSeconds: 5,
+				Metrics: []HeatPumpMetricConfig{{Name: "flow_temperature_celsius", Register: 100, Unit: "dC"}},
+			},
+		},
+		{
+			name: "unit without name suffix",
+			heatPump: HeatPumpConfig{
+				Enabled: true, Protocol: "modbus", Address: "192.168.1.50:502", PollIntervalSeconds: 30, TimeoutSeconds: 5,
+				Metrics: []HeatPumpMetricConfig{{Name: "flow_temperature", Register: 100, Unit: "dC"}},
+			},
+			wantErr: "unit suffix",
+		},
+		{
+			name: "unit not matching name suffix",
+			heatPump: HeatPumpConfig{
+				Enabled: true, Protocol: "modbus", Address: "192.168.1.50:502", PollIntervalSeconds: 30, TimeoutSeconds: 5,
+				Metrics: []HeatPumpMetricConfig{{Name: "flow_temperature_celsius", Register: 100, Unit: "mV"}},
+			},
+			wantErr: "cannot convert",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := newConfig(tt.heatPump)
+			err := cfg.Validate()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("Expected no error, got: %v", err)
+				}
+				if cfg.HeatPump.Metrics[0].Words != 1 {
+					t.Errorf("Expected words to default to 1, got %d", cfg.HeatPump.Metrics[0].Words)
+				}
+				if cfg.HeatPump.Metrics[0].Scale != 0.1 {
+					t.Errorf("Expected scale 0.1, got %v", cfg.HeatPump.Metrics[0].Scale)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Expected error containing %q, got: %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestValidate_OneWire(t *testing.T) {
+	cfg := validConfig()
+	cfg.OneWire = OneWireConfig{
+		Enabled:             true,
+		DevicesPath:         "/sys/bus/w1/devices",
+		ReadIntervalSeconds: 30,
+		Sensors: []OneWireSensorConfig{
+			{Name: "Boiler", ID: 1, DeviceID: "28-0316A2796BFF"},
+		},
+	}
+
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	// Device IDs are normalized to the lowercase sysfs directory name
+	if cfg.OneWire.Sensors[0].DeviceID != "28-0316a2796bff" {
+		t.Errorf("Expected normalized device ID, got %s", cfg.OneWire.Sensors[0].DeviceID)
+	}
+
+	cfg.OneWire.Sensors = append(cfg.OneWire.Sensors, OneWireSensorConfig{Name: "Pipe", ID: 2, DeviceID: "28-0316a2796bff"})
+	if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), "duplicate device ID") {
+		t.Errorf("Expected duplicate device ID error, got: %v", err)
+	}
+
+	cfg.OneWire.Sensors = []OneWireSensorConfig{{Name: "Boiler", ID: 1, DeviceID: "boiler"}}
+	if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), "invalid device ID") {
+		t.Errorf("Expected invalid device ID error, got: %v", err)
+	}
+}
+
+func TestValidate_I2C(t *testing.T) {
+	cfg := validConfig()
+	cfg.I2C = I2CConfig{
+		Enabled:             true,
+		ReadIntervalSeconds: 30,
+		Sensors: []I2CSensorConfig{
+			{Name: "Office", ID: 1, Model: "BME280", Bus: 1, Address: 0x76},
+			{Name: "Bathroom", ID: 2, Model: "sht31", Bus: 1, Address: 0x44},
+		},
+	}
+
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if cfg.I2C.Sensors[0].Model != "bme280" {
+		t.Errorf("Expected normalized model bme280, got %s", cfg.I2C.Sensors[0].Model)
+	}
+
+	cfg.I2C.Sensors[1].Address = 0x76
+	if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), "duplicate address") {
+		t.Errorf("Expected duplicate address error, got: %v", err)
+	}
+
+	cfg.I2C.Sensors[1] = I2CSensorConfig{Name: "Bathroom", ID: 2, Model: "dht22", Bus: 1, Address: 0x44}
+	if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), "model") {
+		t.Errorf("Expected unsupported model error, got: %v", err)
+	}
+}
+
+func TestValidate_AirQuality(t *testing.T) {
+	cfg := validConfig()
+	cfg.AirQuality = AirQualityConfig{
+		Enabled:             true,
+		ReadIntervalSeconds: 30,
+		Sensors: []AirQualitySensorConfig{
+			{Name: "Living room", ID: 1, Model: "MHZ19", Device: "/dev/serial0"},
+			{Name: "Bedroom", ID: 2, Model: "scd4x", Bus: 1},
+		},
+	}
+
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if cfg.AirQuality.Sensors[0].Model != "mhz19" {
+		t.Errorf("Expected normalized model mhz19, got %s", cfg.AirQuality.Sensors[0].Model)
+	}
+	if cfg.AirQuality.Sensors[1].Address != 0x62 {
+		t.Errorf("Expected default SCD4x address 0x62, got 0x%02x", cfg.AirQuality.Sensors[1].Address)
+	}
+
+	cfg.AirQuality.Sensors[0].Device = ""
+	if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), "device is required") {
+		t.Errorf("Expected missing device error, got: %v", err)
+	}
+
+	cfg.AirQuality.Sensors[0] = AirQualitySensorConfig{Name: "Living room", ID: 1, Model: "ccs811"}
+	if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), "model") {
+		t.Errorf("Expected unsupported model error, got: %v", err)
+	}
+}
+
+func TestValidate_Zigbee2MQTT(t *testing.T) {
+	cfg := validConfig()
+	cfg.Zigbee2MQTT = Zigbee2MQTTConfig{
+		Enabled:   true,
+		BaseTopic: "zigbee2mqtt",
+		MQTT:      MQTTConfig{ClientID: "home-controller", KeepAliveSeconds: 60},
+	}
+
+	if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), "broker") {
+		t.Errorf("Expected missing broker error, got: %v", err)
+	}
+
+	cfg.Zigbee2MQTT.MQTT.Broker = "localhost:1883"
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("Expected no error, got: %v", err)
+	}
+
+	cfg.Zigbee2MQTT.MQTT.KeepAliveSeconds = 0
+	if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), "keep-alive") {
+		t.Errorf("Expected keep-alive error, got: %v", err)
+	}
+}
+
+func TestValidate_Forward(t *testing.T) {
+	cfg := validConfig()
+	cfg.BLE.Sensors = []SensorConfig{{Name: "Garage", ID: 9, MACAddress: "A4:C1:38:00:00:09"}}
+	cfg.Prometheus.URL, cfg.Prometheus.Username = "", ""
+	cfg.Forward = ForwardConfig{
+		Enabled:         true,
+		URL:             "http://home-controller.local:8080/api/readings",
+		IntervalSeconds: 10,
+	}
+
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("Expected no error without Prometheus settings when forwarding, got: %v", err)
+	}
+
+	cfg.Forward.Enabled = false
+	if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), "prometheus URL") {
+		t.Errorf("Expected prometheus URL error, got: %v", err)
+	}
+
+	cfg.Forward.Enabled = true
+	cfg.Ingest.Enabled = true
+	if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), "admin server") {
+		t.Errorf("Expected admin server error, got: %v", err)
+	}
+}
+
+func TestValidate_Leader(t *testing.T) {
+	cfg := validConfig()
+	cfg.Leader = LeaderConfig{Enabled: true, TTLSeconds: 30}
+
+	if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), "host the lease") {
+		t.Errorf("Expected lease host error, got: %v", err)
+	}
+
+	cfg.Leader.LeaseURL = "http://pi-1:8080/api/lease"
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("Expected no error with a lease URL, got: %v", err)
+	}
+
+	cfg.Leader.TTLSeconds = 1
+	if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), "TTL") {
+		t.Errorf("Expected TTL error, got: %v", err)
+	}
+
+	cfg.Leader.TTLSeconds = 30
+	cfg.Forward = ForwardConfig{Enabled: true, URL: "http://pi-1:8080/api/readings", IntervalSeconds: 10}
+	if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), "forwarding") {
+		t.Errorf("Expected forwarding error, got: %v", err)
+	}
+}
+
+func TestValidate_AdminAuth(t *testing.T) {
+	cfg := validConfig()
+	cfg.Admin = AdminConfig{
+		Enabled:       true,
+		ListenAddress: ":8080",
+		Auth:          AdminAuthConfig{Type: "basic", Username: "admin", Password: "${ADMIN_TEST_PASSWORD}"},
+	}
+
+	t.Setenv("ADMIN_TEST_PASSWORD", "secret")
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if cfg.Admin.Auth.Password != "secret" {
+		t.Errorf("Expected password to be expanded, got %q", cfg.Admin.Auth.Password)
+	}
+
+	cfg.Admin.AuthGroups = []AdminAuthGroupConfig{{PathPrefix: "/api/v1/write", Auth: AdminAuthConfig{Type: "mtls"}}}
+	if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), "client CA") {
+		t.Errorf("Expected client CA error, got: %v", err)
+	}
+
+	cfg.Admin.TLS = AdminTLSConfig{CertFile: "server.crt", KeyFile: "server.key", ClientCAFile: "ca.crt"}
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("Expected no error with a client CA, got: %v", err)
+	}
+
+	cfg.Admin.AuthGroups[0].PathPrefix = "api"
+	if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), "path prefix") {
+		t.Errorf("Expected path prefix error, got: %v", err)
+	}
+
+	cfg.Admin.AuthGroups = nil
+	cfg.Admin.Auth = AdminAuthConfig{Type: "token"}
+	if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), "type must be") {
+		t.Errorf("Expected auth type error, got: %v", err)
+	}
+}
+
+func TestValidate_Loki(t *testing.T) {
+	cfg := validConfig()
+	cfg.Logging.Loki = LokiConfig{
+		Enabled:              true,
+		Level:                "WARN",
+		Service:              "home-controller",
+		BatchSize:            100,
+		QueueSize:            1000,
+		FlushIntervalSeconds: 10,
+	}
+
+	if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), "loki URL") {
+		t.Errorf("Expected missing URL error, got: %v", err)
+	}
+
+	cfg.Logging.Loki.URL = "https://logs-prod-eu-west-0.grafana.net"
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if cfg.Logging.Loki.Level != "warn" {
+		t.Errorf("Expected normalized level warn, got %s", cfg.Logging.Loki.Level)
+	}
+
+	cfg.Logging.Loki.QueueSize = 10
+	if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), "queue size") {
+		t.Errorf("Expected queue size error, got: %v", err)
+	}
+}
+
+func TestValidate_LoadShedding(t *testing.T) {
+	cfg := validConfig()
+	cfg.Power = PowerConfig{
+		Enabled:               true,
+		ScrapeURL:             "http://192.168.1.50/api",
+		ScrapeIntervalSeconds: 2,
+		ScrapeTimeoutSeconds:  1.5,
+	}
+	cfg.Automation = AutomationConfig{
+		WebhookTimeoutSeconds: 5,
+		LoadShedding: LoadSheddingConfig{
+			Enabled: true,
+			Rules: []LoadShedRuleConfig{{
+				Name:              "water-heater",
+				ThresholdWatts:    7000,
+				ForSeconds:        30,
+				Shed:              WebhookConfig{URL: "http://shelly/rpc/Switch.Set?id=0&on=false"},
+				RestoreBelowWatts: 4000,
+				Restore:           WebhookConfig{Method: "get", URL: "http://shelly/rpc/Switch.Set?id=0&on=true"},
+			}},
+		},
+	}
+
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	rule := cfg.Automation.LoadShedding.Rules[0]
+	if rule.Shed.Method != "POST" || rule.Restore.Method != "GET" {
+		t.Errorf("Expected methods POST and GET, got %s and %s", rule.Shed.Method, rule.Restore.Method)
+	}
+
+	cfg.Automation.LoadShedding.Rules[0].RestoreBelowWatts = 8000
+	if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), "restore threshold") {
+		t.Errorf("Expected restore threshold error, got: %v", err)
+	}
+
+	cfg.Automation.LoadShedding.Rules[0].RestoreBelowWatts = 4000
+	cfg.Power.Enabled = false
+	if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), "power monitoring") {
+		t.Errorf("Expected power monitoring error, got: %v", err)
+	}
+}
+
+func TestValidate_Expressions(t *testing.T) {
+	cfg := validConfig()
+	cfg.Automation = AutomationConfig{
+		WebhookTimeoutSeconds: 5,
+		Expressions: ExpressionsConfig{
+			Enabled: true,
+			Rules: []ExpressionRuleConfig{{
+				Name:   "delta",
+				Vars:   map[string]SelectorConfig{"t": {Metric: "ble_temperature_celsius"}},
+				Expr:   "t - 20.0",
+				Metric: "temperature_delta_celsius",
+			}},
+		},
+	}
+
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	rule := &cfg.Automation.Expressions.Rules[0]
+	rule.Webhook = WebhookConfig{URL: "http://example.com/hook"}
+	if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), "exactly one of metric or webhook") {
+		t.Errorf("Expected metric or webhook error, got: %v", err)
+	}
+
+	rule.Metric = ""
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("Expected no error for webhook rule, got: %v", err)
+	}
+	if rule.Webhook.Method != "POST" {
+		t.Errorf("Expected webhook method POST, got %s", rule.Webhook.Method)
+	}
+
+	rule.Webhook = WebhookConfig{}
+	rule.Metric = "Invalid-Name"
+	if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), "invalid metric name") {
+		t.Errorf("Expected invalid metric name error, got: %v", err)
+	}
+
+	rule.Metric = "temperature_delta_celsius"
+	rule.Vars = nil
+	if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), "variable") {
+		t.Errorf("Expected variable error, got: %v", err)
+	}
+}
+
+func TestValidate_ExpressionFilters(t *testing.T) {
+	cfg := validConfig()
+	cfg.Automation = AutomationConfig{
+		WebhookTimeoutSeconds: 5,
+		Expressions: ExpressionsConfig{
+			Enabled: true,
+			Filters: []SignalFilterConfig{
+				{Name: "smooth", Type: "moving_average", WindowSeconds: 30},
+				{Name: "steady", Type: "debounce", HoldSeconds: 60},
+			},
+			Rules: []ExpressionRuleConfig{{
+				Name:    "overload",
+				Vars:    map[string]SelectorConfig{"p": {Metric: "active_power_watts", Filter: "smooth"}},
+				Expr:    "p > 3500",
+				Webhook: WebhookConfig{URL: "http://example.com/hook"},
+				Filter:  "steady",
+			}},
+		},
+	}
+
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	rule := cfg.Automation.Expressions.Rules[0]
+	specs := cfg.Automation.Expressions.VarFilters(rule)
+	if specs["p"].Type != "moving_average" || specs["p"].Window != 30*time.Second {
+		t.Errorf("Expected a 30s moving average for p, got %+v", specs["p"])
+	}
+	if output := cfg.Automation.Expressions.OutputFilter(rule); output == nil || output.Hold != time.Minute {
+		t.Errorf("Expected a 60s debounce of the result, got %+v", output)
+	}
+
+	cfg.Automation.Expressions.Rules[0].Filter = "missing"
+	if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), "unknown filter") {
+		t.Errorf("Expected unknown filter error, got: %v", err)
+	}
+	cfg.Automation.Expressions.Rules[0].Filter = ""
+	cfg.Automation.Expressions.Filters[0].Type = "median"
+	if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), "unknown filter type") {
+		t.Errorf("Expected unknown filter type error, got: %v", err)
+	}
+}
+
+func TestValidate_BLEProxy(t *testing.T) {
+	cfg := validConfig()
+	cfg.BLEProxy = BLEProxyConfig{
+		Enabled:   true,
+		BaseTopic: "ble_proxy",
+		MQTT:      MQTTConfig{ClientID: "home-controller", KeepAliveSeconds: 60},
+	}
+
+	if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), "MQTT broker or the admin server") {
+		t.Errorf("Expected missing transport error, got: %v", err)
+	}
+
+	cfg.Admin = AdminConfig{Enabled: true, ListenAddress: ":8080"}
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("Expected no error with HTTP only, got: %v", err)
+	}
+
+	cfg.BLEProxy.MQTT.Broker = "localhost:1883"
+	cfg.Zigbee2MQTT = Zigbee2MQTTConfig{
+		Enabled:   true,
+		BaseTopic: "zigbee2mqtt",
+		MQTT:      MQTTConfig{Broker: "localhost:1883", ClientID: "home-controller", KeepAliveSeconds: 60},
+	}
+	if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), "client ID") {
+		t.Errorf("Expected client ID conflict error, got: %v", err)
+	}
+
+	cfg.BLEProxy.MQTT.ClientID = "home-controller-ble-proxy"
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("Expected no error, got: %v", err)
+	}
+}
+
+func TestValidate_RoomFusion(t *testing.T) {
+	cfg := validConfig()
+	cfg.BLE.Sensors = []SensorConfig{{Name: "Bedroom", ID: 1, MACAddress: "A4:C1:38:00:00:01"}}
+	cfg.RoomFusion = RoomFusionConfig{
+		Enabled:      true,
+		StaleSeconds: 600,
+		Rooms: []RoomConfig{{
+			Name:      "bedroom",
+			Primary:   RoomSourceConfig{Metric: "ble_temperature_celsius", Labels: map[string]string{"sensor_name": "Bedroom"}},
+			Secondary: RoomSourceConfig{Metric: "netatmo_measured_temperature_celsius", Labels: map[string]string{"room_name": "Bedroom"}},
+		}},
+	}
+
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	// Two BLE sensors would both default to the ble source label
+	cfg.RoomFusion.Rooms[0].Secondary = RoomSourceConfig{Metric: "ble_temperature_celsius", Labels: map[string]string{"sensor_name": "Hall"}}
+	if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), "distinct source names") {
+		t.Errorf("Expected distinct source names error, got: %v", err)
+	}
+
+	cfg.RoomFusion.Rooms[0].Secondary.Name = "hall"
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("Expected no error, got: %v", err)
+	}
+
+	cfg.RoomFusion.Rooms[0].Primary.Metric = "room_temperature_celsius"
+	if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), "fused room temperatures") {
+		t.Errorf("Expected feedback error, got: %v", err)
+	}
+}
+
+func TestValidate_Locator(t *testing.T) {
+	cfg := validConfig()
+	cfg.Locator = LocatorConfig{
+		Enabled:               true,
+		ReportIntervalSeconds: 30,
+		StaleSeconds:          120,
+		HysteresisDB:          5,
+		Beacons:               []BeaconConfig{{Name: "keys", MACAddress: "C2:00:00:00:00:01"}},
+	}
+
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	// A beacon sharing a sensor's MAC would be tracked twice
+	cfg.Locator.Beacons[0].MACAddress = "a4:c1:38:00:00:01"
+	if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), "duplicate MAC address") {
+		t.Errorf("Expected duplicate MAC error, got: %v", err)
+	}
+
+	cfg.Locator.Beacons[0].MACAddress = "C2:00:00:00:00:01"
+	cfg.Locator.HysteresisDB = -1
+	if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), "hysteresis") {
+		t.Errorf("Expected hysteresis error, got: %v", err)
+	}
+}
+
+func TestValidate_Report(t *testing.T) {
+	cfg := validConfig()
+	cfg.Report = ReportConfig{Enabled: true, SendAt: "07:00", Currency: "PLN"}
+
+	// A report nobody receives is a misconfiguration
+	if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), "notification channel") {
+		t.Errorf("Expected notification channel error, got: %v", err)
+	}
+
+	cfg.Notify.Email = EmailConfig{Enabled: true, Host: "smtp.example.com", Port: 587, From: "home@example.com"}
+	if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), "recipient") {
+		t.Errorf("Expected recipient error, got: %v", err)
+	}
+
+	cfg.Notify.Email.To = []string{"me@example.com"}
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	cfg.Report.SendAt = "7am"
+	if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), "HH:MM") {
+		t.Errorf("Expected send time error, got: %v", err)
+	}
+
+	cfg.Report.SendAt = "07:00"
+	cfg.Notify.Telegram.Enabled = true
+	if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), "bot token") {
+		t.Errorf("Expected bot token error, got: %v", err)
+	}
+}
+
+func TestValidate_History(t *testing.T) {
+	cfg := validConfig()
+	cfg.History = HistoryConfig{Enabled: true, Backend: "SQLite", Dir: "/data/history", RetentionDays: 30, FlushIntervalSeconds: 60}
+
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if cfg.History.Backend != "sqlite" {
+		t.Errorf("Expected backend normalized to sqlite, got %s", cfg.History.Backend)
+	}
+
+	cfg.History.Backend = "postgres"
+	if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), "history backend") {
+		t.Errorf("Expected backend error, got: %v", err)
+	}
+}
+
+func TestValidate_Pstryk(t *testing.T) {
+	cfg := validConfig()
+	cfg.Power = PowerConfig{Enabled: true, ScrapeURL: "http://meter.local", ScrapeIntervalSeconds: 2, ScrapeTimeoutSeconds: 1.5, Auth: HTTPAuthConfig{Type: "none"}}
+	cfg.Pstryk = PstrykConfig{Enabled: true, Token: "secret", IntervalSeconds: 3600, LookbackHours: 48, DriftAlertPercent: 10}
+
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	cfg.Pstryk.Token = ""
+	if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), "pstryk token") {
+		t.Errorf("Expected token error, got: %v", err)
+	}
+
+	cfg.Pstryk.Token = "secret"
+	cfg.Power.Enabled = false
+	if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), "requires power monitoring") {
+		t.Errorf("Expected power monitoring error, got: %v", err)
+	}
+}
+
+func TestValidate_Prices(t *testing.T) {
+	cfg := validConfig()
+	cfg.Prices = PricesConfig{Enabled: true, Source: "PSE", EURPLNRate: 4.25, FetchIntervalSeconds: 3600, ReportIntervalSeconds: 300, HorizonHours: 24}
+
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if cfg.Prices.Source != "pse" {
+		t.Errorf("Expected source normalized to pse, got %s", cfg.Prices.Source)
+	}
+
+	cfg.Prices.Source = "entsoe"
+	if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), "ENTSO-E token") {
+		t.Errorf("Expected token error, got: %v", err)
+	}
+
+	cfg.Prices.Source = "nordpool"
+	if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), "invalid price source") {
+		t.Errorf("Expected source error, got: %v", err)
+	}
+}
+
+func TestValidate_PriceRules(t *testing.T) {
+	below := 0.3
+	cfg := validConfig()
+	cfg.Prices = PricesConfig{Enabled: true, Source: "pse", FetchIntervalSeconds: 3600, ReportIntervalSeconds: 300, HorizonHours: 24}
+	cfg.Automation = AutomationConfig{
+		WebhookTimeoutSeconds: 5,
+		PriceRules: PriceRulesConfig{
+			Enabled:                 true,
+			EvaluateIntervalSeconds: 60,
+			Rules: []PriceRuleConfig{{
+				Name:           "boiler",
+				BelowPLNPerKWh: &below,
+				CheapestHours:  3,
+				Enable:         WebhookConfig{URL: "http://192.168.1.62/on", Method: "get"},
+			}},
+		},
+	}
+
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if cfg.Automation.PriceRules.Rules[0].Enable.Method != "GET" {
+		t.Errorf("Expected method normalized to GET, got %s", cfg.Automation.PriceRules.Rules[0].Enable.Method)
+	}
+
+	cfg.Automation.PriceRules.Rules[0].BelowPLNPerKWh = nil
+	cfg.Automation.PriceRules.Rules[0].CheapestHours = 0
+	if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), "belowPlnPerKwh or cheapestHours") {
+		t.Errorf("Expected condition error, got: %v", err)
+	}
+
+	cfg.Automation.PriceRules.Rules[0].CheapestHours = 3
+	cfg.Prices.Enabled = false
+	if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), "require electricity prices") {
+		t.Errorf("Expected prices error, got: %v", err)
+	}
+}
+
+func TestValidate_PushWatermark(t *testing.T) {
+	cfg := validConfig()
+	cfg.Prometheus.PushWatermarkPercent = 80
+
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	cfg.Prometheus.PushWatermarkPercent = 120
+	if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), "push watermark") {
+		t.Errorf("Expected push watermark error, got: %v", err)
+	}
+}
+
+func TestValidate_Scheduling(t *testing.T) {
+	cfg := validConfig()
+	cfg.Scheduling = SchedulingConfig{AlignToWallClock: true, ScrapeOffsetSeconds: -2}
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("Expected valid scheduling config, got %v", err)
+	}
+
+	cfg.Scheduling.ScrapeOffsetSeconds = 90
+	if err := cfg.Validate(); err == nil {
+		t.Error("Expected an error for a scrape offset beyond a minute")
+	}
+}
+
+func TestValidate_NATS(t *testing.T) {
+	cfg := validConfig()
+	cfg.NATS = NATSConfig{
+		Enabled:           true,
+		Server:            "nats.local:4222",
+		SubjectPrefix:     "home.readings",
+		Encoding:          "protobuf",
+		AckTimeoutSeconds: 10,
+		IntervalSeconds:   10,
+		QueueSize:         10000,
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("Expected valid NATS config, got %v", err)
+	}
+
+	cfg.NATS.SubjectPrefix = "home.>"
+	if err := cfg.Validate(); err == nil {
+		t.Error("Expected an error for a wildcard subject prefix")
+	}
+	cfg.NATS.SubjectPrefix = "home.readings"
+	cfg.NATS.Encoding = "avro"
+	if err := cfg.Validate(); err == nil {
+		t.Error("Expected an error for an unknown encoding")
+	}
+}
+
+func TestValidate_Occupancy(t *testing.T) {
+	cfg := validConfig()
+	cfg.Occupancy = OccupancyConfig{
+		Enabled:               true,
+		Source:                "schedule",
+		Schedule:              []OccupancyPeriodConfig{{Days: "mon-fri", From: "16:00", To: "08:00"}},
+		AwayScrapeMultiplier:  4,
+		ReportIntervalSeconds: 60,
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("Expected valid occupancy config, got %v", err)
+	}
+
+	cfg.Occupancy.Schedule[0].Days = "weekdays"
+	if err := cfg.Validate(); err == nil {
+		t.Error("Expected an error for an unknown day")
+	}
+	cfg.Occupancy.Schedule[0].Days = "mon-fri"
+	cfg.Occupancy.Source = "presence"
+	if err := cfg.Validate(); err == nil {
+		t.Error("Expected an error for the presence source without locator beacons")
+	}
+	cfg.Locator = LocatorConfig{Enabled: true, ReportIntervalSeconds: 30, StaleSeconds: 120, Beacons: []BeaconConfig{{Name: "keys", MACAddress: "C0:00:00:00:00:01"}}}
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("Expected valid presence config, got %v", err)
+	}
+}
+
+func TestValidate_Mode(t *testing.T) {
+	cfg := validConfig()
+	cfg.Mode = ModeConfig{Enabled: true, StateFile: "/data/mode.json", VacationHeatingMode: "hg"}
+	if err := cfg.Validate(); err == nil {
+		t.Error("Expected an error for the mode switch without the admin server")
+	}
+
+	cfg.Admin = AdminConfig{Enabled: true, ListenAddress: ":8080"}
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("Expected valid mode config, got %v", err)
+	}
+	cfg.Mode.VacationHeatingMode = "off"
+	if err := cfg.Validate(); err == nil {
+		t.Error("Expected an error for an unknown heating mode")
+	}
+}
+
+func TestValidate_FrostProtection(t *testing.T) {
+	cfg := validConfig()
+	cfg.FrostProtection = FrostProtectionConfig{Enabled: true, FloorCelsius: 7, SetpointCelsius: 12, HoldMinutes: 60}
+	if err := cfg.Validate(); err == nil {
+		t.Error("Expected an error for frost protection without Netatmo")
+	}
+
+	cfg.Netatmo = NetatmoConfig{Enabled: true, ClientID: "id", ClientSecret: "secret", RefreshToken: "token", FetchInterval: 60}
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("Expected valid frost protection config, got %v", err)
+	}
+	cfg.FrostProtection.SetpointCelsius = 5
+	if err := cfg.Validate(); err == nil {
+		t.Error("Expected an error for a setpoint Netatmo doesn't accept")
+	}
+}
+
+func TestValidate_RemoteConfig(t *testing.T) {
+	cfg := validConfig()
+	cfg.RemoteConfig = RemoteConfigConfig{
+		Enabled:         true,
+		URL:             "https://raw.githubusercontent.com/example/fleet/main/config.yaml",
+		IntervalSeconds: 300,
+		CacheFile:       "/data/remote-config.yaml",
+	}
+	if err := cfg.Validate(); err == nil {
+		t.Error("Expected an error for remote config without verification")
+	}
+
+	cfg.RemoteConfig.PublicKey = "not a key"
+	if err := cfg.Validate(); err == nil {
+		t.Error("Expected an error for an invalid public key")
+	}
+
+	cfg.RemoteConfig.PublicKey = "11qYAYKxCrfVS/7TyWQHOg7hcvPapiMlrwIaaPcHURo="
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("Expected valid remote config, got %v", err)
+	}
+}
+
+func TestValidate_Features(t *testing.T) {
+	cfg := validConfig()
+	cfg.Features = FeaturesConfig{Enabled: []string{"zstd", "adaptive_intervals"}}
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("Expected known feature flags to be valid, got %v", err)
+	}
+
+	cfg.Features.Enabled = append(cfg.Features.Enabled, "zstd2")
+	if err := cfg.Validate(); err == nil {
+		t.Error("Expected an error for an unknown feature flag")
+	}
+}
+
+func TestValidate_Coalesce(t *testing.T) {
+	cfg := validConfig()
+	cfg.Prometheus.CoalesceMetrics = []string{"ble_battery_percent", "netatmo_setpoint_temperature_celsius"}
+	cfg.Prometheus.CoalesceMaxStalenessSeconds = 240
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("Expected coalesced metrics to be valid, got %v", err)
+	}
+
+	cfg.Prometheus.CoalesceMaxStalenessSeconds = 10
+	if err := cfg.Validate(); err == nil {
+		t.Error("Expected an error for a max staleness below the push interval")
+	}
+
+	cfg.Prometheus.CoalesceMaxStalenessSeconds = 240
+	cfg.Prometheus.CoalesceMetrics = []string{"BLE Battery"}
+	if err := cfg.Validate(); err == nil {
+		t.Error("Expected an error for an invalid metric name")
+	}
+}
+
+func TestValidate_Ventilation(t *testing.T) {
+	cfg := validConfig()
+	cfg.Automation = AutomationConfig{
+		WebhookTimeoutSeconds: 5,
+		Ventilation: VentilationConfig{
+			Enabled:                    true,
+			IndoorSensors:              []string{"living_room", "bathroom"},
+			OutdoorSensor:              "balcony",
+			OnDeltaGramsPerCubicMeter:  2,
+			OffDeltaGramsPerCubicMeter: 0.5,
+			MinIndoorHumidityPercent:   45,
+			EvaluateIntervalSeconds:    60,
+			On:                         WebhookConfig{URL: "http://hrv.local/boost"},
+		},
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("Expected valid ventilation config, got %v", err)
+	}
+	if cfg.Automation.Ventilation.On.Method != "POST" {
+		t.Errorf("Expected webhook method to default to POST, got %s", cfg.Automation.Ventilation.On.Method)
+	}
+
+	cfg.Automation.Ventilation.OffDeltaGramsPerCubicMeter = 2
+	if err := cfg.Validate(); err == nil {
+		t.Error("Expected an error for an off delta not below the on delta")
+	}
+
+	cfg.Automation.Ventilation.OffDeltaGramsPerCubicMeter = 0.5
+	cfg.Automation.Ventilation.OutdoorSensor = "bathroom"
+	if err := cfg.Validate(); err == nil {
+		t.Error("Expected an error for a sensor both indoor and outdoor")
+	}
+}
+
+func TestEnabledCollectors(t *testing.T) {
+	cfg := validConfig()
+	cfg.OneWire = OneWireConfig{
+		Enabled:             true,
+		DevicesPath:         "/sys/bus/w1/devices",
+		ReadIntervalSeconds: 30,
+		Sensors:             []OneWireSensorConfig{{Name: "boiler", ID: 1, DeviceID: "28-0316a2796bff"}},
+	}
+	cfg.Collectors = []CollectorConfig{{Name: "i2c", IntervalSeconds: 60, Options: map[string]interface{}{"sensors": []interface{}{}}}}
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("Expected valid collectors, got %v", err)
+	}
+
+	collectors := cfg.EnabledCollectors()
+	if len(collectors) != 2 || collectors[0].Name != "onewire" || collectors[0].IntervalSeconds != 30 || collectors[1].Name != "i2c" {
+		t.Errorf("Expected the onewire section and the listed i2c collector, got %+v", collectors)
+	}
+
+	cfg.Collectors = append(cfg.Collectors, CollectorConfig{Name: "onewire", IntervalSeconds: 30})
+	if err := cfg.Validate(); err == nil {
+		t.Error("Expected an error for a collector also enabled in its own section")
+	}
+
+	cfg.Collectors = []CollectorConfig{{Name: "i2c"}}
+	if err := cfg.Validate(); err == nil {
+		t.Error("Expected an error for a collector without an interval")
+	}
+}
+
+func TestValidate_FaultInjection(t *testing.T) {
+	cfg := validConfig()
+	cfg.FaultInjection = FaultInjectionConfig{Enabled: true, MaxDurationSeconds: 3600}
+	if err := cfg.Validate(); err == nil {
+		t.Error("Expected an error for fault injection without the admin server")
+	}
+
+	cfg.Admin = AdminConfig{Enabled: true, ListenAddress: ":8080", Auth: AdminAuthConfig{Type: "none"}}
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("Expected valid fault injection config, got %v", err)
+	}
+
+	cfg.FaultInjection.MaxDurationSeconds = 0
+	if err := cfg.Validate(); err == nil {
+		t.Error("Expected an error for a zero max duration")
+	}
+}
+
+func TestValidate_Runtime(t *testing.T) {
+	cfg := validConfig()
+	cfg.Runtime = RuntimeConfig{Preset: "pi-zero", GCPercent: -1}
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("Expected GOGC off with the preset memory limit to be valid, got %v", err)
+	}
+
+	cfg.Runtime.Preset = ""
+	if err := cfg.Validate(); err == nil {
+		t.Error("Expected an error for GOGC off without a memory limit")
+	}
+
+	cfg.Runtime = RuntimeConfig{Preset: "pi5"}
+	if err := cfg.Validate(); err == nil {
+		t.Error("Expected an error for an unknown preset")
+	}
+}
+
+func TestValidate_Watchdog(t *testing.T) {
+	cfg := validConfig()
+	cfg.Watchdog = WatchdogConfig{Enabled: true, CheckIntervalSeconds: 10, StallAfterSeconds: 300, ReadingTypes: []string{"ble", "power"}}
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("Expected valid watchdog config, got %v", err)
+	}
+
+	cfg.Watchdog.ReadingTypes = []string{"bluetooth"}
+	if err := cfg.Validate(); err == nil {
+		t.Error("Expected an error for an unknown reading type")
+	}
+
+	cfg.Watchdog.ReadingTypes = nil
+	cfg.Watchdog.StallAfterSeconds = 15
+	if err := cfg.Validate(); err == nil {
+		t.Error("Expected an error for a stall timeout below twice the check interval")
+	}
+}
+
+func TestValidate_Startup(t *testing.T) {
+	cfg := validConfig()
+	cfg.Startup = StartupConfig{MaxWaitSeconds: 120, MaxBackoffSeconds: 15, WaitForBLE: true}
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("Expected valid startup config, got %v", err)
+	}
+
+	cfg.Startup.MaxBackoffSeconds = 0
+	if err := cfg.Validate(); err == nil {
+		t.Error("Expected an error for a zero max backoff")
+	}
+
+	// Without waiting the backoff is unused
+	cfg.Startup.MaxWaitSeconds = 0
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("Expected no error with gating disabled, got %v", err)
+	}
+
+	cfg.Startup.MaxWaitSeconds = -1
+	if err := cfg.Validate(); err == nil {
+		t.Error("Expected an error for a negative max wait")
+	}
+}
+
+func TestValidate_DNS(t *testing.T) {
+	cfg := validConfig()
+	cfg.DNS = DNSConfig{CacheEnabled: true, CacheTTLSeconds: 60, FallbackDelayMs: 300, RecycleAfterFailures: 3}
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("Expected valid DNS config, got %v", err)
+	}
+
+	cfg.DNS.CacheTTLSeconds = 0
+	if err := cfg.Validate(); err == nil {
+		t.Error("Expected an error for a zero cache TTL")
+	}
+
+	cfg.DNS.CacheTTLSeconds = 60
+	cfg.DNS.RecycleAfterFailures = -1
+	if err := cfg.Validate(); err == nil {
+		t.Error("Expected an error for negative recycle failures")
+	}
+}
+
+func TestValidate_Network(t *testing.T) {
+	cfg := validConfig()
+	cfg.Network = NetworkConfig{AddressFamily: "V4"}
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("Expected valid network config, got %v", err)
+	}
+	if cfg.Network.AddressFamily != "v4" {
+		t.Errorf("Expected the address family lower-cased, got %s", cfg.Network.AddressFamily)
+	}
+
+	cfg.Network.AddressFamily = ""
+	if err := cfg.Validate(); err != nil || cfg.Network.AddressFamily != "dual" {
+		t.Errorf("Expected an empty address family to default to dual, got %s, %v", cfg.Network.AddressFamily, err)
+	}
+
+	cfg.Network.AddressFamily = "ipv6"
+	if err := cfg.Validate(); err == nil {
+		t.Error("Expected an error for an unknown address family")
+	}
+
+	cfg.Network.AddressFamily = "dual"
+	cfg.HTTPClient.ProxyURL = "socks5://127.0.0.1:1080"
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("Expected a SOCKS proxy to be valid, got %v", err)
+	}
+	cfg.HTTPClient.ProxyURL = "proxy.lan:3128"
+	if err := cfg.Validate(); err == nil {
+		t.Error("Expected an error for a proxy URL without a scheme")
+	}
+}
+
+func TestValidate_ConnectionMetrics(t *testing.T) {
+	cfg := validConfig()
+	cfg.Prometheus.MaxIdleConns = 2
+	cfg.Prometheus.IdleConnTimeoutSeconds = 300
+	cfg.Telemetry = TelemetryConfig{DependencyMetrics: true, ReportIntervalSeconds: 60, ConnectionMetrics: true}
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("Expected valid connection metrics config, got %v", err)
+	}
+
+	cfg.Telemetry.DependencyMetrics = false
+	if err := cfg.Validate(); err == nil {
+		t.Error("Expected an error for connection metrics without dependency metrics")
+	}
+
+	cfg.Telemetry.DependencyMetrics = true
+	cfg.Prometheus.IdleConnTimeoutSeconds = -1
+	if err := cfg.Validate(); err == nil {
+		t.Error("Expected an error for a negative idle connection timeout")
+	}
+}
+
+func TestValidate_NetatmoTimestampSource(t *testing.T) {
+	cfg := validConfig()
+	cfg.Netatmo = NetatmoConfig{Enabled: true, ClientID: "id", ClientSecret: "secret", RefreshToken: "token", FetchInterval: 60}
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("Expected an empty timestamp source to be valid, got %v", err)
+	}
+	if cfg.Netatmo.TimestampSource != "local" {
+		t.Errorf("Expected the local timestamp source by default, got %s", cfg.Netatmo.TimestampSource)
+	}
+
+	cfg.Netatmo.TimestampSource = "Server"
+	if err := cfg.Validate(); err != nil || cfg.Netatmo.TimestampSource != "server" {
+		t.Errorf("Expected the server timestamp source, got %s, %v", cfg.Netatmo.TimestampSource, err)
+	}
+	cfg.Netatmo.TimestampSource = "ntp"
+	if err := cfg.Validate(); err == nil {
+		t.Error("Expected an error for an unknown timestamp source")
+	}
+}
+
+func TestValidate_NetatmoBackfill(t *testing.T) {
+	cfg := validConfig()
+	cfg.Netatmo = NetatmoConfig{Enabled: true, ClientID: "id", ClientSecret: "secret", RefreshToken: "token", FetchInterval: 60}
+	cfg.Netatmo.Backfill = NetatmoBackfillConfig{Enabled: true, StateFile: "/data/netatmo_backfill.json", WindowSeconds: 3600}
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("Expected a valid backfill config, got %v", err)
+	}
+
+	cfg.Netatmo.Backfill.WindowSeconds = 0
+	if err := cfg.Validate(); err == nil {
+		t.Error("Expected an error for a zero backfill window")
+	}
+	cfg.Netatmo.Backfill.WindowSeconds = 3600
+	cfg.Netatmo.Backfill.StateFile = ""
+	if err := cfg.Validate(); err == nil {
+		t.Error("Expected an error for a backfill without a state file")
+	}
+}
+
+func TestValidate_BLEBacklog(t *testing.T) {
+	cfg := validConfig()
+	cfg.BLE.Backlog = BLEBacklogConfig{Mode: "Spread", GapSeconds: 10, SettleSeconds: 30}
+	if err := cfg.Validate(); err != nil || cfg.BLE.Backlog.Mode != "spread" {
+		t.Fatalf("Expected a valid spread backlog, got %s, %v", cfg.BLE.Backlog.Mode, err)
+	}
+
+	cfg.BLE.Backlog.Mode = "drop"
+	if err := cfg.Validate(); err == nil {
+		t.Error("Expected an error for a drop backlog without a max age")
+	}
+	cfg.BLE.Backlog.MaxAgeSeconds = 120
+	cfg.BLE.Backlog.GapSeconds = 1
+	if err := cfg.Validate(); err == nil {
+		t.Error("Expected an error for a gap not longer than the heartbeat")
+	}
+	cfg.BLE.Backlog.Mode = "smooth"
+	if err := cfg.Validate(); err == nil {
+		t.Error("Expected an error for an unknown mode")
+	}
+}
+
+func TestValidate_EventsLoki(t *testing.T) {
+	cfg := validConfig()
+	cfg.Events.Loki.Enabled = true
+	if err := cfg.Validate(); err == nil {
+		t.Error("Expected an error for events pushed to Loki without a Loki URL")
+	}
+
+	// Log shipping stays disabled; the sink only uses its URL
+	cfg.Logging.Loki.URL = "https://logs.example.com"
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("Expected valid config, got %v", err)
+	}
+}
+
+func TestBLEReceiverName(t *testing.T) {
+	cfg := Config{}
+	if got := cfg.BLEReceiverName(); got != "local" {
+		t.Errorf("Expected local, got %s", got)
+	}
+
+	cfg.Fleet.DeviceName = "pi-garage"
+	if got := cfg.BLEReceiverName(); got != "pi-garage" {
+		t.Errorf("Expected device name pi-garage, got %s", got)
+	}
+
+	cfg.BLE.ReceiverName = "garage"
+	if got := cfg.BLEReceiverName(); got != "garage" {
+		t.Errorf("Expected configured name garage, got %s", got)
+	}
+}
+
+func TestLoad_FleetLabels(t *testing.T) {
+	tmpDir := t.TempDir()
+	configPath := filepath.Join(tmpDir, "config.yaml")
+
+	configContent := `
+ble:
+  sensors:
+    - name: Sensor1
+      id: 1
+      macAddress: "A4:C1:38:00:00:01"
+prometheus:
+  pushIntervalSeconds: 15
+  prometheusUrl: "https://example.com"
+  prometheusUsername: "user"
+`
+	if err := os.WriteFile(configPath, []byte(configContent), 0644); err != nil {
+		t.Fatalf("Failed to create test config file: %v", err)
+	}
+
+	// Set by the balena supervisor; BALENA_APP_NAME is left unset
+	t.Setenv("BALENA_DEVICE_UUID", "0123456789abcdef")
+	t.Setenv("BALENA_DEVICE_NAME_AT_INIT", "pi-garage")
+
+	cfg, err := Load(configPath)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	labels := cfg.Fleet.ExternalLabels()
+	if len(labels) != 2 || labels["device_uuid"] != "0123456789abcdef" || labels["device"] != "pi-garage" {
+		t.Errorf("Expected device_uuid and device labels, got %v", labels)
+	}
+
+	cfg.Fleet.Labels = false
+	if labels := cfg.Fleet.ExternalLabels(); labels != nil {
+		t.Errorf("Expected no labels when disabled, got %v", labels)
+	}
+}
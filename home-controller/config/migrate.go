@@ -0,0 +1,194 @@
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// legacyPrometheusKeys are top-level keys of the legacy flat format that moved under prometheus
+var legacyPrometheusKeys = []string{
+	"pushIntervalSeconds",
+	"prometheusUrl",
+	"prometheusUsername",
+	"prometheusPassword",
+	"startAtEvenSecond",
+	"bufferSize",
+	"batchSize",
+}
+
+// legacyLoggingKeys are top-level keys of the legacy flat format that moved under logging
+var legacyLoggingKeys = []string{"logFormat", "logLevel"}
+
+// legacyDroppedKeys are legacy settings without an equivalent, with the reason shown to the user
+var legacyDroppedKeys = map[string]string{
+	"scanIntervalSeconds": "BLE scanning is continuous",
+	"metricName":          "BLE temperatures are always pushed as ble_temperature_celsius",
+}
+
+// Migrate converts a configuration in the legacy flat format, with top-level prometheus and
+// logging keys and sensors as a list of MAC addresses, to the current sectioned format
+// It returns the new YAML with comments and warnings about settings that could not be carried over
+func Migrate(data []byte) ([]byte, []string, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, nil, fmt.Errorf("failed to parse config: %w", err)
+	}
+	if len(doc.Content) == 0 || doc.Content[0].Kind != yaml.MappingNode {
+		return nil, nil, fmt.Errorf("config must be a YAML mapping")
+	}
+	old := doc.Content[0]
+	if mappingValue(old, "ble") != nil || mappingValue(old, "prometheus") != nil {
+		return nil, nil, fmt.Errorf("config is already in the current format")
+	}
+
+	var warnings []string
+	used := make(map[string]bool)
+	root := &yaml.Node{Kind: yaml.MappingNode}
+
+	// BLE sensors
+	sensors := mappingValue(old, "sensors")
+	used["sensors"] = true
+	if sensors == nil || sensors.Kind != yaml.SequenceNode || len(sensors.Content) == 0 {
+		return nil, nil, fmt.Errorf("legacy config has no sensors list")
+	}
+	sensorList := &yaml.Node{Kind: yaml.SequenceNode}
+	generatedNames := false
+	for i, item := range sensors.Content {
+		sensor, generated, err := migrateSensor(item, i)
+		if err != nil {
+			return nil, nil, err
+		}
+		generatedNames = generatedNames || generated
+		sensorList.Content = append(sensorList.Content, sensor)
+	}
+	if generatedNames {
+		sensorList.HeadComment = "Names were generated by config migrate; rename them, they become the sensor_name label"
+		warnings = append(warnings, "sensor names were generated from their position; rename them before deploying")
+	}
+	ble := &yaml.Node{Kind: yaml.MappingNode}
+	appendPair(ble, "sensors", sensorList, "")
+	appendPair(root, "ble", ble, "BLE scanning configuration")
+
+	// Prometheus and logging settings keep their names, moved into sections
+	for _, section := range []struct {
+		name    string
+		keys    []string
+		comment string
+	}{
+		{"prometheus", legacyPrometheusKeys, "Prometheus remote_write configuration"},
+		{"logging", legacyLoggingKeys, "Logging configuration"},
+	} {
+		node := &yaml.Node{Kind: yaml.MappingNode}
+		for _, key := range section.keys {
+			used[key] = true
+			if value := mappingValue(old, key); value != nil {
+				appendPair(node, key, value, "")
+			}
+		}
+		if len(node.Content) > 0 {
+			appendPair(root, section.name, node, section.comment)
+		}
+	}
+
+	// Settings without an equivalent and unknown keys are reported rather than silently dropped
+	var dropped []string
+	for i := 0; i+1 < len(old.Content); i += 2 {
+		key := old.Content[i].Value
+		if used[key] {
+			continue
+		}
+		if reason, ok := legacyDroppedKeys[key]; ok {
+			dropped = append(dropped, fmt.Sprintf("%s dropped: %s", key, reason))
+			continue
+		}
+		dropped = append(dropped, fmt.Sprintf("%s dropped: unknown legacy setting", key))
+	}
+	sort.Strings(dropped)
+	warnings = append(warnings, dropped...)
+
+	var encoded bytes.Buffer
+	encoder := yaml.NewEncoder(&encoded)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(root); err != nil {
+		return nil, nil, fmt.Errorf("failed to encode config: %w", err)
+	}
+	if err := encoder.Close(); err != nil {
+		return nil, nil, fmt.Errorf("failed to encode config: %w", err)
+	}
+
+	// Separate the sections with blank lines, as in config.yaml
+	var out bytes.Buffer
+	out.WriteString("# Migrated from the legacy flat format by config migrate\n")
+	out.WriteString("# See config.yaml in the repository for all available settings\n")
+	for _, line := range strings.SplitAfter(encoded.String(), "\n") {
+		if strings.HasPrefix(line, "# ") {
+			out.WriteString("\n")
+		}
+		out.WriteString(line)
+	}
+	return out.Bytes(), warnings, nil
+}
+
+// migrateSensor converts a legacy sensor, either a MAC address or a mapping with a MAC and
+// optional name and ID, to a sensor entry; it reports whether the name was generated
+func migrateSensor(item *yaml.Node, index int) (*yaml.Node, bool, error) {
+	id := strconv.Itoa(index + 1)
+	name := ""
+	var mac string
+	switch item.Kind {
+	case yaml.ScalarNode:
+		mac = item.Value
+	case yaml.MappingNode:
+		for _, key := range []string{"macAddress", "mac"} {
+			if value := mappingValue(item, key); value != nil {
+				mac = value.Value
+			}
+		}
+		if value := mappingValue(item, "name"); value != nil {
+			name = value.Value
+		}
+		if value := mappingValue(item, "id"); value != nil {
+			id = value.Value
+		}
+	}
+	if !macAddressRegex.MatchString(mac) {
+		return nil, false, fmt.Errorf("sensor %d: invalid MAC address %q", index+1, mac)
+	}
+
+	generated := name == ""
+	if generated {
+		name = "sensor_" + id
+	}
+	sensor := &yaml.Node{Kind: yaml.MappingNode}
+	appendPair(sensor, "name", scalar(name, yaml.DoubleQuotedStyle), "")
+	appendPair(sensor, "id", &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!int", Value: id}, "")
+	appendPair(sensor, "macAddress", scalar(strings.ToUpper(mac), yaml.DoubleQuotedStyle), "")
+	return sensor, generated, nil
+}
+
+// mappingValue returns the value of key in a mapping node, or nil
+func mappingValue(mapping *yaml.Node, key string) *yaml.Node {
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		if mapping.Content[i].Value == key {
+			return mapping.Content[i+1]
+		}
+	}
+	return nil
+}
+
+// appendPair appends key: value to a mapping node, with an optional comment above the key
+func appendPair(mapping *yaml.Node, key string, value *yaml.Node, comment string) {
+	keyNode := scalar(key, 0)
+	keyNode.HeadComment = comment
+	mapping.Content = append(mapping.Content, keyNode, value)
+}
+
+// scalar returns a string scalar node
+func scalar(value string, style yaml.Style) *yaml.Node {
+	return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: value, Style: style}
+}
@@ -0,0 +1,146 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const legacyConfig = `
+# BLE sensors to monitor
+scanIntervalSeconds: 60
+sensors:
+  - "a4:c1:38:00:00:01"
+  - "A4:C1:38:00:00:02"
+
+# Prometheus metrics push configuration
+pushIntervalSeconds: 15
+prometheusUrl: "https://prometheus-prod-01-eu-west-0.grafana.net/api/prom/push"
+prometheusUsername: "123456"
+prometheusPassword: "test-password" # Use PROMETHEUS_PASSWORD env var
+metricName: "ble_temperature"
+startAtEvenSecond: true
+bufferSize: 1000
+
+logFormat: "console"
+logLevel: "debug"
+`
+
+func TestMigrate_LegacyConfig(t *testing.T) {
+	migrated, warnings, err := Migrate([]byte(legacyConfig))
+	if err != nil {
+		t.Fatalf("Migrate failed: %v", err)
+	}
+
+	// The migrated config must load and validate in the current format
+	configPath := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(configPath, migrated, 0644); err != nil {
+		t.Fatalf("Failed to write migrated config: %v", err)
+	}
+	cfg, err := Load(configPath)
+	if err != nil {
+		t.Fatalf("Migrated config failed to load: %v\n%s", err, migrated)
+	}
+
+	if len(cfg.BLE.Sensors) != 2 {
+		t.Fatalf("Expected 2 sensors, got %d", len(cfg.BLE.Sensors))
+	}
+	if cfg.BLE.Sensors[0].Name != "sensor_1" || cfg.BLE.Sensors[0].ID != 1 {
+		t.Errorf("Expected sensor_1 with ID 1, got %s with ID %d", cfg.BLE.Sensors[0].Name, cfg.BLE.Sensors[0].ID)
+	}
+	if cfg.BLE.Sensors[0].MACAddress != "A4:C1:38:00:00:01" {
+		t.Errorf("Expected upper-case MAC address, got %s", cfg.BLE.Sensors[0].MACAddress)
+	}
+	if cfg.Prometheus.PushIntervalSeconds != 15 {
+		t.Errorf("Expected push interval 15, got %d", cfg.Prometheus.PushIntervalSeconds)
+	}
+	if cfg.Prometheus.Username != "123456" {
+		t.Errorf("Expected username 123456, got %s", cfg.Prometheus.Username)
+	}
+	if cfg.Logging.Level != "debug" {
+		t.Errorf("Expected log level debug, got %s", cfg.Logging.Level)
+	}
+
+	// Comments on carried-over values are kept
+	if !strings.Contains(string(migrated), "# Use PROMETHEUS_PASSWORD env var") {
+		t.Errorf("Expected value comment to be preserved, got:\n%s", migrated)
+	}
+
+	expected := []string{
+		"sensor names were generated",
+		"metricName dropped",
+		"scanIntervalSeconds dropped",
+	}
+	if len(warnings) != len(expected) {
+		t.Fatalf("Expected %d warnings, got %v", len(expected), warnings)
+	}
+	for i, prefix := range expected {
+		if !strings.HasPrefix(warnings[i], prefix) {
+			t.Errorf("Expected warning %d to start with %q, got %q", i, prefix, warnings[i])
+		}
+	}
+}
+
+func TestMigrate_NamedSensors(t *testing.T) {
+	legacy := `
+sensors:
+  - name: Kitchen
+    id: 7
+    mac: "A4:C1:38:00:00:07"
+prometheusUrl: "https://example.com/api/prom/push"
+`
+	migrated, warnings, err := Migrate([]byte(legacy))
+	if err != nil {
+		t.Fatalf("Migrate failed: %v", err)
+	}
+	if len(warnings) != 0 {
+		t.Errorf("Expected no warnings, got %v", warnings)
+	}
+	for _, want := range []string{`name: "Kitchen"`, "id: 7", `macAddress: "A4:C1:38:00:00:07"`} {
+		if !strings.Contains(string(migrated), want) {
+			t.Errorf("Expected output to contain %q, got:\n%s", want, migrated)
+		}
+	}
+}
+
+func TestMigrate_Errors(t *testing.T) {
+	tests := []struct {
+		name   string
+		config string
+		errMsg string
+	}{
+		{
+			name:   "current format",
+			config: "ble:\n  sensors: []\nprometheus:\n  bufferSize: 10\n",
+			errMsg: "already in the current format",
+		},
+		{
+			name:   "no sensors",
+			config: "prometheusUrl: \"https://example.com\"\n",
+			errMsg: "no sensors list",
+		},
+		{
+			name:   "invalid MAC",
+			config: "sensors:\n  - \"not-a-mac\"\n",
+			errMsg: "invalid MAC address",
+		},
+		{
+			name:   "not a mapping",
+			config: "- a\n- b\n",
+			errMsg: "must be a YAML mapping",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, err := Migrate([]byte(tt.config))
+			if err == nil {
+				t.Fatal("Expected error, got nil")
+			}
+			if !strings.Contains(err.Error(), tt.errMsg) {
+				t.Errorf("Expected error containing %q, got %v", tt.errMsg, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,164 @@
+package connectivity
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/mjasion/balena-home/thermostats/events"
+	"go.uber.org/zap"
+)
+
+// Connectivity modes
+const (
+	ModeAuto      = "auto"      // Metered while the default route uses a metered interface
+	ModeMetered   = "metered"   // Always metered
+	ModeUnmetered = "unmetered" // Never metered
+)
+
+// rtfUp is the RTF_UP route flag
+const rtfUp = 0x1
+
+// Monitor reports whether the device is on a metered link, such as an LTE backup,
+// so outputs can switch to a low-bandwidth profile until the primary link returns
+type Monitor struct {
+	mode      string
+	patterns  []string // Interface name patterns of metered links, e.g. wwan*
+	interval  time.Duration
+	routePath string
+	logger    *zap.Logger
+	eventLog  *events.Log
+	metered   atomic.Bool
+}
+
+// NewMonitor creates a monitor; in auto mode the default route interface is checked against
+// the metered interface patterns every checkInterval
+func NewMonitor(mode string, meteredInterfaces []string, checkInterval time.Duration, logger *zap.Logger) *Monitor {
+	m := &Monitor{
+		mode:      mode,
+		patterns:  meteredInterfaces,
+		interval:  checkInterval,
+		routePath: "/proc/net/route",
+		logger:    logger,
+	}
+	m.metered.Store(mode == ModeMetered)
+	return m
+}
+
+// SetEventLog sets the event log used to record switches between metered and unmetered links
+func (m *Monitor) SetEventLog(eventLog *events.Log) {
+	m.eventLog = eventLog
+}
+
+// IsMetered reports whether the device is on a metered link
+func (m *Monitor) IsMetered() bool {
+	return m.metered.Load()
+}
+
+// Start checks the default route immediately and then periodically until the context is cancelled
+// Only auto mode checks; the other modes are fixed
+func (m *Monitor) Start(ctx context.Context) {
+	if m.mode != ModeAuto {
+		return
+	}
+
+	ticker := time.NewTicker(m.interval)
+	defer ticker.Stop()
+
+	m.logger.Info("connectivity monitor started",
+		zap.Strings("metered_interfaces", m.patterns),
+		zap.Duration("check_interval", m.interval),
+	)
+
+	m.check()
+	for {
+		select {
+		case <-ctx.Done():
+			m.logger.Info("connectivity monitor stopping")
+			return
+		case <-ticker.C:
+			m.check()
+		}
+	}
+}
+
+// check updates the metered state from the default route and records a change
+func (m *Monitor) check() {
+	iface, err := defaultRouteInterface(m.routePath)
+	if err != nil {
+		// Keep the last known state, e.g. while no route is up during a failover
+		m.logger.Debug("failed to determine default route", zap.Error(err))
+		return
+	}
+
+	metered := m.matches(iface)
+	if metered == m.metered.Swap(metered) {
+		return
+	}
+	if metered {
+		m.logger.Warn("default route is metered, switching to low-bandwidth profile", zap.String("interface", iface))
+		m.eventLog.Record(events.TypeConnectivityMetered, "connectivity", "switched to metered link",
+			map[string]string{"interface": iface},
+		)
+		return
+	}
+	m.logger.Info("default route is unmetered, resuming normal profile", zap.String("interface", iface))
+	m.eventLog.Record(events.TypeConnectivityUnmetered, "connectivity", "switched to unmetered link",
+		map[string]string{"interface": iface},
+	)
+}
+
+// matches reports whether the interface name matches a metered interface pattern
+func (m *Monitor) matches(iface string) bool {
+	for _, pattern := range m.patterns {
+		if ok, _ := path.Match(pattern, iface); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// defaultRouteInterface returns the interface of the IPv4 default route with the lowest metric,
+// read from a /proc/net/route formatted file
+func defaultRouteInterface(routePath string) (string, error) {
+	f, err := os.Open(routePath)
+	if err != nil {
+		return "", fmt.Errorf("failed to open route table: %w", err)
+	}
+	defer f.Close()
+
+	best := ""
+	bestMetric := -1
+	scanner := bufio.NewScanner(f)
+	scanner.Scan() // Header
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 8 || fields[1] != "00000000" || fields[7] != "00000000" {
+			continue
+		}
+		flags, err := strconv.ParseUint(fields[3], 16, 32)
+		if err != nil || flags&rtfUp == 0 {
+			continue
+		}
+		metric, err := strconv.Atoi(fields[6])
+		if err != nil {
+			continue
+		}
+		if bestMetric < 0 || metric < bestMetric {
+			best, bestMetric = fields[0], metric
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return "", fmt.Errorf("failed to read route table: %w", err)
+	}
+	if best == "" {
+		return "", fmt.Errorf("no default route")
+	}
+	return best, nil
+}
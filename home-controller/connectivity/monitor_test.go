@@ -0,0 +1,79 @@
+package connectivity
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/mjasion/balena-home/thermostats/events"
+	"go.uber.org/zap"
+)
+
+const routeHeader = "Iface\tDestination\tGateway \tFlags\tRefCnt\tUse\tMetric\tMask\t\tMTU\tWindow\tIRTT\n"
+
+func writeRoutes(t *testing.T, path, routes string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(routeHeader+routes), 0644); err != nil {
+		t.Fatalf("Failed to write route table: %v", err)
+	}
+}
+
+func TestDefaultRouteInterface(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "route")
+	writeRoutes(t, path, "eth0\t0001A8C0\t00000000\t0001\t0\t0\t100\t00FFFFFF\t0\t0\t0\n"+
+		"wwan0\t00000000\t0100000A\t0003\t0\t0\t700\t00000000\t0\t0\t0\n"+
+		"eth0\t00000000\t0101A8C0\t0003\t0\t0\t100\t00000000\t0\t0\t0\n")
+
+	iface, err := defaultRouteInterface(path)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if iface != "eth0" {
+		t.Errorf("Expected default route with the lowest metric on eth0, got %s", iface)
+	}
+
+	writeRoutes(t, path, "eth0\t0001A8C0\t00000000\t0001\t0\t0\t100\t00FFFFFF\t0\t0\t0\n")
+	if _, err := defaultRouteInterface(path); err == nil {
+		t.Error("Expected error without a default route")
+	}
+}
+
+func TestMonitor_Check(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "route")
+	eventLog := events.NewLog(10, zap.NewNop())
+	monitor := NewMonitor(ModeAuto, []string{"wwan*", "ppp*"}, time.Minute, zap.NewNop())
+	monitor.SetEventLog(eventLog)
+	monitor.routePath = path
+
+	// Primary link down, LTE backup takes over
+	writeRoutes(t, path, "wwan0\t00000000\t0100000A\t0003\t0\t0\t700\t00000000\t0\t0\t0\n")
+	monitor.check()
+	if !monitor.IsMetered() {
+		t.Error("Expected metered on wwan0")
+	}
+
+	// No route during failover keeps the last state
+	writeRoutes(t, path, "")
+	monitor.check()
+	if !monitor.IsMetered() {
+		t.Error("Expected metered state kept without a default route")
+	}
+
+	writeRoutes(t, path, "eth0\t00000000\t0101A8C0\t0003\t0\t0\t100\t00000000\t0\t0\t0\n")
+	monitor.check()
+	if monitor.IsMetered() {
+		t.Error("Expected unmetered on eth0")
+	}
+
+	recorded := eventLog.List(events.Filter{})
+	if len(recorded) != 2 || recorded[0].Type != events.TypeConnectivityMetered || recorded[1].Type != events.TypeConnectivityUnmetered {
+		t.Errorf("Expected metered and unmetered events, got %+v", recorded)
+	}
+}
+
+func TestMonitor_FixedMode(t *testing.T) {
+	if !NewMonitor(ModeMetered, nil, time.Minute, zap.NewNop()).IsMetered() {
+		t.Error("Expected metered mode to always be metered")
+	}
+}
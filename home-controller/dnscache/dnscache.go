@@ -0,0 +1,290 @@
+package dnscache
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/mjasion/balena-home/thermostats/clock"
+	"github.com/mjasion/balena-home/thermostats/httpclient"
+	"go.uber.org/zap"
+)
+
+// entry is the cached result of a lookup
+type entry struct {
+	addrs   []string
+	expires time.Time
+}
+
+// Resolver caches host lookups for an explicit TTL and dials the cached addresses with happy
+// eyeballs; Go's resolver caches nothing itself, yet pooled keep-alive connections pin a client
+// to the address resolved when they were opened, so a flaky resolver leaves pushes going to a
+// dead address long after DNS recovered
+type Resolver struct {
+	ttl           time.Duration
+	fallbackDelay time.Duration // Delay before racing the next address, per RFC 8305
+	network       string        // tcp4 or tcp6 restricts dialing to one address family
+	lookup        func(ctx context.Context, host string) ([]string, error)
+	dial          func(ctx context.Context, network, address string) (net.Conn, error)
+	clock         clock.Clock
+	logger        *zap.Logger
+
+	mu      sync.Mutex
+	entries map[string]entry
+}
+
+// New creates a resolver caching lookups for ttl and starting a connection attempt to the next
+// address when the previous one hasn't connected within fallbackDelay
+func New(ttl, fallbackDelay time.Duration, logger *zap.Logger) *Resolver {
+	dialer := &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}
+	return &Resolver{
+		ttl:           ttl,
+		fallbackDelay: fallbackDelay,
+		lookup:        net.DefaultResolver.LookupHost,
+		dial:          dialer.DialContext,
+		clock:         clock.Real,
+		logger:        logger,
+		entries:       make(map[string]entry),
+	}
+}
+
+// SetClock sets the clock expiring cache entries and delaying fallback attempts, e.g. a fake one in tests
+func (r *Resolver) SetClock(c clock.Clock) {
+	r.clock = c
+}
+
+// SetNetwork restricts dialing to tcp4 or tcp6 addresses, as network.TCP returns for the
+// configured address family; tcp dials both
+func (r *Resolver) SetNetwork(network string) {
+	r.network = network
+}
+
+// LookupHost returns the addresses of host, resolving it when the cached ones expired; when the
+// lookup fails the expired addresses are returned, so a resolver outage alone breaks nothing
+func (r *Resolver) LookupHost(ctx context.Context, host string) ([]string, error) {
+	if net.ParseIP(host) != nil {
+		return []string{host}, nil
+	}
+
+	now := r.clock.Now()
+	r.mu.Lock()
+	cached, ok := r.entries[host]
+	r.mu.Unlock()
+	if ok && now.Before(cached.expires) {
+		return cached.addrs, nil
+	}
+
+	addrs, err := r.lookup(ctx, host)
+	if err != nil {
+		if ok {
+			r.logger.Warn("DNS lookup failed, using expired addresses", zap.String("host", host), zap.Strings("addresses", cached.addrs), zap.Error(err))
+			return cached.addrs, nil
+		}
+		return nil, err
+	}
+
+	r.mu.Lock()
+	r.entries[host] = entry{addrs: addrs, expires: now.Add(r.ttl)}
+	r.mu.Unlock()
+	return addrs, nil
+}
+
+// Forget drops the cached addresses of host, so the next dial resolves it again
+func (r *Resolver) Forget(host string) {
+	r.mu.Lock()
+	delete(r.entries, host)
+	r.mu.Unlock()
+}
+
+// DialContext resolves the address through the cache and races connections to its addresses,
+// alternating address families; use it as http.Transport.DialContext
+func (r *Resolver) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return nil, err
+	}
+	addrs, err := r.LookupHost(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+	if r.network != "" && r.network != "tcp" {
+		network = r.network
+	}
+	candidates := interleave(addrs, network)
+	if len(candidates) == 0 {
+		return nil, &net.AddrError{Err: "no suitable address", Addr: host}
+	}
+	for i, addr := range candidates {
+		candidates[i] = net.JoinHostPort(addr, port)
+	}
+	return r.race(ctx, network, candidates)
+}
+
+// interleave orders addresses alternating families, starting with the family of the first one
+// as the resolver sorted them, and drops those the network can't reach, e.g. IPv6 for tcp4
+func interleave(addrs []string, network string) []string {
+	var first, second []string
+	firstIsV4 := false
+	for _, addr := range addrs {
+		ip := net.ParseIP(addr)
+		if ip == nil {
+			continue
+		}
+		isV4 := ip.To4() != nil
+		if (network == "tcp4" && !isV4) || (network == "tcp6" && isV4) {
+			continue
+		}
+		if len(first) == 0 && len(second) == 0 {
+			firstIsV4 = isV4
+		}
+		if isV4 == firstIsV4 {
+			first = append(first, addr)
+		} else {
+			second = append(second, addr)
+		}
+	}
+
+	ordered := make([]string, 0, len(first)+len(second))
+	for i := 0; i < len(first) || i < len(second); i++ {
+		if i < len(first) {
+			ordered = append(ordered, first[i])
+		}
+		if i < len(second) {
+			ordered = append(ordered, second[i])
+		}
+	}
+	return ordered
+}
+
+// race dials the addresses in order, starting the next attempt when the previous one fails or
+// hasn't connected within the fallback delay; the first connection wins and the others are closed
+func (r *Resolver) race(ctx context.Context, network string, addresses []string) (net.Conn, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	type result struct {
+		conn net.Conn
+		err  error
+	}
+	results := make(chan result, len(addresses))
+	next, pending := 0, 0
+	start := func() {
+		address := addresses[next]
+		next++
+		pending++
+		go func() {
+			conn, err := r.dial(ctx, network, address)
+			results <- result{conn: conn, err: err}
+		}()
+	}
+
+	var firstErr error
+	start()
+	for pending > 0 {
+		var fallback <-chan time.Time
+		var timer clock.Timer
+		if next < len(addresses) {
+			timer = r.clock.NewTimer(r.fallbackDelay)
+			fallback = timer.C()
+		}
+
+		select {
+		case res := <-results:
+			pending--
+			if timer != nil {
+				timer.Stop()
+			}
+			if res.err == nil {
+				// Close connections of attempts completing after the winner
+				go func(losers int) {
+					for range losers {
+						if late := <-results; late.conn != nil {
+							late.conn.Close()
+						}
+					}
+				}(pending)
+				return res.conn, nil
+			}
+			if firstErr == nil {
+				firstErr = res.err
+			}
+			if next < len(addresses) {
+				start()
+			}
+		case <-fallback:
+			start()
+		}
+	}
+	return nil, firstErr
+}
+
+// Instrument makes the client dial through the resolver and, after maxFailures consecutive
+// requests failing without a response, closes its idle connections and forgets the cached
+// addresses, so the next request resolves and connects afresh; 0 disables recycling
+// Call before fault and telemetry instrumentation, so their failures count and the recycler sees them
+func (r *Resolver) Instrument(client *http.Client, maxFailures int) {
+	if r == nil {
+		return
+	}
+	transport := httpclient.Base(client)
+	if transport == nil {
+		r.logger.Warn("client has no HTTP transport, not dialing through the DNS cache")
+		return
+	}
+	transport.DialContext = r.DialContext
+	if maxFailures > 0 {
+		client.Transport = &recycler{resolver: r, next: client.Transport, transport: transport, maxFailures: maxFailures}
+	}
+}
+
+// recycler closes idle connections and forgets cached addresses after consecutive failures
+type recycler struct {
+	resolver    *Resolver
+	next        http.RoundTripper
+	transport   *http.Transport // Beneath next, holding the connection pool
+	maxFailures int
+
+	mu       sync.Mutex
+	failures int
+}
+
+// RoundTrip sends the request, counting consecutive transport errors; responses of any status
+// prove the connection works and reset the count
+func (t *recycler) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.next.RoundTrip(req)
+
+	t.mu.Lock()
+	if err != nil {
+		t.failures++
+	} else {
+		t.failures = 0
+	}
+	recycle := t.failures >= t.maxFailures
+	if recycle {
+		t.failures = 0
+	}
+	t.mu.Unlock()
+
+	if recycle {
+		t.resolver.logger.Warn("recycling connections after consecutive failures",
+			zap.String("host", req.URL.Hostname()),
+			zap.Int("failures", t.maxFailures),
+			zap.Error(err),
+		)
+		t.transport.CloseIdleConnections()
+		t.resolver.Forget(req.URL.Hostname())
+	}
+	return resp, err
+}
+
+// CloseIdleConnections closes the idle connections of the wrapped transport
+func (t *recycler) CloseIdleConnections() {
+	t.transport.CloseIdleConnections()
+}
+
+// Unwrap returns the wrapped round tripper
+func (t *recycler) Unwrap() http.RoundTripper {
+	return t.next
+}
@@ -0,0 +1,155 @@
+package dnscache
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/mjasion/balena-home/thermostats/clock"
+	"go.uber.org/zap"
+)
+
+func TestResolver_LookupHost(t *testing.T) {
+	clk := clock.NewFake(time.Date(2026, 10, 17, 12, 0, 0, 0, time.UTC))
+	r := New(time.Minute, 300*time.Millisecond, zap.NewNop())
+	r.SetClock(clk)
+	var lookups atomic.Int32
+	var down atomic.Bool
+	r.lookup = func(ctx context.Context, host string) ([]string, error) {
+		lookups.Add(1)
+		if down.Load() {
+			return nil, errors.New("server misbehaving")
+		}
+		return []string{"192.0.2.1"}, nil
+	}
+
+	for range 3 {
+		if _, err := r.LookupHost(context.Background(), "prometheus.example.com"); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+	}
+	if lookups.Load() != 1 {
+		t.Errorf("Expected 1 lookup within the TTL, got %d", lookups.Load())
+	}
+
+	// Expired addresses are used while the resolver fails
+	clk.Advance(2 * time.Minute)
+	down.Store(true)
+	addrs, err := r.LookupHost(context.Background(), "prometheus.example.com")
+	if err != nil || len(addrs) != 1 || addrs[0] != "192.0.2.1" {
+		t.Errorf("Expected the expired address, got %v, %v", addrs, err)
+	}
+
+	r.Forget("prometheus.example.com")
+	if _, err := r.LookupHost(context.Background(), "prometheus.example.com"); err == nil {
+		t.Error("Expected an error once forgotten while the resolver fails")
+	}
+}
+
+func TestInterleave(t *testing.T) {
+	addrs := []string{"2001:db8::1", "2001:db8::2", "192.0.2.1"}
+	got := interleave(addrs, "tcp")
+	want := []string{"2001:db8::1", "192.0.2.1", "2001:db8::2"}
+	if len(got) != len(want) {
+		t.Fatalf("Expected %v, got %v", want, got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("Expected %v, got %v", want, got)
+			break
+		}
+	}
+
+	if got := interleave(addrs, "tcp4"); len(got) != 1 || got[0] != "192.0.2.1" {
+		t.Errorf("Expected only the IPv4 address for tcp4, got %v", got)
+	}
+}
+
+func TestResolver_DialContext(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	defer listener.Close()
+	_, port, _ := net.SplitHostPort(listener.Addr().String())
+
+	r := New(time.Minute, 10*time.Millisecond, zap.NewNop())
+	r.lookup = func(ctx context.Context, host string) ([]string, error) {
+		return []string{"2001:db8::1", "127.0.0.1"}, nil
+	}
+	// The IPv6 address blackholes connection attempts
+	dialer := &net.Dialer{}
+	r.dial = func(ctx context.Context, network, address string) (net.Conn, error) {
+		if address == net.JoinHostPort("2001:db8::1", port) {
+			<-ctx.Done()
+			return nil, ctx.Err()
+		}
+		return dialer.DialContext(ctx, network, address)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	conn, err := r.DialContext(ctx, "tcp", net.JoinHostPort("prometheus.example.com", port))
+	if err != nil {
+		t.Fatalf("Expected the fallback address to connect, got %v", err)
+	}
+	defer conn.Close()
+	if conn.RemoteAddr().String() != listener.Addr().String() {
+		t.Errorf("Expected a connection to %s, got %s", listener.Addr(), conn.RemoteAddr())
+	}
+}
+
+func TestInstrument_Recycle(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+	_, port, _ := net.SplitHostPort(server.Listener.Addr().String())
+
+	r := New(time.Hour, 300*time.Millisecond, zap.NewNop())
+	var lookups atomic.Int32
+	var dead atomic.Bool
+	r.lookup = func(ctx context.Context, host string) ([]string, error) {
+		lookups.Add(1)
+		return []string{"127.0.0.1"}, nil
+	}
+	dialer := &net.Dialer{}
+	r.dial = func(ctx context.Context, network, address string) (net.Conn, error) {
+		if dead.Load() {
+			return nil, errors.New("no route to host")
+		}
+		return dialer.DialContext(ctx, network, address)
+	}
+
+	client := &http.Client{Timeout: 5 * time.Second}
+	r.Instrument(client, 2)
+	url := "http://prometheus.example.com:" + port + "/api/v1/write"
+	get := func() error {
+		resp, err := client.Get(url)
+		if err == nil {
+			resp.Body.Close()
+		}
+		return err
+	}
+
+	if err := get(); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	client.CloseIdleConnections()
+	dead.Store(true)
+	get()
+	get()
+	dead.Store(false)
+	if err := get(); err != nil {
+		t.Fatalf("Expected no error after recovery, got %v", err)
+	}
+	// Two consecutive failures forgot the cached address, so it was resolved again
+	if lookups.Load() != 2 {
+		t.Errorf("Expected 2 lookups, got %d", lookups.Load())
+	}
+}
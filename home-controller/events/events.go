@@ -0,0 +1,215 @@
+package events
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// Event types recorded by the collectors
+const (
+	TypeSensorFirstSeen = "sensor_first_seen"
+	TypePushFailing     = "push_failing"
+	TypePushRecovered   = "push_recovered"
+	TypeIPChanged       = "ip_changed"
+	TypeLoadShed        = "load_shed"
+	TypeLoadRestored    = "load_restored"
+	TypeLoadShedFailed  = "load_shed_failed"
+
+	TypeAutomationTriggered = "automation_triggered"
+	TypeAutomationFailed    = "automation_failed"
+
+	TypeRoomSourceChanged = "room_source_changed"
+
+	TypeLeadershipAcquired = "leadership_acquired"
+	TypeLeadershipLost     = "leadership_lost"
+
+	TypeConnectivityMetered   = "connectivity_metered"
+	TypeConnectivityUnmetered = "connectivity_unmetered"
+
+	TypeSeriesLimited  = "series_limited"
+	TypeSensorConflict = "sensor_conflict"
+	TypeBatteryLow     = "battery_low"
+
+	TypeMeterDrift         = "meter_drift"
+	TypeMeterDriftResolved = "meter_drift_resolved"
+
+	TypeOccupancyHome = "occupancy_home"
+	TypeOccupancyAway = "occupancy_away"
+
+	TypeModeChanged = "mode_changed"
+
+	TypeFrostAlarm         = "frost_alarm"
+	TypeFrostAlarmResolved = "frost_alarm_resolved"
+
+	TypeRemoteConfigChanged  = "remote_config_changed"
+	TypeRemoteConfigRejected = "remote_config_rejected"
+
+	TypeFaultInjected = "fault_injected"
+	TypeFaultCleared  = "fault_cleared"
+)
+
+// Event is a notable state change, kept separately from regular logs
+type Event struct {
+	ID        uint64            `json:"id"`
+	Timestamp time.Time         `json:"timestamp"`
+	Type      string            `json:"type"`
+	Source    string            `json:"source"`
+	Message   string            `json:"message"`
+	Fields    map[string]string `json:"fields,omitempty"`
+}
+
+// Sink delivers events to an external system
+type Sink interface {
+	Name() string
+	Send(ctx context.Context, events []Event) error
+}
+
+// Filter selects events returned by List
+type Filter struct {
+	Type    string
+	SinceID uint64 // Only events with a greater ID
+	Limit   int    // Most recent events when > 0
+}
+
+// Log is a bounded in-memory event log
+// A nil *Log is valid and discards events, so components can record unconditionally
+type Log struct {
+	mu     sync.RWMutex
+	events []Event
+	head   int // Index where the next event will be written
+	count  int
+	nextID uint64
+
+	sinks   []Sink
+	pending chan Event
+	logger  *zap.Logger
+}
+
+// NewLog creates an event log holding up to capacity events
+func NewLog(capacity int, logger *zap.Logger) *Log {
+	return &Log{
+		events:  make([]Event, capacity),
+		nextID:  1,
+		pending: make(chan Event, capacity),
+		logger:  logger,
+	}
+}
+
+// AddSink registers a sink; must be called before Start
+func (l *Log) AddSink(sink Sink) {
+	l.sinks = append(l.sinks, sink)
+}
+
+// Record adds an event to the log and queues it for the sinks
+func (l *Log) Record(eventType, source, message string, fields map[string]string) {
+	if l == nil {
+		return
+	}
+
+	l.mu.Lock()
+	event := Event{
+		ID:        l.nextID,
+		Timestamp: time.Now(),
+		Type:      eventType,
+		Source:    source,
+		Message:   message,
+		Fields:    fields,
+	}
+	l.nextID++
+	l.events[l.head] = event
+	l.head = (l.head + 1) % len(l.events)
+	if l.count < len(l.events) {
+		l.count++
+	}
+	l.mu.Unlock()
+
+	l.logger.Info("event recorded",
+		zap.String("event_type", eventType),
+		zap.String("source", source),
+		zap.String("message", message),
+	)
+
+	if len(l.sinks) == 0 {
+		return
+	}
+	select {
+	case l.pending <- event:
+	default:
+		l.logger.Warn("event sink queue full, dropping event", zap.Uint64("event_id", event.ID))
+	}
+}
+
+// List returns matching events, oldest first
+func (l *Log) List(filter Filter) []Event {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	result := make([]Event, 0, l.count)
+	start := (l.head - l.count + len(l.events)) % len(l.events)
+	for i := 0; i < l.count; i++ {
+		event := l.events[(start+i)%len(l.events)]
+		if event.ID <= filter.SinceID {
+			continue
+		}
+		if filter.Type != "" && event.Type != filter.Type {
+			continue
+		}
+		result = append(result, event)
+	}
+
+	if filter.Limit > 0 && len(result) > filter.Limit {
+		result = result[len(result)-filter.Limit:]
+	}
+	return result
+}
+
+// Start delivers queued events to the sinks until the context is cancelled
+func (l *Log) Start(ctx context.Context) {
+	if len(l.sinks) == 0 {
+		return
+	}
+
+	l.logger.Info("starting event sink delivery", zap.Int("sink_count", len(l.sinks)))
+
+	// Batch events that arrive close together
+	ticker := time.NewTicker(5 * time.Second)
+	defer ticker.Stop()
+
+	var batch []Event
+	for {
+		select {
+		case <-ctx.Done():
+			// Best-effort flush of the last batch
+			flushCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			l.deliver(flushCtx, batch)
+			cancel()
+			return
+		case event := <-l.pending:
+			batch = append(batch, event)
+		case <-ticker.C:
+			if len(batch) > 0 {
+				l.deliver(ctx, batch)
+				batch = nil
+			}
+		}
+	}
+}
+
+// deliver sends a batch to every sink, logging failures
+func (l *Log) deliver(ctx context.Context, batch []Event) {
+	if len(batch) == 0 {
+		return
+	}
+	for _, sink := range l.sinks {
+		if err := sink.Send(ctx, batch); err != nil {
+			l.logger.Warn("failed to deliver events",
+				zap.String("sink", sink.Name()),
+				zap.Int("event_count", len(batch)),
+				zap.Error(err),
+			)
+		}
+	}
+}
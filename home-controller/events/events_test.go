@@ -0,0 +1,152 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mjasion/balena-home/thermostats/admin"
+	"go.uber.org/zap"
+)
+
+func TestLog_RingOverwritesOldest(t *testing.T) {
+	log := NewLog(3, zap.NewNop())
+	for i := 0; i < 5; i++ {
+		log.Record(TypeSensorFirstSeen, "ble", "seen", nil)
+	}
+
+	events := log.List(Filter{})
+	if len(events) != 3 {
+		t.Fatalf("Expected 3 events, got %d", len(events))
+	}
+	for i, expectedID := range []uint64{3, 4, 5} {
+		if events[i].ID != expectedID {
+			t.Errorf("Event %d: expected ID %d, got %d", i, expectedID, events[i].ID)
+		}
+	}
+}
+
+func TestLog_Filter(t *testing.T) {
+	log := NewLog(10, zap.NewNop())
+	log.Record(TypePushFailing, "pusher", "failing", nil)
+	log.Record(TypeSensorFirstSeen, "ble", "seen a", nil)
+	log.Record(TypePushRecovered, "pusher", "recovered", nil)
+	log.Record(TypeSensorFirstSeen, "ble", "seen b", nil)
+
+	if events := log.List(Filter{Type: TypeSensorFirstSeen}); len(events) != 2 {
+		t.Errorf("Expected 2 sensor events, got %d", len(events))
+	}
+	if events := log.List(Filter{SinceID: 2}); len(events) != 2 || events[0].ID != 3 {
+		t.Errorf("Expected events after ID 2, got %+v", events)
+	}
+	if events := log.List(Filter{Limit: 1}); len(events) != 1 || events[0].Message != "seen b" {
+		t.Errorf("Expected only the newest event, got %+v", events)
+	}
+}
+
+func TestLog_NilIsNoop(t *testing.T) {
+	var log *Log
+	log.Record(TypeIPChanged, "network", "changed", nil)
+}
+
+// recordingSink collects delivered events
+type recordingSink struct {
+	mu     sync.Mutex
+	events []Event
+}
+
+func (s *recordingSink) Name() string { return "recording" }
+
+func (s *recordingSink) Send(ctx context.Context, events []Event) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.events = append(s.events, events...)
+	return nil
+}
+
+func TestLog_FlushesSinksOnShutdown(t *testing.T) {
+	sink := &recordingSink{}
+	log := NewLog(10, zap.NewNop())
+	log.AddSink(sink)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		log.Start(ctx)
+		close(done)
+	}()
+
+	log.Record(TypePushRecovered, "pusher", "recovered", nil)
+	time.Sleep(50 * time.Millisecond)
+	cancel()
+	<-done
+
+	sink.mu.Lock()
+	defer sink.mu.Unlock()
+	if len(sink.events) != 1 || sink.events[0].Type != TypePushRecovered {
+		t.Errorf("Expected recovered event delivered, got %+v", sink.events)
+	}
+}
+
+func TestHandleList(t *testing.T) {
+	log := NewLog(10, zap.NewNop())
+	log.Record(TypePushFailing, "pusher", "failing", map[string]string{"error": "timeout"})
+	log.Record(TypeSensorFirstSeen, "ble", "seen", nil)
+
+	server := admin.New(":0", zap.NewNop())
+	log.RegisterHandlers(server)
+
+	rec := httptest.NewRecorder()
+	server.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/events?type=push_failing", nil))
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", rec.Code)
+	}
+	var response struct {
+		Success bool    `json:"success"`
+		Data    []Event `json:"data"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&response); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if len(response.Data) != 1 || response.Data[0].Fields["error"] != "timeout" {
+		t.Errorf("Unexpected events: %+v", response.Data)
+	}
+
+	rec = httptest.NewRecorder()
+	server.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/events?limit=0", nil))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for invalid limit, got %d", rec.Code)
+	}
+}
+
+func TestWatchAddresses(t *testing.T) {
+	log := NewLog(10, zap.NewNop())
+	addresses := [][]string{{"192.168.1.10"}, {"192.168.1.10"}, {"192.168.1.23"}}
+	var mu sync.Mutex
+	lookup := func() ([]string, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		current := addresses[0]
+		if len(addresses) > 1 {
+			addresses = addresses[1:]
+		}
+		return current, nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	log.watchAddresses(ctx, 10*time.Millisecond, lookup)
+
+	events := log.List(Filter{Type: TypeIPChanged})
+	if len(events) != 1 {
+		t.Fatalf("Expected 1 IP change event, got %d", len(events))
+	}
+	if events[0].Fields["previous"] != "192.168.1.10" || events[0].Fields["current"] != "192.168.1.23" {
+		t.Errorf("Unexpected fields: %v", events[0].Fields)
+	}
+}
@@ -0,0 +1,107 @@
+package events
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/mjasion/balena-home/thermostats/httpclient"
+	"github.com/mjasion/balena-home/thermostats/telemetry"
+)
+
+// GrafanaSink creates Grafana annotations for events
+type GrafanaSink struct {
+	url    string
+	token  string
+	tags   []string
+	client *http.Client
+}
+
+// NewGrafanaSink creates a sink posting to the Grafana instance at baseURL using a service account token
+func NewGrafanaSink(baseURL, token string, tags []string) *GrafanaSink {
+	return &GrafanaSink{
+		url:    strings.TrimSuffix(baseURL, "/") + "/api/annotations",
+		token:  token,
+		tags:   tags,
+		client: httpclient.NewDefault(httpclient.Config{Timeout: 10 * time.Second}),
+	}
+}
+
+// SetRecorder records annotation requests as the "grafana" dependency; call before the log starts
+func (s *GrafanaSink) SetRecorder(recorder *telemetry.Recorder) {
+	recorder.Instrument(s.client, "grafana")
+}
+
+// Name returns the sink name used in logs
+func (s *GrafanaSink) Name() string {
+	return "grafana"
+}
+
+// grafanaAnnotation is the request body of POST /api/annotations
+type grafanaAnnotation struct {
+	Time int64    `json:"time"`
+	Tags []string `json:"tags"`
+	Text string   `json:"text"`
+}
+
+// Send creates one annotation per event
+func (s *GrafanaSink) Send(ctx context.Context, events []Event) error {
+	for _, event := range events {
+		tags := append([]string{}, s.tags...)
+		tags = append(tags, event.Type, event.Source)
+
+		body, err := json.Marshal(grafanaAnnotation{
+			Time: event.Timestamp.UnixMilli(),
+			Tags: tags,
+			Text: formatText(event),
+		})
+		if err != nil {
+			return fmt.Errorf("failed to encode annotation: %w", err)
+		}
+
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, bytes.NewReader(body))
+		if err != nil {
+			return fmt.Errorf("failed to create request: %w", err)
+		}
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+s.token)
+
+		resp, err := s.client.Do(req)
+		if err != nil {
+			return fmt.Errorf("failed to send annotation: %w", err)
+		}
+		respBody, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+
+		if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+			return fmt.Errorf("unexpected status code %d: %s", resp.StatusCode, string(respBody))
+		}
+	}
+	return nil
+}
+
+// formatText renders the message followed by sorted key=value fields
+func formatText(event Event) string {
+	if len(event.Fields) == 0 {
+		return event.Message
+	}
+
+	keys := make([]string, 0, len(event.Fields))
+	for key := range event.Fields {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var b strings.Builder
+	b.WriteString(event.Message)
+	for _, key := range keys {
+		fmt.Fprintf(&b, " %s=%s", key, event.Fields[key])
+	}
+	return b.String()
+}
@@ -0,0 +1,59 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestGrafanaSink_Send(t *testing.T) {
+	var received grafanaAnnotation
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/annotations" {
+			t.Errorf("Expected path /api/annotations, got %s", r.URL.Path)
+		}
+		if auth := r.Header.Get("Authorization"); auth != "Bearer secret" {
+			t.Errorf("Expected bearer token, got %s", auth)
+		}
+		json.NewDecoder(r.Body).Decode(&received)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	sink := NewGrafanaSink(server.URL+"/", "secret", []string{"home-controller"})
+	event := Event{
+		Timestamp: time.UnixMilli(1700000000000),
+		Type:      TypePushRecovered,
+		Source:    "pusher",
+		Message:   "metrics push recovered after 3 failures",
+		Fields:    map[string]string{"failures": "3"},
+	}
+	if err := sink.Send(context.Background(), []Event{event}); err != nil {
+		t.Fatalf("Send failed: %v", err)
+	}
+
+	if received.Time != 1700000000000 {
+		t.Errorf("Expected time 1700000000000, got %d", received.Time)
+	}
+	if received.Text != "metrics push recovered after 3 failures failures=3" {
+		t.Errorf("Unexpected text: %s", received.Text)
+	}
+	if len(received.Tags) != 3 || received.Tags[1] != TypePushRecovered {
+		t.Errorf("Unexpected tags: %v", received.Tags)
+	}
+}
+
+func TestGrafanaSink_ErrorStatus(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer server.Close()
+
+	sink := NewGrafanaSink(server.URL, "bad", nil)
+	if err := sink.Send(context.Background(), []Event{{Message: "x"}}); err == nil {
+		t.Error("Expected error for 401 response, got nil")
+	}
+}
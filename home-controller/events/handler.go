@@ -0,0 +1,41 @@
+package events
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/mjasion/balena-home/thermostats/admin"
+)
+
+// RegisterHandlers registers the event log endpoints on the admin server
+func (l *Log) RegisterHandlers(server *admin.Server) {
+	server.HandleFunc("GET /api/events", l.handleList)
+}
+
+// handleList handles GET /api/events?type=<type>&since_id=<id>&limit=<n>
+func (l *Log) handleList(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	filter := Filter{Type: query.Get("type")}
+
+	if value := query.Get("since_id"); value != "" {
+		sinceID, err := strconv.ParseUint(value, 10, 64)
+		if err != nil {
+			admin.WriteError(w, http.StatusBadRequest, "since_id must be a non-negative integer")
+			return
+		}
+		filter.SinceID = sinceID
+	}
+	if value := query.Get("limit"); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit < 1 {
+			admin.WriteError(w, http.StatusBadRequest, "limit must be a positive integer")
+			return
+		}
+		filter.Limit = limit
+	}
+
+	admin.WriteJSON(w, http.StatusOK, admin.Response{
+		Success: true,
+		Data:    l.List(filter),
+	})
+}
@@ -0,0 +1,64 @@
+package events
+
+import (
+	"context"
+	"net"
+	"sort"
+	"strings"
+	"time"
+)
+
+// WatchAddresses records an event whenever the host's global unicast addresses change
+func (l *Log) WatchAddresses(ctx context.Context, interval time.Duration) {
+	l.watchAddresses(ctx, interval, localAddresses)
+}
+
+// watchAddresses polls lookup and compares against the previous result
+func (l *Log) watchAddresses(ctx context.Context, interval time.Duration, lookup func() ([]string, error)) {
+	previous, err := lookup()
+	if err != nil {
+		previous = nil
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			current, err := lookup()
+			if err != nil {
+				continue
+			}
+			if strings.Join(current, ",") == strings.Join(previous, ",") {
+				continue
+			}
+			l.Record(TypeIPChanged, "network", "IP addresses changed", map[string]string{
+				"previous": strings.Join(previous, ","),
+				"current":  strings.Join(current, ","),
+			})
+			previous = current
+		}
+	}
+}
+
+// localAddresses returns the sorted global unicast addresses of all interfaces
+func localAddresses() ([]string, error) {
+	addrs, err := net.InterfaceAddrs()
+	if err != nil {
+		return nil, err
+	}
+
+	var result []string
+	for _, addr := range addrs {
+		ipNet, ok := addr.(*net.IPNet)
+		if !ok || !ipNet.IP.IsGlobalUnicast() {
+			continue
+		}
+		result = append(result, ipNet.IP.String())
+	}
+	sort.Strings(result)
+	return result, nil
+}
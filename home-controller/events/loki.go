@@ -0,0 +1,52 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/mjasion/balena-home/thermostats/logs"
+)
+
+// LokiSink pushes events to Loki as JSON log lines, one stream per event type
+// It is independent of the log shipper, so events arrive whatever level logs are shipped at
+type LokiSink struct {
+	client *logs.Client
+	labels map[string]string
+}
+
+// NewLokiSink creates a sink pushing with client; labels are added to every stream
+func NewLokiSink(client *logs.Client, labels map[string]string) *LokiSink {
+	return &LokiSink{client: client, labels: labels}
+}
+
+// Name returns the sink name used in logs
+func (s *LokiSink) Name() string {
+	return "loki"
+}
+
+// Send pushes the events in a single request, selectable with {stream="events", event_type="<type>"}
+func (s *LokiSink) Send(ctx context.Context, events []Event) error {
+	var streams []logs.Stream
+	index := make(map[string]int)
+	for _, event := range events {
+		line, err := json.Marshal(event)
+		if err != nil {
+			return fmt.Errorf("failed to encode event: %w", err)
+		}
+		i, ok := index[event.Type]
+		if !ok {
+			labels := make(map[string]string, len(s.labels)+2)
+			for name, value := range s.labels {
+				labels[name] = value
+			}
+			labels["stream"] = "events"
+			labels["event_type"] = event.Type
+			i = len(streams)
+			index[event.Type] = i
+			streams = append(streams, logs.Stream{Labels: labels})
+		}
+		streams[i].Entries = append(streams[i].Entries, logs.Entry{Timestamp: event.Timestamp, Line: string(line)})
+	}
+	return s.client.Push(ctx, streams)
+}
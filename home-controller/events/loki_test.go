@@ -0,0 +1,54 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/mjasion/balena-home/thermostats/logs"
+)
+
+func TestLokiSink_Send(t *testing.T) {
+	var received struct {
+		Streams []struct {
+			Stream map[string]string `json:"stream"`
+			Values [][2]string       `json:"values"`
+		} `json:"streams"`
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/loki/api/v1/push" {
+			t.Errorf("Expected path /loki/api/v1/push, got %s", r.URL.Path)
+		}
+		json.NewDecoder(r.Body).Decode(&received)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	sink := NewLokiSink(logs.NewClient(server.URL, "", ""), map[string]string{"service": "home-controller"})
+	events := []Event{
+		{ID: 1, Timestamp: time.Unix(1700000000, 0), Type: TypePushFailing, Source: "pusher", Message: "metrics push failing"},
+		{ID: 2, Timestamp: time.Unix(1700000060, 0), Type: TypePushRecovered, Source: "pusher", Message: "metrics push recovered after 3 failures"},
+		{ID: 3, Timestamp: time.Unix(1700000120, 0), Type: TypePushFailing, Source: "pusher", Message: "metrics push failing"},
+	}
+	if err := sink.Send(context.Background(), events); err != nil {
+		t.Fatalf("Send failed: %v", err)
+	}
+
+	if len(received.Streams) != 2 {
+		t.Fatalf("Expected a stream per event type, got %d", len(received.Streams))
+	}
+	failing := received.Streams[0]
+	if failing.Stream["event_type"] != TypePushFailing || failing.Stream["stream"] != "events" || failing.Stream["service"] != "home-controller" {
+		t.Errorf("Unexpected stream labels: %v", failing.Stream)
+	}
+	if len(failing.Values) != 2 || failing.Values[0][0] != "1700000000000000000" {
+		t.Fatalf("Expected both push_failing events, got %v", failing.Values)
+	}
+	var event Event
+	if err := json.Unmarshal([]byte(failing.Values[0][1]), &event); err != nil || event.ID != 1 || event.Message != "metrics push failing" {
+		t.Errorf("Expected the event as a JSON line, got %s", failing.Values[0][1])
+	}
+}
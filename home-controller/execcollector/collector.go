@@ -0,0 +1,306 @@
+package execcollector
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/mjasion/balena-home/thermostats/buffer"
+	"github.com/mjasion/balena-home/thermostats/clock"
+	"github.com/mjasion/balena-home/thermostats/collector"
+	"github.com/mjasion/balena-home/thermostats/schedule"
+	"go.uber.org/zap"
+)
+
+// maxOutputBytes limits the stdout kept from a command
+const maxOutputBytes = 1 << 20
+
+// Failure reasons of a run
+const (
+	reasonTimeout = "timeout"
+	reasonExit    = "exit"  // Failed to start or exited non-zero
+	reasonParse   = "parse" // Output is not in the configured format
+)
+
+// Command is an executable run every interval whose stdout is parsed into readings
+type Command struct {
+	Name    string        // Job label of its samples
+	Command []string      // Executable and arguments, run without a shell
+	Format  string        // prometheus or json
+	Timeout time.Duration // The process is killed after it
+}
+
+// stats are the cumulative run statistics of a command
+type stats struct {
+	runs     uint64
+	failures map[string]uint64 // By reason
+}
+
+// Collector runs commands every interval and adds their samples as remote readings labelled with
+// job, plus exec_* run statistics, so custom sources don't need a fork of this repository
+type Collector struct {
+	commands []Command
+	buffer   *buffer.RingBuffer
+	interval time.Duration
+	logger   *zap.Logger
+	skipper  schedule.Skipper
+	clock    clock.Clock
+	stats    map[string]*stats // By command name
+}
+
+// New creates a collector running the commands every intervalSeconds
+func New(commands []Command, buf *buffer.RingBuffer, intervalSeconds int, logger *zap.Logger) *Collector {
+	commandStats := make(map[string]*stats, len(commands))
+	for _, command := range commands {
+		commandStats[command.Name] = &stats{failures: make(map[string]uint64)}
+	}
+	return &Collector{
+		commands: commands,
+		buffer:   buf,
+		interval: time.Duration(intervalSeconds) * time.Second,
+		logger:   logger,
+		clock:    clock.Real,
+		stats:    commandStats,
+	}
+}
+
+// SetCadence runs the commands only every cadence.Multiplier() intervals
+func (c *Collector) SetCadence(cadence schedule.Cadence) {
+	c.skipper = schedule.NewSkipper(cadence)
+}
+
+// SetClock sets the clock driving the run ticker and reading timestamps, e.g. a fake one in tests
+func (c *Collector) SetClock(clk clock.Clock) {
+	c.clock = clk
+}
+
+// Start runs the commands immediately and then every interval until the context is cancelled
+func (c *Collector) Start(ctx context.Context) {
+	c.logger.Info("starting exec collector",
+		zap.Duration("interval", c.interval),
+		zap.Int("command_count", len(c.commands)),
+	)
+
+	ticker := c.clock.NewTicker(c.interval)
+	defer ticker.Stop()
+
+	if err := c.runAll(ctx); err != nil {
+		c.logger.Info("buffer closed, stopping exec collector")
+		return
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			c.logger.Info("stopping exec collector")
+			return
+		case <-ticker.C():
+			if c.skipper.Skip() {
+				continue
+			}
+			if err := c.runAll(ctx); err != nil {
+				c.logger.Info("buffer closed, stopping exec collector")
+				return
+			}
+		}
+	}
+}
+
+// runAll runs every command in turn
+// Returns buffer.ErrClosed once the buffer no longer accepts readings
+func (c *Collector) runAll(ctx context.Context) error {
+	for _, command := range c.commands {
+		if ctx.Err() != nil {
+			return nil
+		}
+		if err := c.run(ctx, command); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// run runs a command and adds its samples and run statistics
+func (c *Collector) run(ctx context.Context, command Command) error {
+	started := c.clock.Now()
+	samples, reason, err := c.execute(ctx, command)
+	finished := c.clock.Now()
+
+	commandStats := c.stats[command.Name]
+	commandStats.runs++
+	if err != nil {
+		commandStats.failures[reason]++
+		c.logger.Warn("exec collector command failed",
+			zap.String("job", command.Name),
+			zap.String("reason", reason),
+			zap.Error(err),
+		)
+	}
+
+	for _, s := range samples {
+		labels := make(map[string]string, len(s.labels)+1)
+		for name, value := range s.labels {
+			labels[name] = value
+		}
+		// The job wins over printed labels, as the grouping key does for Pushgateway pushes
+		labels["job"] = command.Name
+		at := s.at
+		if at.IsZero() {
+			at = finished
+		}
+		if err := c.add(at, s.metric, labels, s.value); err != nil {
+			return err
+		}
+	}
+
+	job := map[string]string{"job": command.Name}
+	if err := c.add(finished, "exec_runs_total", job, float64(commandStats.runs)); err != nil {
+		return err
+	}
+	for _, failureReason := range []string{reasonTimeout, reasonExit, reasonParse} {
+		labels := map[string]string{"job": command.Name, "reason": failureReason}
+		if err := c.add(finished, "exec_failures_total", labels, float64(commandStats.failures[failureReason])); err != nil {
+			return err
+		}
+	}
+	if err := c.add(finished, "exec_duration_seconds", job, finished.Sub(started).Seconds()); err != nil {
+		return err
+	}
+
+	c.logger.Debug("ran exec collector command",
+		zap.String("job", command.Name),
+		zap.Int("sample_count", len(samples)),
+		zap.Duration("duration", finished.Sub(started)),
+	)
+	return nil
+}
+
+// execute runs the command with its timeout and parses its output, returning the failure reason with an error
+func (c *Collector) execute(ctx context.Context, command Command) ([]sample, string, error) {
+	runCtx, cancel := context.WithTimeout(ctx, command.Timeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(runCtx, command.Command[0], command.Command[1:]...)
+	// Don't wait forever for pipes held open by children of a killed command
+	cmd.WaitDelay = time.Second
+	stdout := &limitedBuffer{limit: maxOutputBytes}
+	stderr := &limitedBuffer{limit: 4096}
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+
+	if err := cmd.Run(); err != nil {
+		if errors.Is(runCtx.Err(), context.DeadlineExceeded) {
+			return nil, reasonTimeout, fmt.Errorf("timed out after %s", command.Timeout)
+		}
+		if message := strings.TrimSpace(stderr.String()); message != "" {
+			return nil, reasonExit, fmt.Errorf("%w: %s", err, message)
+		}
+		return nil, reasonExit, err
+	}
+	if stdout.truncated {
+		return nil, reasonParse, fmt.Errorf("output exceeds %d bytes", maxOutputBytes)
+	}
+
+	samples, err := parse(command.Format, stdout.Bytes())
+	if err != nil {
+		return nil, reasonParse, err
+	}
+	return samples, "", nil
+}
+
+// add adds a remote reading of one sample
+func (c *Collector) add(at time.Time, metric string, labels map[string]string, value float64) error {
+	return c.buffer.Add(&buffer.Reading{
+		Type: buffer.ReadingTypeRemote,
+		Remote: &buffer.RemoteReading{
+			Timestamp: at,
+			Metric:    metric,
+			Labels:    labels,
+			Value:     value,
+		},
+	})
+}
+
+// limitedBuffer keeps the first limit bytes written to it and discards the rest
+type limitedBuffer struct {
+	bytes.Buffer
+	limit     int
+	truncated bool
+}
+
+// Write never fails, so a chatty command isn't killed by a broken pipe
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if room := b.limit - b.Len(); room < len(p) {
+		b.truncated = true
+		if room > 0 {
+			b.Buffer.Write(p[:room])
+		}
+		return len(p), nil
+	}
+	return b.Buffer.Write(p)
+}
+
+// Options are the settings of the exec collector
+type Options struct {
+	Commands []struct {
+		Name           string   `yaml:"name"`
+		Command        []string `yaml:"command"`
+		Format         string   `yaml:"format"`         // prometheus (default) or json
+		TimeoutSeconds float64  `yaml:"timeoutSeconds"` // Defaults to 10
+	} `yaml:"commands"`
+}
+
+func init() {
+	collector.Register("exec", func(spec collector.Spec, deps collector.Deps) (collector.Collector, error) {
+		var options Options
+		if err := spec.Options.Decode(&options); err != nil {
+			return nil, err
+		}
+		if len(options.Commands) == 0 {
+			return nil, fmt.Errorf("at least one command must be configured")
+		}
+
+		seenNames := make(map[string]bool)
+		commands := make([]Command, len(options.Commands))
+		for i, option := range options.Commands {
+			if option.Name == "" {
+				return nil, fmt.Errorf("command %d: name is required", i)
+			}
+			if seenNames[option.Name] {
+				return nil, fmt.Errorf("command %s: duplicate name", option.Name)
+			}
+			seenNames[option.Name] = true
+			if len(option.Command) == 0 || option.Command[0] == "" {
+				return nil, fmt.Errorf("command %s: command is required", option.Name)
+			}
+
+			format := strings.ToLower(option.Format)
+			if format == "" {
+				format = FormatPrometheus
+			}
+			if format != FormatPrometheus && format != FormatJSON {
+				return nil, fmt.Errorf("command %s: format must be 'prometheus' or 'json', got: %s", option.Name, option.Format)
+			}
+			timeout := 10 * time.Second
+			if option.TimeoutSeconds < 0 {
+				return nil, fmt.Errorf("command %s: timeout must not be negative", option.Name)
+			}
+			if option.TimeoutSeconds > 0 {
+				timeout = time.Duration(option.TimeoutSeconds * float64(time.Second))
+			}
+			if timeout > spec.Interval {
+				return nil, fmt.Errorf("command %s: timeout must not exceed the interval of %s", option.Name, spec.Interval)
+			}
+
+			commands[i] = Command{Name: option.Name, Command: option.Command, Format: format, Timeout: timeout}
+		}
+
+		c := New(commands, deps.Buffer, int(spec.Interval.Seconds()), deps.Logger)
+		c.SetCadence(deps.Cadence)
+		return c, nil
+	})
+}
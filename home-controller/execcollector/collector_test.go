@@ -0,0 +1,123 @@
+package execcollector
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/mjasion/balena-home/thermostats/buffer"
+	"go.uber.org/zap"
+)
+
+func TestParse_Prometheus(t *testing.T) {
+	output := []byte(`# HELP backup_size_bytes Size of the last backup
+# TYPE backup_size_bytes gauge
+backup_size_bytes{target="nas"} 1024
+backup_success 1 1760000000000
+`)
+	samples, err := parse(FormatPrometheus, output)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if len(samples) != 2 {
+		t.Fatalf("Expected 2 samples, got %d", len(samples))
+	}
+	if samples[0].metric != "backup_size_bytes" || samples[0].labels["target"] != "nas" || samples[0].value != 1024 || !samples[0].at.IsZero() {
+		t.Errorf("Expected the labelled size without timestamp, got %+v", samples[0])
+	}
+	if samples[1].at.UnixMilli() != 1760000000000 {
+		t.Errorf("Expected the printed timestamp, got %v", samples[1].at)
+	}
+
+	if _, err := parse(FormatPrometheus, []byte("backup size 1\n")); err == nil {
+		t.Error("Expected an error for invalid exposition format")
+	}
+}
+
+func TestParse_JSON(t *testing.T) {
+	samples, err := parse(FormatJSON, []byte(`{"backup_success": 1, "backup_size_bytes": 2048}`))
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if len(samples) != 2 || samples[0].metric != "backup_size_bytes" || samples[1].value != 1 {
+		t.Errorf("Expected sorted metrics from the object, got %+v", samples)
+	}
+
+	samples, err = parse(FormatJSON, []byte(`[{"metric": "disk_free_bytes", "labels": {"mount": "/data"}, "value": 5e9, "timestamp": "2026-10-17T12:00:00Z"}]`))
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if len(samples) != 1 || samples[0].labels["mount"] != "/data" || samples[0].at.Hour() != 12 {
+		t.Errorf("Expected the labelled sample with timestamp, got %+v", samples)
+	}
+
+	invalid := []string{
+		`{"backup-size": 1}`,
+		`[{"metric": "disk_free_bytes"}]`,
+		`[{"metric": "disk_free_bytes", "labels": {"__name__": "x"}, "value": 1}]`,
+		`{"backup_success": "yes"}`,
+	}
+	for _, output := range invalid {
+		if _, err := parse(FormatJSON, []byte(output)); err == nil {
+			t.Errorf("Expected an error for %s", output)
+		}
+	}
+}
+
+// remoteValues returns the values of the remote readings by metric and reason or job label
+func remoteValues(readings []*buffer.Reading) map[string]float64 {
+	values := make(map[string]float64)
+	for _, reading := range readings {
+		key := reading.Remote.Metric
+		if reason := reading.Remote.Labels["reason"]; reason != "" {
+			key += "/" + reason
+		}
+		values[key] = reading.Remote.Value
+	}
+	return values
+}
+
+func TestCollector_Run(t *testing.T) {
+	buf := buffer.New(100, zap.NewNop())
+	c := New([]Command{
+		{Name: "backup", Command: []string{"sh", "-c", `echo '{"backup_success": 1}'`}, Format: FormatJSON, Timeout: 5 * time.Second},
+		{Name: "broken", Command: []string{"sh", "-c", "echo oops >&2; exit 3"}, Format: FormatPrometheus, Timeout: 5 * time.Second},
+		{Name: "garbage", Command: []string{"echo", "not metrics"}, Format: FormatPrometheus, Timeout: 5 * time.Second},
+		{Name: "slow", Command: []string{"sleep", "5"}, Format: FormatPrometheus, Timeout: 100 * time.Millisecond},
+	}, buf, 60, zap.NewNop())
+
+	if err := c.runAll(context.Background()); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	byJob := make(map[string][]*buffer.Reading)
+	for _, reading := range buf.GetAll() {
+		byJob[reading.Remote.Labels["job"]] = append(byJob[reading.Remote.Labels["job"]], reading)
+	}
+
+	backup := remoteValues(byJob["backup"])
+	if backup["backup_success"] != 1 || backup["exec_runs_total"] != 1 || backup["exec_failures_total/exit"] != 0 {
+		t.Errorf("Expected the backup sample and a successful run, got %v", backup)
+	}
+	if _, ok := backup["exec_duration_seconds"]; !ok {
+		t.Error("Expected the run duration")
+	}
+
+	for job, reason := range map[string]string{"broken": reasonExit, "garbage": reasonParse, "slow": reasonTimeout} {
+		values := remoteValues(byJob[job])
+		if values["exec_failures_total/"+reason] != 1 || values["exec_runs_total"] != 1 {
+			t.Errorf("%s: expected a %s failure, got %v", job, reason, values)
+		}
+	}
+
+	// Counters accumulate across runs
+	buf.GetAllAndClear()
+	if err := c.runAll(context.Background()); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	for _, reading := range buf.GetAll() {
+		if reading.Remote.Labels["job"] == "slow" && reading.Remote.Labels["reason"] == reasonTimeout && reading.Remote.Value != 2 {
+			t.Errorf("Expected 2 timeouts after the second run, got %v", reading.Remote.Value)
+		}
+	}
+}
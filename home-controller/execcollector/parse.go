@@ -0,0 +1,150 @@
+package execcollector
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/prometheus/common/expfmt"
+	"github.com/prometheus/common/model"
+)
+
+// Output formats of a command
+const (
+	FormatPrometheus = "prometheus"
+	FormatJSON       = "json"
+)
+
+// sample is a metric value printed by a command
+type sample struct {
+	metric string
+	labels map[string]string
+	value  float64
+	at     time.Time // Zero uses the time the command finished
+}
+
+// jsonSample is an element of a JSON array output
+type jsonSample struct {
+	Metric    string            `json:"metric"`
+	Labels    map[string]string `json:"labels"`
+	Value     *float64          `json:"value"`
+	Timestamp *time.Time        `json:"timestamp"` // RFC 3339, optional
+}
+
+// parse parses command output in the format
+func parse(format string, output []byte) ([]sample, error) {
+	var samples []sample
+	var err error
+	if format == FormatJSON {
+		samples, err = parseJSON(output)
+	} else {
+		samples, err = parsePrometheus(output)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	for _, s := range samples {
+		if !model.IsValidLegacyMetricName(s.metric) {
+			return nil, fmt.Errorf("invalid metric name %q", s.metric)
+		}
+		for name := range s.labels {
+			if !model.LabelName(name).IsValidLegacy() || name == model.MetricNameLabel {
+				return nil, fmt.Errorf("metric %s: invalid label name %q", s.metric, name)
+			}
+		}
+	}
+	return samples, nil
+}
+
+// parsePrometheus parses the Prometheus text exposition format; samples with a timestamp keep it
+func parsePrometheus(output []byte) ([]sample, error) {
+	decoder := &expfmt.SampleDecoder{
+		Dec:  expfmt.NewDecoder(bytes.NewReader(output), expfmt.NewFormat(expfmt.TypeTextPlain)),
+		Opts: &expfmt.DecodeOptions{},
+	}
+	var decoded model.Vector
+	for {
+		var vector model.Vector
+		if err := decoder.Decode(&vector); err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			return nil, err
+		}
+		decoded = append(decoded, vector...)
+	}
+	// Metric families are decoded in map order; sorted by name and labels, readings are added in a
+	// stable order
+	sort.SliceStable(decoded, func(i, j int) bool {
+		a, b := decoded[i].Metric, decoded[j].Metric
+		if a[model.MetricNameLabel] != b[model.MetricNameLabel] {
+			return a[model.MetricNameLabel] < b[model.MetricNameLabel]
+		}
+		return a.String() < b.String()
+	})
+
+	samples := make([]sample, 0, len(decoded))
+	for _, s := range decoded {
+		if math.IsNaN(float64(s.Value)) {
+			continue
+		}
+		labels := make(map[string]string, len(s.Metric))
+		for name, value := range s.Metric {
+			labels[string(name)] = string(value)
+		}
+		metric := labels[model.MetricNameLabel]
+		delete(labels, model.MetricNameLabel)
+		var at time.Time
+		if s.Timestamp != 0 {
+			at = s.Timestamp.Time()
+		}
+		samples = append(samples, sample{metric: metric, labels: labels, value: float64(s.Value), at: at})
+	}
+	return samples, nil
+}
+
+// parseJSON parses either an object of metric names to numbers, e.g. {"backup_size_bytes": 1024},
+// or an array of {"metric", "labels", "value", "timestamp"} objects
+func parseJSON(output []byte) ([]sample, error) {
+	output = bytes.TrimSpace(output)
+	if len(output) > 0 && output[0] == '[' {
+		var elements []jsonSample
+		if err := json.Unmarshal(output, &elements); err != nil {
+			return nil, fmt.Errorf("invalid JSON samples: %w", err)
+		}
+		samples := make([]sample, 0, len(elements))
+		for i, element := range elements {
+			if element.Value == nil {
+				return nil, fmt.Errorf("sample %d: value is required", i)
+			}
+			s := sample{metric: element.Metric, labels: element.Labels, value: *element.Value}
+			if element.Timestamp != nil {
+				s.at = *element.Timestamp
+			}
+			samples = append(samples, s)
+		}
+		return samples, nil
+	}
+
+	var values map[string]float64
+	if err := json.Unmarshal(output, &values); err != nil {
+		return nil, fmt.Errorf("invalid JSON metrics, expected an object of numbers or an array of samples: %w", err)
+	}
+	// Sorted, so readings are added in a stable order
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	samples := make([]sample, 0, len(values))
+	for _, name := range names {
+		samples = append(samples, sample{metric: name, value: values[name]})
+	}
+	return samples, nil
+}
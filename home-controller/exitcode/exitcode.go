@@ -0,0 +1,104 @@
+package exitcode
+
+import (
+	"errors"
+	"os"
+
+	"go.uber.org/zap"
+)
+
+// Exit codes of the service, so restart policies and fleet alerts can tell failure classes apart
+const (
+	OK         = 0
+	Unhealthy  = 1 // The healthcheck command found the service hung; container health checks only accept 0 and 1
+	Config     = 2 // Invalid configuration, flags or command usage; restarting won't help
+	Dependency = 3 // A device, file or service needed at startup is unavailable; a later restart may succeed
+	Runtime    = 4 // An unexpected failure of a running service, e.g. a stalled loop
+)
+
+// Name returns the failure class of an exit code, as logged in failure_class
+func Name(code int) string {
+	switch code {
+	case OK:
+		return "ok"
+	case Unhealthy:
+		return "unhealthy"
+	case Config:
+		return "config"
+	case Dependency:
+		return "dependency"
+	case Runtime:
+		return "runtime"
+	}
+	return "unknown"
+}
+
+// Error classifies the error it wraps
+type Error struct {
+	Code int
+	Err  error
+}
+
+// Error returns the message of the wrapped error
+func (e *Error) Error() string {
+	return e.Err.Error()
+}
+
+// Unwrap returns the wrapped error
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// ConfigError classifies err as an invalid configuration, unless it is already classified
+func ConfigError(err error) error {
+	return classify(Config, err)
+}
+
+// DependencyError classifies err as an unavailable dependency, unless it is already classified
+func DependencyError(err error) error {
+	return classify(Dependency, err)
+}
+
+// RuntimeError classifies err as a failure of the running service, unless it is already classified
+func RuntimeError(err error) error {
+	return classify(Runtime, err)
+}
+
+// classify wraps err with the code; a classification made closer to the failure wins, e.g. a
+// collector reporting missing hardware while its caller treats failures as configuration errors
+func classify(code int, err error) error {
+	var classified *Error
+	if errors.As(err, &classified) {
+		return err
+	}
+	return &Error{Code: code, Err: err}
+}
+
+// Of returns the exit code for err: OK for nil, its class when classified and Runtime otherwise
+func Of(err error) int {
+	if err == nil {
+		return OK
+	}
+	var classified *Error
+	if errors.As(err, &classified) {
+		return classified.Code
+	}
+	return Runtime
+}
+
+// exit terminates the process, replaced in tests
+var exit = os.Exit
+
+// Fatal logs err with its exit code and failure class, flushes the logger and exits with the code;
+// use it instead of logger.Fatal, which always exits with 1
+func Fatal(logger *zap.Logger, message string, err error, fields ...zap.Field) {
+	code := Of(err)
+	fields = append(fields,
+		zap.Error(err),
+		zap.Int("exit_code", code),
+		zap.String("failure_class", Name(code)),
+	)
+	logger.Error(message, fields...)
+	logger.Sync()
+	exit(code)
+}
@@ -0,0 +1,43 @@
+package exitcode
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+func TestOf(t *testing.T) {
+	missing := errors.New("no such device")
+	tests := []struct {
+		name string
+		err  error
+		code int
+	}{
+		{"nil", nil, OK},
+		{"unclassified", missing, Runtime},
+		{"config", ConfigError(missing), Config},
+		{"wrapped dependency", fmt.Errorf("collector water: %w", DependencyError(missing)), Dependency},
+		{"first classification wins", ConfigError(fmt.Errorf("collector water: %w", DependencyError(missing))), Dependency},
+	}
+	for _, tt := range tests {
+		if code := Of(tt.err); code != tt.code {
+			t.Errorf("%s: expected exit code %d, got %d", tt.name, tt.code, code)
+		}
+	}
+	if err := ConfigError(missing); !errors.Is(err, missing) || err.Error() != missing.Error() {
+		t.Errorf("Expected the classified error to wrap the original, got %v", err)
+	}
+}
+
+func TestFatal(t *testing.T) {
+	defer func(original func(int)) { exit = original }(exit)
+	exitCode := -1
+	exit = func(code int) { exitCode = code }
+
+	Fatal(zap.NewNop(), "failed to open history database", DependencyError(errors.New("read-only file system")))
+	if exitCode != Dependency {
+		t.Errorf("Expected exit code %d, got %d", Dependency, exitCode)
+	}
+}
@@ -0,0 +1,211 @@
+package faults
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mjasion/balena-home/thermostats/clock"
+	"github.com/mjasion/balena-home/thermostats/events"
+	"go.uber.org/zap"
+)
+
+// Faults that can be injected
+const (
+	PushFailure    = "push_failure"    // Remote write requests fail with 503 Service Unavailable
+	ScrapeTimeout  = "scrape_timeout"  // Power meter and heat pump requests hang until the client times out
+	Netatmo429     = "netatmo_429"     // Netatmo API requests are rate limited with 429 Too Many Requests
+	BufferPressure = "buffer_pressure" // Pushers keep readings buffered, filling the buffer as if the uplink stalled
+)
+
+// known lists every fault that can be injected
+var known = map[string]bool{
+	PushFailure:    true,
+	ScrapeTimeout:  true,
+	Netatmo429:     true,
+	BufferPressure: true,
+}
+
+// Known returns the names of all faults, sorted
+func Known() []string {
+	names := make([]string, 0, len(known))
+	for name := range known {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Fault is an injected fault and when it ends
+type Fault struct {
+	Name    string    `json:"name"`
+	Expires time.Time `json:"expires"`
+}
+
+// Injector simulates failures on demand so alerting rules and retry behaviour can be rehearsed
+// on a device before a real outage; every fault expires on its own
+// A nil *Injector is valid and never injects a fault
+type Injector struct {
+	maxDuration time.Duration
+	clock       clock.Clock
+	eventLog    *events.Log
+	logger      *zap.Logger
+
+	mu     sync.Mutex
+	active map[string]time.Time // Expiry by fault name
+}
+
+// NewInjector creates an injector accepting faults lasting up to maxDuration
+func NewInjector(maxDuration time.Duration, logger *zap.Logger) *Injector {
+	return &Injector{
+		maxDuration: maxDuration,
+		clock:       clock.Real,
+		logger:      logger,
+		active:      make(map[string]time.Time),
+	}
+}
+
+// SetClock sets the clock expiring faults, e.g. a fake one in tests
+func (i *Injector) SetClock(c clock.Clock) {
+	i.clock = c
+}
+
+// SetEventLog sets the event log used to record injected and cleared faults, so they show up
+// next to the alerts they cause
+func (i *Injector) SetEventLog(eventLog *events.Log) {
+	i.eventLog = eventLog
+}
+
+// Inject activates the fault for duration, replacing the expiry of an active one
+func (i *Injector) Inject(name string, duration time.Duration) (Fault, error) {
+	if !known[name] {
+		return Fault{}, fmt.Errorf("unknown fault %q, expected one of %s", name, strings.Join(Known(), ", "))
+	}
+	if duration <= 0 || duration > i.maxDuration {
+		return Fault{}, fmt.Errorf("duration must be between 1s and %s", i.maxDuration)
+	}
+
+	fault := Fault{Name: name, Expires: i.clock.Now().Add(duration)}
+	i.mu.Lock()
+	i.active[name] = fault.Expires
+	i.mu.Unlock()
+
+	i.logger.Warn("fault injected", zap.String("fault", name), zap.Duration("duration", duration))
+	i.eventLog.Record(events.TypeFaultInjected, "faults", fmt.Sprintf("%s injected for %s", name, duration), map[string]string{
+		"fault":    name,
+		"duration": duration.String(),
+	})
+	return fault, nil
+}
+
+// Clear ends the fault early and reports whether it was active
+func (i *Injector) Clear(name string) bool {
+	i.mu.Lock()
+	expires, ok := i.active[name]
+	delete(i.active, name)
+	i.mu.Unlock()
+	if !ok || !i.clock.Now().Before(expires) {
+		return false
+	}
+
+	i.logger.Info("fault cleared", zap.String("fault", name))
+	i.eventLog.Record(events.TypeFaultCleared, "faults", name+" cleared", map[string]string{"fault": name})
+	return true
+}
+
+// Active reports whether the fault is being injected
+func (i *Injector) Active(name string) bool {
+	if i == nil {
+		return false
+	}
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	expires, ok := i.active[name]
+	if ok && !i.clock.Now().Before(expires) {
+		delete(i.active, name)
+		return false
+	}
+	return ok
+}
+
+// List returns the active faults, sorted by name
+func (i *Injector) List() []Fault {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	now := i.clock.Now()
+	faults := make([]Fault, 0, len(i.active))
+	for name, expires := range i.active {
+		if !now.Before(expires) {
+			delete(i.active, name)
+			continue
+		}
+		faults = append(faults, Fault{Name: name, Expires: expires})
+	}
+	sort.Slice(faults, func(a, b int) bool { return faults[a].Name < faults[b].Name })
+	return faults
+}
+
+// Instrument wraps the client's transport so its requests fail while the fault is active
+// Call before telemetry instrumentation, so injected failures are recorded like real ones
+func (i *Injector) Instrument(client *http.Client, fault string) {
+	if i == nil {
+		return
+	}
+	next := client.Transport
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	client.Transport = &roundTripper{injector: i, fault: fault, next: next}
+}
+
+// roundTripper fails requests while its fault is active and passes them to next otherwise
+type roundTripper struct {
+	injector *Injector
+	fault    string
+	next     http.RoundTripper
+}
+
+// RoundTrip sends the request unless the fault is active
+func (t *roundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	if !t.injector.Active(t.fault) {
+		return t.next.RoundTrip(req)
+	}
+	if req.Body != nil {
+		req.Body.Close()
+	}
+
+	switch t.fault {
+	case ScrapeTimeout:
+		// Hang like an unresponsive device until the client timeout or the caller gives up
+		<-req.Context().Done()
+		return nil, req.Context().Err()
+	case Netatmo429:
+		return response(req, http.StatusTooManyRequests, `{"error":{"code":26,"message":"User usage reached (injected fault)"}}`), nil
+	default:
+		return response(req, http.StatusServiceUnavailable, "injected fault: "+t.fault), nil
+	}
+}
+
+// Unwrap returns the wrapped round tripper
+func (t *roundTripper) Unwrap() http.RoundTripper {
+	return t.next
+}
+
+// response builds a response to the request without sending it
+func response(req *http.Request, code int, body string) *http.Response {
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", code, http.StatusText(code)),
+		StatusCode:    code,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        http.Header{"Retry-After": []string{"60"}},
+		Body:          io.NopCloser(strings.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}
+}
@@ -0,0 +1,118 @@
+package faults
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/mjasion/balena-home/thermostats/clock"
+	"github.com/mjasion/balena-home/thermostats/events"
+	"go.uber.org/zap"
+)
+
+func TestInjector_Expiry(t *testing.T) {
+	clk := clock.NewFake(time.Date(2026, 10, 17, 12, 0, 0, 0, time.UTC))
+	eventLog := events.NewLog(10, zap.NewNop())
+	injector := NewInjector(time.Hour, zap.NewNop())
+	injector.SetClock(clk)
+	injector.SetEventLog(eventLog)
+
+	if _, err := injector.Inject("disk_full", time.Minute); err == nil {
+		t.Error("Expected an error for an unknown fault")
+	}
+	if _, err := injector.Inject(PushFailure, 2*time.Hour); err == nil {
+		t.Error("Expected an error for a duration above the maximum")
+	}
+
+	if _, err := injector.Inject(PushFailure, 5*time.Minute); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if _, err := injector.Inject(BufferPressure, time.Minute); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if !injector.Active(PushFailure) || injector.Active(Netatmo429) {
+		t.Error("Expected only the injected faults to be active")
+	}
+	if list := injector.List(); len(list) != 2 || list[0].Name != BufferPressure {
+		t.Errorf("Expected 2 active faults sorted by name, got %v", list)
+	}
+
+	clk.Advance(time.Minute)
+	if injector.Active(BufferPressure) {
+		t.Error("Expected buffer_pressure to expire after a minute")
+	}
+	if !injector.Clear(PushFailure) {
+		t.Error("Expected push_failure to be cleared")
+	}
+	if injector.Clear(PushFailure) || injector.Active(PushFailure) {
+		t.Error("Expected push_failure to stay cleared")
+	}
+
+	if list := eventLog.List(events.Filter{Type: events.TypeFaultInjected}); len(list) != 2 {
+		t.Errorf("Expected 2 injected events, got %d", len(list))
+	}
+	if list := eventLog.List(events.Filter{Type: events.TypeFaultCleared}); len(list) != 1 {
+		t.Errorf("Expected 1 cleared event, got %d", len(list))
+	}
+}
+
+func TestInjector_Instrument(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+	}))
+	defer server.Close()
+
+	injector := NewInjector(time.Hour, zap.NewNop())
+	tests := []struct {
+		fault string
+		code  int
+	}{
+		{PushFailure, http.StatusServiceUnavailable},
+		{Netatmo429, http.StatusTooManyRequests},
+	}
+	for _, tt := range tests {
+		client := &http.Client{}
+		injector.Instrument(client, tt.fault)
+
+		resp, err := client.Get(server.URL)
+		if err != nil || resp.StatusCode != http.StatusOK {
+			t.Fatalf("%s: expected the request to pass before injection, got %v", tt.fault, err)
+		}
+		resp.Body.Close()
+
+		injector.Inject(tt.fault, time.Minute)
+		resp, err = client.Get(server.URL)
+		if err != nil {
+			t.Fatalf("%s: expected a response, got %v", tt.fault, err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != tt.code {
+			t.Errorf("%s: expected status %d, got %d", tt.fault, tt.code, resp.StatusCode)
+		}
+		injector.Clear(tt.fault)
+	}
+	if requests != len(tests) {
+		t.Errorf("Expected %d requests to reach the server, got %d", len(tests), requests)
+	}
+
+	client := &http.Client{Timeout: 50 * time.Millisecond}
+	injector.Instrument(client, ScrapeTimeout)
+	injector.Inject(ScrapeTimeout, time.Minute)
+	_, err := client.Get(server.URL)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected the client to time out, got %v", err)
+	}
+}
+
+func TestInjector_Nil(t *testing.T) {
+	var injector *Injector
+	client := &http.Client{}
+	injector.Instrument(client, PushFailure)
+	if client.Transport != nil || injector.Active(PushFailure) {
+		t.Error("Expected a nil injector to leave the client unchanged and inject nothing")
+	}
+}
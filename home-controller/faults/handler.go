@@ -0,0 +1,66 @@
+package faults
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/mjasion/balena-home/thermostats/admin"
+)
+
+// RegisterHandlers registers the fault injection endpoints on the admin server
+func (i *Injector) RegisterHandlers(server *admin.Server) {
+	server.HandleFunc("GET /api/faults", i.handleList)
+	server.HandleFunc("POST /api/faults/{name}", i.handleInject)
+	server.HandleFunc("DELETE /api/faults/{name}", i.handleClear)
+}
+
+// handleList handles GET /api/faults
+func (i *Injector) handleList(w http.ResponseWriter, r *http.Request) {
+	active := i.List()
+	admin.WriteJSON(w, http.StatusOK, admin.Response{
+		Success: true,
+		Message: fmt.Sprintf("%d faults active", len(active)),
+		Data: map[string]interface{}{
+			"active": active,
+			"known":  Known(),
+		},
+	})
+}
+
+// handleInject handles POST /api/faults/{name}?duration=5m; the duration defaults to a minute
+func (i *Injector) handleInject(w http.ResponseWriter, r *http.Request) {
+	duration := time.Minute
+	if value := r.URL.Query().Get("duration"); value != "" {
+		parsed, err := time.ParseDuration(value)
+		if err != nil {
+			admin.WriteError(w, http.StatusBadRequest, "duration must be a Go duration, e.g. 5m")
+			return
+		}
+		duration = parsed
+	}
+
+	fault, err := i.Inject(r.PathValue("name"), duration)
+	if err != nil {
+		admin.WriteError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	admin.WriteJSON(w, http.StatusOK, admin.Response{
+		Success: true,
+		Message: fmt.Sprintf("Injecting %s until %s.", fault.Name, fault.Expires.Format(time.RFC3339)),
+		Data:    fault,
+	})
+}
+
+// handleClear handles DELETE /api/faults/{name}
+func (i *Injector) handleClear(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+	if !i.Clear(name) {
+		admin.WriteError(w, http.StatusNotFound, fmt.Sprintf("fault %s is not active", name))
+		return
+	}
+	admin.WriteJSON(w, http.StatusOK, admin.Response{
+		Success: true,
+		Message: fmt.Sprintf("Cleared %s.", name),
+	})
+}
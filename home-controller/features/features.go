@@ -0,0 +1,77 @@
+package features
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Experimental features; a feature checks its flag with Flags.Enabled before changing behaviour
+const (
+	RemoteWriteV2     = "remote_write_v2"    // Push with the Prometheus remote write 2.0 protocol
+	AdaptiveIntervals = "adaptive_intervals" // Scrape less often while readings are steady
+	Zstd              = "zstd"               // Compress pushes with zstd instead of snappy
+)
+
+// known lists every flag that can be enabled
+var known = map[string]bool{
+	RemoteWriteV2:     true,
+	AdaptiveIntervals: true,
+	Zstd:              true,
+}
+
+// Known returns the names of all flags, sorted
+func Known() []string {
+	names := make([]string, 0, len(known))
+	for name := range known {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Flags is the set of enabled experimental features; the zero value enables none
+type Flags struct {
+	enabled map[string]bool
+}
+
+// Parse enables the named flags; unknown names are rejected so a typo doesn't go unnoticed
+func Parse(names []string) (Flags, error) {
+	flags := Flags{enabled: make(map[string]bool, len(names))}
+	for _, name := range names {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+		if !known[name] {
+			return Flags{}, fmt.Errorf("unknown feature flag %q, expected one of %s", name, strings.Join(Known(), ", "))
+		}
+		flags.enabled[name] = true
+	}
+	return flags, nil
+}
+
+// Enabled reports whether the flag is enabled
+func (f Flags) Enabled(name string) bool {
+	return f.enabled[name]
+}
+
+// Names returns the enabled flags, sorted
+func (f Flags) Names() []string {
+	names := make([]string, 0, len(f.enabled))
+	for name := range f.enabled {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Labels returns a feature_<name> label for every known flag, "true" or "false", so dashboards
+// can compare devices with a feature on and off during a rollout
+func (f Flags) Labels() map[string]string {
+	labels := make(map[string]string, len(known))
+	for name := range known {
+		labels["feature_"+name] = fmt.Sprint(f.enabled[name])
+	}
+	return labels
+}
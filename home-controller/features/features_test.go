@@ -0,0 +1,35 @@
+package features
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	flags, err := Parse([]string{" Zstd", "remote_write_v2", ""})
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if !flags.Enabled(Zstd) || !flags.Enabled(RemoteWriteV2) || flags.Enabled(AdaptiveIntervals) {
+		t.Errorf("Expected zstd and remote_write_v2 enabled, got %v", flags.Names())
+	}
+	if names := flags.Names(); !reflect.DeepEqual(names, []string{"remote_write_v2", "zstd"}) {
+		t.Errorf("Expected sorted names, got %v", names)
+	}
+
+	labels := flags.Labels()
+	if labels["feature_zstd"] != "true" || labels["feature_adaptive_intervals"] != "false" || len(labels) != len(Known()) {
+		t.Errorf("Expected a label for every known flag, got %v", labels)
+	}
+
+	if _, err := Parse([]string{"zstd", "remote_write_3"}); err == nil {
+		t.Error("Expected an error for an unknown flag")
+	}
+}
+
+func TestFlags_ZeroValue(t *testing.T) {
+	var flags Flags
+	if flags.Enabled(Zstd) || len(flags.Names()) != 0 {
+		t.Error("Expected the zero value to enable nothing")
+	}
+}
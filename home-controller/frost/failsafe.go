@@ -0,0 +1,264 @@
+package frost
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mjasion/balena-home/thermostats/buffer"
+	"github.com/mjasion/balena-home/thermostats/events"
+	"github.com/mjasion/balena-home/thermostats/notify"
+	"go.uber.org/zap"
+)
+
+// staleAfter is how long a temperature counts; a sensor that stopped reporting doesn't keep the failsafe active
+const staleAfter = 30 * time.Minute
+
+// recoveryMargin is how far above the floor every room must be before the failsafe clears
+const recoveryMargin = 1.0
+
+// checkInterval is how often the failsafe re-evaluates without new readings, refreshing the forced setpoint
+const checkInterval = time.Minute
+
+// Thermostat holds a room at a manual setpoint; netatmo.Fetcher implements it
+type Thermostat interface {
+	SetRoomSetpoint(ctx context.Context, homeID, roomID string, temperature float64, until time.Time) error
+}
+
+// temperature is the latest temperature of a room or sensor
+type temperature struct {
+	celsius float64
+	at      time.Time
+}
+
+// room is a Netatmo room whose setpoint can be forced
+type room struct {
+	homeID, roomID, name string
+}
+
+// Failsafe forces Netatmo rooms to a safe setpoint and sends notifications when any room falls below
+// a temperature floor, independently of automation rules and of the vacation or maintenance mode
+// Temperatures come from Netatmo rooms, fused rooms and BLE sensors; outdoor sensors must be excluded
+type Failsafe struct {
+	floor      float64
+	setpoint   float64
+	hold       time.Duration
+	exclude    map[string]bool
+	thermostat Thermostat
+	notifiers  []notify.Notifier
+	eventLog   *events.Log
+	logger     *zap.Logger
+	now        func() time.Time
+	check      chan struct{} // Requests an evaluation after a reading below the floor
+
+	mu           sync.Mutex
+	temperatures map[string]temperature // By room or sensor name
+	rooms        map[string]room        // Netatmo rooms by home and room ID
+	active       bool
+	forcedUntil  time.Time // Zero until the setpoint was forced successfully
+}
+
+// New creates a failsafe forcing setpointCelsius for holdMinutes, refreshed while any room not in
+// exclude is below floorCelsius. Register Observe as a buffer listener to feed it and set the
+// thermostat before starting it.
+func New(floorCelsius, setpointCelsius float64, holdMinutes int, exclude []string, notifiers []notify.Notifier, logger *zap.Logger) *Failsafe {
+	excluded := make(map[string]bool, len(exclude))
+	for _, name := range exclude {
+		excluded[name] = true
+	}
+	return &Failsafe{
+		floor:        floorCelsius,
+		setpoint:     setpointCelsius,
+		hold:         time.Duration(holdMinutes) * time.Minute,
+		exclude:      excluded,
+		notifiers:    notifiers,
+		logger:       logger,
+		now:          time.Now,
+		check:        make(chan struct{}, 1),
+		temperatures: make(map[string]temperature),
+		rooms:        make(map[string]room),
+	}
+}
+
+// SetThermostat sets the thermostat whose rooms are forced to the safe setpoint
+func (f *Failsafe) SetThermostat(thermostat Thermostat) {
+	f.thermostat = thermostat
+}
+
+// SetEventLog sets the event log used to record frost alarms
+func (f *Failsafe) SetEventLog(eventLog *events.Log) {
+	f.eventLog = eventLog
+}
+
+// Observe tracks room temperatures and the Netatmo rooms from the reading stream
+func (f *Failsafe) Observe(reading *buffer.Reading) {
+	var name string
+	var value temperature
+	switch reading.Type {
+	case buffer.ReadingTypeNetatmo:
+		t := reading.Thermostat
+		f.mu.Lock()
+		f.rooms[t.HomeID+"/"+t.RoomID] = room{homeID: t.HomeID, roomID: t.RoomID, name: t.RoomName}
+		f.mu.Unlock()
+		if !t.Reachable || t.Historical {
+			return
+		}
+		name, value = t.RoomName, temperature{celsius: t.MeasuredTemperature, at: t.Timestamp}
+	case buffer.ReadingTypeRoom:
+		name, value = reading.Room.Room, temperature{celsius: reading.Room.TemperatureCelsius, at: reading.Room.Timestamp}
+	case buffer.ReadingTypeBLE:
+		name, value = reading.BLE.SensorName, temperature{celsius: reading.BLE.TemperatureCelsius, at: reading.BLE.Timestamp}
+	default:
+		return
+	}
+	if f.exclude[name] {
+		return
+	}
+
+	f.mu.Lock()
+	f.temperatures[name] = value
+	active := f.active
+	f.mu.Unlock()
+
+	if value.celsius < f.floor && !active {
+		select {
+		case f.check <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// Start evaluates the temperatures on readings below the floor and every minute until the context is cancelled
+func (f *Failsafe) Start(ctx context.Context) {
+	f.logger.Info("starting frost protection",
+		zap.Float64("floor_celsius", f.floor),
+		zap.Float64("setpoint_celsius", f.setpoint),
+		zap.Duration("hold", f.hold),
+	)
+
+	ticker := time.NewTicker(checkInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			f.logger.Info("stopping frost protection")
+			return
+		case <-ticker.C:
+		case <-f.check:
+		}
+		f.evaluate(ctx)
+	}
+}
+
+// evaluate raises or clears the alarm and forces the setpoint while it is raised
+func (f *Failsafe) evaluate(ctx context.Context) {
+	now := f.now()
+
+	f.mu.Lock()
+	var cold []string
+	fresh := 0
+	recovered := true
+	for name, t := range f.temperatures {
+		if now.Sub(t.at) > staleAfter {
+			continue
+		}
+		fresh++
+		if t.celsius < f.floor {
+			cold = append(cold, fmt.Sprintf("%s %.1f°C", name, t.celsius))
+		}
+		if t.celsius < f.floor+recoveryMargin {
+			recovered = false
+		}
+	}
+	sort.Strings(cold)
+	raised := !f.active && len(cold) > 0
+	// Sensors going silent while it is cold don't clear the alarm
+	cleared := f.active && recovered && fresh > 0
+	if raised {
+		f.active = true
+	}
+	if cleared {
+		f.active = false
+		f.forcedUntil = time.Time{}
+	}
+	// Refresh the hold halfway through, so the setpoint never lapses while it is cold
+	force := f.active && now.After(f.forcedUntil.Add(-f.hold/2))
+	rooms := make([]room, 0, len(f.rooms))
+	for _, r := range f.rooms {
+		rooms = append(rooms, r)
+	}
+	f.mu.Unlock()
+
+	var forceErr error
+	if force {
+		until := now.Add(f.hold)
+		forceErr = f.force(ctx, rooms, until)
+		if forceErr == nil {
+			f.mu.Lock()
+			f.forcedUntil = until
+			f.mu.Unlock()
+		}
+	}
+
+	switch {
+	case raised:
+		f.logger.Error("temperature below frost floor, forcing heating",
+			zap.Strings("rooms", cold),
+			zap.Float64("floor_celsius", f.floor),
+			zap.Float64("setpoint_celsius", f.setpoint),
+			zap.Error(forceErr),
+		)
+		text := fmt.Sprintf("Below the %.1f°C floor: %s.\nNetatmo rooms were set to %.1f°C.", f.floor, strings.Join(cold, ", "), f.setpoint)
+		if forceErr != nil {
+			text = fmt.Sprintf("Below the %.1f°C floor: %s.\nForcing Netatmo rooms to %.1f°C failed, retrying every minute: %v", f.floor, strings.Join(cold, ", "), f.setpoint, forceErr)
+		}
+		f.eventLog.Record(events.TypeFrostAlarm, "frost", "temperature below frost floor", map[string]string{"rooms": strings.Join(cold, ", ")})
+		f.notify(ctx, notify.Message{Subject: "Frost protection: heating forced", Text: text})
+	case cleared:
+		f.logger.Info("temperatures back above frost floor, forced setpoints expire on their own")
+		f.eventLog.Record(events.TypeFrostAlarmResolved, "frost", "temperatures back above frost floor", nil)
+		f.notify(ctx, notify.Message{
+			Subject: "Frost protection: resolved",
+			Text:    fmt.Sprintf("All rooms are back above %.1f°C. Forced setpoints return to the schedule when their hold ends.", f.floor+recoveryMargin),
+		})
+	case forceErr != nil:
+		f.logger.Error("failed to force frost protection setpoint", zap.Error(forceErr))
+	}
+}
+
+// force holds every Netatmo room at the safe setpoint until the given time, continuing past failures
+func (f *Failsafe) force(ctx context.Context, rooms []room, until time.Time) error {
+	if len(rooms) == 0 {
+		return fmt.Errorf("no Netatmo rooms known yet")
+	}
+	var failed []string
+	for _, r := range rooms {
+		if err := f.thermostat.SetRoomSetpoint(ctx, r.homeID, r.roomID, f.setpoint, until); err != nil {
+			f.logger.Warn("failed to force room setpoint", zap.String("room", r.name), zap.Error(err))
+			failed = append(failed, r.name)
+		}
+	}
+	if len(failed) > 0 {
+		sort.Strings(failed)
+		return fmt.Errorf("failed to force %s", strings.Join(failed, ", "))
+	}
+	return nil
+}
+
+// notify sends a message through every notifier, logging failures
+func (f *Failsafe) notify(ctx context.Context, message notify.Message) {
+	if err := notify.SendAll(ctx, f.notifiers, message); err != nil {
+		f.logger.Error("failed to send frost protection notification", zap.Error(err))
+	}
+}
+
+// Active reports whether the failsafe is forcing the heating
+func (f *Failsafe) Active() bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.active
+}
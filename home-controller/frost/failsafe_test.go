@@ -0,0 +1,145 @@
+package frost
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/mjasion/balena-home/thermostats/buffer"
+	"github.com/mjasion/balena-home/thermostats/events"
+	"github.com/mjasion/balena-home/thermostats/notify"
+	"go.uber.org/zap"
+)
+
+type forcedSetpoint struct {
+	roomID      string
+	temperature float64
+	until       time.Time
+}
+
+type fakeThermostat struct {
+	forced []forcedSetpoint
+	err    error
+}
+
+func (t *fakeThermostat) SetRoomSetpoint(ctx context.Context, homeID, roomID string, temperature float64, until time.Time) error {
+	if t.err != nil {
+		return t.err
+	}
+	t.forced = append(t.forced, forcedSetpoint{roomID: roomID, temperature: temperature, until: until})
+	return nil
+}
+
+type fakeNotifier struct {
+	messages []notify.Message
+}
+
+func (n *fakeNotifier) Name() string {
+	return "fake"
+}
+
+func (n *fakeNotifier) Send(ctx context.Context, message notify.Message) error {
+	n.messages = append(n.messages, message)
+	return nil
+}
+
+func netatmoReading(at time.Time, roomID, roomName string, measured float64) *buffer.Reading {
+	return &buffer.Reading{
+		Type: buffer.ReadingTypeNetatmo,
+		Thermostat: &buffer.ThermostatReading{
+			Timestamp:           at,
+			HomeID:              "home",
+			RoomID:              roomID,
+			RoomName:            roomName,
+			MeasuredTemperature: measured,
+			SetpointTemperature: 16,
+			Reachable:           true,
+		},
+	}
+}
+
+func bleReading(at time.Time, sensorName string, temperature float64) *buffer.Reading {
+	return &buffer.Reading{
+		Type: buffer.ReadingTypeBLE,
+		BLE:  &buffer.SensorReading{Timestamp: at, SensorName: sensorName, TemperatureCelsius: temperature},
+	}
+}
+
+func TestFailsafe_ForcesSetpointAndNotifies(t *testing.T) {
+	now := time.Date(2026, 1, 20, 3, 0, 0, 0, time.UTC)
+	thermostat := &fakeThermostat{}
+	notifier := &fakeNotifier{}
+	eventLog := events.NewLog(10, zap.NewNop())
+	failsafe := New(7, 12, 60, []string{"garden"}, []notify.Notifier{notifier}, zap.NewNop())
+	failsafe.SetThermostat(thermostat)
+	failsafe.SetEventLog(eventLog)
+	failsafe.now = func() time.Time { return now }
+
+	failsafe.Observe(netatmoReading(now, "1", "Living", 15))
+	failsafe.Observe(netatmoReading(now, "2", "Bathroom", 9))
+	failsafe.Observe(bleReading(now, "garden", -8)) // Excluded outdoor sensor
+	failsafe.evaluate(context.Background())
+	if failsafe.Active() || len(thermostat.forced) != 0 {
+		t.Fatal("Expected no alarm above the floor")
+	}
+
+	failsafe.Observe(netatmoReading(now, "2", "Bathroom", 6.5))
+	failsafe.evaluate(context.Background())
+	if !failsafe.Active() {
+		t.Fatal("Expected the alarm below the floor")
+	}
+	if len(thermostat.forced) != 2 || thermostat.forced[0].temperature != 12 || !thermostat.forced[0].until.Equal(now.Add(time.Hour)) {
+		t.Errorf("Expected both rooms forced to 12°C for an hour, got %+v", thermostat.forced)
+	}
+	if len(notifier.messages) != 1 || len(eventLog.List(events.Filter{Type: events.TypeFrostAlarm})) != 1 {
+		t.Errorf("Expected one notification and one frost_alarm event, got %d messages", len(notifier.messages))
+	}
+
+	// The hold is refreshed halfway through, without repeating the notification
+	now = now.Add(20 * time.Minute)
+	failsafe.evaluate(context.Background())
+	if len(thermostat.forced) != 2 {
+		t.Errorf("Expected no refresh before half of the hold, got %d calls", len(thermostat.forced))
+	}
+	now = now.Add(15 * time.Minute)
+	failsafe.evaluate(context.Background())
+	if len(thermostat.forced) != 4 || len(notifier.messages) != 1 {
+		t.Errorf("Expected a refresh without a notification, got %d calls and %d messages", len(thermostat.forced), len(notifier.messages))
+	}
+
+	// Just above the floor is not recovered yet
+	failsafe.Observe(netatmoReading(now, "2", "Bathroom", 7.5))
+	failsafe.evaluate(context.Background())
+	if !failsafe.Active() {
+		t.Error("Expected the alarm to stay raised within the recovery margin")
+	}
+	failsafe.Observe(netatmoReading(now, "2", "Bathroom", 8.5))
+	failsafe.evaluate(context.Background())
+	if failsafe.Active() || len(notifier.messages) != 2 {
+		t.Errorf("Expected the alarm to clear with a notification, got %d messages", len(notifier.messages))
+	}
+}
+
+func TestFailsafe_RetriesFailedSetpoint(t *testing.T) {
+	now := time.Date(2026, 1, 20, 3, 0, 0, 0, time.UTC)
+	thermostat := &fakeThermostat{err: errors.New("netatmo unavailable")}
+	notifier := &fakeNotifier{}
+	failsafe := New(7, 12, 60, nil, []notify.Notifier{notifier}, zap.NewNop())
+	failsafe.SetThermostat(thermostat)
+	failsafe.now = func() time.Time { return now }
+
+	failsafe.Observe(netatmoReading(now, "1", "Living", 15))
+	failsafe.Observe(bleReading(now, "cellar", 4))
+	failsafe.evaluate(context.Background())
+	if !failsafe.Active() || len(notifier.messages) != 1 {
+		t.Fatal("Expected the alarm to be raised and notified despite the failure")
+	}
+
+	thermostat.err = nil
+	now = now.Add(time.Minute)
+	failsafe.evaluate(context.Background())
+	if len(thermostat.forced) != 1 {
+		t.Errorf("Expected the setpoint to be retried, got %d calls", len(thermostat.forced))
+	}
+}
@@ -0,0 +1,152 @@
+package fusion
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mjasion/balena-home/thermostats/automation"
+	"github.com/mjasion/balena-home/thermostats/buffer"
+	"github.com/mjasion/balena-home/thermostats/events"
+	"go.uber.org/zap"
+)
+
+// Source selects the samples a room temperature is taken from
+type Source struct {
+	Name     string // Value of the source label; defaults to the metric prefix, e.g. ble or netatmo
+	Selector automation.Selector
+}
+
+// Room combines a preferred source with a fallback used while the preferred one is stale
+type Room struct {
+	Name       string
+	Primary    Source
+	Secondary  Source
+	StaleAfter time.Duration
+}
+
+// roomState tracks the sources last seen for a room
+type roomState struct {
+	Room
+	primarySeen time.Time // Timestamp of the last primary sample
+	active      string    // Source of the last emitted value
+}
+
+// Fuser produces a canonical room_temperature_celsius reading per room
+type Fuser struct {
+	buffer   *buffer.RingBuffer
+	logger   *zap.Logger
+	eventLog *events.Log
+
+	mu    sync.Mutex
+	rooms []*roomState
+}
+
+// New creates a fuser for rooms; register Observe as a buffer listener to feed it
+func New(rooms []Room, buf *buffer.RingBuffer, logger *zap.Logger) *Fuser {
+	states := make([]*roomState, len(rooms))
+	for i, room := range rooms {
+		if room.Primary.Name == "" {
+			room.Primary.Name = sourceName(room.Primary.Selector)
+		}
+		if room.Secondary.Name == "" {
+			room.Secondary.Name = sourceName(room.Secondary.Selector)
+		}
+		states[i] = &roomState{Room: room}
+	}
+
+	return &Fuser{
+		buffer: buf,
+		logger: logger,
+		rooms:  states,
+	}
+}
+
+// SetEventLog sets the event log used to record source changes
+func (f *Fuser) SetEventLog(eventLog *events.Log) {
+	f.eventLog = eventLog
+}
+
+// Observe emits a room reading for every primary sample, and for secondary
+// samples once the primary source has not been seen within StaleAfter
+// Room readings are not observed, so rooms cannot feed back into themselves
+func (f *Fuser) Observe(reading *buffer.Reading) {
+	if reading.Room != nil {
+		return
+	}
+	samples := automation.Samples(reading)
+	if len(samples) == 0 {
+		return
+	}
+
+	timestamp := automation.TimestampOf(reading)
+	if timestamp.IsZero() {
+		timestamp = time.Now()
+	}
+
+	var fused []*buffer.Reading
+	f.mu.Lock()
+	for _, room := range f.rooms {
+		for _, sample := range samples {
+			var source string
+			switch {
+			case room.Primary.Selector.Matches(sample):
+				room.primarySeen = timestamp
+				source = room.Primary.Name
+			case room.Secondary.Selector.Matches(sample) && timestamp.Sub(room.primarySeen) > room.StaleAfter:
+				source = room.Secondary.Name
+			default:
+				continue
+			}
+
+			if source != room.active {
+				f.sourceChanged(room, source)
+			}
+			fused = append(fused, &buffer.Reading{
+				Type: buffer.ReadingTypeRoom,
+				Room: &buffer.RoomReading{
+					Timestamp:          timestamp,
+					Room:               room.Name,
+					Source:             source,
+					TemperatureCelsius: sample.Value,
+				},
+			})
+		}
+	}
+	f.mu.Unlock()
+
+	// Room readings reach the automation engine's Observe inside Add; f.mu is not held across it
+	for _, r := range fused {
+		f.buffer.Add(r)
+	}
+}
+
+// sourceChanged logs a switch between sources; the first value of a room is not an event
+func (f *Fuser) sourceChanged(room *roomState, source string) {
+	previous := room.active
+	room.active = source
+	if previous == "" {
+		return
+	}
+
+	f.logger.Info("room temperature source changed",
+		zap.String("room", room.Name),
+		zap.String("from", previous),
+		zap.String("to", source),
+	)
+	f.eventLog.Record(events.TypeRoomSourceChanged, "fusion",
+		fmt.Sprintf("room %s switched from %s to %s", room.Name, previous, source),
+		map[string]string{
+			"room": room.Name,
+			"from": previous,
+			"to":   source,
+		},
+	)
+}
+
+// sourceName derives a source label from the metric prefix
+func sourceName(selector automation.Selector) string {
+	name, _, _ := strings.Cut(selector.Metric, "_")
+	return name
+}
@@ -0,0 +1,86 @@
+package fusion
+
+import (
+	"testing"
+	"time"
+
+	"github.com/mjasion/balena-home/thermostats/automation"
+	"github.com/mjasion/balena-home/thermostats/buffer"
+	"go.uber.org/zap"
+)
+
+func newTestFuser(buf *buffer.RingBuffer) *Fuser {
+	rooms := []Room{{
+		Name: "bedroom",
+		Primary: Source{Selector: automation.Selector{
+			Metric: "ble_temperature_celsius",
+			Labels: map[string]string{"sensor_name": "Bedroom"},
+		}},
+		Secondary: Source{Selector: automation.Selector{
+			Metric: "netatmo_measured_temperature_celsius",
+			Labels: map[string]string{"room_name": "Bedroom"},
+		}},
+		StaleAfter: 10 * time.Minute,
+	}}
+	fuser := New(rooms, buf, zap.NewNop())
+	buf.AddListener(fuser.Observe)
+	return fuser
+}
+
+func bleReading(ts time.Time, value float64) *buffer.Reading {
+	return &buffer.Reading{Type: buffer.ReadingTypeBLE, BLE: &buffer.SensorReading{Timestamp: ts, SensorName: "Bedroom", SensorID: 1, TemperatureCelsius: value}}
+}
+
+func netatmoReading(ts time.Time, value float64) *buffer.Reading {
+	return &buffer.Reading{Type: buffer.ReadingTypeNetatmo, Thermostat: &buffer.ThermostatReading{Timestamp: ts, RoomName: "Bedroom", MeasuredTemperature: value}}
+}
+
+func roomReadings(buf *buffer.RingBuffer) []*buffer.RoomReading {
+	var rooms []*buffer.RoomReading
+	for _, reading := range buf.GetAllAndClear() {
+		if reading.Room != nil {
+			rooms = append(rooms, reading.Room)
+		}
+	}
+	return rooms
+}
+
+func TestObserve_PrefersPrimary(t *testing.T) {
+	buf := buffer.New(100, zap.NewNop())
+	newTestFuser(buf)
+	now := time.Now()
+
+	buf.Add(bleReading(now, 21.5))
+	buf.Add(netatmoReading(now.Add(time.Minute), 20.0))
+
+	rooms := roomReadings(buf)
+	if len(rooms) != 1 {
+		t.Fatalf("Expected 1 room reading, got %d", len(rooms))
+	}
+	if rooms[0].Room != "bedroom" || rooms[0].Source != "ble" || rooms[0].TemperatureCelsius != 21.5 {
+		t.Errorf("Expected bedroom 21.5 from ble, got %+v", rooms[0])
+	}
+}
+
+func TestObserve_FallsBackWhenStale(t *testing.T) {
+	buf := buffer.New(100, zap.NewNop())
+	newTestFuser(buf)
+	now := time.Now()
+
+	// Secondary is used before the primary has ever been seen
+	buf.Add(netatmoReading(now, 19.5))
+	buf.Add(bleReading(now.Add(time.Minute), 21.0))
+	// Primary stale for longer than StaleAfter
+	buf.Add(netatmoReading(now.Add(15*time.Minute), 20.0))
+
+	rooms := roomReadings(buf)
+	if len(rooms) != 3 {
+		t.Fatalf("Expected 3 room readings, got %d", len(rooms))
+	}
+	expected := []string{"netatmo", "ble", "netatmo"}
+	for i, source := range expected {
+		if rooms[i].Source != source {
+			t.Errorf("Expected reading %d from %s, got %s", i, source, rooms[i].Source)
+		}
+	}
+}
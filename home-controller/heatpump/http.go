@@ -0,0 +1,135 @@
+package heatpump
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mjasion/balena-home/thermostats/faults"
+	"github.com/mjasion/balena-home/thermostats/httpauth"
+	"github.com/mjasion/balena-home/thermostats/httpclient"
+	"github.com/mjasion/balena-home/thermostats/telemetry"
+)
+
+// HTTPSource reads heat pump values from a local adapter's JSON status endpoint
+type HTTPSource struct {
+	client *http.Client
+	url    string
+	auth   httpauth.Config
+}
+
+// NewHTTPSource creates a new HTTP JSON source
+func NewHTTPSource(url string, timeout time.Duration, auth httpauth.Config, tlsConfig httpauth.TLSConfig) (*HTTPSource, error) {
+	client, err := httpclient.New(httpclient.Config{Timeout: timeout, TLS: tlsConfig})
+	if err != nil {
+		return nil, err
+	}
+	client.Transport = auth.Transport(client.Transport)
+
+	return &HTTPSource{
+		client: client,
+		url:    url,
+		auth:   auth,
+	}, nil
+}
+
+// SetRecorder records status requests as the "heatpump" dependency
+func (s *HTTPSource) SetRecorder(recorder *telemetry.Recorder) {
+	recorder.Instrument(s.client, "heatpump")
+}
+
+// SetFaults hangs status requests while the scrape_timeout fault is injected; call before SetRecorder
+func (s *HTTPSource) SetFaults(injector *faults.Injector) {
+	injector.Instrument(s.client, faults.ScrapeTimeout)
+}
+
+// Read fetches the status document and extracts the configured values
+func (s *HTTPSource) Read(ctx context.Context, metrics []MetricConfig) ([]Reading, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	s.auth.Apply(req)
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("HTTP request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response body: %w", err)
+	}
+
+	var doc interface{}
+	if err := json.Unmarshal(body, &doc); err != nil {
+		return nil, fmt.Errorf("failed to parse JSON: %w", err)
+	}
+
+	now := time.Now()
+	readings := make([]Reading, 0, len(metrics))
+	for _, metric := range metrics {
+		value, err := lookupPath(doc, metric.Path)
+		if err != nil {
+			return nil, fmt.Errorf("metric %s: %w", metric.Name, err)
+		}
+		readings = append(readings, Reading{
+			Name:      metric.Name,
+			Value:     value * metric.Scale,
+			Timestamp: now,
+		})
+	}
+
+	return readings, nil
+}
+
+// lookupPath resolves a dot-separated path (object keys or array indexes) to a numeric value
+func lookupPath(doc interface{}, path string) (float64, error) {
+	current := doc
+	for _, key := range strings.Split(path, ".") {
+		switch node := current.(type) {
+		case map[string]interface{}:
+			next, ok := node[key]
+			if !ok {
+				return 0, fmt.Errorf("key %q not found in path %s", key, path)
+			}
+			current = next
+		case []interface{}:
+			index, err := strconv.Atoi(key)
+			if err != nil || index < 0 || index >= len(node) {
+				return 0, fmt.Errorf("invalid array index %q in path %s", key, path)
+			}
+			current = node[index]
+		default:
+			return 0, fmt.Errorf("cannot descend into %q in path %s", key, path)
+		}
+	}
+
+	switch value := current.(type) {
+	case float64:
+		return value, nil
+	case bool:
+		if value {
+			return 1, nil
+		}
+		return 0, nil
+	case string:
+		parsed, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+		if err != nil {
+			return 0, fmt.Errorf("value at %s is not numeric: %q", path, value)
+		}
+		return parsed, nil
+	default:
+		return 0, fmt.Errorf("value at %s is not numeric", path)
+	}
+}
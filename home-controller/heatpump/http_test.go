@@ -0,0 +1,107 @@
+package heatpump
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/mjasion/balena-home/thermostats/httpauth"
+)
+
+const sampleStatusJSON = `{
+    "heating": {
+        "flow": {"temperature": 35.5},
+        "return": {"temperature": "30.1"}
+    },
+    "compressor": [{"frequency": 42}],
+    "defrost": false
+}`
+
+func TestHTTPSource_Read(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-API-Key") != "key" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(sampleStatusJSON))
+	}))
+	defer server.Close()
+
+	source, err := NewHTTPSource(server.URL, 5*time.Second,
+		httpauth.Config{Type: httpauth.TypeHeader, HeaderName: "X-API-Key", HeaderValue: "key"},
+		httpauth.TLSConfig{},
+	)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	metrics := []MetricConfig{
+		{Name: "flow_temperature_celsius", Path: "heating.flow.temperature", Scale: 1},
+		{Name: "return_temperature_celsius", Path: "heating.return.temperature", Scale: 1},
+		{Name: "compressor_frequency_hertz", Path: "compressor.0.frequency", Scale: 1},
+		{Name: "defrost_active", Path: "defrost", Scale: 1},
+	}
+
+	readings, err := source.Read(context.Background(), metrics)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	expected := map[string]float64{
+		"flow_temperature_celsius":   35.5,
+		"return_temperature_celsius": 30.1,
+		"compressor_frequency_hertz": 42,
+		"defrost_active":             0,
+	}
+
+	if len(readings) != len(expected) {
+		t.Fatalf("Expected %d readings, got %d", len(expected), len(readings))
+	}
+
+	for _, reading := range readings {
+		if reading.Value != expected[reading.Name] {
+			t.Errorf("For metric %s, expected %f, got %f", reading.Name, expected[reading.Name], reading.Value)
+		}
+	}
+}
+
+func TestHTTPSource_MissingPath(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(sampleStatusJSON))
+	}))
+	defer server.Close()
+
+	source, err := NewHTTPSource(server.URL, 5*time.Second, httpauth.Config{}, httpauth.TLSConfig{})
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	_, err = source.Read(context.Background(), []MetricConfig{
+		{Name: "outdoor_temperature_celsius", Path: "outdoor.temperature", Scale: 1},
+	})
+	if err == nil {
+		t.Fatal("Expected error for missing path, got nil")
+	}
+}
+
+func TestHTTPSource_HTTPError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	source, err := NewHTTPSource(server.URL, 5*time.Second, httpauth.Config{}, httpauth.TLSConfig{})
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	_, err = source.Read(context.Background(), []MetricConfig{
+		{Name: "flow_temperature_celsius", Path: "heating.flow.temperature", Scale: 1},
+	})
+	if err == nil {
+		t.Fatal("Expected error for HTTP 500, got nil")
+	}
+}
@@ -0,0 +1,143 @@
+package heatpump
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"time"
+)
+
+const (
+	modbusReadHoldingRegisters = 0x03
+	modbusExceptionFlag        = 0x80
+)
+
+// ModbusSource reads heat pump values from holding registers over Modbus TCP
+type ModbusSource struct {
+	address       string
+	unitID        byte
+	timeout       time.Duration
+	mu            sync.Mutex
+	transactionID uint16
+}
+
+// NewModbusSource creates a new Modbus TCP source
+func NewModbusSource(address string, unitID byte, timeout time.Duration) *ModbusSource {
+	return &ModbusSource{
+		address: address,
+		unitID:  unitID,
+		timeout: timeout,
+	}
+}
+
+// Read connects to the adapter and reads every configured register
+func (s *ModbusSource) Read(ctx context.Context, metrics []MetricConfig) ([]Reading, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	dialer := net.Dialer{Timeout: s.timeout}
+	conn, err := dialer.DialContext(ctx, "tcp", s.address)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to %s: %w", s.address, err)
+	}
+	defer conn.Close()
+
+	if err := conn.SetDeadline(time.Now().Add(s.timeout)); err != nil {
+		return nil, fmt.Errorf("failed to set deadline: %w", err)
+	}
+
+	now := time.Now()
+	readings := make([]Reading, 0, len(metrics))
+	for _, metric := range metrics {
+		words := metric.Words
+		if words == 0 {
+			words = 1
+		}
+
+		registers, err := s.readHoldingRegisters(conn, metric.Register, uint16(words))
+		if err != nil {
+			return nil, fmt.Errorf("metric %s: %w", metric.Name, err)
+		}
+
+		readings = append(readings, Reading{
+			Name:      metric.Name,
+			Value:     decodeRegisters(registers, metric.Signed) * metric.Scale,
+			Timestamp: now,
+		})
+	}
+
+	return readings, nil
+}
+
+// readHoldingRegisters performs a single "read holding registers" request
+func (s *ModbusSource) readHoldingRegisters(conn net.Conn, address, quantity uint16) ([]uint16, error) {
+	s.transactionID++
+
+	// MBAP header (7 bytes) followed by the PDU (5 bytes)
+	request := make([]byte, 12)
+	binary.BigEndian.PutUint16(request[0:2], s.transactionID)
+	binary.BigEndian.PutUint16(request[2:4], 0) // Protocol identifier
+	binary.BigEndian.PutUint16(request[4:6], 6) // Remaining length
+	request[6] = s.unitID
+	request[7] = modbusReadHoldingRegisters
+	binary.BigEndian.PutUint16(request[8:10], address)
+	binary.BigEndian.PutUint16(request[10:12], quantity)
+
+	if _, err := conn.Write(request); err != nil {
+		return nil, fmt.Errorf("failed to send request: %w", err)
+	}
+
+	header := make([]byte, 7)
+	if _, err := io.ReadFull(conn, header); err != nil {
+		return nil, fmt.Errorf("failed to read response header: %w", err)
+	}
+	if binary.BigEndian.Uint16(header[0:2]) != s.transactionID {
+		return nil, fmt.Errorf("transaction ID mismatch")
+	}
+
+	length := binary.BigEndian.Uint16(header[4:6])
+	if length < 2 {
+		return nil, fmt.Errorf("invalid response length: %d", length)
+	}
+
+	pdu := make([]byte, length-1)
+	if _, err := io.ReadFull(conn, pdu); err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+
+	if pdu[0]&modbusExceptionFlag != 0 {
+		if len(pdu) < 2 {
+			return nil, fmt.Errorf("modbus exception")
+		}
+		return nil, fmt.Errorf("modbus exception code %d", pdu[1])
+	}
+	if len(pdu) < 2 || int(pdu[1]) != int(quantity)*2 || len(pdu) < 2+int(pdu[1]) {
+		return nil, fmt.Errorf("unexpected response size")
+	}
+
+	registers := make([]uint16, quantity)
+	for i := range registers {
+		registers[i] = binary.BigEndian.Uint16(pdu[2+i*2 : 4+i*2])
+	}
+
+	return registers, nil
+}
+
+// decodeRegisters combines one or two big-endian registers into a value
+func decodeRegisters(registers []uint16, signed bool) float64 {
+	if len(registers) == 1 {
+		if signed {
+			return float64(int16(registers[0]))
+		}
+		return float64(registers[0])
+	}
+
+	raw := uint32(registers[0])<<16 | uint32(registers[1])
+	if signed {
+		return float64(int32(raw))
+	}
+	return float64(raw)
+}
@@ -0,0 +1,129 @@
+package heatpump
+
+import (
+	"context"
+	"encoding/binary"
+	"io"
+	"net"
+	"testing"
+	"time"
+)
+
+// startModbusServer starts a minimal Modbus TCP server backed by a register map
+func startModbusServer(t *testing.T, registers map[uint16]uint16) string {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func(conn net.Conn) {
+				defer conn.Close()
+				request := make([]byte, 12)
+				for {
+					if _, err := io.ReadFull(conn, request); err != nil {
+						return
+					}
+					address := binary.BigEndian.Uint16(request[8:10])
+					quantity := binary.BigEndian.Uint16(request[10:12])
+
+					var response []byte
+					if _, ok := registers[address]; !ok {
+						// Illegal data address exception
+						response = make([]byte, 9)
+						binary.BigEndian.PutUint16(response[4:6], 3)
+						response[7] = request[7] | modbusExceptionFlag
+						response[8] = 0x02
+					} else {
+						response = make([]byte, 9+quantity*2)
+						binary.BigEndian.PutUint16(response[4:6], 3+quantity*2)
+						response[7] = request[7]
+						response[8] = byte(quantity * 2)
+						for i := uint16(0); i < quantity; i++ {
+							binary.BigEndian.PutUint16(response[9+i*2:], registers[address+i])
+						}
+					}
+					copy(response[0:2], request[0:2])
+					response[6] = request[6]
+
+					if _, err := conn.Write(response); err != nil {
+						return
+					}
+				}
+			}(conn)
+		}
+	}()
+
+	return listener.Addr().String()
+}
+
+func TestModbusSource_Read(t *testing.T) {
+	address := startModbusServer(t, map[uint16]uint16{
+		100: 355,    // Flow temperature 35.5°C
+		101: 0xFFEC, // Outdoor temperature -2.0°C
+		200: 0x0001, // Consumed power high word
+		201: 0x86A0, // Consumed power low word (100000 W * 0.01)
+	})
+
+	source := NewModbusSource(address, 1, 5*time.Second)
+	metrics := []MetricConfig{
+		{Name: "flow_temperature_celsius", Register: 100, Words: 1, Scale: 0.1},
+		{Name: "outdoor_temperature_celsius", Register: 101, Words: 1, Signed: true, Scale: 0.1},
+		{Name: "consumed_power_watts", Register: 200, Words: 2, Scale: 0.01},
+	}
+
+	readings, err := source.Read(context.Background(), metrics)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	expected := map[string]float64{
+		"flow_temperature_celsius":    35.5,
+		"outdoor_temperature_celsius": -2.0,
+		"consumed_power_watts":        1000,
+	}
+
+	for _, reading := range readings {
+		want := expected[reading.Name]
+		if diff := reading.Value - want; diff > 1e-9 || diff < -1e-9 {
+			t.Errorf("For metric %s, expected %f, got %f", reading.Name, want, reading.Value)
+		}
+	}
+}
+
+func TestModbusSource_Exception(t *testing.T) {
+	address := startModbusServer(t, map[uint16]uint16{100: 355})
+
+	source := NewModbusSource(address, 1, 5*time.Second)
+	_, err := source.Read(context.Background(), []MetricConfig{
+		{Name: "missing", Register: 999, Words: 1, Scale: 1},
+	})
+	if err == nil {
+		t.Fatal("Expected error for modbus exception, got nil")
+	}
+}
+
+func TestModbusSource_ConnectionRefused(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	address := listener.Addr().String()
+	listener.Close()
+
+	source := NewModbusSource(address, 1, time.Second)
+	_, err = source.Read(context.Background(), []MetricConfig{
+		{Name: "flow_temperature_celsius", Register: 100, Words: 1, Scale: 1},
+	})
+	if err == nil {
+		t.Fatal("Expected error when adapter is unreachable, got nil")
+	}
+}
@@ -0,0 +1,120 @@
+package heatpump
+
+import (
+	"context"
+	"time"
+
+	"github.com/mjasion/balena-home/thermostats/buffer"
+	"github.com/mjasion/balena-home/thermostats/clock"
+	"github.com/mjasion/balena-home/thermostats/schedule"
+	"go.uber.org/zap"
+)
+
+// Source reads the configured metrics from a heat pump adapter
+type Source interface {
+	Read(ctx context.Context, metrics []MetricConfig) ([]Reading, error)
+}
+
+// Poller periodically reads heat pump values and adds them to the buffer
+type Poller struct {
+	source       Source
+	metrics      []MetricConfig
+	buffer       *buffer.RingBuffer
+	logger       *zap.Logger
+	pollInterval time.Duration
+	skipper      schedule.Skipper
+	clock        clock.Clock
+}
+
+// NewPoller creates a new heat pump poller
+func NewPoller(source Source, metrics []MetricConfig, buf *buffer.RingBuffer, pollIntervalSeconds int, logger *zap.Logger) *Poller {
+	return &Poller{
+		source:       source,
+		metrics:      metrics,
+		buffer:       buf,
+		logger:       logger,
+		pollInterval: time.Duration(pollIntervalSeconds) * time.Second,
+		clock:        clock.Real,
+	}
+}
+
+// SetCadence polls only every cadence.Multiplier() intervals, e.g. less often while nobody is home
+func (p *Poller) SetCadence(cadence schedule.Cadence) {
+	p.skipper = schedule.NewSkipper(cadence)
+}
+
+// SetClock sets the clock driving the poll ticker, e.g. a fake one in tests
+func (p *Poller) SetClock(c clock.Clock) {
+	p.clock = c
+}
+
+// Start starts the polling loop
+func (p *Poller) Start(ctx context.Context) {
+	p.logger.Info("starting heat pump poller",
+		zap.Duration("poll_interval", p.pollInterval),
+		zap.Int("metric_count", len(p.metrics)),
+	)
+
+	// Create ticker for periodic polling
+	ticker := p.clock.NewTicker(p.pollInterval)
+	defer ticker.Stop()
+
+	// Poll immediately on start
+	if err := p.pollAndBuffer(ctx); err != nil {
+		p.logger.Info("buffer closed, stopping heat pump poller")
+		return
+	}
+
+	// Then poll at regular intervals
+	for {
+		select {
+		case <-ctx.Done():
+			p.logger.Info("stopping heat pump poller")
+			return
+		case <-ticker.C():
+			if p.skipper.Skip() {
+				continue
+			}
+			if err := p.pollAndBuffer(ctx); err != nil {
+				p.logger.Info("buffer closed, stopping heat pump poller")
+				return
+			}
+		}
+	}
+}
+
+// pollAndBuffer reads heat pump values and adds them to the buffer
+// Returns buffer.ErrClosed once the buffer no longer accepts readings; read errors are only logged
+func (p *Poller) pollAndBuffer(ctx context.Context) error {
+	readings, err := p.source.Read(ctx, p.metrics)
+	if err != nil {
+		p.logger.Error("failed to read heat pump data",
+			zap.Error(err),
+		)
+		return nil
+	}
+
+	for _, reading := range readings {
+		err := p.buffer.Add(&buffer.Reading{
+			Type: buffer.ReadingTypeHeatPump,
+			HeatPump: &buffer.HeatPumpReading{
+				Timestamp: reading.Timestamp,
+				Name:      reading.Name,
+				Value:     reading.Value,
+			},
+		})
+		if err != nil {
+			return err
+		}
+
+		p.logger.Debug("added heat pump reading to buffer",
+			zap.String("metric", reading.Name),
+			zap.Float64("value", reading.Value),
+		)
+	}
+
+	p.logger.Info("read and buffered heat pump data",
+		zap.Int("reading_count", len(readings)),
+	)
+	return nil
+}
@@ -0,0 +1,26 @@
+package heatpump
+
+import "time"
+
+// Supported source protocols
+const (
+	ProtocolHTTP   = "http"
+	ProtocolModbus = "modbus"
+)
+
+// MetricConfig maps a single heat pump value to a metric
+type MetricConfig struct {
+	Name     string  // Metric name suffix, e.g. flow_temperature_celsius
+	Path     string  // Dot-separated JSON path (http), e.g. heating.flow.temperature
+	Register uint16  // Holding register address (modbus)
+	Words    int     // Number of 16-bit registers to read: 1 or 2 (modbus)
+	Signed   bool    // Interpret register value as two's complement (modbus)
+	Scale    float64 // Multiplier applied to the raw value
+}
+
+// Reading represents a single heat pump measurement
+type Reading struct {
+	Name      string
+	Value     float64
+	Timestamp time.Time
+}
@@ -0,0 +1,44 @@
+package history
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/mjasion/balena-home/thermostats/events"
+)
+
+// eventBackend is a backend that also keeps events
+type eventBackend interface {
+	appendEvents(batch []events.Event) error
+	events(ctx context.Context, from, to time.Time, eventType string) ([]events.Event, error)
+}
+
+// KeepsEvents reports whether the backend can keep events, so the store can be an event sink
+func (s *Store) KeepsEvents() bool {
+	_, ok := s.backend.(eventBackend)
+	return ok
+}
+
+// Name returns the sink name used in logs
+func (s *Store) Name() string {
+	return "history"
+}
+
+// Send stores delivered events, making the store an events.Sink
+func (s *Store) Send(ctx context.Context, batch []events.Event) error {
+	b, ok := s.backend.(eventBackend)
+	if !ok {
+		return fmt.Errorf("history backend does not keep events")
+	}
+	return b.appendEvents(batch)
+}
+
+// Events returns stored events between from and to, oldest first, optionally of one type
+func (s *Store) Events(ctx context.Context, from, to time.Time, eventType string) ([]events.Event, error) {
+	b, ok := s.backend.(eventBackend)
+	if !ok {
+		return nil, fmt.Errorf("history backend does not keep events")
+	}
+	return b.events(ctx, from, to, eventType)
+}
@@ -0,0 +1,108 @@
+package history
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/mjasion/balena-home/thermostats/buffer"
+	"github.com/prometheus/prometheus/prompb"
+)
+
+// exportBatchSize bounds the readings converted to series at once
+const exportBatchSize = 10000
+
+// SeriesBuilder converts readings to the series pushed for them
+type SeriesBuilder func(readings []*buffer.Reading) ([]prompb.TimeSeries, error)
+
+// SetSeriesBuilder sets how readings are converted for export, normally the pusher's
+func (s *Store) SetSeriesBuilder(build SeriesBuilder) {
+	s.build = build
+}
+
+// ExportCSV writes the samples between from (inclusive) and to (exclusive) as CSV with the
+// columns timestamp, metric, one column per label name and value, one sample per row.
+// The history is read twice: once for the label columns, once for the rows.
+func (s *Store) ExportCSV(w io.Writer, from, to time.Time) error {
+	if s.build == nil {
+		return fmt.Errorf("no series builder set")
+	}
+	fromMs, toMs := from.UnixMilli(), to.UnixMilli()
+
+	labelSet := make(map[string]bool)
+	err := s.scanSeries(from, to, func(series prompb.TimeSeries) error {
+		for _, label := range series.Labels {
+			if label.Name != "__name__" {
+				labelSet[label.Name] = true
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	labelNames := make([]string, 0, len(labelSet))
+	for name := range labelSet {
+		labelNames = append(labelNames, name)
+	}
+	sort.Strings(labelNames)
+	column := make(map[string]int, len(labelNames))
+	for i, name := range labelNames {
+		column[name] = 2 + i
+	}
+
+	writer := csv.NewWriter(w)
+	header := append(append([]string{"timestamp", "metric"}, labelNames...), "value")
+	if err := writer.Write(header); err != nil {
+		return err
+	}
+
+	row := make([]string, len(header))
+	err = s.scanSeries(from, to, func(series prompb.TimeSeries) error {
+		for i := range row {
+			row[i] = ""
+		}
+		for _, label := range series.Labels {
+			if label.Name == "__name__" {
+				row[1] = label.Value
+			} else {
+				row[column[label.Name]] = label.Value
+			}
+		}
+		for _, sample := range series.Samples {
+			if sample.Timestamp < fromMs || sample.Timestamp >= toMs {
+				continue
+			}
+			row[0] = time.UnixMilli(sample.Timestamp).UTC().Format(time.RFC3339Nano)
+			row[len(row)-1] = strconv.FormatFloat(sample.Value, 'g', -1, 64)
+			if err := writer.Write(row); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+// scanSeries converts the readings of the files covering from and to to series, batch by batch
+func (s *Store) scanSeries(from, to time.Time, fn func(series prompb.TimeSeries) error) error {
+	return s.Scan(from, to, exportBatchSize, func(readings []*buffer.Reading) error {
+		series, err := s.build(readings)
+		if err != nil {
+			return fmt.Errorf("failed to build series: %w", err)
+		}
+		for _, ts := range series {
+			if err := fn(ts); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
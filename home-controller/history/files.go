@@ -0,0 +1,182 @@
+package history
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mjasion/balena-home/thermostats/buffer"
+	"github.com/mjasion/balena-home/thermostats/readingpb"
+)
+
+// fileSuffix names the daily files holding length-prefixed readingpb records
+const fileSuffix = ".readings"
+
+// maxRecordSize bounds a record length read back, so a corrupted prefix can't exhaust memory
+const maxRecordSize = 1 << 20
+
+// dateLayout names the daily files, in UTC
+const dateLayout = "2006-01-02"
+
+// fileBackend appends records to one file per UTC day of their timestamps
+type fileBackend struct {
+	dir string
+	mu  sync.Mutex // Serialises appends, pruning and reads of the files
+}
+
+func (b *fileBackend) append(records []record) error {
+	var days []string
+	data := make(map[string][]byte)
+	for _, r := range records {
+		day := r.timestamp.UTC().Format(dateLayout)
+		if _, ok := data[day]; !ok {
+			days = append(days, day)
+		}
+		data[day] = binary.AppendUvarint(data[day], uint64(len(r.data)))
+		data[day] = append(data[day], r.data...)
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if err := os.MkdirAll(b.dir, 0755); err != nil {
+		return fmt.Errorf("failed to create history directory: %w", err)
+	}
+	for _, day := range days {
+		file, err := os.OpenFile(b.path(day), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+		if err != nil {
+			return fmt.Errorf("failed to open history file: %w", err)
+		}
+		if _, err := file.Write(data[day]); err != nil {
+			file.Close()
+			return fmt.Errorf("failed to write history file: %w", err)
+		}
+		if err := file.Close(); err != nil {
+			return fmt.Errorf("failed to write history file: %w", err)
+		}
+	}
+	return nil
+}
+
+// path returns the file of a day
+func (b *fileBackend) path(day string) string {
+	return filepath.Join(b.dir, day+fileSuffix)
+}
+
+func (b *fileBackend) prune(before time.Time) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	days, err := b.days()
+	if err != nil {
+		return 0, err
+	}
+	oldest := before.UTC().Format(dateLayout)
+	removed := 0
+	for _, day := range days {
+		if day < oldest {
+			if err := os.Remove(b.path(day)); err != nil {
+				return removed, fmt.Errorf("failed to remove history file: %w", err)
+			}
+			removed++
+		}
+	}
+	return removed, nil
+}
+
+// days returns the dates of the files present, oldest first; the caller must hold mu
+func (b *fileBackend) days() ([]string, error) {
+	entries, err := os.ReadDir(b.dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to list history: %w", err)
+	}
+	var days []string
+	for _, entry := range entries {
+		day, ok := strings.CutSuffix(entry.Name(), fileSuffix)
+		if !ok {
+			continue
+		}
+		if _, err := time.Parse(dateLayout, day); err != nil {
+			continue
+		}
+		days = append(days, day)
+	}
+	sort.Strings(days)
+	return days, nil
+}
+
+// scan reads the whole files of the days in the range
+func (b *fileBackend) scan(from, to time.Time, batchSize int, fn func(readings []*buffer.Reading) error) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	days, err := b.days()
+	if err != nil {
+		return err
+	}
+	first := from.UTC().Format(dateLayout)
+	last := to.UTC().Format(dateLayout)
+	for _, day := range days {
+		if day < first || day > last {
+			continue
+		}
+		if err := b.scanFile(b.path(day), batchSize, fn); err != nil {
+			return fmt.Errorf("history %s: %w", day, err)
+		}
+	}
+	return nil
+}
+
+// scanFile reads the records of one file; a truncated last record, left by a crash
+// during a write, ends the file
+func (b *fileBackend) scanFile(path string, batchSize int, fn func(readings []*buffer.Reading) error) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	reader := bufio.NewReader(file)
+	batch := make([]*buffer.Reading, 0, batchSize)
+	for {
+		size, err := binary.ReadUvarint(reader)
+		if err != nil || size > maxRecordSize {
+			break
+		}
+		data := make([]byte, size)
+		if _, err := io.ReadFull(reader, data); err != nil {
+			break
+		}
+		reading, err := readingpb.Unmarshal(data)
+		if err != nil {
+			// Written by a newer version; skip it rather than failing the export
+			continue
+		}
+		batch = append(batch, reading)
+		if len(batch) == batchSize {
+			if err := fn(batch); err != nil {
+				return err
+			}
+			batch = make([]*buffer.Reading, 0, batchSize)
+		}
+	}
+	if len(batch) > 0 {
+		return fn(batch)
+	}
+	return nil
+}
+
+func (b *fileBackend) close() error {
+	return nil
+}
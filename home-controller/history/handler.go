@@ -0,0 +1,152 @@
+package history
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/mjasion/balena-home/thermostats/admin"
+	"go.uber.org/zap"
+)
+
+// defaultRange is queried when from is not given
+const defaultRange = 24 * time.Hour
+
+// RegisterHandlers registers the history export and query endpoints on the admin server
+func (s *Store) RegisterHandlers(server *admin.Server) {
+	server.HandleFunc("GET /api/export", s.handleExport)
+	server.HandleFunc("GET /api/history/stats", s.handleStats)
+	if s.KeepsEvents() {
+		server.HandleFunc("GET /api/history/events", s.handleEvents)
+	}
+}
+
+// parseTime accepts RFC 3339 timestamps and YYYY-MM-DD dates, taken as UTC midnight
+func parseTime(value string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	return time.Parse(dateLayout, value)
+}
+
+// parseRange reads the from and to parameters, writing an error response when they are invalid;
+// to defaults to now and from to a day before to
+func (s *Store) parseRange(w http.ResponseWriter, r *http.Request) (time.Time, time.Time, bool) {
+	query := r.URL.Query()
+	to := s.now()
+	if value := query.Get("to"); value != "" {
+		t, err := parseTime(value)
+		if err != nil {
+			admin.WriteError(w, http.StatusBadRequest, "to must be an RFC 3339 time or YYYY-MM-DD date")
+			return time.Time{}, time.Time{}, false
+		}
+		to = t
+	}
+	from := to.Add(-defaultRange)
+	if value := query.Get("from"); value != "" {
+		t, err := parseTime(value)
+		if err != nil {
+			admin.WriteError(w, http.StatusBadRequest, "from must be an RFC 3339 time or YYYY-MM-DD date")
+			return time.Time{}, time.Time{}, false
+		}
+		from = t
+	}
+	if !from.Before(to) {
+		admin.WriteError(w, http.StatusBadRequest, "from must be before to")
+		return time.Time{}, time.Time{}, false
+	}
+	return from, to, true
+}
+
+// handleExport handles GET /api/export?from=<time>&to=<time>&format=csv, streaming the
+// kept samples between from and to
+func (s *Store) handleExport(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	switch query.Get("format") {
+	case "", "csv":
+	case "parquet":
+		admin.WriteError(w, http.StatusBadRequest, "parquet export is not supported, use format=csv")
+		return
+	default:
+		admin.WriteError(w, http.StatusBadRequest, "format must be csv")
+		return
+	}
+
+	from, to, ok := s.parseRange(w, r)
+	if !ok {
+		return
+	}
+
+	// Include readings still waiting for the periodic write
+	if err := s.Flush(); err != nil {
+		s.logger.Warn("failed to write history before export", zap.Error(err))
+	}
+
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="readings-%s-%s.csv"`,
+		from.UTC().Format("20060102T150405Z"), to.UTC().Format("20060102T150405Z")))
+	out := &countingWriter{w: w}
+	if err := s.ExportCSV(out, from, to); err != nil {
+		s.logger.Warn("history export failed", zap.Error(err))
+		// Once rows are sent the status is too, so a failure midway can only be logged
+		if out.n == 0 {
+			admin.WriteError(w, http.StatusInternalServerError, err.Error())
+		}
+	}
+}
+
+// handleStats handles GET /api/history/stats?metric=<name>&from=<time>&to=<time>
+func (s *Store) handleStats(w http.ResponseWriter, r *http.Request) {
+	from, to, ok := s.parseRange(w, r)
+	if !ok {
+		return
+	}
+	if err := s.Flush(); err != nil {
+		s.logger.Warn("failed to write history before stats", zap.Error(err))
+	}
+
+	stats, err := s.Stats(from, to, r.URL.Query().Get("metric"))
+	if err != nil {
+		admin.WriteError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	admin.WriteJSON(w, http.StatusOK, admin.Response{
+		Success: true,
+		Message: fmt.Sprintf("%d series", len(stats)),
+		Data:    stats,
+	})
+}
+
+// handleEvents handles GET /api/history/events?type=<type>&from=<time>&to=<time>, listing
+// events kept past the in-memory event log and restarts
+func (s *Store) handleEvents(w http.ResponseWriter, r *http.Request) {
+	from, to, ok := s.parseRange(w, r)
+	if !ok {
+		return
+	}
+
+	result, err := s.Events(r.Context(), from, to, r.URL.Query().Get("type"))
+	if err != nil {
+		admin.WriteError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	admin.WriteJSON(w, http.StatusOK, admin.Response{
+		Success: true,
+		Message: fmt.Sprintf("%d events", len(result)),
+		Data:    result,
+	})
+}
+
+// countingWriter counts the bytes written through it
+type countingWriter struct {
+	w io.Writer
+	n int
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += n
+	return n, err
+}
@@ -0,0 +1,203 @@
+package history
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/mjasion/balena-home/thermostats/buffer"
+	"github.com/mjasion/balena-home/thermostats/events"
+	"github.com/mjasion/balena-home/thermostats/readingpb"
+	"go.uber.org/zap"
+	_ "modernc.org/sqlite" // Pure Go driver, registered as "sqlite"
+)
+
+// sqliteSchema creates the tables on first open; timestamps are Unix milliseconds
+const sqliteSchema = `
+CREATE TABLE IF NOT EXISTS readings (
+	timestamp INTEGER NOT NULL,
+	type      TEXT    NOT NULL,
+	data      BLOB    NOT NULL
+);
+CREATE INDEX IF NOT EXISTS readings_timestamp ON readings (timestamp);
+CREATE TABLE IF NOT EXISTS events (
+	id        INTEGER PRIMARY KEY,
+	timestamp INTEGER NOT NULL,
+	type      TEXT    NOT NULL,
+	source    TEXT    NOT NULL,
+	message   TEXT    NOT NULL,
+	fields    TEXT
+);
+CREATE INDEX IF NOT EXISTS events_timestamp ON events (timestamp);
+`
+
+// sqliteBackend keeps readings and events in a SQLite database
+type sqliteBackend struct {
+	db *sql.DB
+}
+
+// NewSQLite creates a store keeping readings, and events when added as an event sink, in the
+// SQLite database at path, written every intervalSeconds and kept for retentionDays
+func NewSQLite(path string, retentionDays, intervalSeconds int, logger *zap.Logger) (*Store, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create history directory: %w", err)
+	}
+
+	// Incremental auto-vacuum lets pruning return pages to the filesystem without a full
+	// VACUUM; it only takes effect on a new database. WAL keeps exports from blocking writes.
+	params := url.Values{}
+	params.Add("_pragma", "auto_vacuum(incremental)")
+	params.Add("_pragma", "journal_mode(wal)")
+	params.Add("_pragma", "busy_timeout(5000)")
+	db, err := sql.Open("sqlite", "file:"+path+"?"+params.Encode())
+	if err != nil {
+		return nil, fmt.Errorf("failed to open history database: %w", err)
+	}
+	if _, err := db.Exec(sqliteSchema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to create history tables: %w", err)
+	}
+	return newStore(&sqliteBackend{db: db}, retentionDays, intervalSeconds, logger), nil
+}
+
+func (b *sqliteBackend) append(records []record) error {
+	tx, err := b.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin history write: %w", err)
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.Prepare("INSERT INTO readings (timestamp, type, data) VALUES (?, ?, ?)")
+	if err != nil {
+		return fmt.Errorf("failed to prepare history write: %w", err)
+	}
+	defer stmt.Close()
+	for _, r := range records {
+		if _, err := stmt.Exec(r.timestamp.UnixMilli(), string(r.readingType), r.data); err != nil {
+			return fmt.Errorf("failed to write history: %w", err)
+		}
+	}
+	return tx.Commit()
+}
+
+func (b *sqliteBackend) scan(from, to time.Time, batchSize int, fn func(readings []*buffer.Reading) error) error {
+	rows, err := b.db.Query(
+		"SELECT data FROM readings WHERE timestamp >= ? AND timestamp < ? ORDER BY timestamp",
+		from.UnixMilli(), to.UnixMilli(),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to query history: %w", err)
+	}
+	defer rows.Close()
+
+	batch := make([]*buffer.Reading, 0, batchSize)
+	for rows.Next() {
+		var data []byte
+		if err := rows.Scan(&data); err != nil {
+			return fmt.Errorf("failed to read history: %w", err)
+		}
+		reading, err := readingpb.Unmarshal(data)
+		if err != nil {
+			// Written by a newer version; skip it rather than failing the export
+			continue
+		}
+		batch = append(batch, reading)
+		if len(batch) == batchSize {
+			if err := fn(batch); err != nil {
+				return err
+			}
+			batch = make([]*buffer.Reading, 0, batchSize)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to read history: %w", err)
+	}
+	if len(batch) > 0 {
+		return fn(batch)
+	}
+	return nil
+}
+
+// prune deletes expired readings and events, then compacts the freed pages
+func (b *sqliteBackend) prune(before time.Time) (int, error) {
+	removed := 0
+	for _, table := range []string{"readings", "events"} {
+		result, err := b.db.Exec("DELETE FROM "+table+" WHERE timestamp < ?", before.UnixMilli())
+		if err != nil {
+			return removed, fmt.Errorf("failed to prune %s: %w", table, err)
+		}
+		n, _ := result.RowsAffected()
+		removed += int(n)
+	}
+	if removed > 0 {
+		if _, err := b.db.Exec("PRAGMA incremental_vacuum"); err != nil {
+			return removed, fmt.Errorf("failed to compact history: %w", err)
+		}
+	}
+	return removed, nil
+}
+
+func (b *sqliteBackend) close() error {
+	return b.db.Close()
+}
+
+// appendEvents stores delivered events
+func (b *sqliteBackend) appendEvents(batch []events.Event) error {
+	tx, err := b.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin event write: %w", err)
+	}
+	defer tx.Rollback()
+
+	for _, event := range batch {
+		var fields []byte
+		if len(event.Fields) > 0 {
+			fields, _ = json.Marshal(event.Fields)
+		}
+		_, err := tx.Exec(
+			"INSERT INTO events (timestamp, type, source, message, fields) VALUES (?, ?, ?, ?, ?)",
+			event.Timestamp.UnixMilli(), event.Type, event.Source, event.Message, string(fields),
+		)
+		if err != nil {
+			return fmt.Errorf("failed to write event: %w", err)
+		}
+	}
+	return tx.Commit()
+}
+
+// events returns stored events between from and to, oldest first, optionally of one type;
+// IDs are the database's, as event log IDs restart with the process
+func (b *sqliteBackend) events(ctx context.Context, from, to time.Time, eventType string) ([]events.Event, error) {
+	query := "SELECT id, timestamp, type, source, message, fields FROM events WHERE timestamp >= ? AND timestamp < ?"
+	args := []any{from.UnixMilli(), to.UnixMilli()}
+	if eventType != "" {
+		query += " AND type = ?"
+		args = append(args, eventType)
+	}
+	rows, err := b.db.QueryContext(ctx, query+" ORDER BY timestamp, id", args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query events: %w", err)
+	}
+	defer rows.Close()
+
+	result := []events.Event{}
+	for rows.Next() {
+		var event events.Event
+		var timestamp int64
+		var fields sql.NullString
+		if err := rows.Scan(&event.ID, &timestamp, &event.Type, &event.Source, &event.Message, &fields); err != nil {
+			return nil, fmt.Errorf("failed to read event: %w", err)
+		}
+		event.Timestamp = time.UnixMilli(timestamp)
+		if fields.String != "" {
+			json.Unmarshal([]byte(fields.String), &event.Fields)
+		}
+		result = append(result, event)
+	}
+	return result, rows.Err()
+}
@@ -0,0 +1,101 @@
+package history
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/prometheus/prometheus/prompb"
+)
+
+// SeriesStats summarises the samples of one series over a range
+type SeriesStats struct {
+	Metric string            `json:"metric"`
+	Labels map[string]string `json:"labels"`
+	Count  int               `json:"count"`
+	Min    float64           `json:"min"`
+	Max    float64           `json:"max"`
+	Avg    float64           `json:"avg"`
+	First  time.Time         `json:"first"`
+	Last   time.Time         `json:"last"`
+}
+
+// Stats returns the count, min, max and average of each series between from and to,
+// optionally of one metric, computed from the kept readings
+func (s *Store) Stats(from, to time.Time, metric string) ([]SeriesStats, error) {
+	if s.build == nil {
+		return nil, fmt.Errorf("no series builder set")
+	}
+	fromMs, toMs := from.UnixMilli(), to.UnixMilli()
+
+	var keys []string
+	stats := make(map[string]*SeriesStats)
+	sums := make(map[string]float64)
+	err := s.scanSeries(from, to, func(series prompb.TimeSeries) error {
+		name, labels := splitLabels(series.Labels)
+		if metric != "" && name != metric {
+			return nil
+		}
+		key := seriesKey(series.Labels)
+		for _, sample := range series.Samples {
+			if sample.Timestamp < fromMs || sample.Timestamp >= toMs {
+				continue
+			}
+			at := time.UnixMilli(sample.Timestamp).UTC()
+			st, ok := stats[key]
+			if !ok {
+				st = &SeriesStats{Metric: name, Labels: labels, Min: sample.Value, Max: sample.Value, First: at, Last: at}
+				stats[key] = st
+				keys = append(keys, key)
+			}
+			st.Count++
+			sums[key] += sample.Value
+			st.Min = min(st.Min, sample.Value)
+			st.Max = max(st.Max, sample.Value)
+			if at.Before(st.First) {
+				st.First = at
+			}
+			if at.After(st.Last) {
+				st.Last = at
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Strings(keys)
+	result := make([]SeriesStats, 0, len(keys))
+	for _, key := range keys {
+		st := stats[key]
+		st.Avg = sums[key] / float64(st.Count)
+		result = append(result, *st)
+	}
+	return result, nil
+}
+
+// splitLabels returns the metric name and the other labels of a series
+func splitLabels(labels []prompb.Label) (string, map[string]string) {
+	var name string
+	rest := make(map[string]string, len(labels))
+	for _, label := range labels {
+		if label.Name == "__name__" {
+			name = label.Value
+		} else {
+			rest[label.Name] = label.Value
+		}
+	}
+	return name, rest
+}
+
+// seriesKey identifies a series across batches by its sorted labels
+func seriesKey(labels []prompb.Label) string {
+	parts := make([]string, len(labels))
+	for i, label := range labels {
+		parts[i] = label.Name + "=" + label.Value
+	}
+	sort.Strings(parts)
+	return strings.Join(parts, ",")
+}
@@ -0,0 +1,144 @@
+package history
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/mjasion/balena-home/thermostats/buffer"
+	"github.com/mjasion/balena-home/thermostats/readingpb"
+	"go.uber.org/zap"
+)
+
+// record is an encoded reading with the fields backends index on
+type record struct {
+	timestamp   time.Time
+	readingType buffer.ReadingType
+	data        []byte // readingpb.Reading
+}
+
+// backend persists records
+type backend interface {
+	append(records []record) error
+	// scan calls fn with batches of readings between from and to; backends may include
+	// readings outside the range, so callers filter by time
+	scan(from, to time.Time, batchSize int, fn func(readings []*buffer.Reading) error) error
+	// prune deletes readings before the cutoff and returns how many files or rows went
+	prune(before time.Time) (int, error)
+	close() error
+}
+
+// Store keeps a local copy of every reading, independent of the push buffer,
+// which is cleared on every push
+type Store struct {
+	backend       backend
+	retentionDays int
+	interval      time.Duration
+	logger        *zap.Logger
+	now           func() time.Time
+	build         SeriesBuilder
+
+	mu      sync.Mutex
+	pending []*buffer.Reading
+}
+
+// New creates a store keeping daily files in dir, written every intervalSeconds and kept
+// for retentionDays. Register Observe as a buffer listener to feed it.
+func New(dir string, retentionDays, intervalSeconds int, logger *zap.Logger) *Store {
+	return newStore(&fileBackend{dir: dir}, retentionDays, intervalSeconds, logger)
+}
+
+// newStore creates a store on a backend
+func newStore(b backend, retentionDays, intervalSeconds int, logger *zap.Logger) *Store {
+	return &Store{
+		backend:       b,
+		retentionDays: retentionDays,
+		interval:      time.Duration(intervalSeconds) * time.Second,
+		logger:        logger,
+		now:           time.Now,
+	}
+}
+
+// Observe queues a reading for the next write
+func (s *Store) Observe(reading *buffer.Reading) {
+	s.mu.Lock()
+	s.pending = append(s.pending, reading)
+	s.mu.Unlock()
+}
+
+// Start writes queued readings periodically until the context is cancelled, then writes the rest
+func (s *Store) Start(ctx context.Context) {
+	s.logger.Info("starting history", zap.Int("retention_days", s.retentionDays))
+
+	s.prune()
+
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	lastPrune := s.now().UTC().Format(dateLayout)
+	for {
+		select {
+		case <-ctx.Done():
+			if err := s.Flush(); err != nil {
+				s.logger.Warn("failed to write history", zap.Error(err))
+			}
+			if err := s.backend.close(); err != nil {
+				s.logger.Warn("failed to close history", zap.Error(err))
+			}
+			s.logger.Info("stopping history")
+			return
+		case <-ticker.C:
+			if err := s.Flush(); err != nil {
+				s.logger.Warn("failed to write history", zap.Error(err))
+			}
+			if today := s.now().UTC().Format(dateLayout); today != lastPrune {
+				lastPrune = today
+				s.prune()
+			}
+		}
+	}
+}
+
+// Flush writes queued readings; history is best effort, so a failed write drops
+// the readings rather than holding them in memory
+func (s *Store) Flush() error {
+	s.mu.Lock()
+	readings := s.pending
+	s.pending = nil
+	s.mu.Unlock()
+
+	records := make([]record, 0, len(readings))
+	for _, reading := range readings {
+		data, err := readingpb.Marshal(reading)
+		if err != nil {
+			continue
+		}
+		records = append(records, record{timestamp: reading.Timestamp(), readingType: reading.Type, data: data})
+	}
+	if skipped := len(readings) - len(records); skipped > 0 {
+		s.logger.Debug("readings not kept in history", zap.Int("count", skipped))
+	}
+	if len(records) == 0 {
+		return nil
+	}
+	return s.backend.append(records)
+}
+
+// prune deletes readings older than the retention
+func (s *Store) prune() {
+	cutoff := s.now().UTC().AddDate(0, 0, -s.retentionDays)
+	removed, err := s.backend.prune(cutoff)
+	if err != nil {
+		s.logger.Warn("failed to prune history", zap.Error(err))
+		return
+	}
+	if removed > 0 {
+		s.logger.Info("removed expired history", zap.Int("count", removed), zap.Time("before", cutoff))
+	}
+}
+
+// Scan calls fn with batches of up to batchSize readings of the range; readings
+// outside it may be included
+func (s *Store) Scan(from, to time.Time, batchSize int, fn func(readings []*buffer.Reading) error) error {
+	return s.backend.scan(from, to, batchSize, fn)
+}
@@ -0,0 +1,300 @@
+package history
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/mjasion/balena-home/thermostats/buffer"
+	"github.com/mjasion/balena-home/thermostats/events"
+	"github.com/mjasion/balena-home/thermostats/metrics"
+	"go.uber.org/zap"
+)
+
+func newTestStore(t *testing.T, now time.Time) *Store {
+	t.Helper()
+	return withTestClock(New(t.TempDir(), 7, 60, zap.NewNop()), now)
+}
+
+func newTestSQLiteStore(t *testing.T, now time.Time) *Store {
+	t.Helper()
+	store, err := NewSQLite(filepath.Join(t.TempDir(), "history.db"), 7, 60, zap.NewNop())
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	t.Cleanup(func() { store.backend.close() })
+	return withTestClock(store, now)
+}
+
+func withTestClock(store *Store, now time.Time) *Store {
+	store.now = func() time.Time { return now }
+	pusher := metrics.New("http://localhost", "", "", buffer.New(10, zap.NewNop()), 15, 100, zap.NewNop())
+	store.SetSeriesBuilder(pusher.BuildSeries)
+	return store
+}
+
+func powerReading(ts time.Time, watts float64) *buffer.Reading {
+	return &buffer.Reading{
+		Type:  buffer.ReadingTypePower,
+		Power: &buffer.PowerReading{Timestamp: ts, SensorID: 1, Value: watts},
+	}
+}
+
+// scanValues returns the power values the store holds for a range
+func scanValues(t *testing.T, store *Store, from, to time.Time) []float64 {
+	t.Helper()
+	var values []float64
+	err := store.Scan(from, to, 2, func(readings []*buffer.Reading) error {
+		for _, reading := range readings {
+			values = append(values, reading.Power.Value)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	return values
+}
+
+func TestStore_FlushAndScan(t *testing.T) {
+	now := time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC)
+	for name, store := range map[string]*Store{"files": newTestStore(t, now), "sqlite": newTestSQLiteStore(t, now)} {
+		store.Observe(powerReading(now.Add(-time.Minute), 100))
+		store.Observe(powerReading(now, 200))
+		if err := store.Flush(); err != nil {
+			t.Fatalf("%s: expected no error, got %v", name, err)
+		}
+		store.Observe(powerReading(now.Add(time.Minute), 300))
+		if err := store.Flush(); err != nil {
+			t.Fatalf("%s: expected no error, got %v", name, err)
+		}
+
+		values := scanValues(t, store, now.Add(-time.Hour), now.Add(time.Hour))
+		if len(values) != 3 || values[0] != 100 || values[2] != 300 {
+			t.Errorf("%s: expected [100 200 300], got %v", name, values)
+		}
+	}
+}
+
+func TestStore_FilesByReadingDay(t *testing.T) {
+	now := time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC)
+	store := newTestStore(t, now)
+	dir := store.backend.(*fileBackend).dir
+
+	// A reading replayed after an outage goes to the file of its own day
+	store.Observe(powerReading(now.AddDate(0, 0, -2), 100))
+	store.Observe(powerReading(now, 200))
+	if err := store.Flush(); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	for _, day := range []string{"2026-03-08", "2026-03-10"} {
+		if _, err := os.Stat(filepath.Join(dir, day+fileSuffix)); err != nil {
+			t.Errorf("Expected file for %s, got %v", day, err)
+		}
+	}
+}
+
+func TestStore_TruncatedRecord(t *testing.T) {
+	now := time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC)
+	store := newTestStore(t, now)
+	store.Observe(powerReading(now, 100))
+	store.Observe(powerReading(now, 200))
+	if err := store.Flush(); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	// A crash during a write leaves a partial last record
+	path := filepath.Join(store.backend.(*fileBackend).dir, "2026-03-10.readings")
+	info, _ := os.Stat(path)
+	if err := os.Truncate(path, info.Size()-3); err != nil {
+		t.Fatal(err)
+	}
+
+	if values := scanValues(t, store, now, now); len(values) != 1 {
+		t.Errorf("Expected 1 reading, got %v", values)
+	}
+}
+
+func TestStore_PruneFiles(t *testing.T) {
+	now := time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC)
+	store := newTestStore(t, now)
+	backend := store.backend.(*fileBackend)
+	for _, day := range []string{"2026-03-01", "2026-03-03", "2026-03-09"} {
+		if err := os.WriteFile(backend.path(day), nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	store.prune()
+	days, _ := backend.days()
+	if len(days) != 2 || days[0] != "2026-03-03" {
+		t.Errorf("Expected files from 2026-03-03 kept, got %v", days)
+	}
+}
+
+func TestStore_PruneSQLite(t *testing.T) {
+	now := time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC)
+	store := newTestSQLiteStore(t, now)
+	store.Observe(powerReading(now.AddDate(0, 0, -8), 100))
+	store.Observe(powerReading(now.AddDate(0, 0, -6), 200))
+	if err := store.Flush(); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if err := store.Send(context.Background(), []events.Event{{Timestamp: now.AddDate(0, 0, -8), Type: "old", Source: "test"}}); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	removed, err := store.backend.prune(now.AddDate(0, 0, -7))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if removed != 2 {
+		t.Errorf("Expected a reading and an event removed, got %d", removed)
+	}
+	if values := scanValues(t, store, now.AddDate(0, 0, -30), now); len(values) != 1 || values[0] != 200 {
+		t.Errorf("Expected [200], got %v", values)
+	}
+}
+
+func TestStore_Events(t *testing.T) {
+	now := time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC)
+	if newTestStore(t, now).KeepsEvents() {
+		t.Errorf("Expected the files backend not to keep events")
+	}
+
+	store := newTestSQLiteStore(t, now)
+	if !store.KeepsEvents() {
+		t.Fatalf("Expected the SQLite backend to keep events")
+	}
+	batch := []events.Event{
+		{ID: 1, Timestamp: now.Add(-time.Hour), Type: events.TypeBatteryLow, Source: "battery", Message: "kitchen low", Fields: map[string]string{"sensor": "kitchen"}},
+		{ID: 2, Timestamp: now.Add(-time.Minute), Type: events.TypeSensorConflict, Source: "identity", Message: "conflict"},
+	}
+	if err := store.Send(context.Background(), batch); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	all, err := store.Events(context.Background(), now.Add(-2*time.Hour), now, "")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(all) != 2 || all[0].Fields["sensor"] != "kitchen" || !all[0].Timestamp.Equal(now.Add(-time.Hour)) {
+		t.Errorf("Expected both events with fields, got %+v", all)
+	}
+
+	battery, _ := store.Events(context.Background(), now.Add(-2*time.Hour), now, events.TypeBatteryLow)
+	if len(battery) != 1 || battery[0].Message != "kitchen low" {
+		t.Errorf("Expected the battery event, got %+v", battery)
+	}
+}
+
+func TestStore_Stats(t *testing.T) {
+	now := time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC)
+	store := newTestSQLiteStore(t, now)
+	for i, watts := range []float64{100, 300, 200} {
+		store.Observe(powerReading(now.Add(time.Duration(i-3)*time.Minute), watts))
+	}
+	if err := store.Flush(); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	stats, err := store.Stats(now.Add(-time.Hour), now, "")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(stats) != 1 {
+		t.Fatalf("Expected 1 series, got %+v", stats)
+	}
+	st := stats[0]
+	if st.Count != 3 || st.Min != 100 || st.Max != 300 || st.Avg != 200 {
+		t.Errorf("Expected count 3, min 100, max 300, avg 200, got %+v", st)
+	}
+	if st.Labels["sensor_id"] != "1" {
+		t.Errorf("Expected sensor_id label, got %v", st.Labels)
+	}
+
+	if stats, _ := store.Stats(now.Add(-time.Hour), now, "no_such_metric"); len(stats) != 0 {
+		t.Errorf("Expected no series for another metric, got %+v", stats)
+	}
+}
+
+func TestStore_ExportCSV(t *testing.T) {
+	now := time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC)
+	store := newTestStore(t, now)
+	store.Observe(powerReading(now.Add(-2*time.Hour), 100))
+	store.Observe(powerReading(now.Add(-30*time.Minute), 250.5))
+	store.Observe(&buffer.Reading{
+		Type: buffer.ReadingTypeRoom,
+		Room: &buffer.RoomReading{Timestamp: now.Add(-10 * time.Minute), Room: "living", Source: "ble", TemperatureCelsius: 21.5},
+	})
+	if err := store.Flush(); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	var out bytes.Buffer
+	if err := store.ExportCSV(&out, now.Add(-time.Hour), now); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	rows, err := csv.NewReader(&out).ReadAll()
+	if err != nil {
+		t.Fatalf("Expected valid CSV, got %v", err)
+	}
+	if len(rows) != 3 {
+		t.Fatalf("Expected header and 2 rows, got %d: %v", len(rows), rows)
+	}
+
+	header := rows[0]
+	if header[0] != "timestamp" || header[1] != "metric" || header[len(header)-1] != "value" {
+		t.Errorf("Expected timestamp, metric, labels, value columns, got %v", header)
+	}
+	values := make(map[string]string)
+	for _, row := range rows[1:] {
+		values[row[1]] = row[len(row)-1]
+	}
+	if len(values) != 2 {
+		t.Errorf("Expected a power and a room row, got %v", rows[1:])
+	}
+	for metric, value := range values {
+		if value != "250.5" && value != "21.5" {
+			t.Errorf("Unexpected value %s for %s", value, metric)
+		}
+	}
+}
+
+func TestHandleExport(t *testing.T) {
+	now := time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC)
+	store := newTestStore(t, now)
+	store.Observe(powerReading(now.Add(-time.Minute), 100))
+
+	tests := []struct {
+		query  string
+		status int
+	}{
+		{"", http.StatusOK},
+		{"?from=2026-03-10&to=2026-03-11&format=csv", http.StatusOK},
+		{"?format=parquet", http.StatusBadRequest},
+		{"?from=yesterday", http.StatusBadRequest},
+		{"?from=2026-03-11&to=2026-03-10", http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		store.handleExport(rec, httptest.NewRequest(http.MethodGet, "/api/export"+tt.query, nil))
+		if rec.Code != tt.status {
+			t.Errorf("%q: expected status %d, got %d: %s", tt.query, tt.status, rec.Code, rec.Body.String())
+		}
+	}
+
+	// Pending readings are written before exporting
+	rec := httptest.NewRecorder()
+	store.handleExport(rec, httptest.NewRequest(http.MethodGet, "/api/export", nil))
+	rows, _ := csv.NewReader(rec.Body).ReadAll()
+	if len(rows) != 2 {
+		t.Errorf("Expected header and 1 row, got %v", rows)
+	}
+}
@@ -0,0 +1,238 @@
+package httpauth
+
+import (
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// digestTransport answers HTTP Digest challenges (RFC 7616). The last challenge is kept, so
+// later requests authenticate up front until the server sends a new one
+type digestTransport struct {
+	base     http.RoundTripper
+	username string
+	password string
+
+	mu        sync.Mutex
+	challenge *digestChallenge
+	count     uint32 // Requests sent with the current nonce
+}
+
+// digestChallenge holds the parameters of a WWW-Authenticate: Digest header
+type digestChallenge struct {
+	realm     string
+	nonce     string
+	opaque    string
+	algorithm string
+	qop       string // "auth" when offered by the server, empty for the RFC 2069 form
+}
+
+func (t *digestTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if authed, ok := t.authorize(req); ok {
+		resp, err := t.base.RoundTrip(authed)
+		if err != nil || resp.StatusCode != http.StatusUnauthorized {
+			return resp, err
+		}
+		// The nonce expired or was rejected; answer the new challenge below
+		return t.retry(req, resp)
+	}
+
+	resp, err := t.base.RoundTrip(req)
+	if err != nil || resp.StatusCode != http.StatusUnauthorized {
+		return resp, err
+	}
+	return t.retry(req, resp)
+}
+
+// retry answers the challenge in a 401 response, returning the response as-is when it has none
+func (t *digestTransport) retry(req *http.Request, resp *http.Response) (*http.Response, error) {
+	challenge, err := parseDigestChallenge(resp.Header.Values("WWW-Authenticate"))
+	if err != nil {
+		return resp, nil
+	}
+	if req.Body != nil && req.GetBody == nil {
+		return resp, nil
+	}
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+
+	t.mu.Lock()
+	t.challenge = challenge
+	t.count = 0
+	t.mu.Unlock()
+
+	retry := req.Clone(req.Context())
+	if req.Body != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			return nil, fmt.Errorf("failed to replay request body: %w", err)
+		}
+		retry.Body = body
+	}
+	authed, ok := t.authorize(retry)
+	if !ok {
+		return nil, fmt.Errorf("unsupported digest algorithm %q", challenge.algorithm)
+	}
+	return t.base.RoundTrip(authed)
+}
+
+// authorize returns a copy of the request answering the current challenge, or false when no
+// challenge was received yet
+func (t *digestTransport) authorize(req *http.Request) (*http.Request, bool) {
+	t.mu.Lock()
+	challenge := t.challenge
+	if challenge == nil {
+		t.mu.Unlock()
+		return nil, false
+	}
+	t.count++
+	count := t.count
+	t.mu.Unlock()
+
+	header, err := challenge.authorization(t.username, t.password, req.Method, req.URL.RequestURI(), count)
+	if err != nil {
+		return nil, false
+	}
+	authed := req.Clone(req.Context())
+	authed.Header.Set("Authorization", header)
+	return authed, true
+}
+
+// authorization builds the Authorization header for a request
+func (c *digestChallenge) authorization(username, password, method, uri string, count uint32) (string, error) {
+	newHash, err := digestHash(c.algorithm)
+	if err != nil {
+		return "", err
+	}
+	h := func(s string) string {
+		hasher := newHash()
+		hasher.Write([]byte(s))
+		return hex.EncodeToString(hasher.Sum(nil))
+	}
+
+	cnonce := rand.Text()
+	nc := fmt.Sprintf("%08x", count)
+	ha1 := h(username + ":" + c.realm + ":" + password)
+	if strings.HasSuffix(strings.ToUpper(c.algorithm), "-SESS") {
+		ha1 = h(ha1 + ":" + c.nonce + ":" + cnonce)
+	}
+	ha2 := h(method + ":" + uri)
+
+	var response string
+	if c.qop != "" {
+		response = h(strings.Join([]string{ha1, c.nonce, nc, cnonce, c.qop, ha2}, ":"))
+	} else {
+		response = h(ha1 + ":" + c.nonce + ":" + ha2)
+	}
+
+	params := []string{
+		fmt.Sprintf("username=%q", username),
+		fmt.Sprintf("realm=%q", c.realm),
+		fmt.Sprintf("nonce=%q", c.nonce),
+		fmt.Sprintf("uri=%q", uri),
+		fmt.Sprintf("response=%q", response),
+	}
+	if c.algorithm != "" {
+		params = append(params, "algorithm="+c.algorithm)
+	}
+	if c.opaque != "" {
+		params = append(params, fmt.Sprintf("opaque=%q", c.opaque))
+	}
+	if c.qop != "" {
+		params = append(params, "qop="+c.qop, "nc="+nc, fmt.Sprintf("cnonce=%q", cnonce))
+	}
+	return "Digest " + strings.Join(params, ", "), nil
+}
+
+// digestHash returns the hash function of a digest algorithm, MD5 when unset
+func digestHash(algorithm string) (func() hash.Hash, error) {
+	switch strings.TrimSuffix(strings.ToUpper(algorithm), "-SESS") {
+	case "", "MD5":
+		return md5.New, nil
+	case "SHA-256":
+		return sha256.New, nil
+	default:
+		return nil, fmt.Errorf("unsupported digest algorithm %q", algorithm)
+	}
+}
+
+// parseDigestChallenge finds the first Digest challenge with a supported algorithm in the
+// WWW-Authenticate headers
+func parseDigestChallenge(headers []string) (*digestChallenge, error) {
+	for _, header := range headers {
+		scheme, rest, _ := strings.Cut(strings.TrimSpace(header), " ")
+		if !strings.EqualFold(scheme, "Digest") {
+			continue
+		}
+		params := parseAuthParams(rest)
+		challenge := &digestChallenge{
+			realm:     params["realm"],
+			nonce:     params["nonce"],
+			opaque:    params["opaque"],
+			algorithm: params["algorithm"],
+		}
+		if challenge.nonce == "" {
+			continue
+		}
+		if _, err := digestHash(challenge.algorithm); err != nil {
+			continue
+		}
+		if qop, ok := params["qop"]; ok {
+			for _, option := range strings.Split(qop, ",") {
+				if strings.TrimSpace(option) == "auth" {
+					challenge.qop = "auth"
+				}
+			}
+			// auth-int alone would need the response body hashed, which isn't supported
+			if challenge.qop == "" {
+				continue
+			}
+		}
+		return challenge, nil
+	}
+	return nil, fmt.Errorf("no supported digest challenge")
+}
+
+// parseAuthParams parses comma-separated key=value pairs whose values may be quoted
+func parseAuthParams(s string) map[string]string {
+	params := make(map[string]string)
+	for {
+		s = strings.TrimLeft(s, " \t,")
+		if s == "" {
+			return params
+		}
+		key, rest, ok := strings.Cut(s, "=")
+		if !ok {
+			return params
+		}
+		key = strings.ToLower(strings.TrimSpace(key))
+		rest = strings.TrimLeft(rest, " \t")
+
+		var value strings.Builder
+		if strings.HasPrefix(rest, `"`) {
+			i := 1
+			for ; i < len(rest) && rest[i] != '"'; i++ {
+				if rest[i] == '\\' && i+1 < len(rest) {
+					i++
+				}
+				value.WriteByte(rest[i])
+			}
+			s = rest[min(i+1, len(rest)):]
+		} else {
+			end := strings.IndexByte(rest, ',')
+			if end < 0 {
+				end = len(rest)
+			}
+			value.WriteString(strings.TrimSpace(rest[:end]))
+			s = rest[end:]
+		}
+		params[key] = value.String()
+	}
+}
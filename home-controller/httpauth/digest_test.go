@@ -0,0 +1,102 @@
+package httpauth
+
+import (
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"hash"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+// digestServer accepts requests answering its challenge with admin/pass
+func digestServer(t *testing.T, algorithm string, newHash func() hash.Hash, challenges *atomic.Int32) *httptest.Server {
+	t.Helper()
+	const realm, nonce = "meter", "dcd98b7102dd2f0e8b11d0f600bfb0c093"
+	h := func(s string) string {
+		hasher := newHash()
+		hasher.Write([]byte(s))
+		return hex.EncodeToString(hasher.Sum(nil))
+	}
+
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		scheme, rest, _ := strings.Cut(r.Header.Get("Authorization"), " ")
+		if scheme == "Digest" {
+			params := parseAuthParams(rest)
+			ha1 := h("admin:" + realm + ":pass")
+			ha2 := h(r.Method + ":" + params["uri"])
+			want := h(strings.Join([]string{ha1, nonce, params["nc"], params["cnonce"], params["qop"], ha2}, ":"))
+			if params["username"] == "admin" && params["nonce"] == nonce && params["opaque"] == "xyz" && params["response"] == want {
+				w.Write([]byte("ok"))
+				return
+			}
+		}
+		challenges.Add(1)
+		w.Header().Add("WWW-Authenticate", `Basic realm="meter"`)
+		w.Header().Add("WWW-Authenticate", `Digest realm="`+realm+`", qop="auth,auth-int", nonce="`+nonce+`", opaque="xyz", algorithm=`+algorithm)
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+}
+
+func TestDigestTransport(t *testing.T) {
+	tests := []struct {
+		algorithm string
+		newHash   func() hash.Hash
+	}{
+		{algorithm: "MD5", newHash: md5.New},
+		{algorithm: "SHA-256", newHash: sha256.New},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.algorithm, func(t *testing.T) {
+			var challenges atomic.Int32
+			server := digestServer(t, tt.algorithm, tt.newHash, &challenges)
+			defer server.Close()
+
+			client := &http.Client{Transport: Config{Type: TypeDigest, Username: "admin", Password: "pass"}.Transport(nil)}
+			for range 2 {
+				resp, err := client.Get(server.URL + "/metrics?format=json")
+				if err != nil {
+					t.Fatalf("Request failed: %v", err)
+				}
+				resp.Body.Close()
+				if resp.StatusCode != http.StatusOK {
+					t.Fatalf("Expected status 200, got %d", resp.StatusCode)
+				}
+			}
+			// The second request reuses the challenge of the first
+			if got := challenges.Load(); got != 1 {
+				t.Errorf("Expected 1 challenge, got %d", got)
+			}
+		})
+	}
+}
+
+func TestDigestTransport_WrongPassword(t *testing.T) {
+	var challenges atomic.Int32
+	server := digestServer(t, "MD5", md5.New, &challenges)
+	defer server.Close()
+
+	client := &http.Client{Transport: Config{Type: TypeDigest, Username: "admin", Password: "wrong"}.Transport(nil)}
+	resp, err := client.Get(server.URL)
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("Expected status 401, got %d", resp.StatusCode)
+	}
+}
+
+func TestParseAuthParams(t *testing.T) {
+	params := parseAuthParams(`realm="a, \"b\"", qop="auth,auth-int", algorithm=SHA-256, stale=true`)
+	want := map[string]string{"realm": `a, "b"`, "qop": "auth,auth-int", "algorithm": "SHA-256", "stale": "true"}
+	for key, value := range want {
+		if params[key] != value {
+			t.Errorf("Expected %s=%q, got %q", key, value, params[key])
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package httpauth
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestApply(t *testing.T) {
+	tests := []struct {
+		name       string
+		config     Config
+		header     string
+		wantHeader string
+	}{
+		{
+			name:       "bearer",
+			config:     Config{Type: TypeBearer, Token: "secret"},
+			header:     "Authorization",
+			wantHeader: "Bearer secret",
+		},
+		{
+			name:       "custom header",
+			config:     Config{Type: TypeHeader, HeaderName: "X-API-Key", HeaderValue: "key"},
+			header:     "X-API-Key",
+			wantHeader: "key",
+		},
+		{
+			name:       "none",
+			config:     Config{Type: TypeNone},
+			header:     "Authorization",
+			wantHeader: "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, _ := http.NewRequest(http.MethodGet, "http://example.com", nil)
+			tt.config.Apply(req)
+			if got := req.Header.Get(tt.header); got != tt.wantHeader {
+				t.Errorf("Expected %s header %q, got %q", tt.header, tt.wantHeader, got)
+			}
+		})
+	}
+}
+
+func TestApply_Basic(t *testing.T) {
+	req, _ := http.NewRequest(http.MethodGet, "http://example.com", nil)
+	Config{Type: TypeBasic, Username: "admin", Password: "pass"}.Apply(req)
+
+	username, password, ok := req.BasicAuth()
+	if !ok {
+		t.Fatal("Expected basic auth to be set")
+	}
+	if username != "admin" || password != "pass" {
+		t.Errorf("Expected admin/pass, got %s/%s", username, password)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  Config
+		wantErr bool
+	}{
+		{name: "empty type", config: Config{}, wantErr: false},
+		{name: "basic without username", config: Config{Type: TypeBasic}, wantErr: true},
+		{name: "bearer without token", config: Config{Type: TypeBearer}, wantErr: true},
+		{name: "header without value", config: Config{Type: TypeHeader, HeaderName: "X-API-Key"}, wantErr: true},
+		{name: "digest without username", config: Config{Type: TypeDigest}, wantErr: true},
+		{name: "unknown type", config: Config{Type: "ntlm"}, wantErr: true},
+		{name: "valid header", config: Config{Type: TypeHeader, HeaderName: "X-API-Key", HeaderValue: "key"}, wantErr: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.config.Validate()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestNewTransport_MissingCAFile(t *testing.T) {
+	_, err := NewTransport(TLSConfig{CAFile: "/non/existent/ca.pem"})
+	if err == nil {
+		t.Fatal("Expected error for missing CA file, got nil")
+	}
+}
@@ -0,0 +1,231 @@
+package httpclient
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/mjasion/balena-home/thermostats/httpauth"
+)
+
+// DefaultTimeout bounds requests of clients configured without a timeout
+const DefaultTimeout = 30 * time.Second
+
+// Config describes a client; zero values keep the defaults
+type Config struct {
+	Timeout   time.Duration      // Whole request including reading the body, 0 uses DefaultTimeout
+	UserAgent string             // Empty uses the default set with SetDefaults, see UserAgent
+	ProxyURL  string             // Empty uses the default set with SetDefaults, then HTTP_PROXY and HTTPS_PROXY
+	TLS       httpauth.TLSConfig // CA, client certificate and verification of the server
+	Retry     RetryPolicy
+}
+
+// RetryPolicy retries requests failing with a transport error or a 502, 503 or 504 response, as
+// sent while a device or gateway restarts; requests with a body that can't be replayed are sent once
+type RetryPolicy struct {
+	Attempts int           // Total attempts, 0 and 1 send once
+	Backoff  time.Duration // Before the first retry, doubling after each; 0 waits a second
+}
+
+// Defaults apply to every client whose config leaves them empty
+type Defaults struct {
+	UserAgent string
+	ProxyURL  string
+}
+
+var (
+	defaultsMu sync.Mutex
+	defaults   Defaults
+)
+
+// SetDefaults sets the user agent and proxy of clients created afterwards; call at startup
+func SetDefaults(d Defaults) error {
+	if d.ProxyURL != "" {
+		if err := ValidateProxy(d.ProxyURL); err != nil {
+			return err
+		}
+	}
+	defaultsMu.Lock()
+	defaults = d
+	defaultsMu.Unlock()
+	return nil
+}
+
+// New creates a client from the config; it fails on unreadable TLS files or an invalid proxy URL
+func New(cfg Config) (*http.Client, error) {
+	defaultsMu.Lock()
+	if cfg.UserAgent == "" {
+		cfg.UserAgent = defaults.UserAgent
+	}
+	if cfg.ProxyURL == "" {
+		cfg.ProxyURL = defaults.ProxyURL
+	}
+	defaultsMu.Unlock()
+	if cfg.Timeout == 0 {
+		cfg.Timeout = DefaultTimeout
+	}
+
+	transport, err := httpauth.NewTransport(cfg.TLS)
+	if err != nil {
+		return nil, fmt.Errorf("failed to configure TLS: %w", err)
+	}
+	if cfg.ProxyURL != "" {
+		proxy, err := parseProxy(cfg.ProxyURL)
+		if err != nil {
+			return nil, err
+		}
+		transport.Proxy = http.ProxyURL(proxy)
+	}
+
+	var next http.RoundTripper = transport
+	if cfg.Retry.Attempts > 1 {
+		next = &retrier{next: next, policy: cfg.Retry}
+	}
+	next = &requestID{next: next}
+	if cfg.UserAgent != "" {
+		next = &userAgent{next: next, userAgent: cfg.UserAgent}
+	}
+	return &http.Client{Timeout: cfg.Timeout, Transport: next}, nil
+}
+
+// NewDefault creates a client from a config without TLS settings, which can't fail; its TLS
+// settings are ignored
+func NewDefault(cfg Config) *http.Client {
+	cfg.TLS = httpauth.TLSConfig{}
+	client, err := New(cfg)
+	if err != nil {
+		// Only a proxy URL in cfg can be invalid, the default one was validated by SetDefaults
+		return &http.Client{Timeout: cfg.Timeout}
+	}
+	return client
+}
+
+// ValidateProxy reports an error unless raw is a proxy URL, e.g. http://proxy.lan:3128 or
+// socks5://127.0.0.1:1080
+func ValidateProxy(raw string) error {
+	_, err := parseProxy(raw)
+	return err
+}
+
+// parseProxy parses a proxy URL
+func parseProxy(raw string) (*url.URL, error) {
+	proxy, err := url.Parse(raw)
+	if err != nil {
+		return nil, fmt.Errorf("invalid proxy URL: %w", err)
+	}
+	switch proxy.Scheme {
+	case "http", "https", "socks5":
+	default:
+		return nil, fmt.Errorf("proxy URL scheme must be http, https or socks5, got: %s", raw)
+	}
+	if proxy.Host == "" {
+		return nil, fmt.Errorf("proxy URL must have a host, got: %s", raw)
+	}
+	return proxy, nil
+}
+
+// Base returns the *http.Transport beneath the client's wrappers, so connection settings can be
+// tuned after instrumentation; a client using the default transport gets a copy of its own
+// Returns nil when the client ends in another round tripper
+func Base(client *http.Client) *http.Transport {
+	if client.Transport == nil {
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		client.Transport = transport
+		return transport
+	}
+	next := client.Transport
+	for {
+		switch t := next.(type) {
+		case *http.Transport:
+			return t
+		case interface{ Unwrap() http.RoundTripper }:
+			next = t.Unwrap()
+		default:
+			return nil
+		}
+	}
+}
+
+// userAgent sets the User-Agent header of requests without one
+type userAgent struct {
+	next      http.RoundTripper
+	userAgent string
+}
+
+// RoundTrip sends the request with the user agent
+func (t *userAgent) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Header.Get("User-Agent") == "" {
+		req = req.Clone(req.Context())
+		req.Header.Set("User-Agent", t.userAgent)
+	}
+	return t.next.RoundTrip(req)
+}
+
+// Unwrap returns the wrapped round tripper
+func (t *userAgent) Unwrap() http.RoundTripper {
+	return t.next
+}
+
+// retrier retries failed requests per its policy
+type retrier struct {
+	next   http.RoundTripper
+	policy RetryPolicy
+}
+
+// RoundTrip sends the request, retrying transport errors and gateway errors with backoff
+func (t *retrier) RoundTrip(req *http.Request) (*http.Response, error) {
+	backoff := t.policy.Backoff
+	if backoff <= 0 {
+		backoff = time.Second
+	}
+	replayable := req.Body == nil || req.Body == http.NoBody || req.GetBody != nil
+
+	for attempt := 1; ; attempt++ {
+		resp, err := t.next.RoundTrip(req)
+		if attempt >= t.policy.Attempts || !replayable || !retryable(resp, err) {
+			return resp, err
+		}
+		if resp != nil {
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}
+
+		timer := time.NewTimer(backoff)
+		select {
+		case <-req.Context().Done():
+			timer.Stop()
+			return nil, req.Context().Err()
+		case <-timer.C:
+		}
+		backoff *= 2
+
+		if req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, fmt.Errorf("failed to replay request body: %w", err)
+			}
+			req = req.Clone(req.Context())
+			req.Body = body
+		}
+	}
+}
+
+// Unwrap returns the wrapped round tripper
+func (t *retrier) Unwrap() http.RoundTripper {
+	return t.next
+}
+
+// retryable reports whether a request failed in a way a retry may fix
+func retryable(resp *http.Response, err error) bool {
+	if err != nil {
+		return true
+	}
+	switch resp.StatusCode {
+	case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
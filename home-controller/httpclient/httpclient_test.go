@@ -0,0 +1,143 @@
+package httpclient
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/mjasion/balena-home/thermostats/httpauth"
+)
+
+func TestNew_Retry(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body := make([]byte, 5)
+		r.Body.Read(body)
+		if string(body) != "hello" {
+			t.Errorf("Expected the body replayed, got %q", body)
+		}
+		if requests.Add(1) < 3 {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	client, err := New(Config{Timeout: 5 * time.Second, Retry: RetryPolicy{Attempts: 3, Backoff: time.Millisecond}})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	resp, err := client.Post(server.URL, "text/plain", strings.NewReader("hello"))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent || requests.Load() != 3 {
+		t.Errorf("Expected success on the third attempt, got %d after %d", resp.StatusCode, requests.Load())
+	}
+
+	// Client errors are not retried
+	requests.Store(0)
+	server.Config.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.WriteHeader(http.StatusTooManyRequests)
+	})
+	resp, err = client.Get(server.URL)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	resp.Body.Close()
+	if requests.Load() != 1 {
+		t.Errorf("Expected 1 attempt for a 429, got %d", requests.Load())
+	}
+}
+
+func TestNew_Defaults(t *testing.T) {
+	var userAgent string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userAgent = r.UserAgent()
+	}))
+	defer server.Close()
+
+	if err := SetDefaults(Defaults{UserAgent: "home-controller/test"}); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	defer SetDefaults(Defaults{})
+
+	client := NewDefault(Config{})
+	if client.Timeout != DefaultTimeout {
+		t.Errorf("Expected the default timeout, got %s", client.Timeout)
+	}
+	resp, err := client.Get(server.URL)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	resp.Body.Close()
+	if userAgent != "home-controller/test" {
+		t.Errorf("Expected the default user agent, got %q", userAgent)
+	}
+
+	if err := SetDefaults(Defaults{ProxyURL: "ftp://proxy.lan"}); err == nil {
+		t.Error("Expected an error for an ftp proxy")
+	}
+	if _, err := New(Config{TLS: httpauth.TLSConfig{CAFile: "/nonexistent/ca.pem"}}); err == nil {
+		t.Error("Expected an error for a missing CA file")
+	}
+}
+
+func TestBase(t *testing.T) {
+	client, err := New(Config{UserAgent: "home-controller/test", Retry: RetryPolicy{Attempts: 2}})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if Base(client) == nil {
+		t.Error("Expected the transport beneath the wrappers")
+	}
+
+	client = &http.Client{}
+	if transport := Base(client); transport == nil || client.Transport != transport || transport == http.DefaultTransport {
+		t.Error("Expected a copy of the default transport installed on the client")
+	}
+}
+
+func TestNew_RequestID(t *testing.T) {
+	var ids []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ids = append(ids, r.Header.Get(RequestIDHeader))
+		if len(ids) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer server.Close()
+
+	client := NewDefault(Config{Retry: RetryPolicy{Attempts: 2, Backoff: time.Millisecond}})
+	resp, err := client.Get(server.URL)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	resp.Body.Close()
+	if len(ids) != 2 || ids[0] == "" || ids[0] != ids[1] {
+		t.Errorf("Expected a retry with the same request ID, got %q", ids)
+	}
+
+	// The caller chooses the ID through the context to log it
+	ids = nil
+	req, _ := http.NewRequestWithContext(WithRequestID(context.Background(), "abc123"), http.MethodGet, server.URL, nil)
+	resp, err = client.Do(req)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	resp.Body.Close()
+	if ids[len(ids)-1] != "abc123" {
+		t.Errorf("Expected the request ID of the context, got %q", ids)
+	}
+
+	if got := UserAgent("home-controller", "v1.4.0"); got != "balena-home/home-controller/v1.4.0" {
+		t.Errorf("Expected balena-home/home-controller/v1.4.0, got %s", got)
+	}
+}
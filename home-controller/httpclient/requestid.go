@@ -0,0 +1,62 @@
+package httpclient
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+)
+
+// RequestIDHeader carries the ID of an outbound request, which endpoints like Grafana Cloud log,
+// so their side of a failed request can be found from ours
+const RequestIDHeader = "X-Request-ID"
+
+// requestIDKey is the context key of a request ID chosen by the caller
+type requestIDKey struct{}
+
+// UserAgent returns the user agent of the service, e.g. balena-home/home-controller/v1.4.0
+func UserAgent(service, version string) string {
+	return "balena-home/" + service + "/" + version
+}
+
+// NewRequestID returns a random request ID
+func NewRequestID() string {
+	id := make([]byte, 8)
+	rand.Read(id)
+	return hex.EncodeToString(id)
+}
+
+// WithRequestID returns a context sending requests with the ID, so the caller can log it
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// RequestID returns the request ID of the context, or empty when it has none
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// requestID sets the X-Request-ID header of requests without one, from the context or a new ID;
+// retries of a request share its ID
+type requestID struct {
+	next http.RoundTripper
+}
+
+// RoundTrip sends the request with a request ID
+func (t *requestID) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Header.Get(RequestIDHeader) == "" {
+		id := RequestID(req.Context())
+		if id == "" {
+			id = NewRequestID()
+		}
+		req = req.Clone(req.Context())
+		req.Header.Set(RequestIDHeader, id)
+	}
+	return t.next.RoundTrip(req)
+}
+
+// Unwrap returns the wrapped round tripper
+func (t *requestID) Unwrap() http.RoundTripper {
+	return t.next
+}
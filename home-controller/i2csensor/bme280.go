@@ -0,0 +1,186 @@
+package i2csensor
+
+import (
+	"encoding/binary"
+	"fmt"
+	"time"
+)
+
+const (
+	bme280ChipID       = 0x60
+	bme280RegChipID    = 0xD0
+	bme280RegCalib00   = 0x88
+	bme280RegCalib26   = 0xE1
+	bme280RegCtrlHum   = 0xF2
+	bme280RegStatus    = 0xF3
+	bme280RegCtrlMeas  = 0xF4
+	bme280RegData      = 0xF7
+	bme280StatusMeas   = 0x08
+	bme280CtrlHumX1    = 0x01 // Humidity oversampling x1
+	bme280CtrlMeasX1FM = 0x25 // Temperature/pressure oversampling x1, forced mode
+)
+
+// bme280Calibration holds the factory trimming parameters
+type bme280Calibration struct {
+	T1                             uint16
+	T2, T3                         int16
+	P1                             uint16
+	P2, P3, P4, P5, P6, P7, P8, P9 int16
+	H1, H3                         uint8
+	H2, H4, H5                     int16
+	H6                             int8
+}
+
+// BME280 reads temperature, humidity and pressure from a Bosch BME280
+type BME280 struct {
+	bus   Bus
+	calib bme280Calibration
+}
+
+// NewBME280 verifies the chip ID and loads the calibration data
+func NewBME280(bus Bus) (*BME280, error) {
+	id, err := readRegisters(bus, bme280RegChipID, 1)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read chip ID: %w", err)
+	}
+	if id[0] != bme280ChipID {
+		return nil, fmt.Errorf("unexpected chip ID 0x%02x (expected 0x%02x)", id[0], bme280ChipID)
+	}
+
+	calib00, err := readRegisters(bus, bme280RegCalib00, 26)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read calibration data: %w", err)
+	}
+	calib26, err := readRegisters(bus, bme280RegCalib26, 7)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read humidity calibration data: %w", err)
+	}
+
+	return &BME280{
+		bus:   bus,
+		calib: parseBME280Calibration(calib00, calib26),
+	}, nil
+}
+
+// parseBME280Calibration decodes calibration registers 0x88-0xA1 and 0xE1-0xE7
+func parseBME280Calibration(calib00, calib26 []byte) bme280Calibration {
+	u16 := func(b []byte, i int) uint16 { return binary.LittleEndian.Uint16(b[i : i+2]) }
+	s16 := func(b []byte, i int) int16 { return int16(u16(b, i)) }
+
+	return bme280Calibration{
+		T1: u16(calib00, 0),
+		T2: s16(calib00, 2),
+		T3: s16(calib00, 4),
+		P1: u16(calib00, 6),
+		P2: s16(calib00, 8),
+		P3: s16(calib00, 10),
+		P4: s16(calib00, 12),
+		P5: s16(calib00, 14),
+		P6: s16(calib00, 16),
+		P7: s16(calib00, 18),
+		P8: s16(calib00, 20),
+		P9: s16(calib00, 22),
+		H1: calib00[25],
+		H2: s16(calib26, 0),
+		H3: calib26[2],
+		// H4 and H5 are 12-bit signed values sharing register 0xE5
+		H4: int16(int8(calib26[3]))<<4 | int16(calib26[4]&0x0F),
+		H5: int16(int8(calib26[5]))<<4 | int16(calib26[4]>>4),
+		H6: int8(calib26[6]),
+	}
+}
+
+// Read triggers a forced-mode measurement and returns the compensated values
+func (s *BME280) Read() (*Measurement, error) {
+	if err := s.bus.Write([]byte{bme280RegCtrlHum, bme280CtrlHumX1}); err != nil {
+		return nil, fmt.Errorf("failed to configure humidity oversampling: %w", err)
+	}
+	if err := s.bus.Write([]byte{bme280RegCtrlMeas, bme280CtrlMeasX1FM}); err != nil {
+		return nil, fmt.Errorf("failed to start measurement: %w", err)
+	}
+
+	// A single x1 oversampled measurement takes under 10ms
+	for attempt := 0; ; attempt++ {
+		time.Sleep(10 * time.Millisecond)
+		status, err := readRegisters(s.bus, bme280RegStatus, 1)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read status: %w", err)
+		}
+		if status[0]&bme280StatusMeas == 0 {
+			break
+		}
+		if attempt >= 10 {
+			return nil, fmt.Errorf("measurement did not complete")
+		}
+	}
+
+	data, err := readRegisters(s.bus, bme280RegData, 8)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read measurement: %w", err)
+	}
+
+	adcP := int32(data[0])<<12 | int32(data[1])<<4 | int32(data[2])>>4
+	adcT := int32(data[3])<<12 | int32(data[4])<<4 | int32(data[5])>>4
+	adcH := int32(data[6])<<8 | int32(data[7])
+
+	temperature, tFine := s.calib.compensateTemperature(adcT)
+	pressure := s.calib.compensatePressure(adcP, tFine)
+	humidity := s.calib.compensateHumidity(adcH, tFine)
+
+	return &Measurement{
+		TemperatureCelsius: temperature,
+		HumidityPercent:    humidity,
+		PressureHPa:        pressure / 100.0,
+		HasPressure:        true,
+	}, nil
+}
+
+// Close closes the underlying bus
+func (s *BME280) Close() error {
+	return s.bus.Close()
+}
+
+// compensateTemperature returns the temperature in °C and the fine temperature
+// used by the pressure and humidity compensation (datasheet section 8.1)
+func (c bme280Calibration) compensateTemperature(adcT int32) (float64, float64) {
+	var1 := (float64(adcT)/16384.0 - float64(c.T1)/1024.0) * float64(c.T2)
+	var2 := (float64(adcT)/131072.0 - float64(c.T1)/8192.0) *
+		(float64(adcT)/131072.0 - float64(c.T1)/8192.0) * float64(c.T3)
+	tFine := var1 + var2
+	return tFine / 5120.0, tFine
+}
+
+// compensatePressure returns the pressure in Pa
+func (c bme280Calibration) compensatePressure(adcP int32, tFine float64) float64 {
+	var1 := tFine/2.0 - 64000.0
+	var2 := var1 * var1 * float64(c.P6) / 32768.0
+	var2 = var2 + var1*float64(c.P5)*2.0
+	var2 = var2/4.0 + float64(c.P4)*65536.0
+	var1 = (float64(c.P3)*var1*var1/524288.0 + float64(c.P2)*var1) / 524288.0
+	var1 = (1.0 + var1/32768.0) * float64(c.P1)
+	if var1 == 0 {
+		return 0 // Avoid division by zero
+	}
+
+	p := 1048576.0 - float64(adcP)
+	p = (p - var2/4096.0) * 6250.0 / var1
+	var1 = float64(c.P9) * p * p / 2147483648.0
+	var2 = p * float64(c.P8) / 32768.0
+	return p + (var1+var2+float64(c.P7))/16.0
+}
+
+// compensateHumidity returns the relative humidity in %
+func (c bme280Calibration) compensateHumidity(adcH int32, tFine float64) float64 {
+	h := tFine - 76800.0
+	h = (float64(adcH) - (float64(c.H4)*64.0 + float64(c.H5)/16384.0*h)) *
+		(float64(c.H2) / 65536.0 * (1.0 + float64(c.H6)/67108864.0*h*(1.0+float64(c.H3)/67108864.0*h)))
+	h = h * (1.0 - float64(c.H1)*h/524288.0)
+
+	if h > 100.0 {
+		return 100.0
+	}
+	if h < 0.0 {
+		return 0.0
+	}
+	return h
+}
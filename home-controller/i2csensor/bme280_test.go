@@ -0,0 +1,138 @@
+package i2csensor
+
+import (
+	"math"
+	"testing"
+)
+
+// fakeBus emulates a register-addressed I2C device
+type fakeBus struct {
+	registers [256]byte
+	pointer   byte
+	closed    bool
+}
+
+func (b *fakeBus) Write(data []byte) error {
+	b.pointer = data[0]
+	if len(data) > 1 {
+		copy(b.registers[data[0]:], data[1:])
+	}
+	return nil
+}
+
+func (b *fakeBus) Read(data []byte) error {
+	copy(data, b.registers[b.pointer:])
+	return nil
+}
+
+func (b *fakeBus) Close() error {
+	b.closed = true
+	return nil
+}
+
+// Compensation example values from the BME280 datasheet
+var datasheetCalibration = bme280Calibration{
+	T1: 27504, T2: 26435, T3: -1000,
+	P1: 36477, P2: -10685, P3: 3024, P4: 2855, P5: 140, P6: -7, P7: 15500, P8: -14600, P9: 6000,
+}
+
+func TestBME280_CompensateTemperature(t *testing.T) {
+	temperature, _ := datasheetCalibration.compensateTemperature(519888)
+
+	if math.Abs(temperature-25.08) > 0.01 {
+		t.Errorf("Expected temperature 25.08°C, got %f", temperature)
+	}
+}
+
+func TestBME280_CompensatePressure(t *testing.T) {
+	_, tFine := datasheetCalibration.compensateTemperature(519888)
+	pressure := datasheetCalibration.compensatePressure(415148, tFine)
+
+	if math.Abs(pressure-100653.27) > 1 {
+		t.Errorf("Expected pressure 100653.27 Pa, got %f", pressure)
+	}
+}
+
+func TestBME280_HumidityClamped(t *testing.T) {
+	calib := bme280Calibration{H1: 75, H2: 370, H3: 0, H4: 313, H5: 50, H6: 30}
+	_, tFine := datasheetCalibration.compensateTemperature(519888)
+
+	humidity := calib.compensateHumidity(65535, tFine)
+	if humidity != 100.0 {
+		t.Errorf("Expected humidity clamped to 100%%, got %f", humidity)
+	}
+
+	humidity = calib.compensateHumidity(0, tFine)
+	if humidity != 0.0 {
+		t.Errorf("Expected humidity clamped to 0%%, got %f", humidity)
+	}
+}
+
+func TestParseBME280Calibration_HumiditySplitRegisters(t *testing.T) {
+	calib00 := make([]byte, 26)
+	calib26 := []byte{0x6E, 0x01, 0x00, 0x13, 0x29, 0x03, 0x1E}
+
+	calib := parseBME280Calibration(calib00, calib26)
+
+	if calib.H2 != 366 {
+		t.Errorf("Expected H2 366, got %d", calib.H2)
+	}
+	if calib.H4 != 0x139 {
+		t.Errorf("Expected H4 0x139, got 0x%x", calib.H4)
+	}
+	if calib.H5 != 0x032 {
+		t.Errorf("Expected H5 0x032, got 0x%x", calib.H5)
+	}
+	if calib.H6 != 30 {
+		t.Errorf("Expected H6 30, got %d", calib.H6)
+	}
+}
+
+func TestNewBME280_WrongChipID(t *testing.T) {
+	bus := &fakeBus{}
+	bus.registers[bme280RegChipID] = 0x58 // BMP280 has no humidity sensor
+
+	if _, err := NewBME280(bus); err == nil {
+		t.Fatal("Expected error for unexpected chip ID, got nil")
+	}
+}
+
+func TestBME280_Read(t *testing.T) {
+	bus := &fakeBus{}
+	bus.registers[bme280RegChipID] = bme280ChipID
+
+	// Datasheet calibration for temperature and pressure
+	calib := []uint16{27504, 26435, 0xFC18, 36477, 0xD641, 3024, 2855, 140, 0xFFF9, 15500, 0xC6F8, 6000}
+	for i, v := range calib {
+		bus.registers[bme280RegCalib00+i*2] = byte(v)
+		bus.registers[bme280RegCalib00+i*2+1] = byte(v >> 8)
+	}
+
+	// adc_P = 415148, adc_T = 519888, adc_H = 0
+	copy(bus.registers[bme280RegData:], []byte{0x65, 0x5A, 0xC0, 0x7E, 0xED, 0x00, 0x00, 0x00})
+
+	sensor, err := NewBME280(bus)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	measurement, err := sensor.Read()
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	if math.Abs(measurement.TemperatureCelsius-25.08) > 0.01 {
+		t.Errorf("Expected temperature 25.08°C, got %f", measurement.TemperatureCelsius)
+	}
+	if math.Abs(measurement.PressureHPa-1006.53) > 0.01 {
+		t.Errorf("Expected pressure 1006.53 hPa, got %f", measurement.PressureHPa)
+	}
+	if !measurement.HasPressure {
+		t.Error("Expected BME280 measurement to include pressure")
+	}
+
+	sensor.Close()
+	if !bus.closed {
+		t.Error("Expected bus to be closed")
+	}
+}
@@ -0,0 +1,20 @@
+package i2csensor
+
+// Bus is a connection to a single device on an I2C bus
+type Bus interface {
+	Write(data []byte) error
+	Read(data []byte) error
+	Close() error
+}
+
+// readRegisters reads n bytes starting at register reg
+func readRegisters(bus Bus, reg byte, n int) ([]byte, error) {
+	if err := bus.Write([]byte{reg}); err != nil {
+		return nil, err
+	}
+	data := make([]byte, n)
+	if err := bus.Read(data); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
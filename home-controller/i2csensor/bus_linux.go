@@ -0,0 +1,54 @@
+//go:build linux
+
+package i2csensor
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+)
+
+// i2cSlave is the ioctl request selecting the target device address
+const i2cSlave = 0x0703
+
+// linuxBus talks to an I2C device through the i2c-dev character device
+type linuxBus struct {
+	file *os.File
+}
+
+// OpenBus opens /dev/i2c-<bus> and selects the device at address
+func OpenBus(bus, address int) (Bus, error) {
+	path := fmt.Sprintf("/dev/i2c-%d", bus)
+	file, err := os.OpenFile(path, os.O_RDWR, 0)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %w", path, err)
+	}
+
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, file.Fd(), i2cSlave, uintptr(address)); errno != 0 {
+		file.Close()
+		return nil, fmt.Errorf("failed to select I2C address 0x%02x: %w", address, errno)
+	}
+
+	return &linuxBus{file: file}, nil
+}
+
+// Write writes data to the device
+func (b *linuxBus) Write(data []byte) error {
+	if _, err := b.file.Write(data); err != nil {
+		return fmt.Errorf("I2C write failed: %w", err)
+	}
+	return nil
+}
+
+// Read fills data from the device
+func (b *linuxBus) Read(data []byte) error {
+	if _, err := b.file.Read(data); err != nil {
+		return fmt.Errorf("I2C read failed: %w", err)
+	}
+	return nil
+}
+
+// Close closes the character device
+func (b *linuxBus) Close() error {
+	return b.file.Close()
+}
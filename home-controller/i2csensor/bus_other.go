@@ -0,0 +1,10 @@
+//go:build !linux
+
+package i2csensor
+
+import "fmt"
+
+// OpenBus is only supported on Linux
+func OpenBus(bus, address int) (Bus, error) {
+	return nil, fmt.Errorf("I2C is only supported on Linux")
+}
@@ -0,0 +1,45 @@
+package i2csensor
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/mjasion/balena-home/thermostats/collector"
+)
+
+// Options are the settings of the i2c collector
+type Options struct {
+	Sensors []struct {
+		Name    string `yaml:"name"`
+		ID      int    `yaml:"id"`
+		Model   string `yaml:"model"` // bme280 or sht31
+		Bus     int    `yaml:"bus"`
+		Address int    `yaml:"address"`
+	} `yaml:"sensors"`
+}
+
+func init() {
+	collector.Register("i2c", func(spec collector.Spec, deps collector.Deps) (collector.Collector, error) {
+		var options Options
+		if err := spec.Options.Decode(&options); err != nil {
+			return nil, err
+		}
+		if len(options.Sensors) == 0 {
+			return nil, fmt.Errorf("at least one I2C sensor must be configured")
+		}
+		sensors := make([]SensorConfig, len(options.Sensors))
+		for i, sensor := range options.Sensors {
+			sensors[i] = SensorConfig{
+				Name:    sensor.Name,
+				ID:      sensor.ID,
+				Model:   strings.ToLower(sensor.Model),
+				Bus:     sensor.Bus,
+				Address: sensor.Address,
+			}
+		}
+
+		poller := NewPoller(sensors, deps.Buffer, int(spec.Interval.Seconds()), deps.Logger)
+		poller.SetCadence(deps.Cadence)
+		return poller, nil
+	})
+}
@@ -0,0 +1,159 @@
+package i2csensor
+
+import (
+	"context"
+	"time"
+
+	"github.com/mjasion/balena-home/thermostats/buffer"
+	"github.com/mjasion/balena-home/thermostats/clock"
+	"github.com/mjasion/balena-home/thermostats/schedule"
+	"go.uber.org/zap"
+)
+
+// Poller periodically reads I2C sensors and adds readings to the buffer
+type Poller struct {
+	sensors      []SensorConfig
+	open         func(SensorConfig) (Sensor, error)
+	devices      map[int]Sensor // Opened sensors by config ID
+	buffer       *buffer.RingBuffer
+	logger       *zap.Logger
+	readInterval time.Duration
+	skipper      schedule.Skipper
+	clock        clock.Clock
+}
+
+// NewPoller creates a new I2C sensor poller
+func NewPoller(sensors []SensorConfig, buf *buffer.RingBuffer, readIntervalSeconds int, logger *zap.Logger) *Poller {
+	return &Poller{
+		sensors:      sensors,
+		open:         Open,
+		devices:      make(map[int]Sensor),
+		buffer:       buf,
+		logger:       logger,
+		readInterval: time.Duration(readIntervalSeconds) * time.Second,
+		clock:        clock.Real,
+	}
+}
+
+// SetCadence reads only every cadence.Multiplier() intervals, e.g. less often while nobody is home
+func (p *Poller) SetCadence(cadence schedule.Cadence) {
+	p.skipper = schedule.NewSkipper(cadence)
+}
+
+// SetClock sets the clock driving the read ticker and reading timestamps, e.g. a fake one in tests
+func (p *Poller) SetClock(c clock.Clock) {
+	p.clock = c
+}
+
+// Start starts the polling loop
+func (p *Poller) Start(ctx context.Context) {
+	p.logger.Info("starting I2C sensor poller",
+		zap.Duration("read_interval", p.readInterval),
+		zap.Int("sensor_count", len(p.sensors)),
+	)
+
+	// Create ticker for periodic reading
+	ticker := p.clock.NewTicker(p.readInterval)
+	defer ticker.Stop()
+
+	// Read immediately on start
+	if err := p.readAndBuffer(); err != nil {
+		p.logger.Info("buffer closed, stopping I2C sensor poller")
+		p.closeAll()
+		return
+	}
+
+	// Then read at regular intervals
+	for {
+		select {
+		case <-ctx.Done():
+			p.logger.Info("stopping I2C sensor poller")
+			p.closeAll()
+			return
+		case <-ticker.C():
+			if p.skipper.Skip() {
+				continue
+			}
+			if err := p.readAndBuffer(); err != nil {
+				p.logger.Info("buffer closed, stopping I2C sensor poller")
+				p.closeAll()
+				return
+			}
+		}
+	}
+}
+
+// readAndBuffer reads all configured sensors and adds the readings to the buffer
+// Returns buffer.ErrClosed once the buffer no longer accepts readings
+func (p *Poller) readAndBuffer() error {
+	count := 0
+	for _, cfg := range p.sensors {
+		// Sensors that failed to initialize are retried on every interval
+		device, ok := p.devices[cfg.ID]
+		if !ok {
+			var err error
+			device, err = p.open(cfg)
+			if err != nil {
+				p.logger.Warn("failed to open I2C sensor",
+					zap.String("sensor_name", cfg.Name),
+					zap.String("model", cfg.Model),
+					zap.Int("bus", cfg.Bus),
+					zap.Int("address", cfg.Address),
+					zap.Error(err),
+				)
+				continue
+			}
+			p.devices[cfg.ID] = device
+		}
+
+		measurement, err := device.Read()
+		if err != nil {
+			p.logger.Warn("failed to read I2C sensor, reopening on next interval",
+				zap.String("sensor_name", cfg.Name),
+				zap.String("model", cfg.Model),
+				zap.Error(err),
+			)
+			device.Close()
+			delete(p.devices, cfg.ID)
+			continue
+		}
+
+		err = p.buffer.Add(&buffer.Reading{
+			Type: buffer.ReadingTypeI2C,
+			I2C: &buffer.I2CReading{
+				Timestamp:          p.clock.Now(),
+				SensorName:         cfg.Name,
+				SensorID:           cfg.ID,
+				Model:              cfg.Model,
+				TemperatureCelsius: measurement.TemperatureCelsius,
+				HumidityPercent:    measurement.HumidityPercent,
+				PressureHPa:        measurement.PressureHPa,
+				HasPressure:        measurement.HasPressure,
+			},
+		})
+		if err != nil {
+			return err
+		}
+		count++
+
+		p.logger.Debug("added I2C sensor reading to buffer",
+			zap.String("sensor_name", cfg.Name),
+			zap.Float64("temperature_celsius", measurement.TemperatureCelsius),
+			zap.Float64("humidity_percent", measurement.HumidityPercent),
+			zap.Float64("pressure_hpa", measurement.PressureHPa),
+		)
+	}
+
+	p.logger.Info("read and buffered I2C sensor data",
+		zap.Int("reading_count", count),
+	)
+	return nil
+}
+
+// closeAll closes every opened sensor
+func (p *Poller) closeAll() {
+	for id, device := range p.devices {
+		device.Close()
+		delete(p.devices, id)
+	}
+}
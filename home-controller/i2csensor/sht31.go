@@ -0,0 +1,78 @@
+package i2csensor
+
+import (
+	"fmt"
+	"time"
+)
+
+// sht31MeasureHigh is the single-shot, high repeatability, no clock stretching command
+var sht31MeasureHigh = []byte{0x24, 0x00}
+
+// SHT31 reads temperature and humidity from a Sensirion SHT31
+type SHT31 struct {
+	bus Bus
+}
+
+// NewSHT31 creates a new SHT31 sensor
+func NewSHT31(bus Bus) *SHT31 {
+	return &SHT31{bus: bus}
+}
+
+// Read performs a single-shot measurement
+func (s *SHT31) Read() (*Measurement, error) {
+	if err := s.bus.Write(sht31MeasureHigh); err != nil {
+		return nil, fmt.Errorf("failed to start measurement: %w", err)
+	}
+
+	// High repeatability measurement takes up to 15ms
+	time.Sleep(20 * time.Millisecond)
+
+	data := make([]byte, 6)
+	if err := s.bus.Read(data); err != nil {
+		return nil, fmt.Errorf("failed to read measurement: %w", err)
+	}
+
+	return parseSHT31(data)
+}
+
+// Close closes the underlying bus
+func (s *SHT31) Close() error {
+	return s.bus.Close()
+}
+
+// parseSHT31 decodes a measurement response
+// Format (6 bytes):
+// - Bytes 0-1: Temperature (big endian), byte 2: CRC
+// - Bytes 3-4: Humidity (big endian), byte 5: CRC
+func parseSHT31(data []byte) (*Measurement, error) {
+	if CRC8(data[0:2]) != data[2] {
+		return nil, fmt.Errorf("temperature CRC mismatch")
+	}
+	if CRC8(data[3:5]) != data[5] {
+		return nil, fmt.Errorf("humidity CRC mismatch")
+	}
+
+	rawT := uint16(data[0])<<8 | uint16(data[1])
+	rawH := uint16(data[3])<<8 | uint16(data[4])
+
+	return &Measurement{
+		TemperatureCelsius: -45.0 + 175.0*float64(rawT)/65535.0,
+		HumidityPercent:    100.0 * float64(rawH) / 65535.0,
+	}, nil
+}
+
+// CRC8 computes the Sensirion CRC-8 (polynomial 0x31, init 0xFF)
+func CRC8(data []byte) byte {
+	crc := byte(0xFF)
+	for _, b := range data {
+		crc ^= b
+		for i := 0; i < 8; i++ {
+			if crc&0x80 != 0 {
+				crc = crc<<1 ^ 0x31
+			} else {
+				crc <<= 1
+			}
+		}
+	}
+	return crc
+}
@@ -0,0 +1,42 @@
+package i2csensor
+
+import (
+	"math"
+	"testing"
+)
+
+func TestCRC8(t *testing.T) {
+	// Example from the SHT3x datasheet
+	if crc := CRC8([]byte{0xBE, 0xEF}); crc != 0x92 {
+		t.Errorf("Expected CRC 0x92, got 0x%02x", crc)
+	}
+}
+
+func TestParseSHT31(t *testing.T) {
+	rawT := []byte{0x66, 0x66} // 25.0°C
+	rawH := []byte{0x80, 0x00} // 50.0%
+	data := []byte{rawT[0], rawT[1], CRC8(rawT), rawH[0], rawH[1], CRC8(rawH)}
+
+	measurement, err := parseSHT31(data)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	if math.Abs(measurement.TemperatureCelsius-25.0) > 0.01 {
+		t.Errorf("Expected temperature 25.0°C, got %f", measurement.TemperatureCelsius)
+	}
+	if math.Abs(measurement.HumidityPercent-50.0) > 0.01 {
+		t.Errorf("Expected humidity 50.0%%, got %f", measurement.HumidityPercent)
+	}
+	if measurement.HasPressure {
+		t.Error("Expected SHT31 measurement without pressure")
+	}
+}
+
+func TestParseSHT31_CRCMismatch(t *testing.T) {
+	data := []byte{0x66, 0x66, 0x00, 0x80, 0x00, 0x00}
+
+	if _, err := parseSHT31(data); err == nil {
+		t.Fatal("Expected CRC error, got nil")
+	}
+}
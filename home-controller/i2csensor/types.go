@@ -0,0 +1,55 @@
+package i2csensor
+
+import "fmt"
+
+// Supported sensor models
+const (
+	ModelBME280 = "bme280"
+	ModelSHT31  = "sht31"
+)
+
+// SensorConfig represents configuration for a single I2C sensor
+type SensorConfig struct {
+	Name    string
+	ID      int
+	Model   string
+	Bus     int
+	Address int
+}
+
+// Measurement contains the compensated values of a single sensor read
+type Measurement struct {
+	TemperatureCelsius float64
+	HumidityPercent    float64
+	PressureHPa        float64
+	HasPressure        bool
+}
+
+// Sensor is an environmental sensor on the I2C bus
+type Sensor interface {
+	Read() (*Measurement, error)
+	Close() error
+}
+
+// Open opens the I2C bus and initializes the sensor model
+func Open(cfg SensorConfig) (Sensor, error) {
+	bus, err := OpenBus(cfg.Bus, cfg.Address)
+	if err != nil {
+		return nil, err
+	}
+
+	switch cfg.Model {
+	case ModelBME280:
+		sensor, err := NewBME280(bus)
+		if err != nil {
+			bus.Close()
+			return nil, err
+		}
+		return sensor, nil
+	case ModelSHT31:
+		return NewSHT31(bus), nil
+	default:
+		bus.Close()
+		return nil, fmt.Errorf("unsupported sensor model: %s", cfg.Model)
+	}
+}
@@ -0,0 +1,258 @@
+package identity
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mjasion/balena-home/thermostats/buffer"
+	"github.com/mjasion/balena-home/thermostats/events"
+	"go.uber.org/zap"
+)
+
+// Kinds of conflicts, named after the label claimed by several identities
+const (
+	KindSensorID   = "sensor_id"
+	KindSensorName = "sensor_name"
+	KindMAC        = "mac"
+)
+
+// identityWindow is how long an identity is remembered after its last reading,
+// so a replaced sensor stops conflicting with its successor
+const identityWindow = time.Hour
+
+// Identity is the MAC address and labels a sensor's readings carry
+type Identity struct {
+	MAC  string `json:"mac"`
+	Name string `json:"sensor_name"`
+	ID   int    `json:"sensor_id"`
+}
+
+// Conflict is a label value claimed by more than one identity, whose series would be merged
+type Conflict struct {
+	Kind       string     `json:"kind"`
+	Key        string     `json:"key"`
+	Identities []Identity `json:"identities"`
+}
+
+// conflictKey identifies a conflict independent of the identities involved
+type conflictKey struct {
+	kind string
+	key  string
+}
+
+// Checker detects BLE sensor identities that share a sensor_id or sensor_name with a different MAC,
+// or a MAC reported under several names, e.g. when a satellite's configuration disagrees with this one
+type Checker struct {
+	buffer   *buffer.RingBuffer
+	interval time.Duration
+	logger   *zap.Logger
+	eventLog *events.Log
+	now      func() time.Time
+
+	mu       sync.Mutex
+	seen     map[Identity]time.Time // Time of the last reading per identity
+	pinned   map[Identity]bool      // Configured identities, never forgotten
+	reported map[conflictKey]bool   // Conflicts already logged
+}
+
+// New creates a checker reporting conflicts as readings every intervalSeconds;
+// register Observe as a buffer listener to feed it
+func New(buf *buffer.RingBuffer, intervalSeconds int, logger *zap.Logger) *Checker {
+	return &Checker{
+		buffer:   buf,
+		interval: time.Duration(intervalSeconds) * time.Second,
+		logger:   logger,
+		now:      time.Now,
+		seen:     make(map[Identity]time.Time),
+		pinned:   make(map[Identity]bool),
+		reported: make(map[conflictKey]bool),
+	}
+}
+
+// SetEventLog sets the event log used to record new conflicts
+func (c *Checker) SetEventLog(eventLog *events.Log) {
+	c.eventLog = eventLog
+}
+
+// Pin registers a configured sensor, so readings from elsewhere claiming its labels conflict with it
+func (c *Checker) Pin(mac, name string, id int) {
+	identity := Identity{MAC: strings.ToUpper(mac), Name: name, ID: id}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.pinned[identity] = true
+	c.seen[identity] = c.now()
+}
+
+// Observe records the identity of BLE readings and reports conflicts it introduces
+func (c *Checker) Observe(reading *buffer.Reading) {
+	r := reading.BLE
+	if r == nil {
+		return
+	}
+	identity := Identity{MAC: strings.ToUpper(r.MAC), Name: r.SensorName, ID: r.SensorID}
+
+	c.mu.Lock()
+	_, known := c.seen[identity]
+	c.seen[identity] = c.now()
+	var added []Conflict
+	if !known {
+		added = c.newConflicts()
+	}
+	c.mu.Unlock()
+
+	for _, conflict := range added {
+		c.report(conflict)
+	}
+}
+
+// report logs and records a newly detected conflict
+func (c *Checker) report(conflict Conflict) {
+	identities := make([]string, len(conflict.Identities))
+	for i, identity := range conflict.Identities {
+		identities[i] = fmt.Sprintf("%s=%s/%d", identity.MAC, identity.Name, identity.ID)
+	}
+	c.logger.Warn("sensor identity conflict, series of these sensors would be merged",
+		zap.String("kind", conflict.Kind),
+		zap.String("key", conflict.Key),
+		zap.Strings("identities", identities),
+	)
+	c.eventLog.Record(events.TypeSensorConflict, "identity",
+		fmt.Sprintf("%s %s claimed by %d sensors", conflict.Kind, conflict.Key, len(conflict.Identities)),
+		map[string]string{"kind": conflict.Kind, "key": conflict.Key, "identities": strings.Join(identities, ",")},
+	)
+}
+
+// newConflicts returns conflicts not reported before and marks them reported; caller holds the lock
+func (c *Checker) newConflicts() []Conflict {
+	var added []Conflict
+	for _, conflict := range c.conflicts() {
+		key := conflictKey{kind: conflict.Kind, key: conflict.Key}
+		if !c.reported[key] {
+			c.reported[key] = true
+			added = append(added, conflict)
+		}
+	}
+	return added
+}
+
+// conflicts groups the remembered identities by each label; caller holds the lock
+func (c *Checker) conflicts() []Conflict {
+	groups := make(map[conflictKey][]Identity)
+	for identity := range c.seen {
+		keys := []conflictKey{
+			{kind: KindSensorID, key: strconv.Itoa(identity.ID)},
+			{kind: KindSensorName, key: identity.Name},
+			{kind: KindMAC, key: identity.MAC},
+		}
+		for _, key := range keys {
+			groups[key] = append(groups[key], identity)
+		}
+	}
+
+	var conflicts []Conflict
+	for key, identities := range groups {
+		if !distinct(key.kind, identities) {
+			continue
+		}
+		sort.Slice(identities, func(i, j int) bool {
+			if identities[i].MAC != identities[j].MAC {
+				return identities[i].MAC < identities[j].MAC
+			}
+			return identities[i].Name < identities[j].Name
+		})
+		conflicts = append(conflicts, Conflict{Kind: key.kind, Key: key.key, Identities: identities})
+	}
+	sort.Slice(conflicts, func(i, j int) bool {
+		if conflicts[i].Kind != conflicts[j].Kind {
+			return conflicts[i].Kind < conflicts[j].Kind
+		}
+		return conflicts[i].Key < conflicts[j].Key
+	})
+	return conflicts
+}
+
+// distinct reports whether identities sharing a label disagree on what matters for that kind:
+// the MAC for sensor_id and sensor_name, the labels for mac
+func distinct(kind string, identities []Identity) bool {
+	if len(identities) < 2 {
+		return false
+	}
+	if kind == KindMAC {
+		// Every identity in the group differs in name or ID, as identities are unique
+		return true
+	}
+	for _, identity := range identities[1:] {
+		if identity.MAC != identities[0].MAC {
+			return true
+		}
+	}
+	return false
+}
+
+// Conflicts returns the current conflicts
+func (c *Checker) Conflicts() []Conflict {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.conflicts()
+}
+
+// Start periodically forgets identities not seen within the window and adds a reading
+// per conflict until the context is cancelled
+func (c *Checker) Start(ctx context.Context) {
+	c.logger.Info("starting sensor identity checker", zap.Duration("interval", c.interval))
+
+	ticker := time.NewTicker(c.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			c.logger.Info("stopping sensor identity checker")
+			return
+		case <-ticker.C:
+			c.check()
+		}
+	}
+}
+
+// check expires stale identities and adds a conflict reading per current conflict
+func (c *Checker) check() {
+	now := c.now()
+	c.mu.Lock()
+	for identity, seen := range c.seen {
+		if !c.pinned[identity] && now.Sub(seen) > identityWindow {
+			delete(c.seen, identity)
+		}
+	}
+	conflicts := c.conflicts()
+	// Resolved conflicts are reported again should they recur
+	current := make(map[conflictKey]bool, len(conflicts))
+	for _, conflict := range conflicts {
+		current[conflictKey{kind: conflict.Kind, key: conflict.Key}] = true
+	}
+	for key := range c.reported {
+		if !current[key] {
+			delete(c.reported, key)
+		}
+	}
+	c.mu.Unlock()
+
+	// Reported after c.mu is released, as Add blocks until every listener has run
+	for _, conflict := range conflicts {
+		c.buffer.Add(&buffer.Reading{
+			Type: buffer.ReadingTypeConflict,
+			Conflict: &buffer.ConflictReading{
+				Timestamp:  now,
+				Kind:       conflict.Kind,
+				Key:        conflict.Key,
+				Identities: len(conflict.Identities),
+			},
+		})
+	}
+}
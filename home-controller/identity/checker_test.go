@@ -0,0 +1,66 @@
+package identity
+
+import (
+	"testing"
+	"time"
+
+	"github.com/mjasion/balena-home/thermostats/buffer"
+	"github.com/mjasion/balena-home/thermostats/events"
+	"go.uber.org/zap"
+)
+
+func bleReading(mac, name string, id int) *buffer.Reading {
+	return &buffer.Reading{Type: buffer.ReadingTypeBLE, BLE: &buffer.SensorReading{
+		Timestamp: time.Now(), MAC: mac, SensorName: name, SensorID: id, TemperatureCelsius: 21,
+	}}
+}
+
+func TestChecker_DetectsConflicts(t *testing.T) {
+	buf := buffer.New(100, zap.NewNop())
+	eventLog := events.NewLog(100, zap.NewNop())
+	checker := New(buf, 60, zap.NewNop())
+	checker.SetEventLog(eventLog)
+	checker.Pin("a4:c1:38:00:00:01", "Bedroom", 1)
+	buf.AddListener(checker.Observe)
+
+	// The scanner reports configured sensors under their configured labels
+	buf.Add(bleReading("A4:C1:38:00:00:01", "Bedroom", 1))
+	if conflicts := checker.Conflicts(); len(conflicts) != 0 {
+		t.Fatalf("Expected no conflicts, got %+v", conflicts)
+	}
+
+	// A satellite configured differently reuses sensor ID 1 for another MAC
+	buf.Add(bleReading("A4:C1:38:00:00:02", "Garage", 1))
+	buf.Add(bleReading("A4:C1:38:00:00:02", "Garage", 1))
+	conflicts := checker.Conflicts()
+	if len(conflicts) != 1 || conflicts[0].Kind != KindSensorID || conflicts[0].Key != "1" || len(conflicts[0].Identities) != 2 {
+		t.Fatalf("Expected a sensor_id conflict on 1, got %+v", conflicts)
+	}
+	if recorded := eventLog.List(events.Filter{Type: events.TypeSensorConflict}); len(recorded) != 1 {
+		t.Errorf("Expected the conflict to be recorded once, got %d events", len(recorded))
+	}
+
+	// The same MAC under another name conflicts on both the MAC and the name
+	buf.Add(bleReading("A4:C1:38:00:00:03", "Kitchen", 3))
+	buf.Add(bleReading("A4:C1:38:00:00:03", "Hall", 3))
+	kinds := make(map[string]bool)
+	for _, conflict := range checker.Conflicts() {
+		kinds[conflict.Kind+"="+conflict.Key] = true
+	}
+	if !kinds["mac=A4:C1:38:00:00:03"] || kinds["sensor_id=3"] {
+		t.Errorf("Expected a MAC conflict but no sensor_id conflict for a single MAC, got %v", kinds)
+	}
+	buf.GetAllAndClear()
+
+	// Conflicts are pushed as readings, and forgotten once the unconfigured sensors go quiet
+	checker.check()
+	readings := buf.GetAllAndClear()
+	if len(readings) != 2 || readings[0].Conflict == nil {
+		t.Fatalf("Expected 2 conflict readings, got %+v", readings)
+	}
+	checker.now = func() time.Time { return time.Now().Add(identityWindow + time.Minute) }
+	checker.check()
+	if conflicts := checker.Conflicts(); len(conflicts) != 0 {
+		t.Errorf("Expected conflicts to expire with their sensors, got %+v", conflicts)
+	}
+}
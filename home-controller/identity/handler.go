@@ -0,0 +1,30 @@
+package identity
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/mjasion/balena-home/thermostats/admin"
+)
+
+// RegisterHandlers registers the conflict report on the admin server
+func (c *Checker) RegisterHandlers(server *admin.Server) {
+	server.HandleFunc("GET /api/sensors/conflicts", c.handleConflicts)
+}
+
+// handleConflicts handles GET /api/sensors/conflicts
+func (c *Checker) handleConflicts(w http.ResponseWriter, r *http.Request) {
+	conflicts := c.Conflicts()
+	message := "no sensor identity conflicts"
+	if len(conflicts) > 0 {
+		message = fmt.Sprintf("%d sensor identity conflicts", len(conflicts))
+	}
+	if conflicts == nil {
+		conflicts = []Conflict{}
+	}
+	admin.WriteJSON(w, http.StatusOK, admin.Response{
+		Success: true,
+		Message: message,
+		Data:    conflicts,
+	})
+}
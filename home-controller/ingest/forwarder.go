@@ -0,0 +1,140 @@
+package ingest
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/mjasion/balena-home/thermostats/buffer"
+	"github.com/mjasion/balena-home/thermostats/dnscache"
+	"github.com/mjasion/balena-home/thermostats/httpclient"
+	"github.com/mjasion/balena-home/thermostats/readingpb"
+	"github.com/mjasion/balena-home/thermostats/telemetry"
+	"go.uber.org/zap"
+)
+
+// Forwarder relays buffered readings to a main instance's ingestion endpoint
+// Used on satellite instances in place of the Prometheus pusher
+type Forwarder struct {
+	url       string
+	token     string
+	client    *http.Client
+	buffer    *buffer.RingBuffer
+	interval  time.Duration
+	batchSize int
+	logger    *zap.Logger
+	trigger   chan struct{} // Requests an out-of-cycle forward
+}
+
+// NewForwarder creates a forwarder posting to url, e.g. http://home-controller:8080/api/readings
+func NewForwarder(url, token string, buf *buffer.RingBuffer, intervalSeconds, batchSize int, logger *zap.Logger) *Forwarder {
+	return &Forwarder{
+		url:       url,
+		token:     token,
+		client:    httpclient.NewDefault(httpclient.Config{Timeout: 30 * time.Second}),
+		buffer:    buf,
+		interval:  time.Duration(intervalSeconds) * time.Second,
+		batchSize: batchSize,
+		logger:    logger,
+		trigger:   make(chan struct{}, 1),
+	}
+}
+
+// SetResolver dials forwarding requests through the DNS cache, recycling connections after
+// maxFailures consecutive transport errors; call before SetRecorder
+func (f *Forwarder) SetResolver(resolver *dnscache.Resolver, maxFailures int) {
+	resolver.Instrument(f.client, maxFailures)
+}
+
+// SetRecorder records forwarding requests as the "forward" dependency
+func (f *Forwarder) SetRecorder(recorder *telemetry.Recorder) {
+	recorder.Instrument(f.client, "forward")
+}
+
+// Start forwards buffered readings periodically until the context is cancelled
+func (f *Forwarder) Start(ctx context.Context) {
+	ticker := time.NewTicker(f.interval)
+	defer ticker.Stop()
+
+	f.logger.Info("readings forwarder started",
+		zap.String("url", f.url),
+		zap.Duration("interval", f.interval),
+	)
+
+	for {
+		select {
+		case <-ctx.Done():
+			f.logger.Info("readings forwarder stopping")
+			return
+		case <-ticker.C:
+			f.flush(ctx)
+		case <-f.trigger:
+			f.logger.Info("out-of-cycle forward requested", zap.Int("buffered", f.buffer.Size()))
+			f.flush(ctx)
+		}
+	}
+}
+
+// Trigger requests an immediate forward of the buffered readings; a pending request covers later ones
+func (f *Forwarder) Trigger() {
+	select {
+	case f.trigger <- struct{}{}:
+	default:
+	}
+}
+
+// flush forwards all buffered readings in batches, re-adding them to the buffer on failure
+func (f *Forwarder) flush(ctx context.Context) {
+	readings := f.buffer.GetAllAndClear()
+	for start := 0; start < len(readings); start += f.batchSize {
+		end := min(start+f.batchSize, len(readings))
+		if err := f.Forward(ctx, readings[start:end]); err != nil {
+			f.logger.Error("failed to forward readings, re-adding remaining readings to buffer",
+				zap.Error(err),
+				zap.Int("failed_readings", len(readings)-start),
+			)
+			f.buffer.AddMultiple(readings[start:])
+			return
+		}
+	}
+
+	if len(readings) > 0 {
+		f.logger.Debug("forwarded readings", zap.Int("reading_count", len(readings)))
+	}
+}
+
+// Forward sends readings to the main instance in a single request
+func (f *Forwarder) Forward(ctx context.Context, readings []*buffer.Reading) error {
+	if len(readings) == 0 {
+		return nil
+	}
+
+	data, err := readingpb.MarshalBatch(readings)
+	if err != nil {
+		return fmt.Errorf("failed to encode readings: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, f.url, bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", ContentType)
+	if f.token != "" {
+		req.Header.Set("Authorization", "Bearer "+f.token)
+	}
+
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		body, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("received non-2xx status code: %d, body: %s", resp.StatusCode, string(body))
+	}
+	return nil
+}
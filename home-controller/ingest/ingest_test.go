@@ -0,0 +1,223 @@
+package ingest
+
+import (
+	"bytes"
+	"context"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gogo/protobuf/proto"
+	"github.com/golang/snappy"
+	"github.com/mjasion/balena-home/thermostats/admin"
+	"github.com/mjasion/balena-home/thermostats/buffer"
+	"github.com/prometheus/prometheus/prompb"
+	"go.uber.org/zap"
+)
+
+func newTestServer(buf *buffer.RingBuffer, token string) *httptest.Server {
+	logger := zap.NewNop()
+	server := admin.New(":0", logger)
+	NewReceiver(buf, token, logger).RegisterHandlers(server)
+	return httptest.NewServer(server)
+}
+
+func testReadings() []*buffer.Reading {
+	return []*buffer.Reading{
+		{Type: buffer.ReadingTypeBLE, BLE: &buffer.SensorReading{Timestamp: time.Now(), MAC: "A4:C1:38:00:00:09", SensorName: "Garage", SensorID: 9, TemperatureCelsius: 8.5}},
+		{Type: buffer.ReadingTypePower, Power: &buffer.PowerReading{Timestamp: time.Now(), Value: 300}},
+	}
+}
+
+func TestForwarder_DeliversToReceiver(t *testing.T) {
+	logger := zap.NewNop()
+	mainBuffer := buffer.New(100, logger)
+	server := newTestServer(mainBuffer, "secret")
+	defer server.Close()
+
+	satelliteBuffer := buffer.New(100, logger)
+	for _, reading := range testReadings() {
+		satelliteBuffer.Add(reading)
+	}
+	forwarder := NewForwarder(server.URL+"/api/readings", "secret", satelliteBuffer, 10, 1, logger)
+	forwarder.flush(context.Background())
+
+	if satelliteBuffer.Size() != 0 {
+		t.Errorf("Expected satellite buffer to be empty, got %d", satelliteBuffer.Size())
+	}
+	received := mainBuffer.GetAll()
+	if len(received) != 2 {
+		t.Fatalf("Expected 2 readings on main instance, got %d", len(received))
+	}
+	if received[0].BLE == nil || received[0].BLE.SensorName != "Garage" || received[1].Power == nil {
+		t.Errorf("Unexpected forwarded readings: %+v %+v", received[0], received[1])
+	}
+}
+
+func TestForwarder_KeepsReadingsOnFailure(t *testing.T) {
+	logger := zap.NewNop()
+	server := newTestServer(buffer.New(100, logger), "secret")
+	defer server.Close()
+
+	satelliteBuffer := buffer.New(100, logger)
+	for _, reading := range testReadings() {
+		satelliteBuffer.Add(reading)
+	}
+	forwarder := NewForwarder(server.URL+"/api/readings", "wrong", satelliteBuffer, 10, 100, logger)
+	forwarder.flush(context.Background())
+
+	if satelliteBuffer.Size() != 2 {
+		t.Errorf("Expected readings to be re-added after failure, got %d", satelliteBuffer.Size())
+	}
+}
+
+func TestReceiver_RejectsInvalidRequests(t *testing.T) {
+	server := newTestServer(buffer.New(100, zap.NewNop()), "")
+	defer server.Close()
+
+	tests := []struct {
+		name        string
+		contentType string
+		body        []byte
+		status      int
+	}{
+		{"wrong content type", "application/json", []byte("{}"), http.StatusUnsupportedMediaType},
+		{"invalid protobuf", ContentType, []byte{0xff}, http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := http.Post(server.URL+"/api/readings", tt.contentType, bytes.NewReader(tt.body))
+			if err != nil {
+				t.Fatalf("Expected no error, got: %v", err)
+			}
+			resp.Body.Close()
+			if resp.StatusCode != tt.status {
+				t.Errorf("Expected status %d, got %d", tt.status, resp.StatusCode)
+			}
+		})
+	}
+
+	resp, err := http.Post(server.URL+"/api/readings", ContentType, strings.NewReader(""))
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("Expected empty batch to be accepted, got %d", resp.StatusCode)
+	}
+}
+
+func TestRemoteWriteReceiver(t *testing.T) {
+	buf := buffer.New(100, zap.NewNop())
+	server := admin.New(":0", zap.NewNop())
+	NewRemoteWriteReceiver(buf, "secret", zap.NewNop()).RegisterHandlers(server)
+	httpServer := httptest.NewServer(server)
+	defer httpServer.Close()
+
+	now := time.Now().Truncate(time.Millisecond)
+	data, err := proto.Marshal(&prompb.WriteRequest{Timeseries: []prompb.TimeSeries{{
+		Labels: []prompb.Label{{Name: "__name__", Value: "esp32_uptime_seconds"}, {Name: "instance", Value: "esp32-garage"}},
+		Samples: []prompb.Sample{
+			{Value: 3600, Timestamp: now.UnixMilli()},
+			{Value: math.NaN(), Timestamp: now.UnixMilli() + 1000},
+		},
+	}}})
+	if err != nil {
+		t.Fatalf("Failed to marshal write request: %v", err)
+	}
+	body := snappy.Encode(nil, data)
+
+	post := func(token string) int {
+		req, _ := http.NewRequest(http.MethodPost, httpServer.URL+"/api/v1/write", bytes.NewReader(body))
+		req.Header.Set("Content-Encoding", "snappy")
+		req.Header.Set("Authorization", "Bearer "+token)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("Expected no error, got: %v", err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	if status := post("wrong"); status != http.StatusUnauthorized {
+		t.Errorf("Expected status 401, got %d", status)
+	}
+	if status := post("secret"); status != http.StatusNoContent {
+		t.Fatalf("Expected status 204, got %d", status)
+	}
+
+	readings := buf.GetAll()
+	if len(readings) != 1 {
+		t.Fatalf("Expected the stale marker to be skipped and 1 reading buffered, got %d", len(readings))
+	}
+	remote := readings[0].Remote
+	if readings[0].Type != buffer.ReadingTypeRemote || remote.Metric != "esp32_uptime_seconds" ||
+		remote.Labels["instance"] != "esp32-garage" || remote.Value != 3600 || !remote.Timestamp.Equal(now) {
+		t.Errorf("Unexpected reading %+v", remote)
+	}
+}
+
+func TestPushgatewayReceiver(t *testing.T) {
+	buf := buffer.New(100, zap.NewNop())
+	server := admin.New(":0", zap.NewNop())
+	receiver := NewPushgatewayReceiver(buf, "", zap.NewNop())
+	now := time.Date(2026, 3, 10, 3, 0, 0, 0, time.UTC)
+	receiver.now = func() time.Time { return now }
+	receiver.RegisterHandlers(server)
+	httpServer := httptest.NewServer(server)
+	defer httpServer.Close()
+
+	push := func(method, path, body string) int {
+		req, _ := http.NewRequest(method, httpServer.URL+path, strings.NewReader(body))
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("Expected no error, got: %v", err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	body := `# TYPE backup_duration_seconds gauge
+backup_duration_seconds{target="nas",job="ignored"} 42.5
+# TYPE backup_success gauge
+backup_success 1
+`
+	if status := push(http.MethodPut, "/metrics/job/backup/host@base64/cGkvZ2FyYWdl", body); status != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", status)
+	}
+
+	values := make(map[string]*buffer.RemoteReading)
+	for _, reading := range buf.GetAll() {
+		values[reading.Remote.Metric] = reading.Remote
+	}
+	if len(values) != 3 {
+		t.Fatalf("Expected 2 samples and push_time_seconds, got %v", values)
+	}
+	duration := values["backup_duration_seconds"]
+	if duration == nil || duration.Value != 42.5 || duration.Labels["job"] != "backup" ||
+		duration.Labels["host"] != "pi/garage" || duration.Labels["target"] != "nas" || !duration.Timestamp.Equal(now) {
+		t.Errorf("Unexpected duration reading %+v", duration)
+	}
+	if pushTime := values["push_time_seconds"]; pushTime == nil || pushTime.Value != float64(now.Unix()) {
+		t.Errorf("Unexpected push time reading %+v", pushTime)
+	}
+
+	tests := []struct {
+		path   string
+		body   string
+		status int
+	}{
+		{"/metrics/job/backup/host", body, http.StatusBadRequest},
+		{"/metrics/job/backup/__name__/x", body, http.StatusBadRequest},
+		{"/metrics/job/backup", "backup_success{", http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		if status := push(http.MethodPost, tt.path, tt.body); status != tt.status {
+			t.Errorf("%s: expected status %d, got %d", tt.path, tt.status, status)
+		}
+	}
+}
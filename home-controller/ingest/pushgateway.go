@@ -0,0 +1,159 @@
+package ingest
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/mjasion/balena-home/thermostats/admin"
+	"github.com/mjasion/balena-home/thermostats/buffer"
+	"github.com/prometheus/common/expfmt"
+	"github.com/prometheus/common/model"
+	"go.uber.org/zap"
+)
+
+// maxPushBytes limits the size of a pushed metrics body
+const maxPushBytes = 1 << 20
+
+// PushgatewayReceiver accepts metrics pushed the way Pushgateway clients do, so cron scripts can
+// report with curl instead of a remote_write client; pushed samples are forwarded once, not kept
+type PushgatewayReceiver struct {
+	buffer *buffer.RingBuffer
+	token  string
+	logger *zap.Logger
+	now    func() time.Time
+}
+
+// NewPushgatewayReceiver creates a receiver; an empty token accepts unauthenticated requests
+func NewPushgatewayReceiver(buf *buffer.RingBuffer, token string, logger *zap.Logger) *PushgatewayReceiver {
+	return &PushgatewayReceiver{
+		buffer: buf,
+		token:  token,
+		logger: logger,
+		now:    time.Now,
+	}
+}
+
+// RegisterHandlers registers the Pushgateway push endpoints on the admin server
+func (p *PushgatewayReceiver) RegisterHandlers(server *admin.Server) {
+	server.HandleFunc("PUT /metrics/job/{grouping...}", p.handlePush)
+	server.HandleFunc("POST /metrics/job/{grouping...}", p.handlePush)
+}
+
+// handlePush handles PUT and POST /metrics/job/<job>{/<label>/<value>} with a text or delimited
+// protobuf exposition body. Each sample becomes a remote reading labelled with the grouping key,
+// plus push_time_seconds for the group as Pushgateway adds it
+func (p *PushgatewayReceiver) handlePush(w http.ResponseWriter, req *http.Request) {
+	if !admin.BearerAuthorized(req, p.token) {
+		http.Error(w, "invalid or missing bearer token", http.StatusUnauthorized)
+		return
+	}
+
+	grouping, err := groupingKey(req.PathValue("grouping"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	now := p.now()
+	decoder := &expfmt.SampleDecoder{
+		Dec:  expfmt.NewDecoder(http.MaxBytesReader(w, req.Body, maxPushBytes), expfmt.ResponseFormat(req.Header)),
+		Opts: &expfmt.DecodeOptions{Timestamp: model.TimeFromUnixNano(now.UnixNano())},
+	}
+	var readings []*buffer.Reading
+	for {
+		var samples model.Vector
+		if err := decoder.Decode(&samples); err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			p.logger.Warn("rejected pushed metrics",
+				zap.String("remote_addr", req.RemoteAddr),
+				zap.String("job", grouping["job"]),
+				zap.Error(err),
+			)
+			http.Error(w, fmt.Sprintf("invalid metrics: %v", err), http.StatusBadRequest)
+			return
+		}
+		for _, sample := range samples {
+			if math.IsNaN(float64(sample.Value)) {
+				continue
+			}
+			labels := make(map[string]string, len(sample.Metric)+len(grouping))
+			for name, value := range sample.Metric {
+				labels[string(name)] = string(value)
+			}
+			metric := labels[model.MetricNameLabel]
+			delete(labels, model.MetricNameLabel)
+			// The grouping key wins over pushed labels, as in Pushgateway
+			for name, value := range grouping {
+				labels[name] = value
+			}
+			readings = append(readings, remoteReading(now, metric, labels, float64(sample.Value)))
+		}
+	}
+	readings = append(readings, remoteReading(now, "push_time_seconds", grouping, float64(now.UnixNano())/1e9))
+
+	// Add one by one so buffer listeners such as expression rules see pushed samples
+	for _, reading := range readings {
+		if err := p.buffer.Add(reading); err != nil {
+			http.Error(w, "shutting down, retry later", http.StatusServiceUnavailable)
+			return
+		}
+	}
+
+	p.logger.Debug("received pushed metrics",
+		zap.String("remote_addr", req.RemoteAddr),
+		zap.String("job", grouping["job"]),
+		zap.Int("sample_count", len(readings)),
+	)
+	w.WriteHeader(http.StatusOK)
+}
+
+// remoteReading creates a remote reading of one sample
+func remoteReading(ts time.Time, metric string, labels map[string]string, value float64) *buffer.Reading {
+	return &buffer.Reading{
+		Type: buffer.ReadingTypeRemote,
+		Remote: &buffer.RemoteReading{
+			Timestamp: ts,
+			Metric:    metric,
+			Labels:    labels,
+			Value:     value,
+		},
+	}
+}
+
+// groupingKey parses <job>{/<label>/<value>} from the push path; a label name suffixed with
+// @base64 carries a base64url-encoded value, for values containing slashes
+func groupingKey(path string) (map[string]string, error) {
+	parts := strings.Split(strings.TrimSuffix(path, "/"), "/")
+	if len(parts)%2 != 1 {
+		return nil, fmt.Errorf("grouping key must be label/value pairs after the job")
+	}
+
+	grouping := make(map[string]string, len(parts)/2+1)
+	pairs := append([]string{"job"}, parts...)
+	for i := 0; i < len(pairs); i += 2 {
+		name, value := pairs[i], pairs[i+1]
+		if encoded, ok := strings.CutSuffix(name, "@base64"); ok {
+			decoded, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(value, "="))
+			if err != nil {
+				return nil, fmt.Errorf("invalid base64 value for %s: %w", encoded, err)
+			}
+			name, value = encoded, string(decoded)
+		}
+		if !model.LabelName(name).IsValidLegacy() || strings.HasPrefix(name, "__") {
+			return nil, fmt.Errorf("invalid label name %q", name)
+		}
+		if value == "" && name == "job" {
+			return nil, fmt.Errorf("job name is required")
+		}
+		grouping[name] = value
+	}
+	return grouping, nil
+}
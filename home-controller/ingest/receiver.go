@@ -0,0 +1,85 @@
+package ingest
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/mjasion/balena-home/thermostats/admin"
+	"github.com/mjasion/balena-home/thermostats/buffer"
+	"github.com/mjasion/balena-home/thermostats/readingpb"
+	"go.uber.org/zap"
+)
+
+// ContentType is the media type of forwarded reading batches
+const ContentType = "application/x-protobuf"
+
+// maxBatchBytes limits the size of a forwarded batch
+const maxBatchBytes = 16 << 20
+
+// Receiver accepts readings forwarded by satellite instances and adds them to the buffer
+type Receiver struct {
+	buffer *buffer.RingBuffer
+	token  string
+	logger *zap.Logger
+}
+
+// NewReceiver creates a receiver; an empty token accepts unauthenticated requests
+func NewReceiver(buf *buffer.RingBuffer, token string, logger *zap.Logger) *Receiver {
+	return &Receiver{
+		buffer: buf,
+		token:  token,
+		logger: logger,
+	}
+}
+
+// RegisterHandlers registers the ingestion endpoint on the admin server
+func (r *Receiver) RegisterHandlers(server *admin.Server) {
+	server.HandleFunc("POST /api/readings", r.handleReadings)
+}
+
+// handleReadings handles POST /api/readings with a readingpb.ReadingBatch body
+func (r *Receiver) handleReadings(w http.ResponseWriter, req *http.Request) {
+	if !admin.BearerAuthorized(req, r.token) {
+		admin.WriteError(w, http.StatusUnauthorized, "invalid or missing bearer token")
+		return
+	}
+	if contentType := req.Header.Get("Content-Type"); contentType != ContentType {
+		admin.WriteError(w, http.StatusUnsupportedMediaType, fmt.Sprintf("content type must be %s", ContentType))
+		return
+	}
+
+	data, err := io.ReadAll(http.MaxBytesReader(w, req.Body, maxBatchBytes))
+	if err != nil {
+		admin.WriteError(w, http.StatusRequestEntityTooLarge, "batch too large")
+		return
+	}
+
+	readings, err := readingpb.UnmarshalBatch(data)
+	if err != nil {
+		r.logger.Warn("rejected forwarded readings",
+			zap.String("remote_addr", req.RemoteAddr),
+			zap.Error(err),
+		)
+		admin.WriteError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	// Add one by one so buffer listeners such as expression rules see forwarded readings
+	for _, reading := range readings {
+		if err := r.buffer.Add(reading); err != nil {
+			// Shutting down; the satellite keeps the readings and retries
+			admin.WriteError(w, http.StatusServiceUnavailable, "shutting down, retry later")
+			return
+		}
+	}
+
+	r.logger.Debug("received forwarded readings",
+		zap.String("remote_addr", req.RemoteAddr),
+		zap.Int("reading_count", len(readings)),
+	)
+	admin.WriteJSON(w, http.StatusOK, admin.Response{
+		Success: true,
+		Message: fmt.Sprintf("Accepted %d readings.", len(readings)),
+	})
+}
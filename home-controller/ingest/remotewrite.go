@@ -0,0 +1,125 @@
+package ingest
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"time"
+
+	"github.com/gogo/protobuf/proto"
+	"github.com/golang/snappy"
+	"github.com/mjasion/balena-home/thermostats/admin"
+	"github.com/mjasion/balena-home/thermostats/buffer"
+	"github.com/prometheus/prometheus/prompb"
+	"go.uber.org/zap"
+)
+
+// RemoteWriteReceiver accepts Prometheus remote_write requests from other devices, such as an
+// ESP32 or vmagent on another Pi, and merges their samples into this instance's push stream
+type RemoteWriteReceiver struct {
+	buffer *buffer.RingBuffer
+	token  string
+	logger *zap.Logger
+}
+
+// NewRemoteWriteReceiver creates a receiver; an empty token accepts unauthenticated requests
+func NewRemoteWriteReceiver(buf *buffer.RingBuffer, token string, logger *zap.Logger) *RemoteWriteReceiver {
+	return &RemoteWriteReceiver{
+		buffer: buf,
+		token:  token,
+		logger: logger,
+	}
+}
+
+// RegisterHandlers registers the remote_write endpoint on the admin server
+func (r *RemoteWriteReceiver) RegisterHandlers(server *admin.Server) {
+	server.HandleFunc("POST /api/v1/write", r.handleWrite)
+}
+
+// handleWrite handles POST /api/v1/write with a snappy-compressed prompb.WriteRequest body
+// Histograms and stale markers are skipped; float samples become remote readings
+func (r *RemoteWriteReceiver) handleWrite(w http.ResponseWriter, req *http.Request) {
+	if !admin.BearerAuthorized(req, r.token) {
+		http.Error(w, "invalid or missing bearer token", http.StatusUnauthorized)
+		return
+	}
+
+	compressed, err := io.ReadAll(http.MaxBytesReader(w, req.Body, maxBatchBytes))
+	if err != nil {
+		http.Error(w, "request too large", http.StatusRequestEntityTooLarge)
+		return
+	}
+	data, err := snappy.Decode(nil, compressed)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("invalid snappy body: %v", err), http.StatusBadRequest)
+		return
+	}
+	var writeReq prompb.WriteRequest
+	if err := proto.Unmarshal(data, &writeReq); err != nil {
+		r.logger.Warn("rejected remote_write request",
+			zap.String("remote_addr", req.RemoteAddr),
+			zap.Error(err),
+		)
+		http.Error(w, fmt.Sprintf("invalid write request: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	readings, err := remoteReadings(&writeReq)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Add one by one so buffer listeners such as expression rules see received samples
+	for _, reading := range readings {
+		if err := r.buffer.Add(reading); err != nil {
+			// Shutting down; a 5xx makes the sender keep the samples and retry
+			http.Error(w, "shutting down, retry later", http.StatusServiceUnavailable)
+			return
+		}
+	}
+
+	r.logger.Debug("received remote_write samples",
+		zap.String("remote_addr", req.RemoteAddr),
+		zap.Int("series_count", len(writeReq.Timeseries)),
+		zap.Int("sample_count", len(readings)),
+	)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// remoteReadings converts the float samples of a write request to remote readings
+func remoteReadings(writeReq *prompb.WriteRequest) ([]*buffer.Reading, error) {
+	var readings []*buffer.Reading
+	for _, ts := range writeReq.Timeseries {
+		var metric string
+		labels := make(map[string]string, len(ts.Labels))
+		for _, label := range ts.Labels {
+			if label.Name == "__name__" {
+				metric = label.Value
+				continue
+			}
+			labels[label.Name] = label.Value
+		}
+		if metric == "" {
+			return nil, fmt.Errorf("series without __name__ label")
+		}
+
+		for _, sample := range ts.Samples {
+			// Stale markers are NaN; the hub's own push decides staleness
+			if math.IsNaN(sample.Value) {
+				continue
+			}
+			readings = append(readings, &buffer.Reading{
+				Type: buffer.ReadingTypeRemote,
+				Remote: &buffer.RemoteReading{
+					Timestamp: time.UnixMilli(sample.Timestamp),
+					Metric:    metric,
+					Labels:    labels,
+					Value:     sample.Value,
+				},
+			})
+		}
+	}
+	return readings, nil
+}
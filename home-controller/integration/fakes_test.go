@@ -0,0 +1,200 @@
+package integration
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/golang/snappy"
+	"github.com/mjasion/balena-home/thermostats/bleproxy"
+	"github.com/prometheus/prometheus/prompb"
+)
+
+// fakeRemoteWrite is a remote_write receiver that keeps every decoded WriteRequest
+type fakeRemoteWrite struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	requests []*prompb.WriteRequest
+	attempts int // Requests received, including failed ones
+	failNext int // Requests still to answer with 503
+}
+
+func newFakeRemoteWrite(t *testing.T) *fakeRemoteWrite {
+	f := &fakeRemoteWrite{}
+	f.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		compressed, err := io.ReadAll(r.Body)
+		if err != nil {
+			t.Errorf("Failed to read remote_write body: %v", err)
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		data, err := snappy.Decode(nil, compressed)
+		if err != nil {
+			t.Errorf("Failed to decompress remote_write body: %v", err)
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		var writeReq prompb.WriteRequest
+		if err := writeReq.Unmarshal(data); err != nil {
+			t.Errorf("Failed to decode WriteRequest: %v", err)
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		f.attempts++
+		if f.failNext > 0 {
+			f.failNext--
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		f.requests = append(f.requests, &writeReq)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	t.Cleanup(f.Close)
+	return f
+}
+
+// fail answers the next n requests with 503 Service Unavailable
+func (f *fakeRemoteWrite) fail(n int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.failNext = n
+}
+
+// counts returns the number of requests received and of those accepted
+func (f *fakeRemoteWrite) counts() (attempts, accepted int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.attempts, len(f.requests)
+}
+
+// render formats all received samples as sorted "series value" lines
+// Timestamps are left out so the output is stable across runs
+func (f *fakeRemoteWrite) render() string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var lines []string
+	for _, writeReq := range f.requests {
+		for _, ts := range writeReq.Timeseries {
+			var name string
+			var labels []string
+			for _, label := range ts.Labels {
+				if label.Name == "__name__" {
+					name = label.Value
+					continue
+				}
+				labels = append(labels, fmt.Sprintf("%s=%q", label.Name, label.Value))
+			}
+			sort.Strings(labels)
+			for _, sample := range ts.Samples {
+				lines = append(lines, fmt.Sprintf("%s{%s} %g", name, strings.Join(labels, ","), sample.Value))
+			}
+		}
+	}
+	sort.Strings(lines)
+	return strings.Join(lines, "\n") + "\n"
+}
+
+// fakeNetatmo serves the token, homesdata and homestatus endpoints for one home
+type fakeNetatmo struct {
+	*httptest.Server
+}
+
+func newFakeNetatmo(t *testing.T) *fakeNetatmo {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /oauth2/token", func(w http.ResponseWriter, r *http.Request) {
+		writeFakeJSON(t, w, map[string]interface{}{
+			"access_token":  "access",
+			"refresh_token": "refresh",
+			"expires_in":    10800,
+		})
+	})
+	mux.HandleFunc("GET /api/homesdata", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer access" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		writeFakeJSON(t, w, map[string]interface{}{
+			"status": "ok",
+			"body": map[string]interface{}{
+				"homes": []map[string]interface{}{{
+					"id":   "home-1",
+					"name": "Home",
+					"rooms": []map[string]interface{}{
+						{"id": "room-1", "name": "Bedroom"},
+						{"id": "room-2", "name": "Living Room"},
+					},
+				}},
+			},
+		})
+	})
+	mux.HandleFunc("GET /api/homestatus", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("home_id") != "home-1" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		writeFakeJSON(t, w, map[string]interface{}{
+			"status": "ok",
+			"body": map[string]interface{}{
+				"home": map[string]interface{}{
+					"id": "home-1",
+					"rooms": []map[string]interface{}{
+						{"id": "room-1", "reachable": true, "therm_measured_temperature": 20.5, "therm_setpoint_temperature": 21, "therm_setpoint_mode": "schedule", "heating_power_request": 40},
+						{"id": "room-2", "reachable": true, "therm_measured_temperature": 21.5, "therm_setpoint_temperature": 20, "therm_setpoint_mode": "schedule"},
+					},
+				},
+			},
+		})
+	})
+
+	f := &fakeNetatmo{Server: httptest.NewServer(mux)}
+	t.Cleanup(f.Close)
+	return f
+}
+
+func writeFakeJSON(t *testing.T, w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		t.Errorf("Failed to encode fake response: %v", err)
+	}
+}
+
+// bleSimulator publishes ATC_MiThermometer advertisements through the BLE proxy ingestion path
+type bleSimulator struct {
+	proxy *bleproxy.Proxy
+}
+
+// advertise publishes a single advertisement as an ESP32 proxy would
+func (s *bleSimulator) advertise(mac string, temperature float64, humidity, battery, voltageMV, frame int) {
+	var address [6]byte
+	raw, _ := hex.DecodeString(strings.ReplaceAll(mac, ":", ""))
+	copy(address[:], raw)
+
+	data := make([]byte, 13)
+	copy(data[0:6], address[:])
+	binary.BigEndian.PutUint16(data[6:8], uint16(int16(math.Round(temperature*10))))
+	data[8] = byte(humidity)
+	data[9] = byte(battery)
+	binary.LittleEndian.PutUint16(data[10:12], uint16(voltageMV))
+	data[12] = byte(frame)
+
+	payload, _ := json.Marshal(bleproxy.Advertisement{
+		Address:     mac,
+		RSSI:        -70,
+		ServiceData: map[string]string{"181a": hex.EncodeToString(data)},
+	})
+	s.proxy.HandleMessage("ble_proxy/simulator", payload)
+}
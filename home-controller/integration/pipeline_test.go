@@ -0,0 +1,112 @@
+package integration
+
+import (
+	"context"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/mjasion/balena-home/thermostats/automation"
+	"github.com/mjasion/balena-home/thermostats/bleproxy"
+	"github.com/mjasion/balena-home/thermostats/buffer"
+	"github.com/mjasion/balena-home/thermostats/fusion"
+	"github.com/mjasion/balena-home/thermostats/metrics"
+	"github.com/mjasion/balena-home/thermostats/netatmo"
+	"go.uber.org/zap"
+)
+
+var update = flag.Bool("update", false, "rewrite golden files with the current output")
+
+// assertGolden compares output with testdata/<name>.golden
+func assertGolden(t *testing.T, name, output string) {
+	t.Helper()
+	path := filepath.Join("testdata", name+".golden")
+	if *update {
+		if err := os.WriteFile(path, []byte(output), 0o644); err != nil {
+			t.Fatalf("Failed to update golden file: %v", err)
+		}
+		return
+	}
+
+	expected, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read golden file (run with -update to create it): %v", err)
+	}
+	if string(expected) != output {
+		t.Errorf("Output does not match %s (run with -update to accept):\nexpected:\n%s\ngot:\n%s", path, expected, output)
+	}
+}
+
+// waitFor polls until condition holds or the deadline passes
+func waitFor(t *testing.T, condition func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !condition() {
+		if time.Now().After(deadline) {
+			t.Fatal("Timed out waiting for condition")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestPipeline_BLEAndNetatmo(t *testing.T) {
+	logger := zap.NewNop()
+	remoteWrite := newFakeRemoteWrite(t)
+	netatmoAPI := newFakeNetatmo(t)
+
+	buf := buffer.New(1000, logger)
+
+	// Room fusion prefers the BLE sensor and would fall back to Netatmo
+	fuser := fusion.New([]fusion.Room{{
+		Name: "bedroom",
+		Primary: fusion.Source{Selector: automation.Selector{
+			Metric: "ble_temperature_celsius",
+			Labels: map[string]string{"sensor_name": "Bedroom"},
+		}},
+		Secondary: fusion.Source{Selector: automation.Selector{
+			Metric: "netatmo_measured_temperature_celsius",
+			Labels: map[string]string{"room_name": "Bedroom"},
+		}},
+		StaleAfter: 10 * time.Minute,
+	}}, buf, logger)
+	buf.AddListener(fuser.Observe)
+
+	// BLE readings from the simulator
+	proxy := bleproxy.New([]bleproxy.SensorConfig{
+		{Name: "Bedroom", ID: 1, MACAddress: "A4:C1:38:00:00:01"},
+		{Name: "Bathroom", ID: 2, MACAddress: "A4:C1:38:00:00:02"},
+	}, "ble_proxy", buf, logger)
+	simulator := &bleSimulator{proxy: proxy}
+	simulator.advertise("A4:C1:38:00:00:01", 21.3, 45, 90, 2950, 1)
+	simulator.advertise("A4:C1:38:00:00:02", -0.5, 80, 55, 2700, 7)
+
+	// Netatmo readings from the fake API
+	fetcher := netatmo.NewFetcher("client", "secret", "refresh")
+	fetcher.SetBaseURL(netatmoAPI.URL)
+	poller := netatmo.NewPoller(fetcher, buf, 3600, logger)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		poller.Start(ctx)
+	}()
+	waitFor(t, func() bool {
+		for _, reading := range buf.GetAll() {
+			if reading.Thermostat != nil {
+				return true
+			}
+		}
+		return false
+	})
+	cancel()
+	<-done
+
+	pusher := metrics.New(remoteWrite.URL, "user", "password", buf, 15, 1000, logger)
+	if err := pusher.Push(context.Background(), buf.GetAllAndClear()); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	assertGolden(t, "pipeline", remoteWrite.render())
+}
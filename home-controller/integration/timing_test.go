@@ -0,0 +1,102 @@
+package integration
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/mjasion/balena-home/thermostats/buffer"
+	"github.com/mjasion/balena-home/thermostats/clock"
+	"github.com/mjasion/balena-home/thermostats/metrics"
+	"github.com/mjasion/balena-home/thermostats/netatmo"
+	"go.uber.org/zap"
+)
+
+// hasThermostat reports whether the buffer holds a Netatmo reading
+func hasThermostat(buf *buffer.RingBuffer) bool {
+	for _, reading := range buf.GetAll() {
+		if reading.Thermostat != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// expectCounts fails unless the fake remote_write received attempts requests and accepted accepted
+func expectCounts(t *testing.T, remoteWrite *fakeRemoteWrite, attempts, accepted int) {
+	t.Helper()
+	gotAttempts, gotAccepted := remoteWrite.counts()
+	if gotAttempts != attempts || gotAccepted != accepted {
+		t.Fatalf("Expected %d push attempts with %d accepted, got %d with %d accepted", attempts, accepted, gotAttempts, gotAccepted)
+	}
+}
+
+// The poller and the pusher run on a fake clock: aligned pushes, retries and the backoff after a
+// failed cycle happen exactly when the clock reaches them, without sleeping
+func TestTiming_AlignedPushesRetriesAndBackoff(t *testing.T) {
+	logger := zap.NewNop()
+	remoteWrite := newFakeRemoteWrite(t)
+	netatmoAPI := newFakeNetatmo(t)
+	fake := clock.NewFake(time.Date(2026, 3, 10, 12, 0, 7, 0, time.UTC))
+
+	buf := buffer.New(1000, logger)
+	buf.SetClock(fake)
+
+	fetcher := netatmo.NewFetcher("client", "secret", "refresh")
+	fetcher.SetBaseURL(netatmoAPI.URL)
+	poller := netatmo.NewPoller(fetcher, buf, 60, logger)
+	poller.SetClock(fake)
+
+	pusher := metrics.New(remoteWrite.URL, "user", "password", buf, 15, 1000, logger)
+	pusher.SetAligned(true)
+	pusher.SetMaxBackoff(time.Minute)
+	pusher.SetClock(fake)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go poller.Start(ctx)
+	go pusher.Start(ctx)
+
+	// The poller fetches on start and ticks at 12:01:07; the first push is due at 12:00:15
+	waitFor(t, func() bool { return hasThermostat(buf) })
+	fake.BlockUntil(2)
+
+	fake.Advance(7 * time.Second)
+	expectCounts(t, remoteWrite, 0, 0)
+	fake.Advance(time.Second)
+	fake.BlockUntil(2) // Back to waiting for 12:00:30
+	expectCounts(t, remoteWrite, 1, 1)
+
+	// A failing cycle retries after 1s and 2s and then backs off to the next 30s boundary
+	remoteWrite.fail(3)
+	buf.Add(&buffer.Reading{Type: buffer.ReadingTypePower, Power: &buffer.PowerReading{Timestamp: fake.Now(), SensorID: 1, Value: 450}})
+	fake.Advance(15 * time.Second)
+	fake.BlockUntil(2) // Retry timer
+	expectCounts(t, remoteWrite, 2, 1)
+	fake.Advance(time.Second)
+	fake.BlockUntil(2)
+	expectCounts(t, remoteWrite, 3, 1)
+	fake.Advance(2 * time.Second)
+	fake.BlockUntil(2) // Back to waiting for 12:01:00
+	expectCounts(t, remoteWrite, 4, 1)
+	if buf.Size() != 1 {
+		t.Fatalf("Expected the reading to be kept after the failed cycle, got %d readings", buf.Size())
+	}
+
+	fake.Advance(26 * time.Second)
+	expectCounts(t, remoteWrite, 4, 1)
+	fake.Advance(time.Second)
+	fake.BlockUntil(2)
+	expectCounts(t, remoteWrite, 5, 2)
+	if buf.Size() != 0 {
+		t.Errorf("Expected the buffer to be drained, got %d readings", buf.Size())
+	}
+
+	// The poller's next fetch is due at 12:01:07
+	fake.Advance(6 * time.Second)
+	if hasThermostat(buf) {
+		t.Fatal("Expected no fetch before the poll interval elapsed")
+	}
+	fake.Advance(time.Second)
+	waitFor(t, func() bool { return hasThermostat(buf) })
+}
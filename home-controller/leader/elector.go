@@ -0,0 +1,172 @@
+package leader
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/mjasion/balena-home/thermostats/events"
+	"github.com/mjasion/balena-home/thermostats/httpclient"
+	"go.uber.org/zap"
+)
+
+// Elector keeps this instance's claim on the lease and reports whether it is the leader
+// Only the leader pushes; a standby keeps buffering so it can take over with recent readings
+type Elector struct {
+	id       string
+	ttl      time.Duration
+	logger   *zap.Logger
+	eventLog *events.Log
+	leader   atomic.Bool
+
+	acquire func(ctx context.Context) (bool, string, error)
+	release func(ctx context.Context) error
+}
+
+// NewLocalElector creates an elector for the instance hosting the lease
+func NewLocalElector(id string, lease *Lease, ttl time.Duration, logger *zap.Logger) *Elector {
+	e := &Elector{id: id, ttl: ttl, logger: logger}
+	e.acquire = func(ctx context.Context) (bool, string, error) {
+		granted, holder := lease.TryAcquire(id, ttl)
+		return granted, holder, nil
+	}
+	e.release = func(ctx context.Context) error {
+		lease.Release(id)
+		return nil
+	}
+	return e
+}
+
+// NewRemoteElector creates an elector claiming the lease hosted by another instance,
+// e.g. http://pi-1:8080/api/lease
+// When the lease host is unreachable this instance leads, since the host is likely the one that failed
+func NewRemoteElector(id, url, token string, ttl time.Duration, logger *zap.Logger) *Elector {
+	e := &Elector{id: id, ttl: ttl, logger: logger}
+	client := httpclient.NewDefault(httpclient.Config{Timeout: ttl / 3})
+	e.acquire = func(ctx context.Context) (bool, string, error) {
+		resp, err := leaseRequestDo(ctx, client, http.MethodPost, url, token, leaseRequest{Holder: id, TTLSeconds: int(ttl.Seconds())})
+		if err != nil {
+			return true, id, err
+		}
+		defer resp.Body.Close()
+
+		var body struct {
+			Data struct {
+				Holder string `json:"holder"`
+			} `json:"data"`
+		}
+		json.NewDecoder(resp.Body).Decode(&body)
+
+		switch resp.StatusCode {
+		case http.StatusOK:
+			return true, id, nil
+		case http.StatusConflict:
+			return false, body.Data.Holder, nil
+		}
+		return true, id, fmt.Errorf("lease request failed with status %d", resp.StatusCode)
+	}
+	e.release = func(ctx context.Context) error {
+		resp, err := leaseRequestDo(ctx, client, http.MethodDelete, url, token, leaseRequest{Holder: id})
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return fmt.Errorf("lease release failed with status %d", resp.StatusCode)
+		}
+		return nil
+	}
+	return e
+}
+
+// leaseRequestDo sends a lease request to the lease host
+func leaseRequestDo(ctx context.Context, client *http.Client, method, url, token string, body leaseRequest) (*http.Response, error) {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal lease request: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, url, bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create lease request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to reach lease host: %w", err)
+	}
+	return resp, nil
+}
+
+// SetEventLog sets the event log used to record leadership changes
+func (e *Elector) SetEventLog(eventLog *events.Log) {
+	e.eventLog = eventLog
+}
+
+// IsLeader reports whether this instance currently holds the lease
+func (e *Elector) IsLeader() bool {
+	return e.leader.Load()
+}
+
+// Start claims the lease immediately and renews it every third of the TTL until the
+// context is cancelled, then releases it so the standby takes over without waiting for expiry
+func (e *Elector) Start(ctx context.Context) {
+	ticker := time.NewTicker(e.ttl / 3)
+	defer ticker.Stop()
+
+	e.logger.Info("leader election started",
+		zap.String("id", e.id),
+		zap.Duration("ttl", e.ttl),
+	)
+
+	e.renew(ctx)
+	for {
+		select {
+		case <-ctx.Done():
+			if e.leader.Load() {
+				releaseCtx, cancel := context.WithTimeout(context.Background(), e.ttl/3)
+				if err := e.release(releaseCtx); err != nil {
+					e.logger.Warn("failed to release lease", zap.Error(err))
+				}
+				cancel()
+				e.leader.Store(false)
+			}
+			e.logger.Info("leader election stopping")
+			return
+		case <-ticker.C:
+			e.renew(ctx)
+		}
+	}
+}
+
+// renew claims the lease and records a change of leadership
+func (e *Elector) renew(ctx context.Context) {
+	granted, holder, err := e.acquire(ctx)
+	if err != nil {
+		e.logger.Warn("lease host unavailable, leading until it is back", zap.Error(err))
+	}
+
+	if granted == e.leader.Swap(granted) {
+		return
+	}
+	if granted {
+		e.logger.Info("acquired leadership, pushing metrics", zap.String("id", e.id))
+		e.eventLog.Record(events.TypeLeadershipAcquired, "leader", "acquired leadership",
+			map[string]string{"id": e.id},
+		)
+		return
+	}
+	e.logger.Info("lost leadership, buffering as standby",
+		zap.String("id", e.id),
+		zap.String("leader", holder),
+	)
+	e.eventLog.Record(events.TypeLeadershipLost, "leader", "lost leadership",
+		map[string]string{"id": e.id, "leader": holder},
+	)
+}
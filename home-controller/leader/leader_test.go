@@ -0,0 +1,121 @@
+package leader
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/mjasion/balena-home/thermostats/admin"
+	"github.com/mjasion/balena-home/thermostats/events"
+	"go.uber.org/zap"
+)
+
+func TestLease_TryAcquire(t *testing.T) {
+	lease := NewLease("")
+	now := time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)
+	lease.now = func() time.Time { return now }
+
+	if granted, _ := lease.TryAcquire("pi-1", 30*time.Second); !granted {
+		t.Fatal("Expected pi-1 to acquire the free lease")
+	}
+	if granted, holder := lease.TryAcquire("pi-2", 30*time.Second); granted || holder != "pi-1" {
+		t.Errorf("Expected lease held by pi-1, got granted=%v holder=%s", granted, holder)
+	}
+
+	now = now.Add(20 * time.Second)
+	if granted, _ := lease.TryAcquire("pi-1", 30*time.Second); !granted {
+		t.Error("Expected pi-1 to renew its lease")
+	}
+
+	now = now.Add(31 * time.Second)
+	if granted, _ := lease.TryAcquire("pi-2", 30*time.Second); !granted {
+		t.Error("Expected pi-2 to take over the expired lease")
+	}
+
+	lease.Release("pi-1")
+	if granted, _ := lease.TryAcquire("pi-1", 30*time.Second); granted {
+		t.Error("Expected release by a non-holder to be ignored")
+	}
+	lease.Release("pi-2")
+	if granted, _ := lease.TryAcquire("pi-1", 30*time.Second); !granted {
+		t.Error("Expected pi-1 to acquire the released lease")
+	}
+}
+
+func TestRemoteElector(t *testing.T) {
+	lease := NewLease("secret")
+	server := admin.New(":0", zap.NewNop())
+	lease.RegisterHandlers(server)
+	httpServer := httptest.NewServer(server)
+	defer httpServer.Close()
+	url := httpServer.URL + "/api/lease"
+
+	local := NewLocalElector("pi-1", lease, 30*time.Second, zap.NewNop())
+	local.renew(context.Background())
+	if !local.IsLeader() {
+		t.Fatal("Expected the lease host to lead")
+	}
+
+	remote := NewRemoteElector("pi-2", url, "secret", 30*time.Second, zap.NewNop())
+	eventLog := events.NewLog(10, zap.NewNop())
+	remote.SetEventLog(eventLog)
+	remote.renew(context.Background())
+	if remote.IsLeader() {
+		t.Error("Expected the remote instance to stand by while pi-1 leads")
+	}
+
+	lease.Release("pi-1")
+	remote.renew(context.Background())
+	if !remote.IsLeader() {
+		t.Error("Expected the remote instance to lead after pi-1 released the lease")
+	}
+
+	unauthorized := NewRemoteElector("pi-3", url, "wrong", 30*time.Second, zap.NewNop())
+	if granted, _, err := unauthorized.acquire(context.Background()); err == nil || !granted {
+		t.Errorf("Expected an error for a wrong token, got granted=%v err=%v", granted, err)
+	}
+
+	recorded := eventLog.List(events.Filter{})
+	if len(recorded) != 1 || recorded[0].Type != events.TypeLeadershipAcquired {
+		t.Errorf("Expected a single leadership acquired event, got %+v", recorded)
+	}
+}
+
+func TestRemoteElector_LeadsWhenLeaseHostDown(t *testing.T) {
+	httpServer := httptest.NewServer(nil)
+	url := httpServer.URL + "/api/lease"
+	httpServer.Close()
+
+	remote := NewRemoteElector("pi-2", url, "", 3*time.Second, zap.NewNop())
+	remote.renew(context.Background())
+	if !remote.IsLeader() {
+		t.Error("Expected the remote instance to lead while the lease host is down")
+	}
+}
+
+func TestElector_ReleasesOnStop(t *testing.T) {
+	lease := NewLease("")
+	elector := NewLocalElector("pi-1", lease, 3*time.Second, zap.NewNop())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		elector.Start(ctx)
+		close(done)
+	}()
+
+	deadline := time.Now().Add(time.Second)
+	for !elector.IsLeader() && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	cancel()
+	<-done
+
+	if elector.IsLeader() {
+		t.Error("Expected leadership to end on stop")
+	}
+	if granted, _ := lease.TryAcquire("pi-2", 3*time.Second); !granted {
+		t.Error("Expected the lease to be released on stop")
+	}
+}
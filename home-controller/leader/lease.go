@@ -0,0 +1,109 @@
+package leader
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/mjasion/balena-home/thermostats/admin"
+)
+
+// Lease grants leadership to one holder at a time until it expires or is released
+type Lease struct {
+	mu      sync.Mutex
+	holder  string
+	expires time.Time
+	now     func() time.Time
+	token   string // Bearer token required by the HTTP endpoints, empty disables auth
+}
+
+// NewLease creates an unheld lease; an empty token accepts unauthenticated requests
+func NewLease(token string) *Lease {
+	return &Lease{now: time.Now, token: token}
+}
+
+// TryAcquire takes or renews the lease for holder and returns the current holder
+func (l *Lease) TryAcquire(holder string, ttl time.Duration) (bool, string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	if l.holder != "" && l.holder != holder && now.Before(l.expires) {
+		return false, l.holder
+	}
+	l.holder = holder
+	l.expires = now.Add(ttl)
+	return true, holder
+}
+
+// Release gives the lease up if holder has it, so a standby can take over without waiting for expiry
+func (l *Lease) Release(holder string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.holder == holder {
+		l.holder = ""
+		l.expires = time.Time{}
+	}
+}
+
+// leaseRequest is the body of POST and DELETE /api/lease
+type leaseRequest struct {
+	Holder     string `json:"holder"`
+	TTLSeconds int    `json:"ttl_seconds"`
+}
+
+// RegisterHandlers serves the lease to instances using a remote elector
+func (l *Lease) RegisterHandlers(server *admin.Server) {
+	server.HandleFunc("POST /api/lease", l.handleAcquire)
+	server.HandleFunc("DELETE /api/lease", l.handleRelease)
+}
+
+// handleAcquire handles POST /api/lease; 409 means another instance holds the lease
+func (l *Lease) handleAcquire(w http.ResponseWriter, r *http.Request) {
+	if !admin.BearerAuthorized(r, l.token) {
+		admin.WriteError(w, http.StatusUnauthorized, "invalid or missing bearer token")
+		return
+	}
+	var req leaseRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Holder == "" || req.TTLSeconds < 1 {
+		admin.WriteError(w, http.StatusBadRequest, "holder and ttl_seconds are required")
+		return
+	}
+
+	granted, holder := l.TryAcquire(req.Holder, time.Duration(req.TTLSeconds)*time.Second)
+	if !granted {
+		admin.WriteJSON(w, http.StatusConflict, admin.Response{
+			Success: false,
+			Message: fmt.Sprintf("Lease held by %s.", holder),
+			Data:    map[string]string{"holder": holder},
+		})
+		return
+	}
+	admin.WriteJSON(w, http.StatusOK, admin.Response{
+		Success: true,
+		Message: "Lease granted.",
+		Data:    map[string]string{"holder": holder},
+	})
+}
+
+// handleRelease handles DELETE /api/lease
+func (l *Lease) handleRelease(w http.ResponseWriter, r *http.Request) {
+	if !admin.BearerAuthorized(r, l.token) {
+		admin.WriteError(w, http.StatusUnauthorized, "invalid or missing bearer token")
+		return
+	}
+	var req leaseRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Holder == "" {
+		admin.WriteError(w, http.StatusBadRequest, "holder is required")
+		return
+	}
+
+	l.Release(req.Holder)
+	admin.WriteJSON(w, http.StatusOK, admin.Response{
+		Success: true,
+		Message: "Lease released.",
+	})
+}
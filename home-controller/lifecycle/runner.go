@@ -0,0 +1,133 @@
+package lifecycle
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// Phase groups components that stop together; phases stop in declaration order
+type Phase int
+
+const (
+	PhaseIntake     Phase = iota // Collectors and ingestion endpoints adding readings
+	PhaseProcessing              // Buffer listeners and automations acting on readings
+	PhaseOutput                  // Pushers draining the buffer; stop hooks do the final push
+	PhaseTelemetry               // Event delivery, dependency metrics and log shipping
+	phaseCount
+)
+
+// String returns the phase name used in logs
+func (p Phase) String() string {
+	switch p {
+	case PhaseIntake:
+		return "intake"
+	case PhaseProcessing:
+		return "processing"
+	case PhaseOutput:
+		return "output"
+	case PhaseTelemetry:
+		return "telemetry"
+	}
+	return "unknown"
+}
+
+// DefaultStopTimeout bounds how long a phase waits for its components and each stop hook
+const DefaultStopTimeout = 10 * time.Second
+
+// stopHook runs after the components of its phase have returned
+type stopHook struct {
+	name    string
+	timeout time.Duration
+	stop    func(ctx context.Context) error
+}
+
+// phase tracks the components started in a phase
+type phase struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+	hooks  []stopHook
+}
+
+// Runner starts components in phases and stops them in a fixed order:
+// stop intake, stop processing, final push, then close telemetry
+type Runner struct {
+	logger      *zap.Logger
+	stopTimeout time.Duration
+	phases      [phaseCount]*phase
+}
+
+// New creates a runner with a context per phase
+func New(logger *zap.Logger) *Runner {
+	r := &Runner{
+		logger:      logger,
+		stopTimeout: DefaultStopTimeout,
+	}
+	for i := range r.phases {
+		ctx, cancel := context.WithCancel(context.Background())
+		r.phases[i] = &phase{ctx: ctx, cancel: cancel}
+	}
+	return r
+}
+
+// SetStopTimeout changes how long each phase waits for its components to return
+func (r *Runner) SetStopTimeout(timeout time.Duration) {
+	r.stopTimeout = timeout
+}
+
+// Go runs a component until its phase is stopped; run must return once ctx is cancelled
+func (r *Runner) Go(p Phase, name string, run func(ctx context.Context)) {
+	ph := r.phases[p]
+	ph.wg.Add(1)
+	go func() {
+		defer ph.wg.Done()
+		run(ph.ctx)
+		r.logger.Debug("component stopped", zap.String("component", name), zap.Stringer("phase", p))
+	}()
+}
+
+// OnStop registers a hook run after the components of the phase have returned
+// Hooks of a phase run in registration order, each bounded by timeout
+func (r *Runner) OnStop(p Phase, name string, timeout time.Duration, stop func(ctx context.Context) error) {
+	ph := r.phases[p]
+	ph.hooks = append(ph.hooks, stopHook{name: name, timeout: timeout, stop: stop})
+}
+
+// Shutdown stops the phases in order; a phase that doesn't return within the stop
+// timeout is logged and left behind so later phases still get to run
+func (r *Runner) Shutdown() {
+	for i, ph := range r.phases {
+		p := Phase(i)
+		r.logger.Info("stopping phase", zap.Stringer("phase", p))
+		ph.cancel()
+
+		done := make(chan struct{})
+		go func() {
+			ph.wg.Wait()
+			close(done)
+		}()
+		select {
+		case <-done:
+		case <-time.After(r.stopTimeout):
+			r.logger.Warn("phase did not stop in time, continuing shutdown",
+				zap.Stringer("phase", p),
+				zap.Duration("timeout", r.stopTimeout),
+			)
+		}
+
+		for _, hook := range ph.hooks {
+			ctx, cancel := context.WithTimeout(context.Background(), hook.timeout)
+			if err := hook.stop(ctx); err != nil {
+				r.logger.Error("stop hook failed",
+					zap.String("hook", hook.name),
+					zap.Stringer("phase", p),
+					zap.Error(err),
+				)
+			}
+			cancel()
+		}
+	}
+}
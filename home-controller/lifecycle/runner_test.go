@@ -0,0 +1,77 @@
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+func TestShutdown_StopsPhasesInOrder(t *testing.T) {
+	runner := New(zap.NewNop())
+
+	var mu sync.Mutex
+	var order []string
+	record := func(name string) {
+		mu.Lock()
+		order = append(order, name)
+		mu.Unlock()
+	}
+	component := func(name string) func(ctx context.Context) {
+		return func(ctx context.Context) {
+			<-ctx.Done()
+			record(name)
+		}
+	}
+
+	// Registered out of order to show that phases, not registration, decide
+	runner.Go(PhaseTelemetry, "logs", component("logs"))
+	runner.Go(PhaseOutput, "pusher", component("pusher"))
+	runner.Go(PhaseIntake, "scanner", component("scanner"))
+	runner.Go(PhaseProcessing, "engine", component("engine"))
+	runner.OnStop(PhaseOutput, "final push", time.Second, func(ctx context.Context) error {
+		record("final push")
+		return errors.New("ignored")
+	})
+	runner.OnStop(PhaseIntake, "scanner stop", time.Second, func(ctx context.Context) error {
+		record("scanner stop")
+		return nil
+	})
+
+	runner.Shutdown()
+
+	expected := "scanner,scanner stop,engine,pusher,final push,logs"
+	if got := strings.Join(order, ","); got != expected {
+		t.Errorf("Expected order %s, got %s", expected, got)
+	}
+}
+
+func TestShutdown_ContinuesPastStuckPhase(t *testing.T) {
+	runner := New(zap.NewNop())
+	runner.SetStopTimeout(20 * time.Millisecond)
+
+	stuck := make(chan struct{})
+	defer close(stuck)
+	runner.Go(PhaseIntake, "stuck", func(ctx context.Context) { <-stuck })
+
+	var hookCtxErr error
+	runner.OnStop(PhaseOutput, "final push", 50*time.Millisecond, func(ctx context.Context) error {
+		<-ctx.Done()
+		hookCtxErr = ctx.Err()
+		return nil
+	})
+
+	start := time.Now()
+	runner.Shutdown()
+
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Expected shutdown to give up on stuck phase, took %v", elapsed)
+	}
+	if !errors.Is(hookCtxErr, context.DeadlineExceeded) {
+		t.Errorf("Expected hook context deadline, got %v", hookCtxErr)
+	}
+}
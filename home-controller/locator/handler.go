@@ -0,0 +1,26 @@
+package locator
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/mjasion/balena-home/thermostats/admin"
+)
+
+// RegisterHandlers registers the current locations on the admin server
+func (l *Locator) RegisterHandlers(server *admin.Server) {
+	server.HandleFunc("GET /api/locations", l.handleLocations)
+}
+
+// handleLocations handles GET /api/locations
+func (l *Locator) handleLocations(w http.ResponseWriter, r *http.Request) {
+	locations := l.Locations()
+	if locations == nil {
+		locations = []Location{}
+	}
+	admin.WriteJSON(w, http.StatusOK, admin.Response{
+		Success: true,
+		Message: fmt.Sprintf("%d located devices", len(locations)),
+		Data:    locations,
+	})
+}
@@ -0,0 +1,290 @@
+package locator
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mjasion/balena-home/thermostats/buffer"
+	"go.uber.org/zap"
+)
+
+// smoothing is the weight of a new RSSI sample in each receiver's moving average;
+// single advertisements vary by several dB even for a device that doesn't move
+const smoothing = 0.3
+
+// Beacon is a BLE device tracked for presence only, such as a tag on a key ring
+type Beacon struct {
+	Name string
+	MAC  string
+}
+
+// heard is the smoothed signal strength of a device at one receiver
+type heard struct {
+	rssi     float64
+	lastSeen time.Time
+}
+
+// device is a sensor or beacon and the receivers hearing it
+type device struct {
+	name      string
+	receivers map[string]*heard
+	nearest   string
+}
+
+// Location is the receiver nearest to a device and the signal strength at every receiver hearing it
+type Location struct {
+	MAC       string             `json:"mac"`
+	Name      string             `json:"name"`
+	Receiver  string             `json:"receiver"`
+	Room      string             `json:"room,omitempty"`
+	RSSI      float64            `json:"rssi_dbm"`
+	Receivers map[string]float64 `json:"receivers"`
+}
+
+// Locator compares the RSSI of BLE sensors and beacons across receivers (this device's scanner,
+// satellites forwarding readings and BLE proxies) and reports the nearest receiver of each
+type Locator struct {
+	beacons    map[string]string // Beacon name by MAC address
+	rooms      map[string]string // Room by receiver name
+	stale      time.Duration
+	hysteresis float64
+	buffer     *buffer.RingBuffer
+	interval   time.Duration
+	logger     *zap.Logger
+	now        func() time.Time
+
+	mu      sync.Mutex
+	devices map[string]*device // By MAC address
+}
+
+// New creates a locator; a receiver not hearing a device for staleSeconds no longer counts,
+// and the nearest receiver only changes when another one is hysteresisDB stronger.
+// Register Observe as a buffer listener to feed it sensor readings.
+func New(beacons []Beacon, rooms map[string]string, staleSeconds int, hysteresisDB float64, buf *buffer.RingBuffer, intervalSeconds int, logger *zap.Logger) *Locator {
+	beaconMACs := make(map[string]string, len(beacons))
+	for _, beacon := range beacons {
+		beaconMACs[strings.ToUpper(strings.TrimSpace(beacon.MAC))] = beacon.Name
+	}
+	return &Locator{
+		beacons:    beaconMACs,
+		rooms:      rooms,
+		stale:      time.Duration(staleSeconds) * time.Second,
+		hysteresis: hysteresisDB,
+		buffer:     buf,
+		interval:   time.Duration(intervalSeconds) * time.Second,
+		logger:     logger,
+		now:        time.Now,
+		devices:    make(map[string]*device),
+	}
+}
+
+// IsBeacon reports whether the MAC address belongs to a configured beacon
+func (l *Locator) IsBeacon(mac string) bool {
+	if l == nil {
+		return false
+	}
+	_, ok := l.beacons[strings.ToUpper(mac)]
+	return ok
+}
+
+// Observe records the receiver and RSSI of BLE sensor readings
+func (l *Locator) Observe(reading *buffer.Reading) {
+	r := reading.BLE
+	if r == nil || r.Receiver == "" {
+		return
+	}
+	l.record(strings.ToUpper(r.MAC), r.SensorName, r.Receiver, r.RSSI, r.Timestamp)
+}
+
+// Heard records an advertisement that doesn't reach the buffer: a beacon, or a copy of a sensor
+// frame another receiver delivered first. Unknown devices are ignored.
+func (l *Locator) Heard(mac, receiver string, rssi int16, at time.Time) {
+	if l == nil {
+		return
+	}
+	mac = strings.ToUpper(mac)
+	name, beacon := l.beacons[mac]
+	if !beacon {
+		l.mu.Lock()
+		d, known := l.devices[mac]
+		if known {
+			name = d.name
+		}
+		l.mu.Unlock()
+		if !known {
+			return
+		}
+	}
+	l.record(mac, name, receiver, rssi, at)
+}
+
+// record updates the moving average of a device at a receiver
+func (l *Locator) record(mac, name, receiver string, rssi int16, at time.Time) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	d, ok := l.devices[mac]
+	if !ok {
+		d = &device{receivers: make(map[string]*heard)}
+		l.devices[mac] = d
+	}
+	d.name = name
+
+	h, ok := d.receivers[receiver]
+	if !ok || at.Sub(h.lastSeen) > l.stale {
+		// Start over rather than average with a value from before the device moved
+		d.receivers[receiver] = &heard{rssi: float64(rssi), lastSeen: at}
+		return
+	}
+	h.rssi += smoothing * (float64(rssi) - h.rssi)
+	if at.After(h.lastSeen) {
+		h.lastSeen = at
+	}
+}
+
+// locate returns the nearest receiver among those that heard the device recently, keeping the
+// current one unless another is stronger by the hysteresis; the caller must hold the lock
+func (l *Locator) locate(d *device, now time.Time) string {
+	best := ""
+	for receiver, h := range d.receivers {
+		if now.Sub(h.lastSeen) > l.stale {
+			continue
+		}
+		if best == "" || h.rssi > d.receivers[best].rssi || (h.rssi == d.receivers[best].rssi && receiver < best) {
+			best = receiver
+		}
+	}
+	if best == "" {
+		return ""
+	}
+	if current, ok := d.receivers[d.nearest]; ok && now.Sub(current.lastSeen) <= l.stale {
+		if d.receivers[best].rssi < current.rssi+l.hysteresis {
+			return d.nearest
+		}
+	}
+	return best
+}
+
+// Locations returns the current location of every device heard recently, by name
+func (l *Locator) Locations() []Location {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.locations(l.now())
+}
+
+// PresentBeacons returns the names of beacons located at a receiver, for presence detection
+func (l *Locator) PresentBeacons() []string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	var names []string
+	for mac, d := range l.devices {
+		if _, beacon := l.beacons[mac]; beacon && d.nearest != "" {
+			names = append(names, d.name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// locations builds locations from the nearest receivers chosen by update; the caller must hold the lock
+func (l *Locator) locations(now time.Time) []Location {
+	var locations []Location
+	for mac, d := range l.devices {
+		if d.nearest == "" {
+			continue
+		}
+		receivers := make(map[string]float64)
+		for receiver, h := range d.receivers {
+			if now.Sub(h.lastSeen) <= l.stale {
+				receivers[receiver] = h.rssi
+			}
+		}
+		locations = append(locations, Location{
+			MAC:       mac,
+			Name:      d.name,
+			Receiver:  d.nearest,
+			Room:      l.rooms[d.nearest],
+			RSSI:      d.receivers[d.nearest].rssi,
+			Receivers: receivers,
+		})
+	}
+	sort.Slice(locations, func(i, j int) bool {
+		if locations[i].Name != locations[j].Name {
+			return locations[i].Name < locations[j].Name
+		}
+		return locations[i].MAC < locations[j].MAC
+	})
+	return locations
+}
+
+// Start periodically updates the nearest receivers and adds a reading per located device
+// until the context is cancelled
+func (l *Locator) Start(ctx context.Context) {
+	l.logger.Info("starting locator",
+		zap.Duration("interval", l.interval),
+		zap.Int("beacon_count", len(l.beacons)),
+	)
+
+	ticker := time.NewTicker(l.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			l.logger.Info("stopping locator")
+			return
+		case <-ticker.C:
+			l.update()
+		}
+	}
+}
+
+// update picks the nearest receiver of each device, forgets devices no receiver hears
+// and adds a location reading per device
+func (l *Locator) update() {
+	now := l.now()
+	l.mu.Lock()
+	for mac, d := range l.devices {
+		for receiver, h := range d.receivers {
+			if now.Sub(h.lastSeen) > l.stale {
+				delete(d.receivers, receiver)
+			}
+		}
+		if len(d.receivers) == 0 {
+			delete(l.devices, mac)
+			continue
+		}
+		nearest := l.locate(d, now)
+		if nearest != d.nearest {
+			l.logger.Info("nearest receiver changed",
+				zap.String("name", d.name),
+				zap.String("mac", mac),
+				zap.String("previous_receiver", d.nearest),
+				zap.String("receiver", nearest),
+				zap.Float64("rssi_dbm", d.receivers[nearest].rssi),
+			)
+			d.nearest = nearest
+		}
+	}
+	locations := l.locations(now)
+	l.mu.Unlock()
+
+	// Add runs every buffer listener synchronously; none of them should wait on l.mu
+	for _, location := range locations {
+		l.buffer.Add(&buffer.Reading{
+			Type: buffer.ReadingTypeLocation,
+			Location: &buffer.LocationReading{
+				Timestamp: now,
+				MAC:       location.MAC,
+				Name:      location.Name,
+				Receiver:  location.Receiver,
+				Room:      location.Room,
+				RSSI:      location.RSSI,
+			},
+		})
+	}
+}
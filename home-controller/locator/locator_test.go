@@ -0,0 +1,149 @@
+package locator
+
+import (
+	"testing"
+	"time"
+
+	"github.com/mjasion/balena-home/thermostats/buffer"
+	"go.uber.org/zap"
+)
+
+func sensorReading(receiver string, rssi int16, ts time.Time) *buffer.Reading {
+	return &buffer.Reading{
+		Type: buffer.ReadingTypeBLE,
+		BLE: &buffer.SensorReading{
+			Timestamp:  ts,
+			MAC:        "a4:c1:38:00:00:01",
+			SensorName: "kitchen",
+			SensorID:   1,
+			RSSI:       rssi,
+			Receiver:   receiver,
+		},
+	}
+}
+
+func newTestLocator(buf *buffer.RingBuffer, now time.Time) *Locator {
+	l := New(
+		[]Beacon{{Name: "keys", MAC: "c2:00:00:00:00:01"}},
+		map[string]string{"hallway": "hall"},
+		60,
+		5,
+		buf,
+		30,
+		zap.NewNop(),
+	)
+	l.now = func() time.Time { return now }
+	return l
+}
+
+func TestLocator_NearestReceiver(t *testing.T) {
+	now := time.Now()
+	buf := buffer.New(100, zap.NewNop())
+	l := newTestLocator(buf, now)
+
+	l.Observe(sensorReading("local", -80, now))
+	l.Observe(sensorReading("hallway", -60, now))
+	l.update()
+
+	locations := l.Locations()
+	if len(locations) != 1 {
+		t.Fatalf("Expected 1 location, got %d", len(locations))
+	}
+	location := locations[0]
+	if location.Receiver != "hallway" || location.Room != "hall" || location.Name != "kitchen" {
+		t.Errorf("Expected kitchen at hallway in hall, got %+v", location)
+	}
+	if location.MAC != "A4:C1:38:00:00:01" || len(location.Receivers) != 2 {
+		t.Errorf("Expected upper-case MAC heard by 2 receivers, got %+v", location)
+	}
+
+	readings := buf.GetAll()
+	if len(readings) != 1 || readings[0].Location == nil || readings[0].Location.Receiver != "hallway" {
+		t.Fatalf("Expected 1 location reading for hallway, got %+v", readings)
+	}
+}
+
+func TestLocator_Hysteresis(t *testing.T) {
+	now := time.Now()
+	l := newTestLocator(buffer.New(100, zap.NewNop()), now)
+
+	l.Observe(sensorReading("local", -70, now))
+	l.Observe(sensorReading("hallway", -75, now))
+	l.update()
+	if got := l.Locations()[0].Receiver; got != "local" {
+		t.Fatalf("Expected local, got %s", got)
+	}
+
+	// Slightly stronger elsewhere isn't enough to move
+	for i := 0; i < 20; i++ {
+		l.Observe(sensorReading("hallway", -68, now))
+	}
+	l.update()
+	if got := l.Locations()[0].Receiver; got != "local" {
+		t.Errorf("Expected local within the hysteresis, got %s", got)
+	}
+
+	for i := 0; i < 20; i++ {
+		l.Observe(sensorReading("hallway", -60, now))
+	}
+	l.update()
+	if got := l.Locations()[0].Receiver; got != "hallway" {
+		t.Errorf("Expected hallway beyond the hysteresis, got %s", got)
+	}
+}
+
+func TestLocator_StaleReceivers(t *testing.T) {
+	now := time.Now()
+	l := newTestLocator(buffer.New(100, zap.NewNop()), now)
+
+	l.Observe(sensorReading("hallway", -50, now.Add(-2*time.Minute)))
+	l.Observe(sensorReading("local", -80, now))
+	l.update()
+
+	locations := l.Locations()
+	if len(locations) != 1 || locations[0].Receiver != "local" {
+		t.Fatalf("Expected stale hallway to be ignored, got %+v", locations)
+	}
+	if _, ok := locations[0].Receivers["hallway"]; ok {
+		t.Errorf("Expected stale hallway to be forgotten, got %+v", locations[0].Receivers)
+	}
+
+	l.now = func() time.Time { return now.Add(5 * time.Minute) }
+	l.update()
+	if len(l.Locations()) != 0 {
+		t.Errorf("Expected devices no receiver hears to be forgotten")
+	}
+}
+
+func TestLocator_Heard(t *testing.T) {
+	now := time.Now()
+	l := newTestLocator(buffer.New(100, zap.NewNop()), now)
+
+	if !l.IsBeacon("C2:00:00:00:00:01") || l.IsBeacon("A4:C1:38:00:00:01") {
+		t.Errorf("Expected only the configured beacon to be a beacon")
+	}
+
+	// Unknown devices are ignored, beacons and sensors already seen are recorded
+	l.Heard("A4:C1:38:00:00:01", "proxy", -40, now)
+	l.Heard("c2:00:00:00:00:01", "proxy", -55, now)
+	l.Observe(sensorReading("local", -80, now))
+	l.Heard("A4:C1:38:00:00:01", "proxy", -40, now)
+	l.update()
+
+	locations := l.Locations()
+	if len(locations) != 2 {
+		t.Fatalf("Expected 2 locations, got %+v", locations)
+	}
+	if locations[0].Name != "keys" || locations[0].Receiver != "proxy" {
+		t.Errorf("Expected keys at proxy, got %+v", locations[0])
+	}
+	if locations[1].Name != "kitchen" || locations[1].Receiver != "proxy" {
+		t.Errorf("Expected kitchen at proxy, got %+v", locations[1])
+	}
+
+	var nilLocator *Locator
+	nilLocator.Heard("C2:00:00:00:00:01", "proxy", -55, now)
+	if nilLocator.IsBeacon("C2:00:00:00:00:01") {
+		t.Errorf("Expected nil locator to track no beacons")
+	}
+}
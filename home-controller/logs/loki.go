@@ -0,0 +1,121 @@
+package logs
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mjasion/balena-home/thermostats/httpclient"
+	"github.com/mjasion/balena-home/thermostats/telemetry"
+)
+
+// Entry is a single log line with its timestamp
+type Entry struct {
+	Timestamp time.Time
+	Line      string
+}
+
+// Stream is a set of entries sharing the same labels
+type Stream struct {
+	Labels  map[string]string
+	Entries []Entry
+}
+
+// Client pushes log streams to the Loki push API
+type Client struct {
+	url      string
+	username string
+	password string
+	client   *http.Client
+}
+
+// NewClient creates a client for the Loki instance at baseURL
+// A URL already ending in /loki/api/v1/push is used as is
+func NewClient(baseURL, username, password string) *Client {
+	url := strings.TrimSuffix(baseURL, "/")
+	if !strings.HasSuffix(url, "/loki/api/v1/push") {
+		url += "/loki/api/v1/push"
+	}
+	return &Client{
+		url:      url,
+		username: username,
+		password: password,
+		client:   httpclient.NewDefault(httpclient.Config{Timeout: 10 * time.Second}),
+	}
+}
+
+// SetRecorder records push requests as the "loki" dependency; call before the shipper starts
+func (c *Client) SetRecorder(recorder *telemetry.Recorder) {
+	recorder.Instrument(c.client, "loki")
+}
+
+// lokiPushRequest is the JSON body of POST /loki/api/v1/push
+type lokiPushRequest struct {
+	Streams []lokiStream `json:"streams"`
+}
+
+type lokiStream struct {
+	Stream map[string]string `json:"stream"`
+	Values [][2]string       `json:"values"`
+}
+
+// Push sends the streams in a single request
+func (c *Client) Push(ctx context.Context, streams []Stream) error {
+	if len(streams) == 0 {
+		return nil
+	}
+
+	body, err := json.Marshal(buildPushRequest(streams))
+	if err != nil {
+		return fmt.Errorf("failed to encode push request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if c.username != "" && c.password != "" {
+		req.SetBasicAuth(c.username, c.password)
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		respBody, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("received non-2xx status code: %d, body: %s", resp.StatusCode, string(respBody))
+	}
+	return nil
+}
+
+// buildPushRequest converts streams to the Loki wire format, entries ordered by time
+func buildPushRequest(streams []Stream) lokiPushRequest {
+	request := lokiPushRequest{Streams: make([]lokiStream, 0, len(streams))}
+	for _, stream := range streams {
+		entries := append([]Entry{}, stream.Entries...)
+		sort.SliceStable(entries, func(i, j int) bool {
+			return entries[i].Timestamp.Before(entries[j].Timestamp)
+		})
+
+		values := make([][2]string, len(entries))
+		for i, entry := range entries {
+			values[i] = [2]string{strconv.FormatInt(entry.Timestamp.UnixNano(), 10), entry.Line}
+		}
+		request.Streams = append(request.Streams, lokiStream{
+			Stream: stream.Labels,
+			Values: values,
+		})
+	}
+	return request
+}
@@ -0,0 +1,75 @@
+package logs
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestClient_Push(t *testing.T) {
+	var received lokiPushRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/loki/api/v1/push" {
+			t.Errorf("Expected path /loki/api/v1/push, got %s", r.URL.Path)
+		}
+		if r.Header.Get("Content-Type") != "application/json" {
+			t.Errorf("Expected JSON content type, got %s", r.Header.Get("Content-Type"))
+		}
+		username, password, ok := r.BasicAuth()
+		if !ok || username != "123456" || password != "secret" {
+			t.Errorf("Expected basic auth 123456/secret, got %s/%s", username, password)
+		}
+		json.NewDecoder(r.Body).Decode(&received)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL+"/", "123456", "secret")
+	streams := []Stream{{
+		Labels: map[string]string{"service": "home-controller", "level": "warn"},
+		Entries: []Entry{
+			{Timestamp: time.Unix(0, 2000), Line: "second"},
+			{Timestamp: time.Unix(0, 1000), Line: "first"},
+		},
+	}}
+	if err := client.Push(context.Background(), streams); err != nil {
+		t.Fatalf("Push failed: %v", err)
+	}
+
+	if len(received.Streams) != 1 {
+		t.Fatalf("Expected 1 stream, got %d", len(received.Streams))
+	}
+	stream := received.Streams[0]
+	if stream.Stream["service"] != "home-controller" || stream.Stream["level"] != "warn" {
+		t.Errorf("Unexpected labels: %v", stream.Stream)
+	}
+	if len(stream.Values) != 2 {
+		t.Fatalf("Expected 2 values, got %d", len(stream.Values))
+	}
+	if stream.Values[0] != [2]string{"1000", "first"} || stream.Values[1] != [2]string{"2000", "second"} {
+		t.Errorf("Expected values ordered by time, got %v", stream.Values)
+	}
+}
+
+func TestClient_FullPushURL(t *testing.T) {
+	client := NewClient("https://logs.example.com/loki/api/v1/push", "", "")
+	if client.url != "https://logs.example.com/loki/api/v1/push" {
+		t.Errorf("Expected push URL to be kept, got %s", client.url)
+	}
+}
+
+func TestClient_ErrorStatus(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL, "user", "bad")
+	err := client.Push(context.Background(), []Stream{{Entries: []Entry{{Line: "x"}}}})
+	if err == nil {
+		t.Error("Expected error for 401 response, got nil")
+	}
+}
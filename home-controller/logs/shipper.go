@@ -0,0 +1,239 @@
+package logs
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// Pusher sends log streams to a log backend
+type Pusher interface {
+	Push(ctx context.Context, streams []Stream) error
+}
+
+// record is a queued log line waiting to be shipped
+type record struct {
+	level zapcore.Level
+	entry Entry
+}
+
+// Shipper batches log entries from a zap core and pushes them to Loki
+type Shipper struct {
+	pusher        Pusher
+	labels        map[string]string // Static labels, e.g. service and device
+	level         zapcore.LevelEnabler
+	batchSize     int
+	flushInterval time.Duration
+	queue         chan record
+	dropped       atomic.Int64
+	logger        *zap.Logger
+}
+
+// NewShipper creates a shipper for entries at or above level
+// The logger is used for the shipper's own diagnostics and must not write to the shipper's core
+func NewShipper(pusher Pusher, labels map[string]string, level zapcore.LevelEnabler, batchSize, queueSize int, flushInterval time.Duration, logger *zap.Logger) *Shipper {
+	return &Shipper{
+		pusher:        pusher,
+		labels:        labels,
+		level:         level,
+		batchSize:     batchSize,
+		flushInterval: flushInterval,
+		queue:         make(chan record, queueSize),
+		logger:        logger,
+	}
+}
+
+// Core returns a zap core that queues entries for shipping
+// Combine it with the console core using zapcore.NewTee
+func (s *Shipper) Core() zapcore.Core {
+	encoderConfig := zapcore.EncoderConfig{
+		MessageKey:     "msg",
+		NameKey:        "logger",
+		CallerKey:      "caller",
+		StacktraceKey:  "stacktrace",
+		LineEnding:     zapcore.DefaultLineEnding,
+		EncodeDuration: zapcore.StringDurationEncoder,
+		EncodeCaller:   zapcore.ShortCallerEncoder,
+	}
+	return &core{
+		LevelEnabler: s.level,
+		encoder:      zapcore.NewJSONEncoder(encoderConfig),
+		shipper:      s,
+	}
+}
+
+// Dropped returns the number of entries dropped because the queue was full
+func (s *Shipper) Dropped() int64 {
+	return s.dropped.Load()
+}
+
+// enqueue queues an entry without blocking the caller
+func (s *Shipper) enqueue(rec record) {
+	select {
+	case s.queue <- rec:
+	default:
+		s.dropped.Add(1)
+	}
+}
+
+// Start ships queued entries until the context is cancelled
+func (s *Shipper) Start(ctx context.Context) {
+	s.logger.Info("loki log shipper started",
+		zap.Int("batch_size", s.batchSize),
+		zap.Duration("flush_interval", s.flushInterval),
+	)
+
+	ticker := time.NewTicker(s.flushInterval)
+	defer ticker.Stop()
+
+	var batch []record
+	for {
+		select {
+		case <-ctx.Done():
+			// Best-effort flush of everything still queued
+			batch = s.drain(batch)
+			flushCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			s.ship(flushCtx, batch)
+			cancel()
+			s.logger.Info("loki log shipper stopped", zap.Int64("dropped_entries", s.Dropped()))
+			return
+		case rec := <-s.queue:
+			batch = append(batch, rec)
+			if len(batch) >= s.batchSize {
+				s.ship(ctx, batch)
+				batch = nil
+			}
+		case <-ticker.C:
+			if len(batch) > 0 {
+				s.ship(ctx, batch)
+				batch = nil
+			}
+		}
+	}
+}
+
+// drain appends all currently queued entries to the batch
+func (s *Shipper) drain(batch []record) []record {
+	for {
+		select {
+		case rec := <-s.queue:
+			batch = append(batch, rec)
+		default:
+			return batch
+		}
+	}
+}
+
+// ship pushes a batch, dropping it if every attempt fails
+func (s *Shipper) ship(ctx context.Context, batch []record) {
+	if len(batch) == 0 {
+		return
+	}
+
+	if err := s.push(ctx, s.buildStreams(batch)); err != nil {
+		s.logger.Warn("failed to ship logs to loki, dropping batch",
+			zap.Int("entry_count", len(batch)),
+			zap.Error(err),
+		)
+	}
+}
+
+// push pushes streams with retries
+func (s *Shipper) push(ctx context.Context, streams []Stream) error {
+	var lastErr error
+	for attempt := 1; attempt <= 3; attempt++ {
+		err := s.pusher.Push(ctx, streams)
+		if err == nil {
+			return nil
+		}
+		lastErr = err
+
+		// Exponential backoff: 1s, 2s
+		if attempt < 3 {
+			backoff := time.Duration(1<<(attempt-1)) * time.Second
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(backoff):
+			}
+		}
+	}
+
+	return fmt.Errorf("failed to push logs after 3 attempts: %w", lastErr)
+}
+
+// buildStreams groups a batch into one stream per level
+func (s *Shipper) buildStreams(batch []record) []Stream {
+	var streams []Stream
+	index := make(map[zapcore.Level]int)
+	for _, rec := range batch {
+		i, ok := index[rec.level]
+		if !ok {
+			labels := make(map[string]string, len(s.labels)+1)
+			for name, value := range s.labels {
+				labels[name] = value
+			}
+			labels["level"] = rec.level.String()
+
+			i = len(streams)
+			index[rec.level] = i
+			streams = append(streams, Stream{Labels: labels})
+		}
+		streams[i].Entries = append(streams[i].Entries, rec.entry)
+	}
+	return streams
+}
+
+// core is a zap core encoding entries as JSON lines for the shipper
+type core struct {
+	zapcore.LevelEnabler
+	encoder zapcore.Encoder
+	shipper *Shipper
+}
+
+// With adds structured context to the core
+func (c *core) With(fields []zapcore.Field) zapcore.Core {
+	clone := &core{
+		LevelEnabler: c.LevelEnabler,
+		encoder:      c.encoder.Clone(),
+		shipper:      c.shipper,
+	}
+	for _, field := range fields {
+		field.AddTo(clone.encoder)
+	}
+	return clone
+}
+
+// Check adds the core to the checked entry if the level is enabled
+func (c *core) Check(entry zapcore.Entry, checked *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(entry.Level) {
+		return checked.AddCore(entry, c)
+	}
+	return checked
+}
+
+// Write encodes the entry and queues it
+func (c *core) Write(entry zapcore.Entry, fields []zapcore.Field) error {
+	buf, err := c.encoder.EncodeEntry(entry, fields)
+	if err != nil {
+		return fmt.Errorf("failed to encode log entry: %w", err)
+	}
+	line := strings.TrimSuffix(buf.String(), "\n")
+	buf.Free()
+
+	c.shipper.enqueue(record{
+		level: entry.Level,
+		entry: Entry{Timestamp: entry.Time, Line: line},
+	})
+	return nil
+}
+
+// Sync is a no-op; entries are flushed by Start
+func (c *core) Sync() error {
+	return nil
+}
@@ -0,0 +1,134 @@
+package logs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// fakePusher records pushed streams
+type fakePusher struct {
+	mu      sync.Mutex
+	streams []Stream
+	err     error
+	calls   int
+}
+
+func (f *fakePusher) Push(ctx context.Context, streams []Stream) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.calls++
+	if f.err != nil {
+		return f.err
+	}
+	f.streams = append(f.streams, streams...)
+	return nil
+}
+
+func (f *fakePusher) pushed() []Stream {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]Stream{}, f.streams...)
+}
+
+func TestShipper_ShipsEnabledLevels(t *testing.T) {
+	pusher := &fakePusher{}
+	labels := map[string]string{"service": "home-controller", "device": "pi"}
+	shipper := NewShipper(pusher, labels, zapcore.WarnLevel, 100, 100, time.Hour, zap.NewNop())
+	logger := zap.New(shipper.Core()).With(zap.String("component", "pusher"))
+
+	logger.Info("not shipped")
+	logger.Warn("push slow", zap.Int("attempt", 2))
+	logger.Error("push failed")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		shipper.Start(ctx)
+		close(done)
+	}()
+	cancel()
+	<-done
+
+	streams := pusher.pushed()
+	if len(streams) != 2 {
+		t.Fatalf("Expected warn and error streams, got %d", len(streams))
+	}
+
+	byLevel := make(map[string]Stream)
+	for _, stream := range streams {
+		if stream.Labels["service"] != "home-controller" || stream.Labels["device"] != "pi" {
+			t.Errorf("Missing static labels: %v", stream.Labels)
+		}
+		byLevel[stream.Labels["level"]] = stream
+	}
+
+	warn, ok := byLevel["warn"]
+	if !ok || len(warn.Entries) != 1 {
+		t.Fatalf("Expected one warn entry, got %v", byLevel)
+	}
+	var line map[string]interface{}
+	if err := json.Unmarshal([]byte(warn.Entries[0].Line), &line); err != nil {
+		t.Fatalf("Expected JSON line, got %q: %v", warn.Entries[0].Line, err)
+	}
+	if line["msg"] != "push slow" || line["component"] != "pusher" || line["attempt"] != float64(2) {
+		t.Errorf("Unexpected line fields: %v", line)
+	}
+	if _, ok := byLevel["error"]; !ok {
+		t.Error("Expected error stream")
+	}
+}
+
+func TestShipper_FlushesFullBatch(t *testing.T) {
+	pusher := &fakePusher{}
+	shipper := NewShipper(pusher, nil, zapcore.WarnLevel, 2, 100, time.Hour, zap.NewNop())
+	logger := zap.New(shipper.Core())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go shipper.Start(ctx)
+
+	logger.Warn("one")
+	logger.Warn("two")
+
+	deadline := time.Now().Add(time.Second)
+	for len(pusher.pushed()) == 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	streams := pusher.pushed()
+	if len(streams) != 1 || len(streams[0].Entries) != 2 {
+		t.Fatalf("Expected one stream with 2 entries, got %v", streams)
+	}
+}
+
+func TestShipper_DropsWhenQueueFull(t *testing.T) {
+	shipper := NewShipper(&fakePusher{}, nil, zapcore.WarnLevel, 10, 1, time.Hour, zap.NewNop())
+	logger := zap.New(shipper.Core())
+
+	logger.Warn("queued")
+	logger.Warn("dropped")
+
+	if shipper.Dropped() != 1 {
+		t.Errorf("Expected 1 dropped entry, got %d", shipper.Dropped())
+	}
+}
+
+func TestShipper_PushRetries(t *testing.T) {
+	pusher := &fakePusher{err: errors.New("unavailable")}
+	shipper := NewShipper(pusher, nil, zapcore.WarnLevel, 10, 10, time.Hour, zap.NewNop())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := shipper.push(ctx, []Stream{{}}); err == nil {
+		t.Fatal("Expected error, got nil")
+	}
+	if pusher.calls != 1 {
+		t.Errorf("Expected retries to stop on cancelled context, got %d calls", pusher.calls)
+	}
+}
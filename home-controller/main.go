@@ -2,21 +2,76 @@ package main
 
 import (
 	"context"
+	"encoding/json"
 	"flag"
 	"fmt"
+	"maps"
+	"net/url"
 	"os"
 	"os/signal"
-	"sync"
+	"path/filepath"
+	"strings"
 	"syscall"
 	"time"
 
+	"github.com/mjasion/balena-home/thermostats/admin"
+	"github.com/mjasion/balena-home/thermostats/airquality"
+	"github.com/mjasion/balena-home/thermostats/atc"
+	"github.com/mjasion/balena-home/thermostats/automation"
+	"github.com/mjasion/balena-home/thermostats/battery"
+	"github.com/mjasion/balena-home/thermostats/bleproxy"
 	"github.com/mjasion/balena-home/thermostats/buffer"
+	"github.com/mjasion/balena-home/thermostats/clock"
+	"github.com/mjasion/balena-home/thermostats/collector"
 	"github.com/mjasion/balena-home/thermostats/config"
+	"github.com/mjasion/balena-home/thermostats/connectivity"
+	"github.com/mjasion/balena-home/thermostats/dnscache"
+	"github.com/mjasion/balena-home/thermostats/events"
+	_ "github.com/mjasion/balena-home/thermostats/execcollector" // Registers its collector
+	"github.com/mjasion/balena-home/thermostats/exitcode"
+	"github.com/mjasion/balena-home/thermostats/faults"
+	"github.com/mjasion/balena-home/thermostats/features"
+	"github.com/mjasion/balena-home/thermostats/frost"
+	"github.com/mjasion/balena-home/thermostats/fusion"
+	"github.com/mjasion/balena-home/thermostats/heatpump"
+	"github.com/mjasion/balena-home/thermostats/history"
+	"github.com/mjasion/balena-home/thermostats/httpauth"
+	"github.com/mjasion/balena-home/thermostats/httpclient"
+	_ "github.com/mjasion/balena-home/thermostats/i2csensor" // Registers its collector
+	"github.com/mjasion/balena-home/thermostats/identity"
+	"github.com/mjasion/balena-home/thermostats/ingest"
+	"github.com/mjasion/balena-home/thermostats/leader"
+	"github.com/mjasion/balena-home/thermostats/lifecycle"
+	"github.com/mjasion/balena-home/thermostats/locator"
+	"github.com/mjasion/balena-home/thermostats/logs"
 	"github.com/mjasion/balena-home/thermostats/metrics"
+	"github.com/mjasion/balena-home/thermostats/mode"
+	"github.com/mjasion/balena-home/thermostats/mqtt"
+	"github.com/mjasion/balena-home/thermostats/nats"
 	"github.com/mjasion/balena-home/thermostats/netatmo"
+	"github.com/mjasion/balena-home/thermostats/network"
+	"github.com/mjasion/balena-home/thermostats/notify"
+	"github.com/mjasion/balena-home/thermostats/occupancy"
+	_ "github.com/mjasion/balena-home/thermostats/onewire" // Registers its collector
 	"github.com/mjasion/balena-home/thermostats/power"
+	"github.com/mjasion/balena-home/thermostats/prices"
+	"github.com/mjasion/balena-home/thermostats/pstryk"
+	"github.com/mjasion/balena-home/thermostats/remoteconfig"
+	"github.com/mjasion/balena-home/thermostats/report"
+	"github.com/mjasion/balena-home/thermostats/restapi"
 	"github.com/mjasion/balena-home/thermostats/scanner"
+	"github.com/mjasion/balena-home/thermostats/schedule"
+	"github.com/mjasion/balena-home/thermostats/startup"
+	"github.com/mjasion/balena-home/thermostats/summary"
+	"github.com/mjasion/balena-home/thermostats/telemetry"
+	"github.com/mjasion/balena-home/thermostats/tuning"
+	"github.com/mjasion/balena-home/thermostats/ventilation"
+	"github.com/mjasion/balena-home/thermostats/version"
+	"github.com/mjasion/balena-home/thermostats/watchdog"
+	_ "github.com/mjasion/balena-home/thermostats/water" // Registers its collector
+	"github.com/mjasion/balena-home/thermostats/zigbee2mqtt"
 	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
 )
 
 func main() {
@@ -24,41 +79,400 @@ func main() {
 	configPath := flag.String("c", "config.yaml", "Path to configuration file")
 	flag.Parse()
 
+	// Print build information and exit for the version command
+	buildInfo := version.Get()
+	if flag.Arg(0) == "version" {
+		fmt.Println(buildInfo)
+		return
+	}
+
+	// Convert a legacy flat config to the current format for the config migrate command
+	if flag.Arg(0) == "config" && flag.Arg(1) == "migrate" {
+		if flag.NArg() != 3 {
+			fmt.Fprintln(os.Stderr, "Usage: home-controller config migrate <legacy-config.yaml>")
+			os.Exit(exitcode.Config)
+		}
+		data, err := os.ReadFile(flag.Arg(2))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to read configuration: %v\n", err)
+			os.Exit(exitcode.Config)
+		}
+		migrated, warnings, err := config.Migrate(data)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to migrate configuration: %v\n", err)
+			os.Exit(exitcode.Config)
+		}
+		for _, warning := range warnings {
+			fmt.Fprintf(os.Stderr, "warning: %s\n", warning)
+		}
+		os.Stdout.Write(migrated)
+		return
+	}
+
+	// Change ATC firmware settings over GATT for the sensor set command
+	if flag.Arg(0) == "sensor" && flag.Arg(1) == "set" {
+		if flag.NArg() < 4 {
+			fmt.Fprintf(os.Stderr, "Usage: home-controller [-c config.yaml] sensor set <sensor-name|mac> <setting=value>...\n\nSettings:\n%s", atc.Usage())
+			os.Exit(exitcode.Config)
+		}
+		if err := setSensor(*configPath, flag.Arg(2), flag.Args()[3:]); err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to configure sensor: %v\n", err)
+			os.Exit(exitcode.Of(err))
+		}
+		return
+	}
+
+	// Survey BLE sensor reception for the scan command, to choose where to place sensors
+	if flag.Arg(0) == "scan" {
+		if err := scanSensors(*configPath, flag.Args()[1:]); err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to scan: %v\n", err)
+			os.Exit(exitcode.Of(err))
+		}
+		return
+	}
+
 	// Load configuration
 	cfg, err := config.Load(*configPath)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Failed to load configuration: %v\n", err)
-		os.Exit(1)
+		os.Exit(exitcode.Config)
+	}
+
+	// Report whether the watchdog considers the service alive for the healthcheck command, run by
+	// the container health check; healthy when the watchdog doesn't keep a heartbeat file
+	if flag.Arg(0) == "healthcheck" {
+		if cfg.Watchdog.Enabled && cfg.Watchdog.HeartbeatFile != "" {
+			maxAge := 3 * time.Duration(cfg.Watchdog.CheckIntervalSeconds) * time.Second
+			if err := watchdog.CheckFile(cfg.Watchdog.HeartbeatFile, maxAge); err != nil {
+				fmt.Fprintf(os.Stderr, "Unhealthy: %v\n", err)
+				os.Exit(exitcode.Unhealthy)
+			}
+		}
+		return
+	}
+
+	// With remote config, the local file only bootstraps fetching the fleet-wide configuration
+	if cfg.RemoteConfig.Enabled {
+		if cfg, err = loadRemoteConfig(cfg); err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to load remote configuration: %v\n", err)
+			os.Exit(exitcode.Config)
+		}
 	}
 
 	// Initialize logger
 	logger, err := cfg.InitLogger()
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Failed to initialize logger: %v\n", err)
-		os.Exit(1)
+		os.Exit(exitcode.Config)
 	}
 	defer logger.Sync()
 
-	logger.Info("starting BLE temperature monitoring service")
+	// Identify outbound requests before clients are created, starting with the Loki client
+	userAgent := cfg.HTTPClient.UserAgent
+	if userAgent == "" {
+		userAgent = httpclient.UserAgent(cfg.Logging.Loki.Service, buildInfo.Version)
+	}
+	if err := httpclient.SetDefaults(httpclient.Defaults{UserAgent: userAgent, ProxyURL: cfg.HTTPClient.ProxyURL}); err != nil {
+		exitcode.Fatal(logger, "invalid HTTP client defaults", exitcode.ConfigError(err))
+	}
+
+	// Ship log entries to Loki if enabled; runs until after all other goroutines have stopped
+	// The client is shared with the events sink, which can push events without shipping logs
+	var logShipper *logs.Shipper
+	var lokiClient *logs.Client
+	var lokiLabels map[string]string
+	if cfg.Logging.Loki.Enabled || cfg.Events.Loki.Enabled {
+		lokiLabels = map[string]string{"service": cfg.Logging.Loki.Service}
+		if cfg.Logging.Loki.Device != "" {
+			lokiLabels["device"] = cfg.Logging.Loki.Device
+		}
+		for name, value := range cfg.Fleet.ExternalLabels() {
+			if _, ok := lokiLabels[name]; !ok {
+				lokiLabels[name] = value
+			}
+		}
+		lokiClient = logs.NewClient(cfg.Logging.Loki.URL, cfg.Logging.Loki.Username, cfg.Logging.Loki.Password)
+	}
+	if cfg.Logging.Loki.Enabled {
+		lokiLevel, err := zapcore.ParseLevel(cfg.Logging.Loki.Level)
+		if err != nil {
+			exitcode.Fatal(logger, "invalid loki log level", exitcode.ConfigError(err))
+		}
+		logShipper = logs.NewShipper(
+			lokiClient,
+			lokiLabels,
+			lokiLevel,
+			cfg.Logging.Loki.BatchSize,
+			cfg.Logging.Loki.QueueSize,
+			time.Duration(cfg.Logging.Loki.FlushIntervalSeconds)*time.Second,
+			logger,
+		)
+		logger = logger.WithOptions(zap.WrapCore(func(core zapcore.Core) zapcore.Core {
+			return zapcore.NewTee(core, logShipper.Core())
+		}))
+	}
+
+	logger.Info("starting BLE temperature monitoring service",
+		zap.String("version", buildInfo.Version),
+		zap.String("commit", buildInfo.Commit),
+		zap.String("build_date", buildInfo.Date),
+		zap.String("go_version", buildInfo.GoVersion),
+	)
 	cfg.PrintConfig(logger)
 
+	// Tune the garbage collector before the buffer and pushers allocate
+	runtimeSettings, err := cfg.Runtime.Settings()
+	if err != nil {
+		exitcode.Fatal(logger, "invalid runtime tuning", exitcode.ConfigError(err))
+	}
+	runtimeTuning := tuning.Apply(runtimeSettings)
+	logger.Info("runtime tuned",
+		zap.String("preset", cfg.Runtime.Preset),
+		zap.Int("gc_percent", runtimeTuning.GCPercent),
+		zap.Int64("memory_limit_bytes", runtimeTuning.MemoryLimitBytes),
+		zap.Int("ballast_bytes", runtimeTuning.BallastBytes),
+		zap.Strings("from_environment", runtimeTuning.FromEnvironment),
+	)
+
+	// Restrict outbound connections to the address family before clients are created
+	network.Apply(cfg.Network.AddressFamily)
+	if cfg.Network.AddressFamily != network.Dual {
+		logger.Info("outbound connections restricted", zap.String("address_family", cfg.Network.AddressFamily))
+	}
+
 	// Create ring buffer
 	ringBuffer := buffer.New(cfg.Prometheus.BufferSize, logger)
 	logger.Info("ring buffer created", zap.Int("capacity", cfg.Prometheus.BufferSize))
 
-	// Create Prometheus pusher
+	// Pollers start on a wall-clock multiple of their interval when aligned and then tick every
+	// interval, so scrapes happen at the same moments on every device
+	aligned := func(intervalSeconds int, start func(ctx context.Context)) func(ctx context.Context) {
+		if !cfg.Scheduling.AlignToWallClock {
+			return start
+		}
+		offset := time.Duration(cfg.Scheduling.ScrapeOffsetSeconds) * time.Second
+		return schedule.Aligned(clock.Real, time.Duration(intervalSeconds)*time.Second, offset, start)
+	}
+
+	// Create event log
+	eventLog := events.NewLog(cfg.Events.Capacity, logger)
+	var grafanaSink *events.GrafanaSink
+	if cfg.Events.GrafanaAnnotations.Enabled {
+		grafanaSink = events.NewGrafanaSink(
+			cfg.Events.GrafanaAnnotations.URL,
+			cfg.Events.GrafanaAnnotations.Token,
+			[]string{"home-controller"},
+		)
+		eventLog.AddSink(grafanaSink)
+	}
+	if cfg.Events.Loki.Enabled {
+		eventLog.AddSink(events.NewLokiSink(lokiClient, lokiLabels))
+	}
+
+	// Create telemetry recorder if enabled; a nil recorder leaves HTTP clients uninstrumented
+	var telemetryRecorder *telemetry.Recorder
+	if cfg.Telemetry.DependencyMetrics || cfg.Telemetry.HTTPServerMetrics {
+		telemetryRecorder = telemetry.NewRecorder(ringBuffer, cfg.Telemetry.ReportIntervalSeconds, logger)
+	}
+	var recorder *telemetry.Recorder
+	if cfg.Telemetry.DependencyMetrics {
+		recorder = telemetryRecorder
+	}
+	if grafanaSink != nil {
+		grafanaSink.SetRecorder(recorder)
+	}
+
+	// Start shipping logs once the client is instrumented; entries logged until now are queued
+	if lokiClient != nil {
+		lokiClient.SetRecorder(recorder)
+	}
+	logShipperCtx, stopLogShipper := context.WithCancel(context.Background())
+	logShipperDone := make(chan struct{})
+	go func() {
+		defer close(logShipperDone)
+		if logShipper != nil {
+			logShipper.Start(logShipperCtx)
+		}
+	}()
+
+	// Detect metered links such as an LTE backup; pushers switch to a low-bandwidth profile on them
+	var metered metrics.Metered
+	var monitor *connectivity.Monitor
+	meteredInterval := time.Duration(cfg.Connectivity.MeteredPushIntervalSeconds) * time.Second
+	if cfg.Connectivity.Mode != connectivity.ModeUnmetered {
+		monitor = connectivity.NewMonitor(
+			cfg.Connectivity.Mode,
+			cfg.Connectivity.MeteredInterfaces,
+			time.Duration(cfg.Connectivity.CheckIntervalSeconds)*time.Second,
+			logger,
+		)
+		monitor.SetEventLog(eventLog)
+		metered = monitor
+	}
+
+	// Experimental features enabled on this device; their states label the build info series,
+	// so dashboards can compare devices with a feature on and off
+	featureFlags, err := features.Parse(cfg.Features.Enabled)
+	if err != nil {
+		exitcode.Fatal(logger, "invalid feature flags", exitcode.ConfigError(err))
+	}
+	if names := featureFlags.Names(); len(names) > 0 {
+		logger.Info("experimental features enabled", zap.Strings("features", names))
+	}
+	buildLabels := buildInfo.Labels()
+	maps.Copy(buildLabels, featureFlags.Labels())
+	maps.Copy(buildLabels, runtimeTuning.Labels())
+
+	// Fault injection for rehearsing alerts; a nil injector never injects a fault
+	var faultInjector *faults.Injector
+	if cfg.FaultInjection.Enabled {
+		faultInjector = faults.NewInjector(time.Duration(cfg.FaultInjection.MaxDurationSeconds)*time.Second, logger)
+		faultInjector.SetEventLog(eventLog)
+		logger.Warn("fault injection enabled", zap.Strings("faults", faults.Known()))
+	}
+
+	// Liveness watchdog; registered as a listener before any component adds readings
+	var serviceWatchdog *watchdog.Watchdog
+	if cfg.Watchdog.Enabled {
+		serviceWatchdog = watchdog.New(time.Duration(cfg.Watchdog.CheckIntervalSeconds)*time.Second, logger)
+		serviceWatchdog.SetHeartbeatFile(cfg.Watchdog.HeartbeatFile)
+		serviceWatchdog.SetExitOnStall(cfg.Watchdog.ExitOnStall)
+		readingTypes := make([]buffer.ReadingType, len(cfg.Watchdog.ReadingTypes))
+		for i, readingType := range cfg.Watchdog.ReadingTypes {
+			readingTypes[i] = buffer.ReadingType(readingType)
+		}
+		serviceWatchdog.WatchReadings(readingTypes, time.Duration(cfg.Watchdog.StallAfterSeconds)*time.Second)
+		ringBuffer.AddListener(serviceWatchdog.Observe)
+	}
+
+	// Resolve push and forward endpoints through a DNS cache, so clients move to a new address
+	// once DNS recovers instead of reusing connections to a dead one
+	var resolver *dnscache.Resolver
+	if cfg.DNS.CacheEnabled {
+		resolver = dnscache.New(
+			time.Duration(cfg.DNS.CacheTTLSeconds)*time.Second,
+			time.Duration(cfg.DNS.FallbackDelayMs)*time.Millisecond,
+			logger,
+		)
+		resolver.SetNetwork(network.TCP(cfg.Network.AddressFamily))
+	}
+
+	// Create Prometheus pusher; with additional endpoints each pusher gets its own queue fed by a fanout
+	pusherBuffer := ringBuffer
+	if len(cfg.Prometheus.AdditionalEndpoints) > 0 {
+		pusherBuffer = buffer.New(cfg.Prometheus.BufferSize, logger)
+	}
 	pusher := metrics.New(
 		cfg.Prometheus.URL,
 		cfg.Prometheus.Username,
 		cfg.Prometheus.Password,
-		ringBuffer,
+		pusherBuffer,
 		cfg.Prometheus.PushIntervalSeconds,
 		cfg.Prometheus.BatchSize,
 		logger,
 	)
+	var pushLog *metrics.PushLog
+	if cfg.Prometheus.PushLogPath != "" {
+		pushLog, err = metrics.OpenPushLog(cfg.Prometheus.PushLogPath, time.Duration(cfg.Prometheus.PushLogRetentionDays)*24*time.Hour, logger)
+		if err != nil {
+			exitcode.Fatal(logger, "failed to open push log", exitcode.DependencyError(err))
+		}
+		defer pushLog.Close()
+	}
+	pusher.SetEventLog(eventLog)
+	pusher.SetConnectionPool(cfg.Prometheus.MaxIdleConns, time.Duration(cfg.Prometheus.IdleConnTimeoutSeconds)*time.Second)
+	pusher.SetConnectionMetrics(cfg.Telemetry.ConnectionMetrics)
+	pusher.SetResolver(resolver, cfg.DNS.RecycleAfterFailures)
+	pusher.SetFaults(faultInjector)
+	pusher.SetRecorder(recorder)
+	pusher.SetPushLog(pushLog)
+	pusher.SetDerivedHumidity(cfg.BLE.DerivedHumidity)
+	tenantOverrides := make(map[buffer.ReadingType]string, len(cfg.Prometheus.TenantOverrides))
+	for readingType, tenant := range cfg.Prometheus.TenantOverrides {
+		tenantOverrides[buffer.ReadingType(readingType)] = tenant
+	}
+	pusher.SetTenant(cfg.Prometheus.TenantID, tenantOverrides)
+	pusher.SetProtocol(cfg.Prometheus.Protocol)
+	pusher.SetMaxSampleAge(time.Duration(cfg.Prometheus.MaxSampleAgeSeconds)*time.Second, cfg.Prometheus.RetimestampOldSamples)
+	pusher.SetDedupWindow(time.Duration(cfg.Prometheus.DedupWindowSeconds) * time.Second)
+	pusher.SetCoalesce(cfg.Prometheus.CoalesceMetrics, time.Duration(cfg.Prometheus.CoalesceMaxStalenessSeconds)*time.Second)
+	pusher.SetSeriesLimit(cfg.Prometheus.MaxSeriesPerMetric, cfg.Prometheus.SeriesLimitOverrides)
+	pusher.SetBuildInfo(buildLabels)
+	pusher.SetExternalLabels(cfg.Fleet.ExternalLabels())
+	if cfg.Prometheus.ProvenanceHeaders {
+		pusher.SetProvenance(buildInfo.Version, cfg.Fleet.DeviceID())
+	}
+	pusher.SetMeteredProfile(metered, meteredInterval, cfg.Connectivity.AggregatesOnly)
+	pusher.SetMaxBackoff(time.Duration(cfg.Prometheus.PushMaxBackoffSeconds) * time.Second)
+	pusher.SetAligned(cfg.Scheduling.AlignToWallClock)
+	if serviceWatchdog != nil && !cfg.Forward.Enabled {
+		// The push loop waits up to the longest backoff between cycles
+		pushSilence := 3 * max(time.Duration(cfg.Prometheus.PushIntervalSeconds)*time.Second, time.Duration(cfg.Prometheus.PushMaxBackoffSeconds)*time.Second)
+		pusher.SetHeartbeat(serviceWatchdog.Register("push", max(pushSilence, time.Duration(cfg.Watchdog.StallAfterSeconds)*time.Second)))
+	}
 	logger.Info("prometheus pusher initialized", zap.String("url", cfg.Prometheus.URL))
 
-	// Create context for graceful shutdown
+	// Satellite instances forward readings to a main instance instead of pushing to Prometheus
+	var forwarder *ingest.Forwarder
+	if cfg.Forward.Enabled {
+		forwarder = ingest.NewForwarder(
+			cfg.Forward.URL,
+			cfg.Forward.Token,
+			ringBuffer,
+			cfg.Forward.IntervalSeconds,
+			cfg.Prometheus.BatchSize,
+			logger,
+		)
+		forwarder.SetResolver(resolver, cfg.DNS.RecycleAfterFailures)
+		forwarder.SetRecorder(recorder)
+		logger.Info("forwarding readings to main instance", zap.String("url", cfg.Forward.URL))
+	}
+
+	var fanout *metrics.Fanout
+	if !cfg.Forward.Enabled && len(cfg.Prometheus.AdditionalEndpoints) > 0 {
+		pushers := []*metrics.Pusher{pusher}
+		for _, endpoint := range cfg.Prometheus.AdditionalEndpoints {
+			endpointPusher := metrics.New(
+				endpoint.URL,
+				endpoint.Username,
+				endpoint.Password,
+				buffer.New(endpoint.BufferSize, logger),
+				cfg.Prometheus.PushIntervalSeconds,
+				cfg.Prometheus.BatchSize,
+				logger,
+			)
+			endpointPusher.SetName(endpoint.Name)
+			endpointPusher.SetEventLog(eventLog)
+			endpointPusher.SetConnectionPool(cfg.Prometheus.MaxIdleConns, time.Duration(cfg.Prometheus.IdleConnTimeoutSeconds)*time.Second)
+			endpointPusher.SetConnectionMetrics(cfg.Telemetry.ConnectionMetrics)
+			endpointPusher.SetResolver(resolver, cfg.DNS.RecycleAfterFailures)
+			endpointPusher.SetFaults(faultInjector)
+			endpointPusher.SetRecorder(recorder)
+			endpointPusher.SetPushLog(pushLog)
+			endpointPusher.SetDerivedHumidity(cfg.BLE.DerivedHumidity)
+			endpointPusher.SetTenant(endpoint.TenantID, nil)
+			endpointPusher.SetProtocol(endpoint.Protocol)
+			endpointPusher.SetMaxSampleAge(time.Duration(endpoint.MaxSampleAgeSeconds)*time.Second, endpoint.RetimestampOldSamples)
+			endpointPusher.SetDedupWindow(time.Duration(endpoint.DedupWindowSeconds) * time.Second)
+			endpointPusher.SetCoalesce(cfg.Prometheus.CoalesceMetrics, time.Duration(cfg.Prometheus.CoalesceMaxStalenessSeconds)*time.Second)
+			endpointPusher.SetSeriesLimit(cfg.Prometheus.MaxSeriesPerMetric, cfg.Prometheus.SeriesLimitOverrides)
+			endpointPusher.SetBuildInfo(buildLabels)
+			endpointPusher.SetExternalLabels(cfg.Fleet.ExternalLabels())
+			if cfg.Prometheus.ProvenanceHeaders {
+				endpointPusher.SetProvenance(buildInfo.Version, cfg.Fleet.DeviceID())
+			}
+			endpointPusher.SetMeteredProfile(metered, meteredInterval, cfg.Connectivity.AggregatesOnly)
+			pushers = append(pushers, endpointPusher)
+			logger.Info("additional remote_write endpoint initialized",
+				zap.String("name", endpoint.Name),
+				zap.String("url", endpoint.URL),
+				zap.String("protocol", endpoint.Protocol),
+			)
+		}
+		fanout = metrics.NewFanout(ringBuffer, pushers, cfg.Prometheus.PushIntervalSeconds, logger)
+	}
+
+	// Create context cancelled by a failing component to trigger shutdown
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
@@ -66,8 +480,422 @@ func main() {
 	sigChan := make(chan os.Signal, 1)
 	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
 
-	// Create wait group for goroutines
-	var wg sync.WaitGroup
+	// Wait for dependencies that come up after the container, e.g. Wi-Fi and Bluetooth while the Pi
+	// boots, before starting components; a signal while waiting stops the service
+	if cfg.Startup.MaxWaitSeconds > 0 {
+		gate := startup.NewGate(time.Duration(cfg.Startup.MaxWaitSeconds)*time.Second, logger)
+		gate.SetBackoff(time.Second, time.Duration(cfg.Startup.MaxBackoffSeconds)*time.Second)
+		if cfg.Startup.WaitForBLE {
+			gate.Add("ble_adapter", func(ctx context.Context) error { return scanner.EnableAdapter() })
+		}
+		if cfg.Startup.WaitForDNS {
+			endpoint := cfg.Prometheus.URL
+			if cfg.Forward.Enabled {
+				endpoint = cfg.Forward.URL
+			}
+			if parsed, err := url.Parse(endpoint); err == nil && parsed.Hostname() != "" {
+				gate.Add("dns", startup.Resolve(parsed.Hostname()))
+			}
+		}
+		if cfg.Startup.WaitForPowerMeter && cfg.Power.Enabled {
+			meter := power.New(cfg.Power.ScrapeURL, time.Duration(cfg.Power.ScrapeTimeoutSeconds*float64(time.Second)), logger)
+			if err := meter.SetAuth(httpauth.Config(cfg.Power.Auth), httpauth.TLSConfig(cfg.Power.TLS)); err != nil {
+				exitcode.Fatal(logger, "failed to configure power scraper", exitcode.ConfigError(err))
+			}
+			gate.Add("power_meter", func(ctx context.Context) error {
+				_, err := meter.Scrape(ctx)
+				return err
+			})
+		}
+
+		gateCtx, stopGate := signal.NotifyContext(ctx, syscall.SIGINT, syscall.SIGTERM)
+		if err := gate.Wait(gateCtx); err != nil {
+			if gateCtx.Err() != nil {
+				logger.Info("stopped while waiting for dependencies")
+				return
+			}
+			if cfg.Startup.FailOnTimeout {
+				exitcode.Fatal(logger, "dependencies unavailable", exitcode.DependencyError(err))
+			}
+			logger.Warn("starting without all dependencies", zap.Error(err))
+		}
+		stopGate()
+	}
+
+	// Create runner; components are stopped by phase: intake, processing, output, telemetry
+	runner := lifecycle.New(logger)
+
+	// Infer the receiver nearest to each sensor and beacon; registered before any component adds readings
+	var bleLocator *locator.Locator
+	if cfg.Locator.Enabled {
+		beacons := make([]locator.Beacon, len(cfg.Locator.Beacons))
+		for i, beacon := range cfg.Locator.Beacons {
+			beacons[i] = locator.Beacon{Name: beacon.Name, MAC: beacon.MACAddress}
+		}
+		bleLocator = locator.New(
+			beacons,
+			cfg.Locator.Rooms,
+			cfg.Locator.StaleSeconds,
+			cfg.Locator.HysteresisDB,
+			ringBuffer,
+			cfg.Locator.ReportIntervalSeconds,
+			logger,
+		)
+		ringBuffer.AddListener(bleLocator.Observe)
+
+		runner.Go(lifecycle.PhaseProcessing, "locator", bleLocator.Start)
+	}
+
+	// Track whether someone is home to scrape less often and mute automation webhooks while away
+	var cadence schedule.Cadence
+	var occupancyTracker *occupancy.Tracker
+	if cfg.Occupancy.Enabled {
+		periods := make([]occupancy.Period, len(cfg.Occupancy.Schedule))
+		for i, period := range cfg.Occupancy.Schedule {
+			periods[i] = occupancy.Period{Days: period.Days, From: period.From, To: period.To}
+		}
+		var presence occupancy.Presence
+		if cfg.Occupancy.Source == occupancy.SourcePresence {
+			presence = bleLocator
+		}
+		tracker, err := occupancy.New(
+			cfg.Occupancy.Source,
+			periods,
+			presence,
+			cfg.Occupancy.AwayAfterSeconds,
+			cfg.Occupancy.AwayScrapeMultiplier,
+			ringBuffer,
+			cfg.Occupancy.ReportIntervalSeconds,
+			logger,
+		)
+		if err != nil {
+			exitcode.Fatal(logger, "failed to create occupancy tracker", exitcode.ConfigError(err))
+		}
+		tracker.SetEventLog(eventLog)
+		cadence = tracker
+		occupancyTracker = tracker
+
+		runner.Go(lifecycle.PhaseProcessing, "occupancy", tracker.Start)
+	}
+
+	// Restore the vacation or maintenance mode switched on the admin server
+	var modeSwitch *mode.Switch
+	if cfg.Mode.Enabled {
+		modeSwitch = mode.New(cfg.Mode.StateFile, logger)
+		if err := modeSwitch.Load(); err != nil {
+			logger.Warn("failed to load mode, starting in normal mode", zap.Error(err))
+		}
+		modeSwitch.SetEventLog(eventLog)
+		logger.Info("mode switch enabled", zap.String("mode", modeSwitch.State().Mode))
+	}
+
+	// Start expression rules if enabled; registered before any component adds readings
+	if cfg.Automation.Expressions.Enabled {
+		logger.Info("expression rules enabled", zap.Int("rule_count", len(cfg.Automation.Expressions.Rules)))
+
+		rules := make([]automation.ExpressionRule, len(cfg.Automation.Expressions.Rules))
+		for i, rule := range cfg.Automation.Expressions.Rules {
+			vars := make(map[string]automation.Selector, len(rule.Vars))
+			for name, selector := range rule.Vars {
+				vars[name] = automation.Selector{Metric: selector.Metric, Labels: selector.Labels}
+			}
+			rules[i] = automation.ExpressionRule{
+				Name:     rule.Name,
+				Vars:     vars,
+				Expr:     rule.Expr,
+				Metric:   rule.Metric,
+				Critical: rule.Critical,
+				Filters:  cfg.Automation.Expressions.VarFilters(rule),
+				Output:   cfg.Automation.Expressions.OutputFilter(rule),
+			}
+			if rule.Webhook.URL != "" {
+				webhook := automation.Webhook(rule.Webhook)
+				rules[i].Webhook = &webhook
+			}
+		}
+
+		engine, err := automation.NewEngine(
+			rules,
+			automation.NewWebhookCaller(time.Duration(cfg.Automation.WebhookTimeoutSeconds*float64(time.Second))),
+			ringBuffer,
+			logger,
+		)
+		if err != nil {
+			exitcode.Fatal(logger, "failed to compile expression rules", exitcode.ConfigError(err))
+		}
+		engine.SetEventLog(eventLog)
+		if occupancyTracker != nil && cfg.Occupancy.MuteAlertsWhenAway {
+			engine.SetOccupancy(occupancyTracker)
+		}
+		if modeSwitch != nil {
+			engine.SetMode(modeSwitch)
+		}
+		ringBuffer.AddListener(engine.Observe)
+
+		runner.Go(lifecycle.PhaseProcessing, "expressions", engine.Start)
+	}
+
+	// Fuse room temperatures if enabled; registered before any component adds readings
+	if cfg.RoomFusion.Enabled {
+		logger.Info("room fusion enabled", zap.Int("room_count", len(cfg.RoomFusion.Rooms)))
+
+		rooms := make([]fusion.Room, len(cfg.RoomFusion.Rooms))
+		for i, room := range cfg.RoomFusion.Rooms {
+			staleSeconds := room.StaleSeconds
+			if staleSeconds == 0 {
+				staleSeconds = cfg.RoomFusion.StaleSeconds
+			}
+			rooms[i] = fusion.Room{
+				Name: room.Name,
+				Primary: fusion.Source{
+					Name:     room.Primary.Name,
+					Selector: automation.Selector{Metric: room.Primary.Metric, Labels: room.Primary.Labels},
+				},
+				Secondary: fusion.Source{
+					Name:     room.Secondary.Name,
+					Selector: automation.Selector{Metric: room.Secondary.Metric, Labels: room.Secondary.Labels},
+				},
+				StaleAfter: time.Duration(staleSeconds) * time.Second,
+			}
+		}
+
+		fuser := fusion.New(rooms, ringBuffer, logger)
+		fuser.SetEventLog(eventLog)
+		ringBuffer.AddListener(fuser.Observe)
+	}
+
+	// Start hourly summaries if enabled; registered before any component adds readings
+	if cfg.Summary.Enabled {
+		logger.Info("hourly summaries enabled")
+
+		summarizer := summary.New(ringBuffer, logger)
+		ringBuffer.AddListener(summarizer.Observe)
+
+		runner.Go(lifecycle.PhaseProcessing, "summary", summarizer.Start)
+	}
+
+	// Detect sensors whose labels collide; registered before any component adds readings
+	var identityChecker *identity.Checker
+	if cfg.IdentityCheck.Enabled {
+		identityChecker = identity.New(ringBuffer, cfg.IdentityCheck.ReportIntervalSeconds, logger)
+		identityChecker.SetEventLog(eventLog)
+		for _, sensor := range cfg.BLE.Sensors {
+			identityChecker.Pin(sensor.MACAddress, sensor.Name, sensor.ID)
+		}
+		ringBuffer.AddListener(identityChecker.Observe)
+
+		runner.Go(lifecycle.PhaseProcessing, "identity_check", identityChecker.Start)
+	}
+
+	// Project BLE battery life from persisted history; registered before any component adds readings
+	var batteryEstimator *battery.Estimator
+	if cfg.BatteryEstimate.Enabled {
+		batteryEstimator = battery.New(
+			ringBuffer,
+			cfg.BatteryEstimate.WindowDays,
+			cfg.BatteryEstimate.AlertDays,
+			cfg.BatteryEstimate.StateFile,
+			cfg.BatteryEstimate.ReportIntervalSeconds,
+			logger,
+		)
+		batteryEstimator.SetEventLog(eventLog)
+		if err := batteryEstimator.Load(); err != nil {
+			logger.Warn("failed to load battery history, starting empty", zap.Error(err))
+		}
+		ringBuffer.AddListener(batteryEstimator.Observe)
+
+		runner.Go(lifecycle.PhaseProcessing, "battery_estimate", batteryEstimator.Start)
+	}
+
+	// Keep every reading on disk for GET /api/export and /api/history; registered before any component adds readings
+	var historyStore *history.Store
+	if cfg.History.Enabled {
+		if cfg.History.Backend == "sqlite" {
+			var err error
+			historyStore, err = history.NewSQLite(
+				filepath.Join(cfg.History.Dir, "history.db"),
+				cfg.History.RetentionDays,
+				cfg.History.FlushIntervalSeconds,
+				logger,
+			)
+			if err != nil {
+				exitcode.Fatal(logger, "failed to open history database", exitcode.DependencyError(err))
+			}
+		} else {
+			historyStore = history.New(
+				cfg.History.Dir,
+				cfg.History.RetentionDays,
+				cfg.History.FlushIntervalSeconds,
+				logger,
+			)
+		}
+		historyStore.SetSeriesBuilder(pusher.BuildSeries)
+		// Keep events past the in-memory log; event delivery starts below
+		if historyStore.KeepsEvents() {
+			eventLog.AddSink(historyStore)
+		}
+		ringBuffer.AddListener(historyStore.Observe)
+
+		runner.Go(lifecycle.PhaseProcessing, "history", historyStore.Start)
+	}
+
+	// Notification channels shared by the daily report and frost protection
+	var notifiers []notify.Notifier
+	if cfg.Notify.Telegram.Enabled {
+		telegram := notify.NewTelegramNotifier(cfg.Notify.Telegram.BotToken, cfg.Notify.Telegram.ChatID)
+		telegram.SetRecorder(recorder)
+		notifiers = append(notifiers, telegram)
+	}
+	if cfg.Notify.Email.Enabled {
+		notifiers = append(notifiers, notify.NewEmailNotifier(
+			cfg.Notify.Email.Host,
+			cfg.Notify.Email.Port,
+			cfg.Notify.Email.Username,
+			cfg.Notify.Email.Password,
+			cfg.Notify.Email.From,
+			cfg.Notify.Email.To,
+		))
+	}
+	if cfg.Notify.Webhook.Enabled {
+		webhook := notify.NewWebhookNotifier(cfg.Notify.Webhook.URL, cfg.Notify.Webhook.Token)
+		webhook.SetRecorder(recorder)
+		notifiers = append(notifiers, webhook)
+	}
+
+	// Summarise each day and deliver it through the notification channels; registered before any component adds readings
+	var dailyReporter *report.Reporter
+	if cfg.Report.Enabled {
+		var err error
+		dailyReporter, err = report.New(
+			cfg.Report.Title,
+			cfg.Report.SendAt,
+			cfg.Report.EnergyPriceKWh,
+			cfg.Report.Currency,
+			notifiers,
+			logger,
+		)
+		if err != nil {
+			exitcode.Fatal(logger, "failed to configure daily report", exitcode.ConfigError(err))
+		}
+		dailyReporter.SetEventLog(eventLog)
+		ringBuffer.AddListener(dailyReporter.Observe)
+
+		runner.Go(lifecycle.PhaseProcessing, "report", dailyReporter.Start)
+	}
+
+	// Watch for rooms below the frost floor; registered before any component adds readings and
+	// started with the Netatmo poller, whose rooms it forces to a safe setpoint
+	var frostFailsafe *frost.Failsafe
+	if cfg.FrostProtection.Enabled {
+		frostFailsafe = frost.New(
+			cfg.FrostProtection.FloorCelsius,
+			cfg.FrostProtection.SetpointCelsius,
+			cfg.FrostProtection.HoldMinutes,
+			cfg.FrostProtection.Exclude,
+			notifiers,
+			logger,
+		)
+		frostFailsafe.SetEventLog(eventLog)
+		ringBuffer.AddListener(frostFailsafe.Observe)
+	}
+
+	// Recommend ventilating while indoor air is more humid than outdoor air; registered before any
+	// component adds readings
+	if cfg.Automation.Ventilation.Enabled {
+		ventilationCfg := cfg.Automation.Ventilation
+		advisor := ventilation.New(
+			ventilationCfg.IndoorSensors,
+			ventilationCfg.OutdoorSensor,
+			ventilation.Thresholds{
+				OnDelta:           ventilationCfg.OnDeltaGramsPerCubicMeter,
+				OffDelta:          ventilationCfg.OffDeltaGramsPerCubicMeter,
+				MinIndoorHumidity: ventilationCfg.MinIndoorHumidityPercent,
+			},
+			automation.Webhook(ventilationCfg.On),
+			automation.Webhook(ventilationCfg.Off),
+			automation.NewWebhookCaller(time.Duration(cfg.Automation.WebhookTimeoutSeconds*float64(time.Second))),
+			ringBuffer,
+			ventilationCfg.EvaluateIntervalSeconds,
+			logger,
+		)
+		advisor.SetEventLog(eventLog)
+		ringBuffer.AddListener(advisor.Observe)
+
+		runner.Go(lifecycle.PhaseProcessing, "ventilation", advisor.Start)
+	}
+
+	// Keep the latest value of every series for the versioned REST API
+	var latestReadings *restapi.Latest
+	if cfg.Admin.Enabled {
+		latestReadings = restapi.NewLatest()
+		ringBuffer.AddListener(latestReadings.Observe)
+	}
+
+	// Publish to NATS through a pusher with its own queue, copied from the buffer as readings arrive,
+	// so unconfirmed readings are retried like failed pushes
+	var natsPublisher *nats.Publisher
+	var natsQueue *buffer.RingBuffer
+	var natsOutput metrics.Output
+	if cfg.NATS.Enabled {
+		natsPublisher, err = nats.Connect(
+			nats.Options{
+				URL:      cfg.NATS.Server,
+				Name:     "home-controller",
+				Username: cfg.NATS.Username,
+				Password: cfg.NATS.Password,
+				Token:    cfg.NATS.Token,
+			},
+			nats.PublisherOptions{
+				SubjectPrefix: cfg.NATS.SubjectPrefix,
+				Encoding:      cfg.NATS.Encoding,
+				JetStream:     cfg.NATS.JetStream,
+				AckTimeout:    time.Duration(cfg.NATS.AckTimeoutSeconds) * time.Second,
+			},
+			logger,
+		)
+		if err != nil {
+			exitcode.Fatal(logger, "failed to create NATS publisher", exitcode.ConfigError(err))
+		}
+		natsQueue = buffer.New(cfg.NATS.QueueSize, logger)
+		ringBuffer.AddListener(func(reading *buffer.Reading) {
+			natsQueue.Add(reading)
+		})
+		natsPusher := metrics.New("", "", "", natsQueue, cfg.NATS.IntervalSeconds, cfg.Prometheus.BatchSize, logger.Named("nats"))
+		natsPusher.SetName("nats")
+		natsPusher.SetSink(natsPublisher)
+		natsPusher.SetEventLog(eventLog)
+		natsOutput = natsPusher
+	}
+
+	// Start event sink delivery and address monitoring
+	runner.Go(lifecycle.PhaseTelemetry, "events", eventLog.Start)
+	if cfg.Events.IPCheckIntervalSeconds > 0 {
+		runner.Go(lifecycle.PhaseTelemetry, "ip_watch", func(ctx context.Context) {
+			eventLog.WatchAddresses(ctx, time.Duration(cfg.Events.IPCheckIntervalSeconds)*time.Second)
+		})
+	}
+
+	// Watch the default route until outputs have stopped
+	if monitor != nil {
+		runner.Go(lifecycle.PhaseTelemetry, "connectivity", monitor.Start)
+	}
+
+	// Start telemetry metrics reporter if enabled
+	if telemetryRecorder != nil {
+		runner.Go(lifecycle.PhaseTelemetry, "telemetry", telemetryRecorder.Start)
+	}
+
+	// Check for a new remote configuration; once cached, it is applied by a restart
+	var remoteConfigChanged <-chan struct{}
+	if cfg.RemoteConfig.Enabled {
+		remoteConfig, err := remoteconfig.New(remoteConfigOptions(cfg.RemoteConfig), validateConfig, logger)
+		if err != nil {
+			exitcode.Fatal(logger, "failed to create remote config fetcher", exitcode.ConfigError(err))
+		}
+		remoteConfig.SetEventLog(eventLog)
+		remoteConfigChanged = remoteConfig.Changed()
+		runner.Go(lifecycle.PhaseTelemetry, "remote_config", remoteConfig.Start)
+	}
 
 	// Convert config sensors to scanner format
 	scannerSensors := make([]scanner.SensorConfig, len(cfg.BLE.Sensors))
@@ -79,17 +907,39 @@ func main() {
 		}
 	}
 
-	// Start BLE scanner in goroutine
+	// Start BLE scanner; scanning blocks until stopped, so stop it as soon as intake stops
+	// A failed scanner shuts the service down and exits with a dependency failure once stopped
+	var scanErr error
 	bleScanner := scanner.New(scannerSensors, ringBuffer, logger)
-	wg.Add(1)
-	go func() {
-		defer wg.Done()
-		err := bleScanner.Start(ctx)
-		if err != nil {
+	bleScanner.SetEventLog(eventLog)
+	bleScanner.SetReceiver(cfg.BLEReceiverName())
+	bleScanner.SetLocator(bleLocator)
+	if cfg.BLE.Backlog.Mode != scanner.BacklogOff {
+		backlog := scanner.NewBacklog(
+			cfg.BLE.Backlog.Mode,
+			time.Duration(cfg.BLE.Backlog.GapSeconds)*time.Second,
+			time.Duration(cfg.BLE.Backlog.SettleSeconds)*time.Second,
+			time.Duration(cfg.BLE.Backlog.MaxAgeSeconds)*time.Second,
+			logger,
+		)
+		bleScanner.SetBacklog(backlog)
+		runner.Go(lifecycle.PhaseIntake, "ble_backlog", backlog.Start)
+	}
+	runner.Go(lifecycle.PhaseIntake, "ble_scanner", func(scanCtx context.Context) {
+		stopScan := context.AfterFunc(scanCtx, func() {
+			logger.Info("stopping BLE scanner")
+			if err := bleScanner.Stop(); err != nil {
+				logger.Error("failed to stop BLE scanner", zap.Error(err))
+			}
+		})
+		defer stopScan()
+
+		if err := bleScanner.Start(scanCtx); err != nil {
 			logger.Error("BLE scanner failed", zap.Error(err))
-			cancel() // Cancel context to stop other goroutines
+			scanErr = err
+			cancel() // Trigger shutdown of the other components
 		}
-	}()
+	})
 
 	// Start Netatmo poller if enabled
 	if cfg.Netatmo.Enabled {
@@ -100,6 +950,16 @@ func main() {
 			cfg.Netatmo.ClientSecret,
 			cfg.Netatmo.RefreshToken,
 		)
+		netatmoFetcher.SetTimestampSource(cfg.Netatmo.TimestampSource)
+		netatmoFetcher.SetFaults(faultInjector)
+		netatmoFetcher.SetRecorder(recorder)
+		if modeSwitch != nil {
+			modeSwitch.SetHeating(netatmoFetcher, cfg.Mode.VacationHeatingMode)
+		}
+		if frostFailsafe != nil {
+			frostFailsafe.SetThermostat(netatmoFetcher)
+			runner.Go(lifecycle.PhaseProcessing, "frost_protection", frostFailsafe.Start)
+		}
 
 		netatmoPoller := netatmo.NewPoller(
 			netatmoFetcher,
@@ -108,16 +968,31 @@ func main() {
 			logger,
 		)
 
-		wg.Add(1)
-		go func() {
-			defer wg.Done()
-			netatmoPoller.Start(ctx)
-		}()
+		netatmoPoller.SetCadence(cadence)
+		if cfg.Netatmo.Backfill.Enabled {
+			// Samples older than the pusher's age limit would be dropped anyway
+			window := time.Duration(cfg.Netatmo.Backfill.WindowSeconds) * time.Second
+			if maxAge := time.Duration(cfg.Prometheus.MaxSampleAgeSeconds) * time.Second; maxAge > 0 && maxAge < window {
+				window = maxAge
+			}
+			backfill := netatmo.NewBackfill(netatmoFetcher, cfg.Netatmo.Backfill.StateFile, window, logger)
+			netatmoPoller.SetBackfill(backfill)
+			runner.Go(lifecycle.PhaseIntake, "netatmo_backfill", func(ctx context.Context) {
+				backfill.Track(ctx, pusher, time.Minute)
+			})
+			// Runs after the final push, which Track stopped before
+			runner.OnStop(lifecycle.PhaseTelemetry, "record Netatmo backfill", lifecycle.DefaultStopTimeout, func(ctx context.Context) error {
+				backfill.Record(pusher)
+				return nil
+			})
+		}
+		runner.Go(lifecycle.PhaseIntake, "netatmo", aligned(cfg.Netatmo.FetchInterval, netatmoPoller.Start))
 	} else {
 		logger.Info("netatmo integration disabled")
 	}
 
 	// Start Power poller if enabled
+	var reconciler *pstryk.Reconciler
 	if cfg.Power.Enabled {
 		logger.Info("power monitoring enabled, starting poller")
 
@@ -126,25 +1001,432 @@ func main() {
 			time.Duration(cfg.Power.ScrapeTimeoutSeconds*float64(time.Second)),
 			logger,
 		)
+		if err := powerScraper.SetAuth(httpauth.Config(cfg.Power.Auth), httpauth.TLSConfig(cfg.Power.TLS)); err != nil {
+			exitcode.Fatal(logger, "failed to configure power scraper", exitcode.ConfigError(err))
+		}
+		powerScraper.SetFaults(faultInjector)
+		powerScraper.SetRecorder(recorder)
 
 		powerPoller := power.NewPoller(
 			powerScraper,
 			ringBuffer,
 			cfg.Power.ScrapeIntervalSeconds,
+			cfg.Power.StaleRepeatIntervals,
 			logger,
 		)
 
-		wg.Add(1)
-		go func() {
-			defer wg.Done()
-			powerPoller.Start(ctx)
-		}()
+		// Start load shedding if enabled
+		if cfg.Automation.LoadShedding.Enabled {
+			logger.Info("load shedding enabled", zap.Int("rule_count", len(cfg.Automation.LoadShedding.Rules)))
+
+			rules := make([]automation.LoadShedRule, len(cfg.Automation.LoadShedding.Rules))
+			for i, rule := range cfg.Automation.LoadShedding.Rules {
+				rules[i] = automation.LoadShedRule{
+					Name:              rule.Name,
+					SensorID:          rule.SensorID,
+					ThresholdWatts:    rule.ThresholdWatts,
+					For:               time.Duration(rule.ForSeconds) * time.Second,
+					Shed:              automation.Webhook(rule.Shed),
+					RestoreBelowWatts: rule.RestoreBelowWatts,
+					RestoreFor:        time.Duration(rule.RestoreForSeconds) * time.Second,
+					Restore:           automation.Webhook(rule.Restore),
+				}
+			}
+
+			loadShedder := automation.NewLoadShedder(
+				rules,
+				automation.NewWebhookCaller(time.Duration(cfg.Automation.WebhookTimeoutSeconds*float64(time.Second))),
+				ringBuffer,
+				logger,
+			)
+			loadShedder.SetEventLog(eventLog)
+			powerPoller.AddObserver(loadShedder)
+
+			runner.Go(lifecycle.PhaseProcessing, "load_shedding", loadShedder.Start)
+		}
+
+		// Compare locally integrated energy with the official smart meter if enabled
+		if cfg.Pstryk.Enabled {
+			logger.Info("pstryk reconciliation enabled", zap.Ints("sensor_ids", cfg.Pstryk.SensorIDs))
+
+			pstrykClient := pstryk.NewClient(cfg.Pstryk.Token)
+			pstrykClient.SetRecorder(recorder)
+
+			reconciler = pstryk.New(
+				pstrykClient,
+				cfg.Pstryk.SensorIDs,
+				ringBuffer,
+				cfg.Pstryk.IntervalSeconds,
+				cfg.Pstryk.LookbackHours,
+				cfg.Pstryk.DriftAlertPercent,
+				cfg.Power.ScrapeIntervalSeconds,
+				logger,
+			)
+			reconciler.SetEventLog(eventLog)
+			powerPoller.AddObserver(reconciler)
+
+			runner.Go(lifecycle.PhaseProcessing, "pstryk", reconciler.Start)
+		}
+
+		powerPoller.SetCadence(cadence)
+		runner.Go(lifecycle.PhaseIntake, "power", aligned(cfg.Power.ScrapeIntervalSeconds, powerPoller.Start))
 	} else {
 		logger.Info("power monitoring disabled")
 	}
 
-	// Wait for START_AT_EVEN_SECOND if configured
-	if cfg.Prometheus.StartAtEvenSecond {
+	// Start the day-ahead electricity price collector if enabled
+	var priceCollector *prices.Collector
+	if cfg.Prices.Enabled {
+		logger.Info("electricity prices enabled", zap.String("source", cfg.Prices.Source))
+
+		var priceSource prices.Source
+		switch cfg.Prices.Source {
+		case "entsoe":
+			entsoe := prices.NewENTSOE(cfg.Prices.EntsoeToken, cfg.Prices.EntsoeArea, cfg.Prices.EURPLNRate)
+			entsoe.SetRecorder(recorder)
+			priceSource = entsoe
+		default:
+			pse := prices.NewPSE()
+			pse.SetRecorder(recorder)
+			priceSource = pse
+		}
+
+		priceCollector = prices.New(
+			priceSource,
+			ringBuffer,
+			cfg.Prices.FetchIntervalSeconds,
+			cfg.Prices.ReportIntervalSeconds,
+			cfg.Prices.HorizonHours,
+			logger,
+		)
+
+		runner.Go(lifecycle.PhaseIntake, "prices", priceCollector.Start)
+
+		// Start price rules if enabled
+		if cfg.Automation.PriceRules.Enabled {
+			logger.Info("price rules enabled", zap.Int("rule_count", len(cfg.Automation.PriceRules.Rules)))
+
+			rules := make([]automation.PriceRule, len(cfg.Automation.PriceRules.Rules))
+			for i, rule := range cfg.Automation.PriceRules.Rules {
+				rules[i] = automation.PriceRule{
+					Name:          rule.Name,
+					BelowPrice:    rule.BelowPLNPerKWh,
+					CheapestHours: rule.CheapestHours,
+					Enable:        automation.Webhook(rule.Enable),
+					Disable:       automation.Webhook(rule.Disable),
+				}
+			}
+
+			priceScheduler := automation.NewPriceScheduler(
+				rules,
+				automation.NewWebhookCaller(time.Duration(cfg.Automation.WebhookTimeoutSeconds*float64(time.Second))),
+				priceCollector,
+				ringBuffer,
+				cfg.Automation.PriceRules.EvaluateIntervalSeconds,
+				logger,
+			)
+			priceScheduler.SetEventLog(eventLog)
+
+			runner.Go(lifecycle.PhaseProcessing, "price_rules", priceScheduler.Start)
+		}
+	}
+
+	// Start HeatPump poller if enabled
+	if cfg.HeatPump.Enabled {
+		logger.Info("heat pump monitoring enabled, starting poller")
+
+		metrics := make([]heatpump.MetricConfig, len(cfg.HeatPump.Metrics))
+		for i, metric := range cfg.HeatPump.Metrics {
+			metrics[i] = heatpump.MetricConfig{
+				Name:     metric.Name,
+				Path:     metric.Path,
+				Register: metric.Register,
+				Words:    metric.Words,
+				Signed:   metric.Signed,
+				Scale:    metric.Scale,
+			}
+		}
+
+		timeout := time.Duration(cfg.HeatPump.TimeoutSeconds * float64(time.Second))
+		var source heatpump.Source
+		if cfg.HeatPump.Protocol == heatpump.ProtocolModbus {
+			source = heatpump.NewModbusSource(cfg.HeatPump.Address, byte(cfg.HeatPump.UnitID), timeout)
+		} else {
+			httpSource, err := heatpump.NewHTTPSource(
+				cfg.HeatPump.Address,
+				timeout,
+				httpauth.Config(cfg.HeatPump.Auth),
+				httpauth.TLSConfig(cfg.HeatPump.TLS),
+			)
+			if err != nil {
+				exitcode.Fatal(logger, "failed to configure heat pump source", exitcode.ConfigError(err))
+			}
+			httpSource.SetFaults(faultInjector)
+			httpSource.SetRecorder(recorder)
+			source = httpSource
+		}
+
+		heatPumpPoller := heatpump.NewPoller(
+			source,
+			metrics,
+			ringBuffer,
+			cfg.HeatPump.PollIntervalSeconds,
+			logger,
+		)
+
+		heatPumpPoller.SetCadence(cadence)
+		runner.Go(lifecycle.PhaseIntake, "heatpump", aligned(cfg.HeatPump.PollIntervalSeconds, heatPumpPoller.Start))
+	} else {
+		logger.Info("heat pump monitoring disabled")
+	}
+
+	// Start the collectors enabled in the configuration; each registers its factory from init, so
+	// linking its package is all a new data source needs
+	for _, entry := range cfg.EnabledCollectors() {
+		logger.Info("collector enabled, starting", zap.String("collector", entry.Name))
+
+		source, err := collector.New(entry.Name, collector.Spec{
+			Interval: time.Duration(entry.IntervalSeconds) * time.Second,
+			Options:  collector.NewOptions(entry.Options),
+		}, collector.Deps{
+			Buffer:  ringBuffer,
+			Cadence: cadence,
+			Logger:  logger,
+		})
+		if err != nil {
+			exitcode.Fatal(logger, "failed to create collector", exitcode.ConfigError(err), zap.String("collector", entry.Name))
+		}
+
+		runner.Go(lifecycle.PhaseIntake, entry.Name, aligned(entry.IntervalSeconds, source.Start))
+	}
+
+	// Start Zigbee2MQTT ingestion if enabled
+	if cfg.Zigbee2MQTT.Enabled {
+		logger.Info("Zigbee2MQTT enabled, starting MQTT client")
+
+		zigbeeIngester := zigbee2mqtt.NewIngester(cfg.Zigbee2MQTT.BaseTopic, ringBuffer, logger)
+		mqttClient, err := mqtt.NewClient(
+			mqttOptions(cfg.Zigbee2MQTT.MQTT),
+			zigbeeIngester.Topics(),
+			zigbeeIngester.HandleMessage,
+			logger,
+		)
+		if err != nil {
+			exitcode.Fatal(logger, "failed to create Zigbee2MQTT MQTT client", exitcode.ConfigError(err))
+		}
+
+		runner.Go(lifecycle.PhaseIntake, "zigbee2mqtt", mqttClient.Start)
+	} else {
+		logger.Info("Zigbee2MQTT disabled")
+	}
+
+	// Create BLE proxy ingestion if enabled; the HTTP endpoint is registered with the admin server below
+	var bleProxy *bleproxy.Proxy
+	if cfg.BLEProxy.Enabled {
+		logger.Info("BLE proxy ingestion enabled")
+
+		proxySensors := make([]bleproxy.SensorConfig, len(cfg.BLE.Sensors))
+		for i, sensor := range cfg.BLE.Sensors {
+			proxySensors[i] = bleproxy.SensorConfig(sensor)
+		}
+		bleProxy = bleproxy.New(proxySensors, cfg.BLEProxy.BaseTopic, ringBuffer, logger)
+		bleProxy.SetEventLog(eventLog)
+		bleProxy.SetLocator(bleLocator)
+
+		if cfg.BLEProxy.MQTT.Broker != "" {
+			proxyClient, err := mqtt.NewClient(
+				mqttOptions(cfg.BLEProxy.MQTT),
+				bleProxy.Topics(),
+				bleProxy.HandleMessage,
+				logger,
+			)
+			if err != nil {
+				exitcode.Fatal(logger, "failed to create BLE proxy MQTT client", exitcode.ConfigError(err))
+			}
+
+			runner.Go(lifecycle.PhaseIntake, "ble_proxy", proxyClient.Start)
+		}
+	} else {
+		logger.Info("BLE proxy ingestion disabled")
+	}
+
+	// Create admin server if enabled; collectors register their endpoints on it
+	var adminServer *admin.Server
+	if cfg.Admin.Enabled {
+		adminServer = admin.New(cfg.Admin.ListenAddress, logger)
+		if cfg.Admin.TLS.CertFile != "" {
+			if err := adminServer.SetTLS(cfg.Admin.TLS.CertFile, cfg.Admin.TLS.KeyFile, cfg.Admin.TLS.ClientCAFile); err != nil {
+				exitcode.Fatal(logger, "failed to configure admin TLS", exitcode.ConfigError(err))
+			}
+		} else if cfg.Admin.TLS.SelfSigned {
+			if err := adminServer.SetSelfSignedTLS(cfg.Admin.TLS.ClientCAFile); err != nil {
+				exitcode.Fatal(logger, "failed to configure admin TLS", exitcode.ConfigError(err))
+			}
+		}
+		authGroups := make([]admin.AuthGroup, len(cfg.Admin.AuthGroups))
+		for i, group := range cfg.Admin.AuthGroups {
+			authGroups[i] = admin.AuthGroup{PathPrefix: group.PathPrefix, Auth: admin.Auth(group.Auth)}
+		}
+		adminServer.SetAuth(admin.Auth(cfg.Admin.Auth), authGroups)
+		if cfg.Telemetry.HTTPServerMetrics {
+			adminServer.SetObserver(telemetryRecorder)
+		}
+		eventLog.RegisterHandlers(adminServer)
+		version.RegisterHandlers(adminServer)
+		if recorder != nil {
+			recorder.RegisterHandlers(adminServer)
+		}
+		ringBuffer.RegisterHandlers(adminServer)
+		if faultInjector != nil {
+			faultInjector.RegisterHandlers(adminServer)
+		}
+		if pushLog != nil {
+			pushLog.RegisterHandlers(adminServer)
+		}
+		if identityChecker != nil {
+			identityChecker.RegisterHandlers(adminServer)
+		}
+		if batteryEstimator != nil {
+			batteryEstimator.RegisterHandlers(adminServer)
+		}
+		if bleLocator != nil {
+			bleLocator.RegisterHandlers(adminServer)
+		}
+		if modeSwitch != nil {
+			modeSwitch.RegisterHandlers(adminServer)
+		}
+		if dailyReporter != nil {
+			dailyReporter.RegisterHandlers(adminServer)
+		}
+		if historyStore != nil {
+			historyStore.RegisterHandlers(adminServer)
+		}
+		if reconciler != nil {
+			reconciler.RegisterHandlers(adminServer)
+		}
+		if priceCollector != nil {
+			priceCollector.RegisterHandlers(adminServer)
+		}
+	}
+
+	// Accept readings forwarded by satellite instances if enabled
+	if cfg.Ingest.Enabled {
+		logger.Info("readings ingestion enabled", zap.Bool("token_set", cfg.Ingest.Token != ""))
+		ingest.NewReceiver(ringBuffer, cfg.Ingest.Token, logger).RegisterHandlers(adminServer)
+	}
+
+	// Out-of-cycle pushes go to the output that receives the readings
+	var output metrics.Output = pusher
+	if forwarder != nil {
+		output = forwarder
+	} else if fanout != nil {
+		output = fanout
+	}
+	if adminServer != nil {
+		metrics.RegisterPushHandler(adminServer, output)
+
+		// Versioned REST API for Home Assistant and Node-RED, documented at /api/v1/openapi.json
+		// A forwarding satellite doesn't push itself, so its health skips the push age
+		var lastPusher restapi.LastPusher
+		if forwarder == nil {
+			lastPusher = pusher
+		}
+		pushStaleAfter := 5 * time.Duration(cfg.Prometheus.PushIntervalSeconds) * time.Second
+		if cfg.Connectivity.Mode != "unmetered" {
+			pushStaleAfter = max(pushStaleAfter, 5*meteredInterval)
+		}
+		restAPI := restapi.New()
+		latestReadings.Register(restAPI)
+		health := restapi.NewHealth(ringBuffer, lastPusher, pushStaleAfter)
+		health.SetFeatures(featureFlags.Names())
+		health.Register(restAPI)
+		restapi.RegisterPushAction(restAPI, output)
+		restAPI.RegisterHandlers(adminServer)
+	}
+
+	// Accept Prometheus remote_write from other devices and merge it into the push stream
+	if cfg.RemoteWrite.Enabled {
+		logger.Info("remote_write receiver enabled", zap.Bool("token_set", cfg.RemoteWrite.Token != ""))
+		ingest.NewRemoteWriteReceiver(ringBuffer, cfg.RemoteWrite.Token, logger).RegisterHandlers(adminServer)
+	}
+
+	// Accept Pushgateway-style pushes from local scripts if enabled
+	if cfg.Pushgateway.Enabled {
+		logger.Info("pushgateway receiver enabled", zap.Bool("token_set", cfg.Pushgateway.Token != ""))
+		ingest.NewPushgatewayReceiver(ringBuffer, cfg.Pushgateway.Token, logger).RegisterHandlers(adminServer)
+	}
+
+	// Accept advertisements from ESP32 BLE proxies over HTTP
+	if bleProxy != nil && adminServer != nil {
+		bleproxy.NewHandler(bleProxy, cfg.BLEProxy.Token).RegisterHandlers(adminServer)
+	}
+
+	// Start air quality poller if enabled
+	if cfg.AirQuality.Enabled {
+		logger.Info("air quality sensors enabled, starting poller")
+
+		airQualitySensors := make([]airquality.SensorConfig, len(cfg.AirQuality.Sensors))
+		for i, sensor := range cfg.AirQuality.Sensors {
+			airQualitySensors[i] = airquality.SensorConfig(sensor)
+		}
+
+		airQualityPoller := airquality.NewPoller(
+			airQualitySensors,
+			ringBuffer,
+			cfg.AirQuality.ReadIntervalSeconds,
+			logger,
+		)
+		if adminServer != nil {
+			airQualityPoller.RegisterHandlers(adminServer)
+		}
+
+		airQualityPoller.SetCadence(cadence)
+		runner.Go(lifecycle.PhaseIntake, "airquality", aligned(cfg.AirQuality.ReadIntervalSeconds, airQualityPoller.Start))
+	} else {
+		logger.Info("air quality sensors disabled")
+	}
+
+	// Elect a leader among redundant collectors; only the leader pushes, the standby keeps buffering
+	var elector *leader.Elector
+	if cfg.Leader.Enabled {
+		id := cfg.Leader.ID
+		if id == "" {
+			id, _ = os.Hostname()
+		}
+		ttl := time.Duration(cfg.Leader.TTLSeconds) * time.Second
+		if cfg.Leader.LeaseURL != "" {
+			elector = leader.NewRemoteElector(id, cfg.Leader.LeaseURL, cfg.Leader.Token, ttl, logger)
+		} else {
+			lease := leader.NewLease(cfg.Leader.Token)
+			lease.RegisterHandlers(adminServer)
+			elector = leader.NewLocalElector(id, lease, ttl, logger)
+		}
+		elector.SetEventLog(eventLog)
+		pusher.SetLeader(elector)
+		if fanout != nil {
+			fanout.SetLeader(elector)
+		}
+		logger.Info("leader election enabled", zap.String("id", id), zap.String("lease_url", cfg.Leader.LeaseURL))
+
+		// Telemetry stops last, so the lease is kept through the final push and released after it
+		runner.Go(lifecycle.PhaseTelemetry, "leader", elector.Start)
+	}
+
+	// Start admin server if enabled
+	if adminServer != nil {
+		logger.Info("admin server enabled, starting")
+
+		runner.Go(lifecycle.PhaseIntake, "admin", func(ctx context.Context) {
+			if err := adminServer.Start(ctx); err != nil {
+				logger.Error("admin server stopped", zap.Error(err))
+			}
+		})
+	} else {
+		logger.Info("admin server disabled")
+	}
+
+	// Wait for START_AT_EVEN_SECOND if configured; aligned pushes wait for their own boundary
+	if cfg.Prometheus.StartAtEvenSecond && !cfg.Scheduling.AlignToWallClock {
 		now := time.Now()
 		nextEvenSecond := now.Truncate(time.Second).Add(time.Second)
 		waitDuration := nextEvenSecond.Sub(now)
@@ -155,48 +1437,333 @@ func main() {
 		time.Sleep(waitDuration)
 	}
 
-	// Start Prometheus pusher
-	wg.Add(1)
-	go func() {
-		defer wg.Done()
-		pusher.Start(ctx)
-	}()
+	// Close the buffer once intake and processing have stopped, so a producer that missed the stop
+	// signal can't add readings after the final push drained them; producers stop on ErrClosed
+	runner.OnStop(lifecycle.PhaseProcessing, "close buffer", lifecycle.DefaultStopTimeout, func(ctx context.Context) error {
+		ringBuffer.Close()
+		return nil
+	})
+
+	// Stops with intake, so readings ending at shutdown don't count as a stall
+	if serviceWatchdog != nil {
+		runner.Go(lifecycle.PhaseIntake, "watchdog", serviceWatchdog.Start)
+	}
+
+	// Start Prometheus pusher; the final push runs once intake and processing have stopped
+	if forwarder != nil {
+		runner.Go(lifecycle.PhaseOutput, "forwarder", forwarder.Start)
+		runner.OnStop(lifecycle.PhaseOutput, "final forward", lifecycle.DefaultStopTimeout, func(ctx context.Context) error {
+			readings := ringBuffer.GetAll()
+			if err := forwarder.Forward(ctx, readings); err != nil {
+				return fmt.Errorf("failed final readings forward: %w", err)
+			}
+			logger.Info("final readings forward successful", zap.Int("reading_count", len(readings)))
+			return nil
+		})
+	} else if fanout != nil {
+		runner.Go(lifecycle.PhaseOutput, "fanout", fanout.Start)
+		runner.OnStop(lifecycle.PhaseOutput, "final push", lifecycle.DefaultStopTimeout, func(ctx context.Context) error {
+			if elector != nil && !elector.IsLeader() {
+				return nil
+			}
+			if err := fanout.Flush(ctx); err != nil {
+				return fmt.Errorf("failed final metrics push: %w", err)
+			}
+			logger.Info("final metrics push successful")
+			return nil
+		})
+	} else {
+		runner.Go(lifecycle.PhaseOutput, "pusher", pusher.Start)
+		runner.OnStop(lifecycle.PhaseOutput, "final push", lifecycle.DefaultStopTimeout, func(ctx context.Context) error {
+			readings := ringBuffer.GetAll()
+			if len(readings) == 0 || (elector != nil && !elector.IsLeader()) {
+				return nil
+			}
+			if err := pusher.Push(ctx, readings); err != nil {
+				return fmt.Errorf("failed final metrics push: %w", err)
+			}
+			logger.Info("final metrics push successful", zap.Int("reading_count", len(readings)))
+			return nil
+		})
+	}
+
+	// Publish to NATS; the final publish runs once intake and processing have stopped
+	if natsOutput != nil {
+		runner.Go(lifecycle.PhaseOutput, "nats", natsOutput.Start)
+		runner.OnStop(lifecycle.PhaseOutput, "final NATS publish", lifecycle.DefaultStopTimeout, func(ctx context.Context) error {
+			defer natsPublisher.Close()
+			if err := natsPublisher.Push(ctx, natsQueue.GetAll()); err != nil {
+				return fmt.Errorf("failed final NATS publish: %w", err)
+			}
+			return nil
+		})
+	}
+
+	// Push early when the buffer fills past the watermark instead of waiting for the next tick
+	if cfg.Prometheus.PushWatermarkPercent > 0 {
+		watermark := ringBuffer.Watermark(cfg.Prometheus.PushWatermarkPercent)
+		runner.Go(lifecycle.PhaseOutput, "push_watermark", func(ctx context.Context) {
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case <-watermark:
+					logger.Info("buffer passed the push watermark",
+						zap.Int("watermark_percent", cfg.Prometheus.PushWatermarkPercent),
+						zap.Int("buffered", ringBuffer.Size()),
+					)
+					output.Trigger()
+				}
+			}
+		})
+	}
+
+	// SIGUSR2 triggers an immediate push, like POST /api/push-now
+	pushSigChan := make(chan os.Signal, 1)
+	signal.Notify(pushSigChan, syscall.SIGUSR2)
+	runner.Go(lifecycle.PhaseOutput, "push_signal", func(ctx context.Context) {
+		for {
+			select {
+			case <-ctx.Done():
+				signal.Stop(pushSigChan)
+				return
+			case <-pushSigChan:
+				output.Trigger()
+			}
+		}
+	})
+
+	// SIGHUP reloads the configuration by a graceful restart, as does a new remote configuration
+	reloadSigChan := make(chan os.Signal, 1)
+	signal.Notify(reloadSigChan, syscall.SIGHUP)
 
 	// Wait for shutdown signal
-	select {
-	case sig := <-sigChan:
-		logger.Info("received shutdown signal", zap.String("signal", sig.String()))
-	case <-ctx.Done():
-		logger.Info("context cancelled")
+	restart := false
+wait:
+	for {
+		select {
+		case sig := <-sigChan:
+			logger.Info("received shutdown signal", zap.String("signal", sig.String()))
+			break wait
+		case <-ctx.Done():
+			logger.Info("context cancelled")
+			break wait
+		case <-reloadSigChan:
+			// An invalid file would stop the service instead of reloading it
+			if err := validateConfig(*configPath); err != nil {
+				logger.Error("configuration is invalid, not reloading", zap.Error(err))
+				continue
+			}
+			logger.Info("received reload signal, restarting")
+			restart = true
+			break wait
+		case <-remoteConfigChanged:
+			logger.Info("remote configuration changed, restarting to apply it")
+			restart = true
+			break wait
+		}
 	}
 
-	// Cancel context to stop all goroutines
-	cancel()
+	// Stop intake, let processing settle, push what is buffered, then close telemetry
+	runner.Shutdown()
 
-	// Stop scanner
-	logger.Info("stopping BLE scanner")
-	if err := bleScanner.Stop(); err != nil {
-		logger.Error("failed to stop BLE scanner", zap.Error(err))
+	logger.Info("BLE temperature monitoring service stopped")
+
+	// Flush remaining log entries
+	stopLogShipper()
+	<-logShipperDone
+
+	if restart {
+		logger.Sync()
+		restartProcess()
+	}
+	if scanErr != nil {
+		exitcode.Fatal(logger, "stopped after the BLE scanner failed", exitcode.DependencyError(scanErr))
+	}
+}
+
+// loadRemoteConfig fetches the remote configuration into its cache file and loads it; a failed
+// fetch falls back to the last verified copy, and without a usable one to the local configuration
+func loadRemoteConfig(local *config.Config) (*config.Config, error) {
+	logger, err := local.InitLogger()
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize logger: %w", err)
 	}
+	defer logger.Sync()
 
-	// Final push of remaining data
-	logger.Info("performing final metrics push")
-	readings := ringBuffer.GetAll()
-	if len(readings) > 0 {
-		finalCtx, finalCancel := context.WithTimeout(context.Background(), 10*time.Second)
-		defer finalCancel()
+	fetcher, err := remoteconfig.New(remoteConfigOptions(local.RemoteConfig), validateConfig, logger)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	if _, err := fetcher.Sync(ctx); err != nil {
+		logger.Warn("failed to fetch remote configuration, using the cached copy", zap.Error(err))
+	}
 
-		err := pusher.Push(finalCtx, readings)
+	if _, err := os.Stat(local.RemoteConfig.CacheFile); err != nil {
+		logger.Warn("no remote configuration cached, using the local configuration")
+		return local, nil
+	}
+	cfg, err := config.Load(local.RemoteConfig.CacheFile)
+	if err != nil {
+		// e.g. a cached configuration an upgraded binary no longer accepts
+		logger.Error("cached remote configuration is invalid, using the local configuration", zap.Error(err))
+		return local, nil
+	}
+	// The remote configuration can't move or disable its own source
+	cfg.RemoteConfig = local.RemoteConfig
+	return cfg, nil
+}
+
+// remoteConfigOptions converts the remote config section to fetcher options
+func remoteConfigOptions(cfg config.RemoteConfigConfig) remoteconfig.Options {
+	return remoteconfig.Options{
+		URL:          cfg.URL,
+		Token:        cfg.Token,
+		ChecksumURL:  cfg.ChecksumURL,
+		SignatureURL: cfg.SignatureURL,
+		PublicKey:    cfg.PublicKey,
+		CacheFile:    cfg.CacheFile,
+		Interval:     time.Duration(cfg.IntervalSeconds) * time.Second,
+	}
+}
+
+// mqttOptions converts an MQTT broker section to client options
+func mqttOptions(cfg config.MQTTConfig) mqtt.Options {
+	return mqtt.Options{
+		Broker:           cfg.Broker,
+		ClientID:         cfg.ClientID,
+		Username:         cfg.Username,
+		Password:         cfg.Password,
+		KeepAliveSeconds: cfg.KeepAliveSeconds,
+		TLS:              httpauth.TLSConfig(cfg.TLS),
+	}
+}
+
+// validateConfig reports why the configuration file at path doesn't load
+func validateConfig(path string) error {
+	_, err := config.Load(path)
+	return err
+}
+
+// restartProcess replaces the process with a fresh copy of the binary, which loads the current
+// configuration; if that fails, exiting with a runtime failure leaves the restart to the container's
+// restart policy
+func restartProcess() {
+	executable, err := os.Executable()
+	if err == nil {
+		err = syscall.Exec(executable, os.Args, os.Environ())
+	}
+	fmt.Fprintf(os.Stderr, "Failed to restart: %v\n", err)
+	os.Exit(exitcode.Runtime)
+}
+
+// setSensor writes ATC firmware settings to a sensor given by MAC address or by its configured name
+func setSensor(configPath, target string, args []string) error {
+	commands := make([][]byte, 0, len(args))
+	for _, arg := range args {
+		command, err := atc.ParseCommand(arg)
 		if err != nil {
-			logger.Error("failed final metrics push", zap.Error(err))
-		} else {
-			logger.Info("final metrics push successful", zap.Int("reading_count", len(readings)))
+			return exitcode.ConfigError(err)
 		}
+		commands = append(commands, command)
 	}
 
-	// Wait for all goroutines to finish
-	logger.Info("waiting for goroutines to finish")
-	wg.Wait()
+	mac := target
+	if strings.Count(target, ":") != 5 {
+		cfg, err := config.Load(configPath)
+		if err != nil {
+			return exitcode.ConfigError(fmt.Errorf("failed to load configuration to look up sensor %q: %w", target, err))
+		}
+		mac = ""
+		for _, sensor := range cfg.BLE.Sensors {
+			if sensor.Name == target {
+				mac = sensor.MACAddress
+			}
+		}
+		if mac == "" {
+			return exitcode.ConfigError(fmt.Errorf("no BLE sensor named %q in %s", target, configPath))
+		}
+	}
 
-	logger.Info("BLE temperature monitoring service stopped")
+	logger, err := zap.NewDevelopment()
+	if err != nil {
+		return err
+	}
+	defer logger.Sync()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	if err := atc.Configure(ctx, strings.ToUpper(mac), commands, logger); err != nil {
+		return exitcode.DependencyError(err)
+	}
+	return nil
+}
+
+// scanSensors runs the scan command: it surveys the sensors heard for the duration, or until
+// interrupted, redrawing a table of their reception every second or writing each advertisement
+// as a JSON line
+func scanSensors(configPath string, args []string) error {
+	flags := flag.NewFlagSet("scan", flag.ContinueOnError)
+	jsonOutput := flags.Bool("json", false, "Write each advertisement as a JSON line instead of the table")
+	duration := flags.Duration("duration", 0, "How long to scan, until interrupted when 0")
+	if err := flags.Parse(args); err != nil {
+		return exitcode.ConfigError(err)
+	}
+
+	// Configured sensors are shown by name; without a configuration they are shown by MAC only
+	names := make(map[string]string)
+	if cfg, err := config.Load(configPath); err == nil {
+		for _, sensor := range cfg.BLE.Sensors {
+			names[strings.ToUpper(sensor.MACAddress)] = sensor.Name
+		}
+	} else {
+		fmt.Fprintf(os.Stderr, "warning: sensors are not named: %v\n", err)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	if *duration > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, *duration)
+		defer cancel()
+	}
+
+	survey := scanner.NewSurvey(names)
+	if *jsonOutput {
+		encoder := json.NewEncoder(os.Stdout)
+		heard := func(packet scanner.SurveyPacket) {
+			encoder.Encode(packet)
+		}
+		if err := survey.Scan(ctx, heard); err != nil {
+			return exitcode.DependencyError(err)
+		}
+		return nil
+	}
+
+	// Redraw the table in place while scanning, leaving the final one on screen
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(time.Second)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				fmt.Print("\033[H\033[2J")
+				survey.WriteTable(os.Stdout)
+			}
+		}
+	}()
+	err := survey.Scan(ctx, nil)
+	stop() // Stops the redraw when the scan failed to start
+	<-done
+	if err != nil {
+		return exitcode.DependencyError(err)
+	}
+	fmt.Print("\033[H\033[2J")
+	return survey.WriteTable(os.Stdout)
 }
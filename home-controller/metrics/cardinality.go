@@ -0,0 +1,100 @@
+package metrics
+
+import (
+	"time"
+
+	"github.com/prometheus/prometheus/prompb"
+)
+
+// cardinalityIdle is how long a series must go unpushed before it no longer counts towards its metric's limit
+const cardinalityIdle = 24 * time.Hour
+
+// cardinalityGuard limits the number of series per metric name, protecting the endpoint against
+// label explosions from auto-discovered sensors or bad MQTT topics
+// Series already admitted keep being pushed; only new series beyond the limit are dropped
+type cardinalityGuard struct {
+	limit     int
+	overrides map[string]int                  // Per metric name limit, 0 means unlimited
+	series    map[string]map[uint64]time.Time // Admitted series by metric name, with the time last pushed
+	limited   map[string]bool                 // Metric names that have dropped series
+	now       func() time.Time
+}
+
+// newCardinalityGuard creates a guard admitting up to limit series per metric name, 0 meaning unlimited
+func newCardinalityGuard(limit int, overrides map[string]int) *cardinalityGuard {
+	return &cardinalityGuard{
+		limit:     limit,
+		overrides: overrides,
+		series:    make(map[string]map[uint64]time.Time),
+		limited:   make(map[string]bool),
+		now:       time.Now,
+	}
+}
+
+// limitFor returns the series limit of a metric name
+func (g *cardinalityGuard) limitFor(name string) int {
+	if limit, ok := g.overrides[name]; ok {
+		return limit
+	}
+	return g.limit
+}
+
+// filter removes series that would exceed their metric's limit and returns the number of samples
+// removed, along with metric names that reached their limit for the first time
+func (g *cardinalityGuard) filter(writeReq *prompb.WriteRequest) (int, []string) {
+	now := g.now()
+	removed := 0
+	var newlyLimited []string
+
+	series := writeReq.Timeseries[:0]
+	for _, ts := range writeReq.Timeseries {
+		name := metricName(ts.Labels)
+		limit := g.limitFor(name)
+		if limit <= 0 {
+			series = append(series, ts)
+			continue
+		}
+
+		admitted, ok := g.series[name]
+		if !ok {
+			admitted = make(map[uint64]time.Time)
+			g.series[name] = admitted
+		}
+		hash := seriesHash("", ts.Labels)
+		if _, ok := admitted[hash]; !ok && len(admitted) >= limit {
+			g.expire(admitted, now)
+			if len(admitted) >= limit {
+				removed += len(ts.Samples)
+				if !g.limited[name] {
+					g.limited[name] = true
+					newlyLimited = append(newlyLimited, name)
+				}
+				continue
+			}
+		}
+		admitted[hash] = now
+		series = append(series, ts)
+	}
+	writeReq.Timeseries = series
+
+	return removed, newlyLimited
+}
+
+// expire forgets series not pushed within cardinalityIdle, making room for replaced sensors
+func (g *cardinalityGuard) expire(admitted map[uint64]time.Time, now time.Time) {
+	for hash, pushed := range admitted {
+		if now.Sub(pushed) > cardinalityIdle {
+			delete(admitted, hash)
+		}
+	}
+}
+
+// metricName returns the __name__ label value
+func metricName(labels []prompb.Label) string {
+	for _, label := range labels {
+		if label.Name == "__name__" {
+			return label.Value
+		}
+	}
+	return ""
+}
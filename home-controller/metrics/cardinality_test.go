@@ -0,0 +1,65 @@
+package metrics
+
+import (
+	"testing"
+	"time"
+
+	"github.com/prometheus/prometheus/prompb"
+)
+
+// sensorSeries returns a single sample series of name with a sensor_id label
+func sensorSeries(name, sensorID string) prompb.TimeSeries {
+	return prompb.TimeSeries{
+		Labels: []prompb.Label{
+			{Name: "__name__", Value: name},
+			{Name: "sensor_id", Value: sensorID},
+		},
+		Samples: []prompb.Sample{{Value: 1, Timestamp: 1000}},
+	}
+}
+
+func TestCardinalityGuard(t *testing.T) {
+	guard := newCardinalityGuard(2, map[string]int{"unlimited": 0})
+	now := time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)
+	guard.now = func() time.Time { return now }
+
+	writeReq := &prompb.WriteRequest{Timeseries: []prompb.TimeSeries{
+		sensorSeries("temperature", "a"),
+		sensorSeries("temperature", "b"),
+		sensorSeries("temperature", "c"),
+		sensorSeries("temperature", "d"),
+		sensorSeries("unlimited", "a"),
+		sensorSeries("unlimited", "b"),
+		sensorSeries("unlimited", "c"),
+	}}
+	removed, limited := guard.filter(writeReq)
+	if removed != 2 {
+		t.Errorf("Expected 2 samples removed, got %d", removed)
+	}
+	if len(limited) != 1 || limited[0] != "temperature" {
+		t.Errorf("Expected temperature to be reported as limited, got %v", limited)
+	}
+	if len(writeReq.Timeseries) != 5 {
+		t.Errorf("Expected 5 series to remain, got %d", len(writeReq.Timeseries))
+	}
+
+	// Admitted series keep being pushed and a limited metric is only reported once
+	writeReq = &prompb.WriteRequest{Timeseries: []prompb.TimeSeries{
+		sensorSeries("temperature", "b"),
+		sensorSeries("temperature", "e"),
+	}}
+	removed, limited = guard.filter(writeReq)
+	if removed != 1 || len(limited) != 0 {
+		t.Errorf("Expected 1 sample removed without a new report, got %d removed and %v", removed, limited)
+	}
+	if len(writeReq.Timeseries) != 1 || writeReq.Timeseries[0].Labels[1].Value != "b" {
+		t.Errorf("Expected only the admitted series to remain, got %+v", writeReq.Timeseries)
+	}
+
+	// Series idle for longer than cardinalityIdle make room for new ones
+	now = now.Add(cardinalityIdle + time.Minute)
+	writeReq = &prompb.WriteRequest{Timeseries: []prompb.TimeSeries{sensorSeries("temperature", "e")}}
+	if removed, _ := guard.filter(writeReq); removed != 0 {
+		t.Errorf("Expected a new series admitted after idle series expired, got %d removed", removed)
+	}
+}
@@ -0,0 +1,95 @@
+package metrics
+
+import (
+	"time"
+
+	"github.com/prometheus/prometheus/prompb"
+)
+
+// pushedSample is the last pushed sample of a coalesced series
+type pushedSample struct {
+	value     float64
+	timestamp int64
+}
+
+// coalescer skips samples of slow-moving gauges, such as battery levels and setpoints, that repeat
+// the last pushed value of their series, until maxStaleness passed since that sample, so the series
+// is refreshed often enough not to go stale in queries
+// Like the dedup cache, samples are only remembered once their push succeeded
+type coalescer struct {
+	metrics      map[string]bool
+	maxStaleness int64 // Milliseconds
+	last         map[uint64]pushedSample
+}
+
+// newCoalescer creates a coalescer for the metric names
+func newCoalescer(metrics []string, maxStaleness time.Duration) *coalescer {
+	c := &coalescer{
+		metrics:      make(map[string]bool, len(metrics)),
+		maxStaleness: maxStaleness.Milliseconds(),
+		last:         make(map[uint64]pushedSample),
+	}
+	for _, name := range metrics {
+		c.metrics[name] = true
+	}
+	return c
+}
+
+// coalesced reports whether the series belongs to a coalesced metric
+func (c *coalescer) coalesced(labels []prompb.Label) bool {
+	for _, label := range labels {
+		if label.Name == "__name__" {
+			return c.metrics[label.Value]
+		}
+	}
+	return false
+}
+
+// filter removes samples repeating the previous kept or pushed value of their series within
+// maxStaleness of it, and returns the number of samples removed
+func (c *coalescer) filter(tenant string, writeReq *prompb.WriteRequest) int {
+	removed := 0
+	series := writeReq.Timeseries[:0]
+	for _, ts := range writeReq.Timeseries {
+		if !c.coalesced(ts.Labels) {
+			series = append(series, ts)
+			continue
+		}
+
+		previous, ok := c.last[seriesHash(tenant, ts.Labels)]
+		samples := ts.Samples[:0]
+		for _, sample := range ts.Samples {
+			if ok && sample.Value == previous.value && sample.Timestamp >= previous.timestamp &&
+				sample.Timestamp-previous.timestamp < c.maxStaleness {
+				removed++
+				continue
+			}
+			previous, ok = pushedSample{value: sample.Value, timestamp: sample.Timestamp}, true
+			samples = append(samples, sample)
+		}
+
+		if len(samples) == 0 {
+			continue
+		}
+		ts.Samples = samples
+		series = append(series, ts)
+	}
+	writeReq.Timeseries = series
+
+	return removed
+}
+
+// commit remembers the newest sample of each coalesced series of a successfully pushed request
+func (c *coalescer) commit(tenant string, writeReq *prompb.WriteRequest) {
+	for _, ts := range writeReq.Timeseries {
+		if !c.coalesced(ts.Labels) {
+			continue
+		}
+		hash := seriesHash(tenant, ts.Labels)
+		for _, sample := range ts.Samples {
+			if last, ok := c.last[hash]; !ok || sample.Timestamp >= last.timestamp {
+				c.last[hash] = pushedSample{value: sample.Value, timestamp: sample.Timestamp}
+			}
+		}
+	}
+}
@@ -0,0 +1,64 @@
+package metrics
+
+import (
+	"testing"
+	"time"
+
+	"github.com/prometheus/prometheus/prompb"
+)
+
+// gaugeSeries returns a series of the metric with a sample of each value, one minute apart from start
+func gaugeSeries(name string, start int64, values ...float64) prompb.TimeSeries {
+	samples := make([]prompb.Sample, len(values))
+	for i, value := range values {
+		samples[i] = prompb.Sample{Value: value, Timestamp: start + int64(i)*60000}
+	}
+	return prompb.TimeSeries{
+		Labels:  []prompb.Label{{Name: "__name__", Value: name}, {Name: "sensor_name", Value: "Bedroom"}},
+		Samples: samples,
+	}
+}
+
+func TestCoalescer(t *testing.T) {
+	c := newCoalescer([]string{"ble_battery_percent"}, 3*time.Minute)
+
+	// Within a request, repeats of the previous kept value are skipped until it is 3 minutes old
+	first := &prompb.WriteRequest{Timeseries: []prompb.TimeSeries{
+		gaugeSeries("ble_battery_percent", 0, 90, 90, 90, 90, 89),
+		gaugeSeries("ble_temperature_celsius", 0, 21, 21, 21),
+	}}
+	if removed := c.filter("", first); removed != 2 {
+		t.Errorf("Expected 2 repeated battery samples skipped, got %d", removed)
+	}
+	var kept []int64
+	for _, sample := range first.Timeseries[0].Samples {
+		kept = append(kept, sample.Timestamp)
+	}
+	if len(kept) != 3 || kept[0] != 0 || kept[1] != 180000 || kept[2] != 240000 {
+		t.Errorf("Expected samples at 0, 3m and 4m, got %v", kept)
+	}
+	if len(first.Timeseries[1].Samples) != 3 {
+		t.Errorf("Expected metrics not listed to be left alone, got %d samples", len(first.Timeseries[1].Samples))
+	}
+
+	// Nothing is remembered until the push succeeded
+	retry := &prompb.WriteRequest{Timeseries: []prompb.TimeSeries{gaugeSeries("ble_battery_percent", 240000, 89)}}
+	if removed := c.filter("", retry); removed != 0 {
+		t.Errorf("Expected an uncommitted sample not to suppress others, got %d removed", removed)
+	}
+	c.commit("", retry)
+
+	next := &prompb.WriteRequest{Timeseries: []prompb.TimeSeries{gaugeSeries("ble_battery_percent", 300000, 89, 89, 89)}}
+	if removed := c.filter("", next); removed != 2 {
+		t.Errorf("Expected samples repeating the pushed value skipped, got %d removed", removed)
+	}
+	if samples := next.Timeseries[0].Samples; len(samples) != 1 || samples[0].Timestamp != 420000 {
+		t.Errorf("Expected the sample 3 minutes after the pushed one to be kept, got %+v", samples)
+	}
+
+	// A series left without samples is removed from the request
+	empty := &prompb.WriteRequest{Timeseries: []prompb.TimeSeries{gaugeSeries("ble_battery_percent", 250000, 89)}}
+	if removed := c.filter("", empty); removed != 1 || len(empty.Timeseries) != 0 {
+		t.Errorf("Expected the empty series to be removed, got %d removed and %d series", removed, len(empty.Timeseries))
+	}
+}
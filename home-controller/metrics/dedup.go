@@ -0,0 +1,97 @@
+package metrics
+
+import (
+	"hash/fnv"
+	"sort"
+	"time"
+
+	"github.com/prometheus/prometheus/prompb"
+)
+
+// dedupKey identifies a sample by its series and timestamp
+type dedupKey struct {
+	series    uint64
+	timestamp int64
+}
+
+// dedupCache remembers recently pushed samples so replays and redundant collectors
+// don't send the same series and timestamp twice within the window
+// Samples are only remembered once their push succeeded, so retries are not deduplicated away
+type dedupCache struct {
+	window time.Duration
+	seen   map[dedupKey]time.Time // Time the sample was pushed
+	now    func() time.Time
+}
+
+// newDedupCache creates a cache remembering pushed samples for window
+func newDedupCache(window time.Duration) *dedupCache {
+	return &dedupCache{
+		window: window,
+		seen:   make(map[dedupKey]time.Time),
+		now:    time.Now,
+	}
+}
+
+// filter removes samples pushed within the window and repeated samples within the request,
+// and returns the number of samples removed
+func (c *dedupCache) filter(tenant string, writeReq *prompb.WriteRequest) int {
+	removed := 0
+	batch := make(map[dedupKey]bool)
+
+	series := writeReq.Timeseries[:0]
+	for _, ts := range writeReq.Timeseries {
+		hash := seriesHash(tenant, ts.Labels)
+		samples := ts.Samples[:0]
+		for _, sample := range ts.Samples {
+			key := dedupKey{series: hash, timestamp: sample.Timestamp}
+			if _, ok := c.seen[key]; ok || batch[key] {
+				removed++
+				continue
+			}
+			batch[key] = true
+			samples = append(samples, sample)
+		}
+
+		if len(samples) == 0 {
+			continue
+		}
+		ts.Samples = samples
+		series = append(series, ts)
+	}
+	writeReq.Timeseries = series
+
+	return removed
+}
+
+// commit remembers the samples of a successfully pushed request and forgets those older than the window
+func (c *dedupCache) commit(tenant string, writeReq *prompb.WriteRequest) {
+	now := c.now()
+	for key, pushed := range c.seen {
+		if now.Sub(pushed) > c.window {
+			delete(c.seen, key)
+		}
+	}
+	for _, ts := range writeReq.Timeseries {
+		hash := seriesHash(tenant, ts.Labels)
+		for _, sample := range ts.Samples {
+			c.seen[dedupKey{series: hash, timestamp: sample.Timestamp}] = now
+		}
+	}
+}
+
+// seriesHash returns a hash of the tenant and label set, independent of label order
+func seriesHash(tenant string, labels []prompb.Label) uint64 {
+	sorted := make([]prompb.Label, len(labels))
+	copy(sorted, labels)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Name < sorted[j].Name })
+
+	h := fnv.New64a()
+	h.Write([]byte(tenant))
+	for _, label := range sorted {
+		h.Write([]byte{0xff})
+		h.Write([]byte(label.Name))
+		h.Write([]byte{0xfe})
+		h.Write([]byte(label.Value))
+	}
+	return h.Sum64()
+}
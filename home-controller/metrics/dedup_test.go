@@ -0,0 +1,98 @@
+package metrics
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/mjasion/balena-home/thermostats/buffer"
+	"github.com/prometheus/prometheus/prompb"
+	"go.uber.org/zap"
+)
+
+func TestDedupCache(t *testing.T) {
+	cache := newDedupCache(time.Minute)
+	now := time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)
+	cache.now = func() time.Time { return now }
+
+	first := &prompb.WriteRequest{Timeseries: []prompb.TimeSeries{testSeries("a", 1000, 2000, 2000)}}
+	if removed := cache.filter("", first); removed != 1 {
+		t.Errorf("Expected the repeated sample in the request to be removed, got %d", removed)
+	}
+	cache.commit("", first)
+
+	second := &prompb.WriteRequest{Timeseries: []prompb.TimeSeries{
+		testSeries("a", 2000, 3000),
+		testSeries("b", 2000),
+	}}
+	if removed := cache.filter("", second); removed != 1 {
+		t.Errorf("Expected 1 already pushed sample removed, got %d", removed)
+	}
+	if len(second.Timeseries) != 2 || len(second.Timeseries[0].Samples) != 1 || second.Timeseries[0].Samples[0].Timestamp != 3000 {
+		t.Errorf("Expected only new samples to remain, got %+v", second.Timeseries)
+	}
+
+	otherTenant := &prompb.WriteRequest{Timeseries: []prompb.TimeSeries{testSeries("a", 1000)}}
+	if removed := cache.filter("tenant-b", otherTenant); removed != 0 {
+		t.Errorf("Expected tenants to be deduplicated separately, got %d removed", removed)
+	}
+
+	// A commit after the window forgets the earlier samples
+	now = now.Add(2 * time.Minute)
+	cache.commit("", &prompb.WriteRequest{})
+	expired := &prompb.WriteRequest{Timeseries: []prompb.TimeSeries{testSeries("a", 1000)}}
+	if removed := cache.filter("", expired); removed != 0 {
+		t.Errorf("Expected samples outside the window to be pushed again, got %d removed", removed)
+	}
+}
+
+func TestSeriesHash_IgnoresLabelOrder(t *testing.T) {
+	a := []prompb.Label{{Name: "__name__", Value: "x"}, {Name: "mac", Value: "A"}}
+	b := []prompb.Label{{Name: "mac", Value: "A"}, {Name: "__name__", Value: "x"}}
+	if seriesHash("", a) != seriesHash("", b) {
+		t.Error("Expected the same hash regardless of label order")
+	}
+	if seriesHash("", a) == seriesHash("other", a) {
+		t.Error("Expected the tenant to change the hash")
+	}
+}
+
+func TestPush_DedupWindow(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if requests == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	pusher := newTestPusher(server.URL, "user", "pass", zap.NewNop())
+	pusher.SetDedupWindow(time.Minute)
+	readings := wrapBLEReadings([]*buffer.SensorReading{
+		{Timestamp: time.Now(), MAC: "A4:C1:38:00:00:01", SensorName: "Salon", SensorID: 1, TemperatureCelsius: 21.5},
+	})
+
+	// The failed first attempt must not mark the samples as pushed
+	if err := pusher.Push(context.Background(), readings); err != nil {
+		t.Fatalf("Expected push to succeed on retry, got: %v", err)
+	}
+	if requests != 2 {
+		t.Fatalf("Expected 2 requests, got %d", requests)
+	}
+
+	// Replaying the same readings sends nothing
+	if err := pusher.Push(context.Background(), readings); err != nil {
+		t.Fatalf("Expected no error for a replay, got: %v", err)
+	}
+	if requests != 2 {
+		t.Errorf("Expected the replay to be deduplicated, got %d requests", requests)
+	}
+	if pusher.dropped[dropReasonDuplicate] == 0 {
+		t.Error("Expected duplicate samples to be counted as dropped")
+	}
+}
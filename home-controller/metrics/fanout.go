@@ -0,0 +1,143 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/mjasion/balena-home/thermostats/buffer"
+	"go.uber.org/zap"
+)
+
+// Fanout distributes buffered readings to several remote_write endpoints
+// Each pusher owns a queue buffer, so an endpoint that is down keeps its own backlog and
+// retry state without delaying or duplicating pushes to the others
+type Fanout struct {
+	buffer       *buffer.RingBuffer
+	pushers      []*Pusher
+	pushInterval time.Duration
+	logger       *zap.Logger
+	leader       Leader        // Nil pushes unconditionally
+	trigger      chan struct{} // Requests an out-of-cycle push
+}
+
+// NewFanout creates a fanout from the shared buffer to pushers created with their own queue buffers
+func NewFanout(buf *buffer.RingBuffer, pushers []*Pusher, pushIntervalSeconds int, logger *zap.Logger) *Fanout {
+	return &Fanout{
+		buffer:       buf,
+		pushers:      pushers,
+		pushInterval: time.Duration(pushIntervalSeconds) * time.Second,
+		logger:       logger,
+		trigger:      make(chan struct{}, 1),
+	}
+}
+
+// SetLeader only distributes and pushes while leader reports this instance as the leader
+// Endpoint queues keep their backlog while standing by and drain once leadership returns
+func (f *Fanout) SetLeader(leader Leader) {
+	f.leader = leader
+}
+
+// Start copies each buffer snapshot to every endpoint queue and triggers the endpoints' pushes
+func (f *Fanout) Start(ctx context.Context) {
+	ticker := time.NewTicker(f.pushInterval)
+	defer ticker.Stop()
+
+	f.logger.Info("prometheus fanout started",
+		zap.Duration("push_interval", f.pushInterval),
+		zap.Int("endpoint_count", len(f.pushers)),
+	)
+
+	// One goroutine per endpoint so a slow endpoint does not hold back the others
+	var wg sync.WaitGroup
+	triggers := make([]chan struct{}, len(f.pushers))
+	for i, p := range f.pushers {
+		triggers[i] = make(chan struct{}, 1)
+		wg.Add(1)
+		go func(p *Pusher, trigger <-chan struct{}) {
+			defer wg.Done()
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case <-trigger:
+					p.flush(ctx)
+				}
+			}
+		}(p, triggers[i])
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			wg.Wait()
+			f.logger.Info("prometheus fanout stopping")
+			return
+		case <-ticker.C:
+		case <-f.trigger:
+			f.logger.Info("out-of-cycle push requested", zap.Int("buffered", f.buffer.Size()))
+		}
+		if f.leader != nil && !f.leader.IsLeader() {
+			f.logger.Debug("standby, keeping readings buffered", zap.Int("buffered", f.buffer.Size()))
+			continue
+		}
+		if !f.distribute() {
+			continue
+		}
+		for _, trigger := range triggers {
+			// A pending trigger already covers the new readings
+			select {
+			case trigger <- struct{}{}:
+			default:
+			}
+		}
+	}
+}
+
+// Trigger requests an immediate push to every endpoint; a pending request covers later ones
+func (f *Fanout) Trigger() {
+	select {
+	case f.trigger <- struct{}{}:
+	default:
+	}
+}
+
+// distribute moves the shared buffer's readings to every endpoint queue and reports whether there were any
+func (f *Fanout) distribute() bool {
+	readings := f.buffer.GetAllAndClear()
+	if len(readings) == 0 {
+		f.logger.Debug("no readings to distribute")
+		return false
+	}
+	for _, p := range f.pushers {
+		p.buffer.AddMultiple(readings)
+	}
+	return true
+}
+
+// Flush pushes all pending readings to every endpoint concurrently, used for the final push on shutdown
+// Returns an error for each endpoint that failed
+func (f *Fanout) Flush(ctx context.Context) error {
+	f.distribute()
+
+	var wg sync.WaitGroup
+	errs := make([]error, len(f.pushers))
+	for i, p := range f.pushers {
+		wg.Add(1)
+		go func(i int, p *Pusher) {
+			defer wg.Done()
+			if err := p.Push(ctx, p.buffer.GetAll()); err != nil {
+				name := p.name
+				if name == "" {
+					name = "primary"
+				}
+				errs[i] = fmt.Errorf("endpoint %s: %w", name, err)
+			}
+		}(i, p)
+	}
+	wg.Wait()
+
+	return errors.Join(errs...)
+}
@@ -0,0 +1,67 @@
+package metrics
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/mjasion/balena-home/thermostats/buffer"
+	"go.uber.org/zap"
+)
+
+func TestFanout_IndependentEndpoints(t *testing.T) {
+	var healthyRequests, failingRequests atomic.Int32
+	healthy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		healthyRequests.Add(1)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer healthy.Close()
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		failingRequests.Add(1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer failing.Close()
+
+	logger := zap.NewNop()
+	shared := buffer.New(100, logger)
+	primary := New(healthy.URL, "user", "pass", buffer.New(100, logger), 30, 1000, logger)
+	local := New(failing.URL, "", "", buffer.New(100, logger), 30, 1000, logger)
+	local.SetName("local")
+	fanout := NewFanout(shared, []*Pusher{primary, local}, 30, logger)
+
+	for _, reading := range wrapBLEReadings([]*buffer.SensorReading{
+		{Timestamp: time.Now(), MAC: "A4:C1:38:00:00:01", TemperatureCelsius: 22.5},
+		{Timestamp: time.Now(), MAC: "A4:C1:38:00:00:02", TemperatureCelsius: 21.0},
+	}) {
+		shared.Add(reading)
+	}
+
+	if !fanout.distribute() {
+		t.Fatal("Expected readings to distribute")
+	}
+	if shared.Size() != 0 {
+		t.Errorf("Expected shared buffer to be empty, got %d", shared.Size())
+	}
+
+	primary.flush(context.Background())
+	local.flush(context.Background())
+
+	if healthyRequests.Load() != 1 {
+		t.Errorf("Expected 1 request to healthy endpoint, got %d", healthyRequests.Load())
+	}
+	if failingRequests.Load() != 3 {
+		t.Errorf("Expected 3 attempts to failing endpoint, got %d", failingRequests.Load())
+	}
+	if primary.buffer.Size() != 0 {
+		t.Errorf("Expected healthy endpoint queue to be empty, got %d", primary.buffer.Size())
+	}
+	if local.buffer.Size() != 2 {
+		t.Errorf("Expected failing endpoint to keep 2 readings, got %d", local.buffer.Size())
+	}
+	if local.failures != 1 || primary.failures != 0 {
+		t.Errorf("Expected failure counts 0 and 1, got %d and %d", primary.failures, local.failures)
+	}
+}
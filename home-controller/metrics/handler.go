@@ -0,0 +1,28 @@
+package metrics
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/mjasion/balena-home/thermostats/admin"
+)
+
+// Output is a periodic output that can push immediately: Pusher, Fanout or ingest.Forwarder
+type Output interface {
+	// Start pushes periodically until the context is cancelled
+	Start(ctx context.Context)
+	// Trigger requests an out-of-cycle push
+	Trigger()
+}
+
+// RegisterPushHandler registers POST /api/push-now, which triggers an out-of-cycle push of
+// buffered readings, e.g. before a planned reboot
+func RegisterPushHandler(server *admin.Server, output Output) {
+	server.HandleFunc("POST /api/push-now", func(w http.ResponseWriter, r *http.Request) {
+		output.Trigger()
+		admin.WriteJSON(w, http.StatusAccepted, admin.Response{
+			Success: true,
+			Message: "Push triggered.",
+		})
+	})
+}
@@ -0,0 +1,165 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/prometheus/prometheus/prompb"
+	"go.uber.org/zap"
+)
+
+// Reasons for dropping samples, used as the reason label of remote_write_samples_dropped_total
+const (
+	dropReasonTooOld      = "too_old"
+	dropReasonRejected    = "rejected"
+	dropReasonInvalid     = "invalid"
+	dropReasonDuplicate   = "duplicate"
+	dropReasonCardinality = "cardinality"
+	dropReasonCoalesced   = "coalesced" // Not lost: the value is unchanged since the last pushed sample
+)
+
+// statusError is a non-2xx response from the remote endpoint
+type statusError struct {
+	StatusCode int
+	Body       string
+}
+
+func (e *statusError) Error() string {
+	return fmt.Sprintf("received non-2xx status code: %d, body: %s", e.StatusCode, e.Body)
+}
+
+// limitSampleAge drops samples older than the cutoff and returns how many were dropped
+// With retimestamp, the newest too-old sample of each series is kept at the cutoff instead of
+// being dropped, unless the series already has a sample at that time
+func limitSampleAge(writeReq *prompb.WriteRequest, cutoff time.Time, retimestamp bool) int {
+	cutoffMs := cutoff.UnixMilli()
+	dropped := 0
+
+	series := writeReq.Timeseries[:0]
+	for _, ts := range writeReq.Timeseries {
+		samples := ts.Samples[:0]
+		var newestOld *prompb.Sample
+		hasCutoffSample := false
+		for _, sample := range ts.Samples {
+			if sample.Timestamp >= cutoffMs {
+				hasCutoffSample = hasCutoffSample || sample.Timestamp == cutoffMs
+				samples = append(samples, sample)
+				continue
+			}
+			dropped++
+			if newestOld == nil || sample.Timestamp > newestOld.Timestamp {
+				s := sample
+				newestOld = &s
+			}
+		}
+		if retimestamp && newestOld != nil && !hasCutoffSample {
+			dropped--
+			samples = append([]prompb.Sample{{Value: newestOld.Value, Timestamp: cutoffMs}}, samples...)
+		}
+
+		if len(samples) == 0 {
+			continue
+		}
+		ts.Samples = samples
+		series = append(series, ts)
+	}
+	writeReq.Timeseries = series
+
+	return dropped
+}
+
+// labelSetRegex matches label sets such as {__name__="ble_temperature_celsius", sensor_id="1"}
+// as quoted in Prometheus, Mimir and Cortex out-of-order and too-old rejection messages
+var labelSetRegex = regexp.MustCompile(`\{__name__="(?:[^"\\]|\\.)*"(?:,\s*[a-zA-Z_][a-zA-Z0-9_]*="(?:[^"\\]|\\.)*")*\}`)
+
+// labelPairRegex matches a single name="value" pair inside a label set
+var labelPairRegex = regexp.MustCompile(`([a-zA-Z_][a-zA-Z0-9_]*)="((?:[^"\\]|\\.)*)"`)
+
+// parseRejectedSeries extracts the keys of series quoted in a rejection error body
+func parseRejectedSeries(body string) map[string]bool {
+	rejected := make(map[string]bool)
+	for _, labelSet := range labelSetRegex.FindAllString(body, -1) {
+		var labels []prompb.Label
+		for _, pair := range labelPairRegex.FindAllStringSubmatch(labelSet, -1) {
+			value, err := strconv.Unquote(`"` + pair[2] + `"`)
+			if err != nil {
+				value = pair[2]
+			}
+			labels = append(labels, prompb.Label{Name: pair[1], Value: value})
+		}
+		rejected[seriesKey(labels)] = true
+	}
+	return rejected
+}
+
+// removeSeries removes the series with the given keys and returns the number of samples removed
+func removeSeries(writeReq *prompb.WriteRequest, keys map[string]bool) int {
+	removed := 0
+	series := writeReq.Timeseries[:0]
+	for _, ts := range writeReq.Timeseries {
+		if keys[seriesKey(ts.Labels)] {
+			removed += len(ts.Samples)
+			continue
+		}
+		series = append(series, ts)
+	}
+	writeReq.Timeseries = series
+	return removed
+}
+
+// seriesKey returns a canonical identifier for a label set, independent of label order
+func seriesKey(labels []prompb.Label) string {
+	pairs := make([]string, len(labels))
+	for i, label := range labels {
+		pairs[i] = label.Name + "=" + strconv.Quote(label.Value)
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
+
+// isolateInvalidSeries pushes a write request the endpoint rejected with 400 in recursively split
+// halves, dropping only the series that fail on their own
+// Halves that succeed are not resent; dropped series are removed from the write request, so on a
+// non-400 error a retry only resends series that are not known to be invalid
+func (p *Pusher) isolateInvalidSeries(ctx context.Context, tenant string, writeReq *prompb.WriteRequest) error {
+	invalid := make(map[string]bool)
+	err := p.bisect(ctx, tenant, writeReq.Timeseries, invalid)
+	if removed := removeSeries(writeReq, invalid); removed > 0 {
+		p.dropped[dropReasonInvalid] += int64(removed)
+	}
+	return err
+}
+
+// bisect isolates invalid series in a set the endpoint rejected with 400
+func (p *Pusher) bisect(ctx context.Context, tenant string, series []prompb.TimeSeries, invalid map[string]bool) error {
+	if len(series) == 1 {
+		key := seriesKey(series[0].Labels)
+		invalid[key] = true
+		p.logger.Warn("dropping series rejected by endpoint",
+			zap.String("tenant", tenant),
+			zap.String("series", key),
+			zap.Int("dropped_samples", len(series[0].Samples)),
+		)
+		return nil
+	}
+
+	mid := len(series) / 2
+	for _, half := range [][]prompb.TimeSeries{series[:mid], series[mid:]} {
+		err := p.pushOnce(ctx, &prompb.WriteRequest{Timeseries: half}, tenant)
+		var statusErr *statusError
+		if errors.As(err, &statusErr) && statusErr.StatusCode == http.StatusBadRequest {
+			err = p.bisect(ctx, tenant, half, invalid)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
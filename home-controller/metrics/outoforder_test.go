@@ -0,0 +1,154 @@
+package metrics
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gogo/protobuf/proto"
+	"github.com/golang/snappy"
+	"github.com/mjasion/balena-home/thermostats/buffer"
+	"github.com/prometheus/prometheus/prompb"
+	"go.uber.org/zap"
+)
+
+func testSeries(name string, timestamps ...int64) prompb.TimeSeries {
+	samples := make([]prompb.Sample, len(timestamps))
+	for i, ts := range timestamps {
+		samples[i] = prompb.Sample{Value: float64(i), Timestamp: ts}
+	}
+	return prompb.TimeSeries{
+		Labels:  []prompb.Label{{Name: "__name__", Value: name}},
+		Samples: samples,
+	}
+}
+
+func TestLimitSampleAge(t *testing.T) {
+	cutoff := time.UnixMilli(10000)
+
+	writeReq := &prompb.WriteRequest{Timeseries: []prompb.TimeSeries{
+		testSeries("mixed", 1000, 2000, 10000, 11000),
+		testSeries("old", 1000, 2000),
+		testSeries("fresh", 12000),
+	}}
+	if dropped := limitSampleAge(writeReq, cutoff, false); dropped != 4 {
+		t.Errorf("Expected 4 dropped samples, got %d", dropped)
+	}
+	if len(writeReq.Timeseries) != 2 || len(writeReq.Timeseries[0].Samples) != 2 {
+		t.Errorf("Expected mixed series with 2 samples and fresh series, got %v", writeReq.Timeseries)
+	}
+
+	writeReq = &prompb.WriteRequest{Timeseries: []prompb.TimeSeries{
+		testSeries("old", 1000, 2000),
+		testSeries("at-cutoff", 1000, 10000),
+	}}
+	if dropped := limitSampleAge(writeReq, cutoff, true); dropped != 2 {
+		t.Errorf("Expected 2 dropped samples, got %d", dropped)
+	}
+	old := writeReq.Timeseries[0].Samples
+	if len(old) != 1 || old[0].Timestamp != 10000 || old[0].Value != 1 {
+		t.Errorf("Expected newest old sample re-timestamped to cutoff, got %v", old)
+	}
+	if atCutoff := writeReq.Timeseries[1].Samples; len(atCutoff) != 1 {
+		t.Errorf("Expected existing cutoff sample only, got %v", atCutoff)
+	}
+}
+
+func TestParseRejectedSeries(t *testing.T) {
+	body := `failed pushing to ingester: user=home: the sample has been rejected because another sample with a more recent timestamp has already been ingested and out-of-order samples are not allowed (err-mimir-sample-out-of-order). The affected sample has timestamp 2024-01-01T00:00:00Z and is from series {__name__="ble_temperature_celsius", sensor_id="1", sensor_name="Salon \"A\""}`
+
+	rejected := parseRejectedSeries(body)
+	key := seriesKey([]prompb.Label{
+		{Name: "sensor_name", Value: `Salon "A"`},
+		{Name: "__name__", Value: "ble_temperature_celsius"},
+		{Name: "sensor_id", Value: "1"},
+	})
+	if len(rejected) != 1 || !rejected[key] {
+		t.Errorf("Expected rejected series %s, got %v", key, rejected)
+	}
+
+	if rejected := parseRejectedSeries("internal error"); len(rejected) != 0 {
+		t.Errorf("Expected no rejected series, got %v", rejected)
+	}
+}
+
+func TestPush_ExcludesRejectedSeries(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if requests == 1 {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprint(w, `out of order sample for series {__name__="ble_battery_percent", mac="A4:C1:38:00:00:01", sensor_id="1", sensor_name="Salon"}`)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	pusher := newTestPusher(server.URL, "user", "pass", zap.NewNop())
+	readings := wrapBLEReadings([]*buffer.SensorReading{
+		{Timestamp: time.Now(), MAC: "A4:C1:38:00:00:01", SensorName: "Salon", SensorID: 1, TemperatureCelsius: 21.5},
+	})
+
+	if err := pusher.Push(context.Background(), readings); err != nil {
+		t.Fatalf("Expected rejected series to be excluded, got: %v", err)
+	}
+	if requests != 2 {
+		t.Errorf("Expected 2 requests, got %d", requests)
+	}
+	if pusher.dropped[dropReasonRejected] != 1 {
+		t.Errorf("Expected 1 rejected sample, got %d", pusher.dropped[dropReasonRejected])
+	}
+}
+
+func TestPush_BisectsInvalidSeries(t *testing.T) {
+	var pushed []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		compressed, _ := io.ReadAll(r.Body)
+		data, err := snappy.Decode(nil, compressed)
+		if err != nil {
+			t.Fatalf("Failed to decode snappy: %v", err)
+		}
+		var writeReq prompb.WriteRequest
+		if err := proto.Unmarshal(data, &writeReq); err != nil {
+			t.Fatalf("Failed to unmarshal write request: %v", err)
+		}
+
+		var names []string
+		for _, ts := range writeReq.Timeseries {
+			if ts.Labels[0].Value == "bad" {
+				w.WriteHeader(http.StatusBadRequest)
+				fmt.Fprint(w, "invalid label value")
+				return
+			}
+			names = append(names, ts.Labels[0].Value)
+		}
+		pushed = append(pushed, names...)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	pusher := newTestPusher(server.URL, "user", "pass", zap.NewNop())
+	now := time.Now().UnixMilli()
+	writeReq := &prompb.WriteRequest{Timeseries: []prompb.TimeSeries{
+		testSeries("a", now), testSeries("b", now), testSeries("bad", now), testSeries("c", now), testSeries("d", now),
+	}}
+
+	if err := pusher.isolateInvalidSeries(context.Background(), "", writeReq); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	if len(pushed) != 4 {
+		t.Errorf("Expected 4 valid series pushed once each, got %v", pushed)
+	}
+	if len(writeReq.Timeseries) != 4 {
+		t.Errorf("Expected invalid series removed from write request, got %d series", len(writeReq.Timeseries))
+	}
+	if pusher.dropped[dropReasonInvalid] != 1 {
+		t.Errorf("Expected 1 invalid sample dropped, got %d", pusher.dropped[dropReasonInvalid])
+	}
+}
@@ -0,0 +1,65 @@
+package metrics
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/mjasion/balena-home/thermostats/buffer"
+)
+
+// Provenance headers sent with every push, so the receiving side can tell which device sent a batch
+const (
+	HeaderCollectorVersion = "X-Collector-Version"
+	HeaderCollectorDevice  = "X-Collector-Device"
+	HeaderBatchID          = "X-Collector-Batch-ID"
+	HeaderBatchReadings    = "X-Collector-Batch-Readings" // e.g. ble=12,netatmo=4
+)
+
+// provenance identifies the collector in push headers
+type provenance struct {
+	version string
+	device  string // Empty omits the header
+}
+
+// batchInfo describes the readings of a write request; retries and bisected parts of a batch share it
+type batchInfo struct {
+	id       string
+	readings string // Reading counts per type
+}
+
+// newBatchInfo creates a batch with a random ID for the readings
+func newBatchInfo(readings []*buffer.Reading) batchInfo {
+	id := make([]byte, 8)
+	rand.Read(id)
+	return batchInfo{id: hex.EncodeToString(id), readings: countReadings(readings)}
+}
+
+// countReadings formats the number of readings per type, sorted by type
+func countReadings(readings []*buffer.Reading) string {
+	counts := make(map[buffer.ReadingType]int)
+	for _, reading := range readings {
+		counts[reading.Type]++
+	}
+	parts := make([]string, 0, len(counts))
+	for readingType, count := range counts {
+		parts = append(parts, string(readingType)+"="+strconv.Itoa(count))
+	}
+	sort.Strings(parts)
+	return strings.Join(parts, ",")
+}
+
+// setHeaders adds the provenance and batch headers to a push request
+func (p provenance) setHeaders(header http.Header, batch batchInfo) {
+	header.Set(HeaderCollectorVersion, p.version)
+	if p.device != "" {
+		header.Set(HeaderCollectorDevice, p.device)
+	}
+	if batch.id != "" {
+		header.Set(HeaderBatchID, batch.id)
+		header.Set(HeaderBatchReadings, batch.readings)
+	}
+}
@@ -3,14 +3,28 @@ package metrics
 import (
 	"bytes"
 	"context"
+	"crypto/tls"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"slices"
+	"sort"
+	"strconv"
 	"time"
 
 	"github.com/gogo/protobuf/proto"
 	"github.com/golang/snappy"
 	"github.com/mjasion/balena-home/thermostats/buffer"
+	"github.com/mjasion/balena-home/thermostats/climate"
+	"github.com/mjasion/balena-home/thermostats/clock"
+	"github.com/mjasion/balena-home/thermostats/dnscache"
+	"github.com/mjasion/balena-home/thermostats/events"
+	"github.com/mjasion/balena-home/thermostats/faults"
+	"github.com/mjasion/balena-home/thermostats/httpclient"
+	"github.com/mjasion/balena-home/thermostats/schedule"
+	"github.com/mjasion/balena-home/thermostats/telemetry"
+	"github.com/mjasion/balena-home/thermostats/watchdog"
 	"github.com/prometheus/prometheus/prompb"
 	"go.uber.org/zap"
 )
@@ -26,32 +40,335 @@ type Pusher struct {
 	buffer       *buffer.RingBuffer
 	pushInterval time.Duration
 	batchSize    int
+	eventLog     *events.Log
+	failures     int           // Consecutive failed push cycles
+	maxBackoff   time.Duration // Longest time between scheduled pushes while failing, 0 keeps the push interval
+	aligned      bool          // Push on wall-clock multiples of the interval
+	clock        clock.Clock   // Schedules pushes and timestamps samples the pusher generates
+
+	derivedHumidity bool // Push dew point and absolute humidity for BLE sensors
+
+	tenantID        string                        // X-Scope-OrgID for multi-tenant Mimir/Cortex
+	tenantOverrides map[buffer.ReadingType]string // Per reading type tenant
+
+	name     string // Endpoint name, empty for the primary endpoint
+	protocol string // ProtocolRemoteWrite or ProtocolVMImport
+
+	maxSampleAge   time.Duration     // Samples older than this are dropped, 0 disables
+	retimestampOld bool              // Keep the newest too-old sample per series at the age limit
+	dropped        map[string]int64  // Dropped samples by reason
+	dedup          *dedupCache       // Nil disables deduplication
+	coalesce       *coalescer        // Nil pushes every sample of slow-moving gauges
+	cardinality    *cardinalityGuard // Nil disables the series limit
+
+	leader  Leader        // Nil pushes unconditionally
+	trigger chan struct{} // Requests an out-of-cycle push
+
+	buildInfo      []prompb.Label // Labels of the build info series, nil omits it
+	externalLabels []prompb.Label // Added to every series that lacks them
+
+	metered         Metered       // Nil never applies the metered profile
+	meteredInterval time.Duration // Minimum time between scheduled pushes while metered
+	aggregatesOnly  bool          // Drop raw BLE readings while metered; hourly summaries cover them
+	lastFlush       time.Time     // Start of the last push cycle, successful or not
+
+	pushLog *PushLog // Nil keeps no record of push attempts
+
+	faults    *faults.Injector    // Nil never injects faults
+	heartbeat *watchdog.Heartbeat // Nil reports no liveness
+
+	connectionMetrics bool // Trace connections of pushes for the telemetry recorder
+
+	provenance *provenance // Nil sends no provenance headers
+	batch      batchInfo   // Batch being pushed, sent in the provenance headers
+	requestID  string      // X-Request-ID of the last push attempt, logged with its outcome
+
+	sink Sink // Receives the flushed batches, the pusher itself for remote_write
+}
+
+// Sink delivers a batch of readings; Pusher is the remote_write sink, nats.Publisher another
+// Returning an error keeps the batch buffered for the pusher's next attempt, so a sink returns
+// nil only once the whole batch is confirmed
+type Sink interface {
+	Push(ctx context.Context, readings []*buffer.Reading) error
+}
+
+// Metered reports whether the device is on a metered link, see the connectivity package
+type Metered interface {
+	IsMetered() bool
+}
+
+// Leader reports whether this instance should push, see the leader package
+// A standby keeps readings buffered so it can take over with recent data
+type Leader interface {
+	IsLeader() bool
 }
 
 // New creates a new Prometheus pusher
 func New(url, username, password string, buf *buffer.RingBuffer, pushIntervalSeconds, batchSize int, logger *zap.Logger) *Pusher {
-	return &Pusher{
+	p := &Pusher{
 		url:          url,
 		username:     username,
 		password:     password,
-		client: &http.Client{
-			Timeout: 30 * time.Second,
-		},
+		client:       httpclient.NewDefault(httpclient.Config{Timeout: 30 * time.Second}),
 		logger:       logger,
 		lastPush:     time.Now(),
+		clock:        clock.Real,
 		buffer:       buf,
 		pushInterval: time.Duration(pushIntervalSeconds) * time.Second,
 		batchSize:    batchSize,
+		protocol:     ProtocolRemoteWrite,
+		dropped:      make(map[string]int64),
+		trigger:      make(chan struct{}, 1),
+	}
+	p.sink = p
+	return p
+}
+
+// SetSink delivers the buffered readings to sink instead of the remote_write endpoint, keeping
+// the pusher's batching, backoff and retries; remote_write settings don't apply to it
+func (p *Pusher) SetSink(sink Sink) {
+	p.sink = sink
+}
+
+// SetEventLog sets the event log used to record push failures and recoveries
+func (p *Pusher) SetEventLog(eventLog *events.Log) {
+	p.eventLog = eventLog
+}
+
+// SetName names the endpoint in logs, events and dependency metrics; call before SetRecorder
+// Used to tell additional remote_write endpoints apart from the primary one
+func (p *Pusher) SetName(name string) {
+	p.name = name
+	p.logger = p.logger.With(zap.String("endpoint", name))
+}
+
+// SetProtocol selects the push protocol, ProtocolRemoteWrite (default) or ProtocolVMImport
+// With ProtocolVMImport the URL must point at VictoriaMetrics' /api/v1/import endpoint
+func (p *Pusher) SetProtocol(protocol string) {
+	p.protocol = protocol
+}
+
+// SetMaxSampleAge drops samples older than maxAge before pushing, so readings replayed after an
+// outage do not poison batches with samples outside the endpoint's out-of-order window
+// With retimestamp, the newest too-old sample of each series is pushed at the age limit instead
+func (p *Pusher) SetMaxSampleAge(maxAge time.Duration, retimestamp bool) {
+	p.maxSampleAge = maxAge
+	p.retimestampOld = retimestamp
+}
+
+// SetDedupWindow drops samples with the same series and timestamp as one pushed within window,
+// as sent by replays or redundant collectors; 0 disables
+func (p *Pusher) SetDedupWindow(window time.Duration) {
+	if window <= 0 {
+		p.dedup = nil
+		return
+	}
+	p.dedup = newDedupCache(window)
+}
+
+// SetCoalesce skips samples of the metrics repeating the last pushed value of their series until
+// maxStaleness passed since it, cutting the volume of slow-moving gauges; no metrics disables
+func (p *Pusher) SetCoalesce(metrics []string, maxStaleness time.Duration) {
+	if len(metrics) == 0 {
+		p.coalesce = nil
+		return
+	}
+	p.coalesce = newCoalescer(metrics, maxStaleness)
+}
+
+// SetSeriesLimit drops new series of a metric name once it has limit series, with per metric name
+// overrides; 0 disables the limit, globally or for the overridden metric
+func (p *Pusher) SetSeriesLimit(limit int, overrides map[string]int) {
+	if limit <= 0 && len(overrides) == 0 {
+		p.cardinality = nil
+		return
+	}
+	p.cardinality = newCardinalityGuard(limit, overrides)
+}
+
+// SetDerivedHumidity enables dew point and absolute humidity series for BLE sensors
+func (p *Pusher) SetDerivedHumidity(enabled bool) {
+	p.derivedHumidity = enabled
+}
+
+// SetTenant sets the X-Scope-OrgID tenant sent with every push, with optional per reading type overrides
+// An empty tenant omits the header
+func (p *Pusher) SetTenant(tenantID string, overrides map[buffer.ReadingType]string) {
+	p.tenantID = tenantID
+	p.tenantOverrides = overrides
+}
+
+// tenantFor returns the tenant for a reading type
+func (p *Pusher) tenantFor(readingType buffer.ReadingType) string {
+	if tenant, ok := p.tenantOverrides[readingType]; ok {
+		return tenant
+	}
+	return p.tenantID
+}
+
+// SetBuildInfo pushes a home_controller_build_info series with value 1 and the labels,
+// such as version and commit, with every request
+func (p *Pusher) SetBuildInfo(labels map[string]string) {
+	p.buildInfo = []prompb.Label{{Name: "__name__", Value: "home_controller_build_info"}}
+	for name, value := range labels {
+		p.buildInfo = append(p.buildInfo, prompb.Label{Name: name, Value: value})
+	}
+	sort.Slice(p.buildInfo[1:], func(i, j int) bool { return p.buildInfo[i+1].Name < p.buildInfo[j+1].Name })
+}
+
+// SetProvenance sends the collector version, the device and a batch ID with the reading counts per
+// type as X-Collector-* headers with every push, so the receiving side can tell who sent what
+func (p *Pusher) SetProvenance(version, device string) {
+	p.provenance = &provenance{version: version, device: device}
+}
+
+// SetExternalLabels adds the labels, such as the device's fleet identity, to every pushed series
+// Labels a series already has are kept, so samples received from other devices stay attributed to them
+func (p *Pusher) SetExternalLabels(labels map[string]string) {
+	p.externalLabels = nil
+	for name, value := range labels {
+		p.externalLabels = append(p.externalLabels, prompb.Label{Name: name, Value: value})
+	}
+	sort.Slice(p.externalLabels, func(i, j int) bool { return p.externalLabels[i].Name < p.externalLabels[j].Name })
+}
+
+// addExternalLabels adds the external labels a series lacks; label slices are copied, not modified
+func (p *Pusher) addExternalLabels(series []prompb.TimeSeries) {
+	if len(p.externalLabels) == 0 {
+		return
+	}
+	for i := range series {
+		labels := make([]prompb.Label, len(series[i].Labels), len(series[i].Labels)+len(p.externalLabels))
+		copy(labels, series[i].Labels)
+		for _, external := range p.externalLabels {
+			if !slices.ContainsFunc(series[i].Labels, func(l prompb.Label) bool { return l.Name == external.Name }) {
+				labels = append(labels, external)
+			}
+		}
+		series[i].Labels = labels
+	}
+}
+
+// SetMeteredProfile pushes at most every interval while metered reports a metered link,
+// and with aggregatesOnly drops raw BLE readings instead of pushing them
+// Pushes requested with Trigger are not delayed
+func (p *Pusher) SetMeteredProfile(metered Metered, interval time.Duration, aggregatesOnly bool) {
+	p.metered = metered
+	p.meteredInterval = interval
+	p.aggregatesOnly = aggregatesOnly
+}
+
+// isMetered reports whether the low-bandwidth profile applies
+func (p *Pusher) isMetered() bool {
+	return p.metered != nil && p.metered.IsMetered()
+}
+
+// SetPushLog records every push attempt in l
+func (p *Pusher) SetPushLog(l *PushLog) {
+	p.pushLog = l
+}
+
+// SetLeader only pushes while leader reports this instance as the leader
+func (p *Pusher) SetLeader(leader Leader) {
+	p.leader = leader
+}
+
+// standby reports whether another instance is pushing
+func (p *Pusher) standby() bool {
+	return p.leader != nil && !p.leader.IsLeader()
+}
+
+// SetRecorder records remote_write requests as the "prometheus" dependency, or "prometheus_<name>" for named endpoints
+func (p *Pusher) SetRecorder(recorder *telemetry.Recorder) {
+	dependency := "prometheus"
+	if p.name != "" {
+		dependency += "_" + p.name
+	}
+	if p.connectionMetrics {
+		recorder.InstrumentConnections(p.client, dependency)
+		return
+	}
+	recorder.Instrument(p.client, dependency)
+}
+
+// SetConnectionMetrics also records connection reuse, connect and TLS handshake time and time to
+// first byte of pushes; call before SetRecorder
+func (p *Pusher) SetConnectionMetrics(enabled bool) {
+	p.connectionMetrics = enabled
+}
+
+// SetConnectionPool keeps up to maxIdleConns connections to the endpoint open for idleTimeout
+// between pushes and resumes TLS sessions on new ones, so pushes don't pay for a full TLS
+// handshake each time; 0 keeps the transport's default
+func (p *Pusher) SetConnectionPool(maxIdleConns int, idleTimeout time.Duration) {
+	transport := httpclient.Base(p.client)
+	if transport == nil {
+		p.logger.Warn("push client has no HTTP transport, not tuning its connection pool")
+		return
+	}
+	if maxIdleConns > 0 {
+		transport.MaxIdleConns = maxIdleConns
+		transport.MaxIdleConnsPerHost = maxIdleConns
+	}
+	if idleTimeout > 0 {
+		transport.IdleConnTimeout = idleTimeout
 	}
+	if transport.TLSClientConfig == nil {
+		transport.TLSClientConfig = &tls.Config{}
+	}
+	transport.TLSClientConfig.ClientSessionCache = tls.NewLRUClientSessionCache(0)
+}
+
+// SetResolver dials pushes through the DNS cache, recycling connections after maxFailures
+// consecutive transport errors; call before SetFaults and SetRecorder
+func (p *Pusher) SetResolver(resolver *dnscache.Resolver, maxFailures int) {
+	resolver.Instrument(p.client, maxFailures)
+}
+
+// SetFaults fails pushes while the push_failure fault is injected and keeps readings buffered
+// while buffer_pressure is; call before SetRecorder
+func (p *Pusher) SetFaults(injector *faults.Injector) {
+	p.faults = injector
+	injector.Instrument(p.client, faults.PushFailure)
+}
+
+// SetHeartbeat beats the heartbeat after every push cycle, so the watchdog notices a hung push loop
+func (p *Pusher) SetHeartbeat(heartbeat *watchdog.Heartbeat) {
+	p.heartbeat = heartbeat
+}
+
+// SetMaxBackoff lets the time between scheduled pushes double with each consecutive failed push up
+// to maxBackoff, sparing a failing endpoint and the uplink; 0 keeps pushing at the push interval
+func (p *Pusher) SetMaxBackoff(maxBackoff time.Duration) {
+	p.maxBackoff = maxBackoff
+}
+
+// SetAligned schedules pushes on wall-clock multiples of the push interval, e.g. :00, :15, :30
+// and :45 for 15s, so devices push at the same moments
+func (p *Pusher) SetAligned(aligned bool) {
+	p.aligned = aligned
+}
+
+// SetClock replaces the system clock, e.g. with a fake one in tests; call before Start
+func (p *Pusher) SetClock(c clock.Clock) {
+	p.clock = c
+	p.lastPush = c.Now()
 }
 
 // Start begins the periodic metrics pushing in a goroutine
+// Pushes are scheduled at the push interval while healthy and back off while failing; triggers,
+// e.g. from the buffer watermark, push at once
 func (p *Pusher) Start(ctx context.Context) {
-	ticker := time.NewTicker(p.pushInterval)
-	defer ticker.Stop()
+	next := p.nextPush(p.clock.Now(), p.pushInterval)
+	timer := p.clock.NewTimer(next.Sub(p.clock.Now()))
+	defer timer.Stop()
 
 	p.logger.Info("prometheus pusher started",
 		zap.Duration("push_interval", p.pushInterval),
+		zap.Duration("max_backoff", p.maxBackoff),
+		zap.Bool("aligned", p.aligned),
+		zap.Time("first_push", next),
 		zap.Int("batch_size", p.batchSize),
 	)
 
@@ -60,94 +377,334 @@ func (p *Pusher) Start(ctx context.Context) {
 		case <-ctx.Done():
 			p.logger.Info("prometheus pusher stopping")
 			return
-		case <-ticker.C:
-			// Get all readings and clear buffer atomically
-			readings := p.buffer.GetAllAndClear()
-			if len(readings) == 0 {
-				p.logger.Debug("no readings to push")
-				continue
-			}
+		case <-timer.C():
+		case <-p.trigger:
+			p.logger.Info("out-of-cycle push requested", zap.Int("buffered", p.buffer.Size()))
+			p.pushOrKeep(ctx)
+			p.heartbeat.Beat()
+			// The scheduled push stays due; a trigger doesn't postpone it
+			continue
+		}
+		if !p.isMetered() || p.clock.Now().Sub(p.lastFlush) >= p.meteredInterval {
+			p.pushOrKeep(ctx)
+		}
+		p.heartbeat.Beat()
 
-			p.logger.Debug("pushing metrics to prometheus",
-				zap.Int("total_readings", len(readings)),
-				zap.Int("batch_size", p.batchSize),
+		interval := p.nextInterval()
+		if interval != p.pushInterval {
+			p.logger.Info("pushes failing, backing off",
+				zap.Int("consecutive_failures", p.failures),
+				zap.Duration("next_push_in", interval),
 			)
+		}
+		next = p.nextPush(next, interval)
+		timer.Reset(next.Sub(p.clock.Now()))
+	}
+}
 
-			// Process readings in batches
-			totalBatches := (len(readings) + p.batchSize - 1) / p.batchSize
-			for batchNum := 0; batchNum < totalBatches; batchNum++ {
-				start := batchNum * p.batchSize
-				end := start + p.batchSize
-				if end > len(readings) {
-					end = len(readings)
-				}
-				batch := readings[start:end]
+// nextPush returns the time of the push following the one scheduled at last: the next wall-clock
+// multiple of interval when aligned, otherwise interval after last; missed pushes aren't caught up
+func (p *Pusher) nextPush(last time.Time, interval time.Duration) time.Time {
+	now := p.clock.Now()
+	if p.aligned {
+		return schedule.Next(now, interval, 0)
+	}
+	next := last.Add(interval)
+	for !next.After(now) {
+		next = next.Add(interval)
+	}
+	return next
+}
 
-				p.logger.Debug("pushing batch",
-					zap.Int("batch_number", batchNum+1),
-					zap.Int("total_batches", totalBatches),
-					zap.Int("batch_readings", len(batch)),
-				)
+// pushOrKeep flushes the buffer unless this instance is a standby or buffer pressure is injected
+func (p *Pusher) pushOrKeep(ctx context.Context) {
+	if p.standby() {
+		p.logger.Debug("standby, keeping readings buffered", zap.Int("buffered", p.buffer.Size()))
+		return
+	}
+	if p.faults.Active(faults.BufferPressure) {
+		p.logger.Warn("buffer pressure fault injected, keeping readings buffered", zap.Int("buffered", p.buffer.Size()))
+		return
+	}
+	p.flush(ctx)
+}
 
-				err := p.Push(ctx, batch)
-				if err != nil {
-					p.logger.Error("failed to push batch, re-adding remaining readings to buffer",
-						zap.Error(err),
-						zap.Int("batch_number", batchNum+1),
-						zap.Int("failed_readings", len(readings)-start),
-					)
-					// Re-add the failed batch and all remaining batches
-					p.buffer.AddMultiple(readings[start:])
-					break
-				}
+// nextInterval returns the time until the next scheduled push: the push interval while healthy,
+// doubling with each consecutive failed push up to maxBackoff while failing
+func (p *Pusher) nextInterval() time.Duration {
+	interval := p.pushInterval
+	for i := 0; i < p.failures && interval < p.maxBackoff; i++ {
+		interval *= 2
+	}
+	if p.maxBackoff > p.pushInterval && interval > p.maxBackoff {
+		interval = p.maxBackoff
+	}
+	return interval
+}
 
-				p.logger.Debug("successfully pushed batch",
-					zap.Int("batch_number", batchNum+1),
-					zap.Int("batch_readings", len(batch)),
-				)
-			}
+// Trigger requests an immediate push of the buffered readings; a pending request covers later ones
+func (p *Pusher) Trigger() {
+	select {
+	case p.trigger <- struct{}{}:
+	default:
+	}
+}
+
+// flush pushes all buffered readings in batches, re-adding them to the buffer on failure
+func (p *Pusher) flush(ctx context.Context) {
+	p.lastFlush = p.clock.Now()
+	// Fanout endpoints are flushed without running Start
+	defer p.heartbeat.Beat()
+
+	// Get all readings and clear buffer atomically
+	readings := p.buffer.GetAllAndClear()
+	if p.aggregatesOnly && p.isMetered() {
+		readings = p.dropRawReadings(readings)
+	}
+	if len(readings) == 0 {
+		p.logger.Debug("no readings to push")
+		return
+	}
+
+	p.logger.Debug("pushing metrics to prometheus",
+		zap.Int("total_readings", len(readings)),
+		zap.Int("batch_size", p.batchSize),
+	)
+
+	// Process readings in batches
+	totalBatches := (len(readings) + p.batchSize - 1) / p.batchSize
+	for batchNum := 0; batchNum < totalBatches; batchNum++ {
+		start := batchNum * p.batchSize
+		end := start + p.batchSize
+		if end > len(readings) {
+			end = len(readings)
+		}
+		batch := readings[start:end]
+
+		p.logger.Debug("pushing batch",
+			zap.Int("batch_number", batchNum+1),
+			zap.Int("total_batches", totalBatches),
+			zap.Int("batch_readings", len(batch)),
+		)
+
+		err := p.sink.Push(ctx, batch)
+		if err != nil {
+			p.logger.Error("failed to push batch, re-adding remaining readings to buffer",
+				zap.Error(err),
+				zap.Int("batch_number", batchNum+1),
+				zap.Int("failed_readings", len(readings)-start),
+			)
+			// Re-add the failed batch and all remaining batches
+			p.buffer.AddMultiple(readings[start:])
+			p.recordFailure(err)
+			break
+		}
+		p.recordSuccess()
+
+		p.logger.Debug("successfully pushed batch",
+			zap.Int("batch_number", batchNum+1),
+			zap.Int("batch_readings", len(batch)),
+		)
+	}
+}
+
+// dropRawReadings removes raw BLE readings, whose hourly summaries are pushed instead
+func (p *Pusher) dropRawReadings(readings []*buffer.Reading) []*buffer.Reading {
+	kept := readings[:0]
+	for _, reading := range readings {
+		if reading.Type != buffer.ReadingTypeBLE {
+			kept = append(kept, reading)
 		}
 	}
+	if dropped := len(readings) - len(kept); dropped > 0 {
+		p.logger.Info("metered link, dropped raw BLE readings in favour of hourly summaries",
+			zap.Int("dropped_readings", dropped),
+		)
+	}
+	return kept
+}
+
+// recordFailure counts a failed push and records an event when pushing starts failing
+func (p *Pusher) recordFailure(err error) {
+	p.failures++
+	if p.failures == 1 {
+		p.eventLog.Record(events.TypePushFailing, "pusher", "metrics push failing",
+			p.eventFields(map[string]string{"error": err.Error()}),
+		)
+	}
+}
+
+// recordSuccess resets the failure count and records an event if pushing recovered
+func (p *Pusher) recordSuccess() {
+	if p.failures == 0 {
+		return
+	}
+	p.eventLog.Record(events.TypePushRecovered, "pusher",
+		fmt.Sprintf("metrics push recovered after %d failures", p.failures),
+		p.eventFields(map[string]string{"failures": fmt.Sprintf("%d", p.failures)}),
+	)
+	p.failures = 0
+}
+
+// eventFields adds the endpoint name to event fields for named endpoints
+func (p *Pusher) eventFields(fields map[string]string) map[string]string {
+	if p.name != "" {
+		fields["endpoint"] = p.name
+	}
+	return fields
 }
 
 // Push pushes sensor readings to Prometheus
+// Readings are sent in one request per tenant; if a tenant fails, tenants pushed
+// earlier in the same call receive the readings again on retry
 func (p *Pusher) Push(ctx context.Context, readings []*buffer.Reading) error {
 	if len(readings) == 0 {
 		p.logger.Debug("no readings to push")
 		return nil
 	}
 
+	if len(p.tenantOverrides) == 0 {
+		return p.pushTenant(ctx, p.tenantID, readings)
+	}
+
+	// Group readings by tenant, keeping the order tenants first appear in
+	var tenants []string
+	tenantReadings := make(map[string][]*buffer.Reading)
+	for _, r := range readings {
+		tenant := p.tenantFor(r.Type)
+		if _, ok := tenantReadings[tenant]; !ok {
+			tenants = append(tenants, tenant)
+		}
+		tenantReadings[tenant] = append(tenantReadings[tenant], r)
+	}
+
+	for _, tenant := range tenants {
+		if err := p.pushTenant(ctx, tenant, tenantReadings[tenant]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// pushTenant pushes readings for a single tenant with retries
+func (p *Pusher) pushTenant(ctx context.Context, tenant string, readings []*buffer.Reading) error {
 	// Build write request
 	writeReq, err := p.buildWriteRequest(readings)
 	if err != nil {
 		return fmt.Errorf("failed to build write request: %w", err)
 	}
+	p.addExternalLabels(writeReq.Timeseries)
+
+	// Drop new series of metrics that reached their series limit
+	if p.cardinality != nil {
+		dropped, limited := p.cardinality.filter(writeReq)
+		if dropped > 0 {
+			p.dropped[dropReasonCardinality] += int64(dropped)
+		}
+		for _, name := range limited {
+			p.logger.Warn("metric reached its series limit, dropping new series",
+				zap.String("tenant", tenant),
+				zap.String("metric", name),
+				zap.Int("limit", p.cardinality.limitFor(name)),
+			)
+			p.eventLog.Record(events.TypeSeriesLimited, "pusher", "metric reached its series limit",
+				p.eventFields(map[string]string{"metric": name}),
+			)
+		}
+	}
+
+	// Drop samples outside the endpoint's out-of-order window
+	if p.maxSampleAge > 0 {
+		if dropped := limitSampleAge(writeReq, p.clock.Now().Add(-p.maxSampleAge), p.retimestampOld); dropped > 0 {
+			p.dropped[dropReasonTooOld] += int64(dropped)
+			p.logger.Warn("dropped samples older than max sample age",
+				zap.String("tenant", tenant),
+				zap.Int("dropped_samples", dropped),
+				zap.Duration("max_sample_age", p.maxSampleAge),
+			)
+		}
+	}
+	// Drop samples already pushed within the dedup window
+	if p.dedup != nil {
+		if dropped := p.dedup.filter(tenant, writeReq); dropped > 0 {
+			p.dropped[dropReasonDuplicate] += int64(dropped)
+			p.logger.Debug("dropped duplicate samples",
+				zap.String("tenant", tenant),
+				zap.Int("dropped_samples", dropped),
+			)
+		}
+	}
+	// Skip unchanged values of slow-moving gauges
+	if p.coalesce != nil {
+		if skipped := p.coalesce.filter(tenant, writeReq); skipped > 0 {
+			p.dropped[dropReasonCoalesced] += int64(skipped)
+			p.logger.Debug("skipped unchanged gauge samples",
+				zap.String("tenant", tenant),
+				zap.Int("skipped_samples", skipped),
+			)
+		}
+	}
+	if len(writeReq.Timeseries) == 0 {
+		return nil
+	}
+	selfSeries := p.buildDroppedTimeSeries()
+	selfSeries = append(selfSeries, p.buildOverwrittenTimeSeries()...)
+	if p.buildInfo != nil {
+		selfSeries = append(selfSeries, prompb.TimeSeries{
+			Labels:  p.buildInfo,
+			Samples: []prompb.Sample{{Value: 1, Timestamp: p.clock.Now().UnixMilli()}},
+		})
+	}
+	p.addExternalLabels(selfSeries)
+	writeReq.Timeseries = append(writeReq.Timeseries, selfSeries...)
 
 	// Try to push with retries
+	p.batch = newBatchInfo(readings)
 	var lastErr error
 	for attempt := 1; attempt <= 3; attempt++ {
-		err := p.pushOnce(ctx, writeReq)
+		err := p.pushOnce(ctx, writeReq, tenant)
+
+		// Exclude series the endpoint rejected as out of order or too old, and resend the rest
+		var statusErr *statusError
+		if errors.As(err, &statusErr) && statusErr.StatusCode == http.StatusBadRequest {
+			if removed := removeSeries(writeReq, parseRejectedSeries(statusErr.Body)); removed > 0 {
+				p.dropped[dropReasonRejected] += int64(removed)
+				p.logger.Warn("endpoint rejected samples, excluding them from retries",
+					zap.String("tenant", tenant),
+					zap.Int("dropped_samples", removed),
+					zap.Error(err),
+				)
+				if len(writeReq.Timeseries) == 0 {
+					return nil
+				}
+				attempt--
+				continue
+			}
+
+			// The body doesn't name the offending series; isolate them by bisection
+			err = p.isolateInvalidSeries(ctx, tenant, writeReq)
+		}
+
 		if err == nil {
-			p.lastPush = time.Now()
+			p.lastPush = p.clock.Now()
+			if p.dedup != nil {
+				p.dedup.commit(tenant, writeReq)
+			}
+			if p.coalesce != nil {
+				p.coalesce.commit(tenant, writeReq)
+			}
 
-			bleCount := 0
-			netatmoCount := 0
-			powerCount := 0
+			counts := make(map[buffer.ReadingType]int)
 			for _, r := range readings {
-				if r.Type == buffer.ReadingTypeBLE {
-					bleCount++
-				} else if r.Type == buffer.ReadingTypeNetatmo {
-					netatmoCount++
-				} else if r.Type == buffer.ReadingTypePower {
-					powerCount++
-				}
+				counts[r.Type]++
 			}
 
 			p.logger.Info("successfully pushed metrics",
-				zap.Int("ble_data_points", bleCount),
-				zap.Int("netatmo_data_points", netatmoCount),
-				zap.Int("power_data_points", powerCount),
+				zap.Any("data_points", counts),
 				zap.Int("total_data_points", len(readings)),
+				zap.String("tenant", tenant),
+				zap.String("batch_id", p.batch.id),
+				zap.String("request_id", p.requestID),
 				zap.Int("attempt", attempt),
 			)
 			return nil
@@ -155,17 +712,21 @@ func (p *Pusher) Push(ctx context.Context, readings []*buffer.Reading) error {
 
 		lastErr = err
 		p.logger.Warn("failed to push metrics, will retry",
+			zap.String("tenant", tenant),
+			zap.String("batch_id", p.batch.id),
+			zap.String("request_id", p.requestID),
 			zap.Int("attempt", attempt),
 			zap.Error(err),
 		)
 
 		// Exponential backoff: 1s, 2s, 4s
 		if attempt < 3 {
-			backoff := time.Duration(1<<(attempt-1)) * time.Second
+			backoff := p.clock.NewTimer(time.Duration(1<<(attempt-1)) * time.Second)
 			select {
 			case <-ctx.Done():
+				backoff.Stop()
 				return ctx.Err()
-			case <-time.After(backoff):
+			case <-backoff.C():
 			}
 		}
 	}
@@ -173,14 +734,44 @@ func (p *Pusher) Push(ctx context.Context, readings []*buffer.Reading) error {
 	return fmt.Errorf("failed to push metrics after 3 attempts: %w", lastErr)
 }
 
+// BuildSeries converts readings to the series a push would send, without external labels,
+// deduplication or the cardinality guard; used to export local history with the pushed names
+func (p *Pusher) BuildSeries(readings []*buffer.Reading) ([]prompb.TimeSeries, error) {
+	writeReq, err := p.buildWriteRequest(readings)
+	if err != nil {
+		return nil, err
+	}
+	return writeReq.Timeseries, nil
+}
+
 // buildWriteRequest converts sensor readings to Prometheus WriteRequest
 func (p *Pusher) buildWriteRequest(readings []*buffer.Reading) (*prompb.WriteRequest, error) {
 	var timeSeries []prompb.TimeSeries
 
-	// Separate BLE, Netatmo, and Power readings
+	// Separate BLE, Netatmo, Power, HeatPump, Water, OneWire, I2C, AirQuality, Zigbee, dependency, automation, derived, room, summary, and remote readings
 	var bleReadings []*buffer.SensorReading
 	var netatmoReadings []*buffer.ThermostatReading
 	var powerReadings []*buffer.PowerReading
+	var heatPumpReadings []*buffer.HeatPumpReading
+	var waterReadings []*buffer.WaterReading
+	var oneWireReadings []*buffer.OneWireReading
+	var i2cReadings []*buffer.I2CReading
+	var airQualityReadings []*buffer.AirQualityReading
+	var zigbeeReadings []*buffer.ZigbeeReading
+	var dependencyReadings []*buffer.DependencyReading
+	var automationReadings []*buffer.AutomationReading
+	var derivedReadings []*buffer.DerivedReading
+	var roomReadings []*buffer.RoomReading
+	var summaryReadings []*buffer.SummaryReading
+	var remoteReadings []*buffer.RemoteReading
+	var httpReadings []*buffer.HTTPReading
+	var conflictReadings []*buffer.ConflictReading
+	var batteryReadings []*buffer.BatteryReading
+	var locationReadings []*buffer.LocationReading
+	var reconciliationReadings []*buffer.ReconciliationReading
+	var priceReadings []*buffer.PriceReading
+	var occupancyReadings []*buffer.OccupancyReading
+	var ventilationReadings []*buffer.VentilationReading
 
 	for _, reading := range readings {
 		switch reading.Type {
@@ -196,6 +787,86 @@ func (p *Pusher) buildWriteRequest(readings []*buffer.Reading) (*prompb.WriteReq
 			if reading.Power != nil {
 				powerReadings = append(powerReadings, reading.Power)
 			}
+		case buffer.ReadingTypeHeatPump:
+			if reading.HeatPump != nil {
+				heatPumpReadings = append(heatPumpReadings, reading.HeatPump)
+			}
+		case buffer.ReadingTypeWater:
+			if reading.Water != nil {
+				waterReadings = append(waterReadings, reading.Water)
+			}
+		case buffer.ReadingTypeOneWire:
+			if reading.OneWire != nil {
+				oneWireReadings = append(oneWireReadings, reading.OneWire)
+			}
+		case buffer.ReadingTypeI2C:
+			if reading.I2C != nil {
+				i2cReadings = append(i2cReadings, reading.I2C)
+			}
+		case buffer.ReadingTypeAirQuality:
+			if reading.AirQuality != nil {
+				airQualityReadings = append(airQualityReadings, reading.AirQuality)
+			}
+		case buffer.ReadingTypeZigbee:
+			if reading.Zigbee != nil {
+				zigbeeReadings = append(zigbeeReadings, reading.Zigbee)
+			}
+		case buffer.ReadingTypeDependency:
+			if reading.Dependency != nil {
+				dependencyReadings = append(dependencyReadings, reading.Dependency)
+			}
+		case buffer.ReadingTypeAutomation:
+			if reading.Automation != nil {
+				automationReadings = append(automationReadings, reading.Automation)
+			}
+		case buffer.ReadingTypeDerived:
+			if reading.Derived != nil {
+				derivedReadings = append(derivedReadings, reading.Derived)
+			}
+		case buffer.ReadingTypeRoom:
+			if reading.Room != nil {
+				roomReadings = append(roomReadings, reading.Room)
+			}
+		case buffer.ReadingTypeSummary:
+			if reading.Summary != nil {
+				summaryReadings = append(summaryReadings, reading.Summary)
+			}
+		case buffer.ReadingTypeRemote:
+			if reading.Remote != nil {
+				remoteReadings = append(remoteReadings, reading.Remote)
+			}
+		case buffer.ReadingTypeHTTP:
+			if reading.HTTP != nil {
+				httpReadings = append(httpReadings, reading.HTTP)
+			}
+		case buffer.ReadingTypeConflict:
+			if reading.Conflict != nil {
+				conflictReadings = append(conflictReadings, reading.Conflict)
+			}
+		case buffer.ReadingTypeBattery:
+			if reading.Battery != nil {
+				batteryReadings = append(batteryReadings, reading.Battery)
+			}
+		case buffer.ReadingTypeLocation:
+			if reading.Location != nil {
+				locationReadings = append(locationReadings, reading.Location)
+			}
+		case buffer.ReadingTypeReconciliation:
+			if reading.Reconciliation != nil {
+				reconciliationReadings = append(reconciliationReadings, reading.Reconciliation)
+			}
+		case buffer.ReadingTypePrice:
+			if reading.Price != nil {
+				priceReadings = append(priceReadings, reading.Price)
+			}
+		case buffer.ReadingTypeOccupancy:
+			if reading.Occupancy != nil {
+				occupancyReadings = append(occupancyReadings, reading.Occupancy)
+			}
+		case buffer.ReadingTypeVentilation:
+			if reading.Ventilation != nil {
+				ventilationReadings = append(ventilationReadings, reading.Ventilation)
+			}
 		}
 	}
 
@@ -220,57 +891,190 @@ func (p *Pusher) buildWriteRequest(readings []*buffer.Reading) (*prompb.WriteReq
 	}
 	timeSeries = append(timeSeries, powerSeries...)
 
-	return &prompb.WriteRequest{
-		Timeseries: timeSeries,
-	}, nil
-}
+	// Process HeatPump readings
+	heatPumpSeries, err := p.buildHeatPumpTimeSeries(heatPumpReadings)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build HeatPump time series: %w", err)
+	}
+	timeSeries = append(timeSeries, heatPumpSeries...)
 
-// buildBLETimeSeries builds time series for BLE sensor readings
-func (p *Pusher) buildBLETimeSeries(readings []*buffer.SensorReading) ([]prompb.TimeSeries, error) {
-	// Group readings by sensor
-	type sensorKey struct {
-		name string
-		id   int
+	// Process Water readings
+	waterSeries, err := p.buildWaterTimeSeries(waterReadings)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build Water time series: %w", err)
 	}
-	sensorReadings := make(map[sensorKey][]*buffer.SensorReading)
-	for _, reading := range readings {
-		key := sensorKey{name: reading.SensorName, id: reading.SensorID}
-		sensorReadings[key] = append(sensorReadings[key], reading)
+	timeSeries = append(timeSeries, waterSeries...)
+
+	// Process OneWire readings
+	oneWireSeries, err := p.buildOneWireTimeSeries(oneWireReadings)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build OneWire time series: %w", err)
 	}
+	timeSeries = append(timeSeries, oneWireSeries...)
 
-	// Build time series for each sensor and metric
-	var timeSeries []prompb.TimeSeries
-	for key, sensorData := range sensorReadings {
-		// Create base labels for this sensor
-		baseLabels := []prompb.Label{
-			{
-				Name:  "sensor_name",
-				Value: key.name,
-			},
-			{
-				Name:  "sensor_id",
-				Value: fmt.Sprintf("%d", key.id),
-			},
-			{
-				Name:  "mac",
-				Value: sensorData[0].MAC, // All readings have same MAC
-			},
-		}
+	// Process I2C readings
+	i2cSeries, err := p.buildI2CTimeSeries(i2cReadings)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build I2C time series: %w", err)
+	}
+	timeSeries = append(timeSeries, i2cSeries...)
 
-		// Temperature time series
+	// Process AirQuality readings
+	airQualitySeries, err := p.buildAirQualityTimeSeries(airQualityReadings)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build AirQuality time series: %w", err)
+	}
+	timeSeries = append(timeSeries, airQualitySeries...)
+
+	// Process Zigbee readings
+	zigbeeSeries, err := p.buildZigbeeTimeSeries(zigbeeReadings)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build Zigbee time series: %w", err)
+	}
+	timeSeries = append(timeSeries, zigbeeSeries...)
+
+	// Process dependency readings
+	dependencySeries, err := p.buildDependencyTimeSeries(dependencyReadings)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build dependency time series: %w", err)
+	}
+	timeSeries = append(timeSeries, dependencySeries...)
+
+	// Process automation readings
+	automationSeries, err := p.buildAutomationTimeSeries(automationReadings)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build automation time series: %w", err)
+	}
+	timeSeries = append(timeSeries, automationSeries...)
+
+	// Process derived readings
+	derivedSeries, err := p.buildDerivedTimeSeries(derivedReadings)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build derived time series: %w", err)
+	}
+	timeSeries = append(timeSeries, derivedSeries...)
+
+	// Process room readings
+	roomSeries, err := p.buildRoomTimeSeries(roomReadings)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build room time series: %w", err)
+	}
+	timeSeries = append(timeSeries, roomSeries...)
+
+	// Process summary readings
+	summarySeries, err := p.buildSummaryTimeSeries(summaryReadings)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build summary time series: %w", err)
+	}
+	timeSeries = append(timeSeries, summarySeries...)
+
+	// Process readings received over remote_write
+	remoteSeries, err := p.buildRemoteTimeSeries(remoteReadings)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build remote time series: %w", err)
+	}
+	timeSeries = append(timeSeries, remoteSeries...)
+
+	// Process embedded HTTP server readings
+	httpSeries, err := p.buildHTTPTimeSeries(httpReadings)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build HTTP time series: %w", err)
+	}
+	timeSeries = append(timeSeries, httpSeries...)
+
+	// Process sensor identity conflict readings
+	conflictSeries, err := p.buildConflictTimeSeries(conflictReadings)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build conflict time series: %w", err)
+	}
+	timeSeries = append(timeSeries, conflictSeries...)
+
+	// Process battery life estimate readings
+	batterySeries, err := p.buildBatteryTimeSeries(batteryReadings)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build battery time series: %w", err)
+	}
+	timeSeries = append(timeSeries, batterySeries...)
+
+	// Process nearest receiver readings
+	locationSeries, err := p.buildLocationTimeSeries(locationReadings)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build location time series: %w", err)
+	}
+	timeSeries = append(timeSeries, locationSeries...)
+
+	// Process energy meter reconciliation readings
+	reconciliationSeries, err := p.buildReconciliationTimeSeries(reconciliationReadings)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build reconciliation time series: %w", err)
+	}
+	timeSeries = append(timeSeries, reconciliationSeries...)
+
+	// Process electricity price readings
+	priceSeries, err := p.buildPriceTimeSeries(priceReadings)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build price time series: %w", err)
+	}
+	timeSeries = append(timeSeries, priceSeries...)
+
+	// Process occupancy readings
+	occupancySeries, err := p.buildOccupancyTimeSeries(occupancyReadings)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build occupancy time series: %w", err)
+	}
+	timeSeries = append(timeSeries, occupancySeries...)
+
+	// Process ventilation readings
+	if len(ventilationReadings) > 0 {
+		timeSeries = append(timeSeries, p.buildVentilationTimeSeries(ventilationReadings))
+	}
+
+	return &prompb.WriteRequest{
+		Timeseries: timeSeries,
+	}, nil
+}
+
+// buildBLETimeSeries builds time series for BLE sensor readings
+func (p *Pusher) buildBLETimeSeries(readings []*buffer.SensorReading) ([]prompb.TimeSeries, error) {
+	// Group readings by sensor
+	type sensorKey struct {
+		name string
+		id   int
+	}
+	sensorReadings := make(map[sensorKey][]*buffer.SensorReading)
+	for _, reading := range readings {
+		key := sensorKey{name: reading.SensorName, id: reading.SensorID}
+		sensorReadings[key] = append(sensorReadings[key], reading)
+	}
+
+	// Build time series for each sensor and metric
+	var timeSeries []prompb.TimeSeries
+	for key, sensorData := range sensorReadings {
+		// Create base labels for this sensor
+		baseLabels := []prompb.Label{
+			{
+				Name:  "sensor_name",
+				Value: key.name,
+			},
+			{
+				Name:  "sensor_id",
+				Value: fmt.Sprintf("%d", key.id),
+			},
+			{
+				Name:  "mac",
+				Value: sensorData[0].MAC, // All readings have same MAC
+			},
+		}
+
+		// Temperature time series
 		tempSamples := make([]prompb.Sample, 0, len(sensorData))
 		humiditySamples := make([]prompb.Sample, 0, len(sensorData))
 		batterySamples := make([]prompb.Sample, 0, len(sensorData))
+		var dewPointSamples, absoluteHumiditySamples []prompb.Sample
 
 		for _, reading := range sensorData {
 			// Round timestamp to nearest 10 seconds, then convert to milliseconds
-			ts, ok := reading.Timestamp.(time.Time)
-			if !ok {
-				p.logger.Warn("invalid timestamp type in reading",
-					zap.String("sensor_name", key.name),
-				)
-				continue
-			}
+			ts := reading.Timestamp
 			roundedTime := roundToTenSeconds(ts)
 			timestampMs := roundedTime.UnixMilli()
 
@@ -291,6 +1095,22 @@ func (p *Pusher) buildBLETimeSeries(readings []*buffer.SensorReading) ([]prompb.
 				Value:     float64(reading.BatteryPercent),
 				Timestamp: timestampMs,
 			})
+
+			if p.derivedHumidity {
+				humidity := float64(reading.HumidityPercent)
+				if dewPoint, ok := climate.DewPoint(reading.TemperatureCelsius, humidity); ok {
+					dewPointSamples = append(dewPointSamples, prompb.Sample{
+						Value:     dewPoint,
+						Timestamp: timestampMs,
+					})
+				}
+				if absolute, ok := climate.AbsoluteHumidity(reading.TemperatureCelsius, humidity); ok {
+					absoluteHumiditySamples = append(absoluteHumiditySamples, prompb.Sample{
+						Value:     absolute,
+						Timestamp: timestampMs,
+					})
+				}
+			}
 		}
 
 		// Add temperature time series
@@ -328,6 +1148,32 @@ func (p *Pusher) buildBLETimeSeries(readings []*buffer.SensorReading) ([]prompb.
 			Labels:  batteryLabels,
 			Samples: batterySamples,
 		})
+
+		// Add derived humidity time series
+		if len(dewPointSamples) > 0 {
+			dewPointLabels := append([]prompb.Label{
+				{
+					Name:  "__name__",
+					Value: "ble_dew_point_celsius",
+				},
+			}, baseLabels...)
+			timeSeries = append(timeSeries, prompb.TimeSeries{
+				Labels:  dewPointLabels,
+				Samples: dewPointSamples,
+			})
+		}
+		if len(absoluteHumiditySamples) > 0 {
+			absoluteHumidityLabels := append([]prompb.Label{
+				{
+					Name:  "__name__",
+					Value: "ble_absolute_humidity_g_m3",
+				},
+			}, baseLabels...)
+			timeSeries = append(timeSeries, prompb.TimeSeries{
+				Labels:  absoluteHumidityLabels,
+				Samples: absoluteHumiditySamples,
+			})
+		}
 	}
 
 	return timeSeries, nil
@@ -335,26 +1181,43 @@ func (p *Pusher) buildBLETimeSeries(readings []*buffer.SensorReading) ([]prompb.
 
 // buildNetatmoTimeSeries builds time series for Netatmo thermostat readings
 func (p *Pusher) buildNetatmoTimeSeries(readings []*buffer.ThermostatReading) ([]prompb.TimeSeries, error) {
-	// Group readings by room
+	// Group readings by room, keeping stale (last known value) readings in their own series
 	type roomKey struct {
 		homeID   string
 		homeName string
 		roomID   string
 		roomName string
+		stale    bool
 	}
 	roomReadings := make(map[roomKey][]*buffer.ThermostatReading)
+	var timeSeries []prompb.TimeSeries
 	for _, reading := range readings {
+		if reading.ErrorCode != 0 {
+			timeSeries = append(timeSeries, prompb.TimeSeries{
+				Labels: []prompb.Label{
+					{Name: "__name__", Value: "netatmo_room_error"},
+					{Name: "home_id", Value: reading.HomeID},
+					{Name: "room_id", Value: reading.RoomID},
+					{Name: "room_name", Value: reading.RoomName},
+					{Name: "code", Value: strconv.Itoa(reading.ErrorCode)},
+				},
+				Samples: []prompb.Sample{{Value: 1, Timestamp: roundToTenSeconds(reading.Timestamp).UnixMilli()}},
+			})
+		}
+		if reading.NoValues {
+			continue
+		}
 		key := roomKey{
 			homeID:   reading.HomeID,
 			homeName: reading.HomeName,
 			roomID:   reading.RoomID,
 			roomName: reading.RoomName,
+			stale:    reading.Stale,
 		}
 		roomReadings[key] = append(roomReadings[key], reading)
 	}
 
 	// Build time series for each room and metric
-	var timeSeries []prompb.TimeSeries
 	for key, roomData := range roomReadings {
 		// Create base labels for this room
 		baseLabels := []prompb.Label{
@@ -371,6 +1234,12 @@ func (p *Pusher) buildNetatmoTimeSeries(readings []*buffer.ThermostatReading) ([
 				Value: key.roomName,
 			},
 		}
+		if key.stale {
+			baseLabels = append(baseLabels, prompb.Label{
+				Name:  "stale",
+				Value: "true",
+			})
+		}
 
 		// Prepare samples
 		measuredTempSamples := make([]prompb.Sample, 0, len(roomData))
@@ -379,13 +1248,7 @@ func (p *Pusher) buildNetatmoTimeSeries(readings []*buffer.ThermostatReading) ([
 
 		for _, reading := range roomData {
 			// Round timestamp to nearest 10 seconds, then convert to milliseconds
-			ts, ok := reading.Timestamp.(time.Time)
-			if !ok {
-				p.logger.Warn("invalid timestamp type in netatmo reading",
-					zap.String("room_name", key.roomName),
-				)
-				continue
-			}
+			ts := reading.Timestamp
 			roundedTime := roundToTenSeconds(ts)
 			timestampMs := roundedTime.UnixMilli()
 
@@ -401,11 +1264,13 @@ func (p *Pusher) buildNetatmoTimeSeries(readings []*buffer.ThermostatReading) ([
 				Timestamp: timestampMs,
 			})
 
-			// Add heating power request sample
-			heatingPowerSamples = append(heatingPowerSamples, prompb.Sample{
-				Value:     float64(reading.HeatingPowerRequest),
-				Timestamp: timestampMs,
-			})
+			// Add heating power request sample; the measure history has none
+			if !reading.Historical {
+				heatingPowerSamples = append(heatingPowerSamples, prompb.Sample{
+					Value:     float64(reading.HeatingPowerRequest),
+					Timestamp: timestampMs,
+				})
+			}
 		}
 
 		// Add measured temperature time series
@@ -432,43 +1297,101 @@ func (p *Pusher) buildNetatmoTimeSeries(readings []*buffer.ThermostatReading) ([
 			Samples: setpointTempSamples,
 		})
 
-		// Add heating power request time series
-		heatingPowerLabels := append([]prompb.Label{
-			{
-				Name:  "__name__",
-				Value: "netatmo_heating_power_request",
-			},
-		}, baseLabels...)
-		timeSeries = append(timeSeries, prompb.TimeSeries{
-			Labels:  heatingPowerLabels,
-			Samples: heatingPowerSamples,
-		})
+		// Add heating power request time series, unless all readings were backfilled
+		if len(heatingPowerSamples) > 0 {
+			heatingPowerLabels := append([]prompb.Label{
+				{
+					Name:  "__name__",
+					Value: "netatmo_heating_power_request",
+				},
+			}, baseLabels...)
+			timeSeries = append(timeSeries, prompb.TimeSeries{
+				Labels:  heatingPowerLabels,
+				Samples: heatingPowerSamples,
+			})
+		}
 	}
 
 	return timeSeries, nil
 }
 
-// pushOnce attempts to push the write request once
-func (p *Pusher) pushOnce(ctx context.Context, writeReq *prompb.WriteRequest) error {
-	// Marshal to protobuf
-	data, err := proto.Marshal(writeReq)
-	if err != nil {
-		return fmt.Errorf("failed to marshal protobuf: %w", err)
+// pushOnce attempts to push the write request once for the given tenant, recording the attempt in the push log
+func (p *Pusher) pushOnce(ctx context.Context, writeReq *prompb.WriteRequest, tenant string) error {
+	start := p.clock.Now()
+	p.requestID = httpclient.NewRequestID()
+	size, err := p.send(httpclient.WithRequestID(ctx, p.requestID), writeReq, tenant)
+	if p.pushLog != nil {
+		attempt := PushAttempt{
+			Timestamp:  start,
+			Endpoint:   p.name,
+			Tenant:     tenant,
+			BatchID:    p.batch.id,
+			RequestID:  p.requestID,
+			Series:     len(writeReq.Timeseries),
+			Bytes:      size,
+			DurationMs: p.clock.Now().Sub(start).Milliseconds(),
+			Status:     http.StatusOK,
+		}
+		for _, ts := range writeReq.Timeseries {
+			attempt.Samples += len(ts.Samples)
+		}
+		if err != nil {
+			attempt.Error = err.Error()
+			attempt.Status = 0
+			var statusErr *statusError
+			if errors.As(err, &statusErr) {
+				attempt.Status = statusErr.StatusCode
+			}
+		}
+		p.pushLog.Record(attempt)
 	}
+	return err
+}
 
-	// Compress with snappy
-	compressed := snappy.Encode(nil, data)
+// send encodes and sends the write request, returning the encoded body size
+func (p *Pusher) send(ctx context.Context, writeReq *prompb.WriteRequest, tenant string) (int, error) {
+	var req *http.Request
+	var size int
+	if p.protocol == ProtocolVMImport {
+		data, err := encodeVMImport(writeReq)
+		if err != nil {
+			return 0, err
+		}
+		size = len(data)
 
-	// Create request
-	req, err := http.NewRequestWithContext(ctx, "POST", p.url, bytes.NewReader(compressed))
-	if err != nil {
-		return fmt.Errorf("failed to create request: %w", err)
-	}
+		req, err = http.NewRequestWithContext(ctx, "POST", p.url, bytes.NewReader(data))
+		if err != nil {
+			return size, fmt.Errorf("failed to create request: %w", err)
+		}
+		req.Header.Set("Content-Type", "application/json")
+	} else {
+		// Marshal to protobuf
+		data, err := proto.Marshal(writeReq)
+		if err != nil {
+			return 0, fmt.Errorf("failed to marshal protobuf: %w", err)
+		}
+
+		// Compress with snappy
+		compressed := snappy.Encode(nil, data)
+		size = len(compressed)
+
+		// Create request
+		req, err = http.NewRequestWithContext(ctx, "POST", p.url, bytes.NewReader(compressed))
+		if err != nil {
+			return size, fmt.Errorf("failed to create request: %w", err)
+		}
 
-	// Set headers
-	req.Header.Set("Content-Type", "application/x-protobuf")
-	req.Header.Set("Content-Encoding", "snappy")
-	req.Header.Set("X-Prometheus-Remote-Write-Version", "0.1.0")
+		// Set headers
+		req.Header.Set("Content-Type", "application/x-protobuf")
+		req.Header.Set("Content-Encoding", "snappy")
+		req.Header.Set("X-Prometheus-Remote-Write-Version", "0.1.0")
+	}
+	if tenant != "" {
+		req.Header.Set("X-Scope-OrgID", tenant)
+	}
+	if p.provenance != nil {
+		p.provenance.setHeaders(req.Header, p.batch)
+	}
 
 	// Set basic auth
 	if p.username != "" && p.password != "" {
@@ -478,17 +1401,17 @@ func (p *Pusher) pushOnce(ctx context.Context, writeReq *prompb.WriteRequest) er
 	// Send request
 	resp, err := p.client.Do(req)
 	if err != nil {
-		return fmt.Errorf("failed to send request: %w", err)
+		return size, fmt.Errorf("failed to send request: %w", err)
 	}
 	defer resp.Body.Close()
 
 	// Check response
 	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
 		body, _ := io.ReadAll(resp.Body)
-		return fmt.Errorf("received non-2xx status code: %d, body: %s", resp.StatusCode, string(body))
+		return size, &statusError{StatusCode: resp.StatusCode, Body: string(body)}
 	}
 
-	return nil
+	return size, nil
 }
 
 // LastPushTime returns the time of the last successful push
@@ -498,15 +1421,22 @@ func (p *Pusher) LastPushTime() time.Time {
 
 // buildPowerTimeSeries builds time series for power meter readings
 func (p *Pusher) buildPowerTimeSeries(readings []*buffer.PowerReading) ([]prompb.TimeSeries, error) {
-	// Group readings by sensor
-	sensorReadings := make(map[int][]*buffer.PowerReading)
+	// Group readings by sensor, keeping stale (last known value) readings in their own series
+	type powerKey struct {
+		sensorID int
+		stale    bool
+	}
+	sensorReadings := make(map[powerKey][]*buffer.PowerReading)
 	for _, reading := range readings {
-		sensorReadings[reading.SensorID] = append(sensorReadings[reading.SensorID], reading)
+		key := powerKey{sensorID: reading.SensorID, stale: reading.Stale}
+		sensorReadings[key] = append(sensorReadings[key], reading)
 	}
 
 	// Build time series for each sensor
 	var timeSeries []prompb.TimeSeries
-	for sensorID, sensorData := range sensorReadings {
+	for key, sensorData := range sensorReadings {
+		sensorID := key.sensorID
+
 		// Create base labels for this sensor
 		baseLabels := []prompb.Label{
 			{
@@ -518,18 +1448,18 @@ func (p *Pusher) buildPowerTimeSeries(readings []*buffer.PowerReading) ([]prompb
 				Value: fmt.Sprintf("%d", sensorID),
 			},
 		}
+		if key.stale {
+			baseLabels = append(baseLabels, prompb.Label{
+				Name:  "stale",
+				Value: "true",
+			})
+		}
 
 		// Prepare samples
 		samples := make([]prompb.Sample, 0, len(sensorData))
 
 		for _, reading := range sensorData {
-			ts, ok := reading.Timestamp.(time.Time)
-			if !ok {
-				p.logger.Warn("invalid timestamp type in power reading",
-					zap.Int("sensor_id", sensorID),
-				)
-				continue
-			}
+			ts := reading.Timestamp
 			timestampMs := ts.UnixMilli()
 
 			// Add power sample
@@ -549,19 +1479,1140 @@ func (p *Pusher) buildPowerTimeSeries(readings []*buffer.PowerReading) ([]prompb
 	return timeSeries, nil
 }
 
-// roundToTenSeconds rounds a time to the nearest 10-second interval
-func roundToTenSeconds(t time.Time) time.Time {
-	// Truncate to 10-second boundary
-	truncated := t.Truncate(10 * time.Second)
+// buildHeatPumpTimeSeries builds time series for heat pump readings
+func (p *Pusher) buildHeatPumpTimeSeries(readings []*buffer.HeatPumpReading) ([]prompb.TimeSeries, error) {
+	// Group readings by metric name
+	metricReadings := make(map[string][]*buffer.HeatPumpReading)
+	for _, reading := range readings {
+		metricReadings[reading.Name] = append(metricReadings[reading.Name], reading)
+	}
 
-	// Calculate how far we are into the current 10-second interval
-	remainder := t.Sub(truncated)
+	// Build time series for each metric
+	var timeSeries []prompb.TimeSeries
+	for name, metricData := range metricReadings {
+		labels := []prompb.Label{
+			{
+				Name:  "__name__",
+				Value: "heatpump_" + name,
+			},
+		}
 
-	// If we're at 5 seconds or more, round up to next 10-second mark
-	if remainder >= 5*time.Second {
-		return truncated.Add(10 * time.Second)
+		// Prepare samples
+		samples := make([]prompb.Sample, 0, len(metricData))
+
+		for _, reading := range metricData {
+			ts := reading.Timestamp
+
+			samples = append(samples, prompb.Sample{
+				Value:     reading.Value,
+				Timestamp: ts.UnixMilli(),
+			})
+		}
+
+		timeSeries = append(timeSeries, prompb.TimeSeries{
+			Labels:  labels,
+			Samples: samples,
+		})
 	}
 
-	// Otherwise, round down
-	return truncated
+	return timeSeries, nil
+}
+
+// buildWaterTimeSeries builds the consumption counter time series for water meter readings
+func (p *Pusher) buildWaterTimeSeries(readings []*buffer.WaterReading) ([]prompb.TimeSeries, error) {
+	if len(readings) == 0 {
+		return nil, nil
+	}
+
+	samples := make([]prompb.Sample, 0, len(readings))
+	for _, reading := range readings {
+		ts := reading.Timestamp
+
+		samples = append(samples, prompb.Sample{
+			Value:     reading.TotalLiters,
+			Timestamp: ts.UnixMilli(),
+		})
+	}
+
+	return []prompb.TimeSeries{
+		{
+			Labels: []prompb.Label{
+				{
+					Name:  "__name__",
+					Value: "water_consumption_liters_total",
+				},
+			},
+			Samples: samples,
+		},
+	}, nil
+}
+
+// buildOneWireTimeSeries builds time series for 1-Wire temperature sensor readings
+func (p *Pusher) buildOneWireTimeSeries(readings []*buffer.OneWireReading) ([]prompb.TimeSeries, error) {
+	// Group readings by sensor
+	type sensorKey struct {
+		name     string
+		id       int
+		deviceID string
+	}
+	sensorReadings := make(map[sensorKey][]*buffer.OneWireReading)
+	for _, reading := range readings {
+		key := sensorKey{name: reading.SensorName, id: reading.SensorID, deviceID: reading.DeviceID}
+		sensorReadings[key] = append(sensorReadings[key], reading)
+	}
+
+	// Build time series for each sensor
+	var timeSeries []prompb.TimeSeries
+	for key, sensorData := range sensorReadings {
+		labels := []prompb.Label{
+			{
+				Name:  "__name__",
+				Value: "onewire_temperature_celsius",
+			},
+			{
+				Name:  "sensor_name",
+				Value: key.name,
+			},
+			{
+				Name:  "sensor_id",
+				Value: fmt.Sprintf("%d", key.id),
+			},
+			{
+				Name:  "device_id",
+				Value: key.deviceID,
+			},
+		}
+
+		samples := make([]prompb.Sample, 0, len(sensorData))
+		for _, reading := range sensorData {
+			// Round timestamp to nearest 10 seconds, then convert to milliseconds
+			ts := reading.Timestamp
+
+			samples = append(samples, prompb.Sample{
+				Value:     reading.TemperatureCelsius,
+				Timestamp: roundToTenSeconds(ts).UnixMilli(),
+			})
+		}
+
+		timeSeries = append(timeSeries, prompb.TimeSeries{
+			Labels:  labels,
+			Samples: samples,
+		})
+	}
+
+	return timeSeries, nil
+}
+
+// buildI2CTimeSeries builds time series for I2C environmental sensor readings
+func (p *Pusher) buildI2CTimeSeries(readings []*buffer.I2CReading) ([]prompb.TimeSeries, error) {
+	// Group readings by sensor
+	type sensorKey struct {
+		name  string
+		id    int
+		model string
+	}
+	sensorReadings := make(map[sensorKey][]*buffer.I2CReading)
+	for _, reading := range readings {
+		key := sensorKey{name: reading.SensorName, id: reading.SensorID, model: reading.Model}
+		sensorReadings[key] = append(sensorReadings[key], reading)
+	}
+
+	// Build time series for each sensor and metric
+	var timeSeries []prompb.TimeSeries
+	for key, sensorData := range sensorReadings {
+		// Create base labels for this sensor
+		baseLabels := []prompb.Label{
+			{
+				Name:  "sensor_name",
+				Value: key.name,
+			},
+			{
+				Name:  "sensor_id",
+				Value: fmt.Sprintf("%d", key.id),
+			},
+			{
+				Name:  "model",
+				Value: key.model,
+			},
+		}
+
+		tempSamples := make([]prompb.Sample, 0, len(sensorData))
+		humiditySamples := make([]prompb.Sample, 0, len(sensorData))
+		var pressureSamples []prompb.Sample
+
+		for _, reading := range sensorData {
+			// Round timestamp to nearest 10 seconds, then convert to milliseconds
+			ts := reading.Timestamp
+			timestampMs := roundToTenSeconds(ts).UnixMilli()
+
+			tempSamples = append(tempSamples, prompb.Sample{
+				Value:     reading.TemperatureCelsius,
+				Timestamp: timestampMs,
+			})
+			humiditySamples = append(humiditySamples, prompb.Sample{
+				Value:     reading.HumidityPercent,
+				Timestamp: timestampMs,
+			})
+			if reading.HasPressure {
+				pressureSamples = append(pressureSamples, prompb.Sample{
+					Value:     reading.PressureHPa,
+					Timestamp: timestampMs,
+				})
+			}
+		}
+
+		series := []struct {
+			name    string
+			samples []prompb.Sample
+		}{
+			{name: "i2c_temperature_celsius", samples: tempSamples},
+			{name: "i2c_humidity_percent", samples: humiditySamples},
+			{name: "i2c_pressure_hpa", samples: pressureSamples},
+		}
+		for _, s := range series {
+			if len(s.samples) == 0 {
+				continue
+			}
+			labels := append([]prompb.Label{
+				{
+					Name:  "__name__",
+					Value: s.name,
+				},
+			}, baseLabels...)
+			timeSeries = append(timeSeries, prompb.TimeSeries{
+				Labels:  labels,
+				Samples: s.samples,
+			})
+		}
+	}
+
+	return timeSeries, nil
+}
+
+// buildAirQualityTimeSeries builds time series for CO2 sensor readings
+func (p *Pusher) buildAirQualityTimeSeries(readings []*buffer.AirQualityReading) ([]prompb.TimeSeries, error) {
+	// Group readings by sensor
+	type sensorKey struct {
+		name  string
+		id    int
+		model string
+	}
+	sensorReadings := make(map[sensorKey][]*buffer.AirQualityReading)
+	for _, reading := range readings {
+		key := sensorKey{name: reading.SensorName, id: reading.SensorID, model: reading.Model}
+		sensorReadings[key] = append(sensorReadings[key], reading)
+	}
+
+	// Build time series for each sensor and metric
+	var timeSeries []prompb.TimeSeries
+	for key, sensorData := range sensorReadings {
+		// Create base labels for this sensor
+		baseLabels := []prompb.Label{
+			{
+				Name:  "sensor_name",
+				Value: key.name,
+			},
+			{
+				Name:  "sensor_id",
+				Value: fmt.Sprintf("%d", key.id),
+			},
+			{
+				Name:  "model",
+				Value: key.model,
+			},
+		}
+
+		co2Samples := make([]prompb.Sample, 0, len(sensorData))
+		var tempSamples []prompb.Sample
+		var humiditySamples []prompb.Sample
+
+		for _, reading := range sensorData {
+			// Round timestamp to nearest 10 seconds, then convert to milliseconds
+			ts := reading.Timestamp
+			timestampMs := roundToTenSeconds(ts).UnixMilli()
+
+			co2Samples = append(co2Samples, prompb.Sample{
+				Value:     reading.CO2PPM,
+				Timestamp: timestampMs,
+			})
+			if reading.HasClimate {
+				tempSamples = append(tempSamples, prompb.Sample{
+					Value:     reading.TemperatureCelsius,
+					Timestamp: timestampMs,
+				})
+				humiditySamples = append(humiditySamples, prompb.Sample{
+					Value:     reading.HumidityPercent,
+					Timestamp: timestampMs,
+				})
+			}
+		}
+
+		series := []struct {
+			name    string
+			samples []prompb.Sample
+		}{
+			{name: "air_co2_ppm", samples: co2Samples},
+			{name: "air_temperature_celsius", samples: tempSamples},
+			{name: "air_humidity_percent", samples: humiditySamples},
+		}
+		for _, s := range series {
+			if len(s.samples) == 0 {
+				continue
+			}
+			labels := append([]prompb.Label{
+				{
+					Name:  "__name__",
+					Value: s.name,
+				},
+			}, baseLabels...)
+			timeSeries = append(timeSeries, prompb.TimeSeries{
+				Labels:  labels,
+				Samples: s.samples,
+			})
+		}
+	}
+
+	return timeSeries, nil
+}
+
+// buildZigbeeTimeSeries builds time series for Zigbee2MQTT device readings
+func (p *Pusher) buildZigbeeTimeSeries(readings []*buffer.ZigbeeReading) ([]prompb.TimeSeries, error) {
+	// Group readings by device and metric
+	type seriesKey struct {
+		device      string
+		ieeeAddress string
+		model       string
+		vendor      string
+		class       string
+		metric      string
+	}
+	seriesReadings := make(map[seriesKey][]*buffer.ZigbeeReading)
+	for _, reading := range readings {
+		key := seriesKey{
+			device:      reading.Device,
+			ieeeAddress: reading.IEEEAddress,
+			model:       reading.Model,
+			vendor:      reading.Vendor,
+			class:       reading.Class,
+			metric:      reading.Metric,
+		}
+		seriesReadings[key] = append(seriesReadings[key], reading)
+	}
+
+	// Build time series for each device metric
+	var timeSeries []prompb.TimeSeries
+	for key, seriesData := range seriesReadings {
+		labels := []prompb.Label{
+			{
+				Name:  "__name__",
+				Value: "zigbee_" + key.metric,
+			},
+			{
+				Name:  "device",
+				Value: key.device,
+			},
+			{
+				Name:  "ieee_address",
+				Value: key.ieeeAddress,
+			},
+			{
+				Name:  "model",
+				Value: key.model,
+			},
+			{
+				Name:  "vendor",
+				Value: key.vendor,
+			},
+			{
+				Name:  "class",
+				Value: key.class,
+			},
+		}
+
+		samples := make([]prompb.Sample, 0, len(seriesData))
+		for _, reading := range seriesData {
+			ts := reading.Timestamp
+
+			samples = append(samples, prompb.Sample{
+				Value:     reading.Value,
+				Timestamp: ts.UnixMilli(),
+			})
+		}
+
+		timeSeries = append(timeSeries, prompb.TimeSeries{
+			Labels:  labels,
+			Samples: samples,
+		})
+	}
+
+	return timeSeries, nil
+}
+
+// buildDependencyTimeSeries builds request counter and duration histogram time series for outbound dependencies
+func (p *Pusher) buildDependencyTimeSeries(readings []*buffer.DependencyReading) ([]prompb.TimeSeries, error) {
+	// Group samples by series, identified by metric name, dependency and bucket bound
+	type seriesKey struct {
+		name       string
+		dependency string
+		le         string
+	}
+	var keys []seriesKey
+	seriesSamples := make(map[seriesKey][]prompb.Sample)
+	addSample := func(key seriesKey, value float64, timestampMs int64) {
+		if _, ok := seriesSamples[key]; !ok {
+			keys = append(keys, key)
+		}
+		seriesSamples[key] = append(seriesSamples[key], prompb.Sample{
+			Value:     value,
+			Timestamp: timestampMs,
+		})
+	}
+
+	for _, reading := range readings {
+		ts := reading.Timestamp
+		timestampMs := ts.UnixMilli()
+		dependency := reading.Dependency
+
+		addSample(seriesKey{name: "dependency_requests_total", dependency: dependency}, float64(reading.Requests), timestampMs)
+		addSample(seriesKey{name: "dependency_request_errors_total", dependency: dependency}, float64(reading.Errors), timestampMs)
+		addSample(seriesKey{name: "dependency_sent_bytes_total", dependency: dependency}, float64(reading.SentBytes), timestampMs)
+		addSample(seriesKey{name: "dependency_received_bytes_total", dependency: dependency}, float64(reading.ReceivedBytes), timestampMs)
+		for _, bucket := range reading.DurationBuckets {
+			le := strconv.FormatFloat(bucket.UpperBound, 'f', -1, 64)
+			addSample(seriesKey{name: "dependency_request_duration_seconds_bucket", dependency: dependency, le: le}, float64(bucket.Count), timestampMs)
+		}
+		addSample(seriesKey{name: "dependency_request_duration_seconds_bucket", dependency: dependency, le: "+Inf"}, float64(reading.Requests), timestampMs)
+		addSample(seriesKey{name: "dependency_request_duration_seconds_sum", dependency: dependency}, reading.DurationSumSeconds, timestampMs)
+		addSample(seriesKey{name: "dependency_request_duration_seconds_count", dependency: dependency}, float64(reading.Requests), timestampMs)
+
+		// Connection statistics are only reported for traced dependencies
+		if reading.ConnectionsOpened+reading.ConnectionsReused == 0 {
+			continue
+		}
+		addSample(seriesKey{name: "dependency_connections_opened_total", dependency: dependency}, float64(reading.ConnectionsOpened), timestampMs)
+		addSample(seriesKey{name: "dependency_connections_reused_total", dependency: dependency}, float64(reading.ConnectionsReused), timestampMs)
+		addSample(seriesKey{name: "dependency_connect_duration_seconds_sum", dependency: dependency}, reading.ConnectSecondsSum, timestampMs)
+		addSample(seriesKey{name: "dependency_connect_duration_seconds_count", dependency: dependency}, float64(reading.ConnectionsOpened), timestampMs)
+		addSample(seriesKey{name: "dependency_tls_handshake_duration_seconds_sum", dependency: dependency}, reading.TLSHandshakeSecondsSum, timestampMs)
+		addSample(seriesKey{name: "dependency_tls_handshake_duration_seconds_count", dependency: dependency}, float64(reading.TLSHandshakes), timestampMs)
+		addSample(seriesKey{name: "dependency_time_to_first_byte_seconds_sum", dependency: dependency}, reading.FirstByteSecondsSum, timestampMs)
+		addSample(seriesKey{name: "dependency_time_to_first_byte_seconds_count", dependency: dependency}, float64(reading.FirstBytes), timestampMs)
+	}
+
+	timeSeries := make([]prompb.TimeSeries, 0, len(keys))
+	for _, key := range keys {
+		labels := []prompb.Label{
+			{
+				Name:  "__name__",
+				Value: key.name,
+			},
+			{
+				Name:  "dependency",
+				Value: key.dependency,
+			},
+		}
+		if key.le != "" {
+			labels = append(labels, prompb.Label{
+				Name:  "le",
+				Value: key.le,
+			})
+		}
+		timeSeries = append(timeSeries, prompb.TimeSeries{
+			Labels:  labels,
+			Samples: seriesSamples[key],
+		})
+	}
+
+	return timeSeries, nil
+}
+
+// buildHTTPTimeSeries builds request counter and duration histogram time series for embedded HTTP server routes
+func (p *Pusher) buildHTTPTimeSeries(readings []*buffer.HTTPReading) ([]prompb.TimeSeries, error) {
+	// Group samples by series, identified by metric name, route, status code and bucket bound
+	type seriesKey struct {
+		name  string
+		route string
+		code  string
+		le    string
+	}
+	var keys []seriesKey
+	seriesSamples := make(map[seriesKey][]prompb.Sample)
+	addSample := func(key seriesKey, value float64, timestampMs int64) {
+		if _, ok := seriesSamples[key]; !ok {
+			keys = append(keys, key)
+		}
+		seriesSamples[key] = append(seriesSamples[key], prompb.Sample{
+			Value:     value,
+			Timestamp: timestampMs,
+		})
+	}
+
+	for _, reading := range readings {
+		ts := reading.Timestamp
+		timestampMs := ts.UnixMilli()
+		route := reading.Route
+		code := strconv.Itoa(reading.Code)
+
+		addSample(seriesKey{name: "http_server_requests_total", route: route, code: code}, float64(reading.Requests), timestampMs)
+		for _, bucket := range reading.DurationBuckets {
+			le := strconv.FormatFloat(bucket.UpperBound, 'f', -1, 64)
+			addSample(seriesKey{name: "http_server_request_duration_seconds_bucket", route: route, code: code, le: le}, float64(bucket.Count), timestampMs)
+		}
+		addSample(seriesKey{name: "http_server_request_duration_seconds_bucket", route: route, code: code, le: "+Inf"}, float64(reading.Requests), timestampMs)
+		addSample(seriesKey{name: "http_server_request_duration_seconds_sum", route: route, code: code}, reading.DurationSumSeconds, timestampMs)
+		addSample(seriesKey{name: "http_server_request_duration_seconds_count", route: route, code: code}, float64(reading.Requests), timestampMs)
+	}
+
+	timeSeries := make([]prompb.TimeSeries, 0, len(keys))
+	for _, key := range keys {
+		labels := []prompb.Label{
+			{
+				Name:  "__name__",
+				Value: key.name,
+			},
+			{
+				Name:  "route",
+				Value: key.route,
+			},
+			{
+				Name:  "code",
+				Value: key.code,
+			},
+		}
+		if key.le != "" {
+			labels = append(labels, prompb.Label{
+				Name:  "le",
+				Value: key.le,
+			})
+		}
+		timeSeries = append(timeSeries, prompb.TimeSeries{
+			Labels:  labels,
+			Samples: seriesSamples[key],
+		})
+	}
+
+	return timeSeries, nil
+}
+
+// buildConflictTimeSeries builds sensor_identity_conflicts gauges for sensor labels claimed by several identities
+func (p *Pusher) buildConflictTimeSeries(readings []*buffer.ConflictReading) ([]prompb.TimeSeries, error) {
+	type seriesKey struct {
+		kind string
+		key  string
+	}
+	var keys []seriesKey
+	seriesSamples := make(map[seriesKey][]prompb.Sample)
+	for _, reading := range readings {
+		ts := reading.Timestamp
+		key := seriesKey{kind: reading.Kind, key: reading.Key}
+		if _, ok := seriesSamples[key]; !ok {
+			keys = append(keys, key)
+		}
+		seriesSamples[key] = append(seriesSamples[key], prompb.Sample{
+			Value:     float64(reading.Identities),
+			Timestamp: ts.UnixMilli(),
+		})
+	}
+
+	timeSeries := make([]prompb.TimeSeries, 0, len(keys))
+	for _, key := range keys {
+		timeSeries = append(timeSeries, prompb.TimeSeries{
+			Labels: []prompb.Label{
+				{
+					Name:  "__name__",
+					Value: "sensor_identity_conflicts",
+				},
+				{
+					Name:  "kind",
+					Value: key.kind,
+				},
+				{
+					Name:  "key",
+					Value: key.key,
+				},
+			},
+			Samples: seriesSamples[key],
+		})
+	}
+
+	return timeSeries, nil
+}
+
+// buildBatteryTimeSeries builds ble_battery_days_remaining gauges, labeled like the BLE sensor series
+func (p *Pusher) buildBatteryTimeSeries(readings []*buffer.BatteryReading) ([]prompb.TimeSeries, error) {
+	type seriesKey struct {
+		mac  string
+		name string
+		id   int
+	}
+	var keys []seriesKey
+	seriesSamples := make(map[seriesKey][]prompb.Sample)
+	for _, reading := range readings {
+		key := seriesKey{mac: reading.MAC, name: reading.SensorName, id: reading.SensorID}
+		if _, ok := seriesSamples[key]; !ok {
+			keys = append(keys, key)
+		}
+		seriesSamples[key] = append(seriesSamples[key], prompb.Sample{
+			Value:     reading.DaysRemaining,
+			Timestamp: reading.Timestamp.UnixMilli(),
+		})
+	}
+
+	timeSeries := make([]prompb.TimeSeries, 0, len(keys))
+	for _, key := range keys {
+		timeSeries = append(timeSeries, prompb.TimeSeries{
+			Labels: []prompb.Label{
+				{
+					Name:  "__name__",
+					Value: "ble_battery_days_remaining",
+				},
+				{
+					Name:  "sensor_name",
+					Value: key.name,
+				},
+				{
+					Name:  "sensor_id",
+					Value: fmt.Sprintf("%d", key.id),
+				},
+				{
+					Name:  "mac",
+					Value: key.mac,
+				},
+			},
+			Samples: seriesSamples[key],
+		})
+	}
+
+	return timeSeries, nil
+}
+
+// buildLocationTimeSeries builds ble_nearest_receiver series, set to 1 for the receiver currently
+// nearest to each sensor or beacon, with a room label when the receiver has one
+func (p *Pusher) buildLocationTimeSeries(readings []*buffer.LocationReading) ([]prompb.TimeSeries, error) {
+	type seriesKey struct {
+		mac      string
+		name     string
+		receiver string
+		room     string
+	}
+	var keys []seriesKey
+	seriesSamples := make(map[seriesKey][]prompb.Sample)
+	for _, reading := range readings {
+		key := seriesKey{mac: reading.MAC, name: reading.Name, receiver: reading.Receiver, room: reading.Room}
+		if _, ok := seriesSamples[key]; !ok {
+			keys = append(keys, key)
+		}
+		seriesSamples[key] = append(seriesSamples[key], prompb.Sample{
+			Value:     1,
+			Timestamp: reading.Timestamp.UnixMilli(),
+		})
+	}
+
+	timeSeries := make([]prompb.TimeSeries, 0, len(keys))
+	for _, key := range keys {
+		labels := []prompb.Label{
+			{
+				Name:  "__name__",
+				Value: "ble_nearest_receiver",
+			},
+			{
+				Name:  "name",
+				Value: key.name,
+			},
+			{
+				Name:  "mac",
+				Value: key.mac,
+			},
+			{
+				Name:  "receiver",
+				Value: key.receiver,
+			},
+		}
+		if key.room != "" {
+			labels = append(labels, prompb.Label{Name: "room", Value: key.room})
+		}
+		timeSeries = append(timeSeries, prompb.TimeSeries{
+			Labels:  labels,
+			Samples: seriesSamples[key],
+		})
+	}
+
+	return timeSeries, nil
+}
+
+// buildReconciliationTimeSeries builds the official and locally integrated energy of the compared
+// hours and the drift between them, per official data source
+func (p *Pusher) buildReconciliationTimeSeries(readings []*buffer.ReconciliationReading) ([]prompb.TimeSeries, error) {
+	var sources []string
+	seriesSamples := make(map[string][3][]prompb.Sample)
+	for _, reading := range readings {
+		samples, ok := seriesSamples[reading.Source]
+		if !ok {
+			sources = append(sources, reading.Source)
+		}
+		ts := reading.Timestamp.UnixMilli()
+		samples[0] = append(samples[0], prompb.Sample{Value: reading.OfficialKWh, Timestamp: ts})
+		samples[1] = append(samples[1], prompb.Sample{Value: reading.LocalKWh, Timestamp: ts})
+		samples[2] = append(samples[2], prompb.Sample{Value: reading.DriftPercent, Timestamp: ts})
+		seriesSamples[reading.Source] = samples
+	}
+
+	names := [3]string{
+		"energy_reconciliation_official_kwh",
+		"energy_reconciliation_local_kwh",
+		"energy_reconciliation_drift_percent",
+	}
+	timeSeries := make([]prompb.TimeSeries, 0, len(sources)*len(names))
+	for _, source := range sources {
+		for i, name := range names {
+			timeSeries = append(timeSeries, prompb.TimeSeries{
+				Labels: []prompb.Label{
+					{
+						Name:  "__name__",
+						Value: name,
+					},
+					{
+						Name:  "source",
+						Value: source,
+					},
+				},
+				Samples: seriesSamples[source][i],
+			})
+		}
+	}
+
+	return timeSeries, nil
+}
+
+// buildPriceTimeSeries builds electricity price series, one per source and hour ahead of the current
+// one, sampled when reported since remote_write rejects samples from the future
+func (p *Pusher) buildPriceTimeSeries(readings []*buffer.PriceReading) ([]prompb.TimeSeries, error) {
+	type seriesKey struct {
+		source string
+		ahead  int
+	}
+	var keys []seriesKey
+	seriesSamples := make(map[seriesKey][]prompb.Sample)
+	for _, reading := range readings {
+		key := seriesKey{source: reading.Source, ahead: reading.HoursAhead}
+		if _, ok := seriesSamples[key]; !ok {
+			keys = append(keys, key)
+		}
+		seriesSamples[key] = append(seriesSamples[key], prompb.Sample{
+			Value:     reading.PLNPerKWh,
+			Timestamp: reading.Timestamp.UnixMilli(),
+		})
+	}
+
+	timeSeries := make([]prompb.TimeSeries, 0, len(keys))
+	for _, key := range keys {
+		timeSeries = append(timeSeries, prompb.TimeSeries{
+			Labels: []prompb.Label{
+				{
+					Name:  "__name__",
+					Value: "electricity_price_pln_per_kwh",
+				},
+				{
+					Name:  "source",
+					Value: key.source,
+				},
+				{
+					Name:  "hours_ahead",
+					Value: fmt.Sprintf("%d", key.ahead),
+				},
+			},
+			Samples: seriesSamples[key],
+		})
+	}
+
+	return timeSeries, nil
+}
+
+// buildOccupancyTimeSeries builds occupancy_state time series, 1 while someone is home and 0 while away
+func (p *Pusher) buildOccupancyTimeSeries(readings []*buffer.OccupancyReading) ([]prompb.TimeSeries, error) {
+	var sources []string
+	sourceSamples := make(map[string][]prompb.Sample)
+	for _, reading := range readings {
+		if _, ok := sourceSamples[reading.Source]; !ok {
+			sources = append(sources, reading.Source)
+		}
+		value := 0.0
+		if reading.Home {
+			value = 1
+		}
+		sourceSamples[reading.Source] = append(sourceSamples[reading.Source], prompb.Sample{
+			Value:     value,
+			Timestamp: reading.Timestamp.UnixMilli(),
+		})
+	}
+
+	timeSeries := make([]prompb.TimeSeries, 0, len(sources))
+	for _, source := range sources {
+		timeSeries = append(timeSeries, prompb.TimeSeries{
+			Labels: []prompb.Label{
+				{
+					Name:  "__name__",
+					Value: "occupancy_state",
+				},
+				{
+					Name:  "source",
+					Value: source,
+				},
+			},
+			Samples: sourceSamples[source],
+		})
+	}
+
+	return timeSeries, nil
+}
+
+// buildVentilationTimeSeries builds the ventilation_recommended time series, 1 while ventilating would
+// lower the indoor humidity
+func (p *Pusher) buildVentilationTimeSeries(readings []*buffer.VentilationReading) prompb.TimeSeries {
+	samples := make([]prompb.Sample, 0, len(readings))
+	for _, reading := range readings {
+		value := 0.0
+		if reading.Recommended {
+			value = 1
+		}
+		samples = append(samples, prompb.Sample{
+			Value:     value,
+			Timestamp: reading.Timestamp.UnixMilli(),
+		})
+	}
+
+	return prompb.TimeSeries{
+		Labels: []prompb.Label{
+			{
+				Name:  "__name__",
+				Value: "ventilation_recommended",
+			},
+		},
+		Samples: samples,
+	}
+}
+
+// buildAutomationTimeSeries builds rule state time series for automation readings
+func (p *Pusher) buildAutomationTimeSeries(readings []*buffer.AutomationReading) ([]prompb.TimeSeries, error) {
+	// Group readings by rule
+	ruleReadings := make(map[string][]*buffer.AutomationReading)
+	for _, reading := range readings {
+		ruleReadings[reading.Rule] = append(ruleReadings[reading.Rule], reading)
+	}
+
+	// Build time series for each rule
+	var timeSeries []prompb.TimeSeries
+	for rule, ruleData := range ruleReadings {
+		labels := []prompb.Label{
+			{
+				Name:  "__name__",
+				Value: "automation_rule_active",
+			},
+			{
+				Name:  "rule",
+				Value: rule,
+			},
+		}
+
+		samples := make([]prompb.Sample, 0, len(ruleData))
+		for _, reading := range ruleData {
+			ts := reading.Timestamp
+
+			value := 0.0
+			if reading.Active {
+				value = 1
+			}
+			samples = append(samples, prompb.Sample{
+				Value:     value,
+				Timestamp: ts.UnixMilli(),
+			})
+		}
+
+		timeSeries = append(timeSeries, prompb.TimeSeries{
+			Labels:  labels,
+			Samples: samples,
+		})
+	}
+
+	return timeSeries, nil
+}
+
+// buildDroppedTimeSeries builds the remote_write_samples_dropped_total counters once samples were dropped
+func (p *Pusher) buildDroppedTimeSeries() []prompb.TimeSeries {
+	var timeSeries []prompb.TimeSeries
+	now := p.clock.Now().UnixMilli()
+	for _, reason := range []string{dropReasonTooOld, dropReasonRejected, dropReasonInvalid, dropReasonDuplicate, dropReasonCardinality, dropReasonCoalesced} {
+		count, ok := p.dropped[reason]
+		if !ok {
+			continue
+		}
+		labels := []prompb.Label{
+			{
+				Name:  "__name__",
+				Value: "remote_write_samples_dropped_total",
+			},
+			{
+				Name:  "reason",
+				Value: reason,
+			},
+		}
+		if p.name != "" {
+			labels = append(labels, prompb.Label{Name: "endpoint", Value: p.name})
+		}
+		timeSeries = append(timeSeries, prompb.TimeSeries{
+			Labels:  labels,
+			Samples: []prompb.Sample{{Value: float64(count), Timestamp: now}},
+		})
+	}
+	return timeSeries
+}
+
+// buildOverwrittenTimeSeries builds the buffer_overwritten_total counter once the buffer overwrote readings
+func (p *Pusher) buildOverwrittenTimeSeries() []prompb.TimeSeries {
+	count := p.buffer.Overwritten()
+	if count == 0 {
+		return nil
+	}
+	labels := []prompb.Label{
+		{
+			Name:  "__name__",
+			Value: "buffer_overwritten_total",
+		},
+	}
+	if p.name != "" {
+		labels = append(labels, prompb.Label{Name: "endpoint", Value: p.name})
+	}
+	return []prompb.TimeSeries{{
+		Labels:  labels,
+		Samples: []prompb.Sample{{Value: float64(count), Timestamp: p.clock.Now().UnixMilli()}},
+	}}
+}
+
+// buildDerivedTimeSeries builds time series for metrics computed by expression rules
+func (p *Pusher) buildDerivedTimeSeries(readings []*buffer.DerivedReading) ([]prompb.TimeSeries, error) {
+	// Group readings by metric and rule
+	type seriesKey struct {
+		name string
+		rule string
+	}
+	seriesReadings := make(map[seriesKey][]*buffer.DerivedReading)
+	for _, reading := range readings {
+		key := seriesKey{name: reading.Name, rule: reading.Rule}
+		seriesReadings[key] = append(seriesReadings[key], reading)
+	}
+
+	// Build time series for each derived metric
+	var timeSeries []prompb.TimeSeries
+	for key, seriesData := range seriesReadings {
+		labels := []prompb.Label{
+			{
+				Name:  "__name__",
+				Value: key.name,
+			},
+			{
+				Name:  "rule",
+				Value: key.rule,
+			},
+		}
+
+		samples := make([]prompb.Sample, 0, len(seriesData))
+		for _, reading := range seriesData {
+			ts := reading.Timestamp
+
+			samples = append(samples, prompb.Sample{
+				Value:     reading.Value,
+				Timestamp: ts.UnixMilli(),
+			})
+		}
+
+		timeSeries = append(timeSeries, prompb.TimeSeries{
+			Labels:  labels,
+			Samples: samples,
+		})
+	}
+
+	return timeSeries, nil
+}
+
+// buildRoomTimeSeries builds time series for fused room temperatures
+// Each source is its own series so a fallback shows up as a label change
+func (p *Pusher) buildRoomTimeSeries(readings []*buffer.RoomReading) ([]prompb.TimeSeries, error) {
+	// Group readings by room and source
+	type seriesKey struct {
+		room   string
+		source string
+	}
+	seriesReadings := make(map[seriesKey][]*buffer.RoomReading)
+	for _, reading := range readings {
+		key := seriesKey{room: reading.Room, source: reading.Source}
+		seriesReadings[key] = append(seriesReadings[key], reading)
+	}
+
+	// Build time series for each room and source
+	var timeSeries []prompb.TimeSeries
+	for key, seriesData := range seriesReadings {
+		labels := []prompb.Label{
+			{
+				Name:  "__name__",
+				Value: "room_temperature_celsius",
+			},
+			{
+				Name:  "room",
+				Value: key.room,
+			},
+			{
+				Name:  "source",
+				Value: key.source,
+			},
+		}
+
+		samples := make([]prompb.Sample, 0, len(seriesData))
+		for _, reading := range seriesData {
+			ts := reading.Timestamp
+
+			samples = append(samples, prompb.Sample{
+				Value:     reading.TemperatureCelsius,
+				Timestamp: ts.UnixMilli(),
+			})
+		}
+
+		timeSeries = append(timeSeries, prompb.TimeSeries{
+			Labels:  labels,
+			Samples: samples,
+		})
+	}
+
+	return timeSeries, nil
+}
+
+// buildSummaryTimeSeries builds hourly min, max and avg time series for BLE sensor summaries
+func (p *Pusher) buildSummaryTimeSeries(readings []*buffer.SummaryReading) ([]prompb.TimeSeries, error) {
+	// Group readings by metric and sensor
+	type seriesKey struct {
+		metric string
+		name   string
+		id     int
+	}
+	seriesReadings := make(map[seriesKey][]*buffer.SummaryReading)
+	for _, reading := range readings {
+		key := seriesKey{metric: reading.Metric, name: reading.SensorName, id: reading.SensorID}
+		seriesReadings[key] = append(seriesReadings[key], reading)
+	}
+
+	// Build min, max and avg time series for each metric and sensor
+	var timeSeries []prompb.TimeSeries
+	for key, seriesData := range seriesReadings {
+		aggregates := []struct {
+			suffix string
+			value  func(*buffer.SummaryReading) float64
+		}{
+			{"_hourly_min", func(r *buffer.SummaryReading) float64 { return r.Min }},
+			{"_hourly_max", func(r *buffer.SummaryReading) float64 { return r.Max }},
+			{"_hourly_avg", func(r *buffer.SummaryReading) float64 { return r.Avg }},
+		}
+
+		for _, aggregate := range aggregates {
+			labels := []prompb.Label{
+				{
+					Name:  "__name__",
+					Value: key.metric + aggregate.suffix,
+				},
+				{
+					Name:  "sensor_name",
+					Value: key.name,
+				},
+				{
+					Name:  "sensor_id",
+					Value: fmt.Sprintf("%d", key.id),
+				},
+				{
+					Name:  "mac",
+					Value: seriesData[0].MAC, // All readings have same MAC
+				},
+			}
+
+			samples := make([]prompb.Sample, 0, len(seriesData))
+			for _, reading := range seriesData {
+				ts := reading.Timestamp
+
+				samples = append(samples, prompb.Sample{
+					Value:     aggregate.value(reading),
+					Timestamp: ts.UnixMilli(),
+				})
+			}
+
+			timeSeries = append(timeSeries, prompb.TimeSeries{
+				Labels:  labels,
+				Samples: samples,
+			})
+		}
+	}
+
+	return timeSeries, nil
+}
+
+// roundToTenSeconds rounds a time to the nearest 10-second interval
+func roundToTenSeconds(t time.Time) time.Time {
+	// Truncate to 10-second boundary
+	truncated := t.Truncate(10 * time.Second)
+
+	// Calculate how far we are into the current 10-second interval
+	remainder := t.Sub(truncated)
+
+	// If we're at 5 seconds or more, round up to next 10-second mark
+	if remainder >= 5*time.Second {
+		return truncated.Add(10 * time.Second)
+	}
+
+	// Otherwise, round down
+	return truncated
+}
+
+// buildRemoteTimeSeries builds time series for samples received over remote_write, keeping their labels
+func (p *Pusher) buildRemoteTimeSeries(readings []*buffer.RemoteReading) ([]prompb.TimeSeries, error) {
+	// Group readings by label set
+	var keys []string
+	seriesLabels := make(map[string][]prompb.Label)
+	seriesReadings := make(map[string][]*buffer.RemoteReading)
+	for _, reading := range readings {
+		labels := []prompb.Label{
+			{
+				Name:  "__name__",
+				Value: reading.Metric,
+			},
+		}
+		for name, value := range reading.Labels {
+			labels = append(labels, prompb.Label{Name: name, Value: value})
+		}
+		extra := labels[1:]
+		sort.Slice(extra, func(i, j int) bool { return extra[i].Name < extra[j].Name })
+
+		key := seriesKey(labels)
+		if _, ok := seriesLabels[key]; !ok {
+			keys = append(keys, key)
+			seriesLabels[key] = labels
+		}
+		seriesReadings[key] = append(seriesReadings[key], reading)
+	}
+
+	// Build time series for each label set
+	var timeSeries []prompb.TimeSeries
+	for _, key := range keys {
+		samples := make([]prompb.Sample, 0, len(seriesReadings[key]))
+		for _, reading := range seriesReadings[key] {
+			ts := reading.Timestamp
+
+			samples = append(samples, prompb.Sample{
+				Value:     reading.Value,
+				Timestamp: ts.UnixMilli(),
+			})
+		}
+
+		timeSeries = append(timeSeries, prompb.TimeSeries{
+			Labels:  seriesLabels[key],
+			Samples: samples,
+		})
+	}
+
+	return timeSeries, nil
 }
@@ -0,0 +1,133 @@
+package metrics
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/gogo/protobuf/proto"
+	"github.com/golang/snappy"
+	"github.com/mjasion/balena-home/thermostats/buffer"
+	"go.uber.org/zap"
+)
+
+// benchmarkBatchSizes are typical push sizes: a normal interval and a backlog after an outage
+var benchmarkBatchSizes = []int{1000, 10000}
+
+// benchmarkReadings returns a mix resembling a household: mostly BLE, some Netatmo and power
+func benchmarkReadings(n int) []*buffer.Reading {
+	start := time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)
+	readings := make([]*buffer.Reading, 0, n)
+	for i := 0; i < n; i++ {
+		ts := start.Add(time.Duration(i) * time.Second)
+		switch i % 10 {
+		case 8:
+			readings = append(readings, &buffer.Reading{
+				Type: buffer.ReadingTypeNetatmo,
+				Thermostat: &buffer.ThermostatReading{
+					Timestamp: ts, HomeID: "home-1", HomeName: "Home",
+					RoomID: fmt.Sprintf("room-%d", i%4), RoomName: fmt.Sprintf("Room %d", i%4),
+					MeasuredTemperature: 20.5, SetpointTemperature: 21, SetpointMode: "schedule", Reachable: true,
+				},
+			})
+		case 9:
+			readings = append(readings, &buffer.Reading{
+				Type:  buffer.ReadingTypePower,
+				Power: &buffer.PowerReading{Timestamp: ts, SensorID: 1, Value: 350},
+			})
+		default:
+			sensor := i % 8
+			readings = append(readings, &buffer.Reading{
+				Type: buffer.ReadingTypeBLE,
+				BLE: &buffer.SensorReading{
+					Timestamp: ts, MAC: fmt.Sprintf("A4:C1:38:00:00:%02X", sensor),
+					SensorName: fmt.Sprintf("Sensor %d", sensor), SensorID: sensor,
+					TemperatureCelsius: 21.5, HumidityPercent: 45, BatteryPercent: 90,
+					BatteryVoltageMV: 2950, FrameCounter: i % 256, RSSI: -70,
+				},
+			})
+		}
+	}
+	return readings
+}
+
+func BenchmarkBuildBLETimeSeries(b *testing.B) {
+	for _, n := range benchmarkBatchSizes {
+		b.Run(fmt.Sprintf("readings=%d", n), func(b *testing.B) {
+			pusher := newTestPusher("https://example.com", "user", "pass", zap.NewNop())
+			var bleReadings []*buffer.SensorReading
+			for _, reading := range benchmarkReadings(n) {
+				if reading.BLE != nil {
+					bleReadings = append(bleReadings, reading.BLE)
+				}
+			}
+
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if _, err := pusher.buildBLETimeSeries(bleReadings); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkBuildWriteRequest(b *testing.B) {
+	for _, n := range benchmarkBatchSizes {
+		b.Run(fmt.Sprintf("readings=%d", n), func(b *testing.B) {
+			pusher := newTestPusher("https://example.com", "user", "pass", zap.NewNop())
+			readings := benchmarkReadings(n)
+
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if _, err := pusher.buildWriteRequest(readings); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkMarshalSnappy(b *testing.B) {
+	for _, n := range benchmarkBatchSizes {
+		b.Run(fmt.Sprintf("readings=%d", n), func(b *testing.B) {
+			pusher := newTestPusher("https://example.com", "user", "pass", zap.NewNop())
+			writeReq, err := pusher.buildWriteRequest(benchmarkReadings(n))
+			if err != nil {
+				b.Fatal(err)
+			}
+
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				data, err := proto.Marshal(writeReq)
+				if err != nil {
+					b.Fatal(err)
+				}
+				snappy.Encode(nil, data)
+			}
+		})
+	}
+}
+
+func BenchmarkEncodeVMImport(b *testing.B) {
+	for _, n := range benchmarkBatchSizes {
+		b.Run(fmt.Sprintf("readings=%d", n), func(b *testing.B) {
+			pusher := newTestPusher("https://example.com", "user", "pass", zap.NewNop())
+			writeReq, err := pusher.buildWriteRequest(benchmarkReadings(n))
+			if err != nil {
+				b.Fatal(err)
+			}
+
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if _, err := encodeVMImport(writeReq); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -2,12 +2,22 @@ package metrics
 
 import (
 	"context"
+	"fmt"
+	"io"
 	"net/http"
 	"net/http/httptest"
+	"slices"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
+	"github.com/gogo/protobuf/proto"
+	"github.com/golang/snappy"
+	"github.com/mjasion/balena-home/thermostats/admin"
 	"github.com/mjasion/balena-home/thermostats/buffer"
+	"github.com/mjasion/balena-home/thermostats/events"
+	"github.com/prometheus/prometheus/prompb"
 	"go.uber.org/zap"
 )
 
@@ -430,6 +440,382 @@ func TestBuildWriteRequest(t *testing.T) {
 	}
 }
 
+func TestBuildPowerTimeSeries_StaleLabel(t *testing.T) {
+	logger, _ := zap.NewDevelopment()
+	defer logger.Sync()
+
+	pusher := newTestPusher("https://example.com", "user", "pass", logger)
+
+	now := time.Now()
+	readings := []*buffer.PowerReading{
+		{Timestamp: now, SensorID: 0, Value: 89},
+		{Timestamp: now.Add(time.Second), SensorID: 0, Value: 89, Stale: true},
+	}
+
+	timeSeries, err := pusher.buildPowerTimeSeries(readings)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	// Fresh and stale readings must end up in separate series
+	if len(timeSeries) != 2 {
+		t.Fatalf("Expected 2 time series, got %d", len(timeSeries))
+	}
+
+	staleSeries := 0
+	for _, ts := range timeSeries {
+		for _, label := range ts.Labels {
+			if label.Name == "stale" && label.Value == "true" {
+				staleSeries++
+			}
+		}
+	}
+
+	if staleSeries != 1 {
+		t.Errorf("Expected 1 time series with stale=\"true\" label, got %d", staleSeries)
+	}
+}
+
+func TestBuildNetatmoTimeSeries_RoomErrors(t *testing.T) {
+	pusher := newTestPusher("https://example.com", "user", "pass", zap.NewNop())
+
+	now := time.Now()
+	readings := []*buffer.ThermostatReading{
+		{Timestamp: now, HomeID: "home", RoomID: "1", RoomName: "Living Room", MeasuredTemperature: 21, Reachable: true},
+		{Timestamp: now, HomeID: "home", RoomID: "2", RoomName: "Bedroom", MeasuredTemperature: 19, ErrorCode: 6, Stale: true},
+		{Timestamp: now, HomeID: "home", RoomID: "3", RoomName: "Office", ErrorCode: 6, NoValues: true},
+	}
+
+	timeSeries, err := pusher.buildNetatmoTimeSeries(readings)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	// Three series per room with values, one error series per room in error
+	if len(timeSeries) != 8 {
+		t.Fatalf("Expected 8 time series, got %d", len(timeSeries))
+	}
+	errorRooms := make(map[string]string)
+	staleRooms := make(map[string]bool)
+	for _, ts := range timeSeries {
+		var name, room, code string
+		stale := false
+		for _, label := range ts.Labels {
+			switch label.Name {
+			case "__name__":
+				name = label.Value
+			case "room_name":
+				room = label.Value
+			case "code":
+				code = label.Value
+			case "stale":
+				stale = label.Value == "true"
+			}
+		}
+		if name == "netatmo_room_error" {
+			errorRooms[room] = code
+		} else if stale {
+			staleRooms[room] = true
+		}
+		if room == "Office" && name != "netatmo_room_error" {
+			t.Errorf("Expected no %s series for a room without values", name)
+		}
+	}
+	if len(errorRooms) != 2 || errorRooms["Bedroom"] != "6" || errorRooms["Office"] != "6" {
+		t.Errorf("Expected error series with code 6 for Bedroom and Office, got %v", errorRooms)
+	}
+	if len(staleRooms) != 1 || !staleRooms["Bedroom"] {
+		t.Errorf("Expected stale series only for Bedroom, got %v", staleRooms)
+	}
+}
+
+func TestPush_Tenants(t *testing.T) {
+	var mu sync.Mutex
+	tenants := make(map[string]int)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		tenants[r.Header.Get("X-Scope-OrgID")]++
+		mu.Unlock()
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	pusher := newTestPusher(server.URL, "user", "pass", zap.NewNop())
+	pusher.SetTenant("home", map[buffer.ReadingType]string{buffer.ReadingTypePower: "energy"})
+
+	readings := wrapBLEReadings([]*buffer.SensorReading{
+		{Timestamp: time.Now(), MAC: "A4:C1:38:00:00:01", TemperatureCelsius: 22.5},
+	})
+	readings = append(readings, &buffer.Reading{
+		Type:  buffer.ReadingTypePower,
+		Power: &buffer.PowerReading{Timestamp: time.Now(), Value: 1200},
+	})
+
+	if err := pusher.Push(context.Background(), readings); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(tenants) != 2 || tenants["home"] != 1 || tenants["energy"] != 1 {
+		t.Errorf("Expected one request each for home and energy tenants, got %v", tenants)
+	}
+}
+
+func TestBuildBLETimeSeries_DerivedHumidity(t *testing.T) {
+	pusher := newTestPusher("https://example.com", "user", "pass", zap.NewNop())
+	pusher.SetDerivedHumidity(true)
+
+	readings := []*buffer.SensorReading{
+		{
+			Timestamp:          time.Now(),
+			MAC:                "A4:C1:38:00:00:01",
+			SensorName:         "Bathroom",
+			SensorID:           1,
+			TemperatureCelsius: 20,
+			HumidityPercent:    50,
+			BatteryPercent:     90,
+		},
+	}
+
+	timeSeries, err := pusher.buildBLETimeSeries(readings)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	values := make(map[string]float64)
+	for _, ts := range timeSeries {
+		for _, label := range ts.Labels {
+			if label.Name == "__name__" && len(ts.Samples) == 1 {
+				values[label.Value] = ts.Samples[0].Value
+			}
+		}
+	}
+
+	if len(values) != 5 {
+		t.Errorf("Expected 5 metrics, got %d: %v", len(values), values)
+	}
+	if dewPoint := values["ble_dew_point_celsius"]; dewPoint < 9.2 || dewPoint > 9.4 {
+		t.Errorf("Expected dew point around 9.3, got %v", dewPoint)
+	}
+	if absolute := values["ble_absolute_humidity_g_m3"]; absolute < 8.5 || absolute > 8.8 {
+		t.Errorf("Expected absolute humidity around 8.65, got %v", absolute)
+	}
+}
+
+func TestBuildSummaryTimeSeries(t *testing.T) {
+	pusher := newTestPusher("https://example.com", "user", "pass", zap.NewNop())
+
+	readings := []*buffer.SummaryReading{
+		{
+			Timestamp:  time.Now().Truncate(time.Hour),
+			Metric:     "ble_temperature_celsius",
+			MAC:        "A4:C1:38:00:00:01",
+			SensorName: "Bedroom",
+			SensorID:   1,
+			Min:        19.5,
+			Max:        22.5,
+			Avg:        21,
+			Count:      120,
+		},
+	}
+
+	timeSeries, err := pusher.buildSummaryTimeSeries(readings)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	values := make(map[string]float64)
+	for _, ts := range timeSeries {
+		for _, label := range ts.Labels {
+			if label.Name == "__name__" && len(ts.Samples) == 1 {
+				values[label.Value] = ts.Samples[0].Value
+			}
+		}
+	}
+
+	expected := map[string]float64{
+		"ble_temperature_celsius_hourly_min": 19.5,
+		"ble_temperature_celsius_hourly_max": 22.5,
+		"ble_temperature_celsius_hourly_avg": 21,
+	}
+	if len(values) != len(expected) {
+		t.Errorf("Expected %d metrics, got %d: %v", len(expected), len(values), values)
+	}
+	for name, value := range expected {
+		if values[name] != value {
+			t.Errorf("Expected %s to be %v, got %v", name, value, values[name])
+		}
+	}
+}
+
+func TestBuildWriteRequest_HeatPump(t *testing.T) {
+	logger, _ := zap.NewDevelopment()
+	defer logger.Sync()
+
+	pusher := newTestPusher("https://example.com", "user", "pass", logger)
+
+	now := time.Now()
+	readings := []*buffer.Reading{
+		{
+			Type:     buffer.ReadingTypeHeatPump,
+			HeatPump: &buffer.HeatPumpReading{Timestamp: now, Name: "flow_temperature_celsius", Value: 35.5},
+		},
+		{
+			Type:     buffer.ReadingTypeHeatPump,
+			HeatPump: &buffer.HeatPumpReading{Timestamp: now, Name: "compressor_frequency_hertz", Value: 42},
+		},
+	}
+
+	writeReq, err := pusher.buildWriteRequest(readings)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	metricNames := make(map[string]bool)
+	for _, ts := range writeReq.Timeseries {
+		for _, label := range ts.Labels {
+			if label.Name == "__name__" {
+				metricNames[label.Value] = true
+			}
+		}
+	}
+
+	for _, name := range []string{"heatpump_flow_temperature_celsius", "heatpump_compressor_frequency_hertz"} {
+		if !metricNames[name] {
+			t.Errorf("Expected time series for metric %s", name)
+		}
+	}
+}
+
+func TestBuildDependencyTimeSeries(t *testing.T) {
+	logger, _ := zap.NewDevelopment()
+	defer logger.Sync()
+
+	pusher := newTestPusher("https://example.com", "user", "pass", logger)
+
+	now := time.Now()
+	readings := []*buffer.DependencyReading{
+		{
+			Timestamp:          now,
+			Dependency:         "netatmo",
+			Requests:           10,
+			Errors:             1,
+			SentBytes:          2048,
+			ReceivedBytes:      512,
+			DurationSumSeconds: 2.5,
+			DurationBuckets: []buffer.HistogramBucket{
+				{UpperBound: 0.25, Count: 8},
+				{UpperBound: 1, Count: 10},
+			},
+		},
+	}
+
+	timeSeries, err := pusher.buildDependencyTimeSeries(readings)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	// requests, errors, sent and received bytes, 2 buckets, +Inf bucket, sum, count
+	if len(timeSeries) != 9 {
+		t.Fatalf("Expected 9 time series, got %d", len(timeSeries))
+	}
+
+	values := make(map[string]float64)
+	for _, ts := range timeSeries {
+		var name, le string
+		for _, label := range ts.Labels {
+			switch label.Name {
+			case "__name__":
+				name = label.Value
+			case "le":
+				le = label.Value
+			case "dependency":
+				if label.Value != "netatmo" {
+					t.Errorf("Expected dependency label netatmo, got %s", label.Value)
+				}
+			}
+		}
+		values[name+"{"+le+"}"] = ts.Samples[0].Value
+	}
+
+	expected := map[string]float64{
+		"dependency_requests_total{}":                      10,
+		"dependency_request_errors_total{}":                1,
+		"dependency_sent_bytes_total{}":                    2048,
+		"dependency_received_bytes_total{}":                512,
+		"dependency_request_duration_seconds_bucket{0.25}": 8,
+		"dependency_request_duration_seconds_bucket{1}":    10,
+		"dependency_request_duration_seconds_bucket{+Inf}": 10,
+		"dependency_request_duration_seconds_sum{}":        2.5,
+		"dependency_request_duration_seconds_count{}":      10,
+	}
+	for key, value := range expected {
+		if values[key] != value {
+			t.Errorf("Expected %s = %v, got %v", key, value, values[key])
+		}
+	}
+}
+
+func TestBuildDependencyTimeSeries_Connections(t *testing.T) {
+	pusher := newTestPusher("https://example.com", "user", "pass", zap.NewNop())
+	now := time.Now()
+	readings := []*buffer.DependencyReading{
+		{Timestamp: now, Dependency: "netatmo", Requests: 4},
+		{
+			Timestamp:              now,
+			Dependency:             "prometheus",
+			Requests:               10,
+			ConnectionsOpened:      2,
+			ConnectionsReused:      8,
+			ConnectSecondsSum:      0.6,
+			TLSHandshakes:          1,
+			TLSHandshakeSecondsSum: 0.25,
+			FirstBytes:             10,
+			FirstByteSecondsSum:    1.5,
+		},
+	}
+
+	timeSeries, err := pusher.buildDependencyTimeSeries(readings)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	values := make(map[string]float64)
+	for _, ts := range timeSeries {
+		var name, dependency string
+		for _, label := range ts.Labels {
+			switch label.Name {
+			case "__name__":
+				name = label.Value
+			case "dependency":
+				dependency = label.Value
+			}
+		}
+		values[name+"{"+dependency+"}"] = ts.Samples[0].Value
+	}
+
+	expected := map[string]float64{
+		"dependency_connections_opened_total{prometheus}":             2,
+		"dependency_connections_reused_total{prometheus}":             8,
+		"dependency_connect_duration_seconds_sum{prometheus}":         0.6,
+		"dependency_connect_duration_seconds_count{prometheus}":       2,
+		"dependency_tls_handshake_duration_seconds_sum{prometheus}":   0.25,
+		"dependency_tls_handshake_duration_seconds_count{prometheus}": 1,
+		"dependency_time_to_first_byte_seconds_sum{prometheus}":       1.5,
+		"dependency_time_to_first_byte_seconds_count{prometheus}":     10,
+	}
+	for key, value := range expected {
+		if got, ok := values[key]; !ok || got != value {
+			t.Errorf("Expected %s = %v, got %v", key, value, got)
+		}
+	}
+	// Untraced dependencies get no connection series
+	if _, ok := values["dependency_connections_opened_total{netatmo}"]; ok {
+		t.Error("Expected no connection series for an untraced dependency")
+	}
+}
+
 func TestLastPushTime(t *testing.T) {
 	logger, _ := zap.NewDevelopment()
 	defer logger.Sync()
@@ -567,3 +953,580 @@ func TestRoundToTenSeconds(t *testing.T) {
 		})
 	}
 }
+
+func TestPusher_RecordsFailureAndRecoveryEvents(t *testing.T) {
+	pusher := newTestPusher("http://localhost", "user", "pass", zap.NewNop())
+	eventLog := events.NewLog(10, zap.NewNop())
+	pusher.SetEventLog(eventLog)
+
+	pusher.recordSuccess()
+	pusher.recordFailure(fmt.Errorf("connection refused"))
+	pusher.recordFailure(fmt.Errorf("connection refused"))
+	pusher.recordSuccess()
+
+	recorded := eventLog.List(events.Filter{})
+	if len(recorded) != 2 {
+		t.Fatalf("Expected 2 events, got %d", len(recorded))
+	}
+	if recorded[0].Type != events.TypePushFailing {
+		t.Errorf("Expected first event %s, got %s", events.TypePushFailing, recorded[0].Type)
+	}
+	if recorded[1].Type != events.TypePushRecovered || recorded[1].Fields["failures"] != "2" {
+		t.Errorf("Expected recovery after 2 failures, got %+v", recorded[1])
+	}
+}
+
+// failingSink is a Sink that confirms batches until the test fails it
+type failingSink struct {
+	fail    bool
+	batches [][]*buffer.Reading
+}
+
+func (s *failingSink) Push(ctx context.Context, readings []*buffer.Reading) error {
+	if s.fail {
+		return fmt.Errorf("not acknowledged")
+	}
+	s.batches = append(s.batches, readings)
+	return nil
+}
+
+func TestPusher_Sink(t *testing.T) {
+	pusher := newTestPusher("", "", "", zap.NewNop())
+	pusher.batchSize = 2
+	sink := &failingSink{fail: true}
+	pusher.SetSink(sink)
+	for i := 0; i < 3; i++ {
+		pusher.buffer.Add(&buffer.Reading{Type: buffer.ReadingTypeWater, Water: &buffer.WaterReading{Timestamp: time.Now(), TotalLiters: float64(i)}})
+	}
+
+	// Unconfirmed readings stay buffered and back the pusher off
+	pusher.flush(context.Background())
+	if pusher.buffer.Size() != 3 || pusher.failures != 1 {
+		t.Fatalf("Expected 3 buffered readings after 1 failure, got %d after %d", pusher.buffer.Size(), pusher.failures)
+	}
+
+	sink.fail = false
+	pusher.flush(context.Background())
+	if pusher.buffer.Size() != 0 || pusher.failures != 0 {
+		t.Errorf("Expected the buffer drained and failures reset, got %d readings and %d failures", pusher.buffer.Size(), pusher.failures)
+	}
+	if len(sink.batches) != 2 || len(sink.batches[0]) != 2 || len(sink.batches[1]) != 1 {
+		t.Errorf("Expected batches of 2 and 1 readings, got %d batches", len(sink.batches))
+	}
+}
+
+// fixedLeader is a Leader whose answer the test controls
+type fixedLeader struct {
+	mu     sync.Mutex
+	leader bool
+}
+
+func (f *fixedLeader) IsLeader() bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.leader
+}
+
+func (f *fixedLeader) set(leader bool) {
+	f.mu.Lock()
+	f.leader = leader
+	f.mu.Unlock()
+}
+
+func TestPusher_StandbyKeepsReadingsBuffered(t *testing.T) {
+	var mu sync.Mutex
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requests++
+		mu.Unlock()
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	pusher := newTestPusher(server.URL, "user", "pass", zap.NewNop())
+	pusher.pushInterval = 10 * time.Millisecond
+	elector := &fixedLeader{}
+	pusher.SetLeader(elector)
+	pusher.buffer.Add(&buffer.Reading{
+		Type: buffer.ReadingTypeBLE,
+		BLE:  &buffer.SensorReading{Timestamp: time.Now(), MAC: "A4:C1:38:00:00:01", SensorName: "Sensor1", SensorID: 1},
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go pusher.Start(ctx)
+
+	time.Sleep(50 * time.Millisecond)
+	mu.Lock()
+	standbyRequests := requests
+	mu.Unlock()
+	if standbyRequests != 0 {
+		t.Errorf("Expected no pushes while standing by, got %d", standbyRequests)
+	}
+	if pusher.buffer.Size() != 1 {
+		t.Errorf("Expected reading to stay buffered, got buffer size %d", pusher.buffer.Size())
+	}
+
+	elector.set(true)
+	pushed := func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return requests > 0
+	}
+	deadline := time.Now().Add(time.Second)
+	for !pushed() && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if !pushed() {
+		t.Error("Expected a push after becoming leader")
+	}
+}
+
+func TestNextInterval(t *testing.T) {
+	pusher := newTestPusher("https://example.com", "user", "pass", zap.NewNop())
+	pusher.pushInterval = 15 * time.Second
+
+	tests := []struct {
+		maxBackoff time.Duration
+		failures   int
+		expected   time.Duration
+	}{
+		{5 * time.Minute, 0, 15 * time.Second},
+		{5 * time.Minute, 1, 30 * time.Second},
+		{5 * time.Minute, 3, 120 * time.Second},
+		{5 * time.Minute, 5, 5 * time.Minute}, // Capped
+		{5 * time.Minute, 100, 5 * time.Minute},
+		{0, 3, 15 * time.Second}, // Backoff disabled
+		{10 * time.Second, 3, 15 * time.Second},
+	}
+	for _, tt := range tests {
+		pusher.SetMaxBackoff(tt.maxBackoff)
+		pusher.failures = tt.failures
+		if got := pusher.nextInterval(); got != tt.expected {
+			t.Errorf("Max backoff %v after %d failures: expected %v, got %v", tt.maxBackoff, tt.failures, tt.expected, got)
+		}
+	}
+}
+
+func TestNextPush(t *testing.T) {
+	pusher := newTestPusher("https://example.com", "user", "pass", zap.NewNop())
+	now := time.Now()
+
+	// Unaligned pushes keep their schedule instead of drifting by the push duration
+	last := now.Add(-time.Second)
+	if got := pusher.nextPush(last, 15*time.Second); !got.Equal(last.Add(15 * time.Second)) {
+		t.Errorf("Expected the next push 15s after the last one, got %v", got.Sub(last))
+	}
+	// A missed push isn't caught up
+	last = now.Add(-40 * time.Second)
+	if got := pusher.nextPush(last, 15*time.Second); !got.Equal(last.Add(45 * time.Second)) {
+		t.Errorf("Expected the next push on the schedule after now, got %v", got.Sub(last))
+	}
+
+	pusher.SetAligned(true)
+	got := pusher.nextPush(now, 15*time.Second)
+	if got.UnixNano()%int64(15*time.Second) != 0 || !got.After(now) || got.Sub(now) > 15*time.Second {
+		t.Errorf("Expected an aligned push within 15s, got %v", got)
+	}
+}
+
+func TestPushHandler_TriggersImmediatePush(t *testing.T) {
+	pushed := make(chan struct{}, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		select {
+		case pushed <- struct{}{}:
+		default:
+		}
+	}))
+	defer server.Close()
+
+	pusher := newTestPusher(server.URL, "user", "pass", zap.NewNop())
+	pusher.pushInterval = time.Hour
+	pusher.buffer.Add(&buffer.Reading{
+		Type: buffer.ReadingTypeBLE,
+		BLE:  &buffer.SensorReading{Timestamp: time.Now(), MAC: "A4:C1:38:00:00:01", SensorName: "Sensor1", SensorID: 1},
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go pusher.Start(ctx)
+
+	adminServer := admin.New(":0", zap.NewNop())
+	RegisterPushHandler(adminServer, pusher)
+	recorder := httptest.NewRecorder()
+	adminServer.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/api/push-now", nil))
+	if recorder.Code != http.StatusAccepted {
+		t.Errorf("Expected status 202, got %d", recorder.Code)
+	}
+
+	select {
+	case <-pushed:
+	case <-time.After(time.Second):
+		t.Fatal("Expected an immediate push")
+	}
+}
+
+func TestBuildRemoteTimeSeries(t *testing.T) {
+	pusher := newTestPusher("https://example.com", "user", "pass", zap.NewNop())
+	now := time.Now()
+	labels := map[string]string{"job": "esp32", "instance": "esp32-garage"}
+	series, err := pusher.buildRemoteTimeSeries([]*buffer.RemoteReading{
+		{Timestamp: now, Metric: "esp32_uptime_seconds", Labels: labels, Value: 60},
+		{Timestamp: now.Add(time.Second), Metric: "esp32_uptime_seconds", Labels: labels, Value: 61},
+		{Timestamp: now, Metric: "esp32_wifi_rssi", Labels: labels, Value: -60},
+	})
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if len(series) != 2 {
+		t.Fatalf("Expected 2 series, got %d", len(series))
+	}
+	expected := `__name__="esp32_uptime_seconds",instance="esp32-garage",job="esp32"`
+	if got := seriesKey(series[0].Labels); got != expected || len(series[0].Samples) != 2 {
+		t.Errorf("Expected %s with 2 samples, got %s with %d", expected, got, len(series[0].Samples))
+	}
+	if series[0].Labels[0].Name != "__name__" {
+		t.Errorf("Expected __name__ first, got %+v", series[0].Labels)
+	}
+}
+
+func TestBuildHTTPTimeSeries(t *testing.T) {
+	pusher := newTestPusher("https://example.com", "user", "pass", zap.NewNop())
+	series, err := pusher.buildHTTPTimeSeries([]*buffer.HTTPReading{
+		{
+			Timestamp:          time.Now(),
+			Route:              "GET /api/buffer",
+			Code:               200,
+			Requests:           3,
+			DurationSumSeconds: 0.02,
+			DurationBuckets:    []buffer.HistogramBucket{{UpperBound: 0.05, Count: 3}},
+		},
+		{Timestamp: time.Now(), Route: "unmatched", Code: 404, Requests: 1},
+	})
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	// Per reading: requests, buckets, +Inf bucket, sum, count
+	if len(series) != 9 {
+		t.Fatalf("Expected 9 series, got %d", len(series))
+	}
+	expected := `__name__="http_server_requests_total",code="200",route="GET /api/buffer"`
+	if got := seriesKey(series[0].Labels); got != expected || series[0].Samples[0].Value != 3 {
+		t.Errorf("Expected %s with value 3, got %s with %v", expected, got, series[0].Samples[0].Value)
+	}
+	expected = `__name__="http_server_requests_total",code="404",route="unmatched"`
+	if got := seriesKey(series[5].Labels); got != expected {
+		t.Errorf("Expected %s, got %s", expected, got)
+	}
+}
+
+func TestBuildConflictTimeSeries(t *testing.T) {
+	pusher := newTestPusher("https://example.com", "user", "pass", zap.NewNop())
+	series, err := pusher.buildConflictTimeSeries([]*buffer.ConflictReading{
+		{Timestamp: time.Now(), Kind: "sensor_id", Key: "1", Identities: 2},
+		{Timestamp: time.Now(), Kind: "sensor_id", Key: "1", Identities: 3},
+	})
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	if len(series) != 1 || len(series[0].Samples) != 2 {
+		t.Fatalf("Expected 1 series with 2 samples, got %+v", series)
+	}
+	expected := `__name__="sensor_identity_conflicts",key="1",kind="sensor_id"`
+	if got := seriesKey(series[0].Labels); got != expected || series[0].Samples[1].Value != 3 {
+		t.Errorf("Expected %s with value 3, got %s with %v", expected, got, series[0].Samples[1].Value)
+	}
+}
+
+func TestBuildBatteryTimeSeries(t *testing.T) {
+	pusher := newTestPusher("https://example.com", "user", "pass", zap.NewNop())
+	series, err := pusher.buildBatteryTimeSeries([]*buffer.BatteryReading{
+		{Timestamp: time.Now(), MAC: "A4:C1:38:00:00:01", SensorName: "kitchen", SensorID: 1, DaysRemaining: 120.5},
+		{Timestamp: time.Now(), MAC: "A4:C1:38:00:00:02", SensorName: "bedroom", SensorID: 2, DaysRemaining: 12},
+	})
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	if len(series) != 2 {
+		t.Fatalf("Expected 2 series, got %d", len(series))
+	}
+	expected := `__name__="ble_battery_days_remaining",mac="A4:C1:38:00:00:01",sensor_id="1",sensor_name="kitchen"`
+	if got := seriesKey(series[0].Labels); got != expected || series[0].Samples[0].Value != 120.5 {
+		t.Errorf("Expected %s with value 120.5, got %s with %v", expected, got, series[0].Samples[0].Value)
+	}
+}
+
+func TestBuildLocationTimeSeries(t *testing.T) {
+	pusher := newTestPusher("https://example.com", "user", "pass", zap.NewNop())
+	series, err := pusher.buildLocationTimeSeries([]*buffer.LocationReading{
+		{Timestamp: time.Now(), MAC: "A4:C1:38:00:00:01", Name: "keys", Receiver: "hallway", Room: "hall", RSSI: -60},
+		{Timestamp: time.Now(), MAC: "A4:C1:38:00:00:02", Name: "kitchen", Receiver: "local", RSSI: -70},
+	})
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	if len(series) != 2 {
+		t.Fatalf("Expected 2 series, got %d", len(series))
+	}
+	expected := `__name__="ble_nearest_receiver",mac="A4:C1:38:00:00:01",name="keys",receiver="hallway",room="hall"`
+	if got := seriesKey(series[0].Labels); got != expected || series[0].Samples[0].Value != 1 {
+		t.Errorf("Expected %s with value 1, got %s with %v", expected, got, series[0].Samples[0].Value)
+	}
+	expected = `__name__="ble_nearest_receiver",mac="A4:C1:38:00:00:02",name="kitchen",receiver="local"`
+	if got := seriesKey(series[1].Labels); got != expected {
+		t.Errorf("Expected %s, got %s", expected, got)
+	}
+}
+
+func TestBuildReconciliationTimeSeries(t *testing.T) {
+	pusher := newTestPusher("https://example.com", "user", "pass", zap.NewNop())
+	series, err := pusher.buildReconciliationTimeSeries([]*buffer.ReconciliationReading{
+		{Timestamp: time.Now(), Source: "pstryk", Hours: 24, OfficialKWh: 10, LocalKWh: 11, DriftPercent: 10},
+	})
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	if len(series) != 3 {
+		t.Fatalf("Expected 3 series, got %d", len(series))
+	}
+	expected := map[string]float64{
+		`__name__="energy_reconciliation_official_kwh",source="pstryk"`:  10,
+		`__name__="energy_reconciliation_local_kwh",source="pstryk"`:     11,
+		`__name__="energy_reconciliation_drift_percent",source="pstryk"`: 10,
+	}
+	for _, ts := range series {
+		key := seriesKey(ts.Labels)
+		if value, ok := expected[key]; !ok || ts.Samples[0].Value != value {
+			t.Errorf("Unexpected series %s with %v", key, ts.Samples[0].Value)
+		}
+	}
+}
+
+func TestBuildPriceTimeSeries(t *testing.T) {
+	pusher := newTestPusher("https://example.com", "user", "pass", zap.NewNop())
+	now := time.Now()
+	series, err := pusher.buildPriceTimeSeries([]*buffer.PriceReading{
+		{Timestamp: now, Source: "pse", HoursAhead: 0, PLNPerKWh: 0.45},
+		{Timestamp: now, Source: "pse", HoursAhead: 1, PLNPerKWh: 0.32},
+		{Timestamp: now.Add(5 * time.Minute), Source: "pse", HoursAhead: 0, PLNPerKWh: 0.45},
+	})
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	if len(series) != 2 {
+		t.Fatalf("Expected 2 series, got %d", len(series))
+	}
+	expected := `__name__="electricity_price_pln_per_kwh",hours_ahead="0",source="pse"`
+	if got := seriesKey(series[0].Labels); got != expected || len(series[0].Samples) != 2 {
+		t.Errorf("Expected %s with 2 samples, got %s with %d", expected, got, len(series[0].Samples))
+	}
+	expected = `__name__="electricity_price_pln_per_kwh",hours_ahead="1",source="pse"`
+	if got := seriesKey(series[1].Labels); got != expected || series[1].Samples[0].Value != 0.32 {
+		t.Errorf("Expected %s with 0.32, got %s with %v", expected, got, series[1].Samples[0].Value)
+	}
+}
+
+func TestPush_BuildInfo(t *testing.T) {
+	var pushed []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		compressed, _ := io.ReadAll(r.Body)
+		data, err := snappy.Decode(nil, compressed)
+		if err != nil {
+			t.Fatalf("Failed to decode snappy: %v", err)
+		}
+		var writeReq prompb.WriteRequest
+		if err := proto.Unmarshal(data, &writeReq); err != nil {
+			t.Fatalf("Failed to unmarshal write request: %v", err)
+		}
+		for _, ts := range writeReq.Timeseries {
+			pushed = append(pushed, seriesKey(ts.Labels))
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	pusher := newTestPusher(server.URL, "user", "pass", zap.NewNop())
+	pusher.SetBuildInfo(map[string]string{"version": "v1.2.3", "commit": "abc123"})
+	readings := wrapBLEReadings([]*buffer.SensorReading{{Timestamp: time.Now(), MAC: "A4:C1:38:00:00:01", TemperatureCelsius: 21}})
+	if err := pusher.Push(context.Background(), readings); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	expected := `__name__="home_controller_build_info",commit="abc123",version="v1.2.3"`
+	found := false
+	for _, key := range pushed {
+		found = found || key == expected
+	}
+	if !found {
+		t.Errorf("Expected %s to be pushed, got %v", expected, pushed)
+	}
+}
+
+func TestPush_ProvenanceHeaders(t *testing.T) {
+	var mu sync.Mutex
+	var headers []http.Header
+	attempts := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		headers = append(headers, r.Header.Clone())
+		attempts++
+		if attempts == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	pusher := newTestPusher(server.URL, "user", "pass", zap.NewNop())
+	pusher.SetProvenance("v1.2.3", "pi-garage")
+	readings := wrapBLEReadings([]*buffer.SensorReading{
+		{Timestamp: time.Now(), MAC: "A4:C1:38:00:00:01", TemperatureCelsius: 21},
+		{Timestamp: time.Now(), MAC: "A4:C1:38:00:00:02", TemperatureCelsius: 19},
+	})
+	readings = append(readings, &buffer.Reading{Type: buffer.ReadingTypePower, Power: &buffer.PowerReading{Timestamp: time.Now(), Value: 1200}})
+	if err := pusher.Push(context.Background(), readings); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	mu.Lock()
+	received := headers
+	mu.Unlock()
+	if len(received) != 2 {
+		t.Fatalf("Expected a failed and a retried request, got %d", len(received))
+	}
+	first, retry := received[0], received[1]
+	if first.Get(HeaderCollectorVersion) != "v1.2.3" || first.Get(HeaderCollectorDevice) != "pi-garage" {
+		t.Errorf("Expected version and device headers, got %v", first)
+	}
+	if got := first.Get(HeaderBatchReadings); got != "ble=2,power=1" {
+		t.Errorf("Expected reading counts ble=2,power=1, got %q", got)
+	}
+	if id := first.Get(HeaderBatchID); id == "" || retry.Get(HeaderBatchID) != id {
+		t.Errorf("Expected the retry to keep batch ID %q, got %q", id, retry.Get(HeaderBatchID))
+	}
+
+	// The next push is a new batch
+	if err := pusher.Push(context.Background(), readings[:1]); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if id := headers[2].Get(HeaderBatchID); id == first.Get(HeaderBatchID) {
+		t.Errorf("Expected a new batch ID, got %q again", id)
+	}
+}
+
+func TestBuildOverwrittenTimeSeries(t *testing.T) {
+	buf := buffer.New(1, zap.NewNop())
+	pusher := New("https://example.com", "user", "pass", buf, 30, 1000, zap.NewNop())
+	if series := pusher.buildOverwrittenTimeSeries(); series != nil {
+		t.Errorf("Expected no series before an overwrite, got %v", series)
+	}
+
+	for i := 0; i < 3; i++ {
+		buf.Add(&buffer.Reading{Type: buffer.ReadingTypePower, Power: &buffer.PowerReading{Value: float64(i)}})
+	}
+	pusher.SetName("backup")
+	series := pusher.buildOverwrittenTimeSeries()
+	if len(series) != 1 || seriesKey(series[0].Labels) != `__name__="buffer_overwritten_total",endpoint="backup"` || series[0].Samples[0].Value != 2 {
+		t.Errorf("Expected buffer_overwritten_total of 2 for the backup endpoint, got %v", series)
+	}
+}
+
+func TestPusher_ExternalLabels(t *testing.T) {
+	pusher := newTestPusher("https://example.com", "user", "pass", zap.NewNop())
+	pusher.SetExternalLabels(map[string]string{"device": "pi-garage", "fleet": "home"})
+
+	shared := []prompb.Label{{Name: "__name__", Value: "esp32_uptime_seconds"}, {Name: "device", Value: "esp32"}}
+	series := []prompb.TimeSeries{
+		{Labels: []prompb.Label{{Name: "__name__", Value: "ble_temperature_celsius"}}},
+		{Labels: shared},
+	}
+	pusher.addExternalLabels(series)
+
+	expected := `__name__="ble_temperature_celsius",device="pi-garage",fleet="home"`
+	if got := seriesKey(series[0].Labels); got != expected {
+		t.Errorf("Expected %s, got %s", expected, got)
+	}
+	// A series' own labels win
+	expected = `__name__="esp32_uptime_seconds",device="esp32",fleet="home"`
+	if got := seriesKey(series[1].Labels); got != expected {
+		t.Errorf("Expected %s, got %s", expected, got)
+	}
+	if len(shared) != 2 {
+		t.Errorf("Expected original labels unchanged, got %v", shared)
+	}
+}
+
+// fixedMetered is a Metered with a fixed state
+type fixedMetered bool
+
+func (m fixedMetered) IsMetered() bool { return bool(m) }
+
+func TestPusher_MeteredAggregatesOnly(t *testing.T) {
+	var pushed []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		compressed, _ := io.ReadAll(r.Body)
+		data, err := snappy.Decode(nil, compressed)
+		if err != nil {
+			t.Fatalf("Failed to decode snappy: %v", err)
+		}
+		var writeReq prompb.WriteRequest
+		if err := proto.Unmarshal(data, &writeReq); err != nil {
+			t.Fatalf("Failed to unmarshal write request: %v", err)
+		}
+		for _, ts := range writeReq.Timeseries {
+			pushed = append(pushed, ts.Labels[0].Value)
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	addReadings := func(pusher *Pusher) {
+		now := time.Now()
+		pusher.buffer.Add(&buffer.Reading{
+			Type: buffer.ReadingTypeBLE,
+			BLE:  &buffer.SensorReading{Timestamp: now, MAC: "A4:C1:38:00:00:01", SensorName: "Sensor1", SensorID: 1},
+		})
+		pusher.buffer.Add(&buffer.Reading{
+			Type:    buffer.ReadingTypeSummary,
+			Summary: &buffer.SummaryReading{Timestamp: now, Metric: "ble_temperature_celsius", MAC: "A4:C1:38:00:00:01", SensorName: "Sensor1", SensorID: 1, Count: 1},
+		})
+	}
+
+	pusher := newTestPusher(server.URL, "user", "pass", zap.NewNop())
+	pusher.SetMeteredProfile(fixedMetered(true), time.Hour, true)
+	addReadings(pusher)
+	pusher.flush(context.Background())
+
+	for _, name := range pushed {
+		if !strings.Contains(name, "_hourly_") {
+			t.Errorf("Expected raw BLE series dropped on a metered link, got %s", name)
+		}
+	}
+	if len(pushed) == 0 {
+		t.Error("Expected summary series to be pushed")
+	}
+	if pusher.buffer.Size() != 0 {
+		t.Errorf("Expected dropped readings not to be re-buffered, got buffer size %d", pusher.buffer.Size())
+	}
+
+	// Unmetered links push raw readings
+	pushed = nil
+	pusher.SetMeteredProfile(fixedMetered(false), time.Hour, true)
+	addReadings(pusher)
+	pusher.flush(context.Background())
+	if !slices.Contains(pushed, "ble_temperature_celsius") {
+		t.Errorf("Expected raw BLE series on an unmetered link, got %v", pushed)
+	}
+}
@@ -0,0 +1,219 @@
+package metrics
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/mjasion/balena-home/thermostats/admin"
+	"go.uber.org/zap"
+)
+
+// defaultPushLogLimit is the number of attempts returned by GET /api/pushlog without a limit
+const defaultPushLogLimit = 100
+
+// compactInterval is how often expired attempts are removed from the push log file
+const compactInterval = time.Hour
+
+// PushAttempt is a single remote_write request as kept in the push log
+type PushAttempt struct {
+	Timestamp  time.Time `json:"timestamp"`
+	Endpoint   string    `json:"endpoint,omitempty"` // Empty for the primary endpoint
+	Tenant     string    `json:"tenant,omitempty"`
+	BatchID    string    `json:"batch_id,omitempty"`   // Shared by the retries of a batch
+	RequestID  string    `json:"request_id,omitempty"` // X-Request-ID of the attempt, as logged by the endpoint
+	Series     int       `json:"series"`
+	Samples    int       `json:"samples"`
+	Bytes      int       `json:"bytes"` // Encoded request body size
+	DurationMs int64     `json:"duration_ms"`
+	Status     int       `json:"status,omitempty"` // HTTP status, 0 when no response was received
+	Error      string    `json:"error,omitempty"`
+}
+
+// PushLog keeps every push attempt of the last retention period in a JSON lines file,
+// so ingestion gaps can be investigated after the fact
+// A nil *PushLog is valid and discards attempts
+type PushLog struct {
+	mu          sync.Mutex
+	path        string
+	retention   time.Duration
+	file        *os.File
+	lastCompact time.Time
+	now         func() time.Time
+	logger      *zap.Logger
+}
+
+// OpenPushLog opens or creates the push log file at path, removing attempts older than retention
+func OpenPushLog(path string, retention time.Duration, logger *zap.Logger) (*PushLog, error) {
+	l := &PushLog{
+		path:      path,
+		retention: retention,
+		now:       time.Now,
+		logger:    logger,
+	}
+	if err := l.compact(); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+// Record appends an attempt to the log
+func (l *PushLog) Record(attempt PushAttempt) {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.now().Sub(l.lastCompact) >= compactInterval {
+		if err := l.compact(); err != nil {
+			l.logger.Warn("failed to compact push log", zap.Error(err))
+		}
+	}
+	if l.file == nil {
+		return
+	}
+
+	line, err := json.Marshal(attempt)
+	if err != nil {
+		return
+	}
+	if _, err := l.file.Write(append(line, '\n')); err != nil {
+		l.logger.Warn("failed to write push log", zap.Error(err))
+	}
+}
+
+// Close closes the log file
+func (l *PushLog) Close() error {
+	if l == nil {
+		return nil
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.file == nil {
+		return nil
+	}
+	err := l.file.Close()
+	l.file = nil
+	return err
+}
+
+// compact rewrites the file without expired attempts and reopens it for appending;
+// caller holds the lock or has exclusive access
+func (l *PushLog) compact() error {
+	l.lastCompact = l.now()
+	cutoff := l.now().Add(-l.retention)
+
+	var kept []byte
+	err := l.scan(func(attempt PushAttempt, line []byte) {
+		if !attempt.Timestamp.Before(cutoff) {
+			kept = append(kept, line...)
+			kept = append(kept, '\n')
+		}
+	})
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	if l.file != nil {
+		l.file.Close()
+		l.file = nil
+	}
+	tmpPath := l.path + ".tmp"
+	if err := os.WriteFile(tmpPath, kept, 0644); err != nil {
+		return fmt.Errorf("failed to write push log: %w", err)
+	}
+	if err := os.Rename(tmpPath, l.path); err != nil {
+		return fmt.Errorf("failed to replace push log: %w", err)
+	}
+	file, err := os.OpenFile(l.path, os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open push log: %w", err)
+	}
+	l.file = file
+	return nil
+}
+
+// scan calls fn with every attempt in the file, oldest first; malformed lines are skipped
+func (l *PushLog) scan(fn func(attempt PushAttempt, line []byte)) error {
+	f, err := os.Open(l.path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var attempt PushAttempt
+		if err := json.Unmarshal(scanner.Bytes(), &attempt); err != nil {
+			continue
+		}
+		fn(attempt, scanner.Bytes())
+	}
+	return scanner.Err()
+}
+
+// RegisterHandlers registers the push log endpoint on the admin server
+func (l *PushLog) RegisterHandlers(server *admin.Server) {
+	server.HandleFunc("GET /api/pushlog", l.handleList)
+}
+
+// handleList handles GET /api/pushlog?since=<RFC 3339>&failed=true&limit=<n>, newest first
+func (l *PushLog) handleList(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	limit := defaultPushLogLimit
+	if value := query.Get("limit"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 1 {
+			admin.WriteError(w, http.StatusBadRequest, "limit must be a positive integer")
+			return
+		}
+		limit = n
+	}
+	var since time.Time
+	if value := query.Get("since"); value != "" {
+		t, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			admin.WriteError(w, http.StatusBadRequest, "since must be an RFC 3339 timestamp")
+			return
+		}
+		since = t
+	}
+	failedOnly := query.Get("failed") == "true"
+
+	// Keep the newest matches in a ring while scanning oldest first
+	ring := make([]PushAttempt, 0, limit)
+	next := 0
+	l.mu.Lock()
+	err := l.scan(func(attempt PushAttempt, line []byte) {
+		if attempt.Timestamp.Before(since) || (failedOnly && attempt.Error == "") {
+			return
+		}
+		if len(ring) < limit {
+			ring = append(ring, attempt)
+			return
+		}
+		ring[next] = attempt
+		next = (next + 1) % limit
+	})
+	l.mu.Unlock()
+	if err != nil && !os.IsNotExist(err) {
+		admin.WriteError(w, http.StatusInternalServerError, fmt.Sprintf("failed to read push log: %v", err))
+		return
+	}
+
+	attempts := make([]PushAttempt, 0, len(ring))
+	for i := len(ring) - 1; i >= 0; i-- {
+		attempts = append(attempts, ring[(next+i)%len(ring)])
+	}
+	admin.WriteJSON(w, http.StatusOK, admin.Response{
+		Success: true,
+		Data:    attempts,
+	})
+}
@@ -0,0 +1,114 @@
+package metrics
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/mjasion/balena-home/thermostats/admin"
+	"github.com/prometheus/prometheus/prompb"
+	"go.uber.org/zap"
+)
+
+func TestPushLog_RetentionAndQuery(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "pushlog.jsonl")
+	now := time.Now().UTC()
+
+	pushLog, err := OpenPushLog(path, 24*time.Hour, zap.NewNop())
+	if err != nil {
+		t.Fatalf("Failed to open push log: %v", err)
+	}
+	pushLog.Record(PushAttempt{Timestamp: now.Add(-48 * time.Hour), Status: 200})
+	pushLog.Record(PushAttempt{Timestamp: now.Add(-time.Hour), Status: 200, Series: 3})
+	pushLog.Record(PushAttempt{Timestamp: now, Status: 503, Error: "remote_write returned status 503"})
+	if err := pushLog.Close(); err != nil {
+		t.Fatalf("Failed to close push log: %v", err)
+	}
+
+	// Reopening drops the attempt older than the retention
+	pushLog, err = OpenPushLog(path, 24*time.Hour, zap.NewNop())
+	if err != nil {
+		t.Fatalf("Failed to reopen push log: %v", err)
+	}
+	defer pushLog.Close()
+
+	adminServer := admin.New(":0", zap.NewNop())
+	pushLog.RegisterHandlers(adminServer)
+	query := func(target string) []PushAttempt {
+		w := httptest.NewRecorder()
+		adminServer.ServeHTTP(w, httptest.NewRequest(http.MethodGet, target, nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected status 200 for %s, got %d", target, w.Code)
+		}
+		var body struct {
+			Data []PushAttempt `json:"data"`
+		}
+		if err := json.NewDecoder(w.Body).Decode(&body); err != nil {
+			t.Fatalf("Failed to decode response: %v", err)
+		}
+		return body.Data
+	}
+
+	attempts := query("/api/pushlog")
+	if len(attempts) != 2 {
+		t.Fatalf("Expected 2 attempts within retention, got %d", len(attempts))
+	}
+	if attempts[0].Status != 503 || attempts[1].Series != 3 {
+		t.Errorf("Expected newest attempt first, got %+v", attempts)
+	}
+	if failed := query("/api/pushlog?failed=true"); len(failed) != 1 || failed[0].Error == "" {
+		t.Errorf("Expected only the failed attempt, got %+v", failed)
+	}
+	if limited := query("/api/pushlog?limit=1"); len(limited) != 1 || limited[0].Status != 503 {
+		t.Errorf("Expected only the newest attempt, got %+v", limited)
+	}
+
+	w := httptest.NewRecorder()
+	adminServer.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/pushlog?since=yesterday", nil))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for an invalid since, got %d", w.Code)
+	}
+}
+
+func TestPusher_RecordsPushAttempts(t *testing.T) {
+	status := http.StatusOK
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+
+	pushLog, err := OpenPushLog(filepath.Join(t.TempDir(), "pushlog.jsonl"), 24*time.Hour, zap.NewNop())
+	if err != nil {
+		t.Fatalf("Failed to open push log: %v", err)
+	}
+	defer pushLog.Close()
+
+	pusher := newTestPusher(server.URL, "", "", zap.NewNop())
+	pusher.SetPushLog(pushLog)
+	writeReq := &prompb.WriteRequest{Timeseries: []prompb.TimeSeries{testSeries("a", 1000, 2000)}}
+	if err := pusher.pushOnce(context.Background(), writeReq, "tenant-a"); err != nil {
+		t.Fatalf("Expected push to succeed, got %v", err)
+	}
+	status = http.StatusBadRequest
+	if err := pusher.pushOnce(context.Background(), writeReq, ""); err == nil {
+		t.Fatal("Expected push to fail")
+	}
+
+	var attempts []PushAttempt
+	pushLog.scan(func(attempt PushAttempt, line []byte) {
+		attempts = append(attempts, attempt)
+	})
+	if len(attempts) != 2 {
+		t.Fatalf("Expected 2 recorded attempts, got %d", len(attempts))
+	}
+	if attempts[0].Tenant != "tenant-a" || attempts[0].Series != 1 || attempts[0].Samples != 2 || attempts[0].Bytes == 0 || attempts[0].Error != "" {
+		t.Errorf("Expected a successful attempt with 1 series and 2 samples, got %+v", attempts[0])
+	}
+	if attempts[1].Status != http.StatusBadRequest || attempts[1].Error == "" {
+		t.Errorf("Expected a failed attempt with status 400, got %+v", attempts[1])
+	}
+}
@@ -0,0 +1,54 @@
+package metrics
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+
+	"github.com/prometheus/prometheus/prompb"
+)
+
+// Push protocols
+const (
+	ProtocolRemoteWrite = "remote_write" // Prometheus remote_write (snappy protobuf)
+	ProtocolVMImport    = "vm_import"    // VictoriaMetrics /api/v1/import JSON lines
+)
+
+// vmImportLine is a single series in the VictoriaMetrics JSON line import format
+type vmImportLine struct {
+	Metric     map[string]string `json:"metric"`
+	Values     []float64         `json:"values"`
+	Timestamps []int64           `json:"timestamps"`
+}
+
+// encodeVMImport encodes a write request as VictoriaMetrics JSON lines, one series per line
+// VictoriaMetrics accepts samples older than its remote_write out-of-order window on this path,
+// which helps when replaying readings buffered during a long outage
+func encodeVMImport(writeReq *prompb.WriteRequest) ([]byte, error) {
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	for _, ts := range writeReq.Timeseries {
+		if len(ts.Samples) == 0 {
+			continue
+		}
+
+		line := vmImportLine{
+			Metric:     make(map[string]string, len(ts.Labels)),
+			Values:     make([]float64, len(ts.Samples)),
+			Timestamps: make([]int64, len(ts.Samples)),
+		}
+		for _, label := range ts.Labels {
+			line.Metric[label.Name] = label.Value
+		}
+		for i, sample := range ts.Samples {
+			line.Values[i] = sample.Value
+			line.Timestamps[i] = sample.Timestamp
+		}
+
+		// Encode appends the newline separating lines
+		if err := encoder.Encode(line); err != nil {
+			return nil, fmt.Errorf("failed to encode series: %w", err)
+		}
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,91 @@
+package metrics
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/mjasion/balena-home/thermostats/buffer"
+	"github.com/prometheus/prometheus/prompb"
+	"go.uber.org/zap"
+)
+
+func TestEncodeVMImport(t *testing.T) {
+	writeReq := &prompb.WriteRequest{
+		Timeseries: []prompb.TimeSeries{
+			{
+				Labels: []prompb.Label{
+					{Name: "__name__", Value: "ble_temperature_celsius"},
+					{Name: "sensor_name", Value: "Salon"},
+				},
+				Samples: []prompb.Sample{
+					{Value: 21.5, Timestamp: 1700000000000},
+					{Value: 21.6, Timestamp: 1700000010000},
+				},
+			},
+			{
+				Labels: []prompb.Label{{Name: "__name__", Value: "empty"}},
+			},
+		},
+	}
+
+	data, err := encodeVMImport(writeReq)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	lines := bytes.Split(bytes.TrimSpace(data), []byte("\n"))
+	if len(lines) != 1 {
+		t.Fatalf("Expected 1 line (empty series skipped), got %d", len(lines))
+	}
+
+	var line vmImportLine
+	if err := json.Unmarshal(lines[0], &line); err != nil {
+		t.Fatalf("Expected valid JSON, got: %v", err)
+	}
+	if line.Metric["__name__"] != "ble_temperature_celsius" || line.Metric["sensor_name"] != "Salon" {
+		t.Errorf("Unexpected metric labels: %v", line.Metric)
+	}
+	if len(line.Values) != 2 || line.Values[1] != 21.6 || line.Timestamps[1] != 1700000010000 {
+		t.Errorf("Unexpected samples: %v %v", line.Values, line.Timestamps)
+	}
+}
+
+func TestPush_VMImport(t *testing.T) {
+	var contentType string
+	var lineCount int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		contentType = r.Header.Get("Content-Type")
+		body, _ := io.ReadAll(r.Body)
+		scanner := bufio.NewScanner(bytes.NewReader(body))
+		for scanner.Scan() {
+			lineCount++
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	pusher := newTestPusher(server.URL, "", "", zap.NewNop())
+	pusher.SetProtocol(ProtocolVMImport)
+
+	readings := wrapBLEReadings([]*buffer.SensorReading{
+		{Timestamp: time.Now(), MAC: "A4:C1:38:00:00:01", SensorName: "Salon", TemperatureCelsius: 21.5},
+	})
+	if err := pusher.Push(context.Background(), readings); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	if contentType != "application/json" {
+		t.Errorf("Expected Content-Type application/json, got %s", contentType)
+	}
+	// Temperature, humidity and battery series
+	if lineCount != 3 {
+		t.Errorf("Expected 3 lines, got %d", lineCount)
+	}
+}
@@ -0,0 +1,58 @@
+package mode
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/mjasion/balena-home/thermostats/admin"
+)
+
+// maxRequestBytes bounds the body of a mode change
+const maxRequestBytes = 4096
+
+// modeRequest is the body of POST /api/mode
+type modeRequest struct {
+	Mode   string `json:"mode"`
+	Reason string `json:"reason"`
+}
+
+// RegisterHandlers registers the mode endpoints on the admin server
+func (s *Switch) RegisterHandlers(server *admin.Server) {
+	server.HandleFunc("GET /api/mode", s.handleGet)
+	server.HandleFunc("POST /api/mode", s.handleSet)
+}
+
+// handleGet handles GET /api/mode
+func (s *Switch) handleGet(w http.ResponseWriter, r *http.Request) {
+	state := s.State()
+	admin.WriteJSON(w, http.StatusOK, admin.Response{
+		Success: true,
+		Message: fmt.Sprintf("%s mode", state.Mode),
+		Data:    state,
+	})
+}
+
+// handleSet handles POST /api/mode with {"mode":"vacation","reason":"skiing"}
+func (s *Switch) handleSet(w http.ResponseWriter, r *http.Request) {
+	var req modeRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxRequestBytes)).Decode(&req); err != nil {
+		admin.WriteError(w, http.StatusBadRequest, "body must be JSON with a mode")
+		return
+	}
+	if !Valid(req.Mode) {
+		admin.WriteError(w, http.StatusBadRequest, "mode must be normal, vacation or maintenance")
+		return
+	}
+
+	state, err := s.Set(r.Context(), req.Mode, req.Reason)
+	if err != nil {
+		admin.WriteError(w, http.StatusBadGateway, err.Error())
+		return
+	}
+	admin.WriteJSON(w, http.StatusOK, admin.Response{
+		Success: true,
+		Message: fmt.Sprintf("Switched to %s mode.", state.Mode),
+		Data:    state,
+	})
+}
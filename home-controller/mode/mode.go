@@ -0,0 +1,177 @@
+package mode
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/mjasion/balena-home/thermostats/events"
+	"go.uber.org/zap"
+)
+
+// Modes of the home
+const (
+	Normal      = "normal"
+	Vacation    = "vacation"    // Non-critical alerts suppressed, heating lowered
+	Maintenance = "maintenance" // Non-critical alerts suppressed, e.g. while sensors are swapped
+)
+
+// Valid reports whether mode is one of the modes of the home
+func Valid(mode string) bool {
+	return mode == Normal || mode == Vacation || mode == Maintenance
+}
+
+// Heating switches the heating to a thermostat mode; netatmo.Fetcher implements it
+type Heating interface {
+	SetThermMode(ctx context.Context, mode string) error
+}
+
+// State is the current mode and when and why it was set
+type State struct {
+	Mode   string    `json:"mode"`
+	Since  time.Time `json:"since"`
+	Reason string    `json:"reason,omitempty"`
+}
+
+// Switch holds the mode of the home, persisted across restarts
+// Leaving normal mode suppresses non-critical automation webhooks; entering vacation mode
+// also lowers the heating and leaving it returns the heating to its schedule
+type Switch struct {
+	statePath       string
+	heating         Heating // Nil leaves the heating alone
+	vacationHeating string  // Thermostat mode while on vacation
+	eventLog        *events.Log
+	logger          *zap.Logger
+
+	mu    sync.Mutex
+	state State
+}
+
+// New creates a switch in normal mode persisted to statePath
+func New(statePath string, logger *zap.Logger) *Switch {
+	return &Switch{
+		statePath: statePath,
+		logger:    logger,
+		state:     State{Mode: Normal, Since: time.Now()},
+	}
+}
+
+// SetHeating lowers the heating to thermMode while on vacation, e.g. away or frost guard
+func (s *Switch) SetHeating(heating Heating, thermMode string) {
+	s.heating = heating
+	s.vacationHeating = thermMode
+}
+
+// SetEventLog sets the event log used to record mode changes; with Grafana annotations
+// enabled each change is annotated on the dashboards
+func (s *Switch) SetEventLog(eventLog *events.Log) {
+	s.eventLog = eventLog
+}
+
+// State returns the current mode
+func (s *Switch) State() State {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.state
+}
+
+// SuppressAlerts reports whether non-critical alerts are suppressed, outside normal mode
+func (s *Switch) SuppressAlerts() bool {
+	return s.State().Mode != Normal
+}
+
+// Set changes the mode, adjusting the heating first; the mode is unchanged when that fails
+func (s *Switch) Set(ctx context.Context, mode, reason string) (State, error) {
+	if !Valid(mode) {
+		return State{}, fmt.Errorf("unknown mode %q, expected normal, vacation or maintenance", mode)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	previous := s.state
+	if mode == previous.Mode {
+		return previous, nil
+	}
+
+	if thermMode := s.thermModeFor(previous.Mode, mode); thermMode != "" {
+		if err := s.heating.SetThermMode(ctx, thermMode); err != nil {
+			return previous, fmt.Errorf("failed to switch heating to %s: %w", thermMode, err)
+		}
+	}
+
+	s.state = State{Mode: mode, Since: time.Now(), Reason: reason}
+	if err := s.save(); err != nil {
+		// The mode applies until the next restart
+		s.logger.Error("failed to persist mode", zap.Error(err))
+	}
+
+	s.logger.Info("mode changed",
+		zap.String("mode", mode),
+		zap.String("previous", previous.Mode),
+		zap.String("reason", reason),
+	)
+	fields := map[string]string{"mode": mode, "previous": previous.Mode}
+	if reason != "" {
+		fields["reason"] = reason
+	}
+	s.eventLog.Record(events.TypeModeChanged, "mode", fmt.Sprintf("%s mode", mode), fields)
+	return s.state, nil
+}
+
+// thermModeFor returns the thermostat mode to switch to when changing modes, empty to leave the heating alone
+func (s *Switch) thermModeFor(from, to string) string {
+	if s.heating == nil || s.vacationHeating == "" {
+		return ""
+	}
+	switch {
+	case to == Vacation:
+		return s.vacationHeating
+	case from == Vacation:
+		return "schedule" // Back to the thermostat schedule
+	}
+	return ""
+}
+
+// Load restores the mode from the state file; a missing file starts in normal mode
+func (s *Switch) Load() error {
+	data, err := os.ReadFile(s.statePath)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read state file: %w", err)
+	}
+
+	var state State
+	if err := json.Unmarshal(data, &state); err != nil {
+		return fmt.Errorf("failed to parse state file: %w", err)
+	}
+	if !Valid(state.Mode) {
+		return fmt.Errorf("unknown mode %q in state file", state.Mode)
+	}
+
+	s.mu.Lock()
+	s.state = state
+	s.mu.Unlock()
+	return nil
+}
+
+// save persists the state to the state file atomically; the caller must hold the lock
+func (s *Switch) save() error {
+	data, err := json.Marshal(s.state)
+	if err != nil {
+		return fmt.Errorf("failed to marshal state: %w", err)
+	}
+
+	tmpPath := s.statePath + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0644); err != nil {
+		return fmt.Errorf("failed to write state file: %w", err)
+	}
+	if err := os.Rename(tmpPath, s.statePath); err != nil {
+		return fmt.Errorf("failed to replace state file: %w", err)
+	}
+	return nil
+}
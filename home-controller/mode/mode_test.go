@@ -0,0 +1,97 @@
+package mode
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/mjasion/balena-home/thermostats/events"
+	"go.uber.org/zap"
+)
+
+type fakeHeating struct {
+	modes []string
+	err   error
+}
+
+func (h *fakeHeating) SetThermMode(ctx context.Context, mode string) error {
+	if h.err != nil {
+		return h.err
+	}
+	h.modes = append(h.modes, mode)
+	return nil
+}
+
+func TestSwitch_Set(t *testing.T) {
+	statePath := filepath.Join(t.TempDir(), "mode.json")
+	heating := &fakeHeating{}
+	eventLog := events.NewLog(10, zap.NewNop())
+	s := New(statePath, zap.NewNop())
+	s.SetHeating(heating, "away")
+	s.SetEventLog(eventLog)
+
+	if s.SuppressAlerts() {
+		t.Error("Expected alerts in normal mode")
+	}
+	if _, err := s.Set(context.Background(), Maintenance, "replacing sensors"); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if _, err := s.Set(context.Background(), Vacation, "skiing"); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if !s.SuppressAlerts() {
+		t.Error("Expected alerts to be suppressed on vacation")
+	}
+	if _, err := s.Set(context.Background(), Normal, ""); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	// Only entering and leaving vacation touches the heating
+	if len(heating.modes) != 2 || heating.modes[0] != "away" || heating.modes[1] != "schedule" {
+		t.Errorf("Expected heating modes [away schedule], got %v", heating.modes)
+	}
+	recorded := eventLog.List(events.Filter{Type: events.TypeModeChanged})
+	if len(recorded) != 3 || recorded[1].Fields["reason"] != "skiing" || recorded[1].Fields["previous"] != Maintenance {
+		t.Errorf("Expected 3 mode_changed events, got %v", recorded)
+	}
+
+	if _, err := s.Set(context.Background(), "party", ""); err == nil {
+		t.Error("Expected an error for an unknown mode")
+	}
+}
+
+func TestSwitch_HeatingFailureKeepsMode(t *testing.T) {
+	s := New(filepath.Join(t.TempDir(), "mode.json"), zap.NewNop())
+	s.SetHeating(&fakeHeating{err: errors.New("netatmo unavailable")}, "hg")
+
+	if _, err := s.Set(context.Background(), Vacation, ""); err == nil {
+		t.Fatal("Expected the heating error")
+	}
+	if mode := s.State().Mode; mode != Normal {
+		t.Errorf("Expected to stay in normal mode, got %s", mode)
+	}
+}
+
+func TestSwitch_PersistsAcrossRestarts(t *testing.T) {
+	statePath := filepath.Join(t.TempDir(), "mode.json")
+	s := New(statePath, zap.NewNop())
+	if _, err := s.Set(context.Background(), Vacation, "skiing"); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	restarted := New(statePath, zap.NewNop())
+	if err := restarted.Load(); err != nil {
+		t.Fatalf("Failed to load state: %v", err)
+	}
+	state := restarted.State()
+	if state.Mode != Vacation || state.Reason != "skiing" {
+		t.Errorf("Expected vacation mode for skiing, got %+v", state)
+	}
+
+	// A missing file starts in normal mode
+	fresh := New(filepath.Join(t.TempDir(), "missing.json"), zap.NewNop())
+	if err := fresh.Load(); err != nil || fresh.State().Mode != Normal {
+		t.Errorf("Expected normal mode without a state file, got %s (%v)", fresh.State().Mode, err)
+	}
+}
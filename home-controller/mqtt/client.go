@@ -0,0 +1,162 @@
+package mqtt
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	paho "github.com/eclipse/paho.mqtt.golang"
+	"github.com/mjasion/balena-home/thermostats/httpauth"
+	"go.uber.org/zap"
+)
+
+// subscribeQoS is requested for every topic: the broker redelivers unacknowledged messages while
+// the connection lasts, and sends at the QoS they were published with when lower
+const subscribeQoS = 1
+
+// subAckFailure is the SUBACK return code of a rejected subscription
+const subAckFailure = 0x80
+
+// Handler is called for every message received on a subscribed topic
+type Handler func(topic string, payload []byte)
+
+// Options contains broker connection settings
+type Options struct {
+	Broker           string // host:port, or a tcp://, ssl:// (TLS), ws:// or wss:// URL
+	ClientID         string
+	Username         string
+	Password         string
+	KeepAliveSeconds int
+	TLS              httpauth.TLSConfig // CA, client certificate and verification for ssl:// and wss:// brokers
+}
+
+// Client is an MQTT subscriber that reconnects automatically
+type Client struct {
+	options *paho.ClientOptions
+	broker  string
+	topics  []string
+	handler Handler
+	logger  *zap.Logger
+}
+
+// NewClient creates a client that delivers messages on topics to handler
+// Handler is called sequentially, in the order messages arrive
+func NewClient(options Options, topics []string, handler Handler, logger *zap.Logger) (*Client, error) {
+	if options.KeepAliveSeconds <= 0 {
+		options.KeepAliveSeconds = 60
+	}
+	tlsConfig, err := httpauth.NewTLSConfig(options.TLS)
+	if err != nil {
+		return nil, fmt.Errorf("invalid MQTT TLS settings: %w", err)
+	}
+	broker := options.Broker
+	if !strings.Contains(broker, "://") {
+		broker = "tcp://" + broker
+	}
+
+	// Reconnects are left to Start, which resubscribes and checks the SUBACK of every session
+	pahoOptions := paho.NewClientOptions().
+		AddBroker(broker).
+		SetClientID(options.ClientID).
+		SetUsername(options.Username).
+		SetPassword(options.Password).
+		SetKeepAlive(time.Duration(options.KeepAliveSeconds) * time.Second).
+		SetTLSConfig(tlsConfig).
+		SetConnectTimeout(10 * time.Second).
+		SetCleanSession(true).
+		SetAutoReconnect(false).
+		SetOrderMatters(true)
+	return &Client{
+		options: pahoOptions,
+		broker:  broker,
+		topics:  topics,
+		handler: handler,
+		logger:  logger,
+	}, nil
+}
+
+// Start connects to the broker and processes messages until the context is cancelled
+func (c *Client) Start(ctx context.Context) {
+	c.logger.Info("starting MQTT client",
+		zap.String("broker", c.broker),
+		zap.Strings("topics", c.topics),
+	)
+
+	backoff := time.Second
+	for {
+		err := c.session(ctx)
+		if ctx.Err() != nil {
+			c.logger.Info("stopping MQTT client")
+			return
+		}
+
+		c.logger.Warn("MQTT connection lost, reconnecting",
+			zap.Duration("backoff", backoff),
+			zap.Error(err),
+		)
+		select {
+		case <-ctx.Done():
+			c.logger.Info("stopping MQTT client")
+			return
+		case <-time.After(backoff):
+		}
+
+		// Exponential backoff capped at one minute
+		backoff *= 2
+		if backoff > time.Minute {
+			backoff = time.Minute
+		}
+	}
+}
+
+// session runs a single broker connection until it fails or the context is cancelled
+// A rejected subscription fails the session, so it is retried rather than silently receiving nothing
+func (c *Client) session(ctx context.Context) error {
+	lost := make(chan error, 1)
+	options := *c.options
+	options.SetConnectionLostHandler(func(_ paho.Client, err error) {
+		lost <- err
+	})
+	client := paho.NewClient(&options)
+
+	if err := wait(ctx, client.Connect()); err != nil {
+		return fmt.Errorf("failed to connect to broker: %w", err)
+	}
+	defer client.Disconnect(250)
+
+	filters := make(map[string]byte, len(c.topics))
+	for _, topic := range c.topics {
+		filters[topic] = subscribeQoS
+	}
+	token := client.SubscribeMultiple(filters, func(_ paho.Client, message paho.Message) {
+		c.handler(message.Topic(), message.Payload())
+	})
+	if err := wait(ctx, token); err != nil {
+		return fmt.Errorf("failed to subscribe: %w", err)
+	}
+	for topic, code := range token.(*paho.SubscribeToken).Result() {
+		if code == subAckFailure {
+			return fmt.Errorf("broker rejected the subscription to %s", topic)
+		}
+	}
+
+	c.logger.Info("connected to MQTT broker", zap.String("broker", c.broker))
+
+	select {
+	case <-ctx.Done():
+		return nil
+	case err := <-lost:
+		return err
+	}
+}
+
+// wait waits for the token to complete or the context to be cancelled
+func wait(ctx context.Context, token paho.Token) error {
+	select {
+	case <-token.Done():
+		return token.Error()
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,130 @@
+package mqtt
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+
+	mochi "github.com/mochi-mqtt/server/v2"
+	"github.com/mochi-mqtt/server/v2/listeners"
+	"github.com/mochi-mqtt/server/v2/packets"
+	"go.uber.org/zap"
+)
+
+// denyHook allows every client and rejects subscriptions to denied/ topics
+type denyHook struct {
+	mochi.HookBase
+}
+
+func (h *denyHook) ID() string {
+	return "deny"
+}
+
+func (h *denyHook) Provides(b byte) bool {
+	return bytes.Contains([]byte{mochi.OnConnectAuthenticate, mochi.OnACLCheck}, []byte{b})
+}
+
+func (h *denyHook) OnConnectAuthenticate(cl *mochi.Client, pk packets.Packet) bool {
+	return true
+}
+
+func (h *denyHook) OnACLCheck(cl *mochi.Client, topic string, write bool) bool {
+	return !strings.HasPrefix(topic, "denied/")
+}
+
+// runBroker starts an in-process MQTT broker and returns it with its address
+func runBroker(t *testing.T) (*mochi.Server, string) {
+	t.Helper()
+	server := mochi.New(&mochi.Options{InlineClient: true})
+	server.Log = slog.New(slog.DiscardHandler)
+	if err := server.AddHook(new(denyHook), nil); err != nil {
+		t.Fatalf("Failed to add hook: %v", err)
+	}
+	listener := listeners.NewTCP(listeners.Config{ID: "tcp", Address: "127.0.0.1:0"})
+	if err := server.AddListener(listener); err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	if err := server.Serve(); err != nil {
+		t.Fatalf("Failed to serve: %v", err)
+	}
+	t.Cleanup(func() { server.Close() })
+	return server, listener.Address()
+}
+
+func TestClient_ReceivesMessages(t *testing.T) {
+	broker, address := runBroker(t)
+
+	received := make(chan string, 1)
+	client, err := NewClient(
+		Options{Broker: address, ClientID: "test"},
+		[]string{"zigbee2mqtt/#"},
+		func(topic string, payload []byte) {
+			received <- topic + " " + string(payload)
+		},
+		zap.NewNop(),
+	)
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		client.Start(ctx)
+		close(done)
+	}()
+
+	// Published until the subscription is in place
+	deadline := time.After(5 * time.Second)
+	for message := ""; message == ""; {
+		broker.Publish("zigbee2mqtt/sensor", []byte(`{"temperature":21.5}`), false, 0)
+		select {
+		case message = <-received:
+			if message != `zigbee2mqtt/sensor {"temperature":21.5}` {
+				t.Errorf("Unexpected message %s", message)
+			}
+		case <-time.After(50 * time.Millisecond):
+		case <-deadline:
+			t.Fatal("Timed out waiting for message")
+		}
+	}
+
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Client did not stop after cancellation")
+	}
+}
+
+func TestClient_RejectedSubscription(t *testing.T) {
+	_, address := runBroker(t)
+
+	client, err := NewClient(
+		Options{Broker: "tcp://" + address, ClientID: "test"},
+		[]string{"zigbee2mqtt/#", "denied/#"},
+		func(topic string, payload []byte) {},
+		zap.NewNop(),
+	)
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	err = client.session(ctx)
+	if err == nil || !strings.Contains(err.Error(), "denied/#") {
+		t.Errorf("Expected the rejected subscription to fail the session, got %v", err)
+	}
+}
+
+func TestNewClient_InvalidTLS(t *testing.T) {
+	options := Options{Broker: "ssl://broker:8883", ClientID: "test"}
+	options.TLS.CAFile = "/nonexistent/ca.pem"
+	if _, err := NewClient(options, []string{"zigbee2mqtt/#"}, func(string, []byte) {}, zap.NewNop()); err == nil {
+		t.Error("Expected an error for a missing CA file")
+	}
+}
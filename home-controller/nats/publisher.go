@@ -0,0 +1,184 @@
+package nats
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/mjasion/balena-home/thermostats/buffer"
+	"github.com/mjasion/balena-home/thermostats/readingpb"
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+	"go.uber.org/zap"
+)
+
+// Message encodings
+const (
+	EncodingJSON     = "json"
+	EncodingProtobuf = "protobuf"
+)
+
+// jsonReading is a reading published with the JSON encoding, shaped like GET /api/buffer entries
+type jsonReading struct {
+	Type      buffer.ReadingType `json:"type"`
+	Timestamp time.Time          `json:"timestamp"`
+	Reading   interface{}        `json:"reading"`
+}
+
+// Options contains server connection settings
+type Options struct {
+	URL      string // e.g. nats://host:4222, or tls://host:4222 for TLS
+	Name     string // Client name shown in the server's connection list
+	Username string
+	Password string
+	Token    string
+}
+
+// PublisherOptions contains what and how readings are published
+type PublisherOptions struct {
+	SubjectPrefix string        // Readings are published on <prefix>.<type>, e.g. home.readings.ble
+	Encoding      string        // json or protobuf (readingpb.Reading)
+	JetStream     bool          // Wait for the stream's acknowledgement of every message
+	AckTimeout    time.Duration // Time to wait for a batch to be confirmed
+}
+
+// Publisher publishes readings to a NATS server; it is a metrics.Sink, so the pusher delivering to
+// it queues, batches and retries the readings the server didn't confirm
+type Publisher struct {
+	options PublisherOptions
+	conn    *nats.Conn
+	js      jetstream.JetStream
+	logger  *zap.Logger
+}
+
+// Connect creates a publisher for the server; an unreachable server is retried in the background
+func Connect(server Options, options PublisherOptions, logger *zap.Logger) (*Publisher, error) {
+	connOptions := []nats.Option{
+		nats.Name(server.Name),
+		nats.RetryOnFailedConnect(true),
+		nats.MaxReconnects(-1),
+		// Publishes fail while disconnected instead of waiting in the client's reconnect buffer,
+		// where they would count as sent; the pusher keeps them and retries
+		nats.ReconnectBufSize(-1),
+		nats.DisconnectErrHandler(func(_ *nats.Conn, err error) {
+			logger.Warn("disconnected from NATS server", zap.Error(err))
+		}),
+		nats.ReconnectHandler(func(conn *nats.Conn) {
+			logger.Info("connected to NATS server", zap.String("server", conn.ConnectedUrlRedacted()))
+		}),
+	}
+	if server.Username != "" {
+		connOptions = append(connOptions, nats.UserInfo(server.Username, server.Password))
+	}
+	if server.Token != "" {
+		connOptions = append(connOptions, nats.Token(server.Token))
+	}
+	conn, err := nats.Connect(server.URL, connOptions...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to NATS server: %w", err)
+	}
+
+	// Acknowledgements of a batch that failed part way are given up on after the same timeout
+	js, err := jetstream.New(conn, jetstream.WithPublishAsyncTimeout(options.AckTimeout))
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to create JetStream context: %w", err)
+	}
+	return &Publisher{options: options, conn: conn, js: js, logger: logger}, nil
+}
+
+// Push publishes readings and waits until the server confirms them: with JetStream every message
+// is acknowledged by the stream, otherwise the server answers a PING sent after the messages
+// A reading that can't be encoded or exceeds the server's max_payload is logged and dropped, as
+// no retry can deliver it; any other failure returns an error and the batch is retried
+// JetStream discards messages it already stored on a retry by their Nats-Msg-Id
+func (p *Publisher) Push(ctx context.Context, readings []*buffer.Reading) error {
+	ctx, cancel := context.WithTimeout(ctx, p.options.AckTimeout)
+	defer cancel()
+
+	var acks []jetstream.PubAckFuture
+	published := 0
+	for _, reading := range readings {
+		msg, err := p.message(reading)
+		if err != nil {
+			p.logger.Warn("failed to encode reading for NATS, dropping it", zap.String("type", string(reading.Type)), zap.Error(err))
+			continue
+		}
+
+		if p.options.JetStream {
+			var ack jetstream.PubAckFuture
+			ack, err = p.js.PublishMsgAsync(msg, jetstream.WithMsgID(messageID(msg)))
+			if err == nil {
+				acks = append(acks, ack)
+			}
+		} else {
+			err = p.conn.PublishMsg(msg)
+		}
+		if errors.Is(err, nats.ErrMaxPayload) {
+			p.logger.Warn("reading exceeds the NATS server's max_payload, dropping it",
+				zap.String("type", string(reading.Type)),
+				zap.Int("size", len(msg.Data)),
+				zap.Int64("max_payload", p.conn.MaxPayload()),
+			)
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("failed to publish reading: %w", err)
+		}
+		published++
+	}
+
+	if published == 0 {
+		return nil
+	}
+	if !p.options.JetStream {
+		if err := p.conn.FlushWithContext(ctx); err != nil {
+			return fmt.Errorf("failed to confirm published readings: %w", err)
+		}
+		return nil
+	}
+	for _, ack := range acks {
+		select {
+		case <-ack.Ok():
+		case err := <-ack.Err():
+			return fmt.Errorf("JetStream didn't acknowledge reading: %w", err)
+		case <-ctx.Done():
+			return fmt.Errorf("timed out waiting for JetStream acknowledgements: %w", ctx.Err())
+		}
+	}
+	return nil
+}
+
+// message encodes a reading with the configured encoding on its subject
+func (p *Publisher) message(reading *buffer.Reading) (*nats.Msg, error) {
+	var data []byte
+	var err error
+	if p.options.Encoding == EncodingProtobuf {
+		data, err = readingpb.Marshal(reading)
+	} else {
+		data, err = json.Marshal(jsonReading{
+			Type:      reading.Type,
+			Timestamp: reading.Timestamp(),
+			Reading:   reading.Payload(),
+		})
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &nats.Msg{Subject: p.options.SubjectPrefix + "." + string(reading.Type), Data: data}, nil
+}
+
+// messageID identifies a message by its content, so a retried reading gets the same ID
+func messageID(msg *nats.Msg) string {
+	sum := sha256.Sum256(append([]byte(msg.Subject+"\n"), msg.Data...))
+	return hex.EncodeToString(sum[:16])
+}
+
+// Close closes the server connection
+func (p *Publisher) Close() {
+	p.conn.Close()
+}
@@ -0,0 +1,180 @@
+package nats
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mjasion/balena-home/thermostats/buffer"
+	"github.com/nats-io/nats-server/v2/server"
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+	"go.uber.org/zap"
+)
+
+// runServer starts an in-process NATS server with JetStream and a small max_payload
+func runServer(t *testing.T) *server.Server {
+	t.Helper()
+	s, err := server.NewServer(&server.Options{
+		Host:       "127.0.0.1",
+		Port:       -1,
+		NoLog:      true,
+		NoSigs:     true,
+		JetStream:  true,
+		StoreDir:   t.TempDir(),
+		MaxPayload: 1024,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create NATS server: %v", err)
+	}
+	s.Start()
+	if !s.ReadyForConnections(5 * time.Second) {
+		t.Fatal("NATS server not ready")
+	}
+	t.Cleanup(s.Shutdown)
+	return s
+}
+
+// waterReading returns a water reading with the total
+func waterReading(timestamp time.Time, liters float64) *buffer.Reading {
+	return &buffer.Reading{Type: buffer.ReadingTypeWater, Water: &buffer.WaterReading{Timestamp: timestamp, TotalLiters: liters}}
+}
+
+func TestPublisher_JetStream(t *testing.T) {
+	s := runServer(t)
+	conn, err := nats.Connect(s.ClientURL())
+	if err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	defer conn.Close()
+	js, _ := jetstream.New(conn)
+	stream, err := js.CreateStream(context.Background(), jetstream.StreamConfig{Name: "READINGS", Subjects: []string{"home.readings.>"}})
+	if err != nil {
+		t.Fatalf("Failed to create stream: %v", err)
+	}
+
+	publisher, err := Connect(Options{URL: s.ClientURL()}, PublisherOptions{
+		SubjectPrefix: "home.readings",
+		Encoding:      EncodingJSON,
+		JetStream:     true,
+		AckTimeout:    5 * time.Second,
+	}, zap.NewNop())
+	if err != nil {
+		t.Fatalf("Failed to connect publisher: %v", err)
+	}
+	defer publisher.Close()
+
+	// The oversized reading is dropped and the rest of the batch still published
+	timestamp := time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC)
+	readings := []*buffer.Reading{
+		waterReading(timestamp, 1),
+		{Type: buffer.ReadingTypeBLE, BLE: &buffer.SensorReading{Timestamp: timestamp, SensorName: strings.Repeat("x", 2048)}},
+		waterReading(timestamp, 2),
+	}
+	if err := publisher.Push(context.Background(), readings); err != nil {
+		t.Fatalf("Expected the batch to be confirmed, got %v", err)
+	}
+
+	// A retried batch is deduplicated by the stream
+	if err := publisher.Push(context.Background(), readings); err != nil {
+		t.Fatalf("Expected the retried batch to be confirmed, got %v", err)
+	}
+	info, err := stream.Info(context.Background())
+	if err != nil {
+		t.Fatalf("Failed to get stream info: %v", err)
+	}
+	if info.State.Msgs != 2 {
+		t.Fatalf("Expected 2 stored messages, got %d", info.State.Msgs)
+	}
+
+	msg, err := stream.GetMsg(context.Background(), 1)
+	if err != nil {
+		t.Fatalf("Failed to get message: %v", err)
+	}
+	if msg.Subject != "home.readings.water" {
+		t.Errorf("Expected subject home.readings.water, got %s", msg.Subject)
+	}
+	var message jsonReading
+	if err := json.Unmarshal(msg.Data, &message); err != nil || message.Type != buffer.ReadingTypeWater || !message.Timestamp.Equal(timestamp) {
+		t.Errorf("Expected a JSON water reading, got %s", msg.Data)
+	}
+}
+
+func TestPublisher_JetStreamWithoutStream(t *testing.T) {
+	s := runServer(t)
+	publisher, err := Connect(Options{URL: s.ClientURL()}, PublisherOptions{
+		SubjectPrefix: "home.readings",
+		Encoding:      EncodingProtobuf,
+		JetStream:     true,
+		AckTimeout:    time.Second,
+	}, zap.NewNop())
+	if err != nil {
+		t.Fatalf("Failed to connect publisher: %v", err)
+	}
+	defer publisher.Close()
+
+	if err := publisher.Push(context.Background(), []*buffer.Reading{waterReading(time.Now(), 1)}); err == nil {
+		t.Error("Expected an error when no stream acknowledges the reading")
+	}
+}
+
+func TestPublisher_Core(t *testing.T) {
+	s := runServer(t)
+	conn, err := nats.Connect(s.ClientURL())
+	if err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	defer conn.Close()
+	sub, err := conn.SubscribeSync("home.readings.>")
+	if err != nil {
+		t.Fatalf("Failed to subscribe: %v", err)
+	}
+	conn.Flush()
+
+	publisher, err := Connect(Options{URL: s.ClientURL()}, PublisherOptions{
+		SubjectPrefix: "home.readings",
+		Encoding:      EncodingProtobuf,
+		AckTimeout:    time.Second,
+	}, zap.NewNop())
+	if err != nil {
+		t.Fatalf("Failed to connect publisher: %v", err)
+	}
+	defer publisher.Close()
+
+	if err := publisher.Push(context.Background(), []*buffer.Reading{waterReading(time.Now(), 1)}); err != nil {
+		t.Fatalf("Expected the reading to be confirmed, got %v", err)
+	}
+	msg, err := sub.NextMsg(time.Second)
+	if err != nil || msg.Subject != "home.readings.water" {
+		t.Errorf("Expected a message on home.readings.water, got %v", err)
+	}
+}
+
+func TestPublisher_ServerUnreachable(t *testing.T) {
+	// Nothing listens on the address, so the readings aren't confirmed
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	address := listener.Addr().String()
+	listener.Close()
+
+	for _, jetStream := range []bool{false, true} {
+		publisher, err := Connect(Options{URL: "nats://" + address}, PublisherOptions{
+			SubjectPrefix: "home.readings",
+			Encoding:      EncodingJSON,
+			JetStream:     jetStream,
+			AckTimeout:    time.Second,
+		}, zap.NewNop())
+		if err != nil {
+			t.Fatalf("Expected the connection to be retried in the background, got %v", err)
+		}
+		if err := publisher.Push(context.Background(), []*buffer.Reading{waterReading(time.Now(), 1)}); err == nil {
+			t.Errorf("Expected an error without a server (JetStream %v)", jetStream)
+		}
+		publisher.Close()
+	}
+}
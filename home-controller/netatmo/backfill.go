@@ -0,0 +1,163 @@
+package netatmo
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/mjasion/balena-home/thermostats/clock"
+	"go.uber.org/zap"
+)
+
+// backfillScale is the finest scale of the room measure history
+const backfillScale = "30min"
+
+// LastPusher reports when readings were last pushed successfully, such as the metrics pusher
+type LastPusher interface {
+	LastPushTime() time.Time
+}
+
+// Backfill fills the gap in the heating history left by downtime: it records when readings were
+// last pushed, and on startup returns the rooms' measure history since then
+type Backfill struct {
+	fetcher   *Fetcher
+	statePath string
+	window    time.Duration // Bounds the gap to what the endpoint accepts out of order
+	logger    *zap.Logger
+	clock     clock.Clock
+
+	mu       sync.Mutex
+	recorded time.Time // Last push time in the state file, or the pusher's creation time
+}
+
+// backfillState is persisted in the state file
+type backfillState struct {
+	LastPush time.Time `json:"last_push"`
+}
+
+// NewBackfill creates a backfill keeping its state in statePath and reaching at most window back
+func NewBackfill(fetcher *Fetcher, statePath string, window time.Duration, logger *zap.Logger) *Backfill {
+	return &Backfill{
+		fetcher:   fetcher,
+		statePath: statePath,
+		window:    window,
+		logger:    logger,
+		clock:     clock.Real,
+	}
+}
+
+// SetClock sets the clock bounding the gap and driving the state ticker, e.g. a fake one in tests
+func (b *Backfill) SetClock(c clock.Clock) {
+	b.clock = c
+}
+
+// Readings returns the rooms' readings since the last recorded push, at most the window ago;
+// none before a push was ever recorded
+func (b *Backfill) Readings(ctx context.Context) ([]ThermostatReading, error) {
+	state, err := b.load()
+	if err != nil {
+		return nil, err
+	}
+	if state.LastPush.IsZero() {
+		return nil, nil
+	}
+
+	now := b.clock.Now()
+	begin := state.LastPush
+	if limit := now.Add(-b.window); begin.Before(limit) {
+		b.logger.Warn("Netatmo backfill gap exceeds the out-of-order window, backfilling only its end",
+			zap.Time("last_push", state.LastPush),
+			zap.Duration("window", b.window),
+		)
+		begin = limit
+	}
+	if !begin.Before(now) {
+		return nil, nil
+	}
+
+	history, err := b.fetcher.FetchHistory(ctx, backfillScale, begin, now)
+	if err != nil {
+		return nil, err
+	}
+	// Readings up to the last push were pushed live
+	readings := history[:0]
+	for _, reading := range history {
+		if reading.Timestamp > state.LastPush.Unix() {
+			readings = append(readings, reading)
+		}
+	}
+	return readings, nil
+}
+
+// Track records when the pusher last pushed successfully in the state file every interval, and
+// once more when ctx is done; it runs with the intake, so the final push is recorded by calling
+// Record after it
+func (b *Backfill) Track(ctx context.Context, pusher LastPusher, interval time.Duration) {
+	// The pusher reports its creation time until its first push, which must not be recorded
+	b.mu.Lock()
+	b.recorded = pusher.LastPushTime()
+	b.mu.Unlock()
+
+	ticker := b.clock.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			b.Record(pusher)
+			return
+		case <-ticker.C():
+			b.Record(pusher)
+		}
+	}
+}
+
+// Record records the pusher's last successful push in the state file if it pushed since the last record
+func (b *Backfill) Record(pusher LastPusher) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	lastPush := pusher.LastPushTime()
+	if b.recorded.IsZero() || !lastPush.After(b.recorded) {
+		return
+	}
+	if err := b.save(backfillState{LastPush: lastPush}); err != nil {
+		b.logger.Warn("failed to record last push for Netatmo backfill", zap.Error(err))
+		return
+	}
+	b.recorded = lastPush
+}
+
+// load reads the state file; a missing file is an empty state
+func (b *Backfill) load() (backfillState, error) {
+	var state backfillState
+	data, err := os.ReadFile(b.statePath)
+	if os.IsNotExist(err) {
+		return state, nil
+	}
+	if err != nil {
+		return state, fmt.Errorf("failed to read backfill state file: %w", err)
+	}
+	if err := json.Unmarshal(data, &state); err != nil {
+		return state, fmt.Errorf("failed to parse backfill state file: %w", err)
+	}
+	return state, nil
+}
+
+// save persists the state to the state file atomically
+func (b *Backfill) save(state backfillState) error {
+	data, err := json.Marshal(state)
+	if err != nil {
+		return fmt.Errorf("failed to marshal backfill state: %w", err)
+	}
+
+	tmpPath := b.statePath + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0644); err != nil {
+		return fmt.Errorf("failed to write backfill state file: %w", err)
+	}
+	if err := os.Rename(tmpPath, b.statePath); err != nil {
+		return fmt.Errorf("failed to replace backfill state file: %w", err)
+	}
+	return nil
+}
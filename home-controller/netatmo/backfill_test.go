@@ -0,0 +1,103 @@
+package netatmo
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/mjasion/balena-home/thermostats/clock"
+	"go.uber.org/zap"
+)
+
+// lastPush is a pusher reporting when it last pushed
+type lastPush struct {
+	unix atomic.Int64
+}
+
+func (l *lastPush) LastPushTime() time.Time {
+	return time.Unix(l.unix.Load(), 0)
+}
+
+func TestBackfill(t *testing.T) {
+	now := time.Date(2026, 10, 17, 12, 0, 0, 0, time.UTC)
+	var begin string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case tokenPath:
+			w.Write([]byte(`{"access_token":"access","expires_in":10800}`))
+		case homesDataPath:
+			w.Write([]byte(`{"status":"ok","body":{"homes":[{"id":"home","name":"Home","rooms":[{"id":"1","name":"Living Room"}]}]}}`))
+		case roomMeasurePath:
+			begin = r.URL.Query().Get("date_begin")
+			// The first value was pushed live before the downtime; null intervals have no measure
+			w.Write([]byte(`{"status":"ok","body":{
+				"1792231200":[20.5,21],
+				"1792236600":[20.1,21],
+				"1792234800":[null,21],
+				"1792233000":[19.8,17]}}`))
+		}
+	}))
+	defer server.Close()
+
+	fetcher := NewFetcher("id", "secret", "refresh")
+	fetcher.SetBaseURL(server.URL)
+	clk := clock.NewFake(now)
+	backfill := NewBackfill(fetcher, filepath.Join(t.TempDir(), "netatmo_backfill.json"), 2*time.Hour, zap.NewNop())
+	backfill.SetClock(clk)
+
+	// Nothing to backfill before a push was recorded
+	if readings, err := backfill.Readings(context.Background()); err != nil || len(readings) != 0 {
+		t.Fatalf("Expected no readings without a recorded push, got %v, %v", readings, err)
+	}
+
+	// The pusher reports its creation time until the first push, which only is recorded
+	pusher := &lastPush{}
+	pusher.unix.Store(now.Add(-3 * time.Hour).Unix())
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		backfill.Track(ctx, pusher, time.Minute)
+		close(done)
+	}()
+	clk.BlockUntil(1)
+	pusher.unix.Store(1792231200) // 10:00, two hours before the restart
+	cancel()
+	<-done
+
+	readings, err := backfill.Readings(context.Background())
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if begin != "1792231200" {
+		t.Errorf("Expected the history since the last push, got date_begin=%s", begin)
+	}
+	if len(readings) != 2 {
+		t.Fatalf("Expected 2 backfilled readings, got %+v", readings)
+	}
+	if readings[0].Timestamp != 1792233000 || readings[0].MeasuredTemperature != 19.8 || readings[0].SetpointTemperature != 17 || !readings[0].Historical {
+		t.Errorf("Expected the oldest measure first, got %+v", readings[0])
+	}
+	if readings[1].Timestamp != 1792236600 || readings[1].RoomName != "Living Room" {
+		t.Errorf("Expected the newest measure of the living room, got %+v", readings[1])
+	}
+
+	// A gap longer than the window is backfilled only for its end
+	backfill.window = time.Hour
+	if _, err := backfill.Readings(context.Background()); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if begin != "1792234800" {
+		t.Errorf("Expected the history of the last hour, got date_begin=%s", begin)
+	}
+
+	// The final push, after tracking stopped, is recorded by Record
+	pusher.unix.Store(1792234800)
+	backfill.Record(pusher)
+	if state, err := backfill.load(); err != nil || state.LastPush.Unix() != 1792234800 {
+		t.Errorf("Expected the final push to be recorded, got %v, %v", state.LastPush, err)
+	}
+}
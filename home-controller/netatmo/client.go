@@ -8,38 +8,83 @@ import (
 	"io"
 	"net/http"
 	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
+
+	"github.com/mjasion/balena-home/thermostats/httpclient"
+)
+
+// defaultBaseURL is the Netatmo API origin
+const defaultBaseURL = "https://api.netatmo.com"
+
+// API paths relative to the base URL
+const (
+	tokenPath       = "/oauth2/token"
+	homesDataPath   = "/api/homesdata"
+	homeStatusPath  = "/api/homestatus"
+	roomMeasurePath = "/api/getroommeasure"
+	thermModePath   = "/api/setthermmode"
+	roomPointPath   = "/api/setroomthermpoint"
+)
+
+// Token refresh timing
+const (
+	tokenRefreshMargin  = 5 * time.Minute // Refresh tokens expiring within the margin
+	tokenRefreshTimeout = time.Minute     // Bounds a refresh, including the retry of the HTTP client
 )
 
+// Thermostat modes of a home accepted by SetThermMode
 const (
-	baseURL      = "https://api.netatmo.com"
-	tokenURL     = "https://api.netatmo.com/oauth2/token"
-	homesDataURL = "https://api.netatmo.com/api/homesdata"
-	homeStatusURL = "https://api.netatmo.com/api/homestatus"
+	ThermModeSchedule   = "schedule"
+	ThermModeAway       = "away"
+	ThermModeFrostGuard = "hg"
 )
 
 // Client represents a Netatmo API client
 type Client struct {
 	httpClient   *http.Client
+	baseURL      string
 	clientID     string
 	clientSecret string
+
+	// The poller and mode changes from the admin server share the client
+	tokenMu      sync.Mutex
 	refreshToken string
 	accessToken  string
 	tokenExpiry  time.Time
+	refreshing   *tokenRefresh // In-flight refresh shared by concurrent requests, nil when none
+}
+
+// tokenRefresh is a token refresh awaited by one or more requests
+type tokenRefresh struct {
+	done  chan struct{} // Closed once token and err are set
+	token string
+	err   error
 }
 
 // NewClient creates a new Netatmo API client
 func NewClient(clientID, clientSecret, refreshToken string) *Client {
 	return &Client{
-		httpClient: &http.Client{
+		// The API answers the odd request with a 502 or 503; a retry usually succeeds
+		httpClient: httpclient.NewDefault(httpclient.Config{
 			Timeout: 30 * time.Second,
-		},
+			Retry:   httpclient.RetryPolicy{Attempts: 2, Backoff: 2 * time.Second},
+		}),
+		baseURL:      defaultBaseURL,
 		clientID:     clientID,
 		clientSecret: clientSecret,
 		refreshToken: refreshToken,
 	}
 }
 
+// SetBaseURL points the client at another API origin, such as a test server
+func (c *Client) SetBaseURL(baseURL string) {
+	c.baseURL = strings.TrimSuffix(baseURL, "/")
+}
+
 // tokenResponse represents the OAuth2 token response
 type tokenResponse struct {
 	AccessToken  string   `json:"access_token"`
@@ -48,60 +93,93 @@ type tokenResponse struct {
 	Scope        []string `json:"scope"`
 }
 
-// refreshAccessToken refreshes the OAuth2 access token
-func (c *Client) refreshAccessToken(ctx context.Context) error {
+// refreshAccessToken exchanges the refresh token for a new access token
+func (c *Client) refreshAccessToken(ctx context.Context, refreshToken string) (*tokenResponse, error) {
 	data := url.Values{}
 	data.Set("grant_type", "refresh_token")
-	data.Set("refresh_token", c.refreshToken)
+	data.Set("refresh_token", refreshToken)
 	data.Set("client_id", c.clientID)
 	data.Set("client_secret", c.clientSecret)
 
-	req, err := http.NewRequestWithContext(ctx, "POST", tokenURL, bytes.NewBufferString(data.Encode()))
+	req, err := http.NewRequestWithContext(ctx, "POST", c.baseURL+tokenPath, bytes.NewBufferString(data.Encode()))
 	if err != nil {
-		return fmt.Errorf("failed to create token request: %w", err)
+		return nil, fmt.Errorf("failed to create token request: %w", err)
 	}
 
 	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
 
 	resp, err := c.httpClient.Do(req)
 	if err != nil {
-		return fmt.Errorf("failed to refresh token: %w", err)
+		return nil, fmt.Errorf("failed to refresh token: %w", err)
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
 		body, _ := io.ReadAll(resp.Body)
-		return fmt.Errorf("token refresh failed with status %d: %s", resp.StatusCode, string(body))
+		return nil, fmt.Errorf("token refresh failed with status %d: %s", resp.StatusCode, string(body))
 	}
 
 	var tokenResp tokenResponse
 	if err := json.NewDecoder(resp.Body).Decode(&tokenResp); err != nil {
-		return fmt.Errorf("failed to decode token response: %w", err)
+		return nil, fmt.Errorf("failed to decode token response: %w", err)
 	}
+	return &tokenResp, nil
+}
 
-	c.accessToken = tokenResp.AccessToken
-	c.tokenExpiry = time.Now().Add(time.Duration(tokenResp.ExpiresIn) * time.Second)
-
-	// Update refresh token if a new one is provided
-	if tokenResp.RefreshToken != "" {
-		c.refreshToken = tokenResp.RefreshToken
+// ensureToken returns a valid access token, refreshing it first when needed
+// Concurrent requests share one refresh and its outcome, since Netatmo rotates the refresh token
+// and a second refresh with the old one fails; a request whose context ends stops waiting
+// without cancelling the refresh for the others
+func (c *Client) ensureToken(ctx context.Context) (string, error) {
+	c.tokenMu.Lock()
+	if c.accessToken != "" && time.Until(c.tokenExpiry) >= tokenRefreshMargin {
+		token := c.accessToken
+		c.tokenMu.Unlock()
+		return token, nil
 	}
+	refresh := c.refreshing
+	if refresh == nil {
+		refresh = &tokenRefresh{done: make(chan struct{})}
+		c.refreshing = refresh
+		go c.refresh(refresh, c.refreshToken)
+	}
+	c.tokenMu.Unlock()
 
-	return nil
+	select {
+	case <-refresh.done:
+		return refresh.token, refresh.err
+	case <-ctx.Done():
+		return "", ctx.Err()
+	}
 }
 
-// ensureToken ensures we have a valid access token
-func (c *Client) ensureToken(ctx context.Context) error {
-	// Refresh if token is expired or about to expire (within 5 minutes)
-	if c.accessToken == "" || time.Until(c.tokenExpiry) < 5*time.Minute {
-		return c.refreshAccessToken(ctx)
+// refresh performs a refresh awaited by ensureToken and stores the new tokens
+func (c *Client) refresh(refresh *tokenRefresh, refreshToken string) {
+	ctx, cancel := context.WithTimeout(context.Background(), tokenRefreshTimeout)
+	defer cancel()
+	tokenResp, err := c.refreshAccessToken(ctx, refreshToken)
+
+	c.tokenMu.Lock()
+	if err == nil {
+		c.accessToken = tokenResp.AccessToken
+		c.tokenExpiry = time.Now().Add(time.Duration(tokenResp.ExpiresIn) * time.Second)
+
+		// Update refresh token if a new one is provided
+		if tokenResp.RefreshToken != "" {
+			c.refreshToken = tokenResp.RefreshToken
+		}
+		refresh.token = tokenResp.AccessToken
 	}
-	return nil
+	refresh.err = err
+	c.refreshing = nil
+	c.tokenMu.Unlock()
+	close(refresh.done)
 }
 
 // doRequest performs an authenticated API request
 func (c *Client) doRequest(ctx context.Context, method, url string, body io.Reader, result interface{}) error {
-	if err := c.ensureToken(ctx); err != nil {
+	accessToken, err := c.ensureToken(ctx)
+	if err != nil {
 		return fmt.Errorf("failed to ensure token: %w", err)
 	}
 
@@ -110,7 +188,7 @@ func (c *Client) doRequest(ctx context.Context, method, url string, body io.Read
 		return fmt.Errorf("failed to create request: %w", err)
 	}
 
-	req.Header.Set("Authorization", "Bearer "+c.accessToken)
+	req.Header.Set("Authorization", "Bearer "+accessToken)
 	if body != nil {
 		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
 	}
@@ -138,7 +216,7 @@ func (c *Client) doRequest(ctx context.Context, method, url string, body io.Read
 // GetHomesData retrieves homes data including topology
 func (c *Client) GetHomesData(ctx context.Context) (*HomesDataResponse, error) {
 	var response HomesDataResponse
-	if err := c.doRequest(ctx, "GET", homesDataURL, nil, &response); err != nil {
+	if err := c.doRequest(ctx, "GET", c.baseURL+homesDataPath, nil, &response); err != nil {
 		return nil, fmt.Errorf("failed to get homes data: %w", err)
 	}
 	return &response, nil
@@ -146,7 +224,7 @@ func (c *Client) GetHomesData(ctx context.Context) (*HomesDataResponse, error) {
 
 // GetHomeStatus retrieves the current status of a specific home
 func (c *Client) GetHomeStatus(ctx context.Context, homeID string) (*HomeStatusResponse, error) {
-	requestURL := fmt.Sprintf("%s?home_id=%s", homeStatusURL, url.QueryEscape(homeID))
+	requestURL := fmt.Sprintf("%s%s?home_id=%s", c.baseURL, homeStatusPath, url.QueryEscape(homeID))
 
 	var response HomeStatusResponse
 	if err := c.doRequest(ctx, "GET", requestURL, nil, &response); err != nil {
@@ -154,3 +232,80 @@ func (c *Client) GetHomeStatus(ctx context.Context, homeID string) (*HomeStatusR
 	}
 	return &response, nil
 }
+
+// GetRoomMeasure retrieves the measured and setpoint temperatures of a room between begin and end
+// at the given scale, e.g. "30min", stamped when they were measured
+func (c *Client) GetRoomMeasure(ctx context.Context, homeID, roomID, scale string, begin, end time.Time) ([]RoomMeasure, error) {
+	query := url.Values{}
+	query.Set("home_id", homeID)
+	query.Set("room_id", roomID)
+	query.Set("scale", scale)
+	query.Set("type", "temperature,sp_temperature")
+	query.Set("date_begin", strconv.FormatInt(begin.Unix(), 10))
+	query.Set("date_end", strconv.FormatInt(end.Unix(), 10))
+	query.Set("optimize", "false")
+	query.Set("real_time", "true")
+
+	var response RoomMeasureResponse
+	if err := c.doRequest(ctx, "GET", c.baseURL+roomMeasurePath+"?"+query.Encode(), nil, &response); err != nil {
+		return nil, fmt.Errorf("failed to get room measure: %w", err)
+	}
+	if response.Status != "ok" {
+		return nil, fmt.Errorf("room measure request returned status: %s", response.Status)
+	}
+
+	measures := make([]RoomMeasure, 0, len(response.Body))
+	for timestamp, values := range response.Body {
+		unix, err := strconv.ParseInt(timestamp, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid room measure timestamp %q: %w", timestamp, err)
+		}
+		// Intervals without a measured temperature are null
+		if len(values) < 2 || values[0] == nil {
+			continue
+		}
+		measure := RoomMeasure{Timestamp: unix, MeasuredTemperature: *values[0]}
+		if values[1] != nil {
+			measure.SetpointTemperature = *values[1]
+		}
+		measures = append(measures, measure)
+	}
+	sort.Slice(measures, func(i, j int) bool { return measures[i].Timestamp < measures[j].Timestamp })
+	return measures, nil
+}
+
+// SetThermMode switches the heating of a home to its schedule, away or frost guard mode
+func (c *Client) SetThermMode(ctx context.Context, homeID, mode string) error {
+	data := url.Values{}
+	data.Set("home_id", homeID)
+	data.Set("mode", mode)
+
+	var response StatusResponse
+	if err := c.doRequest(ctx, "POST", c.baseURL+thermModePath, strings.NewReader(data.Encode()), &response); err != nil {
+		return fmt.Errorf("failed to set therm mode: %w", err)
+	}
+	if response.Status != "ok" {
+		return fmt.Errorf("set therm mode request returned status: %s", response.Status)
+	}
+	return nil
+}
+
+// SetRoomThermPoint holds a room at a manual setpoint until the given time, after which the room
+// returns to the home's mode
+func (c *Client) SetRoomThermPoint(ctx context.Context, homeID, roomID string, temperature float64, until time.Time) error {
+	data := url.Values{}
+	data.Set("home_id", homeID)
+	data.Set("room_id", roomID)
+	data.Set("mode", "manual")
+	data.Set("temp", strconv.FormatFloat(temperature, 'f', 1, 64))
+	data.Set("endtime", strconv.FormatInt(until.Unix(), 10))
+
+	var response StatusResponse
+	if err := c.doRequest(ctx, "POST", c.baseURL+roomPointPath, strings.NewReader(data.Encode()), &response); err != nil {
+		return fmt.Errorf("failed to set room setpoint: %w", err)
+	}
+	if response.Status != "ok" {
+		return fmt.Errorf("set room setpoint request returned status: %s", response.Status)
+	}
+	return nil
+}
@@ -0,0 +1,106 @@
+package netatmo
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestClient_ConcurrentTokenRefresh(t *testing.T) {
+	var refreshes atomic.Int32
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case tokenPath:
+			refreshes.Add(1)
+			<-release
+			r.ParseForm()
+			if r.PostForm.Get("refresh_token") != "initial-refresh" {
+				t.Errorf("Expected the initial refresh token, got %s", r.PostForm.Get("refresh_token"))
+			}
+			w.Write([]byte(`{"access_token":"access","refresh_token":"rotated-refresh","expires_in":10800}`))
+		case homesDataPath:
+			if r.Header.Get("Authorization") != "Bearer access" {
+				t.Errorf("Expected the refreshed access token, got %s", r.Header.Get("Authorization"))
+			}
+			w.Write([]byte(`{"body":{"homes":[]}}`))
+		}
+	}))
+	defer server.Close()
+
+	client := NewClient("id", "secret", "initial-refresh")
+	client.SetBaseURL(server.URL)
+
+	// A request giving up stops waiting without failing the refresh of the others
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := client.GetHomesData(ctx); err == nil {
+		t.Error("Expected an error for a request whose context ended")
+	}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 10)
+	for range 10 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := client.GetHomesData(context.Background())
+			errs <- err
+		}()
+	}
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Errorf("Expected no error, got %v", err)
+		}
+	}
+	if refreshes.Load() != 1 {
+		t.Errorf("Expected 1 token refresh, got %d", refreshes.Load())
+	}
+	if client.refreshToken != "rotated-refresh" {
+		t.Errorf("Expected the rotated refresh token, got %s", client.refreshToken)
+	}
+}
+
+func TestClient_TokenRefreshFailure(t *testing.T) {
+	var refreshes atomic.Int32
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		refreshes.Add(1)
+		<-release
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer server.Close()
+
+	client := NewClient("id", "secret", "revoked-refresh")
+	client.SetBaseURL(server.URL)
+
+	// Requests waiting on a failed refresh share its error rather than each refreshing again
+	var wg sync.WaitGroup
+	var failures atomic.Int32
+	for range 5 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := client.GetHomesData(context.Background()); err != nil {
+				failures.Add(1)
+			}
+		}()
+	}
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	if failures.Load() != 5 {
+		t.Errorf("Expected 5 failed requests, got %d", failures.Load())
+	}
+	if refreshes.Load() != 1 {
+		t.Errorf("Expected 1 token refresh, got %d", refreshes.Load())
+	}
+}
@@ -3,21 +3,59 @@ package netatmo
 import (
 	"context"
 	"fmt"
+	"sync"
 	"time"
+
+	"github.com/mjasion/balena-home/thermostats/faults"
+	"github.com/mjasion/balena-home/thermostats/telemetry"
+)
+
+// Timestamp sources of thermostat readings
+const (
+	TimestampLocal  = "local"  // The local clock when the status was fetched
+	TimestampServer = "server" // The API's time_server, for a Pi whose clock is off
+	TimestampModule = "module" // When the room's modules last reported, falling back to the server time
 )
 
 // Fetcher fetches thermostat data from Netatmo API
 type Fetcher struct {
-	client *Client
+	client          *Client
+	timestampSource string
+
+	mu        sync.Mutex
+	lastKnown map[string]ThermostatReading // Last reading of each home/room, repeated while its modules report errors
 }
 
 // NewFetcher creates a new Netatmo data fetcher
 func NewFetcher(clientID, clientSecret, refreshToken string) *Fetcher {
 	return &Fetcher{
-		client: NewClient(clientID, clientSecret, refreshToken),
+		client:          NewClient(clientID, clientSecret, refreshToken),
+		timestampSource: TimestampLocal,
+		lastKnown:       make(map[string]ThermostatReading),
 	}
 }
 
+// SetTimestampSource sets where reading timestamps come from: TimestampLocal, TimestampServer
+// or TimestampModule
+func (f *Fetcher) SetTimestampSource(source string) {
+	f.timestampSource = source
+}
+
+// SetRecorder records Netatmo API requests as the "netatmo" dependency
+func (f *Fetcher) SetRecorder(recorder *telemetry.Recorder) {
+	recorder.Instrument(f.client.httpClient, "netatmo")
+}
+
+// SetFaults rate limits Netatmo API requests while the netatmo_429 fault is injected; call before SetRecorder
+func (f *Fetcher) SetFaults(injector *faults.Injector) {
+	injector.Instrument(f.client.httpClient, faults.Netatmo429)
+}
+
+// SetBaseURL points the fetcher at another Netatmo API origin, such as a test server
+func (f *Fetcher) SetBaseURL(baseURL string) {
+	f.client.SetBaseURL(baseURL)
+}
+
 // FetchAllThermostats fetches thermostat data from all homes and rooms
 func (f *Fetcher) FetchAllThermostats(ctx context.Context) ([]ThermostatReading, error) {
 	// First, get homes data to know the topology
@@ -50,7 +88,16 @@ func (f *Fetcher) FetchAllThermostats(ctx context.Context) ([]ThermostatReading,
 		}
 
 		// Process each room's thermostat data
+		errorCodes := roomErrors(home, homeStatus.Body.Errors)
+		reported := make(map[string]bool)
 		timestamp := time.Now().Unix()
+		if f.timestampSource != TimestampLocal && homeStatus.TimeServer > 0 {
+			timestamp = homeStatus.TimeServer
+		}
+		var lastSeen map[string]int64
+		if f.timestampSource == TimestampModule {
+			lastSeen = roomsLastSeen(home, homeStatus.Body.Home.Modules)
+		}
 		for _, roomStatus := range homeStatus.Body.Home.Rooms {
 			roomName, ok := roomNames[roomStatus.ID]
 			if !ok {
@@ -71,9 +118,175 @@ func (f *Fetcher) FetchAllThermostats(ctx context.Context) ([]ThermostatReading,
 				Reachable:           roomStatus.Reachable,
 			}
 
+			if code, ok := errorCodes[roomStatus.ID]; ok {
+				reading.ErrorCode = code
+			}
+			if seen, ok := lastSeen[roomStatus.ID]; ok {
+				reading.Timestamp = seen
+			}
+
 			readings = append(readings, reading)
+			reported[roomStatus.ID] = true
+			f.remember(reading)
 		}
+
+		// Rooms of modules in error are missing from the status; repeat their last known values
+		for _, room := range home.Rooms {
+			code, ok := errorCodes[room.ID]
+			if !ok || reported[room.ID] {
+				continue
+			}
+			reading, known := f.recall(home.ID, room.ID)
+			if !known {
+				reading = ThermostatReading{HomeID: home.ID, RoomID: room.ID, NoValues: true}
+			}
+			reading.Timestamp = timestamp
+			reading.HomeName = home.Name
+			reading.RoomName = room.Name
+			reading.Reachable = false
+			reading.ErrorCode = code
+			reading.Stale = known
+			readings = append(readings, reading)
+		}
+	}
+
+	return readings, nil
+}
+
+// FetchHistory fetches the measured and setpoint temperatures of all rooms between begin and
+// end from their measure history at the given scale
+func (f *Fetcher) FetchHistory(ctx context.Context, scale string, begin, end time.Time) ([]ThermostatReading, error) {
+	homesData, err := f.client.GetHomesData(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get homes data: %w", err)
+	}
+	if homesData.Status != "ok" {
+		return nil, fmt.Errorf("homes data request returned status: %s", homesData.Status)
 	}
 
+	var readings []ThermostatReading
+	for _, home := range homesData.Body.Homes {
+		for _, room := range home.Rooms {
+			measures, err := f.client.GetRoomMeasure(ctx, home.ID, room.ID, scale, begin, end)
+			if err != nil {
+				return nil, fmt.Errorf("failed to get history of room %s: %w", room.Name, err)
+			}
+			for _, measure := range measures {
+				readings = append(readings, ThermostatReading{
+					Timestamp:           measure.Timestamp,
+					HomeID:              home.ID,
+					HomeName:            home.Name,
+					RoomID:              room.ID,
+					RoomName:            room.Name,
+					MeasuredTemperature: measure.MeasuredTemperature,
+					SetpointTemperature: measure.SetpointTemperature,
+					Reachable:           true,
+					Historical:          true,
+				})
+			}
+		}
+	}
 	return readings, nil
 }
+
+// roomErrors maps the rooms of the home's modules in error to an error code; modules bridged by
+// a module in error, e.g. the valves of an unreachable relay, share its code
+func roomErrors(home Home, moduleErrors []ModuleError) map[string]int {
+	rooms := make(map[string]int)
+	if len(moduleErrors) == 0 {
+		return rooms
+	}
+
+	modules := make(map[string]Module, len(home.Modules))
+	for _, module := range home.Modules {
+		modules[module.ID] = module
+	}
+	codes := make(map[string]int)
+	for _, moduleError := range moduleErrors {
+		codes[moduleError.ID] = moduleError.Code
+		for _, bridged := range modules[moduleError.ID].ModuleBridged {
+			if _, ok := codes[bridged]; !ok {
+				codes[bridged] = moduleError.Code
+			}
+		}
+	}
+
+	for _, room := range home.Rooms {
+		for _, moduleID := range room.ModuleIDs {
+			if code, ok := codes[moduleID]; ok {
+				rooms[room.ID] = code
+				break
+			}
+		}
+	}
+	for moduleID, code := range codes {
+		if roomID := modules[moduleID].RoomID; roomID != "" {
+			if _, ok := rooms[roomID]; !ok {
+				rooms[roomID] = code
+			}
+		}
+	}
+	return rooms
+}
+
+// roomsLastSeen maps rooms to when the latest of their modules last reported
+func roomsLastSeen(home Home, modules []ModuleData) map[string]int64 {
+	roomIDs := make(map[string]string)
+	for _, module := range home.Modules {
+		if module.RoomID != "" {
+			roomIDs[module.ID] = module.RoomID
+		}
+	}
+	for _, room := range home.Rooms {
+		for _, moduleID := range room.ModuleIDs {
+			roomIDs[moduleID] = room.ID
+		}
+	}
+
+	rooms := make(map[string]int64)
+	for _, module := range modules {
+		roomID, ok := roomIDs[module.ID]
+		if !ok || module.LastSeen == 0 {
+			continue
+		}
+		if module.LastSeen > rooms[roomID] {
+			rooms[roomID] = module.LastSeen
+		}
+	}
+	return rooms
+}
+
+// remember keeps the reading as the last known values of its room
+func (f *Fetcher) remember(reading ThermostatReading) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.lastKnown[reading.HomeID+"/"+reading.RoomID] = reading
+}
+
+// recall returns the last known values of a room
+func (f *Fetcher) recall(homeID, roomID string) (ThermostatReading, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	reading, ok := f.lastKnown[homeID+"/"+roomID]
+	return reading, ok
+}
+
+// SetThermMode switches the heating of all homes to the given mode, e.g. ThermModeAway while on vacation
+func (f *Fetcher) SetThermMode(ctx context.Context, mode string) error {
+	homesData, err := f.client.GetHomesData(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get homes data: %w", err)
+	}
+
+	for _, home := range homesData.Body.Homes {
+		if err := f.client.SetThermMode(ctx, home.ID, mode); err != nil {
+			return fmt.Errorf("failed to set mode of home %s: %w", home.Name, err)
+		}
+	}
+	return nil
+}
+
+// SetRoomSetpoint holds a room at a manual setpoint until the given time
+func (f *Fetcher) SetRoomSetpoint(ctx context.Context, homeID, roomID string, temperature float64, until time.Time) error {
+	return f.client.SetRoomThermPoint(ctx, homeID, roomID, temperature, until)
+}
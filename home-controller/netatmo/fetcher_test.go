@@ -0,0 +1,116 @@
+package netatmo
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+)
+
+const testHomesData = `{"status":"ok","body":{"homes":[{"id":"home","name":"Home",
+	"modules":[
+		{"id":"relay","type":"NAPlug","modules_bridged":["valve-2"]},
+		{"id":"valve-1","type":"NRV","room_id":"1"},
+		{"id":"valve-2","type":"NRV","room_id":"2"}
+	],
+	"rooms":[
+		{"id":"1","name":"Living Room","module_ids":["valve-1"]},
+		{"id":"2","name":"Bedroom","module_ids":["valve-2"]}
+	]}]}}`
+
+func TestFetcher_ModuleErrors(t *testing.T) {
+	var relayDown atomic.Bool
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case tokenPath:
+			w.Write([]byte(`{"access_token":"access","expires_in":10800}`))
+		case homesDataPath:
+			w.Write([]byte(testHomesData))
+		case homeStatusPath:
+			if relayDown.Load() {
+				w.Write([]byte(`{"status":"ok","body":{"errors":[{"code":6,"id":"relay"}],"home":{"id":"home","rooms":[
+					{"id":"1","reachable":true,"therm_measured_temperature":21.5}]}}}`))
+				return
+			}
+			w.Write([]byte(`{"status":"ok","body":{"home":{"id":"home","rooms":[
+				{"id":"1","reachable":true,"therm_measured_temperature":21},
+				{"id":"2","reachable":true,"therm_measured_temperature":19}]}}}`))
+		}
+	}))
+	defer server.Close()
+
+	fetcher := NewFetcher("id", "secret", "refresh")
+	fetcher.SetBaseURL(server.URL)
+
+	// Before any values are known, a room in error has only its error
+	relayDown.Store(true)
+	readings, err := fetcher.FetchAllThermostats(context.Background())
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(readings) != 2 || !readings[1].NoValues || readings[1].ErrorCode != 6 {
+		t.Fatalf("Expected an error without values for the bedroom, got %+v", readings)
+	}
+
+	relayDown.Store(false)
+	if _, err := fetcher.FetchAllThermostats(context.Background()); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	// The bedroom valve is bridged by the relay in error, so its last known values are repeated
+	relayDown.Store(true)
+	readings, err = fetcher.FetchAllThermostats(context.Background())
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(readings) != 2 {
+		t.Fatalf("Expected 2 readings, got %d", len(readings))
+	}
+	if readings[0].RoomName != "Living Room" || readings[0].Stale || readings[0].ErrorCode != 0 || readings[0].MeasuredTemperature != 21.5 {
+		t.Errorf("Expected a fresh living room reading, got %+v", readings[0])
+	}
+	bedroom := readings[1]
+	if bedroom.RoomName != "Bedroom" || !bedroom.Stale || bedroom.NoValues || bedroom.ErrorCode != 6 || bedroom.Reachable || bedroom.MeasuredTemperature != 19 {
+		t.Errorf("Expected the stale last known bedroom reading with code 6, got %+v", bedroom)
+	}
+}
+
+func TestFetcher_TimestampSource(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case tokenPath:
+			w.Write([]byte(`{"access_token":"access","expires_in":10800}`))
+		case homesDataPath:
+			w.Write([]byte(testHomesData))
+		case homeStatusPath:
+			w.Write([]byte(`{"status":"ok","time_server":1792238400,"body":{"home":{"id":"home",
+				"modules":[{"id":"valve-1","last_seen":1792238100},{"id":"relay","last_seen":1792238390}],
+				"rooms":[{"id":"1","reachable":true},{"id":"2","reachable":true}]}}}`))
+		}
+	}))
+	defer server.Close()
+
+	fetcher := NewFetcher("id", "secret", "refresh")
+	fetcher.SetBaseURL(server.URL)
+
+	tests := []struct {
+		source     string
+		livingRoom int64
+		bedroom    int64
+	}{
+		{TimestampServer, 1792238400, 1792238400},
+		// The bedroom valve reported no last_seen, so the bedroom falls back to the server time
+		{TimestampModule, 1792238100, 1792238400},
+	}
+	for _, tt := range tests {
+		fetcher.SetTimestampSource(tt.source)
+		readings, err := fetcher.FetchAllThermostats(context.Background())
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if len(readings) != 2 || readings[0].Timestamp != tt.livingRoom || readings[1].Timestamp != tt.bedroom {
+			t.Errorf("Expected %d and %d for the %s source, got %+v", tt.livingRoom, tt.bedroom, tt.source, readings)
+		}
+	}
+}
@@ -5,6 +5,8 @@ import (
 	"time"
 
 	"github.com/mjasion/balena-home/thermostats/buffer"
+	"github.com/mjasion/balena-home/thermostats/clock"
+	"github.com/mjasion/balena-home/thermostats/schedule"
 	"go.uber.org/zap"
 )
 
@@ -14,6 +16,9 @@ type Poller struct {
 	buffer       *buffer.RingBuffer
 	logger       *zap.Logger
 	fetchInterval time.Duration
+	skipper       schedule.Skipper
+	clock         clock.Clock
+	backfill      *Backfill // Nil skips the backfill on start
 }
 
 // NewPoller creates a new Netatmo poller
@@ -23,9 +28,25 @@ func NewPoller(fetcher *Fetcher, buf *buffer.RingBuffer, fetchIntervalSeconds in
 		buffer:       buf,
 		logger:       logger,
 		fetchInterval: time.Duration(fetchIntervalSeconds) * time.Second,
+		clock:         clock.Real,
 	}
 }
 
+// SetCadence fetches only every cadence.Multiplier() intervals, e.g. less often while nobody is home
+func (p *Poller) SetCadence(cadence schedule.Cadence) {
+	p.skipper = schedule.NewSkipper(cadence)
+}
+
+// SetBackfill buffers the rooms' history since the last push on start, before the first fetch
+func (p *Poller) SetBackfill(backfill *Backfill) {
+	p.backfill = backfill
+}
+
+// SetClock sets the clock driving the fetch ticker, e.g. a fake one in tests
+func (p *Poller) SetClock(c clock.Clock) {
+	p.clock = c
+}
+
 // Start starts the polling loop
 func (p *Poller) Start(ctx context.Context) {
 	p.logger.Info("starting Netatmo poller",
@@ -33,11 +54,20 @@ func (p *Poller) Start(ctx context.Context) {
 	)
 
 	// Create ticker for periodic fetching
-	ticker := time.NewTicker(p.fetchInterval)
+	ticker := p.clock.NewTicker(p.fetchInterval)
 	defer ticker.Stop()
 
+	// Fill the gap left by downtime, older than the readings fetched next
+	if err := p.backfillAndBuffer(ctx); err != nil {
+		p.logger.Info("buffer closed, stopping Netatmo poller")
+		return
+	}
+
 	// Fetch immediately on start
-	p.fetchAndBuffer(ctx)
+	if err := p.fetchAndBuffer(ctx); err != nil {
+		p.logger.Info("buffer closed, stopping Netatmo poller")
+		return
+	}
 
 	// Then fetch at regular intervals
 	for {
@@ -45,46 +75,47 @@ func (p *Poller) Start(ctx context.Context) {
 		case <-ctx.Done():
 			p.logger.Info("stopping Netatmo poller")
 			return
-		case <-ticker.C:
-			p.fetchAndBuffer(ctx)
+		case <-ticker.C():
+			if p.skipper.Skip() {
+				continue
+			}
+			if err := p.fetchAndBuffer(ctx); err != nil {
+				p.logger.Info("buffer closed, stopping Netatmo poller")
+				return
+			}
 		}
 	}
 }
 
 // fetchAndBuffer fetches thermostat data and adds it to the buffer
-func (p *Poller) fetchAndBuffer(ctx context.Context) {
+// Returns buffer.ErrClosed once the buffer no longer accepts readings; fetch errors are only logged
+func (p *Poller) fetchAndBuffer(ctx context.Context) error {
 	readings, err := p.fetcher.FetchAllThermostats(ctx)
 	if err != nil {
 		p.logger.Error("failed to fetch Netatmo data",
 			zap.Error(err),
 		)
-		return
+		return nil
 	}
 
 	if len(readings) == 0 {
 		p.logger.Debug("no Netatmo readings returned")
-		return
+		return nil
 	}
 
 	// Convert Netatmo readings to buffer readings and add to buffer
 	for _, reading := range readings {
-		bufferReading := &buffer.Reading{
-			Type: buffer.ReadingTypeNetatmo,
-			Thermostat: &buffer.ThermostatReading{
-				Timestamp:           time.Unix(reading.Timestamp, 0),
-				HomeID:              reading.HomeID,
-				HomeName:            reading.HomeName,
-				RoomID:              reading.RoomID,
-				RoomName:            reading.RoomName,
-				MeasuredTemperature: reading.MeasuredTemperature,
-				SetpointTemperature: reading.SetpointTemperature,
-				SetpointMode:        reading.SetpointMode,
-				HeatingPowerRequest: reading.HeatingPowerRequest,
-				OpenWindow:          reading.OpenWindow,
-				Reachable:           reading.Reachable,
-			},
+		if err := p.buffer.Add(toBufferReading(reading)); err != nil {
+			return err
+		}
+		if reading.ErrorCode != 0 {
+			p.logger.Warn("Netatmo room modules report an error",
+				zap.String("home", reading.HomeName),
+				zap.String("room", reading.RoomName),
+				zap.Int("error_code", reading.ErrorCode),
+				zap.Bool("stale", reading.Stale),
+			)
 		}
-		p.buffer.Add(bufferReading)
 
 		p.logger.Debug("added Netatmo reading to buffer",
 			zap.String("home", reading.HomeName),
@@ -99,4 +130,57 @@ func (p *Poller) fetchAndBuffer(ctx context.Context) {
 	p.logger.Info("fetched and buffered Netatmo data",
 		zap.Int("reading_count", len(readings)),
 	)
+	return nil
+}
+
+// backfillAndBuffer adds the rooms' history since the last push to the buffer
+// Returns buffer.ErrClosed once the buffer no longer accepts readings; backfill errors are only logged
+func (p *Poller) backfillAndBuffer(ctx context.Context) error {
+	if p.backfill == nil {
+		return nil
+	}
+	readings, err := p.backfill.Readings(ctx)
+	if err != nil {
+		p.logger.Error("failed to backfill Netatmo history",
+			zap.Error(err),
+		)
+		return nil
+	}
+
+	for _, reading := range readings {
+		if err := p.buffer.Add(toBufferReading(reading)); err != nil {
+			return err
+		}
+	}
+	if len(readings) > 0 {
+		p.logger.Info("backfilled Netatmo history",
+			zap.Int("reading_count", len(readings)),
+			zap.Time("from", time.Unix(readings[0].Timestamp, 0)),
+		)
+	}
+	return nil
+}
+
+// toBufferReading converts a Netatmo reading to a buffer reading
+func toBufferReading(reading ThermostatReading) *buffer.Reading {
+	return &buffer.Reading{
+		Type: buffer.ReadingTypeNetatmo,
+		Thermostat: &buffer.ThermostatReading{
+			Timestamp:           time.Unix(reading.Timestamp, 0),
+			HomeID:              reading.HomeID,
+			HomeName:            reading.HomeName,
+			RoomID:              reading.RoomID,
+			RoomName:            reading.RoomName,
+			MeasuredTemperature: reading.MeasuredTemperature,
+			SetpointTemperature: reading.SetpointTemperature,
+			SetpointMode:        reading.SetpointMode,
+			HeatingPowerRequest: reading.HeatingPowerRequest,
+			OpenWindow:          reading.OpenWindow,
+			Reachable:           reading.Reachable,
+			ErrorCode:           reading.ErrorCode,
+			Stale:               reading.Stale,
+			NoValues:            reading.NoValues,
+			Historical:          reading.Historical,
+		},
+	}
 }
@@ -15,12 +15,33 @@ type HomesDataResponse struct {
 type HomeStatusResponse struct {
 	Status string `json:"status"`
 	Body   struct {
-		Home HomeStatus `json:"home"`
+		Home   HomeStatus    `json:"home"`
+		Errors []ModuleError `json:"errors"` // Modules whose status couldn't be read, e.g. unreachable valves
 	} `json:"body"`
 	TimeExec   float64 `json:"time_exec"`
 	TimeServer int64   `json:"time_server"`
 }
 
+// RoomMeasureResponse represents the response from /api/getroommeasure without optimization: the
+// requested values, measured temperature and setpoint, keyed by Unix timestamp
+type RoomMeasureResponse struct {
+	Status string                `json:"status"`
+	Body   map[string][]*float64 `json:"body"`
+}
+
+// RoomMeasure represents historical values of a room
+type RoomMeasure struct {
+	Timestamp           int64 // Unix timestamp
+	MeasuredTemperature float64
+	SetpointTemperature float64
+}
+
+// StatusResponse represents the response from endpoints changing a home, such as /api/setthermmode
+type StatusResponse struct {
+	Status     string `json:"status"`
+	TimeServer int64  `json:"time_server"`
+}
+
 // Home represents a home's topology and configuration
 type Home struct {
 	ID      string   `json:"id"`
@@ -29,6 +50,13 @@ type Home struct {
 	Rooms   []Room   `json:"rooms"`
 }
 
+// ModuleError represents a module of a home status that reported an error instead of its data
+// The rooms of the module are then missing from the status
+type ModuleError struct {
+	Code int    `json:"code"`
+	ID   string `json:"id"` // Module ID
+}
+
 // HomeStatus represents the current status of a home
 type HomeStatus struct {
 	ID      string       `json:"id"`
@@ -56,6 +84,7 @@ type ModuleData struct {
 	RFStatus            int     `json:"rf_status,omitempty"`
 	BatteryPercent      int     `json:"battery_percent,omitempty"`
 	BatteryState        string  `json:"battery_state,omitempty"`
+	LastSeen            int64   `json:"last_seen,omitempty"` // Unix time the module last reported
 	ThermMeasuredTemperature float64 `json:"therm_measured_temperature,omitempty"`
 	ThermSetpointTemperature float64 `json:"therm_setpoint_temperature,omitempty"`
 }
@@ -116,4 +145,8 @@ type ThermostatReading struct {
 	HeatingPowerRequest  int
 	OpenWindow           bool
 	Reachable            bool
+	ErrorCode            int  // Error code of one of the room's modules, 0 when none reported an error
+	Stale                bool // Last known values repeated while the room's modules report an error
+	NoValues             bool // The room's modules report an error before any values were read; only the error is valid
+	Historical           bool // Backfilled from the room's measure history, with only the temperatures set
 }
@@ -0,0 +1,65 @@
+package network
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+)
+
+// Address families of outbound connections
+const (
+	Dual = "dual" // Both, racing IPv6 and IPv4 addresses with happy eyeballs
+	IPv4 = "v4"   // IPv4 only, e.g. when the ISP's IPv6 is unreliable and dials time out
+	IPv6 = "v6"   // IPv6 only
+)
+
+// DialFunc dials a connection, as http.Transport.DialContext
+type DialFunc func(ctx context.Context, network, address string) (net.Conn, error)
+
+// Validate reports an error unless family is a known address family
+func Validate(family string) error {
+	switch family {
+	case Dual, IPv4, IPv6:
+		return nil
+	}
+	return fmt.Errorf("address family must be '%s', '%s' or '%s', got: %s", Dual, IPv4, IPv6, family)
+}
+
+// TCP returns the dial network of the family: tcp4, tcp6, or tcp for dual
+func TCP(family string) string {
+	switch family {
+	case IPv4:
+		return "tcp4"
+	case IPv6:
+		return "tcp6"
+	}
+	return "tcp"
+}
+
+// Restrict returns a dial function connecting TCP only over the family's addresses
+func Restrict(family string, dial DialFunc) DialFunc {
+	if family == Dual || family == "" {
+		return dial
+	}
+	restricted := TCP(family)
+	return func(ctx context.Context, network, address string) (net.Conn, error) {
+		switch network {
+		case "tcp", "tcp4", "tcp6":
+			network = restricted
+		}
+		return dial(ctx, network, address)
+	}
+}
+
+// Apply restricts connections of http.DefaultTransport, and of transports cloned from it later,
+// to the family; call at startup before clients are created
+// Clients without a transport of their own and the TLS transports of httpauth both use it
+func Apply(family string) {
+	if family == Dual || family == "" {
+		return
+	}
+	dialer := &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}
+	http.DefaultTransport.(*http.Transport).DialContext = Restrict(family, dialer.DialContext)
+}
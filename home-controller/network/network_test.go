@@ -0,0 +1,44 @@
+package network
+
+import (
+	"context"
+	"net"
+	"testing"
+)
+
+func TestRestrict(t *testing.T) {
+	var dialed string
+	dial := func(ctx context.Context, network, address string) (net.Conn, error) {
+		dialed = network
+		return nil, nil
+	}
+
+	tests := []struct {
+		family  string
+		network string
+		want    string
+	}{
+		{Dual, "tcp", "tcp"},
+		{IPv4, "tcp", "tcp4"},
+		{IPv4, "tcp6", "tcp4"},
+		{IPv6, "tcp", "tcp6"},
+		{IPv4, "udp", "udp"},
+	}
+	for _, tt := range tests {
+		Restrict(tt.family, dial)(context.Background(), tt.network, "prometheus.example.com:443")
+		if dialed != tt.want {
+			t.Errorf("Expected %s for %s over %s, got %s", tt.want, tt.network, tt.family, dialed)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	for _, family := range []string{Dual, IPv4, IPv6} {
+		if err := Validate(family); err != nil {
+			t.Errorf("Expected %s to be valid, got %v", family, err)
+		}
+	}
+	if err := Validate("ipv4"); err == nil {
+		t.Error("Expected an error for an unknown family")
+	}
+}
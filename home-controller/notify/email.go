@@ -0,0 +1,119 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/smtp"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// EmailNotifier sends messages over SMTP, upgrading to TLS with STARTTLS when the server offers it
+type EmailNotifier struct {
+	address  string
+	host     string
+	username string
+	password string
+	from     string
+	to       []string
+	now      func() time.Time
+}
+
+// NewEmailNotifier creates a notifier sending from one address to the recipients;
+// an empty username sends without authentication
+func NewEmailNotifier(host string, port int, username, password, from string, to []string) *EmailNotifier {
+	return &EmailNotifier{
+		address:  net.JoinHostPort(host, strconv.Itoa(port)),
+		host:     host,
+		username: username,
+		password: password,
+		from:     from,
+		to:       to,
+		now:      time.Now,
+	}
+}
+
+// Name returns the notifier name used in logs
+func (n *EmailNotifier) Name() string {
+	return "email"
+}
+
+// Send delivers the message as multipart/alternative with the text and HTML bodies
+func (n *EmailNotifier) Send(ctx context.Context, message Message) error {
+	data, err := n.build(message)
+	if err != nil {
+		return err
+	}
+
+	var auth smtp.Auth
+	if n.username != "" {
+		auth = smtp.PlainAuth("", n.username, n.password, n.host)
+	}
+
+	// net/smtp has no context support; run it aside so cancellation returns promptly
+	done := make(chan error, 1)
+	go func() {
+		done <- smtp.SendMail(n.address, auth, n.from, n.to, data)
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			return fmt.Errorf("failed to send e-mail: %w", err)
+		}
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// build renders the message in RFC 5322 format
+func (n *EmailNotifier) build(message Message) ([]byte, error) {
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	parts := []struct {
+		contentType string
+		content     string
+	}{
+		{"text/plain; charset=utf-8", message.Text},
+		{"text/html; charset=utf-8", message.HTML},
+	}
+	for _, part := range parts {
+		if part.content == "" {
+			continue
+		}
+		w, err := writer.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {part.contentType},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to create e-mail part: %w", err)
+		}
+		qp := quotedprintable.NewWriter(w)
+		if _, err := qp.Write([]byte(part.content)); err != nil {
+			return nil, fmt.Errorf("failed to write e-mail part: %w", err)
+		}
+		if err := qp.Close(); err != nil {
+			return nil, fmt.Errorf("failed to write e-mail part: %w", err)
+		}
+	}
+	if err := writer.Close(); err != nil {
+		return nil, fmt.Errorf("failed to finish e-mail: %w", err)
+	}
+
+	var msg bytes.Buffer
+	fmt.Fprintf(&msg, "From: %s\r\n", n.from)
+	fmt.Fprintf(&msg, "To: %s\r\n", strings.Join(n.to, ", "))
+	fmt.Fprintf(&msg, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", message.Subject))
+	fmt.Fprintf(&msg, "Date: %s\r\n", n.now().Format(time.RFC1123Z))
+	msg.WriteString("MIME-Version: 1.0\r\n")
+	fmt.Fprintf(&msg, "Content-Type: multipart/alternative; boundary=%s\r\n\r\n", writer.Boundary())
+	msg.Write(body.Bytes())
+	return msg.Bytes(), nil
+}
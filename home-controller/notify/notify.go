@@ -0,0 +1,32 @@
+package notify
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// Message is a notification with a plain text body and an optional HTML alternative
+type Message struct {
+	Subject string
+	Text    string
+	HTML    string
+}
+
+// Notifier delivers messages to people, e.g. over Telegram or e-mail
+type Notifier interface {
+	Name() string
+	Send(ctx context.Context, message Message) error
+}
+
+// SendAll delivers the message through every notifier, continuing past failures,
+// and returns the failures joined
+func SendAll(ctx context.Context, notifiers []Notifier, message Message) error {
+	var errs []error
+	for _, notifier := range notifiers {
+		if err := notifier.Send(ctx, message); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", notifier.Name(), err))
+		}
+	}
+	return errors.Join(errs...)
+}
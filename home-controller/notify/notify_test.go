@@ -0,0 +1,97 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWebhookNotifier_Send(t *testing.T) {
+	var received webhookMessage
+	var authorization string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authorization = r.Header.Get("Authorization")
+		if err := json.NewDecoder(r.Body).Decode(&received); err != nil {
+			t.Errorf("Expected JSON body, got error %v", err)
+		}
+	}))
+	defer server.Close()
+
+	notifier := NewWebhookNotifier(server.URL, "secret")
+	err := notifier.Send(context.Background(), Message{Subject: "Report", Text: "text", HTML: "<p>html</p>"})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if authorization != "Bearer secret" {
+		t.Errorf("Expected bearer token, got %q", authorization)
+	}
+	if received.Subject != "Report" || received.Text != "text" || received.HTML != "<p>html</p>" {
+		t.Errorf("Expected message fields, got %+v", received)
+	}
+}
+
+func TestWebhookNotifier_ErrorStatus(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "nope", http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	err := NewWebhookNotifier(server.URL, "").Send(context.Background(), Message{Text: "text"})
+	if err == nil || !strings.Contains(err.Error(), "500") {
+		t.Errorf("Expected status code error, got %v", err)
+	}
+}
+
+func TestTelegramNotifier_Send(t *testing.T) {
+	var received telegramMessage
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&received)
+	}))
+	defer server.Close()
+
+	notifier := NewTelegramNotifier("token", "42")
+	notifier.url = server.URL
+	long := strings.Repeat("ä", telegramMaxLength)
+	if err := notifier.Send(context.Background(), Message{Subject: "Report", Text: long}); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if received.ChatID != "42" {
+		t.Errorf("Expected chat ID 42, got %q", received.ChatID)
+	}
+	if !strings.HasPrefix(received.Text, "Report\n\n") {
+		t.Errorf("Expected subject first, got %q", received.Text[:20])
+	}
+	if len(received.Text) > telegramMaxLength+len("…") || !strings.HasSuffix(received.Text, "…") {
+		t.Errorf("Expected text truncated to %d bytes, got %d", telegramMaxLength, len(received.Text))
+	}
+}
+
+func TestEmailNotifier_Build(t *testing.T) {
+	notifier := NewEmailNotifier("smtp.example.com", 587, "", "", "home@example.com", []string{"a@example.com", "b@example.com"})
+	notifier.now = func() time.Time { return time.Date(2026, 3, 10, 7, 0, 0, 0, time.UTC) }
+
+	data, err := notifier.build(Message{Subject: "Raport dzienny – środa", Text: "text body", HTML: "<p>html body</p>"})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	msg := string(data)
+	for _, expected := range []string{
+		"From: home@example.com\r\n",
+		"To: a@example.com, b@example.com\r\n",
+		"Subject: =?utf-8?q?",
+		"Date: Tue, 10 Mar 2026 07:00:00 +0000\r\n",
+		"Content-Type: multipart/alternative; boundary=",
+		"Content-Type: text/plain; charset=utf-8",
+		"Content-Type: text/html; charset=utf-8",
+		"text body",
+		"<p>html body</p>",
+	} {
+		if !strings.Contains(msg, expected) {
+			t.Errorf("Expected message to contain %q, got:\n%s", expected, msg)
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/mjasion/balena-home/thermostats/httpclient"
+	"github.com/mjasion/balena-home/thermostats/telemetry"
+)
+
+// telegramMaxLength is the longest message text the Bot API accepts
+const telegramMaxLength = 4096
+
+// TelegramNotifier sends messages to a chat through a Telegram bot
+type TelegramNotifier struct {
+	url    string
+	chatID string
+	client *http.Client
+}
+
+// NewTelegramNotifier creates a notifier posting to chatID with the bot token
+func NewTelegramNotifier(botToken, chatID string) *TelegramNotifier {
+	return &TelegramNotifier{
+		url:    "https://api.telegram.org/bot" + botToken + "/sendMessage",
+		chatID: chatID,
+		client: httpclient.NewDefault(httpclient.Config{Timeout: 10 * time.Second}),
+	}
+}
+
+// SetRecorder records Bot API requests as the "telegram" dependency
+func (n *TelegramNotifier) SetRecorder(recorder *telemetry.Recorder) {
+	recorder.Instrument(n.client, "telegram")
+}
+
+// Name returns the notifier name used in logs
+func (n *TelegramNotifier) Name() string {
+	return "telegram"
+}
+
+// telegramMessage is the request body of sendMessage
+type telegramMessage struct {
+	ChatID string `json:"chat_id"`
+	Text   string `json:"text"`
+}
+
+// Send posts the subject and plain text body; Telegram's HTML subset can't render the HTML body
+func (n *TelegramNotifier) Send(ctx context.Context, message Message) error {
+	text := message.Text
+	if message.Subject != "" {
+		text = message.Subject + "\n\n" + text
+	}
+	if len(text) > telegramMaxLength {
+		text = strings.ToValidUTF8(text[:telegramMaxLength-1], "") + "…"
+	}
+
+	body, err := json.Marshal(telegramMessage{ChatID: n.chatID, Text: text})
+	if err != nil {
+		return fmt.Errorf("failed to encode message: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, n.url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := n.client.Do(req)
+	if err != nil {
+		// The URL contains the bot token; keep it out of the error
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+		return fmt.Errorf("failed to send message: %w", err)
+	}
+	respBody, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status code %d: %s", resp.StatusCode, string(respBody))
+	}
+	return nil
+}
@@ -0,0 +1,76 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/mjasion/balena-home/thermostats/httpclient"
+	"github.com/mjasion/balena-home/thermostats/telemetry"
+)
+
+// WebhookNotifier posts messages as JSON, e.g. to Home Assistant or a chat integration
+type WebhookNotifier struct {
+	url    string
+	token  string
+	client *http.Client
+}
+
+// NewWebhookNotifier creates a notifier posting to url; a non-empty token is sent as a bearer token
+func NewWebhookNotifier(url, token string) *WebhookNotifier {
+	return &WebhookNotifier{
+		url:    url,
+		token:  token,
+		client: httpclient.NewDefault(httpclient.Config{Timeout: 10 * time.Second}),
+	}
+}
+
+// SetRecorder records webhook requests as the "notify_webhook" dependency
+func (n *WebhookNotifier) SetRecorder(recorder *telemetry.Recorder) {
+	recorder.Instrument(n.client, "notify_webhook")
+}
+
+// Name returns the notifier name used in logs
+func (n *WebhookNotifier) Name() string {
+	return "webhook"
+}
+
+// webhookMessage is the JSON body posted to the webhook
+type webhookMessage struct {
+	Subject string `json:"subject"`
+	Text    string `json:"text"`
+	HTML    string `json:"html,omitempty"`
+}
+
+// Send posts the message
+func (n *WebhookNotifier) Send(ctx context.Context, message Message) error {
+	body, err := json.Marshal(webhookMessage{Subject: message.Subject, Text: message.Text, HTML: message.HTML})
+	if err != nil {
+		return fmt.Errorf("failed to encode message: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, n.url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if n.token != "" {
+		req.Header.Set("Authorization", "Bearer "+n.token)
+	}
+
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send message: %w", err)
+	}
+	respBody, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status code %d: %s", resp.StatusCode, string(respBody))
+	}
+	return nil
+}
@@ -0,0 +1,260 @@
+package occupancy
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mjasion/balena-home/thermostats/buffer"
+	"github.com/mjasion/balena-home/thermostats/events"
+	"go.uber.org/zap"
+)
+
+// Sources of the occupancy state
+const (
+	SourceSchedule = "schedule"
+	SourcePresence = "presence"
+)
+
+// weekdays maps day names used in schedules to weekdays
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday, "mon": time.Monday, "tue": time.Tuesday, "wed": time.Wednesday,
+	"thu": time.Thursday, "fri": time.Friday, "sat": time.Saturday,
+}
+
+// Period is a weekly time range during which someone is home
+type Period struct {
+	Days string // e.g. "mon-fri" or "sat,sun"; empty is every day
+	From string // Local "15:04" time
+	To   string // Local "15:04" time; not after From wraps past midnight
+}
+
+// Validate reports whether the days and times of the period parse
+func (p Period) Validate() error {
+	_, err := parsePeriod(p)
+	return err
+}
+
+// period is a parsed Period
+type period struct {
+	days     [7]bool
+	from, to time.Duration // Offsets from local midnight
+}
+
+// parsePeriod parses the days and times of a period
+func parsePeriod(p Period) (period, error) {
+	var parsed period
+	if strings.TrimSpace(p.Days) == "" {
+		for i := range parsed.days {
+			parsed.days[i] = true
+		}
+	}
+	for _, part := range strings.Split(p.Days, ",") {
+		part = strings.ToLower(strings.TrimSpace(part))
+		if part == "" {
+			continue
+		}
+		first, last, isRange := strings.Cut(part, "-")
+		start, ok := weekdays[first]
+		if !ok {
+			return period{}, fmt.Errorf("unknown day %q, expected mon, tue, wed, thu, fri, sat or sun", first)
+		}
+		end := start
+		if isRange {
+			if end, ok = weekdays[last]; !ok {
+				return period{}, fmt.Errorf("unknown day %q, expected mon, tue, wed, thu, fri, sat or sun", last)
+			}
+		}
+		// Ranges may wrap around the week, e.g. fri-mon
+		for day := start; ; day = (day + 1) % 7 {
+			parsed.days[day] = true
+			if day == end {
+				break
+			}
+		}
+	}
+
+	for _, clock := range []struct {
+		value  string
+		offset *time.Duration
+	}{{p.From, &parsed.from}, {p.To, &parsed.to}} {
+		at, err := time.Parse("15:04", clock.value)
+		if err != nil {
+			return period{}, fmt.Errorf("invalid time %q, expected HH:MM", clock.value)
+		}
+		*clock.offset = time.Duration(at.Hour())*time.Hour + time.Duration(at.Minute())*time.Minute
+	}
+	return parsed, nil
+}
+
+// contains reports whether the local time falls in the period; a period wrapping past midnight
+// belongs to the day it starts on
+func (p period) contains(t time.Time) bool {
+	year, month, day := t.Date()
+	offset := t.Sub(time.Date(year, month, day, 0, 0, 0, 0, t.Location()))
+	if p.from < p.to {
+		return p.days[t.Weekday()] && offset >= p.from && offset < p.to
+	}
+	yesterday := (t.Weekday() + 6) % 7
+	return (p.days[t.Weekday()] && offset >= p.from) || (p.days[yesterday] && offset < p.to)
+}
+
+// Presence reports the beacons currently located; locator.Locator implements it
+type Presence interface {
+	PresentBeacons() []string
+}
+
+// Tracker decides whether someone is home from a weekly schedule or from presence beacons and
+// adds an occupancy_state reading every interval; while away, pollers following its cadence
+// scrape less often and expression rule webhooks can be muted
+type Tracker struct {
+	source         string
+	periods        []period
+	presence       Presence
+	awayAfter      time.Duration
+	awayMultiplier int
+	buffer         *buffer.RingBuffer
+	interval       time.Duration
+	eventLog       *events.Log
+	logger         *zap.Logger
+	now            func() time.Time
+
+	mu       sync.Mutex
+	home     bool
+	lastSeen time.Time // Last time a beacon was present
+}
+
+// New creates a tracker; with the presence source the home is left once no beacon was located
+// for awayAfterSeconds, and pollers run every awayMultiplier intervals while away.
+// The state starts as home, so nothing is throttled or muted before the first evaluation.
+func New(source string, periods []Period, presence Presence, awayAfterSeconds, awayMultiplier int, buf *buffer.RingBuffer, intervalSeconds int, logger *zap.Logger) (*Tracker, error) {
+	parsed := make([]period, len(periods))
+	for i, p := range periods {
+		var err error
+		if parsed[i], err = parsePeriod(p); err != nil {
+			return nil, fmt.Errorf("period %d: %w", i, err)
+		}
+	}
+	if source == SourcePresence && presence == nil {
+		return nil, fmt.Errorf("presence source requires a locator with beacons")
+	}
+	return &Tracker{
+		source:         source,
+		periods:        parsed,
+		presence:       presence,
+		awayAfter:      time.Duration(awayAfterSeconds) * time.Second,
+		awayMultiplier: max(awayMultiplier, 1),
+		buffer:         buf,
+		interval:       time.Duration(intervalSeconds) * time.Second,
+		logger:         logger,
+		now:            time.Now,
+		home:           true,
+		lastSeen:       time.Now(), // Beacons get awayAfter to be located after a restart
+	}, nil
+}
+
+// SetEventLog sets the event log used to record arrivals and departures
+func (t *Tracker) SetEventLog(eventLog *events.Log) {
+	t.eventLog = eventLog
+}
+
+// Home reports whether someone is home
+func (t *Tracker) Home() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.home
+}
+
+// Multiplier returns the number of intervals between scrapes: 1 at home, more while away
+func (t *Tracker) Multiplier() int {
+	if t.Home() {
+		return 1
+	}
+	return t.awayMultiplier
+}
+
+// Start evaluates the state immediately and then every interval until the context is cancelled
+func (t *Tracker) Start(ctx context.Context) {
+	t.logger.Info("starting occupancy tracker",
+		zap.String("source", t.source),
+		zap.Int("period_count", len(t.periods)),
+		zap.Duration("interval", t.interval),
+	)
+
+	ticker := time.NewTicker(t.interval)
+	defer ticker.Stop()
+
+	if err := t.update(); err != nil {
+		t.logger.Info("buffer closed, stopping occupancy tracker")
+		return
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			t.logger.Info("stopping occupancy tracker")
+			return
+		case <-ticker.C:
+			if err := t.update(); err != nil {
+				t.logger.Info("buffer closed, stopping occupancy tracker")
+				return
+			}
+		}
+	}
+}
+
+// update evaluates the state, records a change and adds the current state to the buffer
+// Returns buffer.ErrClosed once the buffer no longer accepts readings
+func (t *Tracker) update() error {
+	now := t.now()
+	t.mu.Lock()
+	home, reason := t.evaluate(now)
+	changed := home != t.home
+	t.home = home
+	t.mu.Unlock()
+
+	if changed {
+		if home {
+			t.logger.Info("someone is home, resuming full cadence", zap.String("reason", reason))
+			t.eventLog.Record(events.TypeOccupancyHome, "occupancy", "someone is home", map[string]string{"reason": reason})
+		} else {
+			t.logger.Info("nobody is home, reducing cadence",
+				zap.String("reason", reason),
+				zap.Int("scrape_multiplier", t.awayMultiplier),
+			)
+			t.eventLog.Record(events.TypeOccupancyAway, "occupancy", "nobody is home", map[string]string{"reason": reason})
+		}
+	}
+
+	return t.buffer.Add(&buffer.Reading{
+		Type: buffer.ReadingTypeOccupancy,
+		Occupancy: &buffer.OccupancyReading{
+			Timestamp: now,
+			Home:      home,
+			Source:    t.source,
+		},
+	})
+}
+
+// evaluate returns whether someone is home and why; the caller must hold the lock
+func (t *Tracker) evaluate(now time.Time) (bool, string) {
+	if t.source == SourcePresence {
+		if beacons := t.presence.PresentBeacons(); len(beacons) > 0 {
+			t.lastSeen = now
+			return true, "beacons present: " + strings.Join(beacons, ", ")
+		}
+		if now.Sub(t.lastSeen) < t.awayAfter {
+			return true, "beacons recently present"
+		}
+		return false, "no beacons present"
+	}
+
+	local := now.Local()
+	for _, p := range t.periods {
+		if p.contains(local) {
+			return true, "scheduled home"
+		}
+	}
+	return false, "scheduled away"
+}
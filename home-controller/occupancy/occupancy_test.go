@@ -0,0 +1,97 @@
+package occupancy
+
+import (
+	"testing"
+	"time"
+
+	"github.com/mjasion/balena-home/thermostats/buffer"
+	"go.uber.org/zap"
+)
+
+type fakePresence struct {
+	beacons []string
+}
+
+func (p *fakePresence) PresentBeacons() []string {
+	return p.beacons
+}
+
+func TestPeriodContains(t *testing.T) {
+	// 2026-03-09 is a Monday
+	at := func(day int, clock string) time.Time {
+		parsed, _ := time.Parse("15:04", clock)
+		return time.Date(2026, 3, day, parsed.Hour(), parsed.Minute(), 0, 0, time.UTC)
+	}
+
+	tests := []struct {
+		period   Period
+		at       time.Time
+		expected bool
+	}{
+		{Period{Days: "mon-fri", From: "16:00", To: "23:00"}, at(9, "17:30"), true},
+		{Period{Days: "mon-fri", From: "16:00", To: "23:00"}, at(9, "23:00"), false},
+		{Period{Days: "mon-fri", From: "16:00", To: "23:00"}, at(14, "17:30"), false}, // Saturday
+		{Period{Days: "sat,sun", From: "00:00", To: "00:00"}, at(15, "12:00"), true},  // All day Sunday
+		{Period{Days: "fri-mon", From: "09:00", To: "10:00"}, at(15, "09:30"), true},  // Wraps around the week
+		{Period{Days: "fri-mon", From: "09:00", To: "10:00"}, at(11, "09:30"), false},
+		{Period{Days: "fri", From: "22:00", To: "07:00"}, at(14, "06:00"), true}, // Friday night into Saturday
+		{Period{Days: "fri", From: "22:00", To: "07:00"}, at(13, "06:00"), false},
+		{Period{From: "08:00", To: "09:00"}, at(12, "08:15"), true}, // Every day
+	}
+	for _, tt := range tests {
+		p, err := parsePeriod(tt.period)
+		if err != nil {
+			t.Fatalf("Failed to parse %+v: %v", tt.period, err)
+		}
+		if got := p.contains(tt.at); got != tt.expected {
+			t.Errorf("%+v at %s: expected %v, got %v", tt.period, tt.at.Format("Mon 15:04"), tt.expected, got)
+		}
+	}
+
+	for _, invalid := range []Period{{Days: "monday", From: "08:00", To: "09:00"}, {From: "8am", To: "09:00"}} {
+		if err := invalid.Validate(); err == nil {
+			t.Errorf("Expected an error for %+v", invalid)
+		}
+	}
+}
+
+func TestTracker_Presence(t *testing.T) {
+	buf := buffer.New(10, zap.NewNop())
+	presence := &fakePresence{beacons: []string{"keys"}}
+	tracker, err := New(SourcePresence, nil, presence, 600, 4, buf, 60, zap.NewNop())
+	if err != nil {
+		t.Fatalf("Failed to create tracker: %v", err)
+	}
+	now := time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC)
+	tracker.now = func() time.Time { return now }
+
+	tracker.update()
+	if !tracker.Home() || tracker.Multiplier() != 1 {
+		t.Errorf("Expected home at full cadence with a beacon present")
+	}
+
+	// Beacons briefly out of range don't count as leaving
+	presence.beacons = nil
+	now = now.Add(5 * time.Minute)
+	tracker.update()
+	if !tracker.Home() {
+		t.Error("Expected to stay home within the away delay")
+	}
+
+	now = now.Add(6 * time.Minute)
+	tracker.update()
+	if tracker.Home() || tracker.Multiplier() != 4 {
+		t.Errorf("Expected away with a 4x multiplier, got multiplier %d", tracker.Multiplier())
+	}
+
+	readings := buf.GetAll()
+	if len(readings) != 3 || !readings[0].Occupancy.Home || readings[2].Occupancy.Home || readings[2].Occupancy.Source != SourcePresence {
+		t.Errorf("Expected home, home and away readings from presence, got %d readings", len(readings))
+	}
+}
+
+func TestNew_PresenceRequiresLocator(t *testing.T) {
+	if _, err := New(SourcePresence, nil, nil, 600, 4, buffer.New(10, zap.NewNop()), 60, zap.NewNop()); err == nil {
+		t.Error("Expected an error for the presence source without a locator")
+	}
+}
@@ -0,0 +1,38 @@
+package onewire
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/mjasion/balena-home/thermostats/collector"
+)
+
+// Options are the settings of the onewire collector
+type Options struct {
+	DevicesPath string `yaml:"devicesPath"` // Defaults to /sys/bus/w1/devices
+	Sensors     []struct {
+		Name     string `yaml:"name"`
+		ID       int    `yaml:"id"`
+		DeviceID string `yaml:"deviceId"`
+	} `yaml:"sensors"`
+}
+
+func init() {
+	collector.Register("onewire", func(spec collector.Spec, deps collector.Deps) (collector.Collector, error) {
+		options := Options{DevicesPath: "/sys/bus/w1/devices"}
+		if err := spec.Options.Decode(&options); err != nil {
+			return nil, err
+		}
+		if len(options.Sensors) == 0 {
+			return nil, fmt.Errorf("at least one 1-Wire sensor must be configured")
+		}
+		sensors := make([]SensorConfig, len(options.Sensors))
+		for i, sensor := range options.Sensors {
+			sensors[i] = SensorConfig{Name: sensor.Name, ID: sensor.ID, DeviceID: strings.ToLower(sensor.DeviceID)}
+		}
+
+		poller := NewPoller(sensors, options.DevicesPath, deps.Buffer, int(spec.Interval.Seconds()), deps.Logger)
+		poller.SetCadence(deps.Cadence)
+		return poller, nil
+	})
+}
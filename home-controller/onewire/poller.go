@@ -0,0 +1,122 @@
+package onewire
+
+import (
+	"context"
+	"time"
+
+	"github.com/mjasion/balena-home/thermostats/buffer"
+	"github.com/mjasion/balena-home/thermostats/clock"
+	"github.com/mjasion/balena-home/thermostats/schedule"
+	"go.uber.org/zap"
+)
+
+// Poller periodically reads DS18B20 sensors and adds readings to the buffer
+type Poller struct {
+	sensors      []SensorConfig
+	devicesPath  string
+	buffer       *buffer.RingBuffer
+	logger       *zap.Logger
+	readInterval time.Duration
+	skipper      schedule.Skipper
+	clock        clock.Clock
+}
+
+// NewPoller creates a new 1-Wire poller
+func NewPoller(sensors []SensorConfig, devicesPath string, buf *buffer.RingBuffer, readIntervalSeconds int, logger *zap.Logger) *Poller {
+	return &Poller{
+		sensors:      sensors,
+		devicesPath:  devicesPath,
+		buffer:       buf,
+		logger:       logger,
+		readInterval: time.Duration(readIntervalSeconds) * time.Second,
+		clock:        clock.Real,
+	}
+}
+
+// SetCadence reads only every cadence.Multiplier() intervals, e.g. less often while nobody is home
+func (p *Poller) SetCadence(cadence schedule.Cadence) {
+	p.skipper = schedule.NewSkipper(cadence)
+}
+
+// SetClock sets the clock driving the read ticker and reading timestamps, e.g. a fake one in tests
+func (p *Poller) SetClock(c clock.Clock) {
+	p.clock = c
+}
+
+// Start starts the polling loop
+func (p *Poller) Start(ctx context.Context) {
+	p.logger.Info("starting 1-Wire poller",
+		zap.Duration("read_interval", p.readInterval),
+		zap.Int("sensor_count", len(p.sensors)),
+	)
+
+	// Create ticker for periodic reading
+	ticker := p.clock.NewTicker(p.readInterval)
+	defer ticker.Stop()
+
+	// Read immediately on start
+	if err := p.readAndBuffer(); err != nil {
+		p.logger.Info("buffer closed, stopping 1-Wire poller")
+		return
+	}
+
+	// Then read at regular intervals
+	for {
+		select {
+		case <-ctx.Done():
+			p.logger.Info("stopping 1-Wire poller")
+			return
+		case <-ticker.C():
+			if p.skipper.Skip() {
+				continue
+			}
+			if err := p.readAndBuffer(); err != nil {
+				p.logger.Info("buffer closed, stopping 1-Wire poller")
+				return
+			}
+		}
+	}
+}
+
+// readAndBuffer reads all configured sensors and adds the readings to the buffer
+// Returns buffer.ErrClosed once the buffer no longer accepts readings
+func (p *Poller) readAndBuffer() error {
+	count := 0
+	for _, sensor := range p.sensors {
+		temperature, err := ReadTemperature(p.devicesPath, sensor.DeviceID)
+		if err != nil {
+			p.logger.Warn("failed to read 1-Wire sensor",
+				zap.String("sensor_name", sensor.Name),
+				zap.String("device_id", sensor.DeviceID),
+				zap.Error(err),
+			)
+			continue
+		}
+
+		err = p.buffer.Add(&buffer.Reading{
+			Type: buffer.ReadingTypeOneWire,
+			OneWire: &buffer.OneWireReading{
+				Timestamp:          p.clock.Now(),
+				DeviceID:           sensor.DeviceID,
+				SensorName:         sensor.Name,
+				SensorID:           sensor.ID,
+				TemperatureCelsius: temperature,
+			},
+		})
+		if err != nil {
+			return err
+		}
+		count++
+
+		p.logger.Debug("added 1-Wire reading to buffer",
+			zap.String("sensor_name", sensor.Name),
+			zap.Int("sensor_id", sensor.ID),
+			zap.Float64("temperature_celsius", temperature),
+		)
+	}
+
+	p.logger.Info("read and buffered 1-Wire data",
+		zap.Int("reading_count", count),
+	)
+	return nil
+}
@@ -0,0 +1,62 @@
+package onewire
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/mjasion/balena-home/thermostats/units"
+)
+
+// powerOnResetMilliC is the value a DS18B20 reports before its first conversion
+const powerOnResetMilliC = 85000
+
+// SensorConfig represents configuration for a single DS18B20 sensor
+type SensorConfig struct {
+	Name     string
+	ID       int
+	DeviceID string // 1-Wire device directory name, e.g. 28-0316a2796bff
+}
+
+// ReadTemperature reads a DS18B20 temperature from the w1_slave file of the device
+// Format (2 lines):
+// - Line 1: raw scratchpad bytes followed by "crc=XX YES" (or NO on CRC failure)
+// - Line 2: raw scratchpad bytes followed by "t=<temperature in milli-°C>"
+func ReadTemperature(devicesPath, deviceID string) (float64, error) {
+	data, err := os.ReadFile(filepath.Join(devicesPath, deviceID, "w1_slave"))
+	if err != nil {
+		return 0, fmt.Errorf("failed to read device %s: %w", deviceID, err)
+	}
+
+	return parseW1Slave(string(data))
+}
+
+// parseW1Slave parses the contents of a w1_slave file
+func parseW1Slave(content string) (float64, error) {
+	lines := strings.Split(strings.TrimSpace(content), "\n")
+	if len(lines) < 2 {
+		return 0, fmt.Errorf("invalid w1_slave content: expected 2 lines, got %d", len(lines))
+	}
+
+	if !strings.HasSuffix(strings.TrimSpace(lines[0]), "YES") {
+		return 0, fmt.Errorf("CRC check failed")
+	}
+
+	idx := strings.LastIndex(lines[1], "t=")
+	if idx < 0 {
+		return 0, fmt.Errorf("temperature not found in w1_slave content")
+	}
+
+	milliC, err := strconv.Atoi(strings.TrimSpace(lines[1][idx+2:]))
+	if err != nil {
+		return 0, fmt.Errorf("invalid temperature value: %w", err)
+	}
+
+	if milliC == powerOnResetMilliC {
+		return 0, fmt.Errorf("sensor returned power-on reset value (85°C)")
+	}
+
+	return units.Convert(float64(milliC), "mC", units.Celsius)
+}
@@ -0,0 +1,78 @@
+package onewire
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestParseW1Slave_Valid(t *testing.T) {
+	content := "72 01 4b 46 7f ff 0e 10 57 : crc=57 YES\n72 01 4b 46 7f ff 0e 10 57 t=23125\n"
+
+	temperature, err := parseW1Slave(content)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	if temperature != 23.125 {
+		t.Errorf("Expected temperature 23.125, got %f", temperature)
+	}
+}
+
+func TestParseW1Slave_Negative(t *testing.T) {
+	content := "5e ff 4b 46 7f ff 02 10 1d : crc=1d YES\n5e ff 4b 46 7f ff 02 10 1d t=-10125\n"
+
+	temperature, err := parseW1Slave(content)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	if temperature != -10.125 {
+		t.Errorf("Expected temperature -10.125, got %f", temperature)
+	}
+}
+
+func TestParseW1Slave_Errors(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+	}{
+		{name: "crc failure", content: "72 01 4b 46 7f ff 0e 10 57 : crc=57 NO\n72 01 4b 46 7f ff 0e 10 57 t=23125\n"},
+		{name: "power-on reset", content: "50 05 4b 46 7f ff 0c 10 1c : crc=1c YES\n50 05 4b 46 7f ff 0c 10 1c t=85000\n"},
+		{name: "single line", content: "72 01 4b 46 7f ff 0e 10 57 : crc=57 YES\n"},
+		{name: "missing temperature", content: "72 01 4b 46 7f ff 0e 10 57 : crc=57 YES\n72 01 4b 46 7f ff 0e 10 57\n"},
+		{name: "empty", content: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := parseW1Slave(tt.content); err == nil {
+				t.Error("Expected error, got nil")
+			}
+		})
+	}
+}
+
+func TestReadTemperature(t *testing.T) {
+	devicesPath := t.TempDir()
+	devicePath := filepath.Join(devicesPath, "28-0316a2796bff")
+	if err := os.MkdirAll(devicePath, 0755); err != nil {
+		t.Fatalf("Failed to create fake device directory: %v", err)
+	}
+	content := "72 01 4b 46 7f ff 0e 10 57 : crc=57 YES\n72 01 4b 46 7f ff 0e 10 57 t=23125\n"
+	if err := os.WriteFile(filepath.Join(devicePath, "w1_slave"), []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to create fake w1_slave: %v", err)
+	}
+
+	temperature, err := ReadTemperature(devicesPath, "28-0316a2796bff")
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if temperature != 23.125 {
+		t.Errorf("Expected temperature 23.125, got %f", temperature)
+	}
+
+	if _, err := ReadTemperature(devicesPath, "28-000000000000"); err == nil {
+		t.Error("Expected error for missing device, got nil")
+	}
+}
@@ -5,27 +5,59 @@ import (
 	"time"
 
 	"github.com/mjasion/balena-home/thermostats/buffer"
+	"github.com/mjasion/balena-home/thermostats/clock"
+	"github.com/mjasion/balena-home/thermostats/schedule"
 	"go.uber.org/zap"
 )
 
+// Observer is notified of every successfully scraped power reading
+type Observer interface {
+	ObservePower(reading ActivePowerReading)
+}
+
 // Poller periodically scrapes power meter data and adds it to the buffer
 type Poller struct {
 	scraper        *Scraper
 	buffer         *buffer.RingBuffer
 	logger         *zap.Logger
 	scrapeInterval time.Duration
+	maxStale       int                  // Max intervals to repeat the last known value (0 disables)
+	lastReadings   []ActivePowerReading // Last successfully scraped readings
+	staleCount     int                  // Consecutive intervals served from lastReadings
+	observers      []Observer
+	skipper        schedule.Skipper
+	clock          clock.Clock
 }
 
 // NewPoller creates a new power meter poller
-func NewPoller(scraper *Scraper, buf *buffer.RingBuffer, scrapeIntervalSeconds int, logger *zap.Logger) *Poller {
+// staleRepeatIntervals controls how many consecutive failed scrapes are filled
+// with the last known value; 0 disables last-known-value handling
+func NewPoller(scraper *Scraper, buf *buffer.RingBuffer, scrapeIntervalSeconds, staleRepeatIntervals int, logger *zap.Logger) *Poller {
 	return &Poller{
 		scraper:        scraper,
 		buffer:         buf,
 		logger:         logger,
 		scrapeInterval: time.Duration(scrapeIntervalSeconds) * time.Second,
+		maxStale:       staleRepeatIntervals,
+		clock:          clock.Real,
 	}
 }
 
+// SetCadence scrapes only every cadence.Multiplier() intervals, e.g. less often while nobody is home
+func (p *Poller) SetCadence(cadence schedule.Cadence) {
+	p.skipper = schedule.NewSkipper(cadence)
+}
+
+// SetClock sets the clock driving the scrape ticker and reading timestamps, e.g. a fake one in tests
+func (p *Poller) SetClock(c clock.Clock) {
+	p.clock = c
+}
+
+// AddObserver registers an observer of scraped readings; must be called before Start
+func (p *Poller) AddObserver(observer Observer) {
+	p.observers = append(p.observers, observer)
+}
+
 // Start starts the polling loop
 func (p *Poller) Start(ctx context.Context) {
 	p.logger.Info("starting power meter poller",
@@ -33,11 +65,14 @@ func (p *Poller) Start(ctx context.Context) {
 	)
 
 	// Create ticker for periodic scraping
-	ticker := time.NewTicker(p.scrapeInterval)
+	ticker := p.clock.NewTicker(p.scrapeInterval)
 	defer ticker.Stop()
 
 	// Scrape immediately on start
-	p.scrapeAndBuffer(ctx)
+	if err := p.scrapeAndBuffer(ctx); err != nil {
+		p.logger.Info("buffer closed, stopping power meter poller")
+		return
+	}
 
 	// Then scrape at regular intervals
 	for {
@@ -45,25 +80,36 @@ func (p *Poller) Start(ctx context.Context) {
 		case <-ctx.Done():
 			p.logger.Info("stopping power meter poller")
 			return
-		case <-ticker.C:
-			p.scrapeAndBuffer(ctx)
+		case <-ticker.C():
+			if p.skipper.Skip() {
+				continue
+			}
+			if err := p.scrapeAndBuffer(ctx); err != nil {
+				p.logger.Info("buffer closed, stopping power meter poller")
+				return
+			}
 		}
 	}
 }
 
 // scrapeAndBuffer scrapes power meter data and adds it to the buffer
-func (p *Poller) scrapeAndBuffer(ctx context.Context) {
+// Returns buffer.ErrClosed once the buffer no longer accepts readings; scrape errors are only logged
+func (p *Poller) scrapeAndBuffer(ctx context.Context) error {
 	result, err := p.scraper.Scrape(ctx)
 	if err != nil {
 		p.logger.Error("failed to scrape power meter data",
 			zap.Error(err),
 		)
-		return
+		return p.bufferStale()
 	}
 
+	// Remember the readings so they can be repeated if the next scrapes fail
+	p.lastReadings = result.Readings
+	p.staleCount = 0
+
 	if len(result.Readings) == 0 {
 		p.logger.Debug("no power readings returned")
-		return
+		return nil
 	}
 
 	// Convert power readings to buffer readings and add to buffer
@@ -76,7 +122,13 @@ func (p *Poller) scrapeAndBuffer(ctx context.Context) {
 				Value:     reading.Value,
 			},
 		}
-		p.buffer.Add(bufferReading)
+		if err := p.buffer.Add(bufferReading); err != nil {
+			return err
+		}
+
+		for _, observer := range p.observers {
+			observer.ObservePower(reading)
+		}
 
 		p.logger.Debug("added power reading to buffer",
 			zap.Int("sensor_id", reading.SensorID),
@@ -88,4 +140,44 @@ func (p *Poller) scrapeAndBuffer(ctx context.Context) {
 	p.logger.Info("scraped and buffered power meter data",
 		zap.Int("reading_count", len(result.Readings)),
 	)
+	return nil
+}
+
+// bufferStale repeats the last known readings, marked as stale, for up to
+// maxStale consecutive failed scrapes
+func (p *Poller) bufferStale() error {
+	if p.maxStale == 0 || len(p.lastReadings) == 0 {
+		return nil
+	}
+
+	if p.staleCount >= p.maxStale {
+		p.logger.Debug("last known power readings expired, leaving gap",
+			zap.Int("stale_intervals", p.staleCount),
+		)
+		return nil
+	}
+	p.staleCount++
+
+	now := p.clock.Now()
+	for _, reading := range p.lastReadings {
+		err := p.buffer.Add(&buffer.Reading{
+			Type: buffer.ReadingTypePower,
+			Power: &buffer.PowerReading{
+				Timestamp: now,
+				SensorID:  reading.SensorID,
+				Value:     reading.Value,
+				Stale:     true,
+			},
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	p.logger.Warn("buffered last known power readings as stale",
+		zap.Int("reading_count", len(p.lastReadings)),
+		zap.Int("stale_intervals", p.staleCount),
+		zap.Int("max_stale_intervals", p.maxStale),
+	)
+	return nil
 }
@@ -0,0 +1,93 @@
+package power
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/mjasion/balena-home/thermostats/buffer"
+	"go.uber.org/zap"
+)
+
+func TestPoller_BufferStale(t *testing.T) {
+	logger := zap.NewNop()
+	buf := buffer.New(100, logger)
+	poller := NewPoller(nil, buf, 1, 2, logger)
+	poller.lastReadings = []ActivePowerReading{
+		{SensorID: 0, Value: 89},
+		{SensorID: 1, Value: 73},
+	}
+
+	// Two failed scrapes are filled with the last known value, the third leaves a gap
+	for i := 0; i < 3; i++ {
+		poller.bufferStale()
+	}
+
+	readings := buf.GetAll()
+	if len(readings) != 4 {
+		t.Fatalf("Expected 4 stale readings, got %d", len(readings))
+	}
+
+	for _, reading := range readings {
+		if reading.Type != buffer.ReadingTypePower {
+			t.Errorf("Expected power reading, got %s", reading.Type)
+		}
+		if !reading.Power.Stale {
+			t.Errorf("Expected reading for sensor %d to be marked stale", reading.Power.SensorID)
+		}
+	}
+}
+
+func TestPoller_BufferStale_Disabled(t *testing.T) {
+	logger := zap.NewNop()
+	buf := buffer.New(100, logger)
+	poller := NewPoller(nil, buf, 1, 0, logger)
+	poller.lastReadings = []ActivePowerReading{{SensorID: 0, Value: 89}}
+
+	poller.bufferStale()
+
+	if buf.Size() != 0 {
+		t.Errorf("Expected no readings when stale handling is disabled, got %d", buf.Size())
+	}
+}
+
+func TestPoller_BufferStale_NoLastReadings(t *testing.T) {
+	logger := zap.NewNop()
+	buf := buffer.New(100, logger)
+	poller := NewPoller(nil, buf, 1, 3, logger)
+
+	poller.bufferStale()
+
+	if buf.Size() != 0 {
+		t.Errorf("Expected no readings before the first successful scrape, got %d", buf.Size())
+	}
+}
+
+func TestPoller_StopsWhenBufferClosed(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(fullSampleJSON))
+	}))
+	defer server.Close()
+
+	logger := zap.NewNop()
+	buf := buffer.New(100, logger)
+	buf.Close()
+	poller := NewPoller(New(server.URL, 5*time.Second, logger), buf, 1, 0, logger)
+
+	// Start returns on its own, without the context being cancelled
+	done := make(chan struct{})
+	go func() {
+		poller.Start(context.Background())
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected the poller to stop once the buffer is closed")
+	}
+	if buf.Size() != 0 {
+		t.Errorf("Expected no readings in a closed buffer, got %d", buf.Size())
+	}
+}
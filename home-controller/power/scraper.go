@@ -8,6 +8,10 @@ import (
 	"net/http"
 	"time"
 
+	"github.com/mjasion/balena-home/thermostats/faults"
+	"github.com/mjasion/balena-home/thermostats/httpauth"
+	"github.com/mjasion/balena-home/thermostats/httpclient"
+	"github.com/mjasion/balena-home/thermostats/telemetry"
 	"go.uber.org/zap"
 )
 
@@ -16,20 +20,44 @@ type Scraper struct {
 	client  *http.Client
 	url     string
 	timeout time.Duration
+	auth    httpauth.Config
 	logger  *zap.Logger
 }
 
 // New creates a new Scraper instance
 func New(url string, timeout time.Duration, logger *zap.Logger) *Scraper {
 	return &Scraper{
-		client: &http.Client{
-			Timeout: timeout,
-		},
-		url:    url,
-		logger: logger,
+		client:  httpclient.NewDefault(httpclient.Config{Timeout: timeout}),
+		url:     url,
+		timeout: timeout,
+		logger:  logger,
 	}
 }
 
+// SetAuth configures authentication and TLS settings for scrape requests
+func (s *Scraper) SetAuth(auth httpauth.Config, tlsConfig httpauth.TLSConfig) error {
+	client, err := httpclient.New(httpclient.Config{Timeout: s.timeout, TLS: tlsConfig})
+	if err != nil {
+		return err
+	}
+	client.Transport = auth.Transport(client.Transport)
+
+	s.client = client
+	s.auth = auth
+	return nil
+}
+
+// SetRecorder records scrape requests as the "power" dependency; call after SetAuth
+func (s *Scraper) SetRecorder(recorder *telemetry.Recorder) {
+	recorder.Instrument(s.client, "power")
+}
+
+// SetFaults hangs scrape requests while the scrape_timeout fault is injected; call after SetAuth and
+// before SetRecorder
+func (s *Scraper) SetFaults(injector *faults.Injector) {
+	injector.Instrument(s.client, faults.ScrapeTimeout)
+}
+
 // Scrape fetches data from the energy meter and extracts active power readings
 func (s *Scraper) Scrape(ctx context.Context) (*ScrapeResult, error) {
 	result := &ScrapeResult{
@@ -78,6 +106,7 @@ func (s *Scraper) scrapeOnce(ctx context.Context) ([]ActivePowerReading, error)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
+	s.auth.Apply(req)
 
 	resp, err := s.client.Do(req)
 	if err != nil {
@@ -7,6 +7,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/mjasion/balena-home/thermostats/httpauth"
 	"go.uber.org/zap"
 )
 
@@ -287,3 +288,49 @@ func TestScrape_ExhaustedRetries(t *testing.T) {
 		t.Error("Expected error in result after exhausted retries")
 	}
 }
+
+func TestScrape_BasicAuth(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		username, password, ok := r.BasicAuth()
+		if !ok || username != "admin" || password != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(fullSampleJSON))
+	}))
+	defer server.Close()
+
+	scraper := New(server.URL, 5*time.Second, zap.NewNop())
+	err := scraper.SetAuth(httpauth.Config{Type: httpauth.TypeBasic, Username: "admin", Password: "secret"}, httpauth.TLSConfig{})
+	if err != nil {
+		t.Fatalf("Expected no error configuring auth, got: %v", err)
+	}
+
+	result, err := scraper.Scrape(context.Background())
+	if err != nil {
+		t.Fatalf("Expected successful authenticated scrape, got error: %v", err)
+	}
+
+	if len(result.Readings) != 4 {
+		t.Errorf("Expected 4 active power readings, got %d", len(result.Readings))
+	}
+}
+
+func TestScrape_TLSInsecureSkipVerify(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(fullSampleJSON))
+	}))
+	defer server.Close()
+
+	scraper := New(server.URL, 5*time.Second, zap.NewNop())
+	err := scraper.SetAuth(httpauth.Config{}, httpauth.TLSConfig{InsecureSkipVerify: true})
+	if err != nil {
+		t.Fatalf("Expected no error configuring TLS, got: %v", err)
+	}
+
+	if _, err := scraper.Scrape(context.Background()); err != nil {
+		t.Fatalf("Expected scrape of self-signed target to succeed, got error: %v", err)
+	}
+}
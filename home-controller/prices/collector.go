@@ -0,0 +1,153 @@
+package prices
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/mjasion/balena-home/thermostats/buffer"
+	"go.uber.org/zap"
+)
+
+// fetchWindow is how far ahead of the start of the UTC day prices are fetched, covering tomorrow's
+// day-ahead prices once published
+const fetchWindow = 48 * time.Hour
+
+// HourPrice is the price of one hour
+type HourPrice struct {
+	Start     time.Time `json:"start"`
+	PLNPerKWh float64   `json:"pln_per_kwh"`
+}
+
+// Collector periodically fetches day-ahead prices and buffers the price of the current hour and
+// the next hours, one series per hour ahead
+type Collector struct {
+	source         Source
+	buffer         *buffer.RingBuffer
+	fetchInterval  time.Duration
+	reportInterval time.Duration
+	horizon        int // Hours ahead reported besides the current one
+	logger         *zap.Logger
+	now            func() time.Time
+
+	mu     sync.Mutex
+	prices map[time.Time]float64 // PLN/kWh by UTC hour start
+}
+
+// New creates a price collector reporting the current hour and up to horizonHours ahead
+func New(source Source, buf *buffer.RingBuffer, fetchIntervalSeconds, reportIntervalSeconds, horizonHours int, logger *zap.Logger) *Collector {
+	return &Collector{
+		source:         source,
+		buffer:         buf,
+		fetchInterval:  time.Duration(fetchIntervalSeconds) * time.Second,
+		reportInterval: time.Duration(reportIntervalSeconds) * time.Second,
+		horizon:        horizonHours,
+		logger:         logger,
+		now:            time.Now,
+		prices:         make(map[time.Time]float64),
+	}
+}
+
+// Start fetches and reports prices until the context is cancelled
+func (c *Collector) Start(ctx context.Context) {
+	c.logger.Info("starting price collector",
+		zap.String("source", c.source.Name()),
+		zap.Duration("fetch_interval", c.fetchInterval),
+		zap.Duration("report_interval", c.reportInterval),
+		zap.Int("horizon_hours", c.horizon),
+	)
+
+	fetchTicker := time.NewTicker(c.fetchInterval)
+	defer fetchTicker.Stop()
+	reportTicker := time.NewTicker(c.reportInterval)
+	defer reportTicker.Stop()
+
+	c.Fetch(ctx)
+	c.Report()
+
+	for {
+		select {
+		case <-ctx.Done():
+			c.logger.Info("stopping price collector")
+			return
+		case <-fetchTicker.C:
+			c.Fetch(ctx)
+		case <-reportTicker.C:
+			c.Report()
+		}
+	}
+}
+
+// Fetch refreshes the prices of today and tomorrow (UTC), keeping known prices when the source fails
+func (c *Collector) Fetch(ctx context.Context) {
+	now := c.now()
+	from := now.UTC().Truncate(24 * time.Hour)
+	fetched, err := c.source.Fetch(ctx, from, from.Add(fetchWindow))
+	if err != nil {
+		c.logger.Error("failed to fetch electricity prices",
+			zap.String("source", c.source.Name()),
+			zap.Error(err),
+		)
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for hour, price := range fetched {
+		c.prices[hour] = price
+	}
+	// Keep the last day for GET /api/prices
+	for hour := range c.prices {
+		if hour.Before(now.Add(-24 * time.Hour)) {
+			delete(c.prices, hour)
+		}
+	}
+
+	c.logger.Info("fetched electricity prices",
+		zap.String("source", c.source.Name()),
+		zap.Int("hours", len(fetched)),
+	)
+}
+
+// Report buffers the price of the current hour and of each known hour within the horizon
+func (c *Collector) Report() {
+	now := c.now()
+	current := now.UTC().Truncate(time.Hour)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, ok := c.prices[current]; !ok {
+		c.logger.Warn("no electricity price for the current hour", zap.Time("hour", current))
+		return
+	}
+	for ahead := 0; ahead <= c.horizon; ahead++ {
+		price, ok := c.prices[current.Add(time.Duration(ahead)*time.Hour)]
+		if !ok {
+			break
+		}
+		c.buffer.Add(&buffer.Reading{
+			Type: buffer.ReadingTypePrice,
+			Price: &buffer.PriceReading{
+				Timestamp:  now,
+				Source:     c.source.Name(),
+				HoursAhead: ahead,
+				PLNPerKWh:  price,
+			},
+		})
+	}
+}
+
+// Prices returns the known hourly prices, oldest first
+func (c *Collector) Prices() []HourPrice {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	prices := make([]HourPrice, 0, len(c.prices))
+	for hour, price := range c.prices {
+		prices = append(prices, HourPrice{Start: hour, PLNPerKWh: price})
+	}
+	sort.Slice(prices, func(i, j int) bool { return prices[i].Start.Before(prices[j].Start) })
+	return prices
+}
@@ -0,0 +1,80 @@
+package prices
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/mjasion/balena-home/thermostats/buffer"
+	"go.uber.org/zap"
+)
+
+// fakeSource returns fixed prices, or an error once failing is set
+type fakeSource struct {
+	prices  map[time.Time]float64
+	failing bool
+}
+
+func (f *fakeSource) Name() string {
+	return "fake"
+}
+
+func (f *fakeSource) Fetch(ctx context.Context, from, to time.Time) (map[time.Time]float64, error) {
+	if f.failing {
+		return nil, fmt.Errorf("unavailable")
+	}
+	return f.prices, nil
+}
+
+func TestCollector_Report(t *testing.T) {
+	hour := time.Date(2026, 3, 10, 8, 0, 0, 0, time.UTC)
+	source := &fakeSource{prices: map[time.Time]float64{
+		hour:                    0.5,
+		hour.Add(time.Hour):     0.3,
+		hour.Add(2 * time.Hour): 0.2,
+		hour.Add(3 * time.Hour): 0.1,
+	}}
+	buf := buffer.New(10, zap.NewNop())
+	collector := New(source, buf, 3600, 300, 2, zap.NewNop())
+	collector.now = func() time.Time { return hour.Add(20 * time.Minute) }
+
+	collector.Fetch(context.Background())
+	collector.Report()
+
+	readings := buf.GetAll()
+	if len(readings) != 3 {
+		t.Fatalf("Expected the current hour and 2 ahead, got %d readings", len(readings))
+	}
+	for i, reading := range readings {
+		if reading.Price.HoursAhead != i || reading.Price.PLNPerKWh != source.prices[hour.Add(time.Duration(i)*time.Hour)] {
+			t.Errorf("Unexpected reading %d: %+v", i, reading.Price)
+		}
+	}
+
+	// Known prices are kept when the source fails
+	source.failing = true
+	collector.now = func() time.Time { return hour.Add(3*time.Hour + 5*time.Minute) }
+	collector.Fetch(context.Background())
+	collector.Report()
+	if readings := buf.GetAll(); len(readings) != 4 || readings[3].Price.PLNPerKWh != 0.1 {
+		t.Errorf("Expected the last known hour reported, got %d readings", len(readings))
+	}
+	if prices := collector.Prices(); len(prices) != 4 || !prices[0].Start.Equal(hour) {
+		t.Errorf("Expected 4 hours oldest first, got %+v", prices)
+	}
+}
+
+func TestCollector_NoCurrentPrice(t *testing.T) {
+	hour := time.Date(2026, 3, 10, 8, 0, 0, 0, time.UTC)
+	source := &fakeSource{prices: map[time.Time]float64{hour.Add(time.Hour): 0.3}}
+	buf := buffer.New(10, zap.NewNop())
+	collector := New(source, buf, 3600, 300, 24, zap.NewNop())
+	collector.now = func() time.Time { return hour }
+
+	collector.Fetch(context.Background())
+	collector.Report()
+	if buf.Size() != 0 {
+		t.Errorf("Expected no readings without a current price, got %d", buf.Size())
+	}
+}
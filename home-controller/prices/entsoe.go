@@ -0,0 +1,161 @@
+package prices
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/mjasion/balena-home/thermostats/httpclient"
+	"github.com/mjasion/balena-home/thermostats/telemetry"
+)
+
+// entsoeBaseURL is the ENTSO-E Transparency Platform REST API
+const entsoeBaseURL = "https://web-api.tp.entsoe.eu/api"
+
+// entsoeTimeLayout is the layout of period starts and ends, e.g. 2026-03-09T23:00Z
+const entsoeTimeLayout = "2006-01-02T15:04Z07:00"
+
+// ENTSOE fetches day-ahead prices of a bidding zone from the ENTSO-E Transparency Platform
+type ENTSOE struct {
+	httpClient *http.Client
+	baseURL    string
+	token      string
+	area       string  // EIC code of the bidding zone
+	eurPLN     float64 // Exchange rate for prices published in EUR
+}
+
+// NewENTSOE creates a day-ahead price source for an area, converting EUR prices with eurPLNRate
+func NewENTSOE(token, area string, eurPLNRate float64) *ENTSOE {
+	return &ENTSOE{
+		httpClient: httpclient.NewDefault(httpclient.Config{Timeout: 30 * time.Second}),
+		baseURL:    entsoeBaseURL,
+		token:      token,
+		area:       area,
+		eurPLN:     eurPLNRate,
+	}
+}
+
+// SetBaseURL points the source at another API URL, such as a test server
+func (e *ENTSOE) SetBaseURL(baseURL string) {
+	e.baseURL = strings.TrimSuffix(baseURL, "/")
+}
+
+// SetRecorder records API requests as the "entsoe" dependency
+func (e *ENTSOE) SetRecorder(recorder *telemetry.Recorder) {
+	recorder.Instrument(e.httpClient, "entsoe")
+}
+
+// Name returns the source label of the prices
+func (e *ENTSOE) Name() string {
+	return "entsoe"
+}
+
+// entsoeDocument is a Publication_MarketDocument; an Acknowledgement_MarketDocument sent when
+// no prices are published yet decodes to no time series
+type entsoeDocument struct {
+	TimeSeries []struct {
+		Currency string `xml:"currency_Unit.name"`
+		Periods  []struct {
+			Start      string `xml:"timeInterval>start"`
+			End        string `xml:"timeInterval>end"`
+			Resolution string `xml:"resolution"`
+			Points     []struct {
+				Position int     `xml:"position"`
+				Price    float64 `xml:"price.amount"` // Per MWh
+			} `xml:"Point"`
+		} `xml:"Period"`
+	} `xml:"TimeSeries"`
+}
+
+// Fetch returns the day-ahead prices of the hours between from and to, averaging sub-hour resolutions
+func (e *ENTSOE) Fetch(ctx context.Context, from, to time.Time) (map[time.Time]float64, error) {
+	query := url.Values{}
+	query.Set("documentType", "A44")
+	query.Set("in_Domain", e.area)
+	query.Set("out_Domain", e.area)
+	query.Set("periodStart", from.UTC().Format("200601021504"))
+	query.Set("periodEnd", to.UTC().Format("200601021504"))
+	query.Set("securityToken", e.token)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, e.baseURL+"?"+query.Encode(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create day-ahead price request: %w", err)
+	}
+
+	resp, err := e.httpClient.Do(req)
+	if err != nil {
+		// The URL carries the token, so only the cause is returned
+		if urlErr, ok := err.(*url.Error); ok {
+			err = urlErr.Err
+		}
+		return nil, fmt.Errorf("failed to fetch day-ahead prices: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read day-ahead price response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("day-ahead price request failed with status %d: %s", resp.StatusCode, string(body))
+	}
+
+	var doc entsoeDocument
+	if err := xml.Unmarshal(body, &doc); err != nil {
+		return nil, fmt.Errorf("failed to parse day-ahead price response: %w", err)
+	}
+
+	prices := newHourly()
+	for _, ts := range doc.TimeSeries {
+		rate, err := e.rate(ts.Currency)
+		if err != nil {
+			return nil, err
+		}
+		for _, period := range ts.Periods {
+			start, err := time.Parse(entsoeTimeLayout, period.Start)
+			if err != nil {
+				return nil, fmt.Errorf("invalid period start %q: %w", period.Start, err)
+			}
+			end, err := time.Parse(entsoeTimeLayout, period.End)
+			if err != nil {
+				return nil, fmt.Errorf("invalid period end %q: %w", period.End, err)
+			}
+			resolution, err := time.ParseDuration(strings.ToLower(strings.TrimPrefix(period.Resolution, "PT")))
+			if err != nil || resolution <= 0 {
+				return nil, fmt.Errorf("unsupported resolution %q", period.Resolution)
+			}
+
+			// Points repeating the previous price are left out, so each price holds until the next point
+			points := period.Points
+			sort.Slice(points, func(i, j int) bool { return points[i].Position < points[j].Position })
+			count := int(end.Sub(start) / resolution)
+			for i, point := range points {
+				last := count
+				if i+1 < len(points) {
+					last = points[i+1].Position - 1
+				}
+				for pos := point.Position; pos <= last; pos++ {
+					prices.add(start.Add(time.Duration(pos-1)*resolution), point.Price*rate/1000, from, to)
+				}
+			}
+		}
+	}
+	return prices.prices(), nil
+}
+
+// rate returns the factor converting a currency to PLN
+func (e *ENTSOE) rate(currency string) (float64, error) {
+	switch currency {
+	case "PLN":
+		return 1, nil
+	case "EUR":
+		return e.eurPLN, nil
+	}
+	return 0, fmt.Errorf("unsupported currency %q", currency)
+}
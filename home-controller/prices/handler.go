@@ -0,0 +1,23 @@
+package prices
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/mjasion/balena-home/thermostats/admin"
+)
+
+// RegisterHandlers registers the known hourly prices on the admin server
+func (c *Collector) RegisterHandlers(server *admin.Server) {
+	server.HandleFunc("GET /api/prices", c.handlePrices)
+}
+
+// handlePrices handles GET /api/prices
+func (c *Collector) handlePrices(w http.ResponseWriter, r *http.Request) {
+	prices := c.Prices()
+	admin.WriteJSON(w, http.StatusOK, admin.Response{
+		Success: true,
+		Message: fmt.Sprintf("%d hourly %s prices", len(prices), c.source.Name()),
+		Data:    prices,
+	})
+}
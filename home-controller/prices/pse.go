@@ -0,0 +1,117 @@
+package prices
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/mjasion/balena-home/thermostats/httpclient"
+	"github.com/mjasion/balena-home/thermostats/telemetry"
+)
+
+// pseBaseURL is the PSE public reports API origin
+const pseBaseURL = "https://api.raporty.pse.pl"
+
+// pseTimeLayout is the layout of dtime_utc, the end of an RCE period
+const pseTimeLayout = "2006-01-02 15:04:05"
+
+// PSE fetches the Polish market price of energy (RCE) published by PSE
+type PSE struct {
+	httpClient *http.Client
+	baseURL    string
+}
+
+// NewPSE creates an RCE source; the PSE API needs no credentials
+func NewPSE() *PSE {
+	return &PSE{
+		httpClient: httpclient.NewDefault(httpclient.Config{Timeout: 30 * time.Second}),
+		baseURL:    pseBaseURL,
+	}
+}
+
+// SetBaseURL points the source at another API origin, such as a test server
+func (p *PSE) SetBaseURL(baseURL string) {
+	p.baseURL = strings.TrimSuffix(baseURL, "/")
+}
+
+// SetRecorder records API requests as the "pse" dependency
+func (p *PSE) SetRecorder(recorder *telemetry.Recorder) {
+	recorder.Instrument(p.httpClient, "pse")
+}
+
+// Name returns the source label of the prices
+func (p *PSE) Name() string {
+	return "pse"
+}
+
+// pseResponse is a page of the rce-pln report
+type pseResponse struct {
+	Value []struct {
+		RCEPLN   *float64 `json:"rce_pln"` // PLN/MWh
+		DTimeUTC string   `json:"dtime_utc"`
+	} `json:"value"`
+	NextLink string `json:"nextLink"`
+}
+
+// Fetch returns the RCE of the hours between from and to; the 15 minute periods are averaged per hour
+func (p *PSE) Fetch(ctx context.Context, from, to time.Time) (map[time.Time]float64, error) {
+	// Business dates are Polish days, up to two hours ahead of UTC; the last hour starts an hour before to
+	filter := fmt.Sprintf("business_date ge '%s' and business_date le '%s'",
+		from.UTC().Format(time.DateOnly), to.UTC().Add(time.Hour).Format(time.DateOnly))
+	next := p.baseURL + "/api/rce-pln?" + url.Values{"$filter": {filter}}.Encode()
+
+	prices := newHourly()
+	for next != "" {
+		page, err := p.get(ctx, next)
+		if err != nil {
+			return nil, err
+		}
+		for _, v := range page.Value {
+			if v.RCEPLN == nil {
+				continue
+			}
+			end, err := time.Parse(pseTimeLayout, v.DTimeUTC)
+			if err != nil {
+				return nil, fmt.Errorf("invalid RCE period end %q: %w", v.DTimeUTC, err)
+			}
+			// Periods are stamped with their end, so the last one of an hour is stamped on the next
+			prices.add(end.Add(-time.Second), *v.RCEPLN/1000, from, to)
+		}
+		next = page.NextLink
+	}
+	return prices.prices(), nil
+}
+
+// get fetches one page of the report
+func (p *PSE) get(ctx context.Context, pageURL string) (*pseResponse, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, pageURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create RCE request: %w", err)
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch RCE: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read RCE response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("RCE request failed with status %d: %s", resp.StatusCode, string(body))
+	}
+
+	var page pseResponse
+	if err := json.Unmarshal(body, &page); err != nil {
+		return nil, fmt.Errorf("failed to parse RCE response: %w", err)
+	}
+	return &page, nil
+}
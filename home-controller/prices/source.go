@@ -0,0 +1,42 @@
+package prices
+
+import (
+	"context"
+	"time"
+)
+
+// Source fetches day-ahead electricity prices
+type Source interface {
+	Name() string
+	// Fetch returns prices in PLN/kWh by UTC hour start for the hours starting between from and to
+	Fetch(ctx context.Context, from, to time.Time) (map[time.Time]float64, error)
+}
+
+// hourly averages prices of sub-hour intervals, such as the 15 minute RCE periods, per hour
+type hourly struct {
+	sum   map[time.Time]float64
+	count map[time.Time]int
+}
+
+func newHourly() *hourly {
+	return &hourly{sum: make(map[time.Time]float64), count: make(map[time.Time]int)}
+}
+
+// add records the price of an interval starting at start, if the hour is between from and to
+func (h *hourly) add(start time.Time, price float64, from, to time.Time) {
+	hour := start.UTC().Truncate(time.Hour)
+	if hour.Before(from) || !hour.Before(to) {
+		return
+	}
+	h.sum[hour] += price
+	h.count[hour]++
+}
+
+// prices returns the average price of each hour
+func (h *hourly) prices() map[time.Time]float64 {
+	prices := make(map[time.Time]float64, len(h.sum))
+	for hour, sum := range h.sum {
+		prices[hour] = sum / float64(h.count[hour])
+	}
+	return prices
+}
@@ -0,0 +1,113 @@
+package prices
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPSE_Fetch(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/rce-pln" {
+			t.Errorf("Unexpected path %s", r.URL.Path)
+		}
+		if r.URL.Query().Get("page") == "" {
+			if filter := r.URL.Query().Get("$filter"); filter != "business_date ge '2026-03-10' and business_date le '2026-03-12'" {
+				t.Errorf("Unexpected filter %q", filter)
+			}
+			w.Write([]byte(`{"value":[
+				{"rce_pln":400.0,"dtime_utc":"2026-03-10 08:15:00"},
+				{"rce_pln":420.0,"dtime_utc":"2026-03-10 08:30:00"},
+				{"rce_pln":null,"dtime_utc":"2026-03-10 08:45:00"},
+				{"rce_pln":440.0,"dtime_utc":"2026-03-10 09:00:00"}
+			],"nextLink":"` + "http://" + r.Host + `/api/rce-pln?page=2"}`))
+			return
+		}
+		w.Write([]byte(`{"value":[
+			{"rce_pln":-10.0,"dtime_utc":"2026-03-10 09:15:00"},
+			{"rce_pln":500.0,"dtime_utc":"2026-03-09 22:00:00"}
+		]}`))
+	}))
+	defer server.Close()
+
+	source := NewPSE()
+	source.SetBaseURL(server.URL)
+	from := time.Date(2026, 3, 10, 0, 0, 0, 0, time.UTC)
+	prices, err := source.Fetch(context.Background(), from, from.Add(48*time.Hour))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	// The period ending at 09:00 belongs to 08:00; the one ending 22:00 the day before is out of range
+	if len(prices) != 2 {
+		t.Fatalf("Expected 2 hours, got %v", prices)
+	}
+	if got := prices[from.Add(8*time.Hour)]; math.Abs(got-0.42) > 1e-9 {
+		t.Errorf("Expected 0.42 PLN/kWh at 08:00, got %v", got)
+	}
+	if got := prices[from.Add(9*time.Hour)]; math.Abs(got+0.01) > 1e-9 {
+		t.Errorf("Expected -0.01 PLN/kWh at 09:00, got %v", got)
+	}
+}
+
+const entsoeResponse = `<?xml version="1.0" encoding="UTF-8"?>
+<Publication_MarketDocument xmlns="urn:iec62325.351:tc57wg16:451-3:publicationdocument:7:3">
+  <TimeSeries>
+    <currency_Unit.name>EUR</currency_Unit.name>
+    <price_Measure_Unit.name>MWH</price_Measure_Unit.name>
+    <Period>
+      <timeInterval>
+        <start>2026-03-09T23:00Z</start>
+        <end>2026-03-10T03:00Z</end>
+      </timeInterval>
+      <resolution>PT60M</resolution>
+      <Point><position>1</position><price.amount>100</price.amount></Point>
+      <Point><position>3</position><price.amount>50</price.amount></Point>
+    </Period>
+  </TimeSeries>
+</Publication_MarketDocument>`
+
+func TestENTSOE_Fetch(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		if query.Get("securityToken") != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if query.Get("in_Domain") != "10YPL-AREA-----S" || query.Get("periodStart") != "202603092300" {
+			t.Errorf("Unexpected query %s", r.URL.RawQuery)
+		}
+		w.Write([]byte(entsoeResponse))
+	}))
+	defer server.Close()
+
+	source := NewENTSOE("secret", "10YPL-AREA-----S", 4.25)
+	source.SetBaseURL(server.URL)
+	from := time.Date(2026, 3, 9, 23, 0, 0, 0, time.UTC)
+	prices, err := source.Fetch(context.Background(), from, from.Add(24*time.Hour))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	// The second hour repeats the first price, the fourth the third
+	expected := []float64{0.425, 0.425, 0.2125, 0.2125}
+	if len(prices) != len(expected) {
+		t.Fatalf("Expected %d hours, got %v", len(expected), prices)
+	}
+	for i, want := range expected {
+		if got := prices[from.Add(time.Duration(i)*time.Hour)]; math.Abs(got-want) > 1e-9 {
+			t.Errorf("Hour %d: expected %v PLN/kWh, got %v", i, want, got)
+		}
+	}
+
+	source = NewENTSOE("wrong", "10YPL-AREA-----S", 4.25)
+	source.SetBaseURL(server.URL)
+	_, err = source.Fetch(context.Background(), from, from.Add(24*time.Hour))
+	if err == nil || strings.Contains(err.Error(), "wrong") {
+		t.Errorf("Expected an error without the token, got %v", err)
+	}
+}
@@ -0,0 +1,109 @@
+package pstryk
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/mjasion/balena-home/thermostats/httpclient"
+	"github.com/mjasion/balena-home/thermostats/telemetry"
+)
+
+// defaultBaseURL is the Pstryk integrations API origin
+const defaultBaseURL = "https://api.pstryk.pl"
+
+// usagePath returns official meter usage per frame
+const usagePath = "/integrations/meter-data/usage/"
+
+// Client fetches official smart meter data from the Pstryk API
+type Client struct {
+	httpClient *http.Client
+	baseURL    string
+	token      string
+}
+
+// NewClient creates a client authenticating with an API token from the Pstryk app
+func NewClient(token string) *Client {
+	return &Client{
+		httpClient: httpclient.NewDefault(httpclient.Config{Timeout: 30 * time.Second}),
+		baseURL:    defaultBaseURL,
+		token:      token,
+	}
+}
+
+// SetBaseURL points the client at another API origin, such as a test server
+func (c *Client) SetBaseURL(baseURL string) {
+	c.baseURL = strings.TrimSuffix(baseURL, "/")
+}
+
+// SetRecorder records API requests as the "pstryk" dependency
+func (c *Client) SetRecorder(recorder *telemetry.Recorder) {
+	recorder.Instrument(c.httpClient, "pstryk")
+}
+
+// Frame is the official consumption of one hour
+type Frame struct {
+	Start time.Time
+	End   time.Time
+	KWh   float64
+	Live  bool // The hour is still in progress, so the value is partial
+}
+
+// usageResponse is the body of the usage endpoint
+type usageResponse struct {
+	Frames []struct {
+		Start    time.Time `json:"start"`
+		End      time.Time `json:"end"`
+		FAEUsage *float64  `json:"fae_usage"` // Forward active energy in kWh, null before the meter reports
+		IsLive   bool      `json:"is_live"`
+	} `json:"frames"`
+}
+
+// HourlyUsage returns the official hourly consumption between from and to; hours the
+// meter hasn't reported yet are left out
+func (c *Client) HourlyUsage(ctx context.Context, from, to time.Time) ([]Frame, error) {
+	query := url.Values{}
+	query.Set("resolution", "hour")
+	query.Set("window_start", from.UTC().Format(time.RFC3339))
+	query.Set("window_end", to.UTC().Format(time.RFC3339))
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.baseURL+usagePath+"?"+query.Encode(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create usage request: %w", err)
+	}
+	req.Header.Set("Authorization", c.token)
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch usage: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read usage response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("usage request failed with status %d: %s", resp.StatusCode, string(body))
+	}
+
+	var usage usageResponse
+	if err := json.Unmarshal(body, &usage); err != nil {
+		return nil, fmt.Errorf("failed to parse usage response: %w", err)
+	}
+
+	frames := make([]Frame, 0, len(usage.Frames))
+	for _, f := range usage.Frames {
+		if f.FAEUsage == nil {
+			continue
+		}
+		frames = append(frames, Frame{Start: f.Start, End: f.End, KWh: *f.FAEUsage, Live: f.IsLive})
+	}
+	return frames, nil
+}
@@ -0,0 +1,35 @@
+package pstryk
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/mjasion/balena-home/thermostats/admin"
+)
+
+// RegisterHandlers registers the last reconciliation result on the admin server
+func (r *Reconciler) RegisterHandlers(server *admin.Server) {
+	server.HandleFunc("GET /api/power/reconciliation", r.handleReconciliation)
+}
+
+// handleReconciliation handles GET /api/power/reconciliation
+func (r *Reconciler) handleReconciliation(w http.ResponseWriter, req *http.Request) {
+	result := r.Result()
+	if result == nil {
+		admin.WriteError(w, http.StatusNotFound, "no reconciliation has run yet")
+		return
+	}
+	if result.Error != "" {
+		admin.WriteJSON(w, http.StatusOK, admin.Response{
+			Success: false,
+			Message: "failed to fetch official meter usage",
+			Data:    result,
+		})
+		return
+	}
+	admin.WriteJSON(w, http.StatusOK, admin.Response{
+		Success: true,
+		Message: fmt.Sprintf("%d hours compared, drift %.1f%%", len(result.Hours), result.DriftPercent),
+		Data:    result,
+	})
+}
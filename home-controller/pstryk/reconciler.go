@@ -0,0 +1,345 @@
+package pstryk
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/mjasion/balena-home/thermostats/buffer"
+	"github.com/mjasion/balena-home/thermostats/events"
+	"github.com/mjasion/balena-home/thermostats/power"
+	"go.uber.org/zap"
+)
+
+// Source labels readings and events of the Pstryk reconciliation
+const Source = "pstryk"
+
+const (
+	// maxGapIntervals is how many scrape intervals two power readings may be apart and still be integrated
+	maxGapIntervals = 3
+
+	// minCoverage is the share of an hour that must be integrated locally before the hour is compared
+	minCoverage = 0.9
+)
+
+// Hour is the comparison of one complete hour
+type Hour struct {
+	Start        time.Time `json:"start"`
+	OfficialKWh  float64   `json:"official_kwh"`
+	LocalKWh     float64   `json:"local_kwh"`
+	DriftPercent float64   `json:"drift_percent"`
+	Coverage     float64   `json:"coverage"` // Share of the hour integrated locally, lowest across sensors
+}
+
+// Result is the outcome of a reconciliation run
+type Result struct {
+	Time         time.Time `json:"time"`
+	Hours        []Hour    `json:"hours"`
+	OfficialKWh  float64   `json:"official_kwh"`
+	LocalKWh     float64   `json:"local_kwh"`
+	DriftPercent float64   `json:"drift_percent"`
+	Error        string    `json:"error,omitempty"`
+}
+
+// sensorHour is the energy of one sensor integrated within one hour
+type sensorHour struct {
+	kwh     float64
+	covered time.Duration
+}
+
+// Reconciler integrates local active power into hourly energy and periodically compares it with
+// the official smart meter consumption, catching CT clamp calibration problems
+type Reconciler struct {
+	client       *Client
+	buffer       *buffer.RingBuffer
+	sensorIDs    []int // Sensors summed into the local consumption; empty means all
+	interval     time.Duration
+	lookback     time.Duration
+	maxGap       time.Duration
+	alertPercent float64
+	eventLog     *events.Log
+	logger       *zap.Logger
+	now          func() time.Time
+
+	mu       sync.Mutex
+	last     map[int]power.ActivePowerReading // Previous reading per sensor
+	hours    map[time.Time]map[int]*sensorHour
+	alerting bool
+	result   *Result
+}
+
+// New creates a reconciler comparing the given power sensors with the official consumption
+// every intervalSeconds over the last lookbackHours
+func New(client *Client, sensorIDs []int, buf *buffer.RingBuffer, intervalSeconds, lookbackHours int, alertPercent float64, scrapeIntervalSeconds int, logger *zap.Logger) *Reconciler {
+	return &Reconciler{
+		client:       client,
+		buffer:       buf,
+		sensorIDs:    sensorIDs,
+		interval:     time.Duration(intervalSeconds) * time.Second,
+		lookback:     time.Duration(lookbackHours) * time.Hour,
+		maxGap:       time.Duration(maxGapIntervals*scrapeIntervalSeconds) * time.Second,
+		alertPercent: alertPercent,
+		logger:       logger,
+		now:          time.Now,
+		last:         make(map[int]power.ActivePowerReading),
+		hours:        make(map[time.Time]map[int]*sensorHour),
+	}
+}
+
+// SetEventLog sets the event log used to record drift beyond the alert threshold
+func (r *Reconciler) SetEventLog(eventLog *events.Log) {
+	r.eventLog = eventLog
+}
+
+// ObservePower integrates a power reading into the hourly energy of its sensor using the
+// trapezoid rule; readings further apart than maxGap leave the interval uncovered
+func (r *Reconciler) ObservePower(reading power.ActivePowerReading) {
+	if !r.tracks(reading.SensorID) {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	prev, ok := r.last[reading.SensorID]
+	r.last[reading.SensorID] = reading
+	if !ok {
+		return
+	}
+	gap := reading.Timestamp.Sub(prev.Timestamp)
+	if gap <= 0 || gap > r.maxGap {
+		return
+	}
+	// The official meter counts imported energy only, so export while generating counts as zero
+	watts := math.Max(0, (prev.Value+reading.Value)/2)
+	r.integrate(reading.SensorID, prev.Timestamp, reading.Timestamp, watts)
+}
+
+// tracks reports whether a sensor counts towards the local consumption
+func (r *Reconciler) tracks(sensorID int) bool {
+	if len(r.sensorIDs) == 0 {
+		return true
+	}
+	for _, id := range r.sensorIDs {
+		if id == sensorID {
+			return true
+		}
+	}
+	return false
+}
+
+// integrate adds constant power between from and to, split at hour boundaries; caller holds the lock
+func (r *Reconciler) integrate(sensorID int, from, to time.Time, watts float64) {
+	for from.Before(to) {
+		hour := from.UTC().Truncate(time.Hour)
+		end := hour.Add(time.Hour)
+		if to.Before(end) {
+			end = to
+		}
+
+		sensors, ok := r.hours[hour]
+		if !ok {
+			sensors = make(map[int]*sensorHour)
+			r.hours[hour] = sensors
+		}
+		sh, ok := sensors[sensorID]
+		if !ok {
+			sh = &sensorHour{}
+			sensors[sensorID] = sh
+		}
+		d := end.Sub(from)
+		sh.kwh += watts * d.Hours() / 1000
+		sh.covered += d
+
+		from = end
+	}
+}
+
+// local returns the local energy of an hour and its lowest coverage across sensors;
+// ok is false unless every sensor covered enough of the hour; caller holds the lock
+func (r *Reconciler) local(hour time.Time) (kwh, coverage float64, ok bool) {
+	sensors := r.hours[hour]
+	ids := r.sensorIDs
+	if len(ids) == 0 {
+		for id := range sensors {
+			ids = append(ids, id)
+		}
+	}
+	if len(ids) == 0 {
+		return 0, 0, false
+	}
+
+	coverage = 1
+	for _, id := range ids {
+		sh, found := sensors[id]
+		if !found {
+			return 0, 0, false
+		}
+		kwh += sh.kwh
+		coverage = math.Min(coverage, sh.covered.Hours())
+	}
+	return kwh, coverage, coverage >= minCoverage
+}
+
+// Result returns the outcome of the last reconciliation run, or nil before the first
+func (r *Reconciler) Result() *Result {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.result
+}
+
+// Start reconciles every interval until the context is cancelled
+func (r *Reconciler) Start(ctx context.Context) {
+	r.logger.Info("starting meter reconciler",
+		zap.Duration("interval", r.interval),
+		zap.Duration("lookback", r.lookback),
+		zap.Ints("sensor_ids", r.sensorIDs),
+	)
+
+	// The first run waits an interval so there is local energy to compare
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			r.logger.Info("stopping meter reconciler")
+			return
+		case <-ticker.C:
+			r.Reconcile(ctx)
+		}
+	}
+}
+
+// Reconcile fetches the official hourly consumption of the lookback window, compares it with the
+// complete local hours and buffers the result
+func (r *Reconciler) Reconcile(ctx context.Context) *Result {
+	now := r.now()
+	to := now.UTC().Truncate(time.Hour)
+	from := to.Add(-r.lookback)
+
+	frames, err := r.client.HourlyUsage(ctx, from, to)
+	if err != nil {
+		r.logger.Error("failed to fetch official meter usage", zap.Error(err))
+		return r.finish(&Result{Time: now, Error: err.Error()}, from)
+	}
+
+	result := r.compare(now, frames)
+	if len(result.Hours) == 0 || result.OfficialKWh <= 0 {
+		r.logger.Info("no complete hours to reconcile",
+			zap.Int("official_hours", len(frames)),
+		)
+		return r.finish(result, from)
+	}
+
+	r.logger.Info("reconciled energy meter",
+		zap.Int("hours", len(result.Hours)),
+		zap.Float64("official_kwh", result.OfficialKWh),
+		zap.Float64("local_kwh", result.LocalKWh),
+		zap.Float64("drift_percent", result.DriftPercent),
+	)
+	r.buffer.Add(&buffer.Reading{
+		Type: buffer.ReadingTypeReconciliation,
+		Reconciliation: &buffer.ReconciliationReading{
+			Timestamp:    now,
+			Source:       Source,
+			Hours:        len(result.Hours),
+			OfficialKWh:  result.OfficialKWh,
+			LocalKWh:     result.LocalKWh,
+			DriftPercent: result.DriftPercent,
+		},
+	})
+	r.alert(result)
+	return r.finish(result, from)
+}
+
+// compare matches official frames with complete local hours
+func (r *Reconciler) compare(now time.Time, frames []Frame) *Result {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	result := &Result{Time: now, Hours: []Hour{}}
+	for _, frame := range frames {
+		if frame.Live {
+			continue
+		}
+		start := frame.Start.UTC()
+		kwh, coverage, ok := r.local(start)
+		if !ok {
+			continue
+		}
+		result.Hours = append(result.Hours, Hour{
+			Start:        start,
+			OfficialKWh:  frame.KWh,
+			LocalKWh:     kwh,
+			DriftPercent: driftPercent(kwh, frame.KWh),
+			Coverage:     coverage,
+		})
+		result.OfficialKWh += frame.KWh
+		result.LocalKWh += kwh
+	}
+	sort.Slice(result.Hours, func(i, j int) bool {
+		return result.Hours[i].Start.Before(result.Hours[j].Start)
+	})
+	result.DriftPercent = driftPercent(result.LocalKWh, result.OfficialKWh)
+	return result
+}
+
+// driftPercent returns how far the local energy is from the official one, or 0 without official usage
+func driftPercent(local, official float64) float64 {
+	if official <= 0 {
+		return 0
+	}
+	return (local - official) / official * 100
+}
+
+// alert records an event when the drift crosses the alert threshold in either direction
+func (r *Reconciler) alert(result *Result) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	drifting := math.Abs(result.DriftPercent) > r.alertPercent
+	if drifting == r.alerting {
+		return
+	}
+	r.alerting = drifting
+
+	fields := map[string]string{
+		"source":        Source,
+		"hours":         fmt.Sprintf("%d", len(result.Hours)),
+		"official_kwh":  fmt.Sprintf("%.2f", result.OfficialKWh),
+		"local_kwh":     fmt.Sprintf("%.2f", result.LocalKWh),
+		"drift_percent": fmt.Sprintf("%.1f", result.DriftPercent),
+	}
+	if drifting {
+		r.logger.Warn("local energy drifts from the official meter",
+			zap.Float64("drift_percent", result.DriftPercent),
+			zap.Float64("alert_percent", r.alertPercent),
+		)
+		r.eventLog.Record(events.TypeMeterDrift, "reconciliation",
+			fmt.Sprintf("local energy is %.1f%% off the official meter over %d hours", result.DriftPercent, len(result.Hours)), fields)
+		return
+	}
+	r.logger.Info("local energy matches the official meter again",
+		zap.Float64("drift_percent", result.DriftPercent),
+	)
+	r.eventLog.Record(events.TypeMeterDriftResolved, "reconciliation",
+		fmt.Sprintf("local energy is within %.1f%% of the official meter", r.alertPercent), fields)
+}
+
+// finish keeps the result for the API and forgets hours before the lookback window
+func (r *Reconciler) finish(result *Result, from time.Time) *Result {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.result = result
+	for hour := range r.hours {
+		if hour.Before(from) {
+			delete(r.hours, hour)
+		}
+	}
+	return result
+}
@@ -0,0 +1,132 @@
+package pstryk
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mjasion/balena-home/thermostats/buffer"
+	"github.com/mjasion/balena-home/thermostats/events"
+	"github.com/mjasion/balena-home/thermostats/power"
+	"go.uber.org/zap"
+)
+
+// usageServer serves 1 kWh for each of the given hours, the last one live
+func usageServer(t *testing.T, hours []time.Time) *httptest.Server {
+	t.Helper()
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if r.URL.Path != usagePath || r.URL.Query().Get("resolution") != "hour" {
+			t.Errorf("Unexpected request %s", r.URL)
+		}
+		var frames []string
+		for i, hour := range hours {
+			frames = append(frames, fmt.Sprintf(`{"start":%q,"end":%q,"fae_usage":1.0,"is_live":%t}`,
+				hour.Format(time.RFC3339), hour.Add(time.Hour).Format(time.RFC3339), i == len(hours)-1))
+		}
+		frames = append(frames, `{"start":"2026-03-10T23:00:00Z","end":"2026-03-11T00:00:00Z","fae_usage":null,"is_live":false}`)
+		fmt.Fprintf(w, `{"frames":[%s]}`, strings.Join(frames, ","))
+	}))
+}
+
+func newTestReconciler(t *testing.T, server *httptest.Server, token string, sensorIDs []int) (*Reconciler, *buffer.RingBuffer) {
+	t.Helper()
+	client := NewClient(token)
+	client.SetBaseURL(server.URL + "/")
+	buf := buffer.New(10, zap.NewNop())
+	return New(client, sensorIDs, buf, 3600, 48, 5, 10, zap.NewNop()), buf
+}
+
+// feed observes constant power of a sensor every 10 seconds between from and to
+func feed(r *Reconciler, sensorID int, from, to time.Time, watts float64) {
+	for ts := from; !ts.After(to); ts = ts.Add(10 * time.Second) {
+		r.ObservePower(power.ActivePowerReading{SensorID: sensorID, Value: watts, Timestamp: ts})
+	}
+}
+
+func TestReconciler_Drift(t *testing.T) {
+	start := time.Date(2026, 3, 10, 8, 0, 0, 0, time.UTC)
+	hours := []time.Time{start, start.Add(time.Hour), start.Add(2 * time.Hour)}
+	server := usageServer(t, hours)
+	defer server.Close()
+
+	reconciler, buf := newTestReconciler(t, server, "secret", []int{1, 2})
+	reconciler.now = func() time.Time { return start.Add(2*time.Hour + 30*time.Minute) }
+	eventLog := events.NewLog(10, zap.NewNop())
+	reconciler.SetEventLog(eventLog)
+
+	// Sensor 3 isn't configured; sensor 2 misses half of the first hour
+	feed(reconciler, 1, start, start.Add(2*time.Hour+30*time.Minute), 800)
+	feed(reconciler, 2, start.Add(30*time.Minute), start.Add(2*time.Hour+30*time.Minute), 300)
+	feed(reconciler, 3, start, start.Add(2*time.Hour+30*time.Minute), 5000)
+
+	result := reconciler.Reconcile(context.Background())
+	if result.Error != "" {
+		t.Fatalf("Expected no error, got %s", result.Error)
+	}
+	// Only the second hour is complete and reported, the third is live
+	if len(result.Hours) != 1 || !result.Hours[0].Start.Equal(start.Add(time.Hour)) {
+		t.Fatalf("Expected the 09:00 hour only, got %+v", result.Hours)
+	}
+	if math.Abs(result.LocalKWh-1.1) > 1e-9 || math.Abs(result.DriftPercent-10) > 1e-6 {
+		t.Errorf("Expected 1.1 kWh local and 10%% drift, got %v kWh and %v%%", result.LocalKWh, result.DriftPercent)
+	}
+
+	readings := buf.GetAll()
+	if len(readings) != 1 || readings[0].Reconciliation == nil || readings[0].Reconciliation.Source != Source {
+		t.Fatalf("Expected a reconciliation reading, got %+v", readings)
+	}
+	if list := eventLog.List(events.Filter{Type: events.TypeMeterDrift}); len(list) != 1 {
+		t.Errorf("Expected a meter drift event, got %+v", list)
+	}
+
+	// The drift event is recorded once until the drift resolves
+	reconciler.Reconcile(context.Background())
+	if list := eventLog.List(events.Filter{Type: events.TypeMeterDrift}); len(list) != 1 {
+		t.Errorf("Expected a single meter drift event, got %d", len(list))
+	}
+}
+
+func TestReconciler_Gaps(t *testing.T) {
+	start := time.Date(2026, 3, 10, 8, 0, 0, 0, time.UTC)
+	reconciler := New(NewClient(""), nil, buffer.New(10, zap.NewNop()), 3600, 48, 5, 10, zap.NewNop())
+
+	// A reading straddling the hour splits between both hours
+	reconciler.ObservePower(power.ActivePowerReading{SensorID: 1, Value: 1000, Timestamp: start.Add(-10 * time.Second)})
+	reconciler.ObservePower(power.ActivePowerReading{SensorID: 1, Value: 1000, Timestamp: start.Add(10 * time.Second)})
+	// A gap longer than three scrape intervals is not integrated
+	reconciler.ObservePower(power.ActivePowerReading{SensorID: 1, Value: 1000, Timestamp: start.Add(40 * time.Minute)})
+
+	kwh, coverage, ok := reconciler.local(start)
+	if ok {
+		t.Errorf("Expected the hour to be incomplete")
+	}
+	if math.Abs(coverage-10.0/3600) > 1e-9 || math.Abs(kwh-1000*10.0/3600/1000) > 1e-9 {
+		t.Errorf("Expected 10 seconds integrated, got %v kWh with coverage %v", kwh, coverage)
+	}
+}
+
+func TestReconciler_FetchError(t *testing.T) {
+	server := usageServer(t, nil)
+	defer server.Close()
+
+	reconciler, buf := newTestReconciler(t, server, "wrong", nil)
+	result := reconciler.Reconcile(context.Background())
+	if !strings.Contains(result.Error, "status 401") {
+		t.Errorf("Expected an authorization error, got %q", result.Error)
+	}
+	if buf.Size() != 0 {
+		t.Errorf("Expected no reading after a failed fetch, got %d", buf.Size())
+	}
+	if reconciler.Result() != result {
+		t.Errorf("Expected the failed result to be kept for the API")
+	}
+}
@@ -0,0 +1,818 @@
+package readingpb
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/mjasion/balena-home/thermostats/buffer"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// SchemaVersion is the version of reading.proto written by Marshal
+// Decoders reject readings with a newer version
+const SchemaVersion = 1
+
+// Reading field numbers from reading.proto
+const (
+	fieldSchemaVersion  = 1
+	fieldType           = 2
+	fieldTimestamp      = 3
+	fieldBLE            = 10
+	fieldThermostat     = 11
+	fieldPower          = 12
+	fieldHeatPump       = 13
+	fieldWater          = 14
+	fieldOneWire        = 15
+	fieldI2C            = 16
+	fieldAirQuality     = 17
+	fieldZigbee         = 18
+	fieldDependency     = 19
+	fieldAutomation     = 20
+	fieldDerived        = 21
+	fieldRoom           = 22
+	fieldSummary        = 23
+	fieldRemote         = 24
+	fieldHTTP           = 25
+	fieldConflict       = 26
+	fieldBattery        = 27
+	fieldLocation       = 28
+	fieldReconciliation = 29
+	fieldPrice          = 30
+	fieldOccupancy      = 31
+	fieldVentilation    = 32
+
+	fieldBatchReadings = 1
+)
+
+// Marshal encodes a reading as a readingpb.Reading message
+func Marshal(reading *buffer.Reading) ([]byte, error) {
+	payloadField, payload, timestamp, err := marshalPayload(reading)
+	if err != nil {
+		return nil, err
+	}
+	if timestamp.IsZero() {
+		return nil, fmt.Errorf("%s reading has no timestamp", reading.Type)
+	}
+
+	e := &encoder{}
+	e.uint64(fieldSchemaVersion, SchemaVersion)
+	e.string(fieldType, string(reading.Type))
+	e.int64(fieldTimestamp, timestamp.UnixNano())
+	e.message(payloadField, payload)
+	return e.b, nil
+}
+
+// MarshalBatch encodes readings as a readingpb.ReadingBatch message
+func MarshalBatch(readings []*buffer.Reading) ([]byte, error) {
+	e := &encoder{}
+	for i, reading := range readings {
+		data, err := Marshal(reading)
+		if err != nil {
+			return nil, fmt.Errorf("reading %d: %w", i, err)
+		}
+		e.message(fieldBatchReadings, data)
+	}
+	return e.b, nil
+}
+
+// Unmarshal decodes a readingpb.Reading message
+func Unmarshal(data []byte) (*buffer.Reading, error) {
+	var version uint64
+	var timestamp time.Time
+	var payloadField protowire.Number
+	var payload []byte
+	reading := &buffer.Reading{}
+
+	err := decodeFields(data, func(f field) error {
+		switch f.num {
+		case fieldSchemaVersion:
+			version = f.uint64()
+		case fieldType:
+			reading.Type = buffer.ReadingType(f.string())
+		case fieldTimestamp:
+			timestamp = time.Unix(0, f.int64())
+		case fieldBLE, fieldThermostat, fieldPower, fieldHeatPump, fieldWater, fieldOneWire,
+			fieldI2C, fieldAirQuality, fieldZigbee, fieldDependency, fieldAutomation, fieldDerived, fieldRoom, fieldSummary, fieldRemote, fieldHTTP, fieldConflict, fieldBattery, fieldLocation, fieldReconciliation, fieldPrice, fieldOccupancy, fieldVentilation:
+			// Last payload wins, as for a oneof
+			payloadField, payload = f.num, f.bytes
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if version == 0 {
+		return nil, fmt.Errorf("missing schema version")
+	}
+	if version > SchemaVersion {
+		return nil, fmt.Errorf("unsupported schema version %d, newest supported is %d", version, SchemaVersion)
+	}
+	if payloadField == 0 {
+		return nil, fmt.Errorf("%s reading has no payload", reading.Type)
+	}
+	if err := unmarshalPayload(reading, payloadField, payload, timestamp); err != nil {
+		return nil, err
+	}
+	return reading, nil
+}
+
+// UnmarshalBatch decodes a readingpb.ReadingBatch message
+func UnmarshalBatch(data []byte) ([]*buffer.Reading, error) {
+	var readings []*buffer.Reading
+	err := decodeFields(data, func(f field) error {
+		if f.num != fieldBatchReadings {
+			return nil
+		}
+		reading, err := Unmarshal(f.bytes)
+		if err != nil {
+			return fmt.Errorf("reading %d: %w", len(readings), err)
+		}
+		readings = append(readings, reading)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return readings, nil
+}
+
+// marshalPayload encodes the populated variant of a reading and returns its field number and timestamp
+func marshalPayload(reading *buffer.Reading) (protowire.Number, []byte, time.Time, error) {
+	e := &encoder{}
+	switch {
+	case reading.BLE != nil:
+		r := reading.BLE
+		e.string(1, r.MAC)
+		e.string(2, r.SensorName)
+		e.int64(3, int64(r.SensorID))
+		e.double(4, r.TemperatureCelsius)
+		e.int64(5, int64(r.HumidityPercent))
+		e.int64(6, int64(r.BatteryPercent))
+		e.int64(7, int64(r.BatteryVoltageMV))
+		e.int64(8, int64(r.FrameCounter))
+		e.int64(9, int64(r.RSSI))
+		e.string(10, r.Receiver)
+		return fieldBLE, e.b, r.Timestamp, nil
+	case reading.Thermostat != nil:
+		r := reading.Thermostat
+		e.string(1, r.HomeID)
+		e.string(2, r.HomeName)
+		e.string(3, r.RoomID)
+		e.string(4, r.RoomName)
+		e.double(5, r.MeasuredTemperature)
+		e.double(6, r.SetpointTemperature)
+		e.string(7, r.SetpointMode)
+		e.int64(8, int64(r.HeatingPowerRequest))
+		e.bool(9, r.OpenWindow)
+		e.bool(10, r.Reachable)
+		e.int64(11, int64(r.ErrorCode))
+		e.bool(12, r.Stale)
+		e.bool(13, r.NoValues)
+		e.bool(14, r.Historical)
+		return fieldThermostat, e.b, r.Timestamp, nil
+	case reading.Power != nil:
+		r := reading.Power
+		e.int64(1, int64(r.SensorID))
+		e.double(2, r.Value)
+		e.bool(3, r.Stale)
+		return fieldPower, e.b, r.Timestamp, nil
+	case reading.HeatPump != nil:
+		r := reading.HeatPump
+		e.string(1, r.Name)
+		e.double(2, r.Value)
+		return fieldHeatPump, e.b, r.Timestamp, nil
+	case reading.Water != nil:
+		r := reading.Water
+		e.double(1, r.TotalLiters)
+		return fieldWater, e.b, r.Timestamp, nil
+	case reading.OneWire != nil:
+		r := reading.OneWire
+		e.string(1, r.DeviceID)
+		e.string(2, r.SensorName)
+		e.int64(3, int64(r.SensorID))
+		e.double(4, r.TemperatureCelsius)
+		return fieldOneWire, e.b, r.Timestamp, nil
+	case reading.I2C != nil:
+		r := reading.I2C
+		e.string(1, r.SensorName)
+		e.int64(2, int64(r.SensorID))
+		e.string(3, r.Model)
+		e.double(4, r.TemperatureCelsius)
+		e.double(5, r.HumidityPercent)
+		e.double(6, r.PressureHPa)
+		e.bool(7, r.HasPressure)
+		return fieldI2C, e.b, r.Timestamp, nil
+	case reading.AirQuality != nil:
+		r := reading.AirQuality
+		e.string(1, r.SensorName)
+		e.int64(2, int64(r.SensorID))
+		e.string(3, r.Model)
+		e.double(4, r.CO2PPM)
+		e.double(5, r.TemperatureCelsius)
+		e.double(6, r.HumidityPercent)
+		e.bool(7, r.HasClimate)
+		return fieldAirQuality, e.b, r.Timestamp, nil
+	case reading.Zigbee != nil:
+		r := reading.Zigbee
+		e.string(1, r.Device)
+		e.string(2, r.IEEEAddress)
+		e.string(3, r.Model)
+		e.string(4, r.Vendor)
+		e.string(5, r.Class)
+		e.string(6, r.Metric)
+		e.double(7, r.Value)
+		return fieldZigbee, e.b, r.Timestamp, nil
+	case reading.Dependency != nil:
+		r := reading.Dependency
+		e.string(1, r.Dependency)
+		e.uint64(2, r.Requests)
+		e.uint64(3, r.Errors)
+		e.double(4, r.DurationSumSeconds)
+		for _, bucket := range r.DurationBuckets {
+			b := &encoder{}
+			b.double(1, bucket.UpperBound)
+			b.uint64(2, bucket.Count)
+			e.message(5, b.b)
+		}
+		e.uint64(6, r.SentBytes)
+		e.uint64(7, r.ReceivedBytes)
+		e.uint64(8, r.ConnectionsOpened)
+		e.uint64(9, r.ConnectionsReused)
+		e.double(10, r.ConnectSecondsSum)
+		e.uint64(11, r.TLSHandshakes)
+		e.double(12, r.TLSHandshakeSecondsSum)
+		e.uint64(13, r.FirstBytes)
+		e.double(14, r.FirstByteSecondsSum)
+		return fieldDependency, e.b, r.Timestamp, nil
+	case reading.Automation != nil:
+		r := reading.Automation
+		e.string(1, r.Rule)
+		e.bool(2, r.Active)
+		return fieldAutomation, e.b, r.Timestamp, nil
+	case reading.Derived != nil:
+		r := reading.Derived
+		e.string(1, r.Name)
+		e.string(2, r.Rule)
+		e.double(3, r.Value)
+		return fieldDerived, e.b, r.Timestamp, nil
+	case reading.Room != nil:
+		r := reading.Room
+		e.string(1, r.Room)
+		e.string(2, r.Source)
+		e.double(3, r.TemperatureCelsius)
+		return fieldRoom, e.b, r.Timestamp, nil
+	case reading.Summary != nil:
+		r := reading.Summary
+		e.string(1, r.Metric)
+		e.string(2, r.MAC)
+		e.string(3, r.SensorName)
+		e.int64(4, int64(r.SensorID))
+		e.double(5, r.Min)
+		e.double(6, r.Max)
+		e.double(7, r.Avg)
+		e.int64(8, int64(r.Count))
+		return fieldSummary, e.b, r.Timestamp, nil
+	case reading.Remote != nil:
+		r := reading.Remote
+		e.string(1, r.Metric)
+		// Map entries in key order, so equal readings encode identically
+		names := make([]string, 0, len(r.Labels))
+		for name := range r.Labels {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			l := &encoder{}
+			l.string(1, name)
+			l.string(2, r.Labels[name])
+			e.message(2, l.b)
+		}
+		e.double(3, r.Value)
+		return fieldRemote, e.b, r.Timestamp, nil
+	case reading.HTTP != nil:
+		r := reading.HTTP
+		e.string(1, r.Route)
+		e.int64(2, int64(r.Code))
+		e.uint64(3, r.Requests)
+		e.double(4, r.DurationSumSeconds)
+		for _, bucket := range r.DurationBuckets {
+			b := &encoder{}
+			b.double(1, bucket.UpperBound)
+			b.uint64(2, bucket.Count)
+			e.message(5, b.b)
+		}
+		return fieldHTTP, e.b, r.Timestamp, nil
+	case reading.Conflict != nil:
+		r := reading.Conflict
+		e.string(1, r.Kind)
+		e.string(2, r.Key)
+		e.int64(3, int64(r.Identities))
+		return fieldConflict, e.b, r.Timestamp, nil
+	case reading.Battery != nil:
+		r := reading.Battery
+		e.string(1, r.MAC)
+		e.string(2, r.SensorName)
+		e.int64(3, int64(r.SensorID))
+		e.double(4, r.DaysRemaining)
+		return fieldBattery, e.b, r.Timestamp, nil
+	case reading.Location != nil:
+		r := reading.Location
+		e.string(1, r.MAC)
+		e.string(2, r.Name)
+		e.string(3, r.Receiver)
+		e.string(4, r.Room)
+		e.double(5, r.RSSI)
+		return fieldLocation, e.b, r.Timestamp, nil
+	case reading.Reconciliation != nil:
+		r := reading.Reconciliation
+		e.string(1, r.Source)
+		e.int64(2, int64(r.Hours))
+		e.double(3, r.OfficialKWh)
+		e.double(4, r.LocalKWh)
+		e.double(5, r.DriftPercent)
+		return fieldReconciliation, e.b, r.Timestamp, nil
+	case reading.Price != nil:
+		r := reading.Price
+		e.string(1, r.Source)
+		e.int64(2, int64(r.HoursAhead))
+		e.double(3, r.PLNPerKWh)
+		return fieldPrice, e.b, r.Timestamp, nil
+	case reading.Occupancy != nil:
+		r := reading.Occupancy
+		e.bool(1, r.Home)
+		e.string(2, r.Source)
+		return fieldOccupancy, e.b, r.Timestamp, nil
+	case reading.Ventilation != nil:
+		r := reading.Ventilation
+		e.bool(1, r.Recommended)
+		return fieldVentilation, e.b, r.Timestamp, nil
+	}
+	return 0, nil, time.Time{}, fmt.Errorf("%s reading has no payload", reading.Type)
+}
+
+// unmarshalPayload decodes a payload message into the matching variant of the reading
+func unmarshalPayload(reading *buffer.Reading, payloadField protowire.Number, data []byte, timestamp time.Time) error {
+	var fn func(f field) error
+	switch payloadField {
+	case fieldBLE:
+		r := &buffer.SensorReading{Timestamp: timestamp}
+		reading.BLE = r
+		fn = func(f field) error {
+			switch f.num {
+			case 1:
+				r.MAC = f.string()
+			case 2:
+				r.SensorName = f.string()
+			case 3:
+				r.SensorID = int(f.int64())
+			case 4:
+				r.TemperatureCelsius = f.double()
+			case 5:
+				r.HumidityPercent = int(f.int64())
+			case 6:
+				r.BatteryPercent = int(f.int64())
+			case 7:
+				r.BatteryVoltageMV = int(f.int64())
+			case 8:
+				r.FrameCounter = int(f.int64())
+			case 9:
+				r.RSSI = int16(f.int64())
+			case 10:
+				r.Receiver = f.string()
+			}
+			return nil
+		}
+	case fieldThermostat:
+		r := &buffer.ThermostatReading{Timestamp: timestamp}
+		reading.Thermostat = r
+		fn = func(f field) error {
+			switch f.num {
+			case 1:
+				r.HomeID = f.string()
+			case 2:
+				r.HomeName = f.string()
+			case 3:
+				r.RoomID = f.string()
+			case 4:
+				r.RoomName = f.string()
+			case 5:
+				r.MeasuredTemperature = f.double()
+			case 6:
+				r.SetpointTemperature = f.double()
+			case 7:
+				r.SetpointMode = f.string()
+			case 8:
+				r.HeatingPowerRequest = int(f.int64())
+			case 9:
+				r.OpenWindow = f.bool()
+			case 10:
+				r.Reachable = f.bool()
+			case 11:
+				r.ErrorCode = int(f.int64())
+			case 12:
+				r.Stale = f.bool()
+			case 13:
+				r.NoValues = f.bool()
+			case 14:
+				r.Historical = f.bool()
+			}
+			return nil
+		}
+	case fieldPower:
+		r := &buffer.PowerReading{Timestamp: timestamp}
+		reading.Power = r
+		fn = func(f field) error {
+			switch f.num {
+			case 1:
+				r.SensorID = int(f.int64())
+			case 2:
+				r.Value = f.double()
+			case 3:
+				r.Stale = f.bool()
+			}
+			return nil
+		}
+	case fieldHeatPump:
+		r := &buffer.HeatPumpReading{Timestamp: timestamp}
+		reading.HeatPump = r
+		fn = func(f field) error {
+			switch f.num {
+			case 1:
+				r.Name = f.string()
+			case 2:
+				r.Value = f.double()
+			}
+			return nil
+		}
+	case fieldWater:
+		r := &buffer.WaterReading{Timestamp: timestamp}
+		reading.Water = r
+		fn = func(f field) error {
+			if f.num == 1 {
+				r.TotalLiters = f.double()
+			}
+			return nil
+		}
+	case fieldOneWire:
+		r := &buffer.OneWireReading{Timestamp: timestamp}
+		reading.OneWire = r
+		fn = func(f field) error {
+			switch f.num {
+			case 1:
+				r.DeviceID = f.string()
+			case 2:
+				r.SensorName = f.string()
+			case 3:
+				r.SensorID = int(f.int64())
+			case 4:
+				r.TemperatureCelsius = f.double()
+			}
+			return nil
+		}
+	case fieldI2C:
+		r := &buffer.I2CReading{Timestamp: timestamp}
+		reading.I2C = r
+		fn = func(f field) error {
+			switch f.num {
+			case 1:
+				r.SensorName = f.string()
+			case 2:
+				r.SensorID = int(f.int64())
+			case 3:
+				r.Model = f.string()
+			case 4:
+				r.TemperatureCelsius = f.double()
+			case 5:
+				r.HumidityPercent = f.double()
+			case 6:
+				r.PressureHPa = f.double()
+			case 7:
+				r.HasPressure = f.bool()
+			}
+			return nil
+		}
+	case fieldAirQuality:
+		r := &buffer.AirQualityReading{Timestamp: timestamp}
+		reading.AirQuality = r
+		fn = func(f field) error {
+			switch f.num {
+			case 1:
+				r.SensorName = f.string()
+			case 2:
+				r.SensorID = int(f.int64())
+			case 3:
+				r.Model = f.string()
+			case 4:
+				r.CO2PPM = f.double()
+			case 5:
+				r.TemperatureCelsius = f.double()
+			case 6:
+				r.HumidityPercent = f.double()
+			case 7:
+				r.HasClimate = f.bool()
+			}
+			return nil
+		}
+	case fieldZigbee:
+		r := &buffer.ZigbeeReading{Timestamp: timestamp}
+		reading.Zigbee = r
+		fn = func(f field) error {
+			switch f.num {
+			case 1:
+				r.Device = f.string()
+			case 2:
+				r.IEEEAddress = f.string()
+			case 3:
+				r.Model = f.string()
+			case 4:
+				r.Vendor = f.string()
+			case 5:
+				r.Class = f.string()
+			case 6:
+				r.Metric = f.string()
+			case 7:
+				r.Value = f.double()
+			}
+			return nil
+		}
+	case fieldDependency:
+		r := &buffer.DependencyReading{Timestamp: timestamp}
+		reading.Dependency = r
+		fn = func(f field) error {
+			switch f.num {
+			case 1:
+				r.Dependency = f.string()
+			case 2:
+				r.Requests = f.uint64()
+			case 3:
+				r.Errors = f.uint64()
+			case 4:
+				r.DurationSumSeconds = f.double()
+			case 5:
+				var bucket buffer.HistogramBucket
+				err := decodeFields(f.bytes, func(f field) error {
+					switch f.num {
+					case 1:
+						bucket.UpperBound = f.double()
+					case 2:
+						bucket.Count = f.uint64()
+					}
+					return nil
+				})
+				if err != nil {
+					return fmt.Errorf("invalid histogram bucket: %w", err)
+				}
+				r.DurationBuckets = append(r.DurationBuckets, bucket)
+			case 6:
+				r.SentBytes = f.uint64()
+			case 7:
+				r.ReceivedBytes = f.uint64()
+			case 8:
+				r.ConnectionsOpened = f.uint64()
+			case 9:
+				r.ConnectionsReused = f.uint64()
+			case 10:
+				r.ConnectSecondsSum = f.double()
+			case 11:
+				r.TLSHandshakes = f.uint64()
+			case 12:
+				r.TLSHandshakeSecondsSum = f.double()
+			case 13:
+				r.FirstBytes = f.uint64()
+			case 14:
+				r.FirstByteSecondsSum = f.double()
+			}
+			return nil
+		}
+	case fieldAutomation:
+		r := &buffer.AutomationReading{Timestamp: timestamp}
+		reading.Automation = r
+		fn = func(f field) error {
+			switch f.num {
+			case 1:
+				r.Rule = f.string()
+			case 2:
+				r.Active = f.bool()
+			}
+			return nil
+		}
+	case fieldDerived:
+		r := &buffer.DerivedReading{Timestamp: timestamp}
+		reading.Derived = r
+		fn = func(f field) error {
+			switch f.num {
+			case 1:
+				r.Name = f.string()
+			case 2:
+				r.Rule = f.string()
+			case 3:
+				r.Value = f.double()
+			}
+			return nil
+		}
+	case fieldRoom:
+		r := &buffer.RoomReading{Timestamp: timestamp}
+		reading.Room = r
+		fn = func(f field) error {
+			switch f.num {
+			case 1:
+				r.Room = f.string()
+			case 2:
+				r.Source = f.string()
+			case 3:
+				r.TemperatureCelsius = f.double()
+			}
+			return nil
+		}
+	case fieldSummary:
+		r := &buffer.SummaryReading{Timestamp: timestamp}
+		reading.Summary = r
+		fn = func(f field) error {
+			switch f.num {
+			case 1:
+				r.Metric = f.string()
+			case 2:
+				r.MAC = f.string()
+			case 3:
+				r.SensorName = f.string()
+			case 4:
+				r.SensorID = int(f.int64())
+			case 5:
+				r.Min = f.double()
+			case 6:
+				r.Max = f.double()
+			case 7:
+				r.Avg = f.double()
+			case 8:
+				r.Count = int(f.int64())
+			}
+			return nil
+		}
+	case fieldRemote:
+		r := &buffer.RemoteReading{Timestamp: timestamp, Labels: make(map[string]string)}
+		reading.Remote = r
+		fn = func(f field) error {
+			switch f.num {
+			case 1:
+				r.Metric = f.string()
+			case 2:
+				var name, value string
+				err := decodeFields(f.bytes, func(f field) error {
+					switch f.num {
+					case 1:
+						name = f.string()
+					case 2:
+						value = f.string()
+					}
+					return nil
+				})
+				if err != nil {
+					return fmt.Errorf("invalid label: %w", err)
+				}
+				r.Labels[name] = value
+			case 3:
+				r.Value = f.double()
+			}
+			return nil
+		}
+	case fieldHTTP:
+		r := &buffer.HTTPReading{Timestamp: timestamp}
+		reading.HTTP = r
+		fn = func(f field) error {
+			switch f.num {
+			case 1:
+				r.Route = f.string()
+			case 2:
+				r.Code = int(f.int64())
+			case 3:
+				r.Requests = f.uint64()
+			case 4:
+				r.DurationSumSeconds = f.double()
+			case 5:
+				var bucket buffer.HistogramBucket
+				err := decodeFields(f.bytes, func(f field) error {
+					switch f.num {
+					case 1:
+						bucket.UpperBound = f.double()
+					case 2:
+						bucket.Count = f.uint64()
+					}
+					return nil
+				})
+				if err != nil {
+					return fmt.Errorf("invalid histogram bucket: %w", err)
+				}
+				r.DurationBuckets = append(r.DurationBuckets, bucket)
+			}
+			return nil
+		}
+	case fieldConflict:
+		r := &buffer.ConflictReading{Timestamp: timestamp}
+		reading.Conflict = r
+		fn = func(f field) error {
+			switch f.num {
+			case 1:
+				r.Kind = f.string()
+			case 2:
+				r.Key = f.string()
+			case 3:
+				r.Identities = int(f.int64())
+			}
+			return nil
+		}
+	case fieldBattery:
+		r := &buffer.BatteryReading{Timestamp: timestamp}
+		reading.Battery = r
+		fn = func(f field) error {
+			switch f.num {
+			case 1:
+				r.MAC = f.string()
+			case 2:
+				r.SensorName = f.string()
+			case 3:
+				r.SensorID = int(f.int64())
+			case 4:
+				r.DaysRemaining = f.double()
+			}
+			return nil
+		}
+	case fieldLocation:
+		r := &buffer.LocationReading{Timestamp: timestamp}
+		reading.Location = r
+		fn = func(f field) error {
+			switch f.num {
+			case 1:
+				r.MAC = f.string()
+			case 2:
+				r.Name = f.string()
+			case 3:
+				r.Receiver = f.string()
+			case 4:
+				r.Room = f.string()
+			case 5:
+				r.RSSI = f.double()
+			}
+			return nil
+		}
+	case fieldReconciliation:
+		r := &buffer.ReconciliationReading{Timestamp: timestamp}
+		reading.Reconciliation = r
+		fn = func(f field) error {
+			switch f.num {
+			case 1:
+				r.Source = f.string()
+			case 2:
+				r.Hours = int(f.int64())
+			case 3:
+				r.OfficialKWh = f.double()
+			case 4:
+				r.LocalKWh = f.double()
+			case 5:
+				r.DriftPercent = f.double()
+			}
+			return nil
+		}
+	case fieldPrice:
+		r := &buffer.PriceReading{Timestamp: timestamp}
+		reading.Price = r
+		fn = func(f field) error {
+			switch f.num {
+			case 1:
+				r.Source = f.string()
+			case 2:
+				r.HoursAhead = int(f.int64())
+			case 3:
+				r.PLNPerKWh = f.double()
+			}
+			return nil
+		}
+	case fieldOccupancy:
+		r := &buffer.OccupancyReading{Timestamp: timestamp}
+		reading.Occupancy = r
+		fn = func(f field) error {
+			switch f.num {
+			case 1:
+				r.Home = f.bool()
+			case 2:
+				r.Source = f.string()
+			}
+			return nil
+		}
+	case fieldVentilation:
+		r := &buffer.VentilationReading{Timestamp: timestamp}
+		reading.Ventilation = r
+		fn = func(f field) error {
+			if f.num == 1 {
+				r.Recommended = f.bool()
+			}
+			return nil
+		}
+	}
+
+	if err := decodeFields(data, fn); err != nil {
+		return fmt.Errorf("invalid %s payload: %w", reading.Type, err)
+	}
+	return nil
+}
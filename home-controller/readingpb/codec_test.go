@@ -0,0 +1,137 @@
+package readingpb
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mjasion/balena-home/thermostats/buffer"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+func TestMarshalBatch_RoundTrip(t *testing.T) {
+	now := time.Unix(1700000000, 123456789)
+	readings := []*buffer.Reading{
+		{Type: buffer.ReadingTypeBLE, BLE: &buffer.SensorReading{
+			Timestamp: now, MAC: "A4:C1:38:00:00:01", SensorName: "Salon", SensorID: 2,
+			TemperatureCelsius: -3.5, HumidityPercent: 55, BatteryPercent: 90, BatteryVoltageMV: 2950, FrameCounter: 17, RSSI: -72, Receiver: "attic",
+		}},
+		{Type: buffer.ReadingTypeNetatmo, Thermostat: &buffer.ThermostatReading{
+			Timestamp: now, HomeID: "h1", HomeName: "Home", RoomID: "r1", RoomName: "Salon",
+			MeasuredTemperature: 21.2, SetpointTemperature: 21, SetpointMode: "schedule", HeatingPowerRequest: 40, Reachable: true,
+		}},
+		{Type: buffer.ReadingTypePower, Power: &buffer.PowerReading{Timestamp: now, SensorID: 1, Value: 1234.5, Stale: true}},
+		{Type: buffer.ReadingTypeHeatPump, HeatPump: &buffer.HeatPumpReading{Timestamp: now, Name: "flow_temperature_celsius", Value: 35}},
+		{Type: buffer.ReadingTypeWater, Water: &buffer.WaterReading{Timestamp: now, TotalLiters: 1500.5}},
+		{Type: buffer.ReadingTypeOneWire, OneWire: &buffer.OneWireReading{Timestamp: now, DeviceID: "28-0316a2796bff", SensorName: "Boiler", SensorID: 5, TemperatureCelsius: 55.1}},
+		{Type: buffer.ReadingTypeI2C, I2C: &buffer.I2CReading{Timestamp: now, SensorName: "Office", SensorID: 6, Model: "bme280", TemperatureCelsius: 22, HumidityPercent: 40.5, PressureHPa: 1013.2, HasPressure: true}},
+		{Type: buffer.ReadingTypeAirQuality, AirQuality: &buffer.AirQualityReading{Timestamp: now, SensorName: "Bedroom", SensorID: 7, Model: "scd4x", CO2PPM: 850, TemperatureCelsius: 20, HumidityPercent: 45, HasClimate: true}},
+		{Type: buffer.ReadingTypeZigbee, Zigbee: &buffer.ZigbeeReading{Timestamp: now, Device: "plug", IEEEAddress: "0x00158d0001", Model: "ZNCZ02LM", Vendor: "Xiaomi", Class: "plug", Metric: "power_watts", Value: 12}},
+		{Type: buffer.ReadingTypeDependency, Dependency: &buffer.DependencyReading{Timestamp: now, Dependency: "netatmo", Requests: 10, Errors: 1, SentBytes: 2048, ReceivedBytes: 4096, DurationSumSeconds: 2.5,
+			DurationBuckets:   []buffer.HistogramBucket{{UpperBound: 0.25, Count: 8}, {UpperBound: 1, Count: 10}},
+			ConnectionsOpened: 2, ConnectionsReused: 8, ConnectSecondsSum: 0.4, TLSHandshakes: 2, TLSHandshakeSecondsSum: 0.3, FirstBytes: 10, FirstByteSecondsSum: 2}},
+		{Type: buffer.ReadingTypeAutomation, Automation: &buffer.AutomationReading{Timestamp: now, Rule: "water-heater", Active: true}},
+		{Type: buffer.ReadingTypeDerived, Derived: &buffer.DerivedReading{Timestamp: now, Name: "temperature_delta_celsius", Rule: "delta", Value: 16.5}},
+		{Type: buffer.ReadingTypeRoom, Room: &buffer.RoomReading{Timestamp: now, Room: "bedroom", Source: "ble", TemperatureCelsius: 21.3}},
+		{Type: buffer.ReadingTypeSummary, Summary: &buffer.SummaryReading{Timestamp: now, Metric: "ble_temperature_celsius", MAC: "A4:C1:38:00:00:01", SensorName: "Bedroom", SensorID: 1, Min: -1.5, Max: 22, Avg: 20.25, Count: 120}},
+		{Type: buffer.ReadingTypeRemote, Remote: &buffer.RemoteReading{Timestamp: now, Metric: "esp32_uptime_seconds", Labels: map[string]string{"instance": "esp32-garage", "job": "esp32"}, Value: 3600}},
+		{Type: buffer.ReadingTypeHTTP, HTTP: &buffer.HTTPReading{Timestamp: now, Route: "GET /api/buffer", Code: 200, Requests: 3, DurationSumSeconds: 0.02,
+			DurationBuckets: []buffer.HistogramBucket{{UpperBound: 0.05, Count: 3}}}},
+		{Type: buffer.ReadingTypeConflict, Conflict: &buffer.ConflictReading{Timestamp: now, Kind: "sensor_id", Key: "3", Identities: 2}},
+		{Type: buffer.ReadingTypeBattery, Battery: &buffer.BatteryReading{Timestamp: now, MAC: "A4:C1:38:00:00:01", SensorName: "kitchen", SensorID: 1, DaysRemaining: 42.5}},
+		{Type: buffer.ReadingTypeLocation, Location: &buffer.LocationReading{Timestamp: now, MAC: "A4:C1:38:00:00:01", Name: "keys", Receiver: "attic", Room: "office", RSSI: -61.5}},
+		{Type: buffer.ReadingTypeReconciliation, Reconciliation: &buffer.ReconciliationReading{Timestamp: now, Source: "pstryk", Hours: 24, OfficialKWh: 12.5, LocalKWh: 13.1, DriftPercent: 4.8}},
+		{Type: buffer.ReadingTypePrice, Price: &buffer.PriceReading{Timestamp: now, Source: "pse", HoursAhead: 3, PLNPerKWh: 0.4521}},
+		{Type: buffer.ReadingTypeOccupancy, Occupancy: &buffer.OccupancyReading{Timestamp: now, Home: true, Source: "presence"}},
+		{Type: buffer.ReadingTypeVentilation, Ventilation: &buffer.VentilationReading{Timestamp: now, Recommended: true}},
+	}
+
+	data, err := MarshalBatch(readings)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	decoded, err := UnmarshalBatch(data)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	if len(decoded) != len(readings) {
+		t.Fatalf("Expected %d readings, got %d", len(readings), len(decoded))
+	}
+	for i := range readings {
+		if !reflect.DeepEqual(normalize(decoded[i]), normalize(readings[i])) {
+			t.Errorf("Reading %d (%s) did not round trip:\nexpected %+v\ngot      %+v", i, readings[i].Type, readings[i], decoded[i])
+		}
+	}
+}
+
+// normalize strips the monotonic clock reading so timestamps compare with DeepEqual
+func normalize(reading *buffer.Reading) *buffer.Reading {
+	copied := *reading
+	v := reflect.ValueOf(&copied).Elem()
+	for i := 1; i < v.NumField(); i++ {
+		if v.Field(i).IsNil() {
+			continue
+		}
+		payload := reflect.New(v.Field(i).Elem().Type())
+		payload.Elem().Set(v.Field(i).Elem())
+		ts := payload.Elem().FieldByName("Timestamp")
+		ts.Set(reflect.ValueOf(ts.Interface().(time.Time).Round(0).UTC()))
+		v.Field(i).Set(payload)
+	}
+	return &copied
+}
+
+func TestUnmarshal_Version(t *testing.T) {
+	e := &encoder{}
+	e.uint64(fieldSchemaVersion, SchemaVersion+1)
+	e.string(fieldType, "power")
+	e.message(fieldPower, nil)
+	if _, err := Unmarshal(e.b); err == nil || !strings.Contains(err.Error(), "unsupported schema version") {
+		t.Errorf("Expected unsupported schema version error, got: %v", err)
+	}
+
+	e = &encoder{}
+	e.string(fieldType, "power")
+	e.message(fieldPower, nil)
+	if _, err := Unmarshal(e.b); err == nil || !strings.Contains(err.Error(), "missing schema version") {
+		t.Errorf("Expected missing schema version error, got: %v", err)
+	}
+}
+
+func TestUnmarshal_SkipsUnknownFields(t *testing.T) {
+	data, err := Marshal(&buffer.Reading{
+		Type:  buffer.ReadingTypePower,
+		Power: &buffer.PowerReading{Timestamp: time.Now(), SensorID: 1, Value: 500},
+	})
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	// Fields added by a newer writer with the same schema version
+	data = protowire.AppendTag(data, 99, protowire.BytesType)
+	data = protowire.AppendString(data, "future")
+	data = protowire.AppendTag(data, 98, protowire.Fixed32Type)
+	data = protowire.AppendFixed32(data, 7)
+
+	reading, err := Unmarshal(data)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if reading.Power == nil || reading.Power.Value != 500 {
+		t.Errorf("Expected power reading with value 500, got %+v", reading)
+	}
+}
+
+func TestMarshal_Errors(t *testing.T) {
+	if _, err := Marshal(&buffer.Reading{Type: buffer.ReadingTypeBLE}); err == nil {
+		t.Error("Expected error for reading without payload")
+	}
+	if _, err := Marshal(&buffer.Reading{Type: buffer.ReadingTypeWater, Water: &buffer.WaterReading{TotalLiters: 1}}); err == nil {
+		t.Error("Expected error for reading without timestamp")
+	}
+	if _, err := Unmarshal([]byte{0xff}); err == nil {
+		t.Error("Expected error for truncated data")
+	}
+}
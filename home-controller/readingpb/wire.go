@@ -0,0 +1,108 @@
+package readingpb
+
+import (
+	"fmt"
+	"math"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// encoder appends proto3 fields, omitting zero values like generated code does
+type encoder struct {
+	b []byte
+}
+
+func (e *encoder) string(num protowire.Number, v string) {
+	if v == "" {
+		return
+	}
+	e.b = protowire.AppendTag(e.b, num, protowire.BytesType)
+	e.b = protowire.AppendString(e.b, v)
+}
+
+func (e *encoder) int64(num protowire.Number, v int64) {
+	if v == 0 {
+		return
+	}
+	e.b = protowire.AppendTag(e.b, num, protowire.VarintType)
+	e.b = protowire.AppendVarint(e.b, uint64(v))
+}
+
+func (e *encoder) uint64(num protowire.Number, v uint64) {
+	if v == 0 {
+		return
+	}
+	e.b = protowire.AppendTag(e.b, num, protowire.VarintType)
+	e.b = protowire.AppendVarint(e.b, v)
+}
+
+func (e *encoder) bool(num protowire.Number, v bool) {
+	if !v {
+		return
+	}
+	e.b = protowire.AppendTag(e.b, num, protowire.VarintType)
+	e.b = protowire.AppendVarint(e.b, 1)
+}
+
+func (e *encoder) double(num protowire.Number, v float64) {
+	if v == 0 && !math.Signbit(v) {
+		return
+	}
+	e.b = protowire.AppendTag(e.b, num, protowire.Fixed64Type)
+	e.b = protowire.AppendFixed64(e.b, math.Float64bits(v))
+}
+
+// message appends a nested message, including empty ones so oneof payloads are preserved
+func (e *encoder) message(num protowire.Number, m []byte) {
+	e.b = protowire.AppendTag(e.b, num, protowire.BytesType)
+	e.b = protowire.AppendBytes(e.b, m)
+}
+
+// field is a decoded field; scalar values are kept in the raw form of their wire type
+type field struct {
+	num   protowire.Number
+	typ   protowire.Type
+	value uint64 // Varint and fixed64 values
+	bytes []byte // Length-delimited values
+}
+
+func (f field) string() string  { return string(f.bytes) }
+func (f field) int64() int64    { return int64(f.value) }
+func (f field) uint64() uint64  { return f.value }
+func (f field) bool() bool      { return f.value != 0 }
+func (f field) double() float64 { return math.Float64frombits(f.value) }
+
+// decodeFields calls fn for every field of a message, skipping unknown wire types
+func decodeFields(data []byte, fn func(f field) error) error {
+	for len(data) > 0 {
+		num, typ, n := protowire.ConsumeTag(data)
+		if n < 0 {
+			return fmt.Errorf("invalid tag: %w", protowire.ParseError(n))
+		}
+		data = data[n:]
+
+		f := field{num: num, typ: typ}
+		switch typ {
+		case protowire.VarintType:
+			f.value, n = protowire.ConsumeVarint(data)
+		case protowire.Fixed64Type:
+			f.value, n = protowire.ConsumeFixed64(data)
+		case protowire.BytesType:
+			f.bytes, n = protowire.ConsumeBytes(data)
+		default:
+			n = protowire.ConsumeFieldValue(num, typ, data)
+		}
+		if n < 0 {
+			return fmt.Errorf("invalid field %d: %w", num, protowire.ParseError(n))
+		}
+		data = data[n:]
+
+		if typ != protowire.VarintType && typ != protowire.Fixed64Type && typ != protowire.BytesType {
+			continue
+		}
+		if err := fn(f); err != nil {
+			return err
+		}
+	}
+	return nil
+}
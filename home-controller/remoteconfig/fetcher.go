@@ -0,0 +1,233 @@
+package remoteconfig
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/mjasion/balena-home/thermostats/events"
+	"github.com/mjasion/balena-home/thermostats/httpclient"
+	"go.uber.org/zap"
+)
+
+// maxSize is the largest configuration file accepted
+const maxSize = 1 << 20
+
+// Options contains where the configuration is fetched from and how it is verified
+type Options struct {
+	URL          string // e.g. a raw file in a git repository
+	Token        string // Bearer token for private repositories; empty sends none
+	ChecksumURL  string // sha256sum output for the file; empty skips the checksum
+	SignatureURL string // Base64 Ed25519 signature of the file; defaults to URL + ".sig"
+	PublicKey    string // Base64 Ed25519 public key; empty skips the signature
+	CacheFile    string // Last verified and valid configuration, loaded on start
+	Interval     time.Duration
+}
+
+// ParsePublicKey decodes a base64 Ed25519 public key
+func ParsePublicKey(key string) (ed25519.PublicKey, error) {
+	raw, err := base64.StdEncoding.DecodeString(strings.TrimSpace(key))
+	if err != nil {
+		return nil, fmt.Errorf("public key is not base64: %w", err)
+	}
+	if len(raw) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("public key must be %d bytes, got %d", ed25519.PublicKeySize, len(raw))
+	}
+	return ed25519.PublicKey(raw), nil
+}
+
+// Fetcher keeps the cache file in sync with a remote configuration file
+// Downloads are verified against the checksum and/or signature and validated before they replace
+// the cache; Changed signals once a new configuration was cached so it can be applied
+type Fetcher struct {
+	options   Options
+	publicKey ed25519.PublicKey // Nil skips the signature
+	validate  func(path string) error
+	client    *http.Client
+	eventLog  *events.Log
+	logger    *zap.Logger
+	applied   [sha256.Size]byte // Checksum of the cached configuration
+	changed   chan struct{}
+}
+
+// New creates a fetcher; validate loads a configuration file and reports why it is invalid
+func New(options Options, validate func(path string) error, logger *zap.Logger) (*Fetcher, error) {
+	f := &Fetcher{
+		options:  options,
+		validate: validate,
+		client:   httpclient.NewDefault(httpclient.Config{Timeout: 30 * time.Second}),
+		logger:   logger,
+		changed:  make(chan struct{}, 1),
+	}
+	if f.options.SignatureURL == "" {
+		f.options.SignatureURL = options.URL + ".sig"
+	}
+	if options.PublicKey != "" {
+		key, err := ParsePublicKey(options.PublicKey)
+		if err != nil {
+			return nil, err
+		}
+		f.publicKey = key
+	}
+	if options.PublicKey == "" && options.ChecksumURL == "" {
+		return nil, fmt.Errorf("a checksum URL or a public key is required to verify the configuration")
+	}
+
+	// The cached configuration is the one loaded on start
+	if data, err := os.ReadFile(options.CacheFile); err == nil {
+		f.applied = sha256.Sum256(data)
+	}
+	return f, nil
+}
+
+// SetEventLog sets the event log used to record applied and rejected configurations
+func (f *Fetcher) SetEventLog(eventLog *events.Log) {
+	f.eventLog = eventLog
+}
+
+// Changed is signalled once a new configuration was cached
+func (f *Fetcher) Changed() <-chan struct{} {
+	return f.changed
+}
+
+// Start checks for a new configuration every interval until the context is cancelled
+func (f *Fetcher) Start(ctx context.Context) {
+	f.logger.Info("starting remote config fetcher",
+		zap.String("url", f.options.URL),
+		zap.Duration("interval", f.options.Interval),
+	)
+
+	ticker := time.NewTicker(f.options.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			f.logger.Info("stopping remote config fetcher")
+			return
+		case <-ticker.C:
+			changed, err := f.Sync(ctx)
+			if err != nil {
+				f.logger.Warn("failed to sync remote configuration", zap.Error(err))
+				continue
+			}
+			if changed {
+				select {
+				case f.changed <- struct{}{}:
+				default:
+				}
+			}
+		}
+	}
+}
+
+// Sync fetches, verifies and validates the remote configuration and caches it if it differs from
+// the cached one; reports whether the cache changed
+func (f *Fetcher) Sync(ctx context.Context) (bool, error) {
+	data, err := f.download(ctx, f.options.URL)
+	if err != nil {
+		return false, err
+	}
+	if err := f.verify(ctx, data); err != nil {
+		f.reject(err)
+		return false, err
+	}
+	checksum := sha256.Sum256(data)
+	if checksum == f.applied {
+		f.logger.Debug("remote configuration unchanged")
+		return false, nil
+	}
+
+	tmpPath := f.options.CacheFile + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0600); err != nil {
+		return false, fmt.Errorf("failed to write configuration: %w", err)
+	}
+	if err := f.validate(tmpPath); err != nil {
+		os.Remove(tmpPath)
+		err = fmt.Errorf("remote configuration is invalid: %w", err)
+		f.reject(err)
+		return false, err
+	}
+	if err := os.Rename(tmpPath, f.options.CacheFile); err != nil {
+		return false, fmt.Errorf("failed to cache configuration: %w", err)
+	}
+	f.applied = checksum
+
+	digest := hex.EncodeToString(checksum[:])
+	f.logger.Info("cached new remote configuration", zap.String("sha256", digest))
+	f.eventLog.Record(events.TypeRemoteConfigChanged, "remote_config", "new remote configuration", map[string]string{"sha256": digest})
+	return true, nil
+}
+
+// verify checks the configuration against the published checksum and signature
+func (f *Fetcher) verify(ctx context.Context, data []byte) error {
+	if f.options.ChecksumURL != "" {
+		published, err := f.download(ctx, f.options.ChecksumURL)
+		if err != nil {
+			return fmt.Errorf("failed to fetch checksum: %w", err)
+		}
+		// sha256sum output: the hex digest followed by the file name
+		fields := strings.Fields(string(published))
+		checksum := sha256.Sum256(data)
+		if len(fields) == 0 || !strings.EqualFold(fields[0], hex.EncodeToString(checksum[:])) {
+			return fmt.Errorf("checksum mismatch")
+		}
+	}
+	if f.publicKey != nil {
+		published, err := f.download(ctx, f.options.SignatureURL)
+		if err != nil {
+			return fmt.Errorf("failed to fetch signature: %w", err)
+		}
+		signature, err := base64.StdEncoding.DecodeString(string(bytes.TrimSpace(published)))
+		if err != nil {
+			return fmt.Errorf("signature is not base64: %w", err)
+		}
+		if !ed25519.Verify(f.publicKey, data, signature) {
+			return fmt.Errorf("invalid signature")
+		}
+	}
+	return nil
+}
+
+// reject logs and records a configuration that failed verification or validation
+func (f *Fetcher) reject(err error) {
+	f.logger.Error("rejected remote configuration, keeping the current one", zap.Error(err))
+	f.eventLog.Record(events.TypeRemoteConfigRejected, "remote_config", "rejected remote configuration", map[string]string{"error": err.Error()})
+}
+
+// download fetches a file, sending the token if set
+func (f *Fetcher) download(ctx context.Context, url string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	if f.options.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+f.options.Token)
+	}
+
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch %s: %w", url, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch %s: status %d", url, resp.StatusCode)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", url, err)
+	}
+	if len(data) > maxSize {
+		return nil, fmt.Errorf("%s exceeds %d bytes", url, maxSize)
+	}
+	return data, nil
+}
@@ -0,0 +1,165 @@
+package remoteconfig
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+// fakeRepository serves a configuration file with its checksum and signature
+type fakeRepository struct {
+	*httptest.Server
+
+	mu        sync.Mutex
+	files     map[string]string
+	lastToken string
+}
+
+func newFakeRepository(t *testing.T) *fakeRepository {
+	r := &fakeRepository{files: make(map[string]string)}
+	r.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		r.lastToken = req.Header.Get("Authorization")
+		content, ok := r.files[req.URL.Path]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write([]byte(content))
+	}))
+	t.Cleanup(r.Close)
+	return r
+}
+
+// publish serves content as config.yaml, signed with key and with its sha256sum
+func (r *fakeRepository) publish(content string, key ed25519.PrivateKey) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	checksum := sha256.Sum256([]byte(content))
+	r.files["/config.yaml"] = content
+	r.files["/config.yaml.sha256"] = hex.EncodeToString(checksum[:]) + "  config.yaml\n"
+	r.files["/config.yaml.sig"] = base64.StdEncoding.EncodeToString(ed25519.Sign(key, []byte(content))) + "\n"
+}
+
+// validateYAML accepts configurations without the word invalid
+func validateYAML(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	if strings.Contains(string(data), "invalid") {
+		return errors.New("invalid configuration")
+	}
+	return nil
+}
+
+func TestFetcher_SignedConfiguration(t *testing.T) {
+	publicKey, privateKey, _ := ed25519.GenerateKey(nil)
+	repo := newFakeRepository(t)
+	repo.publish("logging:\n  level: info\n", privateKey)
+	cacheFile := filepath.Join(t.TempDir(), "remote-config.yaml")
+
+	fetcher, err := New(Options{
+		URL:       repo.URL + "/config.yaml",
+		Token:     "secret",
+		PublicKey: base64.StdEncoding.EncodeToString(publicKey),
+		CacheFile: cacheFile,
+	}, validateYAML, zap.NewNop())
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	changed, err := fetcher.Sync(context.Background())
+	if err != nil || !changed {
+		t.Fatalf("Expected the configuration to be cached, got changed=%v err=%v", changed, err)
+	}
+	if cached, _ := os.ReadFile(cacheFile); string(cached) != "logging:\n  level: info\n" {
+		t.Errorf("Expected the cache to hold the configuration, got %q", cached)
+	}
+	if repo.lastToken != "Bearer secret" {
+		t.Errorf("Expected the token to be sent, got %q", repo.lastToken)
+	}
+
+	if changed, err := fetcher.Sync(context.Background()); err != nil || changed {
+		t.Errorf("Expected an unchanged configuration not to be cached again, got changed=%v err=%v", changed, err)
+	}
+
+	// A configuration signed by another key is rejected and the cache kept
+	_, otherKey, _ := ed25519.GenerateKey(nil)
+	repo.publish("logging:\n  level: debug\n", otherKey)
+	if _, err := fetcher.Sync(context.Background()); err == nil || !strings.Contains(err.Error(), "invalid signature") {
+		t.Errorf("Expected an invalid signature error, got %v", err)
+	}
+	if cached, _ := os.ReadFile(cacheFile); string(cached) != "logging:\n  level: info\n" {
+		t.Errorf("Expected the cache to be kept, got %q", cached)
+	}
+
+	// A fetcher created after a restart starts from the cache
+	restarted, _ := New(Options{
+		URL:       repo.URL + "/config.yaml",
+		PublicKey: base64.StdEncoding.EncodeToString(publicKey),
+		CacheFile: cacheFile,
+	}, validateYAML, zap.NewNop())
+	repo.publish("logging:\n  level: info\n", privateKey)
+	if changed, err := restarted.Sync(context.Background()); err != nil || changed {
+		t.Errorf("Expected the cached configuration to count as applied, got changed=%v err=%v", changed, err)
+	}
+}
+
+func TestFetcher_Checksum(t *testing.T) {
+	_, privateKey, _ := ed25519.GenerateKey(nil)
+	repo := newFakeRepository(t)
+	repo.publish("logging:\n  level: info\n", privateKey)
+	cacheFile := filepath.Join(t.TempDir(), "remote-config.yaml")
+
+	fetcher, err := New(Options{
+		URL:         repo.URL + "/config.yaml",
+		ChecksumURL: repo.URL + "/config.yaml.sha256",
+		CacheFile:   cacheFile,
+	}, validateYAML, zap.NewNop())
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	// The file changed without its checksum being republished
+	repo.mu.Lock()
+	repo.files["/config.yaml"] = "logging:\n  level: debug\n"
+	repo.mu.Unlock()
+	if _, err := fetcher.Sync(context.Background()); err == nil || !strings.Contains(err.Error(), "checksum mismatch") {
+		t.Errorf("Expected a checksum mismatch, got %v", err)
+	}
+	if _, err := os.Stat(cacheFile); !os.IsNotExist(err) {
+		t.Error("Expected nothing to be cached")
+	}
+
+	// A verified but invalid configuration is rejected
+	repo.publish("invalid: true\n", privateKey)
+	if _, err := fetcher.Sync(context.Background()); err == nil || !strings.Contains(err.Error(), "invalid configuration") {
+		t.Errorf("Expected a validation error, got %v", err)
+	}
+	if _, err := os.Stat(cacheFile); !os.IsNotExist(err) {
+		t.Error("Expected an invalid configuration not to be cached")
+	}
+}
+
+func TestNew_RequiresVerification(t *testing.T) {
+	if _, err := New(Options{URL: "http://example.com/config.yaml"}, validateYAML, zap.NewNop()); err == nil {
+		t.Error("Expected an error without a checksum URL or public key")
+	}
+	if _, err := New(Options{URL: "http://example.com/config.yaml", PublicKey: "c2hvcnQ="}, validateYAML, zap.NewNop()); err == nil {
+		t.Error("Expected an error for a short public key")
+	}
+}
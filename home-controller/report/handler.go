@@ -0,0 +1,85 @@
+package report
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/mjasion/balena-home/thermostats/admin"
+)
+
+// RegisterHandlers registers the report preview and delivery endpoints on the admin server
+func (r *Reporter) RegisterHandlers(server *admin.Server) {
+	server.HandleFunc("GET /api/report", r.handleReport)
+	server.HandleFunc("POST /api/report/send", r.handleSend)
+}
+
+// parseDate returns the day of the date parameter, today when absent
+func (r *Reporter) parseDate(req *http.Request) (time.Time, bool) {
+	value := req.URL.Query().Get("date")
+	if value == "" {
+		return r.now(), true
+	}
+	date, err := time.ParseInLocation(dateLayout, value, time.Local)
+	return date, err == nil
+}
+
+// handleReport handles GET /api/report?date=<YYYY-MM-DD>&format=<json|text|html>
+func (r *Reporter) handleReport(w http.ResponseWriter, req *http.Request) {
+	date, ok := r.parseDate(req)
+	if !ok {
+		admin.WriteError(w, http.StatusBadRequest, "date must be in YYYY-MM-DD format")
+		return
+	}
+	report := r.Build(date)
+
+	var (
+		body        string
+		contentType string
+		err         error
+	)
+	switch req.URL.Query().Get("format") {
+	case "", "json":
+		admin.WriteJSON(w, http.StatusOK, admin.Response{
+			Success: true,
+			Message: "report for " + report.Date,
+			Data:    report,
+		})
+		return
+	case "text":
+		body, err = report.Text()
+		contentType = "text/plain; charset=utf-8"
+	case "html":
+		body, err = report.HTML()
+		contentType = "text/html; charset=utf-8"
+	default:
+		admin.WriteError(w, http.StatusBadRequest, "format must be json, text or html")
+		return
+	}
+	if err != nil {
+		admin.WriteError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Write([]byte(body))
+}
+
+// handleSend handles POST /api/report/send?date=<YYYY-MM-DD>, delivering a report immediately
+func (r *Reporter) handleSend(w http.ResponseWriter, req *http.Request) {
+	date, ok := r.parseDate(req)
+	if !ok {
+		admin.WriteError(w, http.StatusBadRequest, "date must be in YYYY-MM-DD format")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(req.Context(), time.Minute)
+	defer cancel()
+	if err := r.Send(ctx, date); err != nil {
+		admin.WriteError(w, http.StatusBadGateway, err.Error())
+		return
+	}
+	admin.WriteJSON(w, http.StatusOK, admin.Response{
+		Success: true,
+		Message: "report sent for " + date.Format(dateLayout),
+	})
+}
@@ -0,0 +1,173 @@
+package report
+
+import (
+	"bytes"
+	"fmt"
+	htmltemplate "html/template"
+	"text/template"
+	"time"
+
+	"github.com/mjasion/balena-home/thermostats/notify"
+)
+
+// Report summarises one day of the home
+type Report struct {
+	Title             string             `json:"title"`
+	Date              string             `json:"date"`
+	Partial           bool               `json:"partial"` // The controller didn't run the whole day, or the day isn't over
+	TemperatureSource string             `json:"temperature_source,omitempty"`
+	Temperatures      []TemperatureRange `json:"temperatures"`
+	Energy            []EnergyUsage      `json:"energy"`
+	TotalKWh          float64            `json:"total_kwh"`
+	TotalCost         float64            `json:"total_cost"`
+	HasCost           bool               `json:"-"`
+	Currency          string             `json:"currency"`
+	Alerts            []Alert            `json:"alerts"`
+	Sources           []SourceUptime     `json:"sources"`
+	Uptime            time.Duration      `json:"-"`
+	UptimeSeconds     int64              `json:"uptime_seconds"` // Of the controller process
+}
+
+// TemperatureRange is the lowest and highest temperature of a room or sensor
+type TemperatureRange struct {
+	Name  string    `json:"name"`
+	Min   float64   `json:"min"`
+	MinAt time.Time `json:"min_at"`
+	Max   float64   `json:"max"`
+	MaxAt time.Time `json:"max_at"`
+}
+
+// EnergyUsage is the energy consumed through one power sensor
+type EnergyUsage struct {
+	SensorID int     `json:"sensor_id"`
+	KWh      float64 `json:"kwh"`
+	Cost     float64 `json:"cost"`
+}
+
+// Alert is an event recorded during the day
+type Alert struct {
+	Time    time.Time `json:"time"`
+	Type    string    `json:"type"`
+	Message string    `json:"message"`
+}
+
+// SourceUptime is the share of the day a reading source delivered readings
+type SourceUptime struct {
+	Source  string  `json:"source"`
+	Percent float64 `json:"percent"`
+}
+
+// Subject returns the message subject
+func (r *Report) Subject() string {
+	return fmt.Sprintf("%s %s", r.Title, r.Date)
+}
+
+var templateFuncs = map[string]any{
+	"clock":    func(t time.Time) string { return t.Local().Format("15:04") },
+	"duration": formatDuration,
+}
+
+var textTemplate = template.Must(template.New("text").Funcs(templateFuncs).Parse(
+	`{{.Title}} {{.Date}}{{if .Partial}} (partial){{end}}
+{{if .Temperatures}}
+Temperatures ({{.TemperatureSource}}):
+{{- range .Temperatures}}
+  {{.Name}}: {{printf "%.1f" .Min}}°C at {{clock .MinAt}} – {{printf "%.1f" .Max}}°C at {{clock .MaxAt}}
+{{- end}}
+{{end}}{{if .Energy}}
+Energy: {{printf "%.2f" .TotalKWh}} kWh{{if .HasCost}}, {{printf "%.2f" .TotalCost}} {{.Currency}}{{end}}
+{{- range .Energy}}
+  sensor {{.SensorID}}: {{printf "%.2f" .KWh}} kWh
+{{- end}}
+{{end}}
+Alerts: {{len .Alerts}}
+{{- range .Alerts}}
+  {{clock .Time}} {{.Type}}: {{.Message}}
+{{- end}}
+
+Sources:
+{{- range .Sources}}
+  {{.Source}}: {{printf "%.1f" .Percent}}%
+{{- end}}
+Controller uptime: {{duration .Uptime}}
+`))
+
+var htmlTemplate = htmltemplate.Must(htmltemplate.New("html").Funcs(templateFuncs).Parse(
+	`<!DOCTYPE html>
+<html>
+<body style="font-family: sans-serif">
+<h2>{{.Title}} {{.Date}}{{if .Partial}} <small>(partial)</small>{{end}}</h2>
+{{if .Temperatures}}
+<h3>Temperatures ({{.TemperatureSource}})</h3>
+<table cellpadding="4">
+<tr><th align="left">Name</th><th>Min</th><th>Max</th></tr>
+{{- range .Temperatures}}
+<tr><td>{{.Name}}</td><td>{{printf "%.1f" .Min}}°C at {{clock .MinAt}}</td><td>{{printf "%.1f" .Max}}°C at {{clock .MaxAt}}</td></tr>
+{{- end}}
+</table>
+{{end}}{{if .Energy}}
+<h3>Energy: {{printf "%.2f" .TotalKWh}} kWh{{if .HasCost}}, {{printf "%.2f" .TotalCost}} {{.Currency}}{{end}}</h3>
+<table cellpadding="4">
+{{- range .Energy}}
+<tr><td>Sensor {{.SensorID}}</td><td>{{printf "%.2f" .KWh}} kWh</td></tr>
+{{- end}}
+</table>
+{{end}}
+<h3>Alerts: {{len .Alerts}}</h3>
+{{if .Alerts}}<ul>
+{{- range .Alerts}}
+<li>{{clock .Time}} <b>{{.Type}}</b>: {{.Message}}</li>
+{{- end}}
+</ul>{{end}}
+<h3>Sources</h3>
+<table cellpadding="4">
+{{- range .Sources}}
+<tr><td>{{.Source}}</td><td>{{printf "%.1f" .Percent}}%</td></tr>
+{{- end}}
+</table>
+<p>Controller uptime: {{duration .Uptime}}</p>
+</body>
+</html>
+`))
+
+// Text renders the report as plain text
+func (r *Report) Text() (string, error) {
+	var out bytes.Buffer
+	if err := textTemplate.Execute(&out, r); err != nil {
+		return "", fmt.Errorf("failed to render text report: %w", err)
+	}
+	return out.String(), nil
+}
+
+// HTML renders the report as an HTML document
+func (r *Report) HTML() (string, error) {
+	var out bytes.Buffer
+	if err := htmlTemplate.Execute(&out, r); err != nil {
+		return "", fmt.Errorf("failed to render HTML report: %w", err)
+	}
+	return out.String(), nil
+}
+
+// Message renders the report as a notification
+func (r *Report) Message() (notify.Message, error) {
+	text, err := r.Text()
+	if err != nil {
+		return notify.Message{}, err
+	}
+	html, err := r.HTML()
+	if err != nil {
+		return notify.Message{}, err
+	}
+	return notify.Message{Subject: r.Subject(), Text: text, HTML: html}, nil
+}
+
+// formatDuration formats a duration as days, hours and minutes
+func formatDuration(d time.Duration) string {
+	days := int(d / (24 * time.Hour))
+	hours := int(d % (24 * time.Hour) / time.Hour)
+	minutes := int(d % time.Hour / time.Minute)
+	if days > 0 {
+		return fmt.Sprintf("%dd %dh %dm", days, hours, minutes)
+	}
+	return fmt.Sprintf("%dh %dm", hours, minutes)
+}
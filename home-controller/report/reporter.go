@@ -0,0 +1,318 @@
+package report
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/mjasion/balena-home/thermostats/automation"
+	"github.com/mjasion/balena-home/thermostats/buffer"
+	"github.com/mjasion/balena-home/thermostats/events"
+	"github.com/mjasion/balena-home/thermostats/notify"
+	"go.uber.org/zap"
+)
+
+// slotDuration is the resolution of source uptime: a source is up for a slot with at least one reading
+const slotDuration = 5 * time.Minute
+
+// maxEnergyGap is the longest gap between power readings that is integrated; longer gaps
+// (a failing meter or a restart) count as unknown rather than as the last value held
+const maxEnergyGap = 5 * time.Minute
+
+// dateLayout formats the day a report covers
+const dateLayout = "2006-01-02"
+
+// temperatureRange tracks the extremes of one room or sensor
+type temperatureRange struct {
+	min, max     float64
+	minAt, maxAt time.Time
+}
+
+// meter integrates the active power of one power sensor
+type meter struct {
+	last      time.Time
+	lastWatts float64
+	wattHours float64
+}
+
+// day holds the statistics collected for one local calendar day
+type day struct {
+	rooms   map[string]*temperatureRange // From room fusion
+	sensors map[string]*temperatureRange // From BLE sensors, used without room fusion
+	meters  map[int]*meter
+	slots   map[buffer.ReadingType]map[int]bool // Slots with readings per source
+}
+
+// Reporter collects daily statistics from the reading stream and sends a summary of the previous day
+// through the notifiers every morning
+type Reporter struct {
+	title     string
+	sendAt    time.Duration // Offset from local midnight
+	price     float64
+	currency  string
+	notifiers []notify.Notifier
+	logger    *zap.Logger
+	eventLog  *events.Log
+	now       func() time.Time
+	started   time.Time
+
+	mu   sync.Mutex
+	days map[string]*day // By date
+}
+
+// New creates a reporter sending at sendAt, a local "15:04" time; a price of 0 leaves out the cost.
+// Register Observe as a buffer listener to feed it.
+func New(title, sendAt string, pricePerKWh float64, currency string, notifiers []notify.Notifier, logger *zap.Logger) (*Reporter, error) {
+	at, err := time.Parse("15:04", sendAt)
+	if err != nil {
+		return nil, fmt.Errorf("invalid send time %q, expected HH:MM", sendAt)
+	}
+	return &Reporter{
+		title:     title,
+		sendAt:    time.Duration(at.Hour())*time.Hour + time.Duration(at.Minute())*time.Minute,
+		price:     pricePerKWh,
+		currency:  currency,
+		notifiers: notifiers,
+		logger:    logger,
+		now:       time.Now,
+		started:   time.Now(),
+		days:      make(map[string]*day),
+	}, nil
+}
+
+// SetEventLog sets the event log whose events of the day are listed as alerts
+func (r *Reporter) SetEventLog(eventLog *events.Log) {
+	r.eventLog = eventLog
+}
+
+// Observe adds a reading to the statistics of its local day
+func (r *Reporter) Observe(reading *buffer.Reading) {
+	timestamp := automation.TimestampOf(reading)
+	if timestamp.IsZero() {
+		return
+	}
+	local := timestamp.Local()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	d := r.day(local)
+	slots, ok := d.slots[reading.Type]
+	if !ok {
+		slots = make(map[int]bool)
+		d.slots[reading.Type] = slots
+	}
+	slots[int(local.Sub(midnight(local))/slotDuration)] = true
+
+	switch {
+	case reading.Room != nil:
+		observeTemperature(d.rooms, reading.Room.Room, reading.Room.TemperatureCelsius, local)
+	case reading.BLE != nil:
+		observeTemperature(d.sensors, reading.BLE.SensorName, reading.BLE.TemperatureCelsius, local)
+	case reading.Power != nil && !reading.Power.Stale:
+		r.observePower(reading.Power.SensorID, reading.Power.Value, local)
+	}
+}
+
+// day returns the statistics of the day containing t, creating them; the caller must hold the lock
+func (r *Reporter) day(t time.Time) *day {
+	date := t.Format(dateLayout)
+	d, ok := r.days[date]
+	if !ok {
+		d = &day{
+			rooms:   make(map[string]*temperatureRange),
+			sensors: make(map[string]*temperatureRange),
+			meters:  make(map[int]*meter),
+			slots:   make(map[buffer.ReadingType]map[int]bool),
+		}
+		r.days[date] = d
+	}
+	return d
+}
+
+// observeTemperature widens the range of a room or sensor
+func observeTemperature(ranges map[string]*temperatureRange, name string, celsius float64, at time.Time) {
+	tr, ok := ranges[name]
+	if !ok {
+		ranges[name] = &temperatureRange{min: celsius, max: celsius, minAt: at, maxAt: at}
+		return
+	}
+	if celsius < tr.min {
+		tr.min, tr.minAt = celsius, at
+	}
+	if celsius > tr.max {
+		tr.max, tr.maxAt = celsius, at
+	}
+}
+
+// observePower integrates active power with the trapezoidal rule; energy between two readings
+// is credited to the day of the later one. The caller must hold the lock.
+func (r *Reporter) observePower(sensorID int, watts float64, at time.Time) {
+	m, ok := r.day(at).meters[sensorID]
+	if !ok {
+		m = &meter{}
+		r.day(at).meters[sensorID] = m
+		// Continue from the previous day's last reading, so energy around midnight isn't lost
+		if previous, ok := r.days[at.AddDate(0, 0, -1).Format(dateLayout)]; ok {
+			if pm, ok := previous.meters[sensorID]; ok {
+				m.last, m.lastWatts = pm.last, pm.lastWatts
+			}
+		}
+	}
+	if !m.last.IsZero() && at.After(m.last) && at.Sub(m.last) <= maxEnergyGap {
+		m.wattHours += (m.lastWatts + watts) / 2 * at.Sub(m.last).Hours()
+	}
+	if at.After(m.last) {
+		m.last, m.lastWatts = at, watts
+	}
+}
+
+// Build returns the report of a local calendar day from the statistics collected so far
+func (r *Reporter) Build(date time.Time) *Report {
+	start := midnight(date.Local())
+	end := start.AddDate(0, 0, 1)
+	now := r.now()
+
+	report := &Report{
+		Title:    r.title,
+		Date:     start.Format(dateLayout),
+		Currency: r.currency,
+		Uptime:   now.Sub(r.started).Round(time.Minute),
+		// Empty rather than nil, so the JSON has lists
+		Temperatures: []TemperatureRange{},
+		Energy:       []EnergyUsage{},
+		Alerts:       []Alert{},
+		Sources:      []SourceUptime{},
+	}
+	report.UptimeSeconds = int64(report.Uptime.Seconds())
+
+	// Sources are measured over the part of the day the reporter has been running
+	from, to := start, end
+	if r.started.After(from) {
+		from = r.started
+		report.Partial = true
+	}
+	if now.Before(to) {
+		to = now
+		report.Partial = true
+	}
+
+	r.mu.Lock()
+	d := r.days[report.Date]
+	if d != nil {
+		ranges, source := d.rooms, "room"
+		if len(ranges) == 0 {
+			ranges, source = d.sensors, "sensor"
+		}
+		for name, tr := range ranges {
+			report.Temperatures = append(report.Temperatures, TemperatureRange{
+				Name:  name,
+				Min:   tr.min,
+				MinAt: tr.minAt,
+				Max:   tr.max,
+				MaxAt: tr.maxAt,
+			})
+		}
+		report.TemperatureSource = source
+
+		for sensorID, m := range d.meters {
+			kwh := m.wattHours / 1000
+			report.Energy = append(report.Energy, EnergyUsage{SensorID: sensorID, KWh: kwh, Cost: kwh * r.price})
+			report.TotalKWh += kwh
+		}
+		report.TotalCost = report.TotalKWh * r.price
+		report.HasCost = r.price > 0
+
+		if expected := int(to.Sub(from) / slotDuration); expected > 0 {
+			for readingType, slots := range d.slots {
+				percent := 100 * float64(len(slots)) / float64(expected)
+				if percent > 100 {
+					percent = 100
+				}
+				report.Sources = append(report.Sources, SourceUptime{Source: string(readingType), Percent: percent})
+			}
+		}
+	}
+	r.mu.Unlock()
+
+	var alerts []events.Event
+	if r.eventLog != nil {
+		alerts = r.eventLog.List(events.Filter{})
+	}
+	for _, event := range alerts {
+		if !event.Timestamp.Before(start) && event.Timestamp.Before(end) {
+			report.Alerts = append(report.Alerts, Alert{Time: event.Timestamp, Type: event.Type, Message: event.Message})
+		}
+	}
+
+	sort.Slice(report.Temperatures, func(i, j int) bool { return report.Temperatures[i].Name < report.Temperatures[j].Name })
+	sort.Slice(report.Energy, func(i, j int) bool { return report.Energy[i].SensorID < report.Energy[j].SensorID })
+	sort.Slice(report.Sources, func(i, j int) bool { return report.Sources[i].Source < report.Sources[j].Source })
+	return report
+}
+
+// Send builds the report of a day and delivers it through every notifier
+func (r *Reporter) Send(ctx context.Context, date time.Time) error {
+	report := r.Build(date)
+	message, err := report.Message()
+	if err != nil {
+		return err
+	}
+	if err := notify.SendAll(ctx, r.notifiers, message); err != nil {
+		return fmt.Errorf("failed to deliver report for %s: %w", report.Date, err)
+	}
+	r.logger.Info("daily report sent",
+		zap.String("date", report.Date),
+		zap.Int("notifier_count", len(r.notifiers)),
+	)
+	return nil
+}
+
+// Start sends the previous day's report at the send time every day until the context is cancelled
+func (r *Reporter) Start(ctx context.Context) {
+	r.logger.Info("starting daily report", zap.Duration("send_at", r.sendAt))
+
+	for {
+		now := r.now()
+		next := midnight(now).Add(r.sendAt)
+		if !next.After(now) {
+			next = midnight(now.AddDate(0, 0, 1)).Add(r.sendAt)
+		}
+		timer := time.NewTimer(next.Sub(now))
+
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			r.logger.Info("stopping daily report")
+			return
+		case <-timer.C:
+			yesterday := next.AddDate(0, 0, -1)
+			sendCtx, cancel := context.WithTimeout(ctx, time.Minute)
+			if err := r.Send(sendCtx, yesterday); err != nil {
+				r.logger.Warn("failed to send daily report", zap.Error(err))
+			}
+			cancel()
+			r.prune(yesterday)
+		}
+	}
+}
+
+// prune drops the statistics of days before the given one
+func (r *Reporter) prune(keep time.Time) {
+	oldest := midnight(keep.Local()).Format(dateLayout)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for date := range r.days {
+		if date < oldest {
+			delete(r.days, date)
+		}
+	}
+}
+
+// midnight returns the start of the local day containing t
+func midnight(t time.Time) time.Time {
+	year, month, dayOfMonth := t.Date()
+	return time.Date(year, month, dayOfMonth, 0, 0, 0, 0, t.Location())
+}
@@ -0,0 +1,253 @@
+package report
+
+import (
+	"context"
+	"errors"
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mjasion/balena-home/thermostats/buffer"
+	"github.com/mjasion/balena-home/thermostats/events"
+	"github.com/mjasion/balena-home/thermostats/notify"
+	"go.uber.org/zap"
+)
+
+// fakeNotifier records the messages it is asked to send
+type fakeNotifier struct {
+	messages []notify.Message
+	err      error
+}
+
+func (n *fakeNotifier) Name() string { return "fake" }
+
+func (n *fakeNotifier) Send(ctx context.Context, message notify.Message) error {
+	n.messages = append(n.messages, message)
+	return n.err
+}
+
+func newTestReporter(t *testing.T, price float64, notifiers ...notify.Notifier) (*Reporter, time.Time) {
+	t.Helper()
+	reporter, err := New("Home report", "07:00", price, "PLN", notifiers, zap.NewNop())
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	day := time.Date(2026, 3, 10, 0, 0, 0, 0, time.Local)
+	reporter.started = day.Add(-time.Hour)
+	reporter.now = func() time.Time { return day.AddDate(0, 0, 1).Add(7 * time.Hour) }
+	return reporter, day
+}
+
+func powerReading(ts time.Time, watts float64) *buffer.Reading {
+	return &buffer.Reading{
+		Type:  buffer.ReadingTypePower,
+		Power: &buffer.PowerReading{Timestamp: ts, SensorID: 1, Value: watts},
+	}
+}
+
+func roomReading(ts time.Time, room string, celsius float64) *buffer.Reading {
+	return &buffer.Reading{
+		Type: buffer.ReadingTypeRoom,
+		Room: &buffer.RoomReading{Timestamp: ts, Room: room, TemperatureCelsius: celsius},
+	}
+}
+
+func TestNew_InvalidSendTime(t *testing.T) {
+	for _, sendAt := range []string{"", "7", "25:00", "07:60"} {
+		if _, err := New("Home report", sendAt, 0, "PLN", nil, zap.NewNop()); err == nil {
+			t.Errorf("Expected error for send time %q", sendAt)
+		}
+	}
+}
+
+func TestReporter_Energy(t *testing.T) {
+	reporter, day := newTestReporter(t, 0.5)
+
+	// 1 kW for 2 hours, sampled every minute
+	for m := 0; m <= 120; m++ {
+		reporter.Observe(powerReading(day.Add(time.Duration(m)*time.Minute), 1000))
+	}
+	// A gap longer than maxEnergyGap is not integrated
+	reporter.Observe(powerReading(day.Add(5*time.Hour), 1000))
+	// Stale values repeated after failed scrapes are ignored
+	stale := powerReading(day.Add(5*time.Hour+time.Minute), 5000)
+	stale.Power.Stale = true
+	reporter.Observe(stale)
+
+	report := reporter.Build(day)
+	if math.Abs(report.TotalKWh-2) > 0.001 {
+		t.Errorf("Expected 2 kWh, got %.3f", report.TotalKWh)
+	}
+	if math.Abs(report.TotalCost-1) > 0.001 {
+		t.Errorf("Expected cost 1.00, got %.3f", report.TotalCost)
+	}
+	if len(report.Energy) != 1 || report.Energy[0].SensorID != 1 {
+		t.Errorf("Expected energy of sensor 1, got %+v", report.Energy)
+	}
+}
+
+func TestReporter_EnergyAcrossMidnight(t *testing.T) {
+	reporter, day := newTestReporter(t, 0)
+
+	reporter.Observe(powerReading(day.Add(-2*time.Minute), 600))
+	reporter.Observe(powerReading(day.Add(2*time.Minute), 600))
+
+	// The interval ending after midnight is credited to the new day
+	report := reporter.Build(day)
+	if math.Abs(report.TotalKWh-0.04) > 0.0001 {
+		t.Errorf("Expected 0.04 kWh, got %.4f", report.TotalKWh)
+	}
+	if report.HasCost {
+		t.Errorf("Expected no cost without a price")
+	}
+}
+
+func TestReporter_Temperatures(t *testing.T) {
+	reporter, day := newTestReporter(t, 0)
+
+	reporter.Observe(roomReading(day.Add(3*time.Hour), "living", 19.5))
+	reporter.Observe(roomReading(day.Add(15*time.Hour), "living", 22.5))
+	reporter.Observe(roomReading(day.Add(12*time.Hour), "living", 21))
+	reporter.Observe(roomReading(day.Add(12*time.Hour), "bedroom", 18))
+	// Readings of other days don't count
+	reporter.Observe(roomReading(day.Add(25*time.Hour), "living", 30))
+
+	report := reporter.Build(day)
+	if report.TemperatureSource != "room" {
+		t.Errorf("Expected room temperatures, got %q", report.TemperatureSource)
+	}
+	if len(report.Temperatures) != 2 {
+		t.Fatalf("Expected 2 rooms, got %d", len(report.Temperatures))
+	}
+	living := report.Temperatures[1]
+	if living.Name != "living" || living.Min != 19.5 || living.Max != 22.5 {
+		t.Errorf("Expected living 19.5-22.5, got %+v", living)
+	}
+	if !living.MinAt.Equal(day.Add(3*time.Hour)) || !living.MaxAt.Equal(day.Add(15*time.Hour)) {
+		t.Errorf("Expected extremes at 03:00 and 15:00, got %s and %s", living.MinAt, living.MaxAt)
+	}
+}
+
+func TestReporter_SensorTemperaturesWithoutRooms(t *testing.T) {
+	reporter, day := newTestReporter(t, 0)
+
+	reporter.Observe(&buffer.Reading{
+		Type: buffer.ReadingTypeBLE,
+		BLE:  &buffer.SensorReading{Timestamp: day.Add(time.Hour), SensorName: "kitchen", TemperatureCelsius: 20},
+	})
+
+	report := reporter.Build(day)
+	if report.TemperatureSource != "sensor" || len(report.Temperatures) != 1 || report.Temperatures[0].Name != "kitchen" {
+		t.Errorf("Expected kitchen sensor temperature, got %q %+v", report.TemperatureSource, report.Temperatures)
+	}
+}
+
+func TestReporter_SourceUptime(t *testing.T) {
+	reporter, day := newTestReporter(t, 0)
+
+	// Readings for the first 12 hours only
+	for m := 0; m < 12*60; m += 5 {
+		reporter.Observe(powerReading(day.Add(time.Duration(m)*time.Minute), 100))
+	}
+
+	report := reporter.Build(day)
+	if len(report.Sources) != 1 || report.Sources[0].Source != "power" {
+		t.Fatalf("Expected power source, got %+v", report.Sources)
+	}
+	if math.Abs(report.Sources[0].Percent-50) > 0.01 {
+		t.Errorf("Expected 50%% uptime, got %.2f", report.Sources[0].Percent)
+	}
+	if report.Partial {
+		t.Errorf("Expected a complete day")
+	}
+}
+
+func TestReporter_PartialDay(t *testing.T) {
+	reporter, day := newTestReporter(t, 0)
+	reporter.started = day.Add(12 * time.Hour)
+
+	for m := 12 * 60; m < 24*60; m += 5 {
+		reporter.Observe(powerReading(day.Add(time.Duration(m)*time.Minute), 100))
+	}
+
+	// Uptime counts only the time the controller was running
+	report := reporter.Build(day)
+	if !report.Partial {
+		t.Errorf("Expected a partial day")
+	}
+	if len(report.Sources) != 1 || math.Abs(report.Sources[0].Percent-100) > 0.01 {
+		t.Errorf("Expected 100%% uptime, got %+v", report.Sources)
+	}
+}
+
+func TestReporter_Alerts(t *testing.T) {
+	reporter, day := newTestReporter(t, 0)
+	eventLog := events.NewLog(10, zap.NewNop())
+	reporter.SetEventLog(eventLog)
+	eventLog.Record(events.TypeBatteryLow, "battery", "kitchen battery low", nil)
+
+	// Events are timestamped now; only today's report lists it
+	if report := reporter.Build(time.Now()); len(report.Alerts) != 1 || report.Alerts[0].Type != events.TypeBatteryLow {
+		t.Errorf("Expected battery_low alert, got %+v", report.Alerts)
+	}
+	if report := reporter.Build(day); len(report.Alerts) != 0 {
+		t.Errorf("Expected no alerts, got %+v", report.Alerts)
+	}
+}
+
+func TestReporter_Send(t *testing.T) {
+	first := &fakeNotifier{err: errors.New("unreachable")}
+	second := &fakeNotifier{}
+	reporter, day := newTestReporter(t, 0.5, first, second)
+	reporter.Observe(roomReading(day.Add(time.Hour), "living", 21))
+
+	// A failing notifier doesn't stop delivery through the others
+	err := reporter.Send(context.Background(), day)
+	if err == nil || !strings.Contains(err.Error(), "fake: unreachable") {
+		t.Errorf("Expected delivery error, got %v", err)
+	}
+	if len(second.messages) != 1 {
+		t.Fatalf("Expected 1 message, got %d", len(second.messages))
+	}
+	message := second.messages[0]
+	if message.Subject != "Home report 2026-03-10" {
+		t.Errorf("Expected subject 'Home report 2026-03-10', got %q", message.Subject)
+	}
+	if !strings.Contains(message.Text, "living: 21.0°C at 01:00") {
+		t.Errorf("Expected living temperature in text, got %q", message.Text)
+	}
+	if !strings.Contains(message.HTML, "<td>living</td>") {
+		t.Errorf("Expected living temperature in HTML, got %q", message.HTML)
+	}
+}
+
+func TestReport_HTMLEscapes(t *testing.T) {
+	report := &Report{
+		Title:  "Home",
+		Date:   "2026-03-10",
+		Alerts: []Alert{{Type: "custom", Message: "<script>"}},
+	}
+	html, err := report.HTML()
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if strings.Contains(html, "<script>") {
+		t.Errorf("Expected alert message to be escaped, got %q", html)
+	}
+}
+
+func TestFormatDuration(t *testing.T) {
+	tests := []struct {
+		duration time.Duration
+		expected string
+	}{
+		{90 * time.Minute, "1h 30m"},
+		{50*time.Hour + 5*time.Minute, "2d 2h 5m"},
+	}
+	for _, tt := range tests {
+		if got := formatDuration(tt.duration); got != tt.expected {
+			t.Errorf("Expected %q, got %q", tt.expected, got)
+		}
+	}
+}
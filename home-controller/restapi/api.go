@@ -0,0 +1,146 @@
+package restapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/mjasion/balena-home/thermostats/admin"
+	"github.com/mjasion/balena-home/thermostats/version"
+)
+
+// BasePath prefixes every versioned endpoint; breaking changes get a new version, not a new shape
+const BasePath = "/api/v1"
+
+// Operation documents an endpoint; it is the annotation the OpenAPI spec is generated from
+type Operation struct {
+	Method      string
+	Path        string // Relative to BasePath, e.g. "/readings"
+	ID          string // Stable operationId, e.g. "listReadings"
+	Summary     string
+	Description string
+	Tag         string
+	Parameters  []Parameter
+	Status      int         // Success status, 200 when zero
+	Data        interface{} // Zero value of the response data; its type becomes the data schema
+}
+
+// Parameter documents a query parameter
+type Parameter struct {
+	Name        string
+	Description string
+	Type        string // OpenAPI type, e.g. "string" or "integer"
+	Required    bool
+}
+
+// route is an operation with its handler
+type route struct {
+	operation Operation
+	handler   http.HandlerFunc
+}
+
+// API collects the versioned endpoints so they are registered and documented together
+type API struct {
+	routes []route
+}
+
+// New creates an empty API
+func New() *API {
+	return &API{}
+}
+
+// Handle adds an endpoint; it is registered on the admin server by RegisterHandlers
+func (a *API) Handle(operation Operation, handler http.HandlerFunc) {
+	a.routes = append(a.routes, route{operation: operation, handler: handler})
+}
+
+// RegisterHandlers registers the endpoints and GET /api/v1/openapi.json on the admin server
+func (a *API) RegisterHandlers(server *admin.Server) {
+	for _, r := range a.routes {
+		server.HandleFunc(r.operation.Method+" "+BasePath+r.operation.Path, r.handler)
+	}
+	server.HandleFunc("GET "+BasePath+"/openapi.json", a.handleSpec)
+}
+
+// handleSpec handles GET /api/v1/openapi.json; unlike other endpoints it returns the document
+// itself, so generators and Swagger UI can load it directly
+func (a *API) handleSpec(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, a.Spec())
+}
+
+// Spec generates the OpenAPI document of the registered endpoints
+func (a *API) Spec() Document {
+	doc := Document{
+		OpenAPI: "3.0.3",
+		Info: Info{
+			Title:       "home-controller",
+			Description: "Readings, sensors, health and actions of a home-controller instance for Home Assistant RESTful sensors and Node-RED flows",
+			Version:     "1 (" + version.Get().Version + ")",
+		},
+		Servers: []Server{{URL: BasePath}},
+		Paths:   make(map[string]PathItem),
+	}
+	for _, r := range a.routes {
+		op := r.operation
+		item, ok := doc.Paths[op.Path]
+		if !ok {
+			item = make(PathItem)
+			doc.Paths[op.Path] = item
+		}
+
+		status := op.Status
+		if status == 0 {
+			status = http.StatusOK
+		}
+		object := OperationObject{
+			OperationID: op.ID,
+			Summary:     op.Summary,
+			Description: op.Description,
+			Responses: map[string]ResponseObject{
+				strconv.Itoa(status): {
+					Description: http.StatusText(status),
+					Content:     map[string]MediaType{"application/json": {Schema: envelopeSchema(op.Data)}},
+				},
+				"default": {
+					Description: "Error",
+					Content:     map[string]MediaType{"application/json": {Schema: envelopeSchema(nil)}},
+				},
+			},
+		}
+		if op.Tag != "" {
+			object.Tags = []string{op.Tag}
+		}
+		for _, p := range op.Parameters {
+			object.Parameters = append(object.Parameters, ParameterObject{
+				Name:        p.Name,
+				In:          "query",
+				Description: p.Description,
+				Required:    p.Required,
+				Schema:      &Schema{Type: p.Type},
+			})
+		}
+		item[strings.ToLower(op.Method)] = object
+	}
+	return doc
+}
+
+// envelopeSchema is the schema of admin.Response carrying data of the type of the given value
+func envelopeSchema(data interface{}) *Schema {
+	schema := schemaOf(reflect.TypeOf(admin.Response{}))
+	schema.Properties["success"].Description = "Whether the request succeeded"
+	schema.Properties["message"].Description = "Human-readable outcome"
+	if data != nil {
+		schema.Properties["data"] = schemaOf(reflect.TypeOf(data))
+	} else {
+		delete(schema.Properties, "data")
+	}
+	return schema
+}
+
+// writeJSON writes a bare JSON body
+func writeJSON(w http.ResponseWriter, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(body)
+}
@@ -0,0 +1,157 @@
+package restapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/mjasion/balena-home/thermostats/admin"
+	"github.com/mjasion/balena-home/thermostats/buffer"
+	"go.uber.org/zap"
+)
+
+type fakePusher struct {
+	last time.Time
+}
+
+func (p fakePusher) LastPushTime() time.Time {
+	return p.last
+}
+
+type fakeTrigger struct {
+	triggered int
+}
+
+func (t *fakeTrigger) Trigger() {
+	t.triggered++
+}
+
+// newTestServer registers the API on an admin server around the given buffer
+func newTestServer(buf *buffer.RingBuffer, latest *Latest, pusher LastPusher, trigger Trigger) *admin.Server {
+	server := admin.New(":0", zap.NewNop())
+	api := New()
+	latest.Register(api)
+	NewHealth(buf, pusher, time.Minute).Register(api)
+	RegisterPushAction(api, trigger)
+	api.RegisterHandlers(server)
+	return server
+}
+
+func TestLatest(t *testing.T) {
+	latest := NewLatest()
+	now := time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC)
+	latest.Observe(&buffer.Reading{Type: buffer.ReadingTypeBLE, BLE: &buffer.SensorReading{
+		Timestamp: now, MAC: "A4:C1:38:00:00:01", SensorName: "living_room", SensorID: 1, TemperatureCelsius: 21.5,
+	}})
+	// An older reading of the same sensor doesn't replace the latest value
+	latest.Observe(&buffer.Reading{Type: buffer.ReadingTypeBLE, BLE: &buffer.SensorReading{
+		Timestamp: now.Add(-time.Minute), MAC: "A4:C1:38:00:00:01", SensorName: "living_room", SensorID: 1, TemperatureCelsius: 19,
+	}})
+	latest.Observe(&buffer.Reading{Type: buffer.ReadingTypePower, Power: &buffer.PowerReading{Timestamp: now, SensorID: 2, Value: 1200}})
+	latest.Observe(&buffer.Reading{Type: buffer.ReadingTypeWater, Water: &buffer.WaterReading{Timestamp: now, TotalLiters: 10}})
+
+	readings := latest.Readings(buffer.ReadingTypeBLE, "ble_temperature_celsius", "living_room")
+	if len(readings) != 1 || readings[0].Value != 21.5 {
+		t.Fatalf("Expected the latest living_room temperature 21.5, got %+v", readings)
+	}
+	if all := latest.Readings("", "", ""); len(all) != 5 {
+		t.Errorf("Expected 5 series, got %d", len(all))
+	}
+
+	// Water readings aren't labelled with a sensor
+	sensors := latest.Sensors("")
+	if len(sensors) != 2 {
+		t.Fatalf("Expected 2 sensors, got %+v", sensors)
+	}
+	if sensors[0].Type != buffer.ReadingTypeBLE || sensors[0].Name != "living_room" || sensors[0].Values["ble_humidity_percent"] != 0 || len(sensors[0].Values) != 3 {
+		t.Errorf("Expected living_room with 3 values, got %+v", sensors[0])
+	}
+	if sensors[1].Type != buffer.ReadingTypePower || sensors[1].ID != "2" || sensors[1].Values["active_power_watts"] != 1200 {
+		t.Errorf("Expected power sensor 2 at 1200 W, got %+v", sensors[1])
+	}
+}
+
+func TestHealth(t *testing.T) {
+	buf := buffer.New(2, zap.NewNop())
+	trigger := &fakeTrigger{}
+	server := newTestServer(buf, NewLatest(), fakePusher{last: time.Now()}, trigger)
+
+	rec := httptest.NewRecorder()
+	server.ServeHTTP(rec, httptest.NewRequest("GET", "/api/v1/health", nil))
+	if rec.Code != http.StatusOK {
+		t.Errorf("Expected status 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	server = newTestServer(buf, NewLatest(), fakePusher{last: time.Now().Add(-time.Hour)}, trigger)
+	rec = httptest.NewRecorder()
+	server.ServeHTTP(rec, httptest.NewRequest("GET", "/api/v1/health", nil))
+	var response struct {
+		Data HealthStatus `json:"data"`
+	}
+	json.NewDecoder(rec.Body).Decode(&response)
+	if rec.Code != http.StatusServiceUnavailable || response.Data.Status != StatusDegraded || response.Data.BufferCapacity != 2 {
+		t.Errorf("Expected a degraded status with a stale push, got %d: %+v", rec.Code, response.Data)
+	}
+
+	rec = httptest.NewRecorder()
+	server.ServeHTTP(rec, httptest.NewRequest("POST", "/api/v1/actions/push", nil))
+	if rec.Code != http.StatusAccepted || trigger.triggered != 1 {
+		t.Errorf("Expected a triggered push, got %d and %d triggers", rec.Code, trigger.triggered)
+	}
+}
+
+func TestHealth_Features(t *testing.T) {
+	health := NewHealth(buffer.New(2, zap.NewNop()), nil, time.Minute)
+	if status := health.Status(); status.Features != nil {
+		t.Errorf("Expected no features by default, got %v", status.Features)
+	}
+
+	health.SetFeatures([]string{"zstd"})
+	if status := health.Status(); len(status.Features) != 1 || status.Features[0] != "zstd" {
+		t.Errorf("Expected the zstd feature, got %v", status.Features)
+	}
+}
+
+func TestSpec(t *testing.T) {
+	server := newTestServer(buffer.New(2, zap.NewNop()), NewLatest(), nil, &fakeTrigger{})
+	rec := httptest.NewRecorder()
+	server.ServeHTTP(rec, httptest.NewRequest("GET", "/api/v1/openapi.json", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", rec.Code)
+	}
+
+	var doc Document
+	if err := json.NewDecoder(rec.Body).Decode(&doc); err != nil {
+		t.Fatalf("Expected a JSON document, got %v", err)
+	}
+	if doc.OpenAPI != "3.0.3" || doc.Servers[0].URL != "/api/v1" {
+		t.Errorf("Unexpected document header %+v", doc)
+	}
+	for _, path := range []string{"/readings", "/sensors", "/health", "/actions/push"} {
+		if _, ok := doc.Paths[path]; !ok {
+			t.Errorf("Expected path %s in the spec", path)
+		}
+	}
+
+	readings := doc.Paths["/readings"]["get"]
+	if readings.OperationID != "listReadings" || len(readings.Parameters) != 3 {
+		t.Errorf("Unexpected readings operation %+v", readings)
+	}
+	data := readings.Responses["200"].Content["application/json"].Schema.Properties["data"]
+	if data.Type != "array" || data.Items.Properties["timestamp"].Format != "date-time" {
+		t.Errorf("Expected an array of readings, got %+v", data)
+	}
+	if metric := data.Items.Properties["metric"]; metric.Type != "string" || metric.Description == "" {
+		t.Errorf("Expected a documented metric string, got %+v", metric)
+	}
+
+	health := doc.Paths["/health"]["get"].Responses["200"].Content["application/json"].Schema.Properties["data"]
+	if health.Properties["last_push"].Nullable != true || len(health.Required) != 5 {
+		t.Errorf("Expected a nullable last_push and 5 required fields, got %+v", health)
+	}
+	if _, ok := doc.Paths["/actions/push"]["post"].Responses["202"]; !ok {
+		t.Errorf("Expected a 202 response for the push action")
+	}
+}
@@ -0,0 +1,150 @@
+package restapi
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/mjasion/balena-home/thermostats/admin"
+	"github.com/mjasion/balena-home/thermostats/automation"
+	"github.com/mjasion/balena-home/thermostats/buffer"
+	"github.com/mjasion/balena-home/thermostats/version"
+)
+
+// Health statuses
+const (
+	StatusOK       = "ok"
+	StatusDegraded = "degraded"
+)
+
+// LastPusher reports the time of the last successful push
+type LastPusher interface {
+	LastPushTime() time.Time
+}
+
+// HealthStatus is the body of GET /api/v1/health
+type HealthStatus struct {
+	Status                string     `json:"status" doc:"ok, or degraded when readings haven't been pushed recently, the buffer is full or shutting down"`
+	Version               string     `json:"version"`
+	UptimeSeconds         float64    `json:"uptime_seconds"`
+	BufferSize            int        `json:"buffer_size" doc:"Readings waiting for the next push"`
+	BufferCapacity        int        `json:"buffer_capacity" doc:"Readings the buffer holds before dropping the oldest"`
+	OldestBufferedSeconds *float64   `json:"oldest_buffered_seconds,omitempty" doc:"Age of the oldest reading waiting for a push; absent when the buffer is empty"`
+	LastPush              *time.Time `json:"last_push,omitempty" doc:"Time of the last successful push; absent when this instance forwards to another"`
+	SecondsSinceLastPush  *float64   `json:"seconds_since_last_push,omitempty"`
+	PushStaleAfterSeconds float64    `json:"push_stale_after_seconds,omitempty" doc:"Age of the last push at which the status becomes degraded"`
+	Features              []string   `json:"features,omitempty" doc:"Experimental feature flags enabled on this device"`
+}
+
+// Health reports whether readings are buffered and pushed, for a Home Assistant binary sensor
+type Health struct {
+	buffer     *buffer.RingBuffer
+	pusher     LastPusher
+	staleAfter time.Duration
+	started    time.Time
+	features   []string
+	now        func() time.Time
+}
+
+// NewHealth creates a health check; a nil pusher skips the push age check
+func NewHealth(buf *buffer.RingBuffer, pusher LastPusher, staleAfter time.Duration) *Health {
+	return &Health{
+		buffer:     buf,
+		pusher:     pusher,
+		staleAfter: staleAfter,
+		started:    time.Now(),
+		now:        time.Now,
+	}
+}
+
+// SetFeatures lists the enabled feature flags in the status
+func (h *Health) SetFeatures(names []string) {
+	h.features = names
+}
+
+// Status returns the current health
+func (h *Health) Status() HealthStatus {
+	now := h.now()
+	snapshot := h.buffer.Snapshot()
+	status := HealthStatus{
+		Status:         StatusOK,
+		Version:        version.Get().Version,
+		UptimeSeconds:  now.Sub(h.started).Seconds(),
+		BufferSize:     len(snapshot.Readings),
+		BufferCapacity: snapshot.Capacity,
+		Features:       h.features,
+	}
+	if status.BufferSize >= status.BufferCapacity || snapshot.Closed {
+		status.Status = StatusDegraded
+	}
+	var oldest time.Time
+	for _, reading := range snapshot.Readings {
+		if timestamp := automation.TimestampOf(reading); !timestamp.IsZero() && (oldest.IsZero() || timestamp.Before(oldest)) {
+			oldest = timestamp
+		}
+	}
+	if !oldest.IsZero() {
+		age := now.Sub(oldest).Seconds()
+		status.OldestBufferedSeconds = &age
+	}
+	if h.pusher != nil {
+		lastPush := h.pusher.LastPushTime()
+		age := now.Sub(lastPush).Seconds()
+		status.LastPush = &lastPush
+		status.SecondsSinceLastPush = &age
+		status.PushStaleAfterSeconds = h.staleAfter.Seconds()
+		if now.Sub(lastPush) > h.staleAfter {
+			status.Status = StatusDegraded
+		}
+	}
+	return status
+}
+
+// Register adds GET /api/v1/health to the API
+func (h *Health) Register(api *API) {
+	api.Handle(Operation{
+		Method:      "GET",
+		Path:        "/health",
+		ID:          "getHealth",
+		Summary:     "Health of the collector",
+		Description: "Responds 503 with the same body when degraded, so it can back a Home Assistant binary sensor or an uptime check.",
+		Tag:         "health",
+		Data:        HealthStatus{},
+	}, h.handleHealth)
+}
+
+// handleHealth handles GET /api/v1/health
+func (h *Health) handleHealth(w http.ResponseWriter, r *http.Request) {
+	status := h.Status()
+	code := http.StatusOK
+	if status.Status != StatusOK {
+		code = http.StatusServiceUnavailable
+	}
+	admin.WriteJSON(w, code, admin.Response{
+		Success: code == http.StatusOK,
+		Data:    status,
+	})
+}
+
+// Trigger starts an out-of-cycle push; metrics.Output implements it
+type Trigger interface {
+	Trigger()
+}
+
+// RegisterPushAction adds POST /api/v1/actions/push to the API
+func RegisterPushAction(api *API, output Trigger) {
+	api.Handle(Operation{
+		Method:      "POST",
+		Path:        "/actions/push",
+		ID:          "pushNow",
+		Summary:     "Push buffered readings now",
+		Description: "Triggers an out-of-cycle push, e.g. from a Node-RED flow before a planned reboot.",
+		Tag:         "actions",
+		Status:      http.StatusAccepted,
+	}, func(w http.ResponseWriter, r *http.Request) {
+		output.Trigger()
+		admin.WriteJSON(w, http.StatusAccepted, admin.Response{
+			Success: true,
+			Message: "Push triggered.",
+		})
+	})
+}
@@ -0,0 +1,137 @@
+package restapi
+
+import (
+	"reflect"
+	"strings"
+	"time"
+)
+
+// Document is the subset of an OpenAPI 3.0 document generated for the REST API
+type Document struct {
+	OpenAPI string              `json:"openapi"`
+	Info    Info                `json:"info"`
+	Servers []Server            `json:"servers"`
+	Paths   map[string]PathItem `json:"paths"`
+}
+
+// Info describes the API
+type Info struct {
+	Title       string `json:"title"`
+	Description string `json:"description,omitempty"`
+	Version     string `json:"version"`
+}
+
+// Server is the base path operations are relative to
+type Server struct {
+	URL string `json:"url"`
+}
+
+// PathItem maps lower-case HTTP methods to the operations of a path
+type PathItem map[string]OperationObject
+
+// OperationObject documents a single endpoint
+type OperationObject struct {
+	OperationID string                    `json:"operationId"`
+	Summary     string                    `json:"summary"`
+	Description string                    `json:"description,omitempty"`
+	Tags        []string                  `json:"tags,omitempty"`
+	Parameters  []ParameterObject         `json:"parameters,omitempty"`
+	Responses   map[string]ResponseObject `json:"responses"`
+}
+
+// ParameterObject documents a query or path parameter
+type ParameterObject struct {
+	Name        string  `json:"name"`
+	In          string  `json:"in"`
+	Description string  `json:"description,omitempty"`
+	Required    bool    `json:"required,omitempty"`
+	Schema      *Schema `json:"schema"`
+}
+
+// ResponseObject documents a response body
+type ResponseObject struct {
+	Description string               `json:"description"`
+	Content     map[string]MediaType `json:"content,omitempty"`
+}
+
+// MediaType holds the schema of a response body
+type MediaType struct {
+	Schema *Schema `json:"schema"`
+}
+
+// Schema is a JSON schema as used by OpenAPI 3.0
+type Schema struct {
+	Type                 string             `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Description          string             `json:"description,omitempty"`
+	Nullable             bool               `json:"nullable,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// schemaOf derives the schema of a Go type from its json struct tags; a doc tag on a field
+// becomes the property description
+func schemaOf(t reflect.Type) *Schema {
+	nullable := false
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+		nullable = true
+	}
+
+	var schema *Schema
+	switch {
+	case t == timeType:
+		schema = &Schema{Type: "string", Format: "date-time"}
+	case t.Kind() == reflect.Bool:
+		schema = &Schema{Type: "boolean"}
+	case t.Kind() >= reflect.Int && t.Kind() <= reflect.Uint64:
+		schema = &Schema{Type: "integer"}
+		if t.Kind() == reflect.Int64 || t.Kind() == reflect.Uint64 {
+			schema.Format = "int64"
+		}
+	case t.Kind() == reflect.Float32 || t.Kind() == reflect.Float64:
+		schema = &Schema{Type: "number", Format: "double"}
+	case t.Kind() == reflect.String:
+		schema = &Schema{Type: "string"}
+	case t.Kind() == reflect.Slice || t.Kind() == reflect.Array:
+		schema = &Schema{Type: "array", Items: schemaOf(t.Elem())}
+	case t.Kind() == reflect.Map:
+		schema = &Schema{Type: "object", AdditionalProperties: schemaOf(t.Elem())}
+	case t.Kind() == reflect.Struct:
+		schema = structSchema(t)
+	default:
+		// interface{} accepts any value
+		schema = &Schema{}
+	}
+	schema.Nullable = nullable
+	return schema
+}
+
+// structSchema derives the schema of a struct; fields without omitempty are required
+func structSchema(t reflect.Type) *Schema {
+	schema := &Schema{Type: "object", Properties: make(map[string]*Schema)}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		name, options, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		property := schemaOf(field.Type)
+		property.Description = field.Tag.Get("doc")
+		schema.Properties[name] = property
+		if !strings.Contains(options, "omitempty") {
+			schema.Required = append(schema.Required, name)
+		}
+	}
+	return schema
+}
@@ -0,0 +1,196 @@
+package restapi
+
+import (
+	"math"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mjasion/balena-home/thermostats/admin"
+	"github.com/mjasion/balena-home/thermostats/automation"
+	"github.com/mjasion/balena-home/thermostats/buffer"
+)
+
+// Reading is the latest value of a series as returned by GET /api/v1/readings
+type Reading struct {
+	Type      buffer.ReadingType `json:"type" doc:"Reading type the value comes from, e.g. ble or power"`
+	Metric    string             `json:"metric" doc:"Metric name as pushed to Prometheus, e.g. ble_temperature_celsius"`
+	Labels    map[string]string  `json:"labels" doc:"Series labels as pushed, e.g. sensor_name"`
+	Value     float64            `json:"value"`
+	Timestamp time.Time          `json:"timestamp" doc:"Time of the reading the value was taken from"`
+}
+
+// Sensor is a sensor seen in readings as returned by GET /api/v1/sensors
+type Sensor struct {
+	Type     buffer.ReadingType `json:"type" doc:"Reading type of the sensor, e.g. ble or onewire"`
+	Name     string             `json:"name,omitempty" doc:"Friendly name from config"`
+	ID       string             `json:"id,omitempty" doc:"Numeric ID from config"`
+	LastSeen time.Time          `json:"last_seen" doc:"Time of the sensor's latest reading"`
+	Values   map[string]float64 `json:"values" doc:"Latest value of each metric of the sensor, by metric name"`
+}
+
+// Latest keeps the latest value of every series seen in the buffer, since pushes empty it
+type Latest struct {
+	mu     sync.RWMutex
+	series map[string]Reading
+}
+
+// NewLatest creates an empty store; register Observe as a buffer listener
+func NewLatest() *Latest {
+	return &Latest{series: make(map[string]Reading)}
+}
+
+// Observe records the samples of a reading, replacing older values of the same series
+func (l *Latest) Observe(reading *buffer.Reading) {
+	timestamp := automation.TimestampOf(reading)
+	samples := automation.Samples(reading)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for _, sample := range samples {
+		// NaN and infinities can't be encoded as JSON
+		if math.IsNaN(sample.Value) || math.IsInf(sample.Value, 0) {
+			continue
+		}
+		key := seriesKey(sample)
+		if existing, ok := l.series[key]; ok && existing.Timestamp.After(timestamp) {
+			continue
+		}
+		labels := sample.Labels
+		if labels == nil {
+			labels = map[string]string{}
+		}
+		l.series[key] = Reading{
+			Type:      reading.Type,
+			Metric:    sample.Metric,
+			Labels:    labels,
+			Value:     sample.Value,
+			Timestamp: timestamp,
+		}
+	}
+}
+
+// seriesKey identifies a series by metric name and sorted labels
+func seriesKey(sample automation.Sample) string {
+	names := make([]string, 0, len(sample.Labels))
+	for name := range sample.Labels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var key strings.Builder
+	key.WriteString(sample.Metric)
+	for _, name := range names {
+		key.WriteString("\x00" + name + "=" + sample.Labels[name])
+	}
+	return key.String()
+}
+
+// Readings returns the latest values matching the filters, sorted by metric and labels;
+// empty filters match everything
+func (l *Latest) Readings(readingType buffer.ReadingType, metric, sensor string) []Reading {
+	l.mu.RLock()
+	keys := make([]string, 0, len(l.series))
+	for key := range l.series {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	readings := []Reading{}
+	for _, key := range keys {
+		r := l.series[key]
+		if readingType != "" && r.Type != readingType {
+			continue
+		}
+		if metric != "" && r.Metric != metric {
+			continue
+		}
+		if sensor != "" && r.Labels["sensor_name"] != sensor && r.Labels["sensor_id"] != sensor {
+			continue
+		}
+		readings = append(readings, r)
+	}
+	l.mu.RUnlock()
+	return readings
+}
+
+// Sensors groups the latest values labelled with a sensor name or ID by sensor
+func (l *Latest) Sensors(readingType buffer.ReadingType) []Sensor {
+	byKey := make(map[string]*Sensor)
+	var keys []string
+	for _, r := range l.Readings(readingType, "", "") {
+		name, id := r.Labels["sensor_name"], r.Labels["sensor_id"]
+		if name == "" && id == "" {
+			continue
+		}
+		key := string(r.Type) + "\x00" + name + "\x00" + id
+		sensor, ok := byKey[key]
+		if !ok {
+			sensor = &Sensor{Type: r.Type, Name: name, ID: id, Values: make(map[string]float64)}
+			byKey[key] = sensor
+			keys = append(keys, key)
+		}
+		sensor.Values[r.Metric] = r.Value
+		if r.Timestamp.After(sensor.LastSeen) {
+			sensor.LastSeen = r.Timestamp
+		}
+	}
+	sort.Strings(keys)
+
+	sensors := make([]Sensor, 0, len(keys))
+	for _, key := range keys {
+		sensors = append(sensors, *byKey[key])
+	}
+	return sensors
+}
+
+// Register adds GET /api/v1/readings and GET /api/v1/sensors to the API
+func (l *Latest) Register(api *API) {
+	api.Handle(Operation{
+		Method:  "GET",
+		Path:    "/readings",
+		ID:      "listReadings",
+		Summary: "Latest value of every series",
+		Description: "Series are named and labelled as they are pushed to Prometheus. Values survive pushes, " +
+			"but are lost on restart.",
+		Tag: "readings",
+		Parameters: []Parameter{
+			{Name: "type", Description: "Only readings of this type, e.g. ble", Type: "string"},
+			{Name: "metric", Description: "Only this metric, e.g. ble_temperature_celsius", Type: "string"},
+			{Name: "sensor", Description: "Only series of the sensor with this name or ID", Type: "string"},
+		},
+		Data: []Reading{},
+	}, l.handleReadings)
+
+	api.Handle(Operation{
+		Method:      "GET",
+		Path:        "/sensors",
+		ID:          "listSensors",
+		Summary:     "Sensors seen since start with their latest values",
+		Description: "A sensor is a series labelled with a sensor name or ID, such as BLE, 1-Wire, I2C, air quality and power sensors.",
+		Tag:         "sensors",
+		Parameters: []Parameter{
+			{Name: "type", Description: "Only sensors of this reading type, e.g. ble", Type: "string"},
+		},
+		Data: []Sensor{},
+	}, l.handleSensors)
+}
+
+// handleReadings handles GET /api/v1/readings?type=<type>&metric=<metric>&sensor=<name or id>
+func (l *Latest) handleReadings(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	admin.WriteJSON(w, http.StatusOK, admin.Response{
+		Success: true,
+		Data:    l.Readings(buffer.ReadingType(query.Get("type")), query.Get("metric"), query.Get("sensor")),
+	})
+}
+
+// handleSensors handles GET /api/v1/sensors?type=<type>
+func (l *Latest) handleSensors(w http.ResponseWriter, r *http.Request) {
+	admin.WriteJSON(w, http.StatusOK, admin.Response{
+		Success: true,
+		Data:    l.Sensors(buffer.ReadingType(r.URL.Query().Get("type"))),
+	})
+}
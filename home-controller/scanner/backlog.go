@@ -0,0 +1,177 @@
+package scanner
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/mjasion/balena-home/thermostats/buffer"
+	"github.com/mjasion/balena-home/thermostats/clock"
+	"go.uber.org/zap"
+)
+
+// Backlog modes
+const (
+	BacklogOff    = "off"    // Keep the decode time of every reading
+	BacklogSpread = "spread" // Spread backlogged readings over the hang by their frame counters
+	BacklogDrop   = "drop"   // Drop backlogged readings estimated older than the max age
+)
+
+// beatInterval is the period of the heartbeat detecting hangs
+const beatInterval = time.Second
+
+// maxLearnFrames bounds the frame steps a sensor's measurement interval is learned from; larger
+// steps follow an absence, over which the 8-bit counter may have wrapped
+const maxLearnFrames = 4
+
+// Backlog sanitizes the advertisements BlueZ delivers in a burst once the process resumes from a
+// hang, which would otherwise all be stamped with nearly the same decode time
+// A hang shows as a gap in the monotonic clock between heartbeats; readings decoded within the
+// settle period after it are backlogged, and their age is estimated from how many frames the
+// sensor's counter advanced since its last reading before the hang
+// A nil *Backlog is valid and keeps every reading as decoded
+type Backlog struct {
+	mode   string
+	gap    time.Duration // Heartbeat gap taken as a hang
+	settle time.Duration // How long after a hang readings are backlogged
+	maxAge time.Duration // Backlogged readings estimated older are dropped in drop mode
+	logger *zap.Logger
+	clock  clock.Clock
+
+	mu       sync.Mutex
+	lastBeat time.Time
+	resumed  time.Time // End of the last hang, zero before one
+	sensors  map[string]*sensorFrames
+	spread   int // Backlogged readings retimed since the last hang
+	dropped  int // Backlogged readings dropped since the last hang
+}
+
+// sensorFrames tracks the frame counter of a sensor
+type sensorFrames struct {
+	frame    int
+	at       time.Time     // Timestamp of the reading with the frame
+	interval time.Duration // Learned time between frames, 0 until learned
+}
+
+// NewBacklog creates a sanitizer in the given mode treating heartbeat gaps longer than gap as hangs
+func NewBacklog(mode string, gap, settle, maxAge time.Duration, logger *zap.Logger) *Backlog {
+	return &Backlog{
+		mode:    mode,
+		gap:     gap,
+		settle:  settle,
+		maxAge:  maxAge,
+		logger:  logger,
+		clock:   clock.Real,
+		sensors: make(map[string]*sensorFrames),
+	}
+}
+
+// SetClock sets the clock of the heartbeat, e.g. a fake one in tests
+func (b *Backlog) SetClock(c clock.Clock) {
+	b.clock = c
+}
+
+// Start beats the heartbeat until ctx is done
+func (b *Backlog) Start(ctx context.Context) {
+	b.mu.Lock()
+	b.lastBeat = b.clock.Now()
+	b.mu.Unlock()
+
+	ticker := b.clock.NewTicker(beatInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C():
+			b.mu.Lock()
+			now := b.clock.Now()
+			b.beat(now)
+			if !b.resumed.IsZero() && now.Sub(b.resumed) >= b.settle && b.spread+b.dropped > 0 {
+				b.logger.Info("sanitized BLE backlog after hang",
+					zap.Int("spread", b.spread),
+					zap.Int("dropped", b.dropped),
+				)
+				b.spread, b.dropped = 0, 0
+			}
+			b.mu.Unlock()
+		}
+	}
+}
+
+// beat records a heartbeat, detecting a hang since the previous one; the caller must hold the lock
+func (b *Backlog) beat(now time.Time) {
+	if !b.lastBeat.IsZero() && now.Sub(b.lastBeat) > b.gap {
+		b.logger.Warn("resumed from a hang, sanitizing BLE backlog",
+			zap.Duration("gap", now.Sub(b.lastBeat)),
+			zap.String("mode", b.mode),
+		)
+		b.resumed = now
+	}
+	b.lastBeat = now
+}
+
+// Sanitize retimes a backlogged reading, or reports false when it should be dropped
+func (b *Backlog) Sanitize(reading *buffer.SensorReading) bool {
+	if b == nil || b.mode == BacklogOff {
+		return true
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	// A reading decoded before the heartbeat noticed the hang still belongs to its backlog
+	now := b.clock.Now()
+	if !b.lastBeat.IsZero() {
+		b.beat(now)
+	}
+
+	sensor, ok := b.sensors[reading.MAC]
+	if !ok {
+		b.sensors[reading.MAC] = &sensorFrames{frame: reading.FrameCounter, at: reading.Timestamp}
+		return true
+	}
+	frames := (reading.FrameCounter - sensor.frame + 256) % 256
+
+	if b.resumed.IsZero() || now.Sub(b.resumed) >= b.settle {
+		if frames > 0 {
+			if frames <= maxLearnFrames {
+				perFrame := reading.Timestamp.Sub(sensor.at) / time.Duration(frames)
+				if sensor.interval == 0 {
+					sensor.interval = perFrame
+				} else {
+					sensor.interval = (3*sensor.interval + perFrame) / 4
+				}
+			}
+			sensor.frame, sensor.at = reading.FrameCounter, reading.Timestamp
+		}
+		return true
+	}
+
+	// Repeats of a frame buffered before the hang add nothing
+	if frames == 0 {
+		b.dropped++
+		return false
+	}
+	if sensor.interval == 0 {
+		sensor.frame, sensor.at = reading.FrameCounter, reading.Timestamp
+		return true
+	}
+	estimated := sensor.at.Add(time.Duration(frames) * sensor.interval)
+	if estimated.After(reading.Timestamp) {
+		estimated = reading.Timestamp
+	}
+	sensor.frame, sensor.at = reading.FrameCounter, estimated
+
+	if b.mode == BacklogDrop {
+		if reading.Timestamp.Sub(estimated) > b.maxAge {
+			b.dropped++
+			return false
+		}
+		return true
+	}
+	if estimated.Before(reading.Timestamp) {
+		b.spread++
+	}
+	reading.Timestamp = estimated
+	return true
+}
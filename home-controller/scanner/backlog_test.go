@@ -0,0 +1,73 @@
+package scanner
+
+import (
+	"testing"
+	"time"
+
+	"github.com/mjasion/balena-home/thermostats/buffer"
+	"github.com/mjasion/balena-home/thermostats/clock"
+	"go.uber.org/zap"
+)
+
+// sanitizeHang feeds a sensor measuring every 5 seconds, hangs for a minute and returns the
+// backlog of frames 1 to 12 since, delivered at once, as sanitized by a backlog in the given mode
+func sanitizeHang(t *testing.T, mode string) (start time.Time, kept []*buffer.SensorReading, dropped int) {
+	clk := clock.NewFake(time.Date(2026, 10, 17, 12, 0, 0, 0, time.UTC))
+	backlog := NewBacklog(mode, 10*time.Second, 30*time.Second, 20*time.Second, zap.NewNop())
+	backlog.SetClock(clk)
+	backlog.lastBeat = clk.Now()
+
+	reading := func(frame int) *buffer.SensorReading {
+		return &buffer.SensorReading{Timestamp: clk.Now(), MAC: "A4:C1:38:00:00:01", FrameCounter: frame}
+	}
+	for frame := 250; frame < 256; frame++ {
+		if !backlog.Sanitize(reading(frame)) {
+			t.Fatalf("Expected frame %d kept before the hang", frame)
+		}
+		clk.Advance(5 * time.Second)
+	}
+	start = clk.Now().Add(-5 * time.Second)
+
+	clk.Advance(55 * time.Second)
+	if backlog.Sanitize(reading(255)) {
+		t.Error("Expected a repeat of the last frame before the hang dropped")
+	}
+	for frame := 0; frame < 12; frame++ {
+		r := reading(frame)
+		if backlog.Sanitize(r) {
+			kept = append(kept, r)
+		} else {
+			dropped++
+		}
+	}
+	return start, kept, dropped
+}
+
+func TestBacklog_Spread(t *testing.T) {
+	start, kept, _ := sanitizeHang(t, BacklogSpread)
+	if len(kept) != 12 {
+		t.Fatalf("Expected all 12 backlogged frames kept, got %d", len(kept))
+	}
+	for i, r := range kept {
+		want := start.Add(time.Duration(i+1) * 5 * time.Second)
+		if !r.Timestamp.Equal(want) {
+			t.Errorf("Expected frame %d at %s, got %s", r.FrameCounter, want, r.Timestamp)
+		}
+	}
+}
+
+func TestBacklog_Drop(t *testing.T) {
+	start, kept, dropped := sanitizeHang(t, BacklogDrop)
+	// Frames measured more than 20 seconds before the burst are dropped
+	if len(kept) != 5 || dropped != 7 {
+		t.Fatalf("Expected 5 frames kept and 7 dropped, got %d and %d", len(kept), dropped)
+	}
+	if !kept[0].Timestamp.Equal(start.Add(60 * time.Second)) {
+		t.Errorf("Expected kept readings to keep their decode time, got %s", kept[0].Timestamp)
+	}
+
+	var backlog *Backlog
+	if !backlog.Sanitize(&buffer.SensorReading{}) {
+		t.Error("Expected a nil backlog to keep readings")
+	}
+}
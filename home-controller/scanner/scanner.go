@@ -2,18 +2,20 @@ package scanner
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"strings"
+	"sync"
+	"time"
 
+	"github.com/mjasion/balena-home/thermostats/bledecoder"
 	"github.com/mjasion/balena-home/thermostats/buffer"
-	"github.com/mjasion/balena-home/thermostats/decoder"
+	"github.com/mjasion/balena-home/thermostats/events"
+	"github.com/mjasion/balena-home/thermostats/locator"
 	"go.uber.org/zap"
 	"tinygo.org/x/bluetooth"
 )
 
-// UUID 0x181A is used by ATC_MiThermometer firmware
-var serviceUUID = bluetooth.New16BitUUID(0x181A)
-
 // SensorInfo contains metadata about a sensor
 type SensorInfo struct {
 	Name string
@@ -33,6 +35,12 @@ type Scanner struct {
 	sensorMACs map[string]SensorInfo // Map of MAC address to sensor info
 	buffer     *buffer.RingBuffer
 	logger     *zap.Logger
+	eventLog   *events.Log
+	receiver   string
+	locator    *locator.Locator
+	backlog    *Backlog // Nil keeps the decode time of every reading
+	seenMu     sync.Mutex
+	seen       map[string]bool // MAC addresses with at least one decoded reading
 }
 
 // New creates a new BLE scanner
@@ -53,9 +61,40 @@ func New(sensors []SensorConfig, buf *buffer.RingBuffer, logger *zap.Logger) *Sc
 		sensorMACs: macMap,
 		buffer:     buf,
 		logger:     logger,
+		seen:       make(map[string]bool),
 	}
 }
 
+// SetEventLog sets the event log used to record the first reading of each sensor
+func (s *Scanner) SetEventLog(eventLog *events.Log) {
+	s.eventLog = eventLog
+}
+
+// SetReceiver sets the receiver name stamped on readings, so readings heard by several
+// scanners can be told apart
+func (s *Scanner) SetReceiver(name string) {
+	s.receiver = name
+}
+
+// SetLocator sets the locator that tracks the signal strength of presence beacons
+func (s *Scanner) SetLocator(l *locator.Locator) {
+	s.locator = l
+}
+
+// SetBacklog sets the sanitizer of advertisements delivered in a burst after a hang
+func (s *Scanner) SetBacklog(b *Backlog) {
+	s.backlog = b
+}
+
+// EnableAdapter enables the default BLE adapter; it fails while BlueZ or the adapter isn't up yet,
+// which makes it a startup check
+func EnableAdapter() error {
+	if err := bluetooth.DefaultAdapter.Enable(); err != nil {
+		return fmt.Errorf("failed to enable BLE adapter: %w", err)
+	}
+	return nil
+}
+
 // Start initializes the BLE adapter and starts scanning
 func (s *Scanner) Start(ctx context.Context) error {
 	s.logger.Info("initializing BLE adapter")
@@ -82,6 +121,10 @@ func (s *Scanner) Start(ctx context.Context) error {
 
 		// Filter by configured sensor MAC addresses
 		mac := strings.ToUpper(result.Address.String())
+		if s.locator.IsBeacon(mac) {
+			s.locator.Heard(mac, s.receiver, result.RSSI, time.Now())
+			return
+		}
 		sensorInfo, found := s.sensorMACs[mac]
 		if !found {
 			return
@@ -92,15 +135,18 @@ func (s *Scanner) Start(ctx context.Context) error {
 			zap.Int("sensor_id", sensorInfo.ID),
 			zap.Any("result", result.ServiceData()))
 
-		// Look for service data with UUID 0x181A
+		// Decode the service data of the sensor formats, skipping other services
 		serviceData := result.ServiceData()
 		for _, sd := range serviceData {
-			if sd.UUID == serviceUUID {
-				// Decode ATC advertisement
-				reading, err := decoder.DecodeATCAdvertisement(sd.Data, result.RSSI)
+			if sd.UUID.Is16Bit() {
+				reading, format, err := bledecoder.Decode(sd.UUID.Get16Bit(), sd.Data, result.RSSI)
+				if errors.Is(err, bledecoder.ErrUnknownFormat) {
+					continue
+				}
 				if err != nil {
-					s.logger.Warn("failed to decode ATC advertisement",
+					s.logger.Warn("failed to decode advertisement",
 						zap.String("mac", mac),
+						zap.String("format", string(format)),
 						zap.Error(err),
 					)
 					continue
@@ -120,9 +166,23 @@ func (s *Scanner) Start(ctx context.Context) error {
 						BatteryVoltageMV:   reading.BatteryVoltageMV,
 						FrameCounter:       reading.FrameCounter,
 						RSSI:               reading.RSSI,
+						Receiver:           s.receiver,
 					},
 				}
-				s.buffer.Add(bufReading)
+				if !s.backlog.Sanitize(bufReading.BLE) {
+					s.logger.Debug("dropped backlogged BLE reading",
+						zap.String("sensor_name", sensorInfo.Name),
+						zap.Int("frame_counter", reading.FrameCounter),
+					)
+					continue
+				}
+				if err := s.buffer.Add(bufReading); err != nil {
+					// The buffer was closed for the final push; stop like a cancelled context
+					s.logger.Info("buffer closed, stopping BLE scan")
+					s.adapter.StopScan()
+					return
+				}
+				s.markSeen(mac, sensorInfo)
 
 				// Log sensor reading
 				s.logger.Info("Read sensor data",
@@ -146,6 +206,24 @@ func (s *Scanner) Start(ctx context.Context) error {
 	return nil
 }
 
+// markSeen records a sensor_first_seen event the first time a sensor is decoded
+func (s *Scanner) markSeen(mac string, sensorInfo SensorInfo) {
+	s.seenMu.Lock()
+	firstSeen := !s.seen[mac]
+	s.seen[mac] = true
+	s.seenMu.Unlock()
+
+	if firstSeen {
+		s.eventLog.Record(events.TypeSensorFirstSeen, "ble",
+			fmt.Sprintf("sensor %s first seen", sensorInfo.Name),
+			map[string]string{
+				"mac":       mac,
+				"sensor_id": fmt.Sprintf("%d", sensorInfo.ID),
+			},
+		)
+	}
+}
+
 // Stop stops the BLE scanner
 func (s *Scanner) Stop() error {
 	s.logger.Info("stopping BLE scan")
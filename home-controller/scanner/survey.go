@@ -0,0 +1,162 @@
+package scanner
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/mjasion/balena-home/thermostats/bledecoder"
+	"github.com/mjasion/balena-home/thermostats/clock"
+	"tinygo.org/x/bluetooth"
+)
+
+// Survey tallies the sensor advertisements a receiver hears, for the scan command placing sensors
+// and receivers where reception is reliable
+// Every sensor of a known format is surveyed, not only the configured ones
+type Survey struct {
+	names   map[string]string // Configured sensor names by MAC address
+	clock   clock.Clock
+	started time.Time
+
+	mu      sync.Mutex
+	devices map[string]*surveyDevice
+}
+
+// surveyDevice is the reception of one sensor
+type surveyDevice struct {
+	last      SurveyPacket
+	packets   int
+	strongest int16
+}
+
+// SurveyPacket is a decoded advertisement, written as a JSON line by the scan command
+type SurveyPacket struct {
+	Time               time.Time         `json:"time"`
+	MAC                string            `json:"mac"`
+	Name               string            `json:"name,omitempty"`
+	Format             bledecoder.Format `json:"format"`
+	RSSI               int16             `json:"rssi_dbm"`
+	TemperatureCelsius float64           `json:"temperature_celsius"`
+	HumidityPercent    int               `json:"humidity_percent"`
+	BatteryPercent     int               `json:"battery_percent"`
+	FrameCounter       int               `json:"frame_counter"`
+}
+
+// NewSurvey creates a survey naming the sensors in names, keyed by upper-case MAC address
+func NewSurvey(names map[string]string) *Survey {
+	return &Survey{
+		names:   names,
+		clock:   clock.Real,
+		started: clock.Real.Now(),
+		devices: make(map[string]*surveyDevice),
+	}
+}
+
+// SetClock sets the clock timing packets and packet rates, e.g. a fake one in tests
+func (s *Survey) SetClock(c clock.Clock) {
+	s.clock = c
+	s.started = c.Now()
+}
+
+// Scan records the advertisements of known formats until ctx is done, passing each to heard
+// when it isn't nil
+func (s *Survey) Scan(ctx context.Context, heard func(SurveyPacket)) error {
+	adapter := bluetooth.DefaultAdapter
+	if err := adapter.Enable(); err != nil {
+		return fmt.Errorf("failed to enable BLE adapter: %w", err)
+	}
+	stop := context.AfterFunc(ctx, func() { adapter.StopScan() })
+	defer stop()
+
+	err := adapter.Scan(func(adapter *bluetooth.Adapter, result bluetooth.ScanResult) {
+		for _, sd := range result.ServiceData() {
+			if !sd.UUID.Is16Bit() {
+				continue
+			}
+			reading, format, err := bledecoder.Decode(sd.UUID.Get16Bit(), sd.Data, result.RSSI)
+			if err != nil {
+				continue
+			}
+			packet := s.Record(reading, format)
+			if heard != nil {
+				heard(packet)
+			}
+		}
+	})
+	if err != nil {
+		return fmt.Errorf("failed to scan: %w", err)
+	}
+	return nil
+}
+
+// Record tallies a decoded advertisement and returns it as a packet
+func (s *Survey) Record(reading *bledecoder.Reading, format bledecoder.Format) SurveyPacket {
+	packet := SurveyPacket{
+		Time:               s.clock.Now(),
+		MAC:                reading.MAC,
+		Name:               s.names[reading.MAC],
+		Format:             format,
+		RSSI:               reading.RSSI,
+		TemperatureCelsius: reading.TemperatureCelsius,
+		HumidityPercent:    reading.HumidityPercent,
+		BatteryPercent:     reading.BatteryPercent,
+		FrameCounter:       reading.FrameCounter,
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	device, ok := s.devices[reading.MAC]
+	if !ok {
+		device = &surveyDevice{strongest: reading.RSSI}
+		s.devices[reading.MAC] = device
+	}
+	device.last = packet
+	device.packets++
+	device.strongest = max(device.strongest, reading.RSSI)
+	return packet
+}
+
+// WriteTable writes the reception of every sensor heard, one row per sensor ordered by MAC
+// address; the packet rate is per minute since the survey started
+func (s *Survey) WriteTable(w io.Writer) error {
+	now := s.clock.Now()
+	elapsed := max(now.Sub(s.started), time.Second)
+
+	s.mu.Lock()
+	macs := make([]string, 0, len(s.devices))
+	for mac := range s.devices {
+		macs = append(macs, mac)
+	}
+	slices.Sort(macs)
+
+	var table strings.Builder
+	tw := tabwriter.NewWriter(&table, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "DEVICE\tNAME\tFORMAT\tRSSI\tBEST\tTEMP\tPACKETS\tRATE/MIN\tLAST SEEN")
+	for _, mac := range macs {
+		device := s.devices[mac]
+		name := device.last.Name
+		if name == "" {
+			name = "-"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%d\t%d\t%.1f°C\t%d\t%.1f\t%s ago\n",
+			mac, name, device.last.Format, device.last.RSSI, device.strongest,
+			device.last.TemperatureCelsius, device.packets,
+			float64(device.packets)/elapsed.Minutes(),
+			now.Sub(device.last.Time).Truncate(time.Second))
+	}
+	s.mu.Unlock()
+	tw.Flush()
+
+	if len(macs) == 0 {
+		table.WriteString("No sensors heard yet\n")
+	}
+	if _, err := io.WriteString(w, table.String()); err != nil {
+		return fmt.Errorf("failed to write survey table: %w", err)
+	}
+	return nil
+}
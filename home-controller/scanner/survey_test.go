@@ -0,0 +1,59 @@
+package scanner
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mjasion/balena-home/thermostats/bledecoder"
+	"github.com/mjasion/balena-home/thermostats/clock"
+)
+
+func TestSurvey(t *testing.T) {
+	clk := clock.NewFake(time.Date(2026, 10, 17, 12, 0, 0, 0, time.UTC))
+	survey := NewSurvey(map[string]string{"A4:C1:38:00:00:01": "living_room"})
+	survey.SetClock(clk)
+
+	if table := writeTable(t, survey); !strings.Contains(table, "No sensors heard yet") {
+		t.Errorf("Expected an empty survey, got:\n%s", table)
+	}
+
+	for _, rssi := range []int16{-80, -60, -70, -75} {
+		clk.Advance(15 * time.Second)
+		survey.Record(&bledecoder.Reading{MAC: "A4:C1:38:00:00:01", TemperatureCelsius: 21.5, RSSI: rssi}, bledecoder.FormatATC)
+	}
+	packet := survey.Record(&bledecoder.Reading{MAC: "A4:C1:38:00:00:02", TemperatureCelsius: -3, RSSI: -90}, bledecoder.FormatATC)
+	clk.Advance(10 * time.Second)
+
+	// Rates are per minute since the survey started 70 seconds ago
+	lines := strings.Split(strings.TrimSpace(writeTable(t, survey)), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("Expected a header and 2 rows, got:\n%s", strings.Join(lines, "\n"))
+	}
+	if fields := strings.Fields(lines[1]); strings.Join(fields, " ") != "A4:C1:38:00:00:01 living_room atc -75 -60 21.5°C 4 3.4 10s ago" {
+		t.Errorf("Unexpected row for the named sensor: %s", lines[1])
+	}
+	if fields := strings.Fields(lines[2]); strings.Join(fields, " ") != "A4:C1:38:00:00:02 - atc -90 -90 -3.0°C 1 0.9 10s ago" {
+		t.Errorf("Unexpected row for the unconfigured sensor: %s", lines[2])
+	}
+
+	line, err := json.Marshal(packet)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	expected := `{"time":"2026-10-17T12:01:00Z","mac":"A4:C1:38:00:00:02","format":"atc","rssi_dbm":-90,"temperature_celsius":-3,"humidity_percent":0,"battery_percent":0,"frame_counter":0}`
+	if string(line) != expected {
+		t.Errorf("Expected %s, got %s", expected, line)
+	}
+}
+
+// writeTable returns the survey table
+func writeTable(t *testing.T, survey *Survey) string {
+	t.Helper()
+	var table strings.Builder
+	if err := survey.WriteTable(&table); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	return table.String()
+}
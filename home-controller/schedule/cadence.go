@@ -0,0 +1,31 @@
+package schedule
+
+// Cadence scales how often periodic work runs, e.g. fewer scrapes while nobody is home
+type Cadence interface {
+	// Multiplier returns the number of intervals between runs; 1 runs on every interval
+	Multiplier() int
+}
+
+// Skipper skips ticks of a periodic loop to follow a cadence; the zero value never skips
+type Skipper struct {
+	cadence Cadence
+	skipped int
+}
+
+// NewSkipper creates a skipper following cadence
+func NewSkipper(cadence Cadence) Skipper {
+	return Skipper{cadence: cadence}
+}
+
+// Skip reports whether the current tick is skipped; a lower multiplier applies from the next tick
+func (s *Skipper) Skip() bool {
+	if s.cadence == nil {
+		return false
+	}
+	if s.skipped+1 >= s.cadence.Multiplier() {
+		s.skipped = 0
+		return false
+	}
+	s.skipped++
+	return true
+}
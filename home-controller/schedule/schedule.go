@@ -0,0 +1,48 @@
+package schedule
+
+import (
+	"context"
+	"time"
+
+	"github.com/mjasion/balena-home/thermostats/clock"
+)
+
+// Next returns the first wall-clock multiple of interval, shifted by offset, after now
+// e.g. :00, :15, :30 and :45 for 15s, or :13, :28, :43 and :58 with an offset of -2s
+// Multiples are counted in UTC, so intervals dividing a day line up across devices
+func Next(now time.Time, interval, offset time.Duration) time.Time {
+	if interval <= 0 {
+		return now
+	}
+	offset %= interval
+	next := now.Add(-offset).Truncate(interval).Add(interval + offset)
+	if !next.After(now) {
+		next = next.Add(interval)
+	}
+	return next
+}
+
+// Wait blocks until the next boundary of interval shifted by offset, or until ctx is cancelled
+// Returns false if ctx was cancelled
+func Wait(ctx context.Context, c clock.Clock, interval, offset time.Duration) bool {
+	now := c.Now()
+	timer := c.NewTimer(Next(now, interval, offset).Sub(now))
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C():
+		return true
+	}
+}
+
+// Aligned wraps a component so it starts at the next boundary of interval shifted by offset;
+// periodic components read on start and then tick every interval, so they stay on the boundaries
+func Aligned(c clock.Clock, interval, offset time.Duration, run func(ctx context.Context)) func(ctx context.Context) {
+	return func(ctx context.Context) {
+		if !Wait(ctx, c, interval, offset) {
+			return
+		}
+		run(ctx)
+	}
+}
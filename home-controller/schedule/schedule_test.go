@@ -0,0 +1,94 @@
+package schedule
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/mjasion/balena-home/thermostats/clock"
+)
+
+func TestNext(t *testing.T) {
+	base := time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		now      time.Duration
+		interval time.Duration
+		offset   time.Duration
+		expected time.Duration
+	}{
+		{7 * time.Second, 15 * time.Second, 0, 15 * time.Second},
+		{15 * time.Second, 15 * time.Second, 0, 30 * time.Second}, // On a boundary, the next one
+		{44*time.Second + 999*time.Millisecond, 15 * time.Second, 0, 45 * time.Second},
+		{7 * time.Second, 15 * time.Second, 5 * time.Second, 20 * time.Second},
+		{14 * time.Second, 15 * time.Second, -2 * time.Second, 28 * time.Second},
+		{12 * time.Second, 15 * time.Second, -2 * time.Second, 13 * time.Second},
+		{7 * time.Second, 15 * time.Second, 20 * time.Second, 20 * time.Second}, // Offset wraps around
+		{10 * time.Minute, time.Hour, 0, time.Hour},
+		{7 * time.Second, 0, 0, 7 * time.Second}, // No interval, no wait
+	}
+	for _, tt := range tests {
+		got := Next(base.Add(tt.now), tt.interval, tt.offset)
+		if want := base.Add(tt.expected); !got.Equal(want) {
+			t.Errorf("Next(%v, %v, %v): expected %v, got %v", tt.now, tt.interval, tt.offset, tt.expected, got.Sub(base))
+		}
+	}
+}
+
+func TestAligned_Cancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	ran := false
+	Aligned(clock.Real, time.Hour, 0, func(ctx context.Context) { ran = true })(ctx)
+	if ran {
+		t.Error("Expected a cancelled component not to start")
+	}
+}
+
+func TestAligned_StartsOnTheBoundary(t *testing.T) {
+	fake := clock.NewFake(time.Date(2026, 3, 10, 12, 0, 7, 0, time.UTC))
+	started := make(chan time.Time, 1)
+	go Aligned(fake, 15*time.Second, -2*time.Second, func(ctx context.Context) { started <- fake.Now() })(context.Background())
+
+	fake.BlockUntil(1)
+	fake.Advance(5 * time.Second)
+	select {
+	case <-started:
+		t.Fatal("Expected the component not to start before the boundary")
+	default:
+	}
+	fake.Advance(time.Second)
+	if at := <-started; !at.Equal(time.Date(2026, 3, 10, 12, 0, 13, 0, time.UTC)) {
+		t.Errorf("Expected the component to start at 12:00:13, got %v", at)
+	}
+}
+
+type fixedCadence int
+
+func (c *fixedCadence) Multiplier() int {
+	return int(*c)
+}
+
+func TestSkipper(t *testing.T) {
+	cadence := fixedCadence(3)
+	skipper := NewSkipper(&cadence)
+
+	var runs []int
+	for tick := 1; tick <= 7; tick++ {
+		if tick == 5 {
+			cadence = 1 // Back to every interval
+		}
+		if !skipper.Skip() {
+			runs = append(runs, tick)
+		}
+	}
+	if len(runs) != 4 || runs[0] != 3 || runs[1] != 5 || runs[3] != 7 {
+		t.Errorf("Expected runs on ticks 3, 5, 6 and 7, got %v", runs)
+	}
+
+	var zero Skipper
+	if zero.Skip() {
+		t.Error("Expected the zero skipper never to skip")
+	}
+}
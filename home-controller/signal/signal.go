@@ -0,0 +1,187 @@
+package signal
+
+import (
+	"fmt"
+	"math"
+	"time"
+)
+
+// Filter types accepted by Spec
+const (
+	TypeMovingAverage = "moving_average"
+	TypeEWMA          = "ewma"
+	TypeHysteresis    = "hysteresis"
+	TypeDebounce      = "debounce"
+)
+
+// Filter transforms a series sample by sample, e.g. smoothing 2-second power readings before a rule
+// acts on them; filters keep state and are not safe for concurrent use
+type Filter interface {
+	// Update adds a sample taken at the given time and returns the filtered value
+	Update(value float64, at time.Time) float64
+}
+
+// Spec describes a filter by type and parameters, so each series can get its own instance
+type Spec struct {
+	Type         string
+	Window       time.Duration // moving_average: samples averaged
+	TimeConstant time.Duration // ewma: time for a step to reach 63% of its size
+	Low, High    float64       // hysteresis: output turns 1 at or above High and 0 at or below Low
+	Hold         time.Duration // debounce: time a new value must hold before it is passed on
+}
+
+// Validate reports whether the spec can create a filter
+func (s Spec) Validate() error {
+	switch s.Type {
+	case TypeMovingAverage:
+		if s.Window <= 0 {
+			return fmt.Errorf("moving_average requires a positive window")
+		}
+	case TypeEWMA:
+		if s.TimeConstant <= 0 {
+			return fmt.Errorf("ewma requires a positive time constant")
+		}
+	case TypeHysteresis:
+		if s.Low >= s.High {
+			return fmt.Errorf("hysteresis requires low below high, got %g and %g", s.Low, s.High)
+		}
+	case TypeDebounce:
+		if s.Hold <= 0 {
+			return fmt.Errorf("debounce requires a positive hold")
+		}
+	default:
+		return fmt.Errorf("unknown filter type %q, expected moving_average, ewma, hysteresis or debounce", s.Type)
+	}
+	return nil
+}
+
+// New creates a filter from a validated spec
+func (s Spec) New() Filter {
+	switch s.Type {
+	case TypeMovingAverage:
+		return NewMovingAverage(s.Window)
+	case TypeEWMA:
+		return NewEWMA(s.TimeConstant)
+	case TypeHysteresis:
+		return NewHysteresis(s.Low, s.High)
+	default:
+		return NewDebounce(s.Hold)
+	}
+}
+
+// sample is a value kept by a moving average
+type sample struct {
+	value float64
+	at    time.Time
+}
+
+// MovingAverage averages the samples of a sliding time window
+type MovingAverage struct {
+	window  time.Duration
+	samples []sample
+	sum     float64
+}
+
+// NewMovingAverage creates a moving average over window
+func NewMovingAverage(window time.Duration) *MovingAverage {
+	return &MovingAverage{window: window}
+}
+
+// Update adds a sample and returns the average of the samples within the window ending at it
+func (m *MovingAverage) Update(value float64, at time.Time) float64 {
+	m.samples = append(m.samples, sample{value: value, at: at})
+	m.sum += value
+
+	expired := 0
+	for expired < len(m.samples)-1 && at.Sub(m.samples[expired].at) >= m.window {
+		m.sum -= m.samples[expired].value
+		expired++
+	}
+	m.samples = m.samples[expired:]
+	return m.sum / float64(len(m.samples))
+}
+
+// EWMA is an exponentially weighted moving average whose weights follow the time between samples,
+// so irregular readings are smoothed consistently
+type EWMA struct {
+	timeConstant time.Duration
+	value        float64
+	last         time.Time
+	primed       bool
+}
+
+// NewEWMA creates an EWMA with the given time constant
+func NewEWMA(timeConstant time.Duration) *EWMA {
+	return &EWMA{timeConstant: timeConstant}
+}
+
+// Update adds a sample and returns the average; the first sample is returned as is
+func (e *EWMA) Update(value float64, at time.Time) float64 {
+	if !e.primed {
+		e.value, e.last, e.primed = value, at, true
+		return value
+	}
+	elapsed := at.Sub(e.last)
+	if elapsed < 0 {
+		elapsed = 0
+	}
+	alpha := 1 - math.Exp(-float64(elapsed)/float64(e.timeConstant))
+	e.value += alpha * (value - e.value)
+	e.last = at
+	return e.value
+}
+
+// Hysteresis turns a value into 1 or 0 with separate thresholds for switching on and off,
+// so a value hovering around one threshold doesn't flap
+type Hysteresis struct {
+	low, high float64
+	on        bool
+}
+
+// NewHysteresis creates a hysteresis switching on at high and off at low
+func NewHysteresis(low, high float64) *Hysteresis {
+	return &Hysteresis{low: low, high: high}
+}
+
+// Update returns 1 once the value reached high and 0 once it fell to low; in between the output holds
+func (h *Hysteresis) Update(value float64, at time.Time) float64 {
+	switch {
+	case value >= h.high:
+		h.on = true
+	case value <= h.low:
+		h.on = false
+	}
+	if h.on {
+		return 1
+	}
+	return 0
+}
+
+// Debounce passes a changed value on only after it held for a time
+type Debounce struct {
+	hold         time.Duration
+	output       float64
+	pending      float64
+	pendingSince time.Time
+	primed       bool
+}
+
+// NewDebounce creates a debounce requiring values to hold for hold
+func NewDebounce(hold time.Duration) *Debounce {
+	return &Debounce{hold: hold}
+}
+
+// Update returns the last value that held for the hold time; the first sample is returned as is
+func (d *Debounce) Update(value float64, at time.Time) float64 {
+	if !d.primed {
+		d.output, d.pending, d.pendingSince, d.primed = value, value, at, true
+		return value
+	}
+	if value != d.pending {
+		d.pending, d.pendingSince = value, at
+	}
+	if d.pending != d.output && at.Sub(d.pendingSince) >= d.hold {
+		d.output = d.pending
+	}
+	return d.output
+}
@@ -0,0 +1,90 @@
+package signal
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+var start = time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC)
+
+// feed updates the filter with values two seconds apart and returns the outputs
+func feed(filter Filter, values ...float64) []float64 {
+	outputs := make([]float64, len(values))
+	for i, value := range values {
+		outputs[i] = filter.Update(value, start.Add(time.Duration(i)*2*time.Second))
+	}
+	return outputs
+}
+
+func TestMovingAverage(t *testing.T) {
+	// A 6s window holds three samples two seconds apart
+	outputs := feed(NewMovingAverage(6*time.Second), 3, 6, 9, 12, 0)
+	expected := []float64{3, 4.5, 6, 9, 7}
+	for i := range expected {
+		if outputs[i] != expected[i] {
+			t.Errorf("Sample %d: expected %g, got %g", i, expected[i], outputs[i])
+		}
+	}
+}
+
+func TestEWMA(t *testing.T) {
+	ewma := NewEWMA(10 * time.Second)
+	if got := ewma.Update(100, start); got != 100 {
+		t.Errorf("Expected the first sample as is, got %g", got)
+	}
+	// After one time constant a step covers 63% of its size
+	got := ewma.Update(200, start.Add(10*time.Second))
+	if expected := 100 + 100*(1-math.Exp(-1)); math.Abs(got-expected) > 1e-9 {
+		t.Errorf("Expected %g, got %g", expected, got)
+	}
+}
+
+func TestHysteresis(t *testing.T) {
+	outputs := feed(NewHysteresis(1800, 2000), 1900, 2050, 1950, 1850, 1800, 1900)
+	expected := []float64{0, 1, 1, 1, 0, 0}
+	for i := range expected {
+		if outputs[i] != expected[i] {
+			t.Errorf("Sample %d: expected %g, got %g", i, expected[i], outputs[i])
+		}
+	}
+}
+
+func TestDebounce(t *testing.T) {
+	// A change must hold for 4s, i.e. three samples two seconds apart
+	outputs := feed(NewDebounce(4*time.Second), 0, 1, 0, 1, 1, 1, 0)
+	expected := []float64{0, 0, 0, 0, 0, 1, 1}
+	for i := range expected {
+		if outputs[i] != expected[i] {
+			t.Errorf("Sample %d: expected %g, got %g", i, expected[i], outputs[i])
+		}
+	}
+}
+
+func TestSpec_Validate(t *testing.T) {
+	valid := []Spec{
+		{Type: TypeMovingAverage, Window: time.Minute},
+		{Type: TypeEWMA, TimeConstant: time.Minute},
+		{Type: TypeHysteresis, Low: 1800, High: 2000},
+		{Type: TypeDebounce, Hold: 30 * time.Second},
+	}
+	for _, spec := range valid {
+		if err := spec.Validate(); err != nil {
+			t.Errorf("Expected %s to be valid, got %v", spec.Type, err)
+		}
+		if spec.New() == nil {
+			t.Errorf("Expected a filter for %s", spec.Type)
+		}
+	}
+
+	invalid := []Spec{
+		{Type: TypeMovingAverage},
+		{Type: TypeHysteresis, Low: 2000, High: 1800},
+		{Type: "median", Window: time.Minute},
+	}
+	for _, spec := range invalid {
+		if err := spec.Validate(); err == nil {
+			t.Errorf("Expected an error for %+v", spec)
+		}
+	}
+}
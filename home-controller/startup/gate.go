@@ -0,0 +1,190 @@
+package startup
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mjasion/balena-home/thermostats/clock"
+	"go.uber.org/zap"
+)
+
+// attemptTimeout bounds a single probe, so a hanging one is retried
+const attemptTimeout = 10 * time.Second
+
+// check is a dependency probed before the service starts
+type check struct {
+	name  string
+	probe func(ctx context.Context) error
+}
+
+// Gate holds startup until dependencies that come up after the container are available, e.g.
+// Wi-Fi and Bluetooth on a booting Pi, instead of failing at once and crash-looping
+type Gate struct {
+	checks         []check
+	maxWait        time.Duration
+	initialBackoff time.Duration
+	maxBackoff     time.Duration
+	clock          clock.Clock
+	logger         *zap.Logger
+}
+
+// NewGate creates a gate waiting up to maxWait for its checks
+func NewGate(maxWait time.Duration, logger *zap.Logger) *Gate {
+	return &Gate{
+		maxWait:        maxWait,
+		initialBackoff: time.Second,
+		maxBackoff:     30 * time.Second,
+		clock:          clock.Real,
+		logger:         logger,
+	}
+}
+
+// SetBackoff sets the delay before the first retry, doubling up to maxBackoff
+func (g *Gate) SetBackoff(initial, maxBackoff time.Duration) {
+	g.initialBackoff = initial
+	g.maxBackoff = maxBackoff
+}
+
+// SetClock sets the clock timing retries and the deadline, e.g. a fake one in tests
+func (g *Gate) SetClock(c clock.Clock) {
+	g.clock = c
+}
+
+// Add adds a dependency; probe returns nil once it is available
+func (g *Gate) Add(name string, probe func(ctx context.Context) error) {
+	g.checks = append(g.checks, check{name: name, probe: probe})
+}
+
+// Wait probes every dependency concurrently, retrying failures with backoff, until all are
+// available; it returns an error naming the unavailable ones after maxWait or when ctx is done
+func (g *Gate) Wait(ctx context.Context) error {
+	if len(g.checks) == 0 {
+		return nil
+	}
+	names := make([]string, len(g.checks))
+	for i, c := range g.checks {
+		names[i] = c.name
+	}
+	g.logger.Info("waiting for dependencies", zap.Strings("dependencies", names), zap.Duration("max_wait", g.maxWait))
+
+	deadline := g.clock.NewTimer(g.maxWait)
+	defer deadline.Stop()
+	waitCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var mu sync.Mutex
+	pending := make(map[string]error, len(g.checks))
+	// Filled before any probe starts reporting
+	for _, c := range g.checks {
+		pending[c.name] = fmt.Errorf("not probed yet")
+	}
+	var wg sync.WaitGroup
+	for _, c := range g.checks {
+		wg.Add(1)
+		go func(c check) {
+			defer wg.Done()
+			g.await(waitCtx, c, func(err error) {
+				mu.Lock()
+				defer mu.Unlock()
+				if err == nil {
+					delete(pending, c.name)
+				} else {
+					pending[c.name] = err
+				}
+			})
+		}(c)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-deadline.C():
+	case <-ctx.Done():
+	}
+	cancel()
+	<-done
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(pending) == 0 {
+		g.logger.Info("dependencies available")
+		return nil
+	}
+	unavailable := make([]string, 0, len(pending))
+	for name, err := range pending {
+		unavailable = append(unavailable, fmt.Sprintf("%s: %v", name, err))
+	}
+	sort.Strings(unavailable)
+	return fmt.Errorf("dependencies unavailable after %s: %s", g.maxWait, strings.Join(unavailable, "; "))
+}
+
+// await probes the dependency until it is available or ctx is done, reporting each outcome
+func (g *Gate) await(ctx context.Context, c check, report func(err error)) {
+	backoff := g.initialBackoff
+	for attempt := 1; ; attempt++ {
+		err := g.attempt(ctx, c.probe)
+		if ctx.Err() != nil {
+			return
+		}
+		report(err)
+		if err == nil {
+			if attempt > 1 {
+				g.logger.Info("dependency available", zap.String("dependency", c.name), zap.Int("attempts", attempt))
+			}
+			return
+		}
+		g.logger.Warn("dependency unavailable, retrying",
+			zap.String("dependency", c.name),
+			zap.Int("attempt", attempt),
+			zap.Duration("retry_in", backoff),
+			zap.Error(err),
+		)
+
+		timer := g.clock.NewTimer(backoff)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C():
+		}
+		backoff = min(2*backoff, g.maxBackoff)
+	}
+}
+
+// attempt runs the probe once, giving up on one that ignores its context after attemptTimeout
+func (g *Gate) attempt(ctx context.Context, probe func(ctx context.Context) error) error {
+	attemptCtx, cancel := context.WithTimeout(ctx, attemptTimeout)
+	defer cancel()
+
+	result := make(chan error, 1)
+	go func() {
+		result <- probe(attemptCtx)
+	}()
+	select {
+	case err := <-result:
+		return err
+	case <-attemptCtx.Done():
+		return attemptCtx.Err()
+	}
+}
+
+// Resolve returns a probe succeeding once the host name resolves, e.g. the push endpoint's while
+// Wi-Fi is still connecting
+func Resolve(host string) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		if _, err := net.DefaultResolver.LookupHost(ctx, host); err != nil {
+			return err
+		}
+		return nil
+	}
+}
@@ -0,0 +1,68 @@
+package startup
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// failing returns a probe failing its first failures attempts, counting attempts
+func failing(failures int32, attempts *atomic.Int32) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		if attempts.Add(1) <= failures {
+			return errors.New("not ready")
+		}
+		return nil
+	}
+}
+
+func TestGate_Wait(t *testing.T) {
+	var ble, dns atomic.Int32
+	g := NewGate(time.Minute, zap.NewNop())
+	g.SetBackoff(time.Millisecond, 4*time.Millisecond)
+	g.Add("ble_adapter", failing(3, &ble))
+	g.Add("dns", failing(0, &dns))
+
+	if err := g.Wait(context.Background()); err != nil {
+		t.Fatalf("Expected dependencies available, got %v", err)
+	}
+	if ble.Load() != 4 || dns.Load() != 1 {
+		t.Errorf("Expected 4 BLE attempts and 1 DNS attempt, got %d and %d", ble.Load(), dns.Load())
+	}
+}
+
+func TestGate_Timeout(t *testing.T) {
+	var ble, dns atomic.Int32
+	g := NewGate(50*time.Millisecond, zap.NewNop())
+	g.SetBackoff(time.Millisecond, 5*time.Millisecond)
+	g.Add("ble_adapter", failing(1<<30, &ble))
+	g.Add("dns", failing(0, &dns))
+
+	err := g.Wait(context.Background())
+	if err == nil {
+		t.Fatal("Expected an error after the max wait")
+	}
+	if !strings.Contains(err.Error(), "ble_adapter: not ready") || strings.Contains(err.Error(), "dns") {
+		t.Errorf("Expected only the BLE adapter reported, got %v", err)
+	}
+	if ble.Load() < 2 {
+		t.Errorf("Expected the BLE adapter retried, got %d attempts", ble.Load())
+	}
+}
+
+func TestGate_Cancel(t *testing.T) {
+	var ble atomic.Int32
+	g := NewGate(time.Hour, zap.NewNop())
+	g.Add("ble_adapter", failing(1<<30, &ble))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(20*time.Millisecond, cancel)
+	if err := g.Wait(ctx); err == nil {
+		t.Error("Expected an error when cancelled")
+	}
+}
@@ -0,0 +1,149 @@
+package summary
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/mjasion/balena-home/thermostats/buffer"
+	"go.uber.org/zap"
+)
+
+// flushDelay leaves room for readings forwarded or re-added shortly after the hour ends
+const flushDelay = time.Minute
+
+// bucketKey identifies the aggregate of one metric of one sensor in one hour
+type bucketKey struct {
+	hour   time.Time
+	metric string
+	mac    string
+}
+
+// bucket accumulates samples of a single metric within an hour
+type bucket struct {
+	sensorName string
+	sensorID   int
+	min        float64
+	max        float64
+	sum        float64
+	count      int
+}
+
+// Summarizer aggregates BLE sensor readings into hourly min, max and avg readings
+type Summarizer struct {
+	buffer *buffer.RingBuffer
+	logger *zap.Logger
+
+	mu      sync.Mutex
+	buckets map[bucketKey]*bucket
+	flushed time.Time // End of the last flushed hour; older samples are dropped
+}
+
+// New creates a summarizer; register Observe as a buffer listener to feed it
+func New(buf *buffer.RingBuffer, logger *zap.Logger) *Summarizer {
+	return &Summarizer{
+		buffer:  buf,
+		logger:  logger,
+		buckets: make(map[bucketKey]*bucket),
+	}
+}
+
+// Observe adds BLE temperature and humidity values to their hourly buckets
+func (s *Summarizer) Observe(reading *buffer.Reading) {
+	r := reading.BLE
+	if r == nil {
+		return
+	}
+	timestamp := r.Timestamp
+	hour := timestamp.Truncate(time.Hour)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if hour.Before(s.flushed) {
+		s.logger.Debug("dropping reading for already summarized hour",
+			zap.String("sensor_name", r.SensorName),
+			zap.Time("timestamp", timestamp),
+		)
+		return
+	}
+	s.add(bucketKey{hour: hour, metric: "ble_temperature_celsius", mac: r.MAC}, r, r.TemperatureCelsius)
+	s.add(bucketKey{hour: hour, metric: "ble_humidity_percent", mac: r.MAC}, r, float64(r.HumidityPercent))
+}
+
+// add updates a bucket with a single value
+func (s *Summarizer) add(key bucketKey, r *buffer.SensorReading, value float64) {
+	b, ok := s.buckets[key]
+	if !ok {
+		s.buckets[key] = &bucket{
+			sensorName: r.SensorName,
+			sensorID:   r.SensorID,
+			min:        value,
+			max:        value,
+			sum:        value,
+			count:      1,
+		}
+		return
+	}
+	b.min = min(b.min, value)
+	b.max = max(b.max, value)
+	b.sum += value
+	b.count++
+}
+
+// Start flushes each hour shortly after it ends until the context is cancelled
+// The current partial hour is not flushed on shutdown
+func (s *Summarizer) Start(ctx context.Context) {
+	s.logger.Info("starting hourly summarizer")
+
+	for {
+		next := time.Now().Truncate(time.Hour).Add(time.Hour + flushDelay)
+		select {
+		case <-ctx.Done():
+			s.logger.Info("stopping hourly summarizer")
+			return
+		case <-time.After(time.Until(next)):
+			s.flush(next.Add(-flushDelay))
+		}
+	}
+}
+
+// flush adds summary readings for every bucket of an hour ending at or before end
+func (s *Summarizer) flush(end time.Time) {
+	var summaries []*buffer.Reading
+	s.mu.Lock()
+	for key, b := range s.buckets {
+		if key.hour.Add(time.Hour).After(end) {
+			continue
+		}
+		summaries = append(summaries, &buffer.Reading{
+			Type: buffer.ReadingTypeSummary,
+			Summary: &buffer.SummaryReading{
+				Timestamp:  key.hour.Add(time.Hour),
+				Metric:     key.metric,
+				MAC:        key.mac,
+				SensorName: b.sensorName,
+				SensorID:   b.sensorID,
+				Min:        b.min,
+				Max:        b.max,
+				Avg:        b.sum / float64(b.count),
+				Count:      b.count,
+			},
+		})
+		delete(s.buckets, key)
+	}
+	if end.After(s.flushed) {
+		s.flushed = end
+	}
+	s.mu.Unlock()
+
+	// Flushed buckets are already removed, so adding the summaries needs no lock
+	for _, r := range summaries {
+		s.buffer.Add(r)
+	}
+
+	s.logger.Debug("flushed hourly summaries",
+		zap.Time("hour_end", end),
+		zap.Int("summary_count", len(summaries)),
+	)
+}
@@ -0,0 +1,67 @@
+package summary
+
+import (
+	"testing"
+	"time"
+
+	"github.com/mjasion/balena-home/thermostats/buffer"
+	"go.uber.org/zap"
+)
+
+func bleReading(ts time.Time, temperature float64, humidity int) *buffer.Reading {
+	return &buffer.Reading{Type: buffer.ReadingTypeBLE, BLE: &buffer.SensorReading{
+		Timestamp: ts, MAC: "A4:C1:38:00:00:01", SensorName: "Bedroom", SensorID: 1,
+		TemperatureCelsius: temperature, HumidityPercent: humidity,
+	}}
+}
+
+func TestFlush_AggregatesCompletedHours(t *testing.T) {
+	buf := buffer.New(100, zap.NewNop())
+	summarizer := New(buf, zap.NewNop())
+	buf.AddListener(summarizer.Observe)
+
+	hour := time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)
+	buf.Add(bleReading(hour.Add(5*time.Minute), 20.0, 40))
+	buf.Add(bleReading(hour.Add(30*time.Minute), 22.0, 50))
+	buf.Add(bleReading(hour.Add(59*time.Minute), 21.0, 60))
+	buf.Add(bleReading(hour.Add(61*time.Minute), 25.0, 70)) // Next hour, not flushed yet
+	buf.GetAllAndClear()
+
+	summarizer.flush(hour.Add(time.Hour))
+
+	readings := buf.GetAllAndClear()
+	if len(readings) != 2 {
+		t.Fatalf("Expected 2 summary readings, got %d", len(readings))
+	}
+	for _, reading := range readings {
+		r := reading.Summary
+		if r == nil {
+			t.Fatalf("Expected summary reading, got %+v", reading)
+		}
+		if !r.Timestamp.Equal(hour.Add(time.Hour)) {
+			t.Errorf("Expected timestamp at end of hour, got %v", r.Timestamp)
+		}
+		switch r.Metric {
+		case "ble_temperature_celsius":
+			if r.Min != 20 || r.Max != 22 || r.Avg != 21 || r.Count != 3 {
+				t.Errorf("Unexpected temperature summary: %+v", r)
+			}
+		case "ble_humidity_percent":
+			if r.Min != 40 || r.Max != 60 || r.Avg != 50 {
+				t.Errorf("Unexpected humidity summary: %+v", r)
+			}
+		default:
+			t.Errorf("Unexpected metric %s", r.Metric)
+		}
+	}
+
+	// Late readings for the flushed hour are dropped
+	buf.Add(bleReading(hour.Add(50*time.Minute), 30.0, 40))
+	buf.GetAllAndClear()
+	summarizer.flush(hour.Add(2 * time.Hour))
+	for _, reading := range buf.GetAllAndClear() {
+		if reading.Summary.Metric == "ble_temperature_celsius" && reading.Summary.Max != 25 {
+			t.Errorf("Expected only the 11:00 reading in the second hour, got %+v", reading.Summary)
+		}
+	}
+}
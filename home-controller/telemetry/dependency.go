@@ -0,0 +1,323 @@
+package telemetry
+
+import (
+	"context"
+	"crypto/tls"
+	"io"
+	"net/http"
+	"net/http/httptrace"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/mjasion/balena-home/thermostats/buffer"
+	"go.uber.org/zap"
+)
+
+// DurationBuckets are the upper bounds in seconds of the request duration histogram
+var DurationBuckets = []float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30}
+
+// dependencyStats holds cumulative request statistics for one dependency or server route
+type dependencyStats struct {
+	requests      uint64
+	errors        uint64 // Unused for server routes, whose status code is part of the key
+	sentBytes     uint64 // Request body bytes, outbound only
+	receivedBytes uint64 // Response body bytes read, outbound only
+	durationSum   float64
+	buckets       []uint64 // Non-cumulative counts per DurationBuckets entry, last entry is +Inf
+
+	// Connection statistics, for dependencies instrumented with InstrumentConnections only
+	connectionsOpened uint64
+	connectionsReused uint64
+	connectSum        float64 // From asking the pool for a connection until a new one is ready: DNS, TCP and TLS
+	tlsHandshakes     uint64
+	tlsHandshakeSum   float64
+	firstBytes        uint64  // Responses whose first byte arrived
+	firstByteSum      float64 // From sending the request until the first response byte
+}
+
+// Recorder derives request rate, error and duration metrics from outbound HTTP calls
+// and from requests served by the embedded HTTP servers
+// A nil *Recorder is valid and leaves clients uninstrumented
+type Recorder struct {
+	mu             sync.Mutex
+	stats          map[string]*dependencyStats
+	routes         map[routeKey]*dependencyStats
+	buffer         *buffer.RingBuffer
+	reportInterval time.Duration
+	logger         *zap.Logger
+}
+
+// NewRecorder creates a recorder adding dependency readings to the buffer every reportIntervalSeconds
+func NewRecorder(buf *buffer.RingBuffer, reportIntervalSeconds int, logger *zap.Logger) *Recorder {
+	return &Recorder{
+		stats:          make(map[string]*dependencyStats),
+		routes:         make(map[routeKey]*dependencyStats),
+		buffer:         buf,
+		reportInterval: time.Duration(reportIntervalSeconds) * time.Second,
+		logger:         logger,
+	}
+}
+
+// Instrument wraps the client's transport so its requests are recorded under the dependency name
+func (r *Recorder) Instrument(client *http.Client, dependency string) {
+	if r == nil {
+		return
+	}
+	client.Transport = r.Wrap(dependency, client.Transport)
+}
+
+// InstrumentConnections instruments the client like Instrument and also traces its connections:
+// new and reused ones, connect and TLS handshake time and time to first byte, e.g. to see whether
+// pushes pay for a TLS handshake each time
+func (r *Recorder) InstrumentConnections(client *http.Client, dependency string) {
+	if r == nil {
+		return
+	}
+	client.Transport = r.wrap(dependency, client.Transport, true)
+}
+
+// Wrap returns a round tripper recording requests sent through next
+// A nil next uses http.DefaultTransport
+func (r *Recorder) Wrap(dependency string, next http.RoundTripper) http.RoundTripper {
+	return r.wrap(dependency, next, false)
+}
+
+// wrap returns a round tripper recording requests, tracing their connections if trace is set
+func (r *Recorder) wrap(dependency string, next http.RoundTripper, trace bool) http.RoundTripper {
+	if r == nil {
+		return next
+	}
+	if next == nil {
+		next = http.DefaultTransport
+	}
+
+	// Register the dependency so it is reported before its first request
+	r.mu.Lock()
+	r.statsFor(dependency)
+	r.mu.Unlock()
+
+	return &roundTripper{recorder: r, dependency: dependency, next: next, trace: trace}
+}
+
+// roundTripper records the outcome and duration of each request
+type roundTripper struct {
+	recorder   *Recorder
+	dependency string
+	next       http.RoundTripper
+	trace      bool // Record connection statistics through an httptrace.ClientTrace
+}
+
+// RoundTrip sends the request and records it, counting body bytes in both directions
+func (t *roundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Body != nil && req.ContentLength > 0 {
+		t.recorder.addBytes(t.dependency, uint64(req.ContentLength), 0)
+	} else if req.Body != nil && req.Body != http.NoBody {
+		// Unknown length, count while the transport reads the body
+		req = req.Clone(req.Context())
+		req.Body = &countingBody{ReadCloser: req.Body, count: func(n int) { t.recorder.addBytes(t.dependency, uint64(n), 0) }}
+	}
+
+	start := time.Now()
+	if t.trace {
+		req = t.traceConnections(req, start)
+	}
+	resp, err := t.next.RoundTrip(req)
+	failed := err != nil || resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests
+	t.recorder.observe(t.dependency, time.Since(start), failed)
+	if resp != nil {
+		resp.Body = &countingBody{ReadCloser: resp.Body, count: func(n int) { t.recorder.addBytes(t.dependency, 0, uint64(n)) }}
+	}
+	return resp, err
+}
+
+// Unwrap returns the wrapped round tripper
+func (t *roundTripper) Unwrap() http.RoundTripper {
+	return t.next
+}
+
+// traceConnections returns the request with a trace recording how it got its connection and
+// when the first response byte arrived
+// GetConn and GotConn run on the caller's goroutine, the TLS hooks on the dialing goroutine
+func (t *roundTripper) traceConnections(req *http.Request, start time.Time) *http.Request {
+	var getConn, tlsStart time.Time
+	trace := &httptrace.ClientTrace{
+		GetConn: func(string) {
+			getConn = time.Now()
+		},
+		GotConn: func(info httptrace.GotConnInfo) {
+			t.recorder.observeConn(t.dependency, info.Reused, time.Since(getConn))
+		},
+		TLSHandshakeStart: func() {
+			tlsStart = time.Now()
+		},
+		TLSHandshakeDone: func(tls.ConnectionState, error) {
+			t.recorder.observeTLSHandshake(t.dependency, time.Since(tlsStart))
+		},
+		GotFirstResponseByte: func() {
+			t.recorder.observeFirstByte(t.dependency, time.Since(start))
+		},
+	}
+	return req.WithContext(httptrace.WithClientTrace(req.Context(), trace))
+}
+
+// countingBody reports the number of bytes read from a body
+type countingBody struct {
+	io.ReadCloser
+	count func(n int)
+}
+
+// Read reads from the body and reports the bytes read
+func (b *countingBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if n > 0 {
+		b.count(n)
+	}
+	return n, err
+}
+
+// addBytes records body bytes sent to and received from a dependency
+func (r *Recorder) addBytes(dependency string, sent, received uint64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stats := r.statsFor(dependency)
+	stats.sentBytes += sent
+	stats.receivedBytes += received
+}
+
+// observe records a single request
+func (r *Recorder) observe(dependency string, duration time.Duration, failed bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stats := r.statsFor(dependency)
+	stats.requests++
+	if failed {
+		stats.errors++
+	}
+	seconds := duration.Seconds()
+	stats.durationSum += seconds
+	stats.buckets[sort.SearchFloat64s(DurationBuckets, seconds)]++
+}
+
+// observeConn records a connection handed to a request, with the time to set up a new one
+func (r *Recorder) observeConn(dependency string, reused bool, setup time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stats := r.statsFor(dependency)
+	if reused {
+		stats.connectionsReused++
+		return
+	}
+	stats.connectionsOpened++
+	stats.connectSum += setup.Seconds()
+}
+
+// observeTLSHandshake records a TLS handshake of a new connection
+func (r *Recorder) observeTLSHandshake(dependency string, duration time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stats := r.statsFor(dependency)
+	stats.tlsHandshakes++
+	stats.tlsHandshakeSum += duration.Seconds()
+}
+
+// observeFirstByte records the time to the first response byte
+func (r *Recorder) observeFirstByte(dependency string, duration time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stats := r.statsFor(dependency)
+	stats.firstBytes++
+	stats.firstByteSum += duration.Seconds()
+}
+
+// statsFor returns the stats for a dependency, creating them if needed; caller holds the lock
+func (r *Recorder) statsFor(dependency string) *dependencyStats {
+	stats, ok := r.stats[dependency]
+	if !ok {
+		stats = &dependencyStats{buckets: make([]uint64, len(DurationBuckets)+1)}
+		r.stats[dependency] = stats
+	}
+	return stats
+}
+
+// Snapshot returns the cumulative statistics of every dependency, sorted by name
+func (r *Recorder) Snapshot() []*buffer.DependencyReading {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	readings := make([]*buffer.DependencyReading, 0, len(r.stats))
+	for dependency, stats := range r.stats {
+		reading := &buffer.DependencyReading{
+			Timestamp:          now,
+			Dependency:         dependency,
+			Requests:           stats.requests,
+			Errors:             stats.errors,
+			SentBytes:          stats.sentBytes,
+			ReceivedBytes:      stats.receivedBytes,
+			DurationSumSeconds: stats.durationSum,
+			DurationBuckets:    cumulativeBuckets(DurationBuckets, stats.buckets),
+
+			ConnectionsOpened:      stats.connectionsOpened,
+			ConnectionsReused:      stats.connectionsReused,
+			ConnectSecondsSum:      stats.connectSum,
+			TLSHandshakes:          stats.tlsHandshakes,
+			TLSHandshakeSecondsSum: stats.tlsHandshakeSum,
+			FirstBytes:             stats.firstBytes,
+			FirstByteSecondsSum:    stats.firstByteSum,
+		}
+		readings = append(readings, reading)
+	}
+
+	sort.Slice(readings, func(i, j int) bool {
+		return readings[i].Dependency < readings[j].Dependency
+	})
+	return readings
+}
+
+// cumulativeBuckets converts non-cumulative counts to cumulative histogram buckets, excluding +Inf
+func cumulativeBuckets(bounds []float64, counts []uint64) []buffer.HistogramBucket {
+	buckets := make([]buffer.HistogramBucket, len(bounds))
+	var cumulative uint64
+	for i, upperBound := range bounds {
+		cumulative += counts[i]
+		buckets[i] = buffer.HistogramBucket{UpperBound: upperBound, Count: cumulative}
+	}
+	return buckets
+}
+
+// Start periodically adds dependency and route readings to the buffer until the context is cancelled
+func (r *Recorder) Start(ctx context.Context) {
+	r.logger.Info("starting telemetry metrics reporter",
+		zap.Duration("report_interval", r.reportInterval),
+	)
+
+	ticker := time.NewTicker(r.reportInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			r.logger.Info("telemetry metrics reporter stopping")
+			return
+		case <-ticker.C:
+			for _, reading := range r.Snapshot() {
+				r.buffer.Add(&buffer.Reading{
+					Type:       buffer.ReadingTypeDependency,
+					Dependency: reading,
+				})
+			}
+			for _, reading := range r.RouteSnapshot() {
+				r.buffer.Add(&buffer.Reading{
+					Type: buffer.ReadingTypeHTTP,
+					HTTP: reading,
+				})
+			}
+		}
+	}
+}
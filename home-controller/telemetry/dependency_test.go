@@ -0,0 +1,184 @@
+package telemetry
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/mjasion/balena-home/thermostats/admin"
+	"github.com/mjasion/balena-home/thermostats/buffer"
+	"go.uber.org/zap"
+)
+
+func TestRecorder_Instrument(t *testing.T) {
+	status := http.StatusOK
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+
+	recorder := NewRecorder(buffer.New(10, zap.NewNop()), 60, zap.NewNop())
+	client := &http.Client{}
+	recorder.Instrument(client, "power")
+
+	for _, code := range []int{http.StatusOK, http.StatusNotFound, http.StatusServiceUnavailable, http.StatusTooManyRequests} {
+		status = code
+		resp, err := client.Get(server.URL)
+		if err != nil {
+			t.Fatalf("Request failed: %v", err)
+		}
+		resp.Body.Close()
+	}
+
+	snapshot := recorder.Snapshot()
+	if len(snapshot) != 1 {
+		t.Fatalf("Expected 1 dependency, got %d", len(snapshot))
+	}
+	reading := snapshot[0]
+	if reading.Dependency != "power" {
+		t.Errorf("Expected dependency power, got %s", reading.Dependency)
+	}
+	if reading.Requests != 4 {
+		t.Errorf("Expected 4 requests, got %d", reading.Requests)
+	}
+	if reading.Errors != 2 {
+		t.Errorf("Expected 2 errors (503, 429), got %d", reading.Errors)
+	}
+	if len(reading.DurationBuckets) != len(DurationBuckets) {
+		t.Fatalf("Expected %d buckets, got %d", len(DurationBuckets), len(reading.DurationBuckets))
+	}
+	last := reading.DurationBuckets[len(reading.DurationBuckets)-1]
+	if last.UpperBound != 30 || last.Count != 4 {
+		t.Errorf("Expected all 4 local requests within 30s bucket, got %+v", last)
+	}
+}
+
+func TestRecorder_InstrumentConnections(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	recorder := NewRecorder(buffer.New(10, zap.NewNop()), 60, zap.NewNop())
+	client := server.Client()
+	recorder.InstrumentConnections(client, "prometheus")
+
+	for range 3 {
+		resp, err := client.Get(server.URL)
+		if err != nil {
+			t.Fatalf("Request failed: %v", err)
+		}
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+	}
+
+	reading := recorder.Snapshot()[0]
+	if reading.ConnectionsOpened != 1 || reading.ConnectionsReused != 2 {
+		t.Errorf("Expected 1 opened and 2 reused connections, got %d and %d", reading.ConnectionsOpened, reading.ConnectionsReused)
+	}
+	if reading.TLSHandshakes != 1 || reading.TLSHandshakeSecondsSum <= 0 {
+		t.Errorf("Expected 1 timed TLS handshake, got %d in %vs", reading.TLSHandshakes, reading.TLSHandshakeSecondsSum)
+	}
+	if reading.FirstBytes != 3 || reading.ConnectSecondsSum <= 0 {
+		t.Errorf("Expected 3 first bytes and a timed connect, got %d and %vs", reading.FirstBytes, reading.ConnectSecondsSum)
+	}
+}
+
+func TestRecorder_TransportError(t *testing.T) {
+	recorder := NewRecorder(buffer.New(10, zap.NewNop()), 60, zap.NewNop())
+	client := &http.Client{}
+	recorder.Instrument(client, "netatmo")
+
+	if _, err := client.Get("http://127.0.0.1:1"); err == nil {
+		t.Fatal("Expected connection error")
+	}
+
+	reading := recorder.Snapshot()[0]
+	if reading.Requests != 1 || reading.Errors != 1 {
+		t.Errorf("Expected 1 failed request, got %d requests, %d errors", reading.Requests, reading.Errors)
+	}
+}
+
+func TestRecorder_Buckets(t *testing.T) {
+	recorder := NewRecorder(buffer.New(10, zap.NewNop()), 60, zap.NewNop())
+	recorder.observe("prometheus", 50_000_000, false)    // 0.05s, on the first bound
+	recorder.observe("prometheus", 300_000_000, false)   // 0.3s
+	recorder.observe("prometheus", 60_000_000_000, true) // 60s, beyond the last bound
+
+	reading := recorder.Snapshot()[0]
+	expected := []uint64{1, 1, 1, 2, 2, 2, 2, 2, 2}
+	for i, bucket := range reading.DurationBuckets {
+		if bucket.Count != expected[i] {
+			t.Errorf("Bucket le=%v: expected %d, got %d", bucket.UpperBound, expected[i], bucket.Count)
+		}
+	}
+	if reading.Requests != 3 || reading.Errors != 1 {
+		t.Errorf("Expected 3 requests and 1 error, got %d and %d", reading.Requests, reading.Errors)
+	}
+	if reading.DurationSumSeconds < 60.34 || reading.DurationSumSeconds > 60.36 {
+		t.Errorf("Expected duration sum 60.35, got %f", reading.DurationSumSeconds)
+	}
+}
+
+func TestRecorder_Nil(t *testing.T) {
+	var recorder *Recorder
+	client := &http.Client{}
+	recorder.Instrument(client, "prometheus")
+	if client.Transport != nil {
+		t.Error("Expected nil recorder to leave the transport unchanged")
+	}
+}
+
+func TestRecorder_CountsBytes(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(io.Discard, r.Body)
+		w.Write([]byte("accepted"))
+	}))
+	defer server.Close()
+
+	recorder := NewRecorder(buffer.New(10, zap.NewNop()), 60, zap.NewNop())
+	client := &http.Client{}
+	recorder.Instrument(client, "loki")
+
+	// Known length
+	resp, err := client.Post(server.URL, "text/plain", strings.NewReader("0123456789"))
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+
+	// Unknown length, streamed
+	resp, err = client.Post(server.URL, "text/plain", io.MultiReader(strings.NewReader("01234"), strings.NewReader("56789")))
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+
+	reading := recorder.Snapshot()[0]
+	if reading.SentBytes != 20 {
+		t.Errorf("Expected 20 sent bytes, got %d", reading.SentBytes)
+	}
+	if reading.ReceivedBytes != 16 {
+		t.Errorf("Expected 16 received bytes, got %d", reading.ReceivedBytes)
+	}
+
+	// The admin endpoint reports the totals
+	adminServer := admin.New(":0", zap.NewNop())
+	recorder.RegisterHandlers(adminServer)
+	w := httptest.NewRecorder()
+	adminServer.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/dependencies", nil))
+	var body struct {
+		Data dependenciesStatus `json:"data"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&body); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if body.Data.TotalSentBytes != 20 || len(body.Data.Dependencies) != 1 || body.Data.Dependencies[0].Dependency != "loki" {
+		t.Errorf("Expected loki with 20 sent bytes, got %+v", body.Data)
+	}
+}
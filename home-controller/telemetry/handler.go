@@ -0,0 +1,58 @@
+package telemetry
+
+import (
+	"net/http"
+
+	"github.com/mjasion/balena-home/thermostats/admin"
+)
+
+// dependencyStatus is a dependency as returned by GET /api/dependencies
+type dependencyStatus struct {
+	Dependency         string  `json:"dependency"`
+	Requests           uint64  `json:"requests"`
+	Errors             uint64  `json:"errors"`
+	SentBytes          uint64  `json:"sent_bytes"`
+	ReceivedBytes      uint64  `json:"received_bytes"`
+	DurationSumSeconds float64 `json:"duration_sum_seconds"`
+	ConnectionsOpened  uint64  `json:"connections_opened,omitempty"`
+	ConnectionsReused  uint64  `json:"connections_reused,omitempty"`
+	TLSHandshakes      uint64  `json:"tls_handshakes,omitempty"`
+}
+
+// dependenciesStatus is the body of GET /api/dependencies
+type dependenciesStatus struct {
+	Dependencies       []dependencyStatus `json:"dependencies"`
+	TotalSentBytes     uint64             `json:"total_sent_bytes"`
+	TotalReceivedBytes uint64             `json:"total_received_bytes"`
+}
+
+// RegisterHandlers registers the dependency status endpoint on the admin server
+func (r *Recorder) RegisterHandlers(server *admin.Server) {
+	server.HandleFunc("GET /api/dependencies", r.handleDependencies)
+}
+
+// handleDependencies handles GET /api/dependencies, showing the traffic of each outbound
+// dependency since start, e.g. to see how much of a metered data plan telemetry uses
+func (r *Recorder) handleDependencies(w http.ResponseWriter, req *http.Request) {
+	status := dependenciesStatus{Dependencies: []dependencyStatus{}}
+	for _, reading := range r.Snapshot() {
+		status.Dependencies = append(status.Dependencies, dependencyStatus{
+			Dependency:         reading.Dependency,
+			Requests:           reading.Requests,
+			Errors:             reading.Errors,
+			SentBytes:          reading.SentBytes,
+			ReceivedBytes:      reading.ReceivedBytes,
+			DurationSumSeconds: reading.DurationSumSeconds,
+			ConnectionsOpened:  reading.ConnectionsOpened,
+			ConnectionsReused:  reading.ConnectionsReused,
+			TLSHandshakes:      reading.TLSHandshakes,
+		})
+		status.TotalSentBytes += reading.SentBytes
+		status.TotalReceivedBytes += reading.ReceivedBytes
+	}
+
+	admin.WriteJSON(w, http.StatusOK, admin.Response{
+		Success: true,
+		Data:    status,
+	})
+}
@@ -0,0 +1,67 @@
+package telemetry
+
+import (
+	"sort"
+	"time"
+
+	"github.com/mjasion/balena-home/thermostats/buffer"
+)
+
+// ServerDurationBuckets are the upper bounds in seconds of the served request duration histogram
+// Embedded handlers mostly answer from memory, so the buckets are finer than DurationBuckets
+var ServerDurationBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5}
+
+// routeKey identifies the requests of a route answered with one status code
+type routeKey struct {
+	route string
+	code  int
+}
+
+// ObserveRequest records a request served by an embedded HTTP server
+// route is the matched handler pattern, so the number of series stays bounded
+func (r *Recorder) ObserveRequest(route string, code int, duration time.Duration) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	key := routeKey{route: route, code: code}
+	stats, ok := r.routes[key]
+	if !ok {
+		stats = &dependencyStats{buckets: make([]uint64, len(ServerDurationBuckets)+1)}
+		r.routes[key] = stats
+	}
+	stats.requests++
+	seconds := duration.Seconds()
+	stats.durationSum += seconds
+	stats.buckets[sort.SearchFloat64s(ServerDurationBuckets, seconds)]++
+}
+
+// RouteSnapshot returns the cumulative statistics of every served route and status code,
+// sorted by route and code
+func (r *Recorder) RouteSnapshot() []*buffer.HTTPReading {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	readings := make([]*buffer.HTTPReading, 0, len(r.routes))
+	for key, stats := range r.routes {
+		readings = append(readings, &buffer.HTTPReading{
+			Timestamp:          now,
+			Route:              key.route,
+			Code:               key.code,
+			Requests:           stats.requests,
+			DurationSumSeconds: stats.durationSum,
+			DurationBuckets:    cumulativeBuckets(ServerDurationBuckets, stats.buckets),
+		})
+	}
+
+	sort.Slice(readings, func(i, j int) bool {
+		if readings[i].Route != readings[j].Route {
+			return readings[i].Route < readings[j].Route
+		}
+		return readings[i].Code < readings[j].Code
+	})
+	return readings
+}
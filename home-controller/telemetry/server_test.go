@@ -0,0 +1,38 @@
+package telemetry
+
+import (
+	"testing"
+	"time"
+
+	"github.com/mjasion/balena-home/thermostats/buffer"
+	"go.uber.org/zap"
+)
+
+func TestRecorder_ObserveRequest(t *testing.T) {
+	recorder := NewRecorder(buffer.New(10, zap.NewNop()), 60, zap.NewNop())
+	recorder.ObserveRequest("GET /api/buffer", 200, 3*time.Millisecond)
+	recorder.ObserveRequest("GET /api/buffer", 200, 30*time.Millisecond)
+	recorder.ObserveRequest("unmatched", 404, time.Millisecond)
+
+	snapshot := recorder.RouteSnapshot()
+	if len(snapshot) != 2 {
+		t.Fatalf("Expected 2 route readings, got %d", len(snapshot))
+	}
+	reading := snapshot[0]
+	if reading.Route != "GET /api/buffer" || reading.Code != 200 || reading.Requests != 2 {
+		t.Errorf("Expected 2 requests to GET /api/buffer with 200, got %+v", reading)
+	}
+	expected := []uint64{1, 1, 1, 2, 2, 2, 2, 2, 2, 2}
+	for i, bucket := range reading.DurationBuckets {
+		if bucket.Count != expected[i] {
+			t.Errorf("Bucket le=%v: expected %d, got %d", bucket.UpperBound, expected[i], bucket.Count)
+		}
+	}
+	if snapshot[1].Route != "unmatched" || snapshot[1].Code != 404 {
+		t.Errorf("Expected unmatched 404 second, got %+v", snapshot[1])
+	}
+
+	// A nil recorder ignores requests
+	var nilRecorder *Recorder
+	nilRecorder.ObserveRequest("GET /api/buffer", 200, time.Millisecond)
+}
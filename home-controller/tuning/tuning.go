@@ -0,0 +1,119 @@
+package tuning
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"runtime/debug"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Presets for common devices; the Go defaults collect whenever the small heap doubles, which on a
+// few MiB heap means frequent collections competing with the push path for a single slow core
+var presets = map[string]Settings{
+	// Pi Zero: 512 MiB shared with the OS and the BLE stack, one core
+	"pi-zero": {GCPercent: 200, MemoryLimitBytes: 96 << 20, BallastBytes: 16 << 20},
+	// Pi 3 and 4: 1 GiB or more, four cores
+	"pi4": {GCPercent: 400, MemoryLimitBytes: 384 << 20},
+}
+
+// Presets returns the preset names, sorted
+func Presets() []string {
+	names := make([]string, 0, len(presets))
+	for name := range presets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Settings tune the Go garbage collector; zero values keep the runtime default
+type Settings struct {
+	GCPercent        int   // GOGC, -1 disables collection until the memory limit is reached
+	MemoryLimitBytes int64 // GOMEMLIMIT, the soft limit the collector works harder to stay below
+	BallastBytes     int   // Allocation keeping the heap target above the live heap; it is never touched, so it costs no RAM
+}
+
+// Resolve returns the named preset with the overrides applied; an empty preset starts from the runtime defaults
+func Resolve(preset string, overrides Settings) (Settings, error) {
+	settings := Settings{}
+	if preset != "" {
+		var ok bool
+		if settings, ok = presets[preset]; !ok {
+			return Settings{}, fmt.Errorf("unknown runtime preset %q, expected one of %s", preset, strings.Join(Presets(), ", "))
+		}
+	}
+	if overrides.GCPercent != 0 {
+		settings.GCPercent = overrides.GCPercent
+	}
+	if overrides.MemoryLimitBytes != 0 {
+		settings.MemoryLimitBytes = overrides.MemoryLimitBytes
+	}
+	if overrides.BallastBytes != 0 {
+		settings.BallastBytes = overrides.BallastBytes
+	}
+	return settings, nil
+}
+
+// ballast is kept reachable for the lifetime of the process
+var ballast []byte
+
+// Applied is the effective runtime configuration
+type Applied struct {
+	GCPercent        int
+	MemoryLimitBytes int64 // math.MaxInt64 when unlimited
+	BallastBytes     int
+	FromEnvironment  []string // GOGC and GOMEMLIMIT set in the environment, which take precedence
+}
+
+// Apply tunes the runtime with the settings and returns the effective values; GOGC and GOMEMLIMIT
+// environment variables win, so the usual Go knobs keep working for one-off experiments
+func Apply(settings Settings) Applied {
+	var applied Applied
+	if _, ok := os.LookupEnv("GOGC"); ok {
+		applied.FromEnvironment = append(applied.FromEnvironment, "GOGC")
+	} else if settings.GCPercent != 0 {
+		debug.SetGCPercent(settings.GCPercent)
+	}
+	if _, ok := os.LookupEnv("GOMEMLIMIT"); ok {
+		applied.FromEnvironment = append(applied.FromEnvironment, "GOMEMLIMIT")
+	} else if settings.MemoryLimitBytes > 0 {
+		debug.SetMemoryLimit(settings.MemoryLimitBytes)
+	}
+	if settings.BallastBytes > 0 {
+		ballast = make([]byte, settings.BallastBytes)
+	}
+
+	applied.GCPercent = gcPercent()
+	// A negative limit reads the current one without changing it
+	applied.MemoryLimitBytes = debug.SetMemoryLimit(-1)
+	applied.BallastBytes = len(ballast)
+	return applied
+}
+
+// gcPercent returns the current GOGC; the runtime only reports it when setting a new one
+func gcPercent() int {
+	current := debug.SetGCPercent(100)
+	debug.SetGCPercent(current)
+	return current
+}
+
+// Labels returns the effective settings as metric labels, added to the build info series so
+// devices with different tuning can be compared
+func (a Applied) Labels() map[string]string {
+	memoryLimit := "unlimited"
+	if a.MemoryLimitBytes != math.MaxInt64 {
+		memoryLimit = strconv.FormatInt(a.MemoryLimitBytes, 10)
+	}
+	gogc := strconv.Itoa(a.GCPercent)
+	if a.GCPercent < 0 {
+		gogc = "off"
+	}
+	return map[string]string{
+		"gogc":               gogc,
+		"memory_limit_bytes": memoryLimit,
+		"ballast_bytes":      strconv.Itoa(a.BallastBytes),
+	}
+}
@@ -0,0 +1,51 @@
+package tuning
+
+import (
+	"math"
+	"runtime/debug"
+	"testing"
+)
+
+func TestResolve(t *testing.T) {
+	settings, err := Resolve("pi-zero", Settings{GCPercent: 150})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if settings.GCPercent != 150 || settings.MemoryLimitBytes != 96<<20 || settings.BallastBytes != 16<<20 {
+		t.Errorf("Expected the pi-zero preset with GOGC overridden, got %+v", settings)
+	}
+
+	settings, err = Resolve("", Settings{})
+	if err != nil || settings != (Settings{}) {
+		t.Errorf("Expected runtime defaults without a preset, got %+v, %v", settings, err)
+	}
+
+	if _, err := Resolve("pi5", Settings{}); err == nil {
+		t.Error("Expected an error for an unknown preset")
+	}
+}
+
+func TestApply(t *testing.T) {
+	t.Setenv("GOMEMLIMIT", "")
+	previousPercent := debug.SetGCPercent(100)
+	previousLimit := debug.SetMemoryLimit(math.MaxInt64)
+	defer func() {
+		debug.SetGCPercent(previousPercent)
+		debug.SetMemoryLimit(previousLimit)
+		ballast = nil
+	}()
+
+	applied := Apply(Settings{GCPercent: 300, MemoryLimitBytes: 64 << 20, BallastBytes: 1 << 20})
+	if applied.GCPercent != 300 || applied.BallastBytes != 1<<20 {
+		t.Errorf("Expected GOGC 300 and a 1 MiB ballast, got %+v", applied)
+	}
+	// GOMEMLIMIT in the environment wins over the configured limit
+	if applied.MemoryLimitBytes != math.MaxInt64 || len(applied.FromEnvironment) != 1 || applied.FromEnvironment[0] != "GOMEMLIMIT" {
+		t.Errorf("Expected the memory limit left to the environment, got %+v", applied)
+	}
+
+	labels := applied.Labels()
+	if labels["gogc"] != "300" || labels["memory_limit_bytes"] != "unlimited" || labels["ballast_bytes"] != "1048576" {
+		t.Errorf("Expected labels of the applied settings, got %v", labels)
+	}
+}
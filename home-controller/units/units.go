@@ -0,0 +1,107 @@
+package units
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Unit is a canonical base unit, named after the metric name suffix it requires
+type Unit string
+
+// Base units; metrics are always pushed in these, converted from whatever the source reports
+const (
+	None            Unit = ""
+	Celsius         Unit = "celsius"
+	Percent         Unit = "percent"
+	Watts           Unit = "watts"
+	Volts           Unit = "volts"
+	Amperes         Unit = "amperes"
+	Hertz           Unit = "hertz"
+	Hectopascals    Unit = "hpa"
+	KilowattHours   Unit = "kwh"
+	PartsPerMillion Unit = "ppm"
+	Lux             Unit = "lux"
+)
+
+// conversion converts a source unit to a base unit by multiplying with factor
+type conversion struct {
+	unit   Unit
+	factor float64
+}
+
+// sourceUnits maps the unit symbols reported by devices and APIs to their base unit
+var sourceUnits = map[string]conversion{
+	"°C":           {Celsius, 1},
+	"C":            {Celsius, 1},
+	"dC":           {Celsius, 0.1}, // Deci-degrees, common in Modbus registers
+	"decicelsius":  {Celsius, 0.1},
+	"mC":           {Celsius, 0.001}, // Milli-degrees, as in the 1-Wire w1_slave file
+	"millicelsius": {Celsius, 0.001},
+	"%":            {Percent, 1},
+	"W":            {Watts, 1},
+	"kW":           {Watts, 1000},
+	"V":            {Volts, 1},
+	"dV":           {Volts, 0.1}, // Deci-volts, as reported by BleBox meters
+	"mV":           {Volts, 0.001},
+	"A":            {Amperes, 1},
+	"mA":           {Amperes, 0.001},
+	"Hz":           {Hertz, 1},
+	"hPa":          {Hectopascals, 1},
+	"mbar":         {Hectopascals, 1},
+	"kPa":          {Hectopascals, 10},
+	"Pa":           {Hectopascals, 0.01},
+	"kWh":          {KilowattHours, 1},
+	"Wh":           {KilowattHours, 0.001},
+	"ppm":          {PartsPerMillion, 1},
+	"lx":           {Lux, 1},
+}
+
+// knownUnits lists the base units
+var knownUnits = []Unit{Celsius, Percent, Watts, Volts, Amperes, Hertz, Hectopascals, KilowattHours, PartsPerMillion, Lux}
+
+// Suffix returns the metric name suffix of the unit, e.g. _celsius, or "" for None
+func (u Unit) Suffix() string {
+	if u == None {
+		return ""
+	}
+	return "_" + string(u)
+}
+
+// FromName returns the unit a metric name's suffix declares, or None
+func FromName(name string) Unit {
+	for _, unit := range knownUnits {
+		if strings.HasSuffix(name, unit.Suffix()) {
+			return unit
+		}
+	}
+	return None
+}
+
+// ValidateName checks that a metric name ends with the suffix of its unit
+func ValidateName(name string, unit Unit) error {
+	if unit != None && !strings.HasSuffix(name, unit.Suffix()) {
+		return fmt.Errorf("metric %s must end with %s", name, unit.Suffix())
+	}
+	return nil
+}
+
+// Factor returns the multiplier converting values in the source unit symbol to unit
+func Factor(from string, to Unit) (float64, error) {
+	conv, ok := sourceUnits[from]
+	if !ok {
+		return 0, fmt.Errorf("unknown unit %q", from)
+	}
+	if conv.unit != to {
+		return 0, fmt.Errorf("cannot convert %s to %s", from, to)
+	}
+	return conv.factor, nil
+}
+
+// Convert converts a value in the source unit symbol to unit
+func Convert(value float64, from string, to Unit) (float64, error) {
+	factor, err := Factor(from, to)
+	if err != nil {
+		return 0, err
+	}
+	return value * factor, nil
+}
@@ -0,0 +1,61 @@
+package units
+
+import "testing"
+
+func TestConvert(t *testing.T) {
+	tests := []struct {
+		value float64
+		from  string
+		to    Unit
+		want  float64
+	}{
+		{215, "dC", Celsius, 21.5},
+		{21500, "mC", Celsius, 21.5},
+		{2417, "dV", Volts, 241.7},
+		{3300, "mV", Volts, 3.3},
+		{1.5, "kW", Watts, 1500},
+		{101.3, "kPa", Hectopascals, 1013},
+		{21.5, "°C", Celsius, 21.5},
+	}
+
+	for _, tt := range tests {
+		got, err := Convert(tt.value, tt.from, tt.to)
+		if err != nil {
+			t.Errorf("Convert(%v, %s, %s): expected no error, got %v", tt.value, tt.from, tt.to, err)
+			continue
+		}
+		if diff := got - tt.want; diff > 1e-9 || diff < -1e-9 {
+			t.Errorf("Convert(%v, %s, %s): expected %v, got %v", tt.value, tt.from, tt.to, tt.want, got)
+		}
+	}
+
+	if _, err := Convert(1, "mV", Celsius); err == nil {
+		t.Error("Expected an error converting volts to celsius")
+	}
+	if _, err := Convert(1, "furlong", Volts); err == nil {
+		t.Error("Expected an error for an unknown unit")
+	}
+}
+
+func TestFromName(t *testing.T) {
+	tests := map[string]Unit{
+		"flow_temperature_celsius":   Celsius,
+		"ble_humidity_percent":       Percent,
+		"power_watts":                Watts,
+		"energy_kwh":                 KilowattHours,
+		"compressor_frequency_hertz": Hertz,
+		"linkquality":                None,
+	}
+	for name, want := range tests {
+		if got := FromName(name); got != want {
+			t.Errorf("FromName(%s): expected %q, got %q", name, want, got)
+		}
+	}
+
+	if err := ValidateName("flow_temperature", Celsius); err == nil {
+		t.Error("Expected an error for a name without the unit suffix")
+	}
+	if err := ValidateName("linkquality", None); err != nil {
+		t.Errorf("Expected unitless names to be valid, got %v", err)
+	}
+}
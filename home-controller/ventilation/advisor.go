@@ -0,0 +1,255 @@
+package ventilation
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/mjasion/balena-home/thermostats/automation"
+	"github.com/mjasion/balena-home/thermostats/buffer"
+	"github.com/mjasion/balena-home/thermostats/climate"
+	"github.com/mjasion/balena-home/thermostats/events"
+	"go.uber.org/zap"
+)
+
+// staleAfter is how long a measurement counts; a sensor that stopped reporting doesn't drive the recommendation
+const staleAfter = 30 * time.Minute
+
+// Thresholds are the hysteresis of the recommendation in absolute humidity difference, indoor minus
+// outdoor, so it doesn't flap while the difference hovers around a single value
+type Thresholds struct {
+	OnDelta           float64 // g/m³ at or above which ventilating is recommended
+	OffDelta          float64 // g/m³ below which the recommendation ends; below OnDelta
+	MinIndoorHumidity float64 // Relative humidity in percent below which indoor air is dry enough
+}
+
+// air is the latest temperature and relative humidity of a sensor; Zigbee devices report them separately
+type air struct {
+	temperature   float64
+	humidity      float64
+	temperatureAt time.Time
+	humidityAt    time.Time
+}
+
+// absoluteHumidity returns the water vapor density in g/m³, false while either value is missing or stale
+func (a air) absoluteHumidity(now time.Time) (float64, bool) {
+	if now.Sub(a.temperatureAt) > staleAfter || now.Sub(a.humidityAt) > staleAfter {
+		return 0, false
+	}
+	return climate.AbsoluteHumidity(a.temperature, a.humidity)
+}
+
+// Advisor compares the absolute humidity of the most humid indoor sensor with an outdoor sensor and
+// recommends ventilating while outdoor air would dry the home, adding a ventilation_recommended
+// reading every interval; the optional webhooks switch an HRV or fan when the recommendation changes
+type Advisor struct {
+	indoor     map[string]bool
+	outdoor    string
+	thresholds Thresholds
+	on, off    automation.Webhook // Empty URLs only record the change
+	caller     *automation.WebhookCaller
+	buffer     *buffer.RingBuffer
+	interval   time.Duration
+	eventLog   *events.Log
+	logger     *zap.Logger
+	now        func() time.Time
+
+	mu          sync.Mutex
+	sensors     map[string]air // By sensor name
+	recommended bool
+}
+
+// New creates an advisor for the named sensors, evaluated every intervalSeconds. Register Observe
+// as a buffer listener to feed it.
+func New(indoor []string, outdoor string, thresholds Thresholds, on, off automation.Webhook, caller *automation.WebhookCaller, buf *buffer.RingBuffer, intervalSeconds int, logger *zap.Logger) *Advisor {
+	indoorSensors := make(map[string]bool, len(indoor))
+	for _, name := range indoor {
+		indoorSensors[name] = true
+	}
+	return &Advisor{
+		indoor:     indoorSensors,
+		outdoor:    outdoor,
+		thresholds: thresholds,
+		on:         on,
+		off:        off,
+		caller:     caller,
+		buffer:     buf,
+		interval:   time.Duration(intervalSeconds) * time.Second,
+		logger:     logger,
+		now:        time.Now,
+		sensors:    make(map[string]air),
+	}
+}
+
+// SetEventLog sets the event log used to record recommendation changes
+func (a *Advisor) SetEventLog(eventLog *events.Log) {
+	a.eventLog = eventLog
+}
+
+// Observe tracks the temperature and humidity of the configured sensors from the reading stream
+func (a *Advisor) Observe(reading *buffer.Reading) {
+	switch reading.Type {
+	case buffer.ReadingTypeBLE:
+		r := reading.BLE
+		humidity := float64(r.HumidityPercent)
+		a.update(r.SensorName, r.Timestamp, &r.TemperatureCelsius, &humidity)
+	case buffer.ReadingTypeI2C:
+		r := reading.I2C
+		a.update(r.SensorName, r.Timestamp, &r.TemperatureCelsius, &r.HumidityPercent)
+	case buffer.ReadingTypeAirQuality:
+		r := reading.AirQuality
+		if r.HasClimate {
+			a.update(r.SensorName, r.Timestamp, &r.TemperatureCelsius, &r.HumidityPercent)
+		}
+	case buffer.ReadingTypeZigbee:
+		r := reading.Zigbee
+		switch r.Metric {
+		case "temperature_celsius":
+			a.update(r.Device, r.Timestamp, &r.Value, nil)
+		case "humidity_percent":
+			a.update(r.Device, r.Timestamp, nil, &r.Value)
+		}
+	}
+}
+
+// update stores the values of a configured sensor; nil leaves a value unchanged
+func (a *Advisor) update(name string, at time.Time, temperature, humidity *float64) {
+	if !a.indoor[name] && name != a.outdoor {
+		return
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	sensor := a.sensors[name]
+	if temperature != nil {
+		sensor.temperature, sensor.temperatureAt = *temperature, at
+	}
+	if humidity != nil {
+		sensor.humidity, sensor.humidityAt = *humidity, at
+	}
+	a.sensors[name] = sensor
+}
+
+// Start evaluates the recommendation every interval until the context is cancelled
+func (a *Advisor) Start(ctx context.Context) {
+	a.logger.Info("starting ventilation advisor",
+		zap.Int("indoor_sensor_count", len(a.indoor)),
+		zap.String("outdoor_sensor", a.outdoor),
+		zap.Float64("on_delta_grams_per_cubic_meter", a.thresholds.OnDelta),
+		zap.Float64("off_delta_grams_per_cubic_meter", a.thresholds.OffDelta),
+		zap.Duration("interval", a.interval),
+	)
+
+	ticker := time.NewTicker(a.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			a.logger.Info("stopping ventilation advisor")
+			return
+		case <-ticker.C:
+			a.Evaluate(ctx)
+		}
+	}
+}
+
+// Evaluate updates the recommendation and adds a reading of it; the recommendation is kept while the
+// outdoor or every indoor sensor is missing or stale
+func (a *Advisor) Evaluate(ctx context.Context) {
+	now := a.now()
+
+	a.mu.Lock()
+	outdoor, outdoorKnown := a.sensors[a.outdoor].absoluteHumidity(now)
+	indoor, indoorHumidity, indoorKnown := 0.0, 0.0, false
+	for name := range a.indoor {
+		sensor := a.sensors[name]
+		if value, ok := sensor.absoluteHumidity(now); ok && (!indoorKnown || value > indoor) {
+			indoor, indoorHumidity, indoorKnown = value, sensor.humidity, true
+		}
+	}
+	recommended := a.recommended
+	a.mu.Unlock()
+
+	if outdoorKnown && indoorKnown {
+		delta := indoor - outdoor
+		want, reason := a.decide(recommended, delta, indoorHumidity)
+		if want != recommended && a.execute(ctx, want, delta, reason) {
+			recommended = want
+		}
+	} else {
+		a.logger.Debug("indoor or outdoor humidity unknown, keeping ventilation recommendation",
+			zap.Bool("indoor_known", indoorKnown),
+			zap.Bool("outdoor_known", outdoorKnown),
+		)
+	}
+
+	a.buffer.Add(&buffer.Reading{
+		Type: buffer.ReadingTypeVentilation,
+		Ventilation: &buffer.VentilationReading{
+			Timestamp:   now,
+			Recommended: recommended,
+		},
+	})
+}
+
+// decide applies the hysteresis to the absolute humidity difference and reports why
+func (a *Advisor) decide(recommended bool, delta, indoorHumidity float64) (bool, string) {
+	if indoorHumidity < a.thresholds.MinIndoorHumidity {
+		return false, fmt.Sprintf("indoor humidity %.0f%% below %.0f%%", indoorHumidity, a.thresholds.MinIndoorHumidity)
+	}
+	if !recommended && delta >= a.thresholds.OnDelta {
+		return true, fmt.Sprintf("indoor air holds %.1f g/m³ more water than outdoor air", delta)
+	}
+	if recommended && delta < a.thresholds.OffDelta {
+		return false, fmt.Sprintf("indoor air holds only %.1f g/m³ more water than outdoor air", delta)
+	}
+	return recommended, ""
+}
+
+// execute calls the webhook of a recommendation change and records the outcome; a failed webhook
+// leaves the recommendation unchanged so the next evaluation retries
+func (a *Advisor) execute(ctx context.Context, recommended bool, delta float64, reason string) bool {
+	action, webhook := automation.ActionEnable, a.on
+	if !recommended {
+		action, webhook = automation.ActionDisable, a.off
+	}
+
+	fields := map[string]string{
+		"action":                      action,
+		"delta_grams_per_cubic_meter": fmt.Sprintf("%.2f", delta),
+		"reason":                      reason,
+	}
+	if webhook.URL != "" {
+		if err := a.caller.Call(ctx, webhook); err != nil {
+			a.logger.Error("ventilation webhook failed", zap.String("action", action), zap.Error(err))
+			fields["error"] = err.Error()
+			a.eventLog.Record(events.TypeAutomationFailed, "ventilation",
+				fmt.Sprintf("%s action for ventilation failed", action), fields)
+			return false
+		}
+	}
+
+	a.mu.Lock()
+	a.recommended = recommended
+	a.mu.Unlock()
+
+	message := "ventilation recommended"
+	if !recommended {
+		message = "ventilation no longer recommended"
+	}
+	a.logger.Info(message,
+		zap.Float64("delta_grams_per_cubic_meter", delta),
+		zap.String("reason", reason),
+	)
+	a.eventLog.Record(events.TypeAutomationTriggered, "ventilation", message+": "+reason, fields)
+	return true
+}
+
+// Recommended reports whether ventilating is currently recommended
+func (a *Advisor) Recommended() bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.recommended
+}
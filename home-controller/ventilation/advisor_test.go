@@ -0,0 +1,123 @@
+package ventilation
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/mjasion/balena-home/thermostats/automation"
+	"github.com/mjasion/balena-home/thermostats/buffer"
+	"github.com/mjasion/balena-home/thermostats/events"
+	"go.uber.org/zap"
+)
+
+func bleReading(at time.Time, sensorName string, temperature float64, humidity int) *buffer.Reading {
+	return &buffer.Reading{
+		Type: buffer.ReadingTypeBLE,
+		BLE:  &buffer.SensorReading{Timestamp: at, SensorName: sensorName, TemperatureCelsius: temperature, HumidityPercent: humidity},
+	}
+}
+
+// zigbeeReadings returns the separate temperature and humidity readings of a Zigbee device
+func zigbeeReadings(at time.Time, device string, temperature, humidity float64) []*buffer.Reading {
+	return []*buffer.Reading{
+		{Type: buffer.ReadingTypeZigbee, Zigbee: &buffer.ZigbeeReading{Timestamp: at, Device: device, Metric: "temperature_celsius", Value: temperature}},
+		{Type: buffer.ReadingTypeZigbee, Zigbee: &buffer.ZigbeeReading{Timestamp: at, Device: device, Metric: "humidity_percent", Value: humidity}},
+	}
+}
+
+func TestAdvisor_Evaluate(t *testing.T) {
+	var calls []string
+	failing := false
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if failing {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		calls = append(calls, r.URL.Path)
+	}))
+	defer server.Close()
+
+	logger := zap.NewNop()
+	buf := buffer.New(20, logger)
+	eventLog := events.NewLog(10, logger)
+	advisor := New(
+		[]string{"living_room", "bedroom"},
+		"balcony",
+		Thresholds{OnDelta: 2, OffDelta: 0.5, MinIndoorHumidity: 45},
+		automation.Webhook{URL: server.URL + "/boost"},
+		automation.Webhook{URL: server.URL + "/normal"},
+		automation.NewWebhookCaller(time.Second),
+		buf,
+		60,
+		logger,
+	)
+	advisor.SetEventLog(eventLog)
+
+	start := time.Date(2026, 11, 3, 8, 0, 0, 0, time.UTC)
+	steps := []struct {
+		name        string
+		minutes     int
+		outdoor     [2]float64 // Temperature and humidity; zero skips the reading
+		failing     bool
+		calls       int
+		recommended bool
+	}{
+		{"outdoor unknown", 0, [2]float64{}, false, 0, false},
+		{"cold dry outdoor air", 1, [2]float64{5, 80}, false, 1, true}, // About 12.6 g/m³ indoor against 5.4 g/m³
+		{"within hysteresis", 2, [2]float64{15, 90}, false, 1, true},   // About 1.1 g/m³ difference
+		{"webhook fails", 3, [2]float64{20, 85}, true, 1, true},        // Outdoor air is more humid
+		{"retried", 4, [2]float64{20, 85}, false, 2, false},
+		{"stale sensors", 40, [2]float64{}, false, 2, false},
+	}
+	for _, step := range steps {
+		now := start.Add(time.Duration(step.minutes) * time.Minute)
+		if step.outdoor != [2]float64{} {
+			advisor.Observe(bleReading(now, "living_room", 22, 65))
+			advisor.Observe(bleReading(now, "bedroom", 19, 50))
+			advisor.Observe(bleReading(now, "kitchen", 30, 90)) // Not configured
+			for _, reading := range zigbeeReadings(now, "balcony", step.outdoor[0], step.outdoor[1]) {
+				advisor.Observe(reading)
+			}
+		}
+		failing = step.failing
+		advisor.now = func() time.Time { return now }
+		advisor.Evaluate(context.Background())
+		if len(calls) != step.calls || advisor.Recommended() != step.recommended {
+			t.Errorf("%s: expected %d calls and recommended %v, got %v and %v", step.name, step.calls, step.recommended, calls, advisor.Recommended())
+		}
+	}
+
+	if len(calls) != 2 || calls[0] != "/boost" || calls[1] != "/normal" {
+		t.Errorf("Expected /boost then /normal, got %v", calls)
+	}
+	if list := eventLog.List(events.Filter{Type: events.TypeAutomationTriggered}); len(list) != 2 {
+		t.Errorf("Expected 2 triggered events, got %d", len(list))
+	}
+	if list := eventLog.List(events.Filter{Type: events.TypeAutomationFailed}); len(list) != 1 {
+		t.Errorf("Expected 1 failed event, got %d", len(list))
+	}
+	readings := buf.GetAll()
+	if len(readings) != len(steps) {
+		t.Fatalf("Expected a reading per evaluation, got %d", len(readings))
+	}
+	for i, step := range steps {
+		if readings[i].Ventilation.Recommended != step.recommended {
+			t.Errorf("%s: expected reading recommended %v", step.name, step.recommended)
+		}
+	}
+}
+
+func TestAdvisor_DryIndoorAir(t *testing.T) {
+	advisor := New([]string{"living_room"}, "balcony", Thresholds{OnDelta: 2, OffDelta: 0.5, MinIndoorHumidity: 45}, automation.Webhook{}, automation.Webhook{}, nil, buffer.New(10, zap.NewNop()), 60, zap.NewNop())
+
+	// Freezing outdoor air is much drier, but ventilating would dry out the 35% indoor air further
+	if recommended, _ := advisor.decide(false, 5, 35); recommended {
+		t.Error("Expected no recommendation below the minimum indoor humidity")
+	}
+	if recommended, _ := advisor.decide(true, 5, 35); recommended {
+		t.Error("Expected the recommendation to end below the minimum indoor humidity")
+	}
+}
@@ -0,0 +1,20 @@
+package version
+
+import (
+	"net/http"
+
+	"github.com/mjasion/balena-home/thermostats/admin"
+)
+
+// RegisterHandlers registers the version endpoint on the admin server
+func RegisterHandlers(server *admin.Server) {
+	server.HandleFunc("GET /api/version", handleVersion)
+}
+
+// handleVersion handles GET /api/version
+func handleVersion(w http.ResponseWriter, r *http.Request) {
+	admin.WriteJSON(w, http.StatusOK, admin.Response{
+		Success: true,
+		Data:    Get(),
+	})
+}
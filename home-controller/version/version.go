@@ -0,0 +1,70 @@
+package version
+
+import (
+	"fmt"
+	"runtime"
+	"runtime/debug"
+)
+
+// Build information, set at build time with
+//
+//	go build -ldflags "-X github.com/mjasion/balena-home/thermostats/version.Version=v1.2.3 \
+//	  -X github.com/mjasion/balena-home/thermostats/version.Commit=$(git rev-parse HEAD) \
+//	  -X github.com/mjasion/balena-home/thermostats/version.Date=$(date -u +%Y-%m-%dT%H:%M:%SZ)"
+var (
+	Version = "dev"
+	Commit  = ""
+	Date    = ""
+)
+
+// Info describes the running build
+type Info struct {
+	Version   string `json:"version"`
+	Commit    string `json:"commit"`
+	Date      string `json:"date"`
+	GoVersion string `json:"go_version"`
+}
+
+// Get returns the build information
+// Commit and date not set with ldflags fall back to the VCS stamp go build embeds when building
+// from a git checkout, and to "unknown" otherwise
+func Get() Info {
+	info := Info{
+		Version:   Version,
+		Commit:    Commit,
+		Date:      Date,
+		GoVersion: runtime.Version(),
+	}
+	if buildInfo, ok := debug.ReadBuildInfo(); ok {
+		for _, setting := range buildInfo.Settings {
+			switch {
+			case setting.Key == "vcs.revision" && info.Commit == "":
+				info.Commit = setting.Value
+			case setting.Key == "vcs.time" && info.Date == "":
+				info.Date = setting.Value
+			}
+		}
+	}
+	if info.Commit == "" {
+		info.Commit = "unknown"
+	}
+	if info.Date == "" {
+		info.Date = "unknown"
+	}
+	return info
+}
+
+// String formats the build information for the version command
+func (i Info) String() string {
+	return fmt.Sprintf("home-controller %s (commit %s, built %s, %s)", i.Version, i.Commit, i.Date, i.GoVersion)
+}
+
+// Labels returns the build information as metric labels
+func (i Info) Labels() map[string]string {
+	return map[string]string{
+		"version":    i.Version,
+		"commit":     i.Commit,
+		"build_date": i.Date,
+		"go_version": i.GoVersion,
+	}
+}
@@ -0,0 +1,57 @@
+package version
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"testing"
+
+	"github.com/mjasion/balena-home/thermostats/admin"
+	"go.uber.org/zap"
+)
+
+func TestGet(t *testing.T) {
+	Version, Commit, Date = "v1.2.3", "abc123", "2026-01-02T03:04:05Z"
+	defer func() { Version, Commit, Date = "dev", "", "" }()
+
+	info := Get()
+	if info.Version != "v1.2.3" || info.Commit != "abc123" || info.Date != "2026-01-02T03:04:05Z" {
+		t.Errorf("Expected ldflags values, got %+v", info)
+	}
+	if info.GoVersion != runtime.Version() {
+		t.Errorf("Expected go version %s, got %s", runtime.Version(), info.GoVersion)
+	}
+	if labels := info.Labels(); labels["version"] != "v1.2.3" || labels["commit"] != "abc123" {
+		t.Errorf("Expected version and commit labels, got %v", labels)
+	}
+}
+
+func TestGet_Unset(t *testing.T) {
+	// Test binaries carry no VCS stamp
+	info := Get()
+	if info.Version != "dev" || info.Commit == "" || info.Date == "" {
+		t.Errorf("Expected dev version with placeholders, got %+v", info)
+	}
+}
+
+func TestHandleVersion(t *testing.T) {
+	server := admin.New(":0", zap.NewNop())
+	RegisterHandlers(server)
+
+	w := httptest.NewRecorder()
+	server.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/version", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", w.Code)
+	}
+
+	var response struct {
+		Data Info `json:"data"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if response.Data.Version != Version {
+		t.Errorf("Expected version %s, got %s", Version, response.Data.Version)
+	}
+}
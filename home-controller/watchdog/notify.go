@@ -0,0 +1,42 @@
+package watchdog
+
+import (
+	"net"
+	"os"
+	"strconv"
+	"time"
+)
+
+// notify sends a state to systemd over NOTIFY_SOCKET, see sd_notify(3); it does nothing when the
+// service isn't run by systemd with Type=notify or NotifyAccess set
+func notify(state string) error {
+	socket := os.Getenv("NOTIFY_SOCKET")
+	if socket == "" {
+		return nil
+	}
+	// A leading @ names a socket in the abstract namespace
+	if socket[0] == '@' {
+		socket = "\x00" + socket[1:]
+	}
+
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: socket, Net: "unixgram"})
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	_, err = conn.Write([]byte(state))
+	return err
+}
+
+// systemdTimeout returns the WatchdogSec of the service from WATCHDOG_USEC, 0 when systemd
+// doesn't watch this process
+func systemdTimeout() time.Duration {
+	if pid := os.Getenv("WATCHDOG_PID"); pid != "" && pid != strconv.Itoa(os.Getpid()) {
+		return 0
+	}
+	usec, err := strconv.ParseInt(os.Getenv("WATCHDOG_USEC"), 10, 64)
+	if err != nil || usec <= 0 {
+		return 0
+	}
+	return time.Duration(usec) * time.Microsecond
+}
@@ -0,0 +1,224 @@
+package watchdog
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/mjasion/balena-home/thermostats/buffer"
+	"github.com/mjasion/balena-home/thermostats/clock"
+	"github.com/mjasion/balena-home/thermostats/exitcode"
+	"go.uber.org/zap"
+)
+
+// Heartbeat is beaten by a loop each time it makes progress
+// A nil *Heartbeat is valid and ignores beats
+type Heartbeat struct {
+	name       string
+	maxSilence time.Duration
+
+	mu   sync.Mutex
+	last time.Time
+	now  func() time.Time
+}
+
+// Beat records progress
+func (h *Heartbeat) Beat() {
+	if h == nil {
+		return
+	}
+	h.mu.Lock()
+	h.last = h.now()
+	h.mu.Unlock()
+}
+
+// silence returns the time since the last beat
+func (h *Heartbeat) silence(now time.Time) time.Duration {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return now.Sub(h.last)
+}
+
+// Watchdog assesses liveness from heartbeats of the scrape, BLE and push loops; while all are
+// alive it pings the systemd watchdog and touches the heartbeat file a container health check
+// reads, so a hung goroutine gets the service restarted instead of silently losing data
+type Watchdog struct {
+	interval      time.Duration
+	heartbeatFile string // Empty touches no file
+	exitOnStall   bool   // Exit so the restart policy restarts a stalled service
+	exit          func(code int)
+	clock         clock.Clock
+	logger        *zap.Logger
+
+	mu         sync.Mutex
+	heartbeats []*Heartbeat
+	byType     map[buffer.ReadingType]*Heartbeat
+	stalled    bool
+}
+
+// New creates a watchdog checking the heartbeats every checkInterval; when systemd enables its
+// watchdog for the service, checks run at least twice per WatchdogSec
+func New(checkInterval time.Duration, logger *zap.Logger) *Watchdog {
+	if timeout := systemdTimeout(); timeout > 0 && timeout/2 < checkInterval {
+		checkInterval = timeout / 2
+	}
+	return &Watchdog{
+		interval: checkInterval,
+		exit:     os.Exit,
+		clock:    clock.Real,
+		logger:   logger,
+		byType:   make(map[buffer.ReadingType]*Heartbeat),
+	}
+}
+
+// SetClock sets the clock driving checks and heartbeats, e.g. a fake one in tests; call before Register
+func (w *Watchdog) SetClock(c clock.Clock) {
+	w.clock = c
+}
+
+// SetHeartbeatFile touches the file after every check that finds all loops alive
+func (w *Watchdog) SetHeartbeatFile(path string) {
+	w.heartbeatFile = path
+}
+
+// SetExitOnStall exits the process when a loop stalls, for runtimes without systemd or a health check
+func (w *Watchdog) SetExitOnStall(exit bool) {
+	w.exitOnStall = exit
+}
+
+// Register adds a heartbeat that stalls after maxSilence without a beat; registering counts as
+// the first beat, so a loop has maxSilence to start
+func (w *Watchdog) Register(name string, maxSilence time.Duration) *Heartbeat {
+	h := &Heartbeat{name: name, maxSilence: maxSilence, now: w.clock.Now}
+	h.Beat()
+	w.mu.Lock()
+	w.heartbeats = append(w.heartbeats, h)
+	w.mu.Unlock()
+	return h
+}
+
+// WatchReadings registers a heartbeat per reading type, beaten by Observe, e.g. ble for the BLE
+// scan callback or power for the meter scrape loop
+func (w *Watchdog) WatchReadings(readingTypes []buffer.ReadingType, maxSilence time.Duration) {
+	for _, readingType := range readingTypes {
+		h := w.Register("readings_"+string(readingType), maxSilence)
+		w.mu.Lock()
+		w.byType[readingType] = h
+		w.mu.Unlock()
+	}
+}
+
+// Observe beats the heartbeat of the reading's type; register it as a buffer listener
+func (w *Watchdog) Observe(reading *buffer.Reading) {
+	w.mu.Lock()
+	h := w.byType[reading.Type]
+	w.mu.Unlock()
+	h.Beat()
+}
+
+// Stalled returns the names of heartbeats silent for longer than allowed, sorted
+func (w *Watchdog) Stalled() []string {
+	now := w.clock.Now()
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	var stalled []string
+	for _, h := range w.heartbeats {
+		if h.silence(now) > h.maxSilence {
+			stalled = append(stalled, h.name)
+		}
+	}
+	sort.Strings(stalled)
+	return stalled
+}
+
+// Start reports readiness and checks liveness every interval until the context is cancelled
+func (w *Watchdog) Start(ctx context.Context) {
+	w.logger.Info("starting watchdog",
+		zap.Duration("interval", w.interval),
+		zap.Int("heartbeat_count", len(w.heartbeats)),
+		zap.Bool("systemd", os.Getenv("NOTIFY_SOCKET") != ""),
+		zap.String("heartbeat_file", w.heartbeatFile),
+	)
+	if err := notify("READY=1"); err != nil {
+		w.logger.Warn("failed to notify systemd of readiness", zap.Error(err))
+	}
+
+	ticker := w.clock.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	w.Check()
+	for {
+		select {
+		case <-ctx.Done():
+			w.logger.Info("stopping watchdog")
+			notify("STOPPING=1")
+			return
+		case <-ticker.C():
+			w.Check()
+		}
+	}
+}
+
+// Check pings systemd and touches the heartbeat file while every loop is alive; a stalled loop
+// withholds both, letting systemd or the container health check restart the service
+func (w *Watchdog) Check() {
+	stalled := w.Stalled()
+	if len(stalled) > 0 {
+		w.mu.Lock()
+		first := !w.stalled
+		w.stalled = true
+		w.mu.Unlock()
+		if first {
+			w.logger.Error("loops stalled, withholding watchdog pings", zap.Strings("stalled", stalled))
+		}
+		if w.exitOnStall {
+			w.logger.Error("exiting so the service is restarted", zap.Strings("stalled", stalled))
+			w.logger.Sync()
+			w.exit(exitcode.Runtime)
+		}
+		return
+	}
+
+	w.mu.Lock()
+	recovered := w.stalled
+	w.stalled = false
+	w.mu.Unlock()
+	if recovered {
+		w.logger.Info("stalled loops recovered")
+	}
+
+	if err := notify("WATCHDOG=1"); err != nil {
+		w.logger.Warn("failed to ping systemd watchdog", zap.Error(err))
+	}
+	if w.heartbeatFile != "" {
+		if err := touch(w.heartbeatFile); err != nil {
+			w.logger.Warn("failed to touch heartbeat file", zap.String("path", w.heartbeatFile), zap.Error(err))
+		}
+	}
+}
+
+// touch creates the file or updates its modification time; the health check compares it with the
+// wall clock, so the watchdog clock isn't used
+func touch(path string) error {
+	now := time.Now()
+	if err := os.Chtimes(path, now, now); err == nil {
+		return nil
+	}
+	return os.WriteFile(path, nil, 0o644)
+}
+
+// CheckFile reports an error unless the heartbeat file was touched within maxAge, for a
+// container health check
+func CheckFile(path string, maxAge time.Duration) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return fmt.Errorf("no heartbeat: %w", err)
+	}
+	if age := time.Since(info.ModTime()); age > maxAge {
+		return fmt.Errorf("last heartbeat %s ago, expected within %s", age.Round(time.Second), maxAge)
+	}
+	return nil
+}
@@ -0,0 +1,111 @@
+package watchdog
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/mjasion/balena-home/thermostats/buffer"
+	"github.com/mjasion/balena-home/thermostats/clock"
+	"github.com/mjasion/balena-home/thermostats/exitcode"
+	"go.uber.org/zap"
+)
+
+// listenNotify listens on a NOTIFY_SOCKET for the test and returns the datagrams systemd would receive
+func listenNotify(t *testing.T) *net.UnixConn {
+	path := filepath.Join(t.TempDir(), "notify.sock")
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: path, Net: "unixgram"})
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	t.Setenv("NOTIFY_SOCKET", path)
+	return conn
+}
+
+// received returns the next datagram, or "" when none arrives
+func received(conn *net.UnixConn) string {
+	conn.SetReadDeadline(time.Now().Add(100 * time.Millisecond))
+	data := make([]byte, 64)
+	n, err := conn.Read(data)
+	if err != nil {
+		return ""
+	}
+	return string(data[:n])
+}
+
+func TestWatchdog_Check(t *testing.T) {
+	conn := listenNotify(t)
+	heartbeatFile := filepath.Join(t.TempDir(), "alive")
+	clk := clock.NewFake(time.Date(2026, 10, 17, 12, 0, 0, 0, time.UTC))
+
+	w := New(10*time.Second, zap.NewNop())
+	w.SetClock(clk)
+	w.SetHeartbeatFile(heartbeatFile)
+	w.WatchReadings([]buffer.ReadingType{buffer.ReadingTypeBLE}, 5*time.Minute)
+	push := w.Register("push", 2*time.Minute)
+
+	clk.Advance(time.Minute)
+	w.Check()
+	if state := received(conn); state != "WATCHDOG=1" {
+		t.Errorf("Expected a watchdog ping while alive, got %q", state)
+	}
+	if err := CheckFile(heartbeatFile, time.Minute); err != nil {
+		t.Errorf("Expected a fresh heartbeat file, got %v", err)
+	}
+
+	// The push loop hangs while BLE readings keep arriving
+	clk.Advance(2 * time.Minute)
+	w.Observe(&buffer.Reading{Type: buffer.ReadingTypeBLE, BLE: &buffer.SensorReading{}})
+	if stalled := w.Stalled(); len(stalled) != 1 || stalled[0] != "push" {
+		t.Errorf("Expected the push loop to be stalled, got %v", stalled)
+	}
+	w.Check()
+	if state := received(conn); state != "" {
+		t.Errorf("Expected no ping while stalled, got %q", state)
+	}
+
+	push.Beat()
+	w.Check()
+	if state := received(conn); state != "WATCHDOG=1" {
+		t.Errorf("Expected pings to resume after recovery, got %q", state)
+	}
+}
+
+func TestWatchdog_ExitOnStall(t *testing.T) {
+	t.Setenv("NOTIFY_SOCKET", "")
+	clk := clock.NewFake(time.Date(2026, 10, 17, 12, 0, 0, 0, time.UTC))
+	w := New(10*time.Second, zap.NewNop())
+	w.SetClock(clk)
+	w.SetExitOnStall(true)
+	exitCode := -1
+	w.exit = func(code int) { exitCode = code }
+	w.WatchReadings([]buffer.ReadingType{buffer.ReadingTypePower}, time.Minute)
+
+	w.Check()
+	if exitCode != -1 {
+		t.Fatalf("Expected no exit while alive, got %d", exitCode)
+	}
+	clk.Advance(2 * time.Minute)
+	w.Check()
+	if exitCode != exitcode.Runtime {
+		t.Errorf("Expected the runtime exit code on a stall, got %d", exitCode)
+	}
+}
+
+func TestCheckFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "alive")
+	if err := CheckFile(path, time.Minute); err == nil {
+		t.Error("Expected an error for a missing heartbeat file")
+	}
+	if err := touch(path); err != nil {
+		t.Fatalf("Failed to touch: %v", err)
+	}
+	old := time.Now().Add(-time.Hour)
+	os.Chtimes(path, old, old)
+	if err := CheckFile(path, time.Minute); err == nil {
+		t.Error("Expected an error for a stale heartbeat file")
+	}
+}
@@ -0,0 +1,49 @@
+package water
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/mjasion/balena-home/thermostats/collector"
+	"github.com/mjasion/balena-home/thermostats/exitcode"
+)
+
+// Options are the settings of the water collector; the interval is the report interval
+type Options struct {
+	GPIOPin          int     `yaml:"gpioPin"`
+	GPIOBasePath     string  `yaml:"gpioBasePath"`
+	ActiveLow        bool    `yaml:"activeLow"`
+	LitersPerPulse   float64 `yaml:"litersPerPulse"`
+	DebounceMs       int     `yaml:"debounceMs"`
+	SampleIntervalMs int     `yaml:"sampleIntervalMs"`
+	StateFile        string  `yaml:"stateFile"`
+}
+
+func init() {
+	collector.Register("water", func(spec collector.Spec, deps collector.Deps) (collector.Collector, error) {
+		options := Options{
+			GPIOPin:          17,
+			GPIOBasePath:     "/sys/class/gpio",
+			ActiveLow:        true,
+			LitersPerPulse:   1,
+			DebounceMs:       50,
+			SampleIntervalMs: 10,
+			StateFile:        "/data/water_counter.json",
+		}
+		if err := spec.Options.Decode(&options); err != nil {
+			return nil, err
+		}
+		if options.LitersPerPulse <= 0 {
+			return nil, fmt.Errorf("liters per pulse must be positive")
+		}
+		if options.SampleIntervalMs < 1 {
+			return nil, fmt.Errorf("sample interval must be at least 1ms")
+		}
+
+		counter, err := NewPulseCounter(options.GPIOBasePath, options.GPIOPin, options.ActiveLow, time.Duration(options.DebounceMs)*time.Millisecond)
+		if err != nil {
+			return nil, exitcode.DependencyError(fmt.Errorf("failed to initialize water meter GPIO: %w", err))
+		}
+		return NewPoller(counter, deps.Buffer, options.StateFile, options.LitersPerPulse, options.SampleIntervalMs, int(spec.Interval.Seconds()), deps.Logger), nil
+	})
+}
@@ -0,0 +1,181 @@
+package water
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// PulseCounter counts debounced pulses from a reed switch attached to a GPIO pin
+type PulseCounter struct {
+	valuePath string
+	activeLow bool
+	debounce  time.Duration
+
+	mu          sync.Mutex
+	pulses      uint64
+	primed      bool      // Whether the first sample seeded the state
+	stable      bool      // Debounced pin state (true = active)
+	candidate   bool      // Last raw state seen
+	candidateAt time.Time // When the raw state last changed
+}
+
+// state is the persisted counter representation
+type state struct {
+	Pulses    uint64    `json:"pulses"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// headerChipLabels are the labels of the GPIO controllers driving the Raspberry Pi pin header
+var headerChipLabels = []string{"pinctrl-bcm2835", "pinctrl-bcm2711", "pinctrl-rp1"}
+
+// NewPulseCounter creates a pulse counter reading the sysfs GPIO value of the BCM pin,
+// exporting the pin first if needed
+func NewPulseCounter(gpioBasePath string, pin int, activeLow bool, debounce time.Duration) (*PulseCounter, error) {
+	base, err := headerChipBase(gpioBasePath)
+	if err != nil {
+		return nil, err
+	}
+	line := base + pin
+
+	pinPath := filepath.Join(gpioBasePath, fmt.Sprintf("gpio%d", line))
+	if _, err := os.Stat(pinPath); os.IsNotExist(err) {
+		exportPath := filepath.Join(gpioBasePath, "export")
+		if err := os.WriteFile(exportPath, []byte(fmt.Sprintf("%d", line)), 0200); err != nil {
+			return nil, fmt.Errorf("failed to export GPIO %d (sysfs %d): %w", pin, line, err)
+		}
+	}
+
+	// Reed switches are wired as inputs; the direction file may be missing on some kernels
+	directionPath := filepath.Join(pinPath, "direction")
+	if _, err := os.Stat(directionPath); err == nil {
+		if err := os.WriteFile(directionPath, []byte("in"), 0644); err != nil {
+			return nil, fmt.Errorf("failed to set GPIO %d direction: %w", pin, err)
+		}
+	}
+
+	return &PulseCounter{
+		valuePath: filepath.Join(pinPath, "value"),
+		activeLow: activeLow,
+		debounce:  debounce,
+	}, nil
+}
+
+// headerChipBase returns the sysfs number of the pin header controller's first line
+// Kernels since 6.6 number sysfs GPIOs from 512 rather than 0, so BCM pin numbers are offset by
+// the base; without a known controller, e.g. off a Raspberry Pi, pins are numbered from 0
+func headerChipBase(gpioBasePath string) (int, error) {
+	chips, err := filepath.Glob(filepath.Join(gpioBasePath, "gpiochip*"))
+	if err != nil {
+		return 0, fmt.Errorf("failed to list GPIO chips: %w", err)
+	}
+	for _, chip := range chips {
+		label, err := os.ReadFile(filepath.Join(chip, "label"))
+		if err != nil || !slices.Contains(headerChipLabels, strings.TrimSpace(string(label))) {
+			continue
+		}
+		raw, err := os.ReadFile(filepath.Join(chip, "base"))
+		if err != nil {
+			return 0, fmt.Errorf("failed to read GPIO chip base: %w", err)
+		}
+		base, err := strconv.Atoi(strings.TrimSpace(string(raw)))
+		if err != nil {
+			return 0, fmt.Errorf("invalid GPIO chip base in %s: %w", chip, err)
+		}
+		return base, nil
+	}
+	return 0, nil
+}
+
+// Sample reads the pin once and feeds the value to the debouncer
+func (c *PulseCounter) Sample(now time.Time) error {
+	raw, err := os.ReadFile(c.valuePath)
+	if err != nil {
+		return fmt.Errorf("failed to read GPIO value: %w", err)
+	}
+
+	high := strings.TrimSpace(string(raw)) == "1"
+	c.observe(high != c.activeLow, now)
+	return nil
+}
+
+// observe updates the debounced state and counts a pulse on each inactive→active transition
+func (c *PulseCounter) observe(active bool, now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// The state at startup is not an edge: a meter resting on the contact counts no pulse
+	if !c.primed {
+		c.primed = true
+		c.stable, c.candidate, c.candidateAt = active, active, now
+		return
+	}
+
+	if active != c.candidate {
+		c.candidate = active
+		c.candidateAt = now
+		return
+	}
+
+	// Only accept the new state after it has been stable for the debounce period
+	if c.candidate != c.stable && now.Sub(c.candidateAt) >= c.debounce {
+		c.stable = c.candidate
+		if c.stable {
+			c.pulses++
+		}
+	}
+}
+
+// Pulses returns the total number of counted pulses
+func (c *PulseCounter) Pulses() uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.pulses
+}
+
+// Load restores the pulse total from the state file; a missing file starts at zero
+func (c *PulseCounter) Load(path string) error {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read state file: %w", err)
+	}
+
+	var s state
+	if err := json.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf("failed to parse state file: %w", err)
+	}
+
+	c.mu.Lock()
+	c.pulses = s.Pulses
+	c.mu.Unlock()
+	return nil
+}
+
+// Save persists the pulse total to the state file atomically
+func (c *PulseCounter) Save(path string) error {
+	data, err := json.Marshal(state{
+		Pulses:    c.Pulses(),
+		UpdatedAt: time.Now(),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to marshal state: %w", err)
+	}
+
+	tmpPath := path + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0644); err != nil {
+		return fmt.Errorf("failed to write state file: %w", err)
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		return fmt.Errorf("failed to replace state file: %w", err)
+	}
+	return nil
+}
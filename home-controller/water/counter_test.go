@@ -0,0 +1,164 @@
+package water
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// newTestCounter creates a counter backed by a fake sysfs GPIO directory
+func newTestCounter(t *testing.T, debounce time.Duration) (*PulseCounter, string) {
+	t.Helper()
+
+	basePath := t.TempDir()
+	pinPath := filepath.Join(basePath, "gpio17")
+	if err := os.MkdirAll(pinPath, 0755); err != nil {
+		t.Fatalf("Failed to create fake GPIO directory: %v", err)
+	}
+	valuePath := filepath.Join(pinPath, "value")
+	if err := os.WriteFile(valuePath, []byte("1\n"), 0644); err != nil {
+		t.Fatalf("Failed to create fake GPIO value: %v", err)
+	}
+
+	counter, err := NewPulseCounter(basePath, 17, true, debounce)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	return counter, valuePath
+}
+
+func TestPulseCounter_Debounce(t *testing.T) {
+	counter, _ := newTestCounter(t, 50*time.Millisecond)
+	start := time.Now()
+
+	// Contact bounce: short active glitches must not be counted
+	counter.observe(false, start.Add(-time.Second))
+	counter.observe(true, start)
+	counter.observe(false, start.Add(10*time.Millisecond))
+	counter.observe(true, start.Add(20*time.Millisecond))
+	counter.observe(false, start.Add(30*time.Millisecond))
+	if counter.Pulses() != 0 {
+		t.Fatalf("Expected bounces to be ignored, got %d pulses", counter.Pulses())
+	}
+
+	// Stable active state counts exactly one pulse
+	counter.observe(true, start.Add(100*time.Millisecond))
+	counter.observe(true, start.Add(160*time.Millisecond))
+	counter.observe(true, start.Add(200*time.Millisecond))
+	if counter.Pulses() != 1 {
+		t.Fatalf("Expected 1 pulse, got %d", counter.Pulses())
+	}
+
+	// Release and press again for a second pulse
+	counter.observe(false, start.Add(300*time.Millisecond))
+	counter.observe(false, start.Add(360*time.Millisecond))
+	counter.observe(true, start.Add(400*time.Millisecond))
+	counter.observe(true, start.Add(460*time.Millisecond))
+	if counter.Pulses() != 2 {
+		t.Errorf("Expected 2 pulses, got %d", counter.Pulses())
+	}
+}
+
+func TestPulseCounter_SampleActiveLow(t *testing.T) {
+	counter, valuePath := newTestCounter(t, 0)
+	now := time.Now()
+	if err := counter.Sample(now.Add(-time.Millisecond)); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	// With an active-low reed switch, "0" means the contact is closed
+	if err := os.WriteFile(valuePath, []byte("0\n"), 0644); err != nil {
+		t.Fatalf("Failed to write fake GPIO value: %v", err)
+	}
+	for i := 0; i < 2; i++ {
+		if err := counter.Sample(now.Add(time.Duration(i) * time.Millisecond)); err != nil {
+			t.Fatalf("Expected no error, got: %v", err)
+		}
+	}
+
+	if counter.Pulses() != 1 {
+		t.Errorf("Expected 1 pulse, got %d", counter.Pulses())
+	}
+}
+
+func TestPulseCounter_ActiveAtStartup(t *testing.T) {
+	counter, _ := newTestCounter(t, 50*time.Millisecond)
+	start := time.Now()
+
+	// The meter stopped with the magnet on the reed switch: its closed state at startup is no pulse
+	counter.observe(true, start)
+	counter.observe(true, start.Add(100*time.Millisecond))
+	if counter.Pulses() != 0 {
+		t.Fatalf("Expected no pulse for the state at startup, got %d", counter.Pulses())
+	}
+
+	// Opening and closing it again is one
+	counter.observe(false, start.Add(200*time.Millisecond))
+	counter.observe(false, start.Add(260*time.Millisecond))
+	counter.observe(true, start.Add(300*time.Millisecond))
+	counter.observe(true, start.Add(360*time.Millisecond))
+	if counter.Pulses() != 1 {
+		t.Errorf("Expected 1 pulse, got %d", counter.Pulses())
+	}
+}
+
+func TestPulseCounter_Persistence(t *testing.T) {
+	counter, _ := newTestCounter(t, 0)
+	statePath := filepath.Join(t.TempDir(), "water_counter.json")
+
+	// Missing state file starts from zero
+	if err := counter.Load(statePath); err != nil {
+		t.Fatalf("Expected no error for missing state file, got: %v", err)
+	}
+
+	counter.pulses = 1234
+	if err := counter.Save(statePath); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	restored, _ := newTestCounter(t, 0)
+	if err := restored.Load(statePath); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	if restored.Pulses() != 1234 {
+		t.Errorf("Expected 1234 restored pulses, got %d", restored.Pulses())
+	}
+}
+
+func TestNewPulseCounter_ExportFails(t *testing.T) {
+	_, err := NewPulseCounter(filepath.Join(t.TempDir(), "missing"), 17, true, 0)
+	if err == nil {
+		t.Fatal("Expected error when GPIO cannot be exported, got nil")
+	}
+}
+
+func TestNewPulseCounter_ChipBase(t *testing.T) {
+	// Kernel 6.6 numbering on a Raspberry Pi 4: the header controller starts at 512
+	basePath := t.TempDir()
+	chips := map[string][2]string{
+		"gpiochip512": {"pinctrl-bcm2711", "512"},
+		"gpiochip570": {"raspberrypi-exp-gpio", "570"},
+	}
+	for chip, attrs := range chips {
+		chipPath := filepath.Join(basePath, chip)
+		if err := os.MkdirAll(chipPath, 0755); err != nil {
+			t.Fatalf("Failed to create fake GPIO chip: %v", err)
+		}
+		os.WriteFile(filepath.Join(chipPath, "label"), []byte(attrs[0]+"\n"), 0644)
+		os.WriteFile(filepath.Join(chipPath, "base"), []byte(attrs[1]+"\n"), 0644)
+	}
+	exportPath := filepath.Join(basePath, "export")
+	if err := os.WriteFile(exportPath, nil, 0644); err != nil {
+		t.Fatalf("Failed to create fake export file: %v", err)
+	}
+
+	if _, err := NewPulseCounter(basePath, 17, true, 0); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	exported, _ := os.ReadFile(exportPath)
+	if string(exported) != "529" {
+		t.Errorf("Expected BCM 17 to be exported as 529, got %q", exported)
+	}
+}
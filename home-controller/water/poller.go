@@ -0,0 +1,120 @@
+package water
+
+import (
+	"context"
+	"time"
+
+	"github.com/mjasion/balena-home/thermostats/buffer"
+	"go.uber.org/zap"
+)
+
+// Poller samples the pulse counter and periodically reports the consumption total
+type Poller struct {
+	counter        *PulseCounter
+	buffer         *buffer.RingBuffer
+	logger         *zap.Logger
+	stateFile      string
+	litersPerPulse float64
+	sampleInterval time.Duration
+	reportInterval time.Duration
+
+	failedSamples int // Consecutive failed GPIO reads, logged when reads start and stop failing
+}
+
+// NewPoller creates a new water meter poller
+func NewPoller(counter *PulseCounter, buf *buffer.RingBuffer, stateFile string, litersPerPulse float64, sampleIntervalMs, reportIntervalSeconds int, logger *zap.Logger) *Poller {
+	return &Poller{
+		counter:        counter,
+		buffer:         buf,
+		logger:         logger,
+		stateFile:      stateFile,
+		litersPerPulse: litersPerPulse,
+		sampleInterval: time.Duration(sampleIntervalMs) * time.Millisecond,
+		reportInterval: time.Duration(reportIntervalSeconds) * time.Second,
+	}
+}
+
+// Start starts the sampling loop
+func (p *Poller) Start(ctx context.Context) {
+	if err := p.counter.Load(p.stateFile); err != nil {
+		p.logger.Error("failed to restore water meter counter, starting from zero",
+			zap.String("state_file", p.stateFile),
+			zap.Error(err),
+		)
+	}
+
+	p.logger.Info("starting water meter poller",
+		zap.Duration("sample_interval", p.sampleInterval),
+		zap.Duration("report_interval", p.reportInterval),
+		zap.Uint64("restored_pulses", p.counter.Pulses()),
+	)
+
+	sampleTicker := time.NewTicker(p.sampleInterval)
+	defer sampleTicker.Stop()
+	reportTicker := time.NewTicker(p.reportInterval)
+	defer reportTicker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			p.logger.Info("stopping water meter poller")
+			p.report()
+			return
+		case now := <-sampleTicker.C:
+			p.sample(now)
+		case <-reportTicker.C:
+			if err := p.report(); err != nil {
+				p.logger.Info("buffer closed, stopping water meter poller")
+				return
+			}
+		}
+	}
+}
+
+// sample feeds one GPIO read to the counter
+// Reads run every few milliseconds, so a failing pin is logged when it starts and stops failing
+// rather than on every read
+func (p *Poller) sample(now time.Time) {
+	err := p.counter.Sample(now)
+	if err != nil {
+		if p.failedSamples == 0 {
+			p.logger.Error("failed to sample water meter GPIO, pulses are missed until it recovers", zap.Error(err))
+		}
+		p.failedSamples++
+		return
+	}
+	if p.failedSamples > 0 {
+		p.logger.Info("water meter GPIO sampling recovered", zap.Int("failed_samples", p.failedSamples))
+		p.failedSamples = 0
+	}
+}
+
+// report persists the counter and adds the current total to the buffer
+// Returns buffer.ErrClosed once the buffer no longer accepts readings; the counter is still persisted
+func (p *Poller) report() error {
+	if err := p.counter.Save(p.stateFile); err != nil {
+		p.logger.Error("failed to persist water meter counter",
+			zap.String("state_file", p.stateFile),
+			zap.Error(err),
+		)
+	}
+
+	pulses := p.counter.Pulses()
+	liters := float64(pulses) * p.litersPerPulse
+	err := p.buffer.Add(&buffer.Reading{
+		Type: buffer.ReadingTypeWater,
+		Water: &buffer.WaterReading{
+			Timestamp:   time.Now(),
+			TotalLiters: liters,
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	p.logger.Debug("buffered water meter total",
+		zap.Uint64("pulses", pulses),
+		zap.Float64("total_liters", liters),
+	)
+	return nil
+}
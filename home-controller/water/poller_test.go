@@ -0,0 +1,33 @@
+package water
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestPoller_SampleFailuresLoggedOnce(t *testing.T) {
+	counter, valuePath := newTestCounter(t, 0)
+	core, logs := observer.New(zapcore.InfoLevel)
+	poller := NewPoller(counter, nil, "", 1, 10, 15, zap.New(core))
+
+	os.Remove(valuePath)
+	now := time.Now()
+	for i := 0; i < 100; i++ {
+		poller.sample(now.Add(time.Duration(i) * 10 * time.Millisecond))
+	}
+	if errors := logs.FilterMessageSnippet("failed to sample").Len(); errors != 1 {
+		t.Errorf("Expected 1 error line for 100 failed samples, got %d", errors)
+	}
+
+	os.WriteFile(valuePath, []byte("1\n"), 0644)
+	poller.sample(now.Add(time.Second))
+	recovered := logs.FilterMessageSnippet("recovered").All()
+	if len(recovered) != 1 || recovered[0].ContextMap()["failed_samples"] != int64(100) {
+		t.Errorf("Expected a recovery line counting 100 failed samples, got %+v", recovered)
+	}
+}
@@ -0,0 +1,65 @@
+package zigbee2mqtt
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// Expose describes a device capability from the Zigbee2MQTT definition
+type Expose struct {
+	Type     string          `json:"type"`
+	Name     string          `json:"name"`
+	Property string          `json:"property"`
+	Unit     string          `json:"unit"`
+	ValueOn  json.RawMessage `json:"value_on"`
+	ValueOff json.RawMessage `json:"value_off"`
+	Features []Expose        `json:"features"`
+}
+
+// Definition identifies the device model supported by Zigbee2MQTT
+type Definition struct {
+	Model   string   `json:"model"`
+	Vendor  string   `json:"vendor"`
+	Exposes []Expose `json:"exposes"`
+}
+
+// Device is an entry from the bridge/devices topic
+type Device struct {
+	IEEEAddress  string      `json:"ieee_address"`
+	FriendlyName string      `json:"friendly_name"`
+	Type         string      `json:"type"`
+	Definition   *Definition `json:"definition"`
+}
+
+// parseDevices parses the retained bridge/devices payload, skipping the coordinator and unsupported devices
+func parseDevices(payload []byte) ([]Device, error) {
+	var devices []Device
+	if err := json.Unmarshal(payload, &devices); err != nil {
+		return nil, fmt.Errorf("failed to decode devices: %w", err)
+	}
+
+	supported := devices[:0]
+	for _, device := range devices {
+		if device.Type == "Coordinator" || device.Definition == nil {
+			continue
+		}
+		supported = append(supported, device)
+	}
+	return supported, nil
+}
+
+// flattenExposes returns property exposes keyed by property, descending into composite features
+func flattenExposes(exposes []Expose) map[string]Expose {
+	result := make(map[string]Expose)
+	var walk func([]Expose)
+	walk = func(list []Expose) {
+		for _, expose := range list {
+			if expose.Property != "" {
+				result[expose.Property] = expose
+			}
+			walk(expose.Features)
+		}
+	}
+	walk(exposes)
+	return result
+}
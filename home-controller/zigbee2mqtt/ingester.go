@@ -0,0 +1,117 @@
+package zigbee2mqtt
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mjasion/balena-home/thermostats/buffer"
+	"go.uber.org/zap"
+)
+
+// Ingester maps Zigbee2MQTT messages to buffered readings
+type Ingester struct {
+	baseTopic string
+	buffer    *buffer.RingBuffer
+	logger    *zap.Logger
+
+	mu      sync.RWMutex
+	devices map[string]Device  // Devices by friendly name
+	mappers map[string]*mapper // Mappers by friendly name
+}
+
+// NewIngester creates an ingester for devices published under baseTopic
+func NewIngester(baseTopic string, buf *buffer.RingBuffer, logger *zap.Logger) *Ingester {
+	return &Ingester{
+		baseTopic: strings.TrimSuffix(baseTopic, "/"),
+		buffer:    buf,
+		logger:    logger,
+		devices:   make(map[string]Device),
+		mappers:   make(map[string]*mapper),
+	}
+}
+
+// Topics returns the MQTT topic filters the ingester needs
+func (i *Ingester) Topics() []string {
+	return []string{i.baseTopic + "/#"}
+}
+
+// HandleMessage processes a message from the broker
+func (i *Ingester) HandleMessage(topic string, payload []byte) {
+	if !strings.HasPrefix(topic, i.baseTopic+"/") {
+		return
+	}
+	name := strings.TrimPrefix(topic, i.baseTopic+"/")
+
+	if name == "bridge/devices" {
+		i.updateDevices(payload)
+		return
+	}
+	if strings.HasPrefix(name, "bridge/") {
+		return
+	}
+
+	i.mu.RLock()
+	device, ok := i.devices[name]
+	m := i.mappers[name]
+	i.mu.RUnlock()
+	if !ok {
+		// Covers /set, /get and /availability subtopics as well as devices not yet announced
+		i.logger.Debug("ignoring message for unknown Zigbee device", zap.String("topic", topic))
+		return
+	}
+
+	values, err := m.mapState(payload)
+	if err != nil {
+		i.logger.Warn("failed to decode Zigbee device state",
+			zap.String("device", name),
+			zap.Error(err),
+		)
+		return
+	}
+
+	now := time.Now()
+	for _, value := range values {
+		i.buffer.Add(&buffer.Reading{
+			Type: buffer.ReadingTypeZigbee,
+			Zigbee: &buffer.ZigbeeReading{
+				Timestamp:   now,
+				Device:      device.FriendlyName,
+				IEEEAddress: device.IEEEAddress,
+				Model:       device.Definition.Model,
+				Vendor:      device.Definition.Vendor,
+				Class:       m.class,
+				Metric:      value.Metric,
+				Value:       value.Value,
+			},
+		})
+	}
+
+	i.logger.Debug("added Zigbee readings to buffer",
+		zap.String("device", name),
+		zap.Int("reading_count", len(values)),
+	)
+}
+
+// updateDevices replaces the device registry from the bridge/devices payload
+func (i *Ingester) updateDevices(payload []byte) {
+	devices, err := parseDevices(payload)
+	if err != nil {
+		i.logger.Warn("failed to parse Zigbee2MQTT device list", zap.Error(err))
+		return
+	}
+
+	byName := make(map[string]Device, len(devices))
+	mappers := make(map[string]*mapper, len(devices))
+	for _, device := range devices {
+		byName[device.FriendlyName] = device
+		mappers[device.FriendlyName] = newMapper(device)
+	}
+
+	i.mu.Lock()
+	i.devices = byName
+	i.mappers = mappers
+	i.mu.Unlock()
+
+	i.logger.Info("updated Zigbee2MQTT device list", zap.Int("device_count", len(devices)))
+}
@@ -0,0 +1,167 @@
+package zigbee2mqtt
+
+import (
+	"math"
+	"testing"
+
+	"github.com/mjasion/balena-home/thermostats/buffer"
+	"github.com/mjasion/balena-home/thermostats/units"
+	"go.uber.org/zap"
+)
+
+const devicesPayload = `[
+	{"ieee_address": "0x00124b0000000000", "friendly_name": "Coordinator", "type": "Coordinator", "definition": null},
+	{
+		"ieee_address": "0x00158d0001a2b3c4",
+		"friendly_name": "living_room",
+		"type": "EndDevice",
+		"definition": {
+			"model": "WSDCGQ11LM",
+			"vendor": "Aqara",
+			"exposes": [
+				{"type": "numeric", "name": "temperature", "property": "temperature", "unit": "°C"},
+				{"type": "numeric", "name": "humidity", "property": "humidity", "unit": "%"},
+				{"type": "numeric", "name": "pressure", "property": "pressure", "unit": "hPa"},
+				{"type": "numeric", "name": "battery", "property": "battery", "unit": "%"},
+				{"type": "numeric", "name": "voltage", "property": "voltage", "unit": "mV"},
+				{"type": "numeric", "name": "linkquality", "property": "linkquality", "unit": "lqi"}
+			]
+		}
+	},
+	{
+		"ieee_address": "0x00124b0022334455",
+		"friendly_name": "front_door",
+		"type": "EndDevice",
+		"definition": {
+			"model": "SNZB-04",
+			"vendor": "SONOFF",
+			"exposes": [
+				{"type": "binary", "name": "contact", "property": "contact", "value_on": false, "value_off": true}
+			]
+		}
+	},
+	{
+		"ieee_address": "0x00124b0099887766",
+		"friendly_name": "desk_lamp",
+		"type": "Router",
+		"definition": {
+			"model": "UNKNOWN-PLUG",
+			"vendor": "Acme",
+			"exposes": [
+				{"type": "switch", "features": [
+					{"type": "binary", "name": "state", "property": "state", "value_on": "ON", "value_off": "OFF"}
+				]},
+				{"type": "numeric", "name": "power", "property": "power", "unit": "W"}
+			]
+		}
+	}
+]`
+
+func newTestIngester(t *testing.T) (*Ingester, *buffer.RingBuffer) {
+	t.Helper()
+	buf := buffer.New(100, zap.NewNop())
+	ingester := NewIngester("zigbee2mqtt", buf, zap.NewNop())
+	ingester.HandleMessage("zigbee2mqtt/bridge/devices", []byte(devicesPayload))
+	return ingester, buf
+}
+
+func readingsByMetric(buf *buffer.RingBuffer) map[string]*buffer.ZigbeeReading {
+	result := make(map[string]*buffer.ZigbeeReading)
+	for _, reading := range buf.GetAll() {
+		result[reading.Zigbee.Metric] = reading.Zigbee
+	}
+	return result
+}
+
+func TestIngester_KnownClimateSensor(t *testing.T) {
+	ingester, buf := newTestIngester(t)
+
+	ingester.HandleMessage("zigbee2mqtt/living_room",
+		[]byte(`{"temperature":21.4,"humidity":45.2,"pressure":1001.3,"battery":91,"voltage":2985,"linkquality":120,"power_outage_count":3}`))
+
+	readings := readingsByMetric(buf)
+	if len(readings) != 6 {
+		t.Fatalf("Expected 6 readings, got %d", len(readings))
+	}
+
+	temperature := readings["temperature_celsius"]
+	if temperature == nil || temperature.Value != 21.4 {
+		t.Fatalf("Expected temperature 21.4, got %+v", temperature)
+	}
+	if temperature.Class != "climate" || temperature.Vendor != "Aqara" || temperature.IEEEAddress != "0x00158d0001a2b3c4" {
+		t.Errorf("Unexpected labels: %+v", temperature)
+	}
+
+	if voltage := readings["voltage_volts"]; voltage == nil || math.Abs(voltage.Value-2.985) > 1e-9 {
+		t.Errorf("Expected voltage converted to 2.985 V, got %+v", voltage)
+	}
+}
+
+func TestIngester_BinaryValues(t *testing.T) {
+	ingester, buf := newTestIngester(t)
+
+	// SNZB-04 reports contact=false when the door is open
+	ingester.HandleMessage("zigbee2mqtt/front_door", []byte(`{"contact":false}`))
+	ingester.HandleMessage("zigbee2mqtt/desk_lamp", []byte(`{"state":"ON","power":12.5}`))
+
+	readings := readingsByMetric(buf)
+	if contact := readings["contact"]; contact == nil || contact.Value != 1 || contact.Class != "contact" {
+		t.Errorf("Expected contact value_on mapped to 1, got %+v", contact)
+	}
+	if state := readings["state"]; state == nil || state.Value != 1 || state.Class != genericClass {
+		t.Errorf("Expected generic state ON mapped to 1, got %+v", state)
+	}
+	if power := readings["power_watts"]; power == nil || power.Value != 12.5 {
+		t.Errorf("Expected power 12.5 W, got %+v", power)
+	}
+}
+
+func TestIngester_IgnoresUnknownTopics(t *testing.T) {
+	ingester, buf := newTestIngester(t)
+
+	ingester.HandleMessage("zigbee2mqtt/living_room/set", []byte(`{"temperature":1}`))
+	ingester.HandleMessage("zigbee2mqtt/bridge/state", []byte(`{"state":"online"}`))
+	ingester.HandleMessage("zigbee2mqtt/unannounced", []byte(`{"temperature":1}`))
+	ingester.HandleMessage("other/living_room", []byte(`{"temperature":1}`))
+	ingester.HandleMessage("zigbee2mqtt/living_room", []byte(`not json`))
+
+	if buf.Size() != 0 {
+		t.Errorf("Expected no readings, got %d", buf.Size())
+	}
+}
+
+func TestMapper_ModelQuirks(t *testing.T) {
+	device := Device{
+		FriendlyName: "hall_motion",
+		Definition: &Definition{
+			Model:  "RTCGQ11LM",
+			Vendor: "Aqara",
+			Exposes: []Expose{
+				{Property: "occupancy", ValueOn: []byte("true"), ValueOff: []byte("false")},
+				{Property: "illuminance"},
+				{Property: "illuminance_lux", Unit: "lx"},
+			},
+		},
+	}
+
+	values, err := newMapper(device).mapState([]byte(`{"occupancy":true,"illuminance":1800,"illuminance_lux":23}`))
+	if err != nil {
+		t.Fatalf("mapState failed: %v", err)
+	}
+
+	got := make(map[string]float64)
+	for _, v := range values {
+		got[v.Metric] = v.Value
+	}
+	if len(got) != 2 || got["occupancy"] != 1 || got["illuminance_lux"] != 23 {
+		t.Errorf("Expected occupancy and lux only, got %v", got)
+	}
+}
+
+func TestPropertyMetrics_UnitSuffixes(t *testing.T) {
+	for property, spec := range propertyMetrics {
+		if err := units.ValidateName(spec.name, spec.unit); err != nil {
+			t.Errorf("Property %s: %v", property, err)
+		}
+	}
+}
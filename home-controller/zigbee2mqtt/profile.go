@@ -0,0 +1,176 @@
+package zigbee2mqtt
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+
+	"github.com/mjasion/balena-home/thermostats/units"
+)
+
+// metricSpec maps a Zigbee2MQTT property to a metric name and base unit
+type metricSpec struct {
+	name string
+	unit units.Unit // Exposed values are converted to this unit, None keeps them as reported
+}
+
+// propertyMetrics maps well-known exposes properties to consistent metric names
+var propertyMetrics = map[string]metricSpec{
+	"temperature":        {name: "temperature_celsius", unit: units.Celsius},
+	"device_temperature": {name: "device_temperature_celsius", unit: units.Celsius},
+	"humidity":           {name: "humidity_percent", unit: units.Percent},
+	"pressure":           {name: "pressure_hpa", unit: units.Hectopascals},
+	"co2":                {name: "co2_ppm", unit: units.PartsPerMillion},
+	"illuminance_lux":    {name: "illuminance_lux", unit: units.Lux},
+	"battery":            {name: "battery_percent", unit: units.Percent},
+	"voltage":            {name: "voltage_volts", unit: units.Volts},
+	"current":            {name: "current_amperes", unit: units.Amperes},
+	"power":              {name: "power_watts", unit: units.Watts},
+	"energy":             {name: "energy_kwh", unit: units.KilowattHours},
+	"linkquality":        {name: "linkquality"},
+	"contact":            {name: "contact"},
+	"occupancy":          {name: "occupancy"},
+	"water_leak":         {name: "water_leak"},
+	"state":              {name: "state"},
+}
+
+// modelProfile describes quirks of a known device model
+type modelProfile struct {
+	class   string            // Device class label, e.g. climate or contact
+	aliases map[string]string // Model-specific property renames to propertyMetrics keys
+	ignore  []string          // Properties that duplicate or misrepresent another metric
+}
+
+// knownModels contains profiles for common Aqara and Sonoff devices
+// Unknown models are still mapped from their exposes, labelled with the generic class
+var knownModels = map[string]modelProfile{
+	// Aqara
+	"WSDCGQ11LM": {class: "climate"},
+	"WSDCGQ01LM": {class: "climate"},
+	"MCCGQ11LM":  {class: "contact"},
+	"MCCGQ01LM":  {class: "contact"},
+	"RTCGQ11LM":  {class: "motion", ignore: []string{"illuminance"}},
+	"RTCGQ01LM":  {class: "motion"},
+	// The P1 motion sensor reports lux under the plain illuminance property
+	"RTCGQ14LM": {class: "motion", aliases: map[string]string{"illuminance": "illuminance_lux"}},
+	"SJCGQ11LM": {class: "water_leak"},
+	"SP-EUC01":  {class: "plug"},
+	"ZNCZ04LM":  {class: "plug"},
+	// Sonoff
+	"SNZB-02":   {class: "climate"},
+	"SNZB-02D":  {class: "climate"},
+	"SNZB-03":   {class: "motion"},
+	"SNZB-04":   {class: "contact"},
+	"S31ZB":     {class: "plug"},
+	"S26R2ZB":   {class: "plug"},
+	"BASICZBR3": {class: "switch"},
+	"ZBMINI":    {class: "switch"},
+}
+
+const genericClass = "generic"
+
+// Value is a single mapped metric value
+type Value struct {
+	Metric string
+	Value  float64
+}
+
+// mapper converts state payloads of one device to metric values
+type mapper struct {
+	class   string
+	aliases map[string]string
+	ignore  map[string]bool
+	exposes map[string]Expose
+}
+
+// newMapper builds the mapper for a device from its model profile and exposes
+func newMapper(device Device) *mapper {
+	m := &mapper{
+		class:   genericClass,
+		ignore:  make(map[string]bool),
+		exposes: flattenExposes(device.Definition.Exposes),
+	}
+	if profile, ok := knownModels[device.Definition.Model]; ok {
+		m.class = profile.class
+		m.aliases = profile.aliases
+		for _, property := range profile.ignore {
+			m.ignore[property] = true
+		}
+	}
+	return m
+}
+
+// mapState converts a device state payload to metric values
+func (m *mapper) mapState(payload []byte) ([]Value, error) {
+	var state map[string]json.RawMessage
+	if err := json.Unmarshal(payload, &state); err != nil {
+		return nil, err
+	}
+
+	var values []Value
+	for property, raw := range state {
+		if m.ignore[property] {
+			continue
+		}
+		expose, exposed := m.exposes[property]
+		key := property
+		if alias, ok := m.aliases[property]; ok {
+			key = alias
+		}
+		spec, ok := propertyMetrics[key]
+		if !ok {
+			continue
+		}
+		// Devices with exposes metadata only report what they declare
+		if len(m.exposes) > 0 && !exposed {
+			continue
+		}
+
+		value, ok := convertValue(raw, expose)
+		if !ok {
+			continue
+		}
+		// Values in units we don't know how to convert are kept as reported
+		if spec.unit != units.None && expose.Unit != "" {
+			if converted, err := units.Convert(value, expose.Unit, spec.unit); err == nil {
+				value = converted
+			}
+		}
+		values = append(values, Value{Metric: spec.name, Value: value})
+	}
+	return values, nil
+}
+
+// convertValue converts a JSON state value to a float using the expose's binary values
+func convertValue(raw json.RawMessage, expose Expose) (float64, bool) {
+	var number float64
+	if err := json.Unmarshal(raw, &number); err == nil {
+		return number, true
+	}
+
+	if len(expose.ValueOn) > 0 && bytes.Equal(raw, expose.ValueOn) {
+		return 1, true
+	}
+	if len(expose.ValueOff) > 0 && bytes.Equal(raw, expose.ValueOff) {
+		return 0, true
+	}
+
+	var flag bool
+	if err := json.Unmarshal(raw, &flag); err == nil {
+		if flag {
+			return 1, true
+		}
+		return 0, true
+	}
+
+	var text string
+	if err := json.Unmarshal(raw, &text); err == nil {
+		switch strings.ToUpper(text) {
+		case "ON", "OPEN":
+			return 1, true
+		case "OFF", "CLOSED":
+			return 0, true
+		}
+	}
+	return 0, false
+}